@@ -0,0 +1,9 @@
+// api/api.go
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI 3 document describing every route the server exposes
+//
+//go:embed openapi.yaml
+var Spec []byte
@@ -0,0 +1,170 @@
+// cmd/server/admin.go
+package main
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/database"
+	"api-server/internal/model"
+	"api-server/internal/validation"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// createAdmin bootstraps an admin account without needing psql access
+func createAdmin(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	email := flags.String("email", "", "admin email address (required)")
+	username := flags.String("username", "", "login name (defaults to the part of the email before @)")
+	firstName := flags.String("first-name", "Admin", "first name")
+	lastName := flags.String("last-name", "", "last name")
+	tenantSlug := flags.String("tenant", model.DefaultTenantSlug, "slug of the tenant the admin belongs to")
+	flags.Parse(args)
+
+	// Keep the password out of shell history and process listings
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		return errors.New("ADMIN_PASSWORD must be set")
+	}
+
+	if *username == "" {
+		*username, _, _ = strings.Cut(*email, "@")
+	}
+
+	req := model.CreateUserRequest{
+		FirstName: *firstName,
+		LastName:  *lastName,
+		Username:  *username,
+		Password:  password,
+		Role:      "admin",
+		Email:     *email,
+	}
+	if err := validation.Struct(&req); err != nil {
+		return err
+	}
+
+	db, err := database.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	t, err := model.GetTenantBySlug(ctx, db, *tenantSlug)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return fmt.Errorf("tenant %q does not exist", *tenantSlug)
+		}
+		return err
+	}
+
+	user, err := model.CreateUser(ctx, db, t.ID, req)
+	if err != nil {
+		if model.IsUniqueViolation(err, "users_username_key") {
+			return fmt.Errorf("username %q already exists", req.Username)
+		}
+		if model.IsUniqueViolation(err, "users_email_key") {
+			return fmt.Errorf("email %q already exists", req.Email)
+		}
+		return err
+	}
+
+	log.Printf("Created admin %s (%s) in tenant %s", user.Username, user.ID, t.Slug)
+	return nil
+}
+
+// createTenant adds a tenant and prints its API key, which is not stored
+// and cannot be shown again
+func createTenant(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("create-tenant", flag.ExitOnError)
+	slug := flags.String("slug", "", "short name used in the X-Tenant header and as subdomain (required)")
+	name := flags.String("name", "", "display name (required)")
+	bucketPrefix := flags.String("bucket-prefix", "", "object name prefix for the tenant's traces")
+	withKey := flags.Bool("api-key", true, "generate an API key that selects the tenant")
+	flags.Parse(args)
+
+	req := model.CreateTenantRequest{Slug: *slug, Name: *name}
+	if *bucketPrefix != "" {
+		req.BucketPrefix = bucketPrefix
+	}
+	if err := validation.Struct(&req); err != nil {
+		return err
+	}
+
+	var apiKey string
+	var apiKeyHash *string
+	if *withKey {
+		var err error
+		if apiKey, err = model.NewAPIKey(); err != nil {
+			return err
+		}
+		hash := model.HashAPIKey(apiKey)
+		apiKeyHash = &hash
+	}
+
+	db, err := database.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	t, err := model.CreateTenant(ctx, db, req, apiKeyHash)
+	if err != nil {
+		if model.IsUniqueViolation(err, "tenants_slug_key") {
+			return fmt.Errorf("tenant %q already exists", req.Slug)
+		}
+		return err
+	}
+
+	log.Printf("Created tenant %s (%s)", t.Slug, t.ID)
+	if apiKey != "" {
+		fmt.Println(apiKey)
+	}
+	return nil
+}
+
+// resetMFA turns off a user's MFA, for the last admin locked out of their
+// authenticator app with no recovery codes left
+func resetMFA(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("reset-mfa", flag.ExitOnError)
+	username := flags.String("username", "", "login name of the user (required)")
+	tenantSlug := flags.String("tenant", model.DefaultTenantSlug, "slug of the tenant the user belongs to")
+	flags.Parse(args)
+
+	if *username == "" {
+		return errors.New("-username is required")
+	}
+
+	db, err := database.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	t, err := model.GetTenantBySlug(ctx, db, *tenantSlug)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return fmt.Errorf("tenant %q does not exist", *tenantSlug)
+		}
+		return err
+	}
+	user, err := model.GetUserByUsername(ctx, db, t.ID, *username)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return fmt.Errorf("user %q does not exist", *username)
+		}
+		return err
+	}
+
+	if err := model.DeleteMFA(ctx, db, t.ID, user.ID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return fmt.Errorf("user %q has no MFA enrollment", *username)
+		}
+		return err
+	}
+	log.Printf("Reset MFA of %s (%s) in tenant %s", user.Username, user.ID, t.Slug)
+	return nil
+}
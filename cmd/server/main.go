@@ -3,98 +3,92 @@ package main
 
 import (
 	"api-server/internal/config"
-	"api-server/internal/database"
-	"api-server/internal/handler"
+	"api-server/internal/logging"
+	"api-server/internal/secrets"
+	"context"
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
-
-	"github.com/IBM/sarama"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/collectors"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
-func main() {
-	cfg := config.NewConfig()
+// command is one subcommand of the api-server binary
+type command struct {
+	name        string
+	description string
+	run         func(ctx context.Context, cfg *config.Config, args []string) error
+}
 
-	db, err := database.NewPostgresConnection(cfg)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-	defer db.Close()
+var commands = []command{
+	{"serve", "Run the HTTP API (default)", serve},
+	{"migrate", "Apply pending database migrations", runMigrations},
+	{"create-admin", "Create an admin user", createAdmin},
+	{"create-tenant", "Create a tenant and print its API key", createTenant},
+	{"reset-mfa", "Turn off a user's MFA after a lockout", resetMFA},
+	{"seed", "Load demo users, instructors, courses and traces", seedData},
+	{"reconcile-storage", "Compare trace records with the objects in storage", reconcileStorage},
+	{"replay-events", "Re-publish pdf-upload events for existing traces", replayEvents},
+}
 
-	brokers := []string{cfg.KAFKA_BROKER}
+func main() {
+	// Global flags come before the subcommand
+	global := flag.NewFlagSet("api-server", flag.ExitOnError)
+	global.Usage = usage
+	configPath := global.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables take precedence")
+	global.Parse(os.Args[1:])
+
+	// Running the binary without a subcommand starts the server, as before
+	name, args := "serve", global.Args()
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	kafkaConfig := sarama.NewConfig()
-	kafkaConfig.Producer.Return.Successes = true
-	producer, err := sarama.NewSyncProducer(brokers, kafkaConfig)
-	if err != nil {
-		log.Fatalf("Failed to initialize Kafka producer: %v", err)
+	if name == "help" {
+		usage()
+		return
 	}
-	defer producer.Close()
 
-	// Create a new ServeMux
-	mux := http.NewServeMux()
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
 
-	// Create a custom Prometheus registry to avoid conflicts with default registry
-	reg := prometheus.NewRegistry()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Register collectors with the custom registry
-	if err := reg.Register(collectors.NewGoCollector()); err != nil {
-		log.Printf("Failed to register Go collector: %v", err)
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if err := reg.Register(collectors.NewBuildInfoCollector()); err != nil {
-		log.Printf("Failed to register BuildInfo collector: %v", err)
+	if err := logging.Setup(cfg.LogLevel); err != nil {
+		log.Fatalf("Invalid LOG_LEVEL: %v", err)
 	}
-
-	// Define and register the custom counter metric
-	requestCounter := prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests per endpoint",
-		},
-		[]string{"path", "method"},
-	)
-	if err := reg.Register(requestCounter); err != nil {
-		log.Fatalf("Failed to register requestCounter: %v", err)
+	// Fetch secrets referenced from Vault or Secret Manager before any command runs
+	if err := secrets.Resolve(ctx, cfg); err != nil {
+		log.Fatalf("Failed to resolve secrets: %v", err)
 	}
-
-	// Middleware to count requests and pass to the handler
-	countRequests := func(path string, next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Increment the counter with the path and method
-			requestCounter.WithLabelValues(path, r.Method).Inc()
-			next.ServeHTTP(w, r)
-		})
+	if err := cmd.run(ctx, cfg, args); err != nil {
+		log.Fatalf("%s: %v", cmd.name, err)
 	}
+}
 
-	// create /healthz endpoint to check if the server is running
-	healthHandler := handler.NewHealthHandler(db)
-	mux.Handle("/healthz", countRequests("/healthz", healthHandler))
-
-	// User endpoint
-	userHandler := handler.NewUserHandler(db)
-	mux.Handle("/v1/user", countRequests("/v1/user", userHandler))
-
-	// Instructor endpoint
-	instructorHandler := handler.NewInstructorHandler(db)
-	mux.Handle("/v1/instructor", countRequests("/v1/instructor", instructorHandler))
-
-	courseHandler := handler.NewCourseHandler(db, cfg, producer)
-	mux.Handle("POST /v1/course", countRequests("/v1/course", http.HandlerFunc(courseHandler.CreateCourse)))
-	mux.Handle("GET /v1/course/{course_id}", countRequests("/v1/course/{course_id}", http.HandlerFunc(courseHandler.GetCourseByID)))
-	mux.Handle("PATCH /v1/course/{course_id}", countRequests("/v1/course/{course_id}", http.HandlerFunc(courseHandler.PatchCourse)))
-	mux.Handle("DELETE /v1/course/{course_id}", countRequests("/v1/course/{course_id}", http.HandlerFunc(courseHandler.DeleteCourseByID)))
-	mux.Handle("GET /v1/course/{course_id}/trace", countRequests("/v1/course/{course_id}/trace", http.HandlerFunc(courseHandler.GetTracesByCourseID)))
-	mux.Handle("POST /v1/course/{course_id}/trace", countRequests("/v1/course/{course_id}/trace", http.HandlerFunc(courseHandler.HandleTraceUpload)))
-	mux.Handle("GET /v1/course/{course_id}/trace/{trace_id}", countRequests("/v1/course/{course_id}/trace/{trace_id}", http.HandlerFunc(courseHandler.GetTraceByID)))
-	mux.Handle("DELETE /v1/course/{course_id}/trace/{trace_id}", countRequests("/v1/course/{course_id}/trace/{trace_id}", http.HandlerFunc(courseHandler.DeleteTraceByID)))
-
-	// Use the custom registry for the /metrics endpoint
-	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
-
-	log.Println("Server starting on :3000")
-	if err := http.ListenAndServe(":3000", mux); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: api-server [--config file.yaml] <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", cmd.name, cmd.description)
 	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'api-server <command> -h' for a command's flags.")
 }
@@ -0,0 +1,64 @@
+// cmd/server/migrate.go
+package main
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/database"
+	"api-server/internal/migrate"
+	"api-server/migrations"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// runMigrations applies the embedded SQL migrations
+func runMigrations(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list pending migrations without applying them")
+	baseline := flags.String("baseline", "", "record migrations up to this version (e.g. 008) as applied without running them, for databases migrated by hand")
+	flags.Parse(args)
+
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	switch {
+	case *dryRun:
+		pending, err := migrate.Pending(ctx, db, all)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			log.Println("Database is up to date")
+		}
+		for _, m := range pending {
+			log.Printf("Pending: %s", m.Name)
+		}
+		return nil
+
+	case *baseline != "":
+		recorded, err := migrate.Baseline(ctx, db, all, *baseline)
+		for _, m := range recorded {
+			log.Printf("Recorded without running: %s", m.Name)
+		}
+		return err
+
+	default:
+		applied, err := migrate.Up(ctx, db, all)
+		for _, m := range applied {
+			log.Printf("Applied: %s", m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			log.Println("Database is up to date")
+		}
+		return err
+	}
+}
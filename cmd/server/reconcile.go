@@ -0,0 +1,45 @@
+// cmd/server/reconcile.go
+package main
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/database"
+	"api-server/internal/lifecycle"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// reconcileStorage reports traces whose object is gone and objects without a trace
+func reconcileStorage(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("reconcile-storage", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "mark traces whose object is missing as failed")
+	flags.Parse(args)
+
+	db, err := database.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	store := storage.NewGCS(cfg)
+	defer store.Close()
+
+	report, err := lifecycle.NewManager(repository.NewPostgres(db), store, cfg).Reconcile(ctx, *fix)
+	if err != nil {
+		return err
+	}
+
+	for _, trace := range report.Missing {
+		log.Printf("Missing object for trace %s: %s", trace.ID, trace.BucketURL)
+	}
+	for _, object := range report.Orphaned {
+		log.Printf("Orphaned object: %s (%s)", object.URL, object.StorageClass)
+	}
+	log.Printf("Checked %d traces: %d missing, %d orphaned objects, %d marked failed",
+		report.Checked, len(report.Missing), len(report.Orphaned), report.Fixed)
+	return nil
+}
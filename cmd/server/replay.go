@@ -0,0 +1,164 @@
+// cmd/server/replay.go
+package main
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/database"
+	"api-server/internal/model"
+	"api-server/internal/publisher"
+	"api-server/internal/resilience"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+)
+
+// replayBatchSize is how many traces are read from the database at a time
+const replayBatchSize = 500
+
+// replayEvents re-publishes the pdf-upload events of existing traces, for a
+// consumer rebuilding its index from scratch. Events go straight to Kafka at
+// a throttled rate rather than through the outbox, and traces' publish
+// status is left as it is.
+func replayEvents(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("replay-events", flag.ExitOnError)
+	tenantSlug := flags.String("tenant", "", "only traces of this tenant (default every tenant)")
+	courseID := flags.String("course", "", "only traces of this course ID")
+	since := flags.String("since", "", "only traces uploaded at or after this time (YYYY-MM-DD or RFC 3339)")
+	until := flags.String("until", "", "only traces uploaded before this time (YYYY-MM-DD or RFC 3339)")
+	status := flags.String("status", "", "only traces with this processing status (uploaded, processed or failed)")
+	perSecond := flags.Float64("rate", 50, "events published per second at most")
+	topic := flags.String("topic", model.TopicPDFUpload, "topic to publish to")
+	dryRun := flags.Bool("dry-run", false, "count the matching traces without publishing")
+	flags.Parse(args)
+
+	var filter model.ReplayFilter
+	var err error
+	if *courseID != "" {
+		id, err := uuid.Parse(*courseID)
+		if err != nil {
+			return fmt.Errorf("-course: %q is not a UUID", *courseID)
+		}
+		filter.CourseID = &id
+	}
+	if filter.Since, err = parseReplayTime("since", *since); err != nil {
+		return err
+	}
+	if filter.Until, err = parseReplayTime("until", *until); err != nil {
+		return err
+	}
+	switch *status {
+	case "", "uploaded", "processed", "failed":
+		filter.Status = *status
+	default:
+		return fmt.Errorf("-status: must be uploaded, processed or failed, got %q", *status)
+	}
+	if *perSecond <= 0 {
+		return errors.New("-rate: must be positive")
+	}
+
+	db, err := database.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	if *tenantSlug != "" {
+		t, err := model.GetTenantBySlug(ctx, db, *tenantSlug)
+		if err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				return fmt.Errorf("tenant %q does not exist", *tenantSlug)
+			}
+			return err
+		}
+		filter.TenantID = &t.ID
+	}
+
+	// Publishes are retried like the server's, behind their own breaker
+	var pub publisher.Publisher
+	if !*dryRun {
+		kafka, err := publisher.New(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize Kafka producer: %w", err)
+		}
+		pub = publisher.NewResilient(kafka, resilience.RetryPolicy{
+			Attempts:  cfg.RetryMaxAttempts,
+			BaseDelay: cfg.RetryBaseDelay,
+			MaxDelay:  cfg.RetryMaxDelay,
+		}, resilience.NewBreaker("kafka", cfg.BreakerFailureThreshold, cfg.BreakerCooldown), nil)
+		defer pub.Close()
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(*perSecond), 1)
+	var (
+		afterCreated time.Time
+		afterID      uuid.UUID
+		replayed     int
+	)
+	for {
+		traces, err := model.ListReplayTraces(ctx, db, filter, afterCreated, afterID, replayBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, trace := range traces {
+			if !*dryRun {
+				// A video link's event points the pipeline at the video
+				bucketPath := trace.BucketURL
+				if trace.LinkURL != nil {
+					bucketPath = *trace.LinkURL
+				}
+				payload, err := model.PDFUploadEvent(trace.ID, *trace.Course, *trace.Instructor, bucketPath, trace.ContentType, trace.TenantSlug)
+				if err != nil {
+					return err
+				}
+				if err := limiter.Wait(ctx); err != nil {
+					return replayStopped(replayed, trace.Trace, err)
+				}
+				if err := pub.Publish(ctx, *topic, payload); err != nil {
+					return replayStopped(replayed, trace.Trace, err)
+				}
+			}
+			replayed++
+			if replayed%1000 == 0 {
+				log.Printf("Replayed %d events, up to traces uploaded at %s", replayed, trace.DateCreated.Format(time.RFC3339))
+			}
+		}
+		if len(traces) < replayBatchSize {
+			break
+		}
+		last := traces[len(traces)-1]
+		afterCreated, afterID = last.DateCreated, last.ID
+	}
+
+	if *dryRun {
+		log.Printf("%d traces match, nothing published (-dry-run)", replayed)
+	} else {
+		log.Printf("Replayed %d events to %s", replayed, *topic)
+	}
+	return nil
+}
+
+// replayStopped reports how far a replay got, and where to resume it
+func replayStopped(replayed int, next model.Trace, err error) error {
+	return fmt.Errorf("stopped after %d events, resume with -since %s: %w",
+		replayed, next.DateCreated.Format(time.RFC3339Nano), err)
+}
+
+// parseReplayTime parses a -since or -until flag, nil when it is empty
+func parseReplayTime(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("-%s: %q must be YYYY-MM-DD or RFC 3339", name, value)
+}
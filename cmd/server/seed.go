@@ -0,0 +1,67 @@
+// cmd/server/seed.go
+package main
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/database"
+	"api-server/internal/seed"
+	"api-server/internal/storage"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// seedData loads the embedded demo fixtures
+func seedData(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	force := flags.Bool("force", false, "allow seeding outside ENV=development")
+	skipTraces := flags.Bool("skip-traces", false, "don't create example traces")
+	flags.Parse(args)
+
+	// The fixtures contain well-known passwords
+	if !cfg.Development() && !*force {
+		return errors.New("refusing to seed outside ENV=development without -force")
+	}
+
+	fixtures, err := seed.LoadFixtures()
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	// Example traces need somewhere to put their PDFs; only write fake
+	// objects to the local storage emulator, never to a real bucket
+	var store storage.Storage
+	if !*skipTraces {
+		if cfg.StorageEmulatorHost != "" {
+			gcs := storage.NewGCS(cfg)
+			defer gcs.Close()
+			store = gcs
+		} else {
+			log.Println("STORAGE_EMULATOR_HOST is not set, skipping example traces")
+		}
+	}
+
+	result, err := seed.Load(ctx, db, store, fixtures)
+	if errors.Is(err, seed.ErrAlreadySeeded) {
+		log.Println("Seed data is already loaded, nothing to do")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Seeded %d users, %d instructors, %d courses and %d traces",
+		result.Users, result.Instructors, result.Courses, result.Traces)
+	for _, user := range fixtures.Users {
+		log.Printf("Login: %s / %s", user.Username, user.Password)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+// cmd/server/serve.go
+package main
+
+import (
+	"api-server/internal/app"
+	"api-server/internal/config"
+	"context"
+	"flag"
+)
+
+// serve runs the HTTP API along with the outbox relay and lifecycle manager
+func serve(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Parse(args)
+
+	// Answer liveness probes while waiting on Postgres and Kafka
+	if cfg.StartupDegraded {
+		return app.RunDegraded(ctx, cfg)
+	}
+
+	server, err := app.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	return server.Run(ctx)
+}
@@ -0,0 +1,193 @@
+// internal/apierror/apierror.go
+package apierror
+
+import (
+	"api-server/internal/i18n"
+	"api-server/internal/validation"
+	"fmt"
+	"net/http"
+)
+
+// Code is a stable, machine-readable error identifier clients can branch on
+type Code string
+
+// Request errors
+const (
+	CodeInvalidRequestBody Code = "INVALID_REQUEST_BODY"
+	CodeValidationFailed   Code = "VALIDATION_FAILED"
+	CodeInvalidID          Code = "INVALID_ID"
+	CodeInvalidQuery       Code = "INVALID_QUERY"
+	CodeInvalidReference   Code = "INVALID_REFERENCE"
+	CodeInvalidPatch       Code = "INVALID_PATCH"
+	CodePatchTestFailed    Code = "PATCH_TEST_FAILED"
+	CodeMethodNotAllowed   Code = "METHOD_NOT_ALLOWED"
+	CodePayloadTooLarge    Code = "PAYLOAD_TOO_LARGE"
+	CodeUnsupportedType    Code = "UNSUPPORTED_MEDIA_TYPE"
+	CodeRateLimited        Code = "RATE_LIMITED"
+	CodeRedirectNotAllowed Code = "REDIRECT_NOT_ALLOWED"
+)
+
+// Authentication and authorization errors
+const (
+	CodeAuthenticationRequired  Code = "AUTHENTICATION_REQUIRED"
+	CodeInvalidCredentials      Code = "INVALID_CREDENTIALS"
+	CodeInsufficientPermissions Code = "INSUFFICIENT_PERMISSIONS"
+	CodeInvalidAPIKey           Code = "INVALID_API_KEY"
+	CodeInvalidToken            Code = "INVALID_TOKEN"
+	CodeUnknownProvider         Code = "UNKNOWN_PROVIDER"
+	CodeEmailRequired           Code = "EMAIL_REQUIRED"
+	CodeMFARequired             Code = "MFA_REQUIRED"
+	CodeMFAEnrollmentRequired   Code = "MFA_ENROLLMENT_REQUIRED"
+	CodeInsufficientScope       Code = "INSUFFICIENT_SCOPE"
+	CodeInvalidMFACode          Code = "INVALID_MFA_CODE"
+	CodeIPNotAllowed            Code = "IP_NOT_ALLOWED"
+	CodeAccountDeactivated      Code = "ACCOUNT_DEACTIVATED"
+	CodePasswordResetRequired   Code = "PASSWORD_RESET_REQUIRED"
+	CodeMFALocked               Code = "MFA_LOCKED"
+)
+
+// Resource errors
+const (
+	CodeTenantNotFound     Code = "TENANT_NOT_FOUND"
+	CodeUserNotFound       Code = "USER_NOT_FOUND"
+	CodeDataJobNotFound    Code = "DATA_JOB_NOT_FOUND"
+	CodeExportNotReady     Code = "EXPORT_NOT_READY"
+	CodeContentNotReady    Code = "CONTENT_NOT_READY"
+	CodeNoTraceText        Code = "NO_TRACE_TEXT"
+	CodeThumbnailNotReady  Code = "THUMBNAIL_NOT_READY"
+	CodeNoThumbnail        Code = "NO_THUMBNAIL"
+	CodeCourseNotFound     Code = "COURSE_NOT_FOUND"
+	CodeCourseArchived     Code = "COURSE_ARCHIVED"
+	CodeTraceNotFound      Code = "TRACE_NOT_FOUND"
+	CodeTraceIsLink        Code = "TRACE_IS_LINK"
+	CodeCommentNotFound    Code = "COMMENT_NOT_FOUND"
+	CodeFavoriteNotFound   Code = "FAVORITE_NOT_FOUND"
+	CodeInstructorNotFound Code = "INSTRUCTOR_NOT_FOUND"
+	CodeFeatureNotFound    Code = "FEATURE_NOT_FOUND"
+	CodeDependencyNotFound Code = "DEPENDENCY_NOT_FOUND"
+	CodeCaptureNotFound    Code = "CAPTURE_NOT_FOUND"
+	CodeUsernameTaken      Code = "USERNAME_TAKEN"
+	CodeEmailTaken         Code = "EMAIL_TAKEN"
+	CodeSlugTaken          Code = "SLUG_TAKEN"
+	CodeTenantInUse        Code = "TENANT_IN_USE"
+	CodeMFANotEnrolled     Code = "MFA_NOT_ENROLLED"
+	CodeMFAAlreadyEnabled  Code = "MFA_ALREADY_ENABLED"
+	CodeSessionNotFound    Code = "SESSION_NOT_FOUND"
+
+	CodeNotificationNotFound Code = "NOTIFICATION_NOT_FOUND"
+
+	CodeCourseVersionNotFound Code = "COURSE_VERSION_NOT_FOUND"
+
+	CodeServiceAccountNotFound  Code = "SERVICE_ACCOUNT_NOT_FOUND"
+	CodeServiceAccountNameTaken Code = "SERVICE_ACCOUNT_NAME_TAKEN"
+
+	CodeCanvasNotConnected    Code = "CANVAS_NOT_CONNECTED"
+	CodeCanvasCourseNotLinked Code = "CANVAS_COURSE_NOT_LINKED"
+	CodeCanvasCourseLinked    Code = "CANVAS_COURSE_LINKED"
+	CodeCanvasSyncNotFound    Code = "CANVAS_SYNC_NOT_FOUND"
+	CodeCanvasSyncInProgress  Code = "CANVAS_SYNC_IN_PROGRESS"
+
+	CodeUploadSessionNotFound Code = "UPLOAD_SESSION_NOT_FOUND"
+	CodeUploadSessionInUse    Code = "UPLOAD_SESSION_IN_USE"
+
+	CodeShareLinkNotFound Code = "SHARE_LINK_NOT_FOUND"
+	CodeAvatarNotFound    Code = "AVATAR_NOT_FOUND"
+)
+
+// Quota errors
+const (
+	CodeQuotaExceeded         Code = "QUOTA_EXCEEDED"
+	CodeUploadQuotaExceeded   Code = "UPLOAD_QUOTA_EXCEEDED"
+	CodeCourseStorageExceeded Code = "COURSE_STORAGE_EXCEEDED"
+)
+
+// Server errors
+const (
+	CodeStorageUnavailable     Code = "STORAGE_UNAVAILABLE"
+	CodeIdentityProviderFailed Code = "IDENTITY_PROVIDER_FAILED"
+	CodeUploadFailed           Code = "UPLOAD_FAILED"
+	CodeEmbeddingUnavailable   Code = "EMBEDDING_UNAVAILABLE"
+	CodeSummaryUnavailable     Code = "SUMMARY_UNAVAILABLE"
+	CodeSearchIndexUnavailable Code = "SEARCH_INDEX_UNAVAILABLE"
+	CodeCanvasUnavailable      Code = "CANVAS_UNAVAILABLE"
+	CodeServiceStarting        Code = "SERVICE_STARTING"
+	CodeServerOverloaded       Code = "SERVER_OVERLOADED"
+	CodeFaultInjected          Code = "FAULT_INJECTED"
+	CodeRequestTimeout         Code = "REQUEST_TIMEOUT"
+	CodeContractViolation      Code = "CONTRACT_VIOLATION"
+	CodeInternal               Code = "INTERNAL_ERROR"
+)
+
+// Error is an API error with the HTTP status it is reported with
+type Error struct {
+	Status  int    `json:"-"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+	// Cause is what made a server error happen. It goes to error tracking,
+	// never to the client.
+	Cause error `json:"-"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// BadRequest returns a 400 error with the given code
+func BadRequest(code Code, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+// Validation returns a 400 VALIDATION_FAILED error
+func Validation(message string) *Error {
+	return New(http.StatusBadRequest, CodeValidationFailed, message)
+}
+
+// WithDetails returns a copy of e carrying structured details, such as field-level validation errors
+func (e *Error) WithDetails(details any) *Error {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// Localize returns a copy of e with its message, and those of field-level
+// validation errors in its details, in locale. The code stays as it is.
+func (e *Error) Localize(locale string) *Error {
+	copied := *e
+	copied.Message = i18n.Error(locale, string(e.Code), e.Message)
+	if fieldErrs, ok := e.Details.(validation.Errors); ok {
+		copied.Details = fieldErrs.Localize(locale)
+	}
+	return &copied
+}
+
+// WithCause returns a copy of e recording the error behind it
+func (e *Error) WithCause(cause error) *Error {
+	copied := *e
+	copied.Cause = cause
+	return &copied
+}
+
+// NotFound returns a 404 error with the given code
+func NotFound(code Code, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+// Conflict returns a 409 error with the given code
+func Conflict(code Code, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+// PayloadTooLarge returns a 413 error naming the body size limit
+func PayloadTooLarge(limit int64) *Error {
+	return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, fmt.Sprintf("Request body exceeds the %d byte limit", limit))
+}
+
+// Internal returns a 500 INTERNAL_ERROR error
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
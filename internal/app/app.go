@@ -0,0 +1,548 @@
+// internal/app/app.go
+package app
+
+import (
+	"api-server/internal/canvas"
+	"api-server/internal/capture"
+	"api-server/internal/chaos"
+	"api-server/internal/config"
+	"api-server/internal/database"
+	"api-server/internal/embedding"
+	"api-server/internal/errortracking"
+	"api-server/internal/extract"
+	"api-server/internal/featureflag"
+	"api-server/internal/handler"
+	"api-server/internal/lifecycle"
+	"api-server/internal/llm"
+	"api-server/internal/mailer"
+	"api-server/internal/middleware"
+	"api-server/internal/notify"
+	"api-server/internal/outbox"
+	"api-server/internal/privacy"
+	"api-server/internal/publisher"
+	"api-server/internal/repository"
+	"api-server/internal/resilience"
+	"api-server/internal/searchindex"
+	"api-server/internal/secrets"
+	"api-server/internal/storage"
+	"api-server/internal/thumbnail"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// shutdownTimeout bounds how long Run waits for in-flight requests
+const shutdownTimeout = 10 * time.Second
+
+// errorFlushTimeout bounds how long Close waits to send tracked errors
+const errorFlushTimeout = 2 * time.Second
+
+// Server is the API and everything it depends on. The serve command and the
+// test helpers both build it with New, so they share the same wiring.
+type Server struct {
+	Config *config.Config
+
+	// DB and Secrets are nil in in-memory mode
+	DB        database.Pool
+	Secrets   *secrets.Watcher
+	Repo      repository.Repository
+	Storage   storage.Storage
+	Publisher publisher.Publisher
+	Lifecycle *lifecycle.Manager
+	// Partitions is nil in in-memory mode
+	Partitions *lifecycle.Partitioner
+	Extractor  *extract.Extractor
+	Thumbnails *thumbnail.Generator
+	Outbox     *outbox.Relay
+	Flags      *featureflag.Flags
+	DataJobs   *privacy.Runner
+	Notifier   *notify.Notifier
+	Mailer     *mailer.Mailer
+	Embedder   embedding.Embedder
+	LLM        llm.Summarizer
+	// Canvas is nil unless Canvas sync is enabled
+	Canvas *canvas.Syncer
+	// Chaos is nil unless fault injection is enabled
+	Chaos *chaos.Injector
+	// Capture is nil unless payload capture is enabled
+	Capture *capture.Recorder
+	// SearchIndex and Indexer are nil without a search index
+	SearchIndex *searchindex.Client
+	Indexer     *searchindex.Indexer
+	Backlog     *outbox.BacklogMonitor
+	Registry    *prometheus.Registry
+	Handler     http.Handler
+	// DebugHandler serves the profiling endpoints on DEBUG_ADDR
+	DebugHandler http.Handler
+
+	closers []func()
+}
+
+// New connects to the backends selected by cfg and builds the router.
+// MODE=inmemory swaps Postgres, GCS and Kafka for in-process fakes. Nothing
+// runs in the background until Start or Run.
+func New(ctx context.Context, cfg *config.Config) (*Server, error) {
+	s := &Server{Config: cfg}
+	if err := s.build(ctx); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewTestServer builds a Server on in-memory fakes with OpenAPI validation
+// on, for tests that don't need real backends. Serve its Handler with
+// httptest.
+func NewTestServer() (*Server, error) {
+	cfg := config.NewConfig()
+	cfg.Mode = config.ModeInMemory
+	cfg.OpenAPIValidation = true
+	cfg.DebugAddr = ""
+	cfg.LifecycleEnabled = false
+	return New(context.Background(), cfg)
+}
+
+func (s *Server) build(ctx context.Context) error {
+	cfg := s.Config
+
+	if err := errortracking.Init(cfg); err != nil {
+		return err
+	}
+	s.onClose(func() { errortracking.Flush(errorFlushTimeout) })
+
+	var (
+		baseStore     storage.Storage
+		basePublisher publisher.Publisher
+		queryTracer   *database.QueryTracer
+	)
+	if cfg.InMemory() {
+		log.Println("Running in-memory mode, data is lost on restart")
+		s.Repo = repository.NewMemory()
+		baseStore = storage.NewMemory(cfg.GCSBucketName)
+		basePublisher = publisher.NewMemory()
+	} else {
+		// A database password from a secret is re-fetched, and the pool
+		// rebuilt, as soon as Postgres rejects it
+		s.Secrets = secrets.NewWatcher(cfg)
+
+		// Time every statement and log the slow ones
+		queryTracer = database.NewQueryTracer(cfg.DBSlowQueryThreshold)
+
+		var err error
+		s.DB, err = waitFor(ctx, cfg, "postgres", func(ctx context.Context) (database.Pool, error) {
+			if s.Secrets.Watching("DB_PASSWORD") {
+				return database.NewRotatingPool(ctx, cfg, s.Secrets.Secret("DB_PASSWORD"), queryTracer)
+			}
+			return database.NewTracedConnection(ctx, cfg, queryTracer)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		s.onClose(s.DB.Close)
+		s.Repo = repository.NewPostgres(s.DB)
+		baseStore = storage.NewGCS(cfg)
+
+		basePublisher, err = waitFor(ctx, cfg, "kafka", func(context.Context) (publisher.Publisher, error) {
+			return publisher.New(cfg)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize Kafka producer: %w", err)
+		}
+	}
+
+	// Mirror course and trace writes into the search index, if there is one
+	s.SearchIndex = searchindex.New(cfg)
+	if s.SearchIndex != nil {
+		s.Indexer = searchindex.NewIndexer(s.SearchIndex, cfg)
+		s.Repo = searchindex.NewRepository(s.Repo, s.Indexer)
+	}
+
+	// Create a custom Prometheus registry to avoid conflicts with default registry
+	s.Registry = prometheus.NewRegistry()
+
+	// Storage and Kafka calls are retried with backoff behind circuit breakers
+	retryPolicy := resilience.RetryPolicy{
+		Attempts:  cfg.RetryMaxAttempts,
+		BaseDelay: cfg.RetryBaseDelay,
+		MaxDelay:  cfg.RetryMaxDelay,
+	}
+	storageBreaker := resilience.NewBreaker("gcs", cfg.BreakerFailureThreshold, cfg.BreakerCooldown)
+	publisherBreaker := resilience.NewBreaker("kafka", cfg.BreakerFailureThreshold, cfg.BreakerCooldown)
+
+	// Injected faults go beneath the retries and breakers, which see them as
+	// the backends failing
+	store, pub := baseStore, basePublisher
+	if cfg.ChaosEnabled {
+		log.Println("Warning: fault injection is enabled, see /v1/admin/chaos")
+		s.Chaos = chaos.New()
+		store = storage.NewChaos(baseStore, s.Chaos)
+		pub = publisher.NewChaos(basePublisher, s.Chaos)
+	}
+	if cfg.CaptureEnabled {
+		log.Println("Warning: payload capture is enabled, see /v1/admin/captures")
+		s.Capture = capture.New(cfg.CaptureSamplePercent, cfg.CaptureMaxBodyBytes, cfg.CaptureMaxEntries)
+	}
+
+	publisherMetrics, err := publisher.NewMetrics(s.Registry)
+	if err != nil {
+		log.Printf("Failed to register Kafka producer metrics: %v", err)
+	}
+	s.Publisher = publisher.NewResilient(pub, retryPolicy, publisherBreaker, publisherMetrics)
+	s.onClose(func() { s.Publisher.Close() })
+	storageMetrics, err := storage.NewMetrics(s.Registry)
+	if err != nil {
+		log.Printf("Failed to register storage metrics: %v", err)
+	}
+	poolMetrics, err := storage.NewPoolMetrics(s.Registry)
+	if err != nil {
+		log.Printf("Failed to register storage pool metrics: %v", err)
+	}
+	// Bound how many storage operations run at once, each with its retries
+	s.Storage = storage.NewPool(storage.NewResilient(store, retryPolicy, storageBreaker, storageMetrics),
+		cfg.StorageWorkers, cfg.StorageTaskTimeout, poolMetrics)
+	s.onClose(func() { s.Storage.Close() })
+
+	s.Lifecycle = lifecycle.NewManager(s.Repo, s.Storage, cfg)
+	if s.DB != nil {
+		s.Partitions = lifecycle.NewPartitioner(s.Repo, cfg)
+	}
+	s.Extractor = extract.NewExtractor(s.Repo, s.Storage, cfg)
+	s.Thumbnails = thumbnail.NewGenerator(s.Repo, s.Storage, cfg)
+	s.Outbox = outbox.NewRelay(s.Repo, s.Publisher, cfg)
+	s.Flags = featureflag.New(s.Repo, cfg)
+	s.DataJobs = privacy.NewRunner(s.Repo, s.Storage, s.Outbox, cfg)
+	s.Notifier = notify.New(cfg)
+	s.Mailer = mailer.New(cfg, s.Repo)
+	s.Embedder = embedding.New(cfg)
+	s.LLM = llm.New(cfg)
+	s.Backlog = outbox.NewBacklogMonitor(s.Repo, s.Notifier, cfg)
+	if cfg.CanvasEnabled {
+		s.Canvas = canvas.NewSyncer(s.Repo, cfg)
+	}
+
+	if err := s.Registry.Register(collectors.NewGoCollector()); err != nil {
+		log.Printf("Failed to register Go collector: %v", err)
+	}
+	if err := s.Registry.Register(collectors.NewBuildInfoCollector()); err != nil {
+		log.Printf("Failed to register BuildInfo collector: %v", err)
+	}
+	// Export connection pool statistics (acquired, idle, acquire waits) for pool tuning
+	if s.DB != nil {
+		if err := s.Registry.Register(database.NewPoolStatsCollector(s.DB, cfg.DBName)); err != nil {
+			log.Printf("Failed to register pool stats collector: %v", err)
+		}
+		if err := s.Registry.Register(queryTracer); err != nil {
+			log.Printf("Failed to register query metrics: %v", err)
+		}
+	}
+	if err := resilience.RegisterBreakerMetrics(s.Registry, storageBreaker, publisherBreaker); err != nil {
+		log.Printf("Failed to register circuit breaker metrics: %v", err)
+	}
+	if err := s.Registry.Register(outbox.NewBacklogCollector(s.Repo)); err != nil {
+		log.Printf("Failed to register outbox backlog collector: %v", err)
+	}
+	if _, ok := basePublisher.(*publisher.Kafka); ok {
+		if err := s.Registry.Register(publisher.NewUpCollector(s.Publisher)); err != nil {
+			log.Printf("Failed to register Kafka up collector: %v", err)
+		}
+	}
+
+	// Register every API route along with /metrics
+	s.Handler, err = handler.NewRouter(cfg, handler.Services{
+		Repo:        s.Repo,
+		Storage:     s.Storage,
+		Publisher:   s.Publisher,
+		Lifecycle:   s.Lifecycle,
+		Outbox:      s.Outbox,
+		Flags:       s.Flags,
+		DataJobs:    s.DataJobs,
+		Notifier:    s.Notifier,
+		Mailer:      s.Mailer,
+		Embedder:    s.Embedder,
+		Summarizer:  s.LLM,
+		SearchIndex: s.SearchIndex,
+		Canvas:      s.Canvas,
+		Chaos:       s.Chaos,
+		Capture:     s.Capture,
+	}, s.Registry)
+	if err != nil {
+		return err
+	}
+	// The profiling endpoints authenticate callers the way the API does
+	s.DebugHandler, err = handler.NewDebugRouter(cfg, s.Repo)
+	return err
+}
+
+// onClose registers fn to run when the server is closed, in reverse order
+func (s *Server) onClose(fn func()) {
+	s.closers = append(s.closers, fn)
+}
+
+// Start runs the background work until ctx is cancelled: secret refresh,
+// the outbox relay and its backlog alerts, data jobs, Canvas sync, feature
+// flag sync, trace partitioning, storage lifecycle, PDF text extraction and
+// GCS warm-up
+func (s *Server) Start(ctx context.Context) {
+	if s.Secrets != nil {
+		go s.Secrets.Run(ctx)
+	}
+
+	// The GCS client is created lazily; warm it up without blocking startup
+	go func() {
+		if err := s.Storage.Connect(ctx); err != nil {
+			log.Printf("GCS client not ready, trace uploads degraded: %v", err)
+		}
+	}()
+
+	// Create trace partitions for the coming semesters
+	if s.Partitions != nil {
+		go s.Partitions.Run(ctx)
+	}
+
+	// Archive traces from past semesters to coldline in the background
+	if s.Config.LifecycleEnabled {
+		go s.Lifecycle.Run(ctx)
+	}
+
+	// Extract the text of uploaded PDFs for keyword search
+	if s.Config.ExtractEnabled {
+		go s.Extractor.Run(ctx)
+	}
+
+	// Render a preview of each uploaded PDF's first page
+	if s.Config.ThumbnailEnabled {
+		go s.Thumbnails.Run(ctx)
+	}
+
+	// Apply search index writes queued by course and trace changes
+	if s.Indexer != nil {
+		go s.Indexer.Run(ctx)
+	}
+
+	// Publish outbox events written alongside trace records
+	go s.Outbox.Run(ctx)
+
+	// Alert when the outbox backs up or gives up on events
+	go s.Backlog.Run(ctx)
+
+	// Run personal data exports and erasures requested through the API
+	go s.DataJobs.Run(ctx)
+
+	// Sync connected tenants with Canvas on schedule and on request
+	if s.Canvas != nil {
+		go s.Canvas.Run(ctx)
+	}
+
+	// Load feature flag overrides now and keep them in sync with other replicas
+	if err := s.Flags.Refresh(ctx); err != nil {
+		log.Printf("Failed to load feature flag overrides, using config: %v", err)
+	}
+	go s.Flags.Run(ctx)
+}
+
+// Run starts the background work and serves the API on its listeners, plus
+// the debug and metrics listeners when configured, until ctx is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.Start(ctx)
+	s.startDebug()
+	s.startMetrics()
+	return listen(ctx, s.Config, s.Handler)
+}
+
+// RunDegraded opens the API's listeners straight away, answering /livez while
+// New waits on Postgres and Kafka, and serves the API once they are up. It
+// returns New's error if they don't come up within the startup retries.
+func RunDegraded(ctx context.Context, cfg *config.Config) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	gate := &startupGate{}
+	gate.set(handler.NewStartingRouter())
+	listened := make(chan error, 1)
+	go func() {
+		listened <- listen(ctx, cfg, gate)
+		// Stop waiting on dependencies if the listener failed
+		cancel()
+	}()
+
+	s, err := New(ctx, cfg)
+	if err != nil {
+		cancel()
+		if listenErr := <-listened; listenErr != nil {
+			return listenErr
+		}
+		return err
+	}
+	defer s.Close()
+
+	s.Start(ctx)
+	s.startDebug()
+	s.startMetrics()
+	gate.set(s.Handler)
+	log.Printf("Dependencies ready, serving the API")
+	return <-listened
+}
+
+// startDebug serves the profiling endpoints on their own port, so they are
+// never public
+func (s *Server) startDebug() {
+	if s.Config.DebugAddr == "" {
+		return
+	}
+	go func() {
+		log.Printf("Debug server starting on %s", s.Config.DebugAddr)
+		if err := http.ListenAndServe(s.Config.DebugAddr, s.DebugHandler); err != nil {
+			log.Printf("Debug server failed: %v", err)
+		}
+	}()
+}
+
+// startMetrics serves /metrics on METRICS_ADDR, when set, instead of on the
+// API's listeners, so it can be kept off the public network
+func (s *Server) startMetrics() {
+	if s.Config.MetricsAddr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler.NewMetricsHandler(s.Config, s.Registry))
+	go func() {
+		log.Printf("Metrics server starting on %s", s.Config.MetricsAddr)
+		if err := http.ListenAndServe(s.Config.MetricsAddr, mux); err != nil {
+			log.Printf("Metrics server failed: %v", err)
+		}
+	}()
+}
+
+// listen serves h on every API listener until ctx is cancelled, then shuts
+// them down gracefully. If one fails the others are shut down too, and its
+// error is returned.
+func listen(ctx context.Context, cfg *config.Config, h http.Handler) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	listeners := cfg.APIListeners()
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() {
+			err := serve(ctx, cfg, l, h)
+			if err != nil {
+				cancel()
+			}
+			errs <- err
+		}()
+	}
+
+	var first error
+	for range listeners {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// serve serves h on l until ctx is cancelled, then shuts down gracefully
+func serve(ctx context.Context, cfg *config.Config, l config.Listener, h http.Handler) error {
+	srv, err := newHTTPServer(cfg, l, h)
+	if err != nil {
+		return fmt.Errorf("server failed to start on %s: %w", l, err)
+	}
+	ln, err := openListener(l)
+	if err != nil {
+		return fmt.Errorf("server failed to start on %s: %w", l, err)
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server shutdown failed on %s: %v", l, err)
+		}
+	}()
+
+	switch {
+	case l.TLSCertFile != "":
+		log.Printf("Server starting on %s with TLS and HTTP/2", l)
+		err = srv.ServeTLS(ln, l.TLSCertFile, l.TLSKeyFile)
+	case l.H2C:
+		log.Printf("Server starting on %s with cleartext HTTP/2", l)
+		err = srv.Serve(ln)
+	default:
+		log.Printf("Server starting on %s", l)
+		err = srv.Serve(ln)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed on %s: %w", l, err)
+	}
+	return nil
+}
+
+// openListener listens on l. A socket file left behind by a previous run
+// that didn't shut down cleanly is removed first, since it would otherwise
+// make the address look taken.
+func openListener(l config.Listener) (net.Listener, error) {
+	if l.Network != "unix" {
+		return net.Listen(l.Network, l.Address)
+	}
+	if info, err := os.Lstat(l.Address); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if err := os.Remove(l.Address); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", l.Address)
+	if err != nil {
+		return nil, err
+	}
+	if l.SocketMode != 0 {
+		if err := os.Chmod(l.Address, l.SocketMode); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
+
+// newHTTPServer builds the server for one listener. HTTP/2 is offered over
+// TLS through ALPN and, with h2c, without TLS to clients that ask for it;
+// HTTP/1.1 clients are served as before. Requests through a Unix socket are
+// marked as coming from a local peer, for middleware.RealIP.
+func newHTTPServer(cfg *config.Config, l config.Listener, h http.Handler) (*http.Server, error) {
+	h2 := &http2.Server{
+		MaxConcurrentStreams: uint32(cfg.HTTP2MaxConcurrentStreams),
+		IdleTimeout:          cfg.HTTPIdleTimeout,
+	}
+	if l.H2C {
+		h = h2c.NewHandler(h, h2)
+	}
+	srv := &http.Server{Handler: h, IdleTimeout: cfg.HTTPIdleTimeout}
+	if l.Network == "unix" {
+		srv.ConnContext = func(ctx context.Context, _ net.Conn) context.Context {
+			return middleware.WithLocalPeer(ctx)
+		}
+	}
+	if err := http2.ConfigureServer(srv, h2); err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
+
+// Close releases the database pool, storage client and publisher
+func (s *Server) Close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+	s.closers = nil
+}
@@ -0,0 +1,55 @@
+// internal/app/startup.go
+package app
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/resilience"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// waitFor calls connect until it succeeds, retrying with backoff as
+// STARTUP_RETRY_* allow, so the server outlasts a dependency that starts
+// after it. Each failed attempt is logged with the dependency's name.
+func waitFor[T any](ctx context.Context, cfg *config.Config, dependency string, connect func(ctx context.Context) (T, error)) (T, error) {
+	policy := resilience.RetryPolicy{
+		Attempts:  cfg.StartupRetryAttempts,
+		BaseDelay: cfg.StartupRetryBaseDelay,
+		MaxDelay:  cfg.StartupRetryMaxDelay,
+	}
+	start := time.Now()
+	attempts := 0
+	var result T
+	err := resilience.Retry(ctx, policy, func(ctx context.Context) error {
+		attempts++
+		var err error
+		if result, err = connect(ctx); err != nil {
+			slog.Warn("Dependency not ready", "dependency", dependency, "attempt", attempts, "max_attempts", policy.Attempts, "error", err)
+		}
+		return err
+	})
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("%s not ready after %d attempts in %s: %w", dependency, attempts, time.Since(start).Round(time.Millisecond), err)
+	}
+	slog.Info("Dependency ready", "dependency", dependency, "attempts", attempts, "elapsed", time.Since(start).Round(time.Millisecond))
+	return result, nil
+}
+
+// startupGate hands requests to the starting router until the API is
+// built, then to the API
+type startupGate struct {
+	handler atomic.Pointer[http.Handler]
+}
+
+func (g *startupGate) set(h http.Handler) {
+	g.handler.Store(&h)
+}
+
+func (g *startupGate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*g.handler.Load()).ServeHTTP(w, r)
+}
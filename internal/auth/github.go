@@ -0,0 +1,131 @@
+// internal/auth/github.go
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const githubAPI = "https://api.github.com"
+
+// githubProvider verifies GitHub OAuth access tokens. GitHub doesn't issue
+// ID tokens, so the token is checked against the OAuth app through the
+// token check API, which proves it was issued to this app, and the profile
+// is then read with the token itself.
+type githubProvider struct {
+	clientID     string
+	clientSecret string
+	client       *http.Client
+}
+
+func newGitHubProvider(clientID, clientSecret string, client *http.Client) *githubProvider {
+	return &githubProvider{clientID: clientID, clientSecret: clientSecret, client: client}
+}
+
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+type githubOrg struct {
+	Login string `json:"login"`
+}
+
+func (p *githubProvider) Verify(ctx context.Context, token string) (*Identity, error) {
+	// POST /applications/{client_id}/token answers 404 for tokens the app didn't issue
+	var check struct {
+		User githubUser `json:"user"`
+	}
+	body, err := json.Marshal(map[string]string{"access_token": token})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, githubAPI+"/applications/"+url.PathEscape(p.clientID)+"/token", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(p.clientID, p.clientSecret)
+	if err := p.do(req, &check); err != nil {
+		return nil, err
+	}
+
+	var emails []githubEmail
+	if err := p.get(ctx, token, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+	var orgs []githubOrg
+	if err := p.get(ctx, token, "/user/orgs", &orgs); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{Claims: map[string][]string{"login": {check.User.Login}}}
+	identity.Provider = ProviderGitHub
+	identity.Subject = strconv.FormatInt(check.User.ID, 10)
+	identity.Username = check.User.Login
+	identity.FirstName, identity.LastName, _ = strings.Cut(check.User.Name, " ")
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+			if _, domain, ok := strings.Cut(e.Email, "@"); ok {
+				identity.Claims["email_domain"] = []string{strings.ToLower(domain)}
+			}
+		}
+	}
+	for _, org := range orgs {
+		identity.Claims["org"] = append(identity.Claims["org"], org.Login)
+	}
+	return identity, nil
+}
+
+// get calls a GitHub API path as the token's user
+func (p *githubProvider) get(ctx context.Context, token, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, githubAPI+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return p.do(req, v)
+}
+
+// do sends req and decodes the response into v. 401, 403 and 404 mean the
+// token is not good for this app.
+func (p *githubProvider) do(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if req.Body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GitHub request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound:
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("%w: GitHub answered %s", ErrInvalidCredential, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("GitHub %s %s answered %s", req.Method, req.URL.Path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode GitHub response: %w", err)
+	}
+	return nil
+}
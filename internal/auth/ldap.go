@@ -0,0 +1,250 @@
+// internal/auth/ldap.go
+package auth
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ProviderLDAP names directory accounts in user_identities
+const ProviderLDAP = "ldap"
+
+// LDAP verifies passwords by binding to the directory as the user. The
+// user's entry is looked up with the service account (LDAP_BIND_DN, or an
+// anonymous bind without one) on pooled connections; usernames the
+// directory doesn't know are passed on to fallback, so local accounts such
+// as the bootstrap admin keep working.
+type LDAP struct {
+	repo     repository.Repository
+	fallback PasswordAuthenticator
+	roles    *RoleMapper
+
+	url          string
+	startTLS     bool
+	tlsConfig    *tls.Config
+	bindDN       string
+	bindPassword string
+	baseDN       string
+	userFilter   string
+	idAttribute  string
+	groupAttr    string
+	timeout      time.Duration
+
+	// pool holds idle connections bound as the service account
+	pool chan *ldap.Conn
+}
+
+// NewLDAP builds the LDAP backend; connections are opened on demand
+func NewLDAP(cfg *config.Config, repo repository.Repository, fallback PasswordAuthenticator) (*LDAP, error) {
+	u, err := url.Parse(cfg.LDAPURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP_URL: %w", err)
+	}
+	tlsConfig := &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	if cfg.LDAPCAFile != "" {
+		pem, err := os.ReadFile(cfg.LDAPCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LDAP_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("LDAP_CA_FILE %s holds no PEM certificates", cfg.LDAPCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &LDAP{
+		repo:         repo,
+		fallback:     fallback,
+		roles:        NewRoleMapper(cfg.LDAPDefaultRole, cfg.LDAPRoleMappings),
+		url:          cfg.LDAPURL,
+		startTLS:     cfg.LDAPStartTLS,
+		tlsConfig:    tlsConfig,
+		bindDN:       cfg.LDAPBindDN,
+		bindPassword: cfg.LDAPBindPassword,
+		baseDN:       cfg.LDAPBaseDN,
+		userFilter:   cfg.LDAPUserFilter,
+		idAttribute:  cfg.LDAPIDAttribute,
+		groupAttr:    cfg.LDAPGroupAttribute,
+		timeout:      cfg.LDAPTimeout,
+		pool:         make(chan *ldap.Conn, cfg.LDAPPoolSize),
+	}, nil
+}
+
+func (l *LDAP) Authenticate(ctx context.Context, username, password string) (*model.User, error) {
+	// An empty password makes a bind unauthenticated, which succeeds
+	if username == "" || password == "" {
+		return nil, model.ErrInvalidCredentials
+	}
+
+	conn, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := l.findUser(conn, username)
+	if err != nil {
+		l.put(conn, err)
+		return nil, err
+	}
+	if entry == nil {
+		l.put(conn, nil)
+		return l.fallback.Authenticate(ctx, username, password)
+	}
+
+	// Binding as the user checks the password; the connection is bound
+	// back to the service account before it returns to the pool
+	err = conn.Bind(entry.DN, password)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		l.put(conn, l.rebind(conn))
+		return nil, model.ErrInvalidCredentials
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %v", ErrDirectoryUnavailable, err)
+	}
+	l.put(conn, l.rebind(conn))
+
+	identity := l.identity(entry, username)
+	if identity.Email == "" {
+		return nil, fmt.Errorf("directory entry %s has no mail attribute", entry.DN)
+	}
+	user, _, err := l.repo.LoginExternal(ctx, identity.ExternalIdentity, l.roles.Role(identity))
+	return user, err
+}
+
+// findUser returns the entry LDAP_USER_FILTER finds for username, or nil
+// when there is none. More than one match is refused rather than guessed at.
+func (l *LDAP) findUser(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	attributes := []string{"mail", "givenName", "sn", l.groupAttr}
+	if l.idAttribute != "" {
+		attributes = append(attributes, l.idAttribute)
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		l.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(l.timeout.Seconds()), false,
+		fmt.Sprintf(l.userFilter, ldap.EscapeFilter(username)), attributes, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDirectoryUnavailable, err)
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, model.ErrInvalidCredentials
+	}
+}
+
+// identity reads the account from the entry. Its group claims are the CNs
+// of the groups it is a member of, and its email is taken as verified,
+// since the directory is the institution's own.
+func (l *LDAP) identity(entry *ldap.Entry, username string) *Identity {
+	identity := &Identity{Claims: map[string][]string{}}
+	for _, group := range entry.GetAttributeValues(l.groupAttr) {
+		identity.Claims["group"] = append(identity.Claims["group"], groupName(group))
+	}
+
+	// Binary IDs such as Active Directory's objectGUID are hex-encoded;
+	// without the attribute the DN is the subject
+	subject := entry.DN
+	if raw := entry.GetRawAttributeValue(l.idAttribute); len(raw) > 0 {
+		subject = string(raw)
+		if strings.EqualFold(l.idAttribute, "objectGUID") {
+			subject = hex.EncodeToString(raw)
+		}
+	}
+
+	identity.Provider = ProviderLDAP
+	identity.Subject = subject
+	identity.Email = entry.GetAttributeValue("mail")
+	identity.EmailVerified = identity.Email != ""
+	identity.FirstName = entry.GetAttributeValue("givenName")
+	identity.LastName = entry.GetAttributeValue("sn")
+	identity.Username = username
+	if _, domain, ok := strings.Cut(identity.Email, "@"); ok {
+		identity.Claims["email_domain"] = []string{strings.ToLower(domain)}
+	}
+	return identity
+}
+
+// groupName is the CN of a group DN, or the DN itself if it has none
+func groupName(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return dn
+	}
+	for _, attr := range parsed.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, "cn") {
+			return attr.Value
+		}
+	}
+	return dn
+}
+
+// get takes an idle connection from the pool or opens a new one
+func (l *LDAP) get() (*ldap.Conn, error) {
+	select {
+	case conn := <-l.pool:
+		if !conn.IsClosing() {
+			return conn, nil
+		}
+	default:
+	}
+
+	conn, err := ldap.DialURL(l.url,
+		ldap.DialWithDialer(&net.Dialer{Timeout: l.timeout}),
+		ldap.DialWithTLSConfig(l.tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDirectoryUnavailable, err)
+	}
+	conn.SetTimeout(l.timeout)
+	if l.startTLS {
+		if err := conn.StartTLS(l.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: StartTLS failed: %v", ErrDirectoryUnavailable, err)
+		}
+	}
+	if err := l.rebind(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%w: service account bind failed: %v", ErrDirectoryUnavailable, err)
+	}
+	return conn, nil
+}
+
+// rebind binds conn as the service account, or anonymously without one
+func (l *LDAP) rebind(conn *ldap.Conn) error {
+	if l.bindDN == "" {
+		return conn.UnauthenticatedBind("")
+	}
+	return conn.Bind(l.bindDN, l.bindPassword)
+}
+
+// put returns conn to the pool, closing it instead if it failed or the
+// pool is full
+func (l *LDAP) put(conn *ldap.Conn, err error) {
+	if err != nil && !errors.Is(err, model.ErrInvalidCredentials) {
+		conn.Close()
+		return
+	}
+	select {
+	case l.pool <- conn:
+	default:
+		conn.Close()
+	}
+}
@@ -0,0 +1,105 @@
+// internal/auth/oidc.go
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// oidcProvider verifies ID tokens from an OpenID Connect issuer. The
+// issuer's discovery document is fetched on first use rather than at
+// startup, so an unreachable provider doesn't keep the API from starting.
+type oidcProvider struct {
+	name         string
+	issuer       string
+	clientID     string
+	hostedDomain string
+	client       *http.Client
+
+	mu       sync.Mutex
+	verifier *oidc.IDTokenVerifier
+}
+
+func newOIDCProvider(name, issuer, clientID, hostedDomain string, client *http.Client) *oidcProvider {
+	return &oidcProvider{name: name, issuer: issuer, clientID: clientID, hostedDomain: hostedDomain, client: client}
+}
+
+func (p *oidcProvider) Verify(ctx context.Context, token string) (*Identity, error) {
+	verifier, err := p.getVerifier()
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := verifier.Verify(oidc.ClientContext(ctx, p.client), token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	identity := &Identity{Claims: flattenClaims(claims)}
+	identity.Provider = p.name
+	identity.Subject = idToken.Subject
+	identity.Email = stringClaim(claims, "email")
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.FirstName = stringClaim(claims, "given_name")
+	identity.LastName = stringClaim(claims, "family_name")
+	identity.Username = stringClaim(claims, "preferred_username")
+	if _, domain, ok := strings.Cut(identity.Email, "@"); ok {
+		identity.Claims["email_domain"] = []string{strings.ToLower(domain)}
+	}
+
+	// Google's hd claim names the Workspace domain the account belongs to
+	if p.hostedDomain != "" && !strings.EqualFold(stringClaim(claims, "hd"), p.hostedDomain) {
+		return nil, fmt.Errorf("%w: account is not in the %s domain", ErrInvalidCredential, p.hostedDomain)
+	}
+	return identity, nil
+}
+
+// getVerifier runs discovery on first use and keeps the verifier once it succeeds
+func (p *oidcProvider) getVerifier() (*oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.verifier != nil {
+		return p.verifier, nil
+	}
+	// The discovered key set outlives this request, so it gets a context of its own
+	provider, err := oidc.NewProvider(oidc.ClientContext(context.Background(), p.client), p.issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC issuer %s: %w", p.issuer, err)
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.clientID})
+	return p.verifier, nil
+}
+
+func stringClaim(claims map[string]any, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// flattenClaims keeps the string, boolean and string-list claims, each as a
+// list of strings
+func flattenClaims(claims map[string]any) map[string][]string {
+	flat := make(map[string][]string, len(claims))
+	for name, value := range claims {
+		switch v := value.(type) {
+		case string:
+			flat[name] = []string{v}
+		case bool:
+			flat[name] = []string{fmt.Sprint(v)}
+		case []any:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					flat[name] = append(flat[name], s)
+				}
+			}
+		}
+	}
+	return flat
+}
@@ -0,0 +1,40 @@
+// internal/auth/password.go
+package auth
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"context"
+	"errors"
+)
+
+// ErrDirectoryUnavailable is returned when a password can't be checked
+// because the directory behind it is unreachable
+var ErrDirectoryUnavailable = errors.New("directory unavailable")
+
+// PasswordAuthenticator verifies Basic Auth credentials, returning
+// model.ErrInvalidCredentials for a wrong username or password
+type PasswordAuthenticator interface {
+	Authenticate(ctx context.Context, username, password string) (*model.User, error)
+}
+
+// NewPasswordAuthenticator returns the backend AUTH_BACKEND selects
+func NewPasswordAuthenticator(cfg *config.Config, repo repository.Repository) (PasswordAuthenticator, error) {
+	local := &Local{repo: repo}
+	switch cfg.AuthBackend {
+	case "ldap":
+		return NewLDAP(cfg, repo, local)
+	default:
+		return local, nil
+	}
+}
+
+// Local checks the password against the hash stored with the user
+type Local struct {
+	repo repository.Repository
+}
+
+func (l *Local) Authenticate(ctx context.Context, username, password string) (*model.User, error) {
+	return l.repo.AuthenticateUser(ctx, username, password)
+}
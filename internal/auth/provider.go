@@ -0,0 +1,54 @@
+// internal/auth/provider.go
+package auth
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/model"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Provider names as accepted by POST /auth/oidc
+const (
+	ProviderGoogle = "google"
+	ProviderGitHub = "github"
+	ProviderSSO    = "sso"
+)
+
+// providerTimeout bounds every call to an identity provider
+const providerTimeout = 10 * time.Second
+
+// ErrInvalidCredential is returned for a provider token that does not verify
+var ErrInvalidCredential = errors.New("invalid identity provider credential")
+
+// Identity is a verified provider account along with the claims role
+// mappings match against, e.g. "groups" or "org", each with all its values
+type Identity struct {
+	model.ExternalIdentity
+	Claims map[string][]string
+}
+
+// Provider verifies a token the client obtained from an identity provider.
+// Errors other than ErrInvalidCredential mean the provider could not be
+// reached or answered unexpectedly.
+type Provider interface {
+	Verify(ctx context.Context, token string) (*Identity, error)
+}
+
+// NewProviders returns the providers enabled in cfg, keyed by name
+func NewProviders(cfg *config.Config) map[string]Provider {
+	client := &http.Client{Timeout: providerTimeout}
+	providers := map[string]Provider{}
+	if cfg.OIDCGoogleClientID != "" {
+		providers[ProviderGoogle] = newOIDCProvider(ProviderGoogle, "https://accounts.google.com", cfg.OIDCGoogleClientID, cfg.OIDCGoogleHostedDomain, client)
+	}
+	if cfg.OIDCGitHubClientID != "" {
+		providers[ProviderGitHub] = newGitHubProvider(cfg.OIDCGitHubClientID, cfg.OIDCGitHubClientSecret, client)
+	}
+	if cfg.OIDCIssuer != "" {
+		providers[ProviderSSO] = newOIDCProvider(ProviderSSO, cfg.OIDCIssuer, cfg.OIDCClientID, "", client)
+	}
+	return providers
+}
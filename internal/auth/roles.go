@@ -0,0 +1,38 @@
+// internal/auth/roles.go
+package auth
+
+import (
+	"strings"
+)
+
+// rolePrivilege orders the roles so the most privileged mapping wins
+var rolePrivilege = map[string]int{"student": 0, "instructor": 1, "admin": 2}
+
+// RoleMapper picks the role a provisioned user is given from the identity's
+// claims
+type RoleMapper struct {
+	defaultRole string
+	// mappings are keyed "claim:value", as in OIDC_ROLE_MAPPINGS, split at
+	// the last colon since SAML attribute names are often URNs
+	mappings map[string]string
+}
+
+func NewRoleMapper(defaultRole string, mappings map[string]string) *RoleMapper {
+	return &RoleMapper{defaultRole: defaultRole, mappings: mappings}
+}
+
+// Role returns the most privileged role any of the identity's claim values
+// maps to, or the default role when none does. Values compare case-insensitively.
+func (m *RoleMapper) Role(identity *Identity) string {
+	role := m.defaultRole
+	for key, mapped := range m.mappings {
+		i := strings.LastIndex(key, ":")
+		claim, value := key[:i], key[i+1:]
+		for _, v := range identity.Claims[claim] {
+			if strings.EqualFold(v, value) && rolePrivilege[mapped] > rolePrivilege[role] {
+				role = mapped
+			}
+		}
+	}
+	return role
+}
@@ -0,0 +1,343 @@
+// internal/auth/saml.go
+package auth
+
+import (
+	"api-server/internal/config"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ProviderSAML names SAML accounts in user_identities
+const ProviderSAML = "saml"
+
+// Paths of the SAML endpoints, which the IdP is configured with. They are
+// only served under /v2.
+const (
+	SAMLMetadataPath = "/v2/auth/saml/metadata"
+	SAMLLoginPath    = "/v2/auth/saml/login"
+	SAMLACSPath      = "/v2/auth/saml/acs"
+)
+
+// samlStateCookie carries the pending login from SAMLLoginPath to the ACS
+const samlStateCookie = "saml_state"
+
+// samlStateTTL is how long the user has to log in at the IdP
+const samlStateTTL = 10 * time.Minute
+
+// ErrRedirectNotAllowed is returned for a post-login redirect that is not
+// under one of SAML_REDIRECT_URLS
+var ErrRedirectNotAllowed = errors.New("redirect URL not allowed")
+
+// Attribute names, with their OID and WS-Federation claim forms, that fill
+// in the identity's profile
+var (
+	samlEmailAttributes     = []string{"mail", "email", "urn:oid:0.9.2342.19200300.100.1.3", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"}
+	samlFirstNameAttributes = []string{"givenName", "urn:oid:2.5.4.42", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname"}
+	samlLastNameAttributes  = []string{"sn", "surname", "urn:oid:2.5.4.4", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname"}
+	samlUsernameAttributes  = []string{"uid", "urn:oid:0.9.2342.19200300.100.1.1"}
+)
+
+// SAML is a SAML 2.0 service provider for SP-initiated login. The IdP's
+// metadata is loaded on first use rather than at startup, so an unreachable
+// IdP doesn't keep the API from starting.
+type SAML struct {
+	base         saml.ServiceProvider
+	metadata     string
+	client       *http.Client
+	tokens       *Tokens
+	redirects    []string
+	secureCookie bool
+
+	mu sync.Mutex
+	sp *saml.ServiceProvider
+}
+
+// samlState is the pending login, signed into the state cookie
+type samlState struct {
+	RequestID  string `json:"rid"`
+	RelayState string `json:"rs"`
+	TenantID   string `json:"tid"`
+	Redirect   string `json:"ret,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// NewSAML returns nil when SAML_IDP_METADATA is unset
+func NewSAML(cfg *config.Config, tokens *Tokens) (*SAML, error) {
+	if !cfg.SAMLEnabled() {
+		return nil, nil
+	}
+	keyPair, err := tls.LoadX509KeyPair(cfg.SAMLCertFile, cfg.SAMLKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SAML certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SAML certificate: %w", err)
+	}
+	key, ok := keyPair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("SAML key must be an RSA key")
+	}
+	root, err := url.Parse(strings.TrimSuffix(cfg.SAMLRootURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML_ROOT_URL: %w", err)
+	}
+
+	return &SAML{
+		base: saml.ServiceProvider{
+			EntityID:          cfg.SAMLEntityID,
+			Key:               key,
+			Certificate:       cert,
+			MetadataURL:       *root.JoinPath(SAMLMetadataPath),
+			AcsURL:            *root.JoinPath(SAMLACSPath),
+			AuthnNameIDFormat: saml.PersistentNameIDFormat,
+		},
+		metadata:     cfg.SAMLIdPMetadata,
+		client:       &http.Client{Timeout: providerTimeout},
+		tokens:       tokens,
+		redirects:    cfg.SAMLRedirectURLs,
+		secureCookie: root.Scheme == "https",
+	}, nil
+}
+
+// Metadata is the SP metadata document the IdP is configured with
+func (s *SAML) Metadata() ([]byte, error) {
+	sp := s.base
+	return xml.MarshalIndent(sp.Metadata(), "", "  ")
+}
+
+// Login starts a login for the tenant: it returns the IdP URL to send the
+// browser to and the cookie that ties the IdP's answer to this request.
+// redirect, if set, is where the ACS sends the browser afterwards.
+func (s *SAML) Login(tenantID uuid.UUID, redirect string) (string, *http.Cookie, error) {
+	if redirect != "" && !s.redirectAllowed(redirect) {
+		return "", nil, ErrRedirectNotAllowed
+	}
+	sp, err := s.serviceProvider()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The relay state is echoed back by the IdP and must match the cookie,
+	// so an assertion can't be replayed into another browser's login
+	relay := make([]byte, 16)
+	if _, err := rand.Read(relay); err != nil {
+		return "", nil, err
+	}
+	location := sp.GetSSOBindingLocation(saml.HTTPRedirectBinding)
+	if location == "" {
+		return "", nil, errors.New("SAML IdP metadata has no HTTP-Redirect SSO endpoint")
+	}
+	req, err := sp.MakeAuthenticationRequest(location, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to build SAML request: %w", err)
+	}
+	loginURL, err := req.Redirect(hex.EncodeToString(relay), sp)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to build SAML request: %w", err)
+	}
+
+	now := time.Now()
+	state, err := jwt.NewWithClaims(jwt.SigningMethodHS256, samlState{
+		RequestID:  req.ID,
+		RelayState: hex.EncodeToString(relay),
+		TenantID:   tenantID.String(),
+		Redirect:   redirect,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(samlStateTTL)),
+		},
+	}).SignedString(s.tokens.secret)
+	if err != nil {
+		return "", nil, err
+	}
+	return loginURL.String(), s.stateCookie(state, int(samlStateTTL.Seconds())), nil
+}
+
+// ClearCookie expires the state cookie once the login is done
+func (s *SAML) ClearCookie() *http.Cookie {
+	return s.stateCookie("", -1)
+}
+
+// The IdP posts to the ACS from its own site, so the cookie has to be
+// SameSite=None, which browsers only accept on secure cookies
+func (s *SAML) stateCookie(value string, maxAge int) *http.Cookie {
+	sameSite := http.SameSiteLaxMode
+	if s.secureCookie {
+		sameSite = http.SameSiteNoneMode
+	}
+	return &http.Cookie{
+		Name:     samlStateCookie,
+		Value:    value,
+		Path:     SAMLACSPath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   s.secureCookie,
+		SameSite: sameSite,
+	}
+}
+
+// Verify checks the assertion the IdP posted to the ACS against the login
+// in the request's state cookie, returning the identity and the redirect
+// the login asked for
+func (s *SAML) Verify(r *http.Request, tenantID uuid.UUID) (*Identity, string, error) {
+	cookie, err := r.Cookie(samlStateCookie)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: no login in progress", ErrInvalidCredential)
+	}
+	var state samlState
+	_, err = jwt.ParseWithClaims(cookie.Value, &state, func(*jwt.Token) (any, error) {
+		return s.tokens.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: invalid login state: %v", ErrInvalidCredential, err)
+	}
+	if state.TenantID != tenantID.String() {
+		return nil, "", fmt.Errorf("%w: login started in another tenant", ErrInvalidCredential)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrInvalidCredential, err)
+	}
+	if r.PostForm.Get("RelayState") != state.RelayState {
+		return nil, "", fmt.Errorf("%w: relay state does not match", ErrInvalidCredential)
+	}
+	sp, err := s.serviceProvider()
+	if err != nil {
+		return nil, "", err
+	}
+	assertion, err := sp.ParseResponse(r, []string{state.RequestID})
+	if err != nil {
+		// The reason is kept out of Error() so it doesn't reach clients
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, "", fmt.Errorf("%w: assertion has no NameID", ErrInvalidCredential)
+	}
+	return samlIdentity(assertion), state.Redirect, nil
+}
+
+// samlIdentity reads the account from the assertion. Every attribute is a
+// claim under its name and, if it has one, its friendly name. The email is
+// taken as verified, since the IdP is the institution's own directory.
+func samlIdentity(assertion *saml.Assertion) *Identity {
+	identity := &Identity{Claims: map[string][]string{}}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			for _, v := range attr.Values {
+				identity.Claims[attr.Name] = append(identity.Claims[attr.Name], v.Value)
+				if attr.FriendlyName != "" && attr.FriendlyName != attr.Name {
+					identity.Claims[attr.FriendlyName] = append(identity.Claims[attr.FriendlyName], v.Value)
+				}
+			}
+		}
+	}
+
+	nameID := assertion.Subject.NameID
+	identity.Provider = ProviderSAML
+	identity.Subject = nameID.Value
+	identity.Email = firstClaim(identity.Claims, samlEmailAttributes)
+	if identity.Email == "" && nameID.Format == string(saml.EmailAddressNameIDFormat) {
+		identity.Email = nameID.Value
+	}
+	identity.EmailVerified = identity.Email != ""
+	identity.FirstName = firstClaim(identity.Claims, samlFirstNameAttributes)
+	identity.LastName = firstClaim(identity.Claims, samlLastNameAttributes)
+	identity.Username = firstClaim(identity.Claims, samlUsernameAttributes)
+	if _, domain, ok := strings.Cut(identity.Email, "@"); ok {
+		identity.Claims["email_domain"] = []string{strings.ToLower(domain)}
+	}
+	return identity
+}
+
+func firstClaim(claims map[string][]string, names []string) string {
+	for _, name := range names {
+		if values := claims[name]; len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// redirectAllowed reports whether redirect is on the origin of one of
+// SAML_REDIRECT_URLS and under its path
+func (s *SAML) redirectAllowed(redirect string) bool {
+	u, err := url.Parse(redirect)
+	if err != nil || u.User != nil {
+		return false
+	}
+	for _, allowed := range s.redirects {
+		a, err := url.Parse(allowed)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(u.Scheme, a.Scheme) && strings.EqualFold(u.Host, a.Host) && strings.HasPrefix(u.Path, a.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceProvider loads the IdP metadata on first use and keeps the
+// service provider once it succeeds
+func (s *SAML) serviceProvider() (*saml.ServiceProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sp != nil {
+		return s.sp, nil
+	}
+
+	var idp *saml.EntityDescriptor
+	if strings.HasPrefix(s.metadata, "https://") || strings.HasPrefix(s.metadata, "http://") {
+		metadataURL, err := url.Parse(s.metadata)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SAML_IDP_METADATA: %w", err)
+		}
+		idp, err = samlsp.FetchMetadata(context.Background(), s.client, *metadataURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch SAML IdP metadata: %w", err)
+		}
+	} else {
+		data, err := os.ReadFile(s.metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SAML IdP metadata: %w", err)
+		}
+		idp, err = samlsp.ParseMetadata(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SAML IdP metadata: %w", err)
+		}
+	}
+
+	sp := s.base
+	sp.IDPMetadata = idp
+	sp.HTTPClient = s.client
+	s.sp = &sp
+	return s.sp, nil
+}
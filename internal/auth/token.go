@@ -0,0 +1,161 @@
+// internal/auth/token.go
+package auth
+
+import (
+	"api-server/internal/config"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// issuer names this API in the tokens it signs
+const issuer = "api-server"
+
+// mfaChallengeType marks a token that only proves the first factor. It can
+// be redeemed for an access token with a TOTP code and nothing else.
+const mfaChallengeType = "mfa"
+
+// mfaChallengeTTL is how long the user has to enter their code
+const mfaChallengeTTL = 5 * time.Minute
+
+// ErrInvalidToken is returned for a bearer token that is malformed, expired
+// or not signed by this API
+var ErrInvalidToken = errors.New("invalid token")
+
+// Tokens issues and verifies the API's own bearer tokens, HS256 JWTs naming
+// the user and the tenant they belong to. A nil *Tokens issues nothing and
+// rejects every token.
+type Tokens struct {
+	secret           []byte
+	ttl              time.Duration
+	impersonationTTL time.Duration
+}
+
+// Claims are what a verified token vouches for
+type Claims struct {
+	// SessionID is the token's jti, which names its session
+	SessionID uuid.UUID
+	UserID    uuid.UUID
+	TenantID  uuid.UUID
+	ExpiresAt time.Time
+	// ImpersonatorID is the admin acting as the user, or uuid.Nil
+	ImpersonatorID uuid.UUID
+}
+
+type tokenClaims struct {
+	TenantID string       `json:"tid"`
+	Type     string       `json:"typ,omitempty"`
+	Actor    *actorClaims `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// actorClaims is the RFC 8693 actor of an impersonation token: who is
+// acting as its subject
+type actorClaims struct {
+	Subject string `json:"sub"`
+}
+
+// NewTokens returns nil when AUTH_TOKEN_SECRET is unset
+func NewTokens(cfg *config.Config) *Tokens {
+	if cfg.AuthTokenSecret == "" {
+		return nil
+	}
+	return &Tokens{secret: []byte(cfg.AuthTokenSecret), ttl: cfg.AuthTokenTTL, impersonationTTL: cfg.ImpersonationTokenTTL}
+}
+
+// Issue signs a token for the user's session, returning it with its expiry
+func (t *Tokens) Issue(sessionID, userID, tenantID uuid.UUID) (string, time.Time, error) {
+	if t == nil {
+		return "", time.Time{}, errors.New("bearer tokens are not configured")
+	}
+	return t.sign(sessionID, userID, tenantID, "", nil, t.ttl)
+}
+
+// IssueImpersonation signs a short-lived token for the user's session that
+// names the admin acting as them
+func (t *Tokens) IssueImpersonation(sessionID, userID, tenantID, adminID uuid.UUID) (string, time.Time, error) {
+	if t == nil {
+		return "", time.Time{}, errors.New("bearer tokens are not configured")
+	}
+	return t.sign(sessionID, userID, tenantID, "", &actorClaims{Subject: adminID.String()}, t.impersonationTTL)
+}
+
+// IssueMFAChallenge signs a short-lived token for a user who still has to
+// give a TOTP code
+func (t *Tokens) IssueMFAChallenge(userID, tenantID uuid.UUID) (string, time.Time, error) {
+	if t == nil {
+		return "", time.Time{}, errors.New("bearer tokens are not configured")
+	}
+	return t.sign(uuid.New(), userID, tenantID, mfaChallengeType, nil, mfaChallengeTTL)
+}
+
+func (t *Tokens) sign(tokenID, userID, tenantID uuid.UUID, tokenType string, actor *actorClaims, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl).Truncate(time.Second)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims{
+		TenantID: tenantID.String(),
+		Type:     tokenType,
+		Actor:    actor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        tokenID.String(),
+		},
+	})
+	signed, err := token.SignedString(t.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// Verify checks an access token's signature, issuer and expiry
+func (t *Tokens) Verify(token string) (*Claims, error) {
+	return t.verify(token, "")
+}
+
+// VerifyMFAChallenge checks a token from IssueMFAChallenge
+func (t *Tokens) VerifyMFAChallenge(token string) (*Claims, error) {
+	return t.verify(token, mfaChallengeType)
+}
+
+func (t *Tokens) verify(token, tokenType string) (*Claims, error) {
+	if t == nil {
+		return nil, ErrInvalidToken
+	}
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return t.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Type != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	tenantID, err := uuid.Parse(claims.TenantID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	sessionID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	verified := &Claims{SessionID: sessionID, UserID: userID, TenantID: tenantID, ExpiresAt: claims.ExpiresAt.Time}
+	if claims.Actor != nil {
+		if verified.ImpersonatorID, err = uuid.Parse(claims.Actor.Subject); err != nil {
+			return nil, ErrInvalidToken
+		}
+	}
+	return verified, nil
+}
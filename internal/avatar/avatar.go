@@ -0,0 +1,103 @@
+// internal/avatar/avatar.go
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"path"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnsupported is returned for an upload that isn't a PNG, JPEG or GIF
+// image, or is too large to decode
+var ErrUnsupported = errors.New("unsupported image")
+
+// maxSourcePixels caps the images decoded, so a small file declaring huge
+// dimensions can't exhaust memory
+const maxSourcePixels = 40_000_000
+
+// ObjectName is where a user's profile picture goes, before the tenant's
+// bucket prefix
+func ObjectName(userID uuid.UUID) string {
+	return path.Join("avatars", userID.String()+".png")
+}
+
+// Image is a profile picture ready to store
+type Image struct {
+	PNG  []byte
+	Size int
+}
+
+// Process decodes an uploaded image, crops it to a centered square and
+// scales it down to size pixels, or keeps it as is if it's smaller, and
+// encodes the result as PNG
+func Process(data []byte, size int) (*Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxSourcePixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", ErrUnsupported, config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	square := centerSquare(src.Bounds())
+	size = min(size, square.Dx())
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scale(src, square, size)); err != nil {
+		return nil, err
+	}
+	return &Image{PNG: buf.Bytes(), Size: size}, nil
+}
+
+// centerSquare is the largest square centered in bounds
+func centerSquare(bounds image.Rectangle) image.Rectangle {
+	side := min(bounds.Dx(), bounds.Dy())
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// scale resamples the square region of src to size by size pixels,
+// averaging the source pixels each destination pixel covers
+func scale(src image.Image, region image.Rectangle, size int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	side := region.Dx()
+	for dy := 0; dy < size; dy++ {
+		y0 := region.Min.Y + dy*side/size
+		y1 := max(region.Min.Y+(dy+1)*side/size, y0+1)
+		for dx := 0; dx < size; dx++ {
+			x0 := region.Min.X + dx*side/size
+			x1 := max(region.Min.X+(dx+1)*side/size, x0+1)
+
+			// Sum premultiplied colors so transparent pixels don't darken
+			// the edges around them
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					pr, pg, pb, pa := src.At(x, y).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			i := dst.PixOffset(dx, dy)
+			if a == 0 {
+				continue
+			}
+			dst.Pix[i+0] = uint8(r * 0xff / a)
+			dst.Pix[i+1] = uint8(g * 0xff / a)
+			dst.Pix[i+2] = uint8(b * 0xff / a)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
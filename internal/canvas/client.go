@@ -0,0 +1,139 @@
+// internal/canvas/client.go
+package canvas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Errors for the Canvas answers a sync reports as conflicts
+var (
+	errNotFound     = errors.New("canvas: not found")
+	errAccessDenied = errors.New("canvas: access denied")
+)
+
+// Client calls one tenant's Canvas instance through its REST API with the
+// tenant's API token
+type Client struct {
+	baseURL *url.URL
+	token   string
+	client  *http.Client
+}
+
+func NewClient(baseURL, token string, timeout time.Duration) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Canvas URL %q", baseURL)
+	}
+	return &Client{baseURL: u, token: token, client: &http.Client{Timeout: timeout}}, nil
+}
+
+// User is a member of a Canvas course with their enrollments in it
+type User struct {
+	ID          int64        `json:"id"`
+	Name        string       `json:"name"`
+	Email       *string      `json:"email"`
+	Enrollments []Enrollment `json:"enrollments"`
+}
+
+type Enrollment struct {
+	// Type is StudentEnrollment, TeacherEnrollment, TaEnrollment,
+	// DesignerEnrollment or ObserverEnrollment
+	Type string `json:"type"`
+}
+
+// CourseUsers returns every user enrolled in a Canvas course, following
+// Canvas's pagination
+func (c *Client) CourseUsers(ctx context.Context, courseID int64) ([]User, error) {
+	next := c.url(fmt.Sprintf("/api/v1/courses/%d/users?include[]=email&include[]=enrollments&per_page=100", courseID))
+	var users []User
+	for next != "" {
+		var page []User
+		header, err := c.do(ctx, http.MethodGet, next, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, page...)
+		next = c.nextPage(header)
+	}
+	return users, nil
+}
+
+// PutPage creates or replaces the published wiki page at slug in a Canvas
+// course
+func (c *Client) PutPage(ctx context.Context, courseID int64, slug, title, body string) error {
+	page := map[string]any{"wiki_page": map[string]any{"title": title, "body": body, "published": true}}
+	_, err := c.do(ctx, http.MethodPut, c.url(fmt.Sprintf("/api/v1/courses/%d/pages/%s", courseID, url.PathEscape(slug))), page, nil)
+	return err
+}
+
+func (c *Client) url(path string) string {
+	return c.baseURL.String() + path
+}
+
+// nextPage returns the rel="next" URL of a Link header, if it is on the
+// Canvas instance; the token must not be sent anywhere else
+func (c *Client) nextPage(header http.Header) string {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme != c.baseURL.Scheme || u.Host != c.baseURL.Host {
+			return ""
+		}
+		return target
+	}
+	return ""
+}
+
+func (c *Client) do(ctx context.Context, method, target string, body, out any) (http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Canvas: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errNotFound
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, errAccessDenied
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("Canvas returned %s for %s %s: %s", resp.Status, method, req.URL.Path, bytes.TrimSpace(detail))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("failed to decode Canvas response: %w", err)
+		}
+	}
+	return resp.Header, nil
+}
@@ -0,0 +1,321 @@
+// internal/canvas/syncer.go
+package canvas
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/errortracking"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/tenant"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PageSlug and PageTitle name the wiki page each linked Canvas course gets,
+// listing the course and its processed traces
+const (
+	PageSlug  = "api-server-traces"
+	PageTitle = "Course traces"
+)
+
+// enrollmentRoles maps Canvas enrollment types onto roster roles
+var enrollmentRoles = map[string]string{
+	"TeacherEnrollment":  model.CanvasRoleTeacher,
+	"TaEnrollment":       model.CanvasRoleTA,
+	"StudentEnrollment":  model.CanvasRoleStudent,
+	"DesignerEnrollment": model.CanvasRoleDesigner,
+	"ObserverEnrollment": model.CanvasRoleObserver,
+}
+
+// Syncer runs Canvas syncs: it queues one for every connected tenant that
+// is due, and runs those and the ones admins request. A sync pulls the
+// roster of each linked course and pushes a page of links to it; what it
+// can't reconcile is reported as conflicts on the sync. A syncer that dies
+// mid-sync leaves it to be claimed again once its lease runs out.
+type Syncer struct {
+	repo     repository.Repository
+	poll     time.Duration
+	interval time.Duration
+	lease    time.Duration
+	timeout  time.Duration
+	wake     chan struct{}
+}
+
+func NewSyncer(repo repository.Repository, cfg *config.Config) *Syncer {
+	return &Syncer{
+		repo:     repo,
+		poll:     cfg.CanvasPollInterval,
+		interval: cfg.CanvasSyncInterval,
+		lease:    cfg.CanvasSyncLease,
+		timeout:  cfg.CanvasTimeout,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Notify asks the syncer to look for syncs now instead of waiting for the next poll
+func (s *Syncer) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run queues and runs syncs until ctx is cancelled
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+
+		if queued, err := s.repo.ScheduleCanvasSyncs(ctx, s.interval); err != nil {
+			log.Printf("Failed to schedule Canvas syncs: %v", err)
+			errortracking.Capture(err, "canvas", nil)
+		} else if queued > 0 {
+			log.Printf("Scheduled %d Canvas syncs", queued)
+		}
+
+		// Keep going while there are syncs to run
+		for {
+			ran, err := s.runNext(ctx)
+			if err != nil {
+				log.Printf("Canvas sync failed: %v", err)
+				errortracking.Capture(err, "canvas", nil)
+				break
+			}
+			if !ran {
+				break
+			}
+		}
+	}
+}
+
+// runNext claims and runs one sync, reporting whether there was one
+func (s *Syncer) runNext(ctx context.Context) (bool, error) {
+	run, err := s.repo.ClaimCanvasSync(ctx, s.lease)
+	if errors.Is(err, model.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	t, err := s.repo.GetTenantByID(ctx, run.TenantID)
+	if err != nil {
+		return true, fmt.Errorf("failed to load tenant of Canvas sync %s: %w", run.ID, err)
+	}
+	ctx = tenant.NewContext(ctx, t)
+
+	run.Conflicts = []model.CanvasConflict{}
+	run.CoursesSynced = 0
+	run.RosterEntries = 0
+	if err := s.sync(ctx, run); err != nil {
+		log.Printf("Canvas sync %s of tenant %s failed: %v", run.ID, t.Slug, err)
+		errortracking.Capture(err, "canvas", map[string]string{"sync_id": run.ID.String(), "tenant": t.Slug})
+		message := err.Error()
+		run.Error = &message
+		run.Status = model.DataJobFailed
+	} else {
+		log.Printf("Canvas sync %s of tenant %s completed: %d courses, %d conflicts", run.ID, t.Slug, run.CoursesSynced, len(run.Conflicts))
+		run.Error = nil
+		run.Status = model.DataJobCompleted
+	}
+	return true, s.repo.FinishCanvasSync(ctx, *run)
+}
+
+// sync syncs every linked course of ctx's tenant. It fails only when the
+// tenant can't be synced at all; a course that can't is a conflict.
+func (s *Syncer) sync(ctx context.Context, run *model.CanvasSync) error {
+	conn, err := s.repo.GetCanvasConnection(ctx)
+	if errors.Is(err, model.ErrNotFound) {
+		return errors.New("Canvas is not connected")
+	}
+	if err != nil {
+		return err
+	}
+	if !conn.Enabled {
+		return errors.New("the Canvas connection is disabled")
+	}
+	client, err := NewClient(conn.BaseURL, conn.APIToken, s.timeout)
+	if err != nil {
+		return err
+	}
+	links, err := s.repo.ListCanvasCourseLinks(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		result, err := s.syncCourse(ctx, client, conn, link)
+		if err != nil {
+			return fmt.Errorf("failed to sync course %s: %w", link.CourseID, err)
+		}
+		run.Conflicts = append(run.Conflicts, result.conflicts...)
+		run.RosterEntries += result.rosterEntries
+		if result.synced {
+			run.CoursesSynced++
+		}
+	}
+	return nil
+}
+
+// courseResult is what syncing one course found. A course is synced when
+// both its roster was pulled and its page pushed.
+type courseResult struct {
+	conflicts     []model.CanvasConflict
+	rosterEntries int
+	synced        bool
+}
+
+// syncCourse pulls one course's roster and pushes its page. Canvas failures
+// are conflicts; the error is for failures of our own.
+func (s *Syncer) syncCourse(ctx context.Context, client *Client, conn *model.CanvasConnection, link model.CanvasCourseLink) (courseResult, error) {
+	var result courseResult
+	conflict := func(kind, detail string) {
+		result.conflicts = append(result.conflicts, model.CanvasConflict{CourseID: link.CourseID, CanvasCourseID: link.CanvasCourseID, Kind: kind, Detail: detail})
+	}
+
+	course, err := s.repo.GetCourseByID(ctx, link.CourseID)
+	if errors.Is(err, model.ErrNotFound) {
+		// Deleted since the links were listed; its link went with it
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	users, err := client.CourseUsers(ctx, link.CanvasCourseID)
+	if err != nil {
+		conflict(canvasErrorKind(err), "Failed to pull the roster: "+err.Error())
+		return result, nil
+	}
+	roster, err := s.repo.ReplaceCanvasRoster(ctx, link.CourseID, rosterEntries(users))
+	if errors.Is(err, model.ErrNotFound) {
+		// Unlinked since the links were listed
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	result.rosterEntries = len(roster)
+
+	taught := false
+	for _, e := range roster {
+		if e.Role != model.CanvasRoleTeacher {
+			continue
+		}
+		if e.InstructorID == nil {
+			email := "no email"
+			if e.Email != nil {
+				email = *e.Email
+			}
+			conflict(model.CanvasConflictUnknownInstructor, fmt.Sprintf("Canvas teacher %s (%s) matches no instructor", e.Name, email))
+		} else if *e.InstructorID == course.InstructorID {
+			taught = true
+		}
+	}
+	if !taught {
+		conflict(model.CanvasConflictInstructorMismatch, fmt.Sprintf("Instructor %s is not a teacher of the Canvas course", course.InstructorID))
+	}
+
+	traces, err := s.processedTraces(ctx, link.CourseID)
+	if err != nil {
+		return result, err
+	}
+	if err := client.PutPage(ctx, link.CanvasCourseID, PageSlug, PageTitle, pageBody(conn.LinkBaseURL, course, traces)); err != nil {
+		conflict(canvasErrorKind(err), "Failed to push the course page: "+err.Error())
+		return result, nil
+	}
+	result.synced = true
+	return result, nil
+}
+
+// processedTraces returns every processed trace of a course
+func (s *Syncer) processedTraces(ctx context.Context, courseID uuid.UUID) ([]model.Trace, error) {
+	var traces []model.Trace
+	opts := model.ListOptions{Page: model.PageRequest{Limit: model.MaxPageLimit}}
+	for {
+		page, err := s.repo.GetTracesByCourseID(ctx, courseID, nil, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range page.Data {
+			if t.Status == "processed" {
+				traces = append(traces, t)
+			}
+		}
+		if !page.HasMore || page.NextCursor == nil {
+			return traces, nil
+		}
+		if opts.Page.After, err = model.DecodeCursor(*page.NextCursor); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rosterEntries flattens Canvas users into one roster entry per user and role
+func rosterEntries(users []User) []model.CanvasRosterEntry {
+	var entries []model.CanvasRosterEntry
+	for _, u := range users {
+		for _, e := range u.Enrollments {
+			role, ok := enrollmentRoles[e.Type]
+			if !ok {
+				continue
+			}
+			entries = append(entries, model.CanvasRosterEntry{CanvasUserID: u.ID, Name: u.Name, Email: u.Email, Role: role})
+		}
+	}
+	return entries
+}
+
+// pageBody is the HTML of a course's page: a link to the course and one to
+// each processed trace
+func pageBody(linkBaseURL string, course *model.Course, traces []model.Trace) string {
+	base := strings.TrimRight(linkBaseURL, "/")
+	courseURL := fmt.Sprintf("%s/course/%s", base, course.ID)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<p>Traces for <a href="%s">%s %d: %s</a> (%s %d).</p>`,
+		html.EscapeString(courseURL), html.EscapeString(course.SubjectCode), course.CourseID,
+		html.EscapeString(course.Name), html.EscapeString(course.SemesterTerm), course.SemesterYear)
+	if len(traces) == 0 {
+		b.WriteString("<p>No traces have been processed yet.</p>")
+		return b.String()
+	}
+	b.WriteString("<ul>")
+	for _, t := range traces {
+		// A video link has no file name, so it is listed by the video's URL
+		name := t.FileName
+		if t.LinkURL != nil {
+			name = *t.LinkURL
+		}
+		fmt.Fprintf(&b, `<li><a href="%s">%s</a></li>`,
+			html.EscapeString(fmt.Sprintf("%s/trace/%s", courseURL, t.ID)), html.EscapeString(name))
+	}
+	b.WriteString("</ul>")
+	return b.String()
+}
+
+// canvasErrorKind is the conflict kind for a failed Canvas call
+func canvasErrorKind(err error) string {
+	switch {
+	case errors.Is(err, errNotFound):
+		return model.CanvasConflictCourseNotFound
+	case errors.Is(err, errAccessDenied):
+		return model.CanvasConflictAccessDenied
+	default:
+		return model.CanvasConflictError
+	}
+}
@@ -0,0 +1,131 @@
+// internal/capture/capture.go
+package capture
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Capture is one request and its response as the API saw them, redacted
+type Capture struct {
+	RequestID       string      `json:"request_id"`
+	Time            time.Time   `json:"time"`
+	Method          string      `json:"method"`
+	URI             string      `json:"uri"`
+	Status          int         `json:"status"`
+	DurationMS      float64     `json:"duration_ms"`
+	RequestHeaders  http.Header `json:"request_headers"`
+	RequestBody     Body        `json:"request_body"`
+	ResponseHeaders http.Header `json:"response_headers"`
+	ResponseBody    Body        `json:"response_body"`
+}
+
+// Body is what was captured of a request or response body. Content holds
+// the first bytes of a textual body, such as JSON; binary bodies, such as
+// uploaded PDFs, are only measured.
+type Body struct {
+	ContentType string `json:"content_type,omitempty"`
+	// Size is how much of the body was read or written in all
+	Size      int64  `json:"size"`
+	Content   string `json:"content,omitempty"`
+	Truncated bool   `json:"truncated"`
+}
+
+// Recorder keeps the latest captures, and the request IDs an admin has
+// asked to capture, in this process only, so each replica has its own. A
+// nil Recorder captures nothing.
+type Recorder struct {
+	samplePercent int
+	maxBodyBytes  int
+	maxEntries    int
+
+	mu       sync.Mutex
+	captures []Capture // oldest first
+	watched  map[string]bool
+}
+
+func New(samplePercent, maxBodyBytes, maxEntries int) *Recorder {
+	return &Recorder{
+		samplePercent: samplePercent,
+		maxBodyBytes:  maxBodyBytes,
+		maxEntries:    maxEntries,
+		watched:       map[string]bool{},
+	}
+}
+
+// MaxBodyBytes is how much of each body is kept
+func (r *Recorder) MaxBodyBytes() int {
+	return r.maxBodyBytes
+}
+
+// ShouldCapture reports whether the request with requestID is to be
+// captured: a watched one always is, once, and others by sampling
+func (r *Recorder) ShouldCapture(requestID string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watched[requestID] {
+		delete(r.watched, requestID)
+		return true
+	}
+	return r.samplePercent > 0 && rand.IntN(100) < r.samplePercent
+}
+
+// Watch captures the next request with requestID as its X-Request-ID,
+// whatever the sampling. Only as many request IDs as captures are kept are
+// watched at once; beyond that the oldest is forgotten.
+func (r *Recorder) Watch(requestID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.watched) >= r.maxEntries {
+		for id := range r.watched {
+			delete(r.watched, id)
+			break
+		}
+	}
+	r.watched[requestID] = true
+}
+
+// Add keeps c, dropping the oldest capture when there are too many
+func (r *Recorder) Add(c Capture) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.captures) >= r.maxEntries {
+		r.captures = slices.Delete(r.captures, 0, len(r.captures)-r.maxEntries+1)
+	}
+	r.captures = append(r.captures, c)
+}
+
+// List returns the captures, newest first
+func (r *Recorder) List() []Capture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	captures := slices.Clone(r.captures)
+	slices.Reverse(captures)
+	return captures
+}
+
+// Get returns the latest capture of the request with requestID
+func (r *Recorder) Get(requestID string) (Capture, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.captures) - 1; i >= 0; i-- {
+		if r.captures[i].RequestID == requestID {
+			return r.captures[i], true
+		}
+	}
+	return Capture{}, false
+}
+
+// Clear forgets every capture and watched request ID
+func (r *Recorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.captures = nil
+	clear(r.watched)
+}
@@ -0,0 +1,122 @@
+// internal/chaos/chaos.go
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Dependencies faults can be injected into. HTTP is the API itself, as its
+// clients see it; storage and publisher are the backends it calls.
+const (
+	DependencyHTTP      = "http"
+	DependencyStorage   = "storage"
+	DependencyPublisher = "publisher"
+)
+
+var Dependencies = []string{DependencyHTTP, DependencyStorage, DependencyPublisher}
+
+var (
+	// ErrInjected is the error an injected failure returns
+	ErrInjected          = errors.New("injected fault")
+	ErrUnknownDependency = errors.New("unknown dependency")
+)
+
+// Fault is what is injected into calls to one dependency: LatencyPercent of
+// them are delayed by LatencyMS, and ErrorPercent of them then fail. The
+// zero Fault injects nothing.
+type Fault struct {
+	Dependency     string `json:"dependency"`
+	LatencyMS      int    `json:"latency_ms"`
+	LatencyPercent int    `json:"latency_percent"`
+	ErrorPercent   int    `json:"error_percent"`
+}
+
+// Injector holds the faults for each dependency. They live in this process
+// only, so each replica is configured on its own. A nil Injector injects
+// nothing.
+type Injector struct {
+	mu     sync.RWMutex
+	faults map[string]Fault
+}
+
+func New() *Injector {
+	return &Injector{faults: map[string]Fault{}}
+}
+
+// List reports the fault for every dependency, including those without one
+func (i *Injector) List() []Fault {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	faults := make([]Fault, 0, len(Dependencies))
+	for _, dependency := range Dependencies {
+		faults = append(faults, i.fault(dependency))
+	}
+	return faults
+}
+
+// Set replaces the fault for f.Dependency
+func (i *Injector) Set(f Fault) (Fault, error) {
+	if !slices.Contains(Dependencies, f.Dependency) {
+		return Fault{}, ErrUnknownDependency
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults[f.Dependency] = f
+	return f, nil
+}
+
+// Clear stops injecting faults into dependency
+func (i *Injector) Clear(dependency string) (Fault, error) {
+	if !slices.Contains(Dependencies, dependency) {
+		return Fault{}, ErrUnknownDependency
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.faults, dependency)
+	return Fault{Dependency: dependency}, nil
+}
+
+// Inject applies dependency's fault to one call: it waits out any latency,
+// returning early with ctx's error if ctx is done first, then returns an
+// error wrapping ErrInjected if the call is to fail
+func (i *Injector) Inject(ctx context.Context, dependency string) error {
+	if i == nil {
+		return nil
+	}
+	i.mu.RLock()
+	f := i.fault(dependency)
+	i.mu.RUnlock()
+
+	if f.LatencyMS > 0 && hit(f.LatencyPercent) {
+		timer := time.NewTimer(time.Duration(f.LatencyMS) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if hit(f.ErrorPercent) {
+		return fmt.Errorf("%w into %s", ErrInjected, dependency)
+	}
+	return nil
+}
+
+func (i *Injector) fault(dependency string) Fault {
+	if f, ok := i.faults[dependency]; ok {
+		return f
+	}
+	return Fault{Dependency: dependency}
+}
+
+// hit reports whether a call falls within percent of calls
+func hit(percent int) bool {
+	return percent > 0 && rand.IntN(100) < percent
+}
@@ -1,14 +1,29 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// ModeInMemory runs the API against in-memory fakes, for demos and integration tests
+const ModeInMemory = "inmemory"
+
+// EnvDevelopment loads .env and turns on development-only checks
+const EnvDevelopment = "development"
+
 type Config struct {
+	// Env is the deployment environment, e.g. "development"
+	Env string
+
+	// Mode "inmemory" replaces Postgres, GCS and Kafka with in-process fakes
+	Mode string
+
 	DBHost             string
 	DBPort             string
 	DBUser             string
@@ -17,11 +32,428 @@ type Config struct {
 	GCSBucketName      string
 	GCSCredentialsFile string
 	KAFKA_BROKER       string
+
+	// Kafka SASL/PLAIN authentication, disabled when the username is empty,
+	// and TLS to the brokers
+	KafkaSASLUsername string
+	KafkaSASLPassword string
+	KafkaTLS          bool
+
+	// Secret backends. DB_PASSWORD and the Kafka SASL credentials may be
+	// vault://<path>#<key> or gcpsm://projects/<project>/secrets/<name>
+	// references, resolved at startup and refreshed every interval.
+	VaultAddr              string
+	VaultToken             string
+	SecretsRefreshInterval time.Duration
+	// SecretRefs maps setting names to the references they were given as
+	SecretRefs map[string]string
+
+	// GCS client initialization
+	GCSInitAttempts int
+	GCSInitBackoff  time.Duration
+	GCSInitCooldown time.Duration
+
+	// Storage operations other than downloads run on StorageWorkers workers,
+	// each given StorageTaskTimeout
+	StorageWorkers     int
+	StorageTaskTimeout time.Duration
+
+	// Local development backends
+	StorageEmulatorHost string
+	PublisherBackend    string
+
+	// Trace storage lifecycle
+	GCSArchiveBucketName  string
+	LifecycleEnabled      bool
+	LifecycleInterval     time.Duration
+	LifecycleArchiveAfter int
+	// LifecycleCourseArchiveAfter archives courses this many semesters old;
+	// 0 leaves courses alone
+	LifecycleCourseArchiveAfter int
+
+	// Traces are partitioned by semester. Partitions are created every
+	// TracePartitionInterval for the semesters starting within
+	// TracePartitionMonthsAhead months.
+	TracePartitionInterval    time.Duration
+	TracePartitionMonthsAhead int
+
+	// Background text extraction from uploaded PDFs for keyword search.
+	// PDFs without a text layer are posted to ExtractOCRURL when it is set.
+	ExtractEnabled   bool
+	ExtractInterval  time.Duration
+	ExtractBatchSize int
+	ExtractOCRURL    string
+
+	// Background rendering of each uploaded PDF's first page as a PNG
+	// ThumbnailWidth pixels wide, with pdftoppm at ThumbnailPdftoppmPath
+	ThumbnailEnabled      bool
+	ThumbnailInterval     time.Duration
+	ThumbnailBatchSize    int
+	ThumbnailWidth        int
+	ThumbnailPdftoppmPath string
+
+	// Profile pictures: PNG, JPEG or GIF uploads up to AvatarMaxBytes,
+	// cropped square and stored as AvatarSize pixel PNGs
+	AvatarMaxBytes int64
+	AvatarSize     int
+
+	// Retry and circuit breaker settings for GCS and Kafka calls
+	RetryMaxAttempts        int
+	RetryBaseDelay          time.Duration
+	RetryMaxDelay           time.Duration
+	BreakerFailureThreshold int
+	BreakerCooldown         time.Duration
+
+	// Startup retries while Postgres and Kafka come up. With StartupDegraded
+	// the server listens straight away and answers /livez while it waits.
+	StartupRetryAttempts  int
+	StartupRetryBaseDelay time.Duration
+	StartupRetryMaxDelay  time.Duration
+	StartupDegraded       bool
+
+	// Database connection pool
+	DBMaxOpenConns    int
+	DBMinConns        int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+
+	// Statements taking at least this long are logged; 0 logs none
+	DBSlowQueryThreshold time.Duration
+
+	// Transactional outbox relay
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	OutboxMaxAttempts  int
+
+	// Personal data export and erasure jobs
+	DataJobPollInterval time.Duration
+	DataJobLease        time.Duration
+	DataJobMaxAttempts  int
+
+	// Request timeouts per route group
+	RequestTimeoutRead   time.Duration
+	RequestTimeoutWrite  time.Duration
+	RequestTimeoutUpload time.Duration
+
+	// Request body size limits in bytes
+	MaxJSONBodyBytes   int64
+	MaxUploadBodyBytes int64
+
+	// Total trace bytes a course may hold; zero is unlimited
+	CourseMaxStorageBytes int64
+
+	// Uploads, and their total bytes, each user may make per day (UTC);
+	// zero is unlimited
+	UserMaxUploadsPerDay     int64
+	UserMaxUploadBytesPerDay int64
+
+	// How long an upload's progress stays readable after it last changed
+	UploadProgressRetention time.Duration
+
+	// MIME types traces may be uploaded as
+	UploadAllowedTypes []string
+
+	// How long trace share links last unless their creator says otherwise,
+	// and the longest they may be made to last
+	ShareLinkDefaultTTL time.Duration
+	ShareLinkMaxTTL     time.Duration
+
+	// /v1 is deprecated in favour of /v2. The dates are announced in the
+	// Deprecation and Sunset headers of every v1 response; both are optional.
+	APIV1DeprecationDate time.Time
+	APIV1SunsetDate      time.Time
+
+	// Host several departments on one deployment. When off, every request
+	// belongs to the default tenant. TenantBaseDomain, if set, makes
+	// <slug>.<TenantBaseDomain> select a tenant.
+	MultiTenancy     bool
+	TenantBaseDomain string
+
+	// Cache-Control for the public course catalog (course list and course
+	// reads); every other /v1 and /v2 response is no-store
+	CatalogCacheMaxAge               time.Duration
+	CatalogCacheStaleWhileRevalidate time.Duration
+
+	// Per-client rate limit on /v1 and /v2, keyed by user or remote address;
+	// zero RPS disables it
+	RateLimitRPS   int
+	RateLimitBurst int
+
+	// Caps on requests in flight at once, uploads apart from every other
+	// /v1 and /v2 route, with a queue each of ConcurrencyQueue requests
+	// waiting up to ConcurrencyQueueTimeout; zero disables a cap
+	ConcurrencyLimit        int
+	ConcurrencyLimitUpload  int
+	ConcurrencyQueue        int
+	ConcurrencyQueueTimeout time.Duration
+
+	// The API listener serves HTTPS with TLSCertFile and TLSKeyFile,
+	// offering HTTP/2 to clients that support it, and plain HTTP/1.1
+	// without them. HTTP2Cleartext adds HTTP/2 without TLS (h2c) to the
+	// plain listener, for clients inside the cluster whose service mesh
+	// terminates TLS. Listeners, when set, replaces that one listener with
+	// any number of TCP addresses and Unix sockets, each with its own TLS
+	// and h2c settings; see ParseListener. An HTTP/2 connection carries up
+	// to HTTP2MaxConcurrentStreams requests at once, and connections of
+	// either version are closed after HTTPIdleTimeout without a request.
+	TLSCertFile               string
+	TLSKeyFile                string
+	HTTP2Cleartext            bool
+	Listeners                 []string
+	HTTP2MaxConcurrentStreams int
+	HTTPIdleTimeout           time.Duration
+
+	// Client addresses allowed to reach /admin endpoints, and /metrics with
+	// AdminIPProtectMetrics, as CIDRs or bare IPs. The denylist wins over
+	// the allowlist; an empty allowlist allows any address not denied.
+	AdminIPAllowlist      []string
+	AdminIPDenylist       []string
+	AdminIPProtectMetrics bool
+
+	// Load balancers whose X-Forwarded-For and X-Real-IP headers are
+	// believed, as CIDRs or bare IPs. The client address they give is used
+	// for rate limiting, logs, sessions and the admin IP allowlist.
+	TrustedProxies []string
+
+	// Validate traffic against api/openapi.yaml; on by default in development
+	OpenAPIValidation bool
+
+	// Profiling listener; an empty address disables it
+	DebugAddr         string
+	DebugRequireAdmin bool
+
+	// /metrics moves from the API listeners to MetricsAddr when it is set.
+	// Scrapes must carry MetricsUsername and MetricsPassword, or
+	// MetricsToken as a bearer token, when those are set.
+	MetricsAddr     string
+	MetricsUsername string
+	MetricsPassword string
+	MetricsToken    string
+
+	// Fault injection for resilience testing, development only: latency and
+	// errors set on /v1/admin/chaos are injected into API requests and into
+	// storage and publisher calls beneath their retries and breakers
+	ChaosEnabled bool
+
+	// Payload capture for debugging client integrations: CaptureSamplePercent
+	// of requests, plus any request ID watched on /v1/admin/captures, have
+	// their redacted headers and first CaptureMaxBodyBytes of body kept, up
+	// to CaptureMaxEntries captures per replica
+	CaptureEnabled       bool
+	CaptureSamplePercent int
+	CaptureMaxBodyBytes  int
+	CaptureMaxEntries    int
+
+	// Initial log level; PUT /v1/admin/loglevel changes it at runtime
+	LogLevel string
+
+	// Per-request access logs, "none", "combined" or "json", written to
+	// AccessLogFile or else stdout. AccessLogSamplePercent of requests are
+	// logged, plus every server error; AccessLogExclude paths never are.
+	AccessLogFormat        string
+	AccessLogFile          string
+	AccessLogSamplePercent int
+	AccessLogExclude       []string
+
+	// HTTP request latency is a histogram with classic buckets and, with
+	// MetricsNativeHistograms, a native histogram alongside them
+	MetricsNativeHistograms bool
+
+	// Error tracking: handler panics, server errors and background job
+	// failures are sent to SentryDSN, tagged with SentryEnvironment (ENV by
+	// default) and SentryRelease (the build's VCS revision by default).
+	// SentryEnabled=false turns it off without removing the DSN.
+	SentryDSN         string
+	SentryEnabled     bool
+	SentryEnvironment string
+	SentryRelease     string
+
+	// Alerts on failed trace uploads, an outbox backlog of at least
+	// NotifyBacklogThreshold pending events, and outbox events given up on,
+	// posted to a Slack webhook and/or emailed through an SMTP relay.
+	// NotifyEvents turns event types off, e.g. NOTIFY_EVENTS=upload_failed=false.
+	// Each event type sends NotifyBurst alerts at once, then at most one per
+	// NotifyMinInterval.
+	NotifySlackWebhookURL  string
+	NotifySMTPAddr         string
+	NotifySMTPUsername     string
+	NotifySMTPPassword     string
+	NotifyEmailFrom        string
+	NotifyEmailTo          []string
+	NotifyEvents           map[string]bool
+	NotifyBacklogThreshold int
+	NotifyCheckInterval    time.Duration
+	NotifyMinInterval      time.Duration
+	NotifyBurst            int
+
+	// Emails to users about their account and traces, sent by MailDriver:
+	// "none", "smtp" through MailSMTPAddr, or "sendgrid" with
+	// MailSendGridAPIKey, from MailFrom. Users turn emails off for themselves.
+	MailDriver         string
+	MailFrom           string
+	MailSMTPAddr       string
+	MailSMTPUsername   string
+	MailSMTPPassword   string
+	MailSendGridAPIKey string
+
+	// Trace embeddings: the processing pipeline writes each trace's vector of
+	// EmbeddingDimensions floats, and similarity search embeds the query
+	// text with EmbeddingBackend, "none" (search disabled) or "openai" for
+	// an OpenAI-compatible endpoint at EmbeddingURL. The model must match
+	// the pipeline's, or distances are meaningless.
+	EmbeddingBackend    string
+	EmbeddingURL        string
+	EmbeddingAPIKey     string
+	EmbeddingModel      string
+	EmbeddingDimensions int
+
+	// Trace summaries: LLMBackend, "none" (summaries disabled) or "openai"
+	// for an OpenAI-compatible chat completions endpoint at LLMURL, digests
+	// a trace's extracted text, cut to LLMMaxInputChars, in at most
+	// LLMMaxTokens tokens.
+	LLMBackend       string
+	LLMURL           string
+	LLMAPIKey        string
+	LLMModel         string
+	LLMMaxTokens     int
+	LLMMaxInputChars int
+	LLMTimeout       time.Duration
+
+	// Search index: with SearchIndexBackend "elasticsearch" (OpenSearch
+	// speaks the same API), every course and trace write is mirrored into
+	// the <SearchIndexPrefix>-courses and -traces indices at SearchIndexURL
+	// through a queue of SearchIndexQueueSize operations. "none" disables it.
+	SearchIndexBackend   string
+	SearchIndexURL       string
+	SearchIndexUsername  string
+	SearchIndexPassword  string
+	SearchIndexPrefix    string
+	SearchIndexQueueSize int
+	SearchIndexTimeout   time.Duration
+
+	// Canvas LMS sync: with CanvasEnabled, every tenant that has connected a
+	// Canvas instance is synced every CanvasSyncInterval, and admins can ask
+	// for a sync at any time. The syncer looks for due and requested syncs
+	// every CanvasPollInterval, holds a claimed sync for CanvasSyncLease
+	// and waits at most CanvasTimeout for each Canvas call.
+	CanvasEnabled      bool
+	CanvasSyncInterval time.Duration
+	CanvasPollInterval time.Duration
+	CanvasSyncLease    time.Duration
+	CanvasTimeout      time.Duration
+
+	// AuthBackend verifies Basic Auth passwords: "local" checks the stored
+	// hash, "ldap" binds to the directory as the user and falls back to
+	// local accounts for usernames the directory doesn't have. Directory
+	// users are linked or provisioned on first login like OIDC ones, with a
+	// role from LDAPRoleMappings keyed group:<cn>.
+	AuthBackend        string
+	LDAPURL            string
+	LDAPStartTLS       bool
+	LDAPCAFile         string
+	LDAPBindDN         string
+	LDAPBindPassword   string
+	LDAPBaseDN         string
+	LDAPUserFilter     string
+	LDAPIDAttribute    string
+	LDAPGroupAttribute string
+	LDAPPoolSize       int
+	LDAPTimeout        time.Duration
+	LDAPDefaultRole    string
+	LDAPRoleMappings   map[string]string
+
+	// Bearer tokens issued by the login endpoints, signed with
+	// AuthTokenSecret. Without a secret only Basic Auth is accepted.
+	// Tokens admins take to act as another user last ImpersonationTokenTTL.
+	AuthTokenSecret       string
+	AuthTokenTTL          time.Duration
+	ImpersonationTokenTTL time.Duration
+
+	// TOTP multi-factor authentication for admins. MFAIssuer names the
+	// account in authenticator apps. With MFARequiredForAdmins, admins
+	// without MFA enabled lose access to admin endpoints until they enroll.
+	// Each login challenge takes MFAChallengeAttempts codes, and
+	// MFAMaxFailedAttempts wrong codes in a row lock the user out for
+	// MFALockout, whatever RATE_LIMIT_RPS allows.
+	MFAIssuer            string
+	MFARequiredForAdmins bool
+	MFAChallengeAttempts int
+	MFAMaxFailedAttempts int
+	MFALockout           time.Duration
+
+	// OIDC login. A provider is enabled by its client ID: Google ID tokens,
+	// GitHub OAuth access tokens (checked against the app's client secret)
+	// and ID tokens from the OIDC_ISSUER of a university SSO. First logins
+	// create a user with OIDCDefaultRole unless OIDCRoleMappings, e.g.
+	// OIDC_ROLE_MAPPINGS=groups:cs-faculty=instructor,org:ops-team=admin,
+	// match one of the identity's claims.
+	OIDCGoogleClientID     string
+	OIDCGoogleHostedDomain string
+	OIDCGitHubClientID     string
+	OIDCGitHubClientSecret string
+	OIDCIssuer             string
+	OIDCClientID           string
+	OIDCDefaultRole        string
+	OIDCRoleMappings       map[string]string
+
+	// SAML login, enabled by the IdP's metadata (a URL or a file). SAMLRootURL
+	// is where the IdP reaches this API; the SP certificate and key sign
+	// requests and decrypt assertions. After login the browser is sent to
+	// one of SAMLRedirectURLs with the bearer token in the fragment. Role
+	// mappings read attributes, e.g. in YAML:
+	//
+	//	saml_role_mappings:
+	//	  "eduPersonAffiliation:faculty": instructor
+	SAMLIdPMetadata  string
+	SAMLRootURL      string
+	SAMLEntityID     string
+	SAMLCertFile     string
+	SAMLKeyFile      string
+	SAMLRedirectURLs []string
+	SAMLDefaultRole  string
+	SAMLRoleMappings map[string]string
+
+	// Feature flags, e.g. FEATURE_FLAGS=async_uploads=true,kafka_consumer=false.
+	// Overrides set through the admin API take precedence.
+	FeatureFlags               map[string]bool
+	FeatureFlagRefreshInterval time.Duration
+}
+
+// Load builds the config from environment variables, then the YAML file at
+// path (skipped when empty), then defaults. Every invalid or missing setting
+// is reported together in the returned error, so startup fails before any
+// setting is first used.
+func Load(path string) (*Config, error) {
+	src, err := newSource(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := build(src)
+
+	problems := append(src.problems(), cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return cfg, nil
 }
 
+// NewConfig builds the config from environment variables alone, without
+// validating it, for callers such as tests that adjust fields afterwards.
+// Values that fail to parse fall back to their defaults with a warning.
 func NewConfig() *Config {
+	src, _ := newSource("")
+	cfg := build(src)
+	for _, problem := range src.problems() {
+		log.Printf("Warning: %s, using default", problem)
+	}
+	return cfg
+}
+
+func build(src *source) *Config {
+	env := src.getEnv("ENV", "")
+
 	// Only load .env if explicitly running in development mode
-	if os.Getenv("ENV") == "development" {
+	if env == EnvDevelopment {
 		projectRoot, _ := os.Getwd()
 		log.Println("Running in development mode, loading .env file")
 		if err := godotenv.Load(filepath.Join(projectRoot, ".env")); err != nil {
@@ -31,22 +463,346 @@ func NewConfig() *Config {
 		log.Println("Running in production mode, using environment variables directly")
 	}
 
-	return &Config{
-		DBHost:             getEnv("DB_HOST", "localhost"),
-		DBPort:             getEnv("DB_PORT", "5432"),
-		DBUser:             getEnv("DB_USER", "admin"),
-		DBPassword:         getEnv("DB_PASSWORD", "password"),
-		DBName:             getEnv("DB_NAME", "api"),
-		GCSBucketName:      getEnv("GCS_BUCKET_NAME", "bucket_name"),
-		GCSCredentialsFile: getEnv("GCS_CREDENTIALS_FILE", ""),
-		KAFKA_BROKER:       getEnv("KAFKA_BROKER", "localhost:9092"),
+	cfg := &Config{
+		Env:  env,
+		Mode: src.getEnv("MODE", ""),
+
+		DBHost:             src.getEnv("DB_HOST", "localhost"),
+		DBPort:             src.getEnv("DB_PORT", "5432"),
+		DBUser:             src.getEnv("DB_USER", "admin"),
+		DBPassword:         src.getEnv("DB_PASSWORD", "password"),
+		DBName:             src.getEnv("DB_NAME", "api"),
+		GCSBucketName:      src.getEnv("GCS_BUCKET_NAME", "bucket_name"),
+		GCSCredentialsFile: src.getEnv("GCS_CREDENTIALS_FILE", ""),
+		KAFKA_BROKER:       src.getEnv("KAFKA_BROKER", "localhost:9092"),
+
+		KafkaSASLUsername: src.getEnv("KAFKA_SASL_USERNAME", ""),
+		KafkaSASLPassword: src.getEnv("KAFKA_SASL_PASSWORD", ""),
+		KafkaTLS:          src.getEnvBool("KAFKA_TLS", false),
+
+		VaultAddr:              src.getEnv("VAULT_ADDR", ""),
+		VaultToken:             src.getEnv("VAULT_TOKEN", ""),
+		SecretsRefreshInterval: src.getEnvDuration("SECRETS_REFRESH_INTERVAL", 5*time.Minute),
+
+		GCSInitAttempts: src.getEnvInt("GCS_INIT_ATTEMPTS", 3),
+		GCSInitBackoff:  src.getEnvDuration("GCS_INIT_BACKOFF", 500*time.Millisecond),
+		GCSInitCooldown: src.getEnvDuration("GCS_INIT_COOLDOWN", 30*time.Second),
+
+		StorageWorkers:     src.getEnvInt("STORAGE_WORKERS", 8),
+		StorageTaskTimeout: src.getEnvDuration("STORAGE_TASK_TIMEOUT", 2*time.Minute),
+
+		StorageEmulatorHost: src.getEnv("STORAGE_EMULATOR_HOST", ""),
+		PublisherBackend:    src.getEnv("PUBLISHER_BACKEND", "kafka"),
+
+		GCSArchiveBucketName:        src.getEnv("GCS_ARCHIVE_BUCKET_NAME", ""),
+		LifecycleEnabled:            src.getEnvBool("LIFECYCLE_ENABLED", false),
+		LifecycleInterval:           src.getEnvDuration("LIFECYCLE_INTERVAL", 24*time.Hour),
+		LifecycleArchiveAfter:       src.getEnvInt("LIFECYCLE_ARCHIVE_AFTER_SEMESTERS", 1),
+		LifecycleCourseArchiveAfter: src.getEnvInt("LIFECYCLE_COURSE_ARCHIVE_AFTER_SEMESTERS", 0),
+
+		TracePartitionInterval:    src.getEnvDuration("TRACE_PARTITION_INTERVAL", 24*time.Hour),
+		TracePartitionMonthsAhead: src.getEnvInt("TRACE_PARTITION_MONTHS_AHEAD", 12),
+
+		ExtractEnabled:   src.getEnvBool("EXTRACT_ENABLED", true),
+		ExtractInterval:  src.getEnvDuration("EXTRACT_INTERVAL", 30*time.Second),
+		ExtractBatchSize: src.getEnvInt("EXTRACT_BATCH_SIZE", 20),
+		ExtractOCRURL:    src.getEnv("EXTRACT_OCR_URL", ""),
+
+		ThumbnailEnabled:      src.getEnvBool("THUMBNAIL_ENABLED", false),
+		ThumbnailInterval:     src.getEnvDuration("THUMBNAIL_INTERVAL", 30*time.Second),
+		ThumbnailBatchSize:    src.getEnvInt("THUMBNAIL_BATCH_SIZE", 20),
+		ThumbnailWidth:        src.getEnvInt("THUMBNAIL_WIDTH", 320),
+		ThumbnailPdftoppmPath: src.getEnv("THUMBNAIL_PDFTOPPM_PATH", "pdftoppm"),
+
+		AvatarMaxBytes: int64(src.getEnvInt("AVATAR_MAX_BYTES", 5<<20)),
+		AvatarSize:     src.getEnvInt("AVATAR_SIZE", 256),
+
+		RetryMaxAttempts:        src.getEnvInt("RETRY_MAX_ATTEMPTS", 3),
+		RetryBaseDelay:          src.getEnvDuration("RETRY_BASE_DELAY", 200*time.Millisecond),
+		RetryMaxDelay:           src.getEnvDuration("RETRY_MAX_DELAY", 5*time.Second),
+		BreakerFailureThreshold: src.getEnvInt("BREAKER_FAILURE_THRESHOLD", 5),
+		BreakerCooldown:         src.getEnvDuration("BREAKER_COOLDOWN", 30*time.Second),
+
+		StartupRetryAttempts:  src.getEnvInt("STARTUP_RETRY_ATTEMPTS", 10),
+		StartupRetryBaseDelay: src.getEnvDuration("STARTUP_RETRY_BASE_DELAY", time.Second),
+		StartupRetryMaxDelay:  src.getEnvDuration("STARTUP_RETRY_MAX_DELAY", 30*time.Second),
+		StartupDegraded:       src.getEnvBool("STARTUP_DEGRADED", false),
+
+		DBMaxOpenConns:    src.getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMinConns:        src.getEnvInt("DB_MIN_CONNS", 2),
+		DBConnMaxLifetime: src.getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime: src.getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+
+		DBSlowQueryThreshold: src.getEnvDuration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+
+		OutboxPollInterval: src.getEnvDuration("OUTBOX_POLL_INTERVAL", 5*time.Second),
+		OutboxBatchSize:    src.getEnvInt("OUTBOX_BATCH_SIZE", 50),
+		OutboxMaxAttempts:  src.getEnvInt("OUTBOX_MAX_ATTEMPTS", 10),
+
+		DataJobPollInterval: src.getEnvDuration("DATA_JOB_POLL_INTERVAL", 10*time.Second),
+		DataJobLease:        src.getEnvDuration("DATA_JOB_LEASE", 15*time.Minute),
+		DataJobMaxAttempts:  src.getEnvInt("DATA_JOB_MAX_ATTEMPTS", 3),
+
+		RequestTimeoutRead:   src.getEnvDuration("REQUEST_TIMEOUT_READ", 5*time.Second),
+		RequestTimeoutWrite:  src.getEnvDuration("REQUEST_TIMEOUT_WRITE", 15*time.Second),
+		RequestTimeoutUpload: src.getEnvDuration("REQUEST_TIMEOUT_UPLOAD", 2*time.Minute),
+
+		MaxJSONBodyBytes:   int64(src.getEnvInt("MAX_JSON_BODY_BYTES", 1<<20)),
+		MaxUploadBodyBytes: int64(src.getEnvInt("MAX_UPLOAD_BODY_BYTES", 10<<20)),
+
+		CourseMaxStorageBytes: int64(src.getEnvInt("COURSE_MAX_STORAGE_BYTES", 0)),
+
+		UserMaxUploadsPerDay:     int64(src.getEnvInt("USER_MAX_UPLOADS_PER_DAY", 0)),
+		UserMaxUploadBytesPerDay: int64(src.getEnvInt("USER_MAX_UPLOAD_BYTES_PER_DAY", 0)),
+
+		UploadProgressRetention: src.getEnvDuration("UPLOAD_PROGRESS_RETENTION", 10*time.Minute),
+
+		UploadAllowedTypes: src.getEnvListOr("UPLOAD_ALLOWED_TYPES", []string{"application/pdf"}),
+
+		ShareLinkDefaultTTL: src.getEnvDuration("SHARE_LINK_DEFAULT_TTL", 7*24*time.Hour),
+		ShareLinkMaxTTL:     src.getEnvDuration("SHARE_LINK_MAX_TTL", 30*24*time.Hour),
+
+		APIV1DeprecationDate: src.getEnvDate("API_V1_DEPRECATION_DATE"),
+		APIV1SunsetDate:      src.getEnvDate("API_V1_SUNSET_DATE"),
+
+		MultiTenancy:     src.getEnvBool("MULTI_TENANCY", false),
+		TenantBaseDomain: src.getEnv("TENANT_BASE_DOMAIN", ""),
+
+		CatalogCacheMaxAge:               src.getEnvDuration("CATALOG_CACHE_MAX_AGE", 5*time.Minute),
+		CatalogCacheStaleWhileRevalidate: src.getEnvDuration("CATALOG_CACHE_STALE_WHILE_REVALIDATE", time.Minute),
+
+		RateLimitRPS:   src.getEnvInt("RATE_LIMIT_RPS", 0),
+		RateLimitBurst: src.getEnvInt("RATE_LIMIT_BURST", 20),
+
+		ConcurrencyLimit:        src.getEnvInt("CONCURRENCY_LIMIT", 0),
+		ConcurrencyLimitUpload:  src.getEnvInt("CONCURRENCY_LIMIT_UPLOAD", 16),
+		ConcurrencyQueue:        src.getEnvInt("CONCURRENCY_QUEUE", 50),
+		ConcurrencyQueueTimeout: src.getEnvDuration("CONCURRENCY_QUEUE_TIMEOUT", 2*time.Second),
+
+		TLSCertFile:               src.getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:                src.getEnv("TLS_KEY_FILE", ""),
+		HTTP2Cleartext:            src.getEnvBool("HTTP2_H2C", false),
+		Listeners:                 src.getEnvList("LISTENERS"),
+		HTTP2MaxConcurrentStreams: src.getEnvInt("HTTP2_MAX_CONCURRENT_STREAMS", 250),
+		HTTPIdleTimeout:           src.getEnvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+
+		AdminIPAllowlist:      src.getEnvList("ADMIN_IP_ALLOWLIST"),
+		AdminIPDenylist:       src.getEnvList("ADMIN_IP_DENYLIST"),
+		AdminIPProtectMetrics: src.getEnvBool("ADMIN_IP_PROTECT_METRICS", false),
+		TrustedProxies:        src.getEnvList("TRUSTED_PROXIES"),
+
+		OpenAPIValidation: src.getEnvBool("OPENAPI_VALIDATE", env == EnvDevelopment),
+
+		DebugAddr:         src.getEnv("DEBUG_ADDR", ":9090"),
+		DebugRequireAdmin: src.getEnvBool("DEBUG_REQUIRE_ADMIN", true),
+
+		MetricsAddr:     src.getEnv("METRICS_ADDR", ""),
+		MetricsUsername: src.getEnv("METRICS_USERNAME", ""),
+		MetricsPassword: src.getEnv("METRICS_PASSWORD", ""),
+		MetricsToken:    src.getEnv("METRICS_TOKEN", ""),
+
+		ChaosEnabled: src.getEnvBool("CHAOS_ENABLED", false),
+
+		CaptureEnabled:       src.getEnvBool("CAPTURE_ENABLED", false),
+		CaptureSamplePercent: src.getEnvInt("CAPTURE_SAMPLE_PERCENT", 0),
+		CaptureMaxBodyBytes:  src.getEnvInt("CAPTURE_MAX_BODY_BYTES", 16384),
+		CaptureMaxEntries:    src.getEnvInt("CAPTURE_MAX_ENTRIES", 100),
+
+		LogLevel: src.getEnv("LOG_LEVEL", "info"),
+
+		AccessLogFormat:        src.getEnv("ACCESS_LOG_FORMAT", "none"),
+		AccessLogFile:          src.getEnv("ACCESS_LOG_FILE", ""),
+		AccessLogSamplePercent: src.getEnvInt("ACCESS_LOG_SAMPLE_PERCENT", 100),
+		AccessLogExclude:       src.getEnvListOr("ACCESS_LOG_EXCLUDE", []string{"/livez", "/healthz", "/readyz", "/metrics", "/internal/livez", "/internal/healthz", "/internal/readyz", "/internal/metrics"}),
+
+		MetricsNativeHistograms: src.getEnvBool("METRICS_NATIVE_HISTOGRAMS", false),
+
+		SentryDSN:         src.getEnv("SENTRY_DSN", ""),
+		SentryEnabled:     src.getEnvBool("SENTRY_ENABLED", true),
+		SentryEnvironment: src.getEnv("SENTRY_ENVIRONMENT", env),
+		SentryRelease:     src.getEnv("SENTRY_RELEASE", ""),
+
+		NotifySlackWebhookURL:  src.getEnv("NOTIFY_SLACK_WEBHOOK_URL", ""),
+		NotifySMTPAddr:         src.getEnv("NOTIFY_SMTP_ADDR", ""),
+		NotifySMTPUsername:     src.getEnv("NOTIFY_SMTP_USERNAME", ""),
+		NotifySMTPPassword:     src.getEnv("NOTIFY_SMTP_PASSWORD", ""),
+		NotifyEmailFrom:        src.getEnv("NOTIFY_EMAIL_FROM", ""),
+		NotifyEmailTo:          src.getEnvList("NOTIFY_EMAIL_TO"),
+		NotifyEvents:           src.getEnvBoolMap("NOTIFY_EVENTS"),
+		NotifyBacklogThreshold: src.getEnvInt("NOTIFY_BACKLOG_THRESHOLD", 1000),
+		NotifyCheckInterval:    src.getEnvDuration("NOTIFY_CHECK_INTERVAL", time.Minute),
+		NotifyMinInterval:      src.getEnvDuration("NOTIFY_MIN_INTERVAL", 15*time.Minute),
+		NotifyBurst:            src.getEnvInt("NOTIFY_BURST", 3),
+
+		MailDriver:         src.getEnv("MAIL_DRIVER", "none"),
+		MailFrom:           src.getEnv("MAIL_FROM", ""),
+		MailSMTPAddr:       src.getEnv("MAIL_SMTP_ADDR", ""),
+		MailSMTPUsername:   src.getEnv("MAIL_SMTP_USERNAME", ""),
+		MailSMTPPassword:   src.getEnv("MAIL_SMTP_PASSWORD", ""),
+		MailSendGridAPIKey: src.getEnv("MAIL_SENDGRID_API_KEY", ""),
+
+		EmbeddingBackend:    src.getEnv("EMBEDDING_BACKEND", "none"),
+		EmbeddingURL:        src.getEnv("EMBEDDING_URL", "https://api.openai.com/v1/embeddings"),
+		EmbeddingAPIKey:     src.getEnv("EMBEDDING_API_KEY", ""),
+		EmbeddingModel:      src.getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		EmbeddingDimensions: src.getEnvInt("EMBEDDING_DIMENSIONS", 1536),
+
+		LLMBackend:       src.getEnv("LLM_BACKEND", "none"),
+		LLMURL:           src.getEnv("LLM_URL", "https://api.openai.com/v1/chat/completions"),
+		LLMAPIKey:        src.getEnv("LLM_API_KEY", ""),
+		LLMModel:         src.getEnv("LLM_MODEL", "gpt-4o-mini"),
+		LLMMaxTokens:     src.getEnvInt("LLM_MAX_TOKENS", 500),
+		LLMMaxInputChars: src.getEnvInt("LLM_MAX_INPUT_CHARS", 48000),
+		LLMTimeout:       src.getEnvDuration("LLM_TIMEOUT", 60*time.Second),
+
+		SearchIndexBackend:   src.getEnv("SEARCH_INDEX_BACKEND", "none"),
+		SearchIndexURL:       src.getEnv("SEARCH_INDEX_URL", ""),
+		SearchIndexUsername:  src.getEnv("SEARCH_INDEX_USERNAME", ""),
+		SearchIndexPassword:  src.getEnv("SEARCH_INDEX_PASSWORD", ""),
+		SearchIndexPrefix:    src.getEnv("SEARCH_INDEX_PREFIX", "api-server"),
+		SearchIndexQueueSize: src.getEnvInt("SEARCH_INDEX_QUEUE_SIZE", 1000),
+		SearchIndexTimeout:   src.getEnvDuration("SEARCH_INDEX_TIMEOUT", 10*time.Second),
+
+		CanvasEnabled:      src.getEnvBool("CANVAS_ENABLED", false),
+		CanvasSyncInterval: src.getEnvDuration("CANVAS_SYNC_INTERVAL", 24*time.Hour),
+		CanvasPollInterval: src.getEnvDuration("CANVAS_POLL_INTERVAL", time.Minute),
+		CanvasSyncLease:    src.getEnvDuration("CANVAS_SYNC_LEASE", 30*time.Minute),
+		CanvasTimeout:      src.getEnvDuration("CANVAS_TIMEOUT", 30*time.Second),
+
+		AuthBackend:        src.getEnv("AUTH_BACKEND", "local"),
+		LDAPURL:            src.getEnv("LDAP_URL", ""),
+		LDAPStartTLS:       src.getEnvBool("LDAP_START_TLS", false),
+		LDAPCAFile:         src.getEnv("LDAP_CA_FILE", ""),
+		LDAPBindDN:         src.getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:   src.getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:         src.getEnv("LDAP_BASE_DN", ""),
+		LDAPUserFilter:     src.getEnv("LDAP_USER_FILTER", "(uid=%s)"),
+		LDAPIDAttribute:    src.getEnv("LDAP_ID_ATTRIBUTE", "entryUUID"),
+		LDAPGroupAttribute: src.getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		LDAPPoolSize:       src.getEnvInt("LDAP_POOL_SIZE", 4),
+		LDAPTimeout:        src.getEnvDuration("LDAP_TIMEOUT", 5*time.Second),
+		LDAPDefaultRole:    src.getEnv("LDAP_DEFAULT_ROLE", "student"),
+		LDAPRoleMappings:   src.getEnvStringMap("LDAP_ROLE_MAPPINGS"),
+
+		AuthTokenSecret:       src.getEnv("AUTH_TOKEN_SECRET", ""),
+		AuthTokenTTL:          src.getEnvDuration("AUTH_TOKEN_TTL", time.Hour),
+		ImpersonationTokenTTL: src.getEnvDuration("IMPERSONATION_TOKEN_TTL", 15*time.Minute),
+
+		MFAIssuer:            src.getEnv("MFA_ISSUER", "api-server"),
+		MFARequiredForAdmins: src.getEnvBool("MFA_REQUIRED_FOR_ADMINS", false),
+		MFAChallengeAttempts: src.getEnvInt("MFA_CHALLENGE_ATTEMPTS", 5),
+		MFAMaxFailedAttempts: src.getEnvInt("MFA_MAX_FAILED_ATTEMPTS", 10),
+		MFALockout:           src.getEnvDuration("MFA_LOCKOUT", 15*time.Minute),
+
+		OIDCGoogleClientID:     src.getEnv("OIDC_GOOGLE_CLIENT_ID", ""),
+		OIDCGoogleHostedDomain: src.getEnv("OIDC_GOOGLE_HOSTED_DOMAIN", ""),
+		OIDCGitHubClientID:     src.getEnv("OIDC_GITHUB_CLIENT_ID", ""),
+		OIDCGitHubClientSecret: src.getEnv("OIDC_GITHUB_CLIENT_SECRET", ""),
+		OIDCIssuer:             src.getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:           src.getEnv("OIDC_CLIENT_ID", ""),
+		OIDCDefaultRole:        src.getEnv("OIDC_DEFAULT_ROLE", "student"),
+		OIDCRoleMappings:       src.getEnvStringMap("OIDC_ROLE_MAPPINGS"),
+
+		SAMLIdPMetadata:  src.getEnv("SAML_IDP_METADATA", ""),
+		SAMLRootURL:      src.getEnv("SAML_ROOT_URL", ""),
+		SAMLEntityID:     src.getEnv("SAML_ENTITY_ID", ""),
+		SAMLCertFile:     src.getEnv("SAML_CERT_FILE", ""),
+		SAMLKeyFile:      src.getEnv("SAML_KEY_FILE", ""),
+		SAMLRedirectURLs: src.getEnvList("SAML_REDIRECT_URLS"),
+		SAMLDefaultRole:  src.getEnv("SAML_DEFAULT_ROLE", "student"),
+		SAMLRoleMappings: src.getEnvStringMap("SAML_ROLE_MAPPINGS"),
+
+		FeatureFlags:               src.getEnvBoolMap("FEATURE_FLAGS"),
+		FeatureFlagRefreshInterval: src.getEnvDuration("FEATURE_FLAG_REFRESH_INTERVAL", 30*time.Second),
+	}
+
+	cfg.SecretRefs = map[string]string{}
+	for _, name := range SecretSettings {
+		if value := cfg.Secret(name); IsSecretRef(value) {
+			cfg.SecretRefs[name] = value
+		}
 	}
+	return cfg
 }
 
-// getEnv retrieves an environment variable with a fallback value
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// SecretSettings are the settings that may reference a secret backend
+var SecretSettings = []string{"DB_PASSWORD", "KAFKA_SASL_USERNAME", "KAFKA_SASL_PASSWORD", "AUTH_TOKEN_SECRET", "OIDC_GITHUB_CLIENT_SECRET", "LDAP_BIND_PASSWORD", "SENTRY_DSN", "NOTIFY_SLACK_WEBHOOK_URL", "NOTIFY_SMTP_PASSWORD", "MAIL_SMTP_PASSWORD", "MAIL_SENDGRID_API_KEY", "EMBEDDING_API_KEY", "LLM_API_KEY", "SEARCH_INDEX_PASSWORD", "METRICS_PASSWORD", "METRICS_TOKEN"}
+
+// IsSecretRef reports whether value points at Vault or GCP Secret Manager
+// rather than holding the secret itself
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, "vault://") || strings.HasPrefix(value, "gcpsm://")
+}
+
+// Secret returns the current value of a secret setting
+func (c *Config) Secret(name string) string {
+	if field := c.secretField(name); field != nil {
+		return *field
+	}
+	return ""
+}
+
+// SetSecret replaces a secret setting, once its reference has been resolved
+func (c *Config) SetSecret(name, value string) {
+	if field := c.secretField(name); field != nil {
+		*field = value
+	}
+}
+
+func (c *Config) secretField(name string) *string {
+	switch name {
+	case "DB_PASSWORD":
+		return &c.DBPassword
+	case "KAFKA_SASL_USERNAME":
+		return &c.KafkaSASLUsername
+	case "KAFKA_SASL_PASSWORD":
+		return &c.KafkaSASLPassword
+	case "AUTH_TOKEN_SECRET":
+		return &c.AuthTokenSecret
+	case "OIDC_GITHUB_CLIENT_SECRET":
+		return &c.OIDCGitHubClientSecret
+	case "LDAP_BIND_PASSWORD":
+		return &c.LDAPBindPassword
+	case "SENTRY_DSN":
+		return &c.SentryDSN
+	case "NOTIFY_SLACK_WEBHOOK_URL":
+		return &c.NotifySlackWebhookURL
+	case "NOTIFY_SMTP_PASSWORD":
+		return &c.NotifySMTPPassword
+	case "MAIL_SMTP_PASSWORD":
+		return &c.MailSMTPPassword
+	case "MAIL_SENDGRID_API_KEY":
+		return &c.MailSendGridAPIKey
+	case "EMBEDDING_API_KEY":
+		return &c.EmbeddingAPIKey
+	case "LLM_API_KEY":
+		return &c.LLMAPIKey
+	case "SEARCH_INDEX_PASSWORD":
+		return &c.SearchIndexPassword
+	case "METRICS_PASSWORD":
+		return &c.MetricsPassword
+	case "METRICS_TOKEN":
+		return &c.MetricsToken
 	}
-	return fallback
+	return nil
+}
+
+// OIDCEnabled reports whether any OIDC login provider is configured
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCGoogleClientID != "" || c.OIDCGitHubClientID != "" || c.OIDCIssuer != ""
+}
+
+// SAMLEnabled reports whether SAML login is configured
+func (c *Config) SAMLEnabled() bool {
+	return c.SAMLIdPMetadata != ""
+}
+
+// Development reports whether ENV=development
+func (c *Config) Development() bool {
+	return c.Env == EnvDevelopment
+}
+
+// InMemory reports whether the server runs without external dependencies
+func (c *Config) InMemory() bool {
+	return c.Mode == ModeInMemory
 }
@@ -0,0 +1,126 @@
+// internal/config/listeners.go
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+// DefaultListenAddr is where the API listens when LISTENERS isn't set
+const DefaultListenAddr = ":3000"
+
+// Listener is one address the API is served on
+type Listener struct {
+	// Network is "tcp" or "unix"
+	Network string
+	// Address is host:port, or the socket's path
+	Address string
+	// TLSCertFile and TLSKeyFile serve HTTPS, with HTTP/2 through ALPN
+	TLSCertFile string
+	TLSKeyFile  string
+	// H2C serves cleartext HTTP/2 alongside HTTP/1.1
+	H2C bool
+	// SocketMode sets a Unix socket's permissions; zero leaves the umask's
+	SocketMode fs.FileMode
+}
+
+// String is the listener as it is written in LISTENERS, without its options
+func (l Listener) String() string {
+	switch {
+	case l.Network == "unix":
+		return "unix://" + l.Address
+	case l.TLSCertFile != "":
+		return "https://" + l.Address
+	}
+	return "http://" + l.Address
+}
+
+// APIListeners returns the parsed LISTENERS or, when it is empty, the one
+// listener on DefaultListenAddr that TLS_CERT_FILE, TLS_KEY_FILE and
+// HTTP2_H2C describe. Validation has already checked every entry parses.
+func (c *Config) APIListeners() []Listener {
+	if len(c.Listeners) == 0 {
+		return []Listener{{
+			Network:     "tcp",
+			Address:     DefaultListenAddr,
+			TLSCertFile: c.TLSCertFile,
+			TLSKeyFile:  c.TLSKeyFile,
+			H2C:         c.HTTP2Cleartext,
+		}}
+	}
+	listeners := make([]Listener, 0, len(c.Listeners))
+	for _, spec := range c.Listeners {
+		if l, err := ParseListener(spec); err == nil {
+			listeners = append(listeners, l)
+		}
+	}
+	return listeners
+}
+
+// ParseListener parses one LISTENERS entry:
+//
+//	http://host:port[?h2c=true]
+//	https://host:port?cert=<file>&key=<file>
+//	unix:///path/to/socket[?h2c=true&mode=0660]
+func ParseListener(spec string) (Listener, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return Listener{}, fmt.Errorf("%q is not a listener URL", spec)
+	}
+	query := u.Query()
+	var l Listener
+
+	for name := range query {
+		switch name {
+		case "h2c", "cert", "key", "mode":
+		default:
+			return Listener{}, fmt.Errorf("%q: unknown option %q", spec, name)
+		}
+	}
+	if raw := query.Get("h2c"); raw != "" {
+		if l.H2C, err = strconv.ParseBool(raw); err != nil {
+			return Listener{}, fmt.Errorf("%q: h2c must be true or false", spec)
+		}
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if _, _, err := net.SplitHostPort(u.Host); err != nil || u.Path != "" {
+			return Listener{}, fmt.Errorf("%q: must be %s://host:port", spec, u.Scheme)
+		}
+		l.Network, l.Address = "tcp", u.Host
+	case "unix":
+		if u.Host != "" || u.Path == "" {
+			return Listener{}, fmt.Errorf("%q: must be unix:///path/to/socket", spec)
+		}
+		l.Network, l.Address = "unix", u.Path
+		if raw := query.Get("mode"); raw != "" {
+			mode, err := strconv.ParseUint(raw, 8, 32)
+			if err != nil || mode > 0o777 {
+				return Listener{}, fmt.Errorf("%q: mode must be octal permissions such as 0660", spec)
+			}
+			l.SocketMode = fs.FileMode(mode)
+		}
+	default:
+		return Listener{}, fmt.Errorf("%q: scheme must be http, https or unix", spec)
+	}
+	if query.Has("mode") && u.Scheme != "unix" {
+		return Listener{}, fmt.Errorf("%q: mode only applies to unix sockets", spec)
+	}
+
+	if u.Scheme == "https" {
+		l.TLSCertFile, l.TLSKeyFile = query.Get("cert"), query.Get("key")
+		if l.TLSCertFile == "" || l.TLSKeyFile == "" {
+			return Listener{}, fmt.Errorf("%q: https needs cert and key", spec)
+		}
+		if l.H2C {
+			return Listener{}, fmt.Errorf("%q: h2c can't be used with https, which offers HTTP/2 over TLS", spec)
+		}
+	} else if query.Has("cert") || query.Has("key") {
+		return Listener{}, fmt.Errorf("%q: cert and key only apply to https", spec)
+	}
+	return l, nil
+}
@@ -0,0 +1,217 @@
+// internal/config/source.go
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// source looks settings up by environment variable name, falling back to
+// the config file, and collects every value that fails to parse. File keys
+// are the variable names in lower case, e.g. db_host or request_timeout_read.
+type source struct {
+	path   string
+	file   map[string]string
+	used   map[string]bool
+	errors []string
+}
+
+func newSource(path string) (*source, error) {
+	src := &source{path: path, file: map[string]string{}, used: map[string]bool{}}
+	if path == "" {
+		return src, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	for key, value := range raw {
+		src.file[strings.ToUpper(key)] = fileValue(value)
+	}
+	return src, nil
+}
+
+// fileValue renders a YAML value the way it would be written in the
+// environment. Mappings such as feature_flags become name=value lists.
+func fileValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case map[string]any:
+		entries := make([]string, 0, len(v))
+		for name, item := range v {
+			entries = append(entries, fmt.Sprintf("%s=%v", name, item))
+		}
+		sort.Strings(entries)
+		return strings.Join(entries, ",")
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (s *source) lookup(key string) (string, bool) {
+	s.used[key] = true
+	if value, exists := os.LookupEnv(key); exists {
+		return value, true
+	}
+	value, exists := s.file[key]
+	return value, exists
+}
+
+func (s *source) invalid(key, kind, value string) {
+	s.errors = append(s.errors, fmt.Sprintf("%s: invalid %s %q", key, kind, value))
+}
+
+// problems lists the values that failed to parse and any file keys that
+// don't name a known setting
+func (s *source) problems() []string {
+	problems := slices.Clone(s.errors)
+	var unknown []string
+	for key := range s.file {
+		if !s.used[key] {
+			unknown = append(unknown, fmt.Sprintf("%s: unknown setting %q", s.path, strings.ToLower(key)))
+		}
+	}
+	sort.Strings(unknown)
+	return append(problems, unknown...)
+}
+
+// getEnv retrieves a setting with a fallback value
+func (s *source) getEnv(key, fallback string) string {
+	if value, exists := s.lookup(key); exists {
+		return value
+	}
+	return fallback
+}
+
+// getEnvBool parses a boolean setting, falling back on parse errors
+func (s *source) getEnvBool(key string, fallback bool) bool {
+	if value, exists := s.lookup(key); exists {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			s.invalid(key, "boolean", value)
+			return fallback
+		}
+		return parsed
+	}
+	return fallback
+}
+
+// getEnvBoolMap parses a comma-separated list of name=bool pairs, skipping
+// malformed entries
+func (s *source) getEnvBoolMap(key string) map[string]bool {
+	values := map[string]bool{}
+	raw, _ := s.lookup(key)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(entry, "=")
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			s.invalid(key, "entry", entry)
+			continue
+		}
+		values[strings.TrimSpace(name)] = parsed
+	}
+	return values
+}
+
+// getEnvStringMap parses a comma-separated list of name=value pairs, split
+// at the last =, skipping malformed entries
+func (s *source) getEnvStringMap(key string) map[string]string {
+	values := map[string]string{}
+	raw, _ := s.lookup(key)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 || i == len(entry)-1 {
+			s.invalid(key, "entry", entry)
+			continue
+		}
+		values[strings.TrimSpace(entry[:i])] = strings.TrimSpace(entry[i+1:])
+	}
+	return values
+}
+
+// getEnvList splits a comma-separated setting, dropping empty entries
+func (s *source) getEnvList(key string) []string {
+	var values []string
+	raw, _ := s.lookup(key)
+	for _, entry := range strings.Split(raw, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			values = append(values, entry)
+		}
+	}
+	return values
+}
+
+// getEnvListOr is getEnvList with a fallback for when the setting is
+// absent; set to an empty value, it is an empty list
+func (s *source) getEnvListOr(key string, fallback []string) []string {
+	if _, exists := s.lookup(key); !exists {
+		return fallback
+	}
+	return s.getEnvList(key)
+}
+
+// getEnvInt parses an integer setting, falling back on parse errors
+func (s *source) getEnvInt(key string, fallback int) int {
+	if value, exists := s.lookup(key); exists {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			s.invalid(key, "integer", value)
+			return fallback
+		}
+		return parsed
+	}
+	return fallback
+}
+
+// getEnvDuration parses a duration setting such as "30s" or "24h"
+// getEnvDate parses a YYYY-MM-DD date as midnight UTC; the zero time means unset
+func (s *source) getEnvDate(key string) time.Time {
+	if value, exists := s.lookup(key); exists && value != "" {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			s.invalid(key, "date (YYYY-MM-DD)", value)
+			return time.Time{}
+		}
+		return parsed
+	}
+	return time.Time{}
+}
+
+func (s *source) getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, exists := s.lookup(key); exists {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			s.invalid(key, "duration", value)
+			return fallback
+		}
+		return parsed
+	}
+	return fallback
+}
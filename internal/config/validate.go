@@ -0,0 +1,478 @@
+// internal/config/validate.go
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values accepted by settings that select a backend, level or event. These
+// mirror the publisher, logging, notify, mailer, embedding, llm and searchindex packages,
+// which import config, and the upload formats in model.
+var (
+	publisherBackends = []string{"kafka", "noop", "memory"}
+	accessLogFormats  = []string{"none", "combined", "json"}
+	authBackends      = []string{"local", "ldap"}
+	logLevels         = []string{"debug", "info", "warn", "error"}
+	userRoles         = []string{"student", "admin", "instructor"}
+	notifyEvents      = []string{"upload_failed", "outbox_backlog", "dead_letter"}
+	mailDrivers       = []string{"none", "smtp", "sendgrid"}
+	embeddingBackends = []string{"none", "openai"}
+	llmBackends       = []string{"none", "openai"}
+	searchIndexes     = []string{"none", "elasticsearch"}
+	uploadTypes       = []string{
+		"application/pdf",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"application/zip",
+		"text/uri-list",
+	}
+)
+
+// validate checks settings that parsed but are out of range, inconsistent or
+// required and empty, and returns one message per problem
+func (c *Config) validate() []string {
+	var problems []string
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	required := func(key, value string) {
+		if value == "" {
+			fail("%s: required", key)
+		}
+	}
+	atLeast := func(key string, value, min int) {
+		if value < min {
+			fail("%s: must be at least %d, got %d", key, min, value)
+		}
+	}
+	positive := func(key string, value time.Duration) {
+		if value <= 0 {
+			fail("%s: must be a positive duration, got %s", key, value)
+		}
+	}
+	// Zero disables request timeouts and body limits
+	notNegative := func(key string, value int64) {
+		if value < 0 {
+			fail("%s: must not be negative, got %d", key, value)
+		}
+	}
+	notNegativeDuration := func(key string, value time.Duration) {
+		if value < 0 {
+			fail("%s: must not be negative, got %s", key, value)
+		}
+	}
+
+	if c.Mode != "" && c.Mode != ModeInMemory {
+		fail("MODE: must be empty or %q, got %q", ModeInMemory, c.Mode)
+	}
+	if !slices.Contains(logLevels, c.LogLevel) {
+		fail("LOG_LEVEL: must be one of %v, got %q", logLevels, c.LogLevel)
+	}
+	if !slices.Contains(accessLogFormats, c.AccessLogFormat) {
+		fail("ACCESS_LOG_FORMAT: must be one of %v, got %q", accessLogFormats, c.AccessLogFormat)
+	}
+	if c.AccessLogSamplePercent < 0 || c.AccessLogSamplePercent > 100 {
+		fail("ACCESS_LOG_SAMPLE_PERCENT: must be between 0 and 100, got %d", c.AccessLogSamplePercent)
+	}
+	if !slices.Contains(publisherBackends, c.PublisherBackend) {
+		fail("PUBLISHER_BACKEND: must be one of %v, got %q", publisherBackends, c.PublisherBackend)
+	}
+	required("GCS_BUCKET_NAME", c.GCSBucketName)
+
+	// Postgres and Kafka are replaced by fakes in memory mode
+	if !c.InMemory() {
+		required("DB_HOST", c.DBHost)
+		required("DB_USER", c.DBUser)
+		required("DB_NAME", c.DBName)
+		if port, err := strconv.Atoi(c.DBPort); err != nil || port < 1 || port > 65535 {
+			fail("DB_PORT: must be a port number, got %q", c.DBPort)
+		}
+		if c.PublisherBackend == "kafka" {
+			required("KAFKA_BROKER", c.KAFKA_BROKER)
+		}
+	}
+
+	atLeast("GCS_INIT_ATTEMPTS", c.GCSInitAttempts, 1)
+	positive("GCS_INIT_BACKOFF", c.GCSInitBackoff)
+	positive("GCS_INIT_COOLDOWN", c.GCSInitCooldown)
+	atLeast("STORAGE_WORKERS", c.StorageWorkers, 1)
+	positive("STORAGE_TASK_TIMEOUT", c.StorageTaskTimeout)
+
+	if c.LifecycleEnabled {
+		positive("LIFECYCLE_INTERVAL", c.LifecycleInterval)
+		atLeast("LIFECYCLE_ARCHIVE_AFTER_SEMESTERS", c.LifecycleArchiveAfter, 1)
+		atLeast("LIFECYCLE_COURSE_ARCHIVE_AFTER_SEMESTERS", c.LifecycleCourseArchiveAfter, 0)
+	}
+	positive("TRACE_PARTITION_INTERVAL", c.TracePartitionInterval)
+	// The longest semester is 5 months, so the next one always has a partition
+	atLeast("TRACE_PARTITION_MONTHS_AHEAD", c.TracePartitionMonthsAhead, 6)
+
+	if c.ExtractEnabled {
+		positive("EXTRACT_INTERVAL", c.ExtractInterval)
+		atLeast("EXTRACT_BATCH_SIZE", c.ExtractBatchSize, 1)
+	}
+	if u, err := url.Parse(c.ExtractOCRURL); c.ExtractOCRURL != "" && (err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "") {
+		fail("EXTRACT_OCR_URL: must be an absolute http(s) URL, got %q", c.ExtractOCRURL)
+	}
+	if c.ThumbnailEnabled {
+		positive("THUMBNAIL_INTERVAL", c.ThumbnailInterval)
+		atLeast("THUMBNAIL_BATCH_SIZE", c.ThumbnailBatchSize, 1)
+		if c.ThumbnailWidth < 16 || c.ThumbnailWidth > 2048 {
+			fail("THUMBNAIL_WIDTH: must be between 16 and 2048, got %d", c.ThumbnailWidth)
+		}
+		required("THUMBNAIL_PDFTOPPM_PATH", c.ThumbnailPdftoppmPath)
+	}
+	if c.AvatarMaxBytes <= 0 {
+		fail("AVATAR_MAX_BYTES: must be positive, got %d", c.AvatarMaxBytes)
+	}
+	if c.AvatarSize < 16 || c.AvatarSize > 1024 {
+		fail("AVATAR_SIZE: must be between 16 and 1024, got %d", c.AvatarSize)
+	}
+
+	atLeast("RETRY_MAX_ATTEMPTS", c.RetryMaxAttempts, 1)
+	positive("RETRY_BASE_DELAY", c.RetryBaseDelay)
+	positive("RETRY_MAX_DELAY", c.RetryMaxDelay)
+	if c.RetryMaxDelay < c.RetryBaseDelay {
+		fail("RETRY_MAX_DELAY: must not be less than RETRY_BASE_DELAY (%s)", c.RetryBaseDelay)
+	}
+	atLeast("BREAKER_FAILURE_THRESHOLD", c.BreakerFailureThreshold, 1)
+	positive("BREAKER_COOLDOWN", c.BreakerCooldown)
+
+	atLeast("STARTUP_RETRY_ATTEMPTS", c.StartupRetryAttempts, 1)
+	positive("STARTUP_RETRY_BASE_DELAY", c.StartupRetryBaseDelay)
+	positive("STARTUP_RETRY_MAX_DELAY", c.StartupRetryMaxDelay)
+	if c.StartupRetryMaxDelay < c.StartupRetryBaseDelay {
+		fail("STARTUP_RETRY_MAX_DELAY: must not be less than STARTUP_RETRY_BASE_DELAY (%s)", c.StartupRetryBaseDelay)
+	}
+
+	atLeast("DB_MAX_OPEN_CONNS", c.DBMaxOpenConns, 1)
+	atLeast("DB_MIN_CONNS", c.DBMinConns, 0)
+	if c.DBMinConns > c.DBMaxOpenConns {
+		fail("DB_MIN_CONNS: must not exceed DB_MAX_OPEN_CONNS (%d), got %d", c.DBMaxOpenConns, c.DBMinConns)
+	}
+	positive("DB_CONN_MAX_LIFETIME", c.DBConnMaxLifetime)
+	positive("DB_CONN_MAX_IDLE_TIME", c.DBConnMaxIdleTime)
+	notNegativeDuration("DB_SLOW_QUERY_THRESHOLD", c.DBSlowQueryThreshold)
+
+	positive("OUTBOX_POLL_INTERVAL", c.OutboxPollInterval)
+	atLeast("OUTBOX_BATCH_SIZE", c.OutboxBatchSize, 1)
+	atLeast("OUTBOX_MAX_ATTEMPTS", c.OutboxMaxAttempts, 1)
+
+	positive("DATA_JOB_POLL_INTERVAL", c.DataJobPollInterval)
+	positive("DATA_JOB_LEASE", c.DataJobLease)
+	atLeast("DATA_JOB_MAX_ATTEMPTS", c.DataJobMaxAttempts, 1)
+
+	notNegativeDuration("REQUEST_TIMEOUT_READ", c.RequestTimeoutRead)
+	notNegativeDuration("REQUEST_TIMEOUT_WRITE", c.RequestTimeoutWrite)
+	notNegativeDuration("REQUEST_TIMEOUT_UPLOAD", c.RequestTimeoutUpload)
+	notNegative("MAX_JSON_BODY_BYTES", c.MaxJSONBodyBytes)
+	notNegative("MAX_UPLOAD_BODY_BYTES", c.MaxUploadBodyBytes)
+	notNegative("COURSE_MAX_STORAGE_BYTES", c.CourseMaxStorageBytes)
+	notNegative("USER_MAX_UPLOADS_PER_DAY", c.UserMaxUploadsPerDay)
+	notNegative("USER_MAX_UPLOAD_BYTES_PER_DAY", c.UserMaxUploadBytesPerDay)
+	positive("UPLOAD_PROGRESS_RETENTION", c.UploadProgressRetention)
+	if len(c.UploadAllowedTypes) == 0 {
+		fail("UPLOAD_ALLOWED_TYPES: required")
+	}
+	for _, t := range c.UploadAllowedTypes {
+		if !slices.Contains(uploadTypes, t) {
+			fail("UPLOAD_ALLOWED_TYPES: must list types from %v, got %q", uploadTypes, t)
+		}
+	}
+	positive("SHARE_LINK_DEFAULT_TTL", c.ShareLinkDefaultTTL)
+	if c.ShareLinkMaxTTL < c.ShareLinkDefaultTTL {
+		fail("SHARE_LINK_MAX_TTL: must be at least SHARE_LINK_DEFAULT_TTL, got %s", c.ShareLinkMaxTTL)
+	}
+
+	if !c.APIV1DeprecationDate.IsZero() && !c.APIV1SunsetDate.IsZero() && !c.APIV1SunsetDate.After(c.APIV1DeprecationDate) {
+		fail("API_V1_SUNSET_DATE: must be after API_V1_DEPRECATION_DATE")
+	}
+	notNegativeDuration("CATALOG_CACHE_MAX_AGE", c.CatalogCacheMaxAge)
+	notNegativeDuration("CATALOG_CACHE_STALE_WHILE_REVALIDATE", c.CatalogCacheStaleWhileRevalidate)
+	atLeast("RATE_LIMIT_RPS", c.RateLimitRPS, 0)
+	if c.RateLimitRPS > 0 {
+		atLeast("RATE_LIMIT_BURST", c.RateLimitBurst, 1)
+	}
+	atLeast("CONCURRENCY_LIMIT", c.ConcurrencyLimit, 0)
+	atLeast("CONCURRENCY_LIMIT_UPLOAD", c.ConcurrencyLimitUpload, 0)
+	if c.ConcurrencyLimit > 0 || c.ConcurrencyLimitUpload > 0 {
+		atLeast("CONCURRENCY_QUEUE", c.ConcurrencyQueue, 0)
+		positive("CONCURRENCY_QUEUE_TIMEOUT", c.ConcurrencyQueueTimeout)
+	}
+
+	// A certificate is useless without its key, and the other way round
+	if c.TLSCertFile != "" || c.TLSKeyFile != "" {
+		required("TLS_CERT_FILE", c.TLSCertFile)
+		required("TLS_KEY_FILE", c.TLSKeyFile)
+	}
+	if c.HTTP2Cleartext && c.TLSCertFile != "" {
+		fail("HTTP2_H2C: must be off when TLS_CERT_FILE is set, which offers HTTP/2 over TLS")
+	}
+	if len(c.Listeners) > 0 && (c.TLSCertFile != "" || c.HTTP2Cleartext) {
+		fail("LISTENERS: replaces TLS_CERT_FILE, TLS_KEY_FILE and HTTP2_H2C, which must be unset; give each listener its own")
+	}
+	seen := map[string]bool{}
+	for _, spec := range c.Listeners {
+		l, err := ParseListener(spec)
+		if err != nil {
+			fail("LISTENERS: %v", err)
+			continue
+		}
+		if seen[l.Network+" "+l.Address] {
+			fail("LISTENERS: %s is listed twice", l)
+		}
+		seen[l.Network+" "+l.Address] = true
+	}
+	atLeast("HTTP2_MAX_CONCURRENT_STREAMS", c.HTTP2MaxConcurrentStreams, 1)
+	positive("HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout)
+
+	cidrs := func(key string, entries []string) {
+		for _, entry := range entries {
+			_, prefixErr := netip.ParsePrefix(entry)
+			if _, addrErr := netip.ParseAddr(entry); prefixErr != nil && addrErr != nil {
+				fail("%s: %q must be a CIDR or IP address", key, entry)
+			}
+		}
+	}
+	cidrs("ADMIN_IP_ALLOWLIST", c.AdminIPAllowlist)
+	cidrs("ADMIN_IP_DENYLIST", c.AdminIPDenylist)
+	cidrs("TRUSTED_PROXIES", c.TrustedProxies)
+	if c.AdminIPProtectMetrics && len(c.AdminIPAllowlist) == 0 && len(c.AdminIPDenylist) == 0 {
+		fail("ADMIN_IP_PROTECT_METRICS: needs ADMIN_IP_ALLOWLIST or ADMIN_IP_DENYLIST")
+	}
+
+	if c.DebugAddr != "" {
+		if _, _, err := net.SplitHostPort(c.DebugAddr); err != nil {
+			fail("DEBUG_ADDR: must be host:port or empty, got %q", c.DebugAddr)
+		}
+	}
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			fail("METRICS_ADDR: must be host:port or empty, got %q", c.MetricsAddr)
+		} else if c.MetricsAddr == c.DebugAddr {
+			fail("METRICS_ADDR: must differ from DEBUG_ADDR")
+		}
+	}
+	if c.MetricsUsername != "" || c.MetricsPassword != "" {
+		required("METRICS_USERNAME", c.MetricsUsername)
+		required("METRICS_PASSWORD", c.MetricsPassword)
+	}
+	if c.ChaosEnabled && !c.Development() {
+		fail("CHAOS_ENABLED: only allowed with ENV=%s", EnvDevelopment)
+	}
+	if c.CaptureSamplePercent < 0 || c.CaptureSamplePercent > 100 {
+		fail("CAPTURE_SAMPLE_PERCENT: must be between 0 and 100, got %d", c.CaptureSamplePercent)
+	}
+	atLeast("CAPTURE_MAX_BODY_BYTES", c.CaptureMaxBodyBytes, 1)
+	atLeast("CAPTURE_MAX_ENTRIES", c.CaptureMaxEntries, 1)
+	positive("FEATURE_FLAG_REFRESH_INTERVAL", c.FeatureFlagRefreshInterval)
+
+	// The webhook is resolved from a secret after validation
+	if c.NotifySlackWebhookURL != "" && !IsSecretRef(c.NotifySlackWebhookURL) {
+		if u, err := url.Parse(c.NotifySlackWebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			fail("NOTIFY_SLACK_WEBHOOK_URL: must be an https URL")
+		}
+	}
+	if len(c.NotifyEmailTo) > 0 {
+		required("NOTIFY_EMAIL_FROM", c.NotifyEmailFrom)
+		if _, _, err := net.SplitHostPort(c.NotifySMTPAddr); err != nil {
+			fail("NOTIFY_SMTP_ADDR: must be host:port, got %q", c.NotifySMTPAddr)
+		}
+		if c.NotifySMTPUsername != "" {
+			required("NOTIFY_SMTP_PASSWORD", c.NotifySMTPPassword)
+		}
+	}
+	for event := range c.NotifyEvents {
+		if !slices.Contains(notifyEvents, event) {
+			fail("NOTIFY_EVENTS: event must be one of %v, got %q", notifyEvents, event)
+		}
+	}
+	atLeast("NOTIFY_BACKLOG_THRESHOLD", c.NotifyBacklogThreshold, 0)
+	positive("NOTIFY_CHECK_INTERVAL", c.NotifyCheckInterval)
+	positive("NOTIFY_MIN_INTERVAL", c.NotifyMinInterval)
+	atLeast("NOTIFY_BURST", c.NotifyBurst, 1)
+
+	if !slices.Contains(mailDrivers, c.MailDriver) {
+		fail("MAIL_DRIVER: must be one of %v, got %q", mailDrivers, c.MailDriver)
+	}
+	if c.MailDriver != "none" {
+		required("MAIL_FROM", c.MailFrom)
+	}
+	if c.MailDriver == "smtp" {
+		if _, _, err := net.SplitHostPort(c.MailSMTPAddr); err != nil {
+			fail("MAIL_SMTP_ADDR: must be host:port, got %q", c.MailSMTPAddr)
+		}
+		if c.MailSMTPUsername != "" {
+			required("MAIL_SMTP_PASSWORD", c.MailSMTPPassword)
+		}
+	}
+	if c.MailDriver == "sendgrid" {
+		required("MAIL_SENDGRID_API_KEY", c.MailSendGridAPIKey)
+	}
+
+	if !slices.Contains(embeddingBackends, c.EmbeddingBackend) {
+		fail("EMBEDDING_BACKEND: must be one of %v, got %q", embeddingBackends, c.EmbeddingBackend)
+	}
+	if c.EmbeddingBackend == "openai" {
+		if u, err := url.Parse(c.EmbeddingURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			fail("EMBEDDING_URL: must be an absolute http(s) URL, got %q", c.EmbeddingURL)
+		}
+		required("EMBEDDING_MODEL", c.EmbeddingModel)
+	}
+	// pgvector can index at most 2000 dimensions
+	if c.EmbeddingDimensions < 1 || c.EmbeddingDimensions > 2000 {
+		fail("EMBEDDING_DIMENSIONS: must be between 1 and 2000, got %d", c.EmbeddingDimensions)
+	}
+
+	if !slices.Contains(llmBackends, c.LLMBackend) {
+		fail("LLM_BACKEND: must be one of %v, got %q", llmBackends, c.LLMBackend)
+	}
+	if c.LLMBackend == "openai" {
+		if u, err := url.Parse(c.LLMURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			fail("LLM_URL: must be an absolute http(s) URL, got %q", c.LLMURL)
+		}
+		required("LLM_MODEL", c.LLMModel)
+		atLeast("LLM_MAX_TOKENS", c.LLMMaxTokens, 1)
+		atLeast("LLM_MAX_INPUT_CHARS", c.LLMMaxInputChars, 1000)
+		positive("LLM_TIMEOUT", c.LLMTimeout)
+		// Summaries are requested under the upload deadline
+		if c.LLMTimeout > c.RequestTimeoutUpload {
+			fail("LLM_TIMEOUT: must not exceed REQUEST_TIMEOUT_UPLOAD (%s), got %s", c.RequestTimeoutUpload, c.LLMTimeout)
+		}
+	}
+
+	if !slices.Contains(searchIndexes, c.SearchIndexBackend) {
+		fail("SEARCH_INDEX_BACKEND: must be one of %v, got %q", searchIndexes, c.SearchIndexBackend)
+	}
+	if c.SearchIndexBackend == "elasticsearch" {
+		if u, err := url.Parse(c.SearchIndexURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			fail("SEARCH_INDEX_URL: must be an absolute http(s) URL, got %q", c.SearchIndexURL)
+		}
+		// Index names must be lowercase and can't start with - _ or +
+		if c.SearchIndexPrefix == "" || c.SearchIndexPrefix != strings.ToLower(c.SearchIndexPrefix) || strings.ContainsAny(c.SearchIndexPrefix[:1], "-_+") {
+			fail("SEARCH_INDEX_PREFIX: must be lowercase and start with a letter or digit, got %q", c.SearchIndexPrefix)
+		}
+		atLeast("SEARCH_INDEX_QUEUE_SIZE", c.SearchIndexQueueSize, 1)
+		positive("SEARCH_INDEX_TIMEOUT", c.SearchIndexTimeout)
+	}
+
+	if c.CanvasEnabled {
+		positive("CANVAS_SYNC_INTERVAL", c.CanvasSyncInterval)
+		positive("CANVAS_POLL_INTERVAL", c.CanvasPollInterval)
+		positive("CANVAS_SYNC_LEASE", c.CanvasSyncLease)
+		positive("CANVAS_TIMEOUT", c.CanvasTimeout)
+		// A sync makes several calls per course, so a lease no longer than
+		// one call would let another replica claim it mid-sync
+		if c.CanvasSyncLease <= c.CanvasTimeout {
+			fail("CANVAS_SYNC_LEASE: must be longer than CANVAS_TIMEOUT (%s), got %s", c.CanvasTimeout, c.CanvasSyncLease)
+		}
+	}
+
+	// Tokens are HMAC-signed, so a short secret could be brute-forced
+	if c.AuthTokenSecret != "" && len(c.AuthTokenSecret) < 32 {
+		fail("AUTH_TOKEN_SECRET: must be at least 32 bytes")
+	}
+	positive("AUTH_TOKEN_TTL", c.AuthTokenTTL)
+	positive("IMPERSONATION_TOKEN_TTL", c.ImpersonationTokenTTL)
+	if c.OIDCEnabled() {
+		required("AUTH_TOKEN_SECRET (for OIDC login)", c.AuthTokenSecret)
+	}
+	// Admins with MFA can only use bearer tokens
+	if c.MFARequiredForAdmins {
+		required("AUTH_TOKEN_SECRET (for MFA_REQUIRED_FOR_ADMINS)", c.AuthTokenSecret)
+	}
+	required("MFA_ISSUER", c.MFAIssuer)
+	atLeast("MFA_CHALLENGE_ATTEMPTS", c.MFAChallengeAttempts, 1)
+	atLeast("MFA_MAX_FAILED_ATTEMPTS", c.MFAMaxFailedAttempts, 1)
+	positive("MFA_LOCKOUT", c.MFALockout)
+	if c.OIDCGitHubClientID != "" {
+		required("OIDC_GITHUB_CLIENT_SECRET", c.OIDCGitHubClientSecret)
+	}
+	if c.OIDCIssuer != "" {
+		required("OIDC_CLIENT_ID", c.OIDCClientID)
+	}
+	roleMappings := func(key, defaultKey, defaultRole string, mappings map[string]string) {
+		if !slices.Contains(userRoles, defaultRole) {
+			fail("%s: must be one of %v, got %q", defaultKey, userRoles, defaultRole)
+		}
+		for claim, role := range mappings {
+			if i := strings.LastIndex(claim, ":"); i <= 0 || i == len(claim)-1 {
+				fail("%s: %q must look like claim:value=role", key, claim+"="+role)
+			}
+			if !slices.Contains(userRoles, role) {
+				fail("%s: role for %q must be one of %v, got %q", key, claim, userRoles, role)
+			}
+		}
+	}
+	roleMappings("OIDC_ROLE_MAPPINGS", "OIDC_DEFAULT_ROLE", c.OIDCDefaultRole, c.OIDCRoleMappings)
+
+	if c.SAMLEnabled() {
+		required("AUTH_TOKEN_SECRET (for SAML login)", c.AuthTokenSecret)
+		required("SAML_ROOT_URL", c.SAMLRootURL)
+		required("SAML_CERT_FILE", c.SAMLCertFile)
+		required("SAML_KEY_FILE", c.SAMLKeyFile)
+	}
+	if c.SAMLRootURL != "" {
+		if u, err := url.Parse(c.SAMLRootURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			fail("SAML_ROOT_URL: must be an absolute http(s) URL, got %q", c.SAMLRootURL)
+		}
+	}
+	for _, redirect := range c.SAMLRedirectURLs {
+		if u, err := url.Parse(redirect); err != nil || u.Scheme == "" || u.Host == "" {
+			fail("SAML_REDIRECT_URLS: %q must be an absolute URL", redirect)
+		}
+	}
+	roleMappings("SAML_ROLE_MAPPINGS", "SAML_DEFAULT_ROLE", c.SAMLDefaultRole, c.SAMLRoleMappings)
+
+	if !slices.Contains(authBackends, c.AuthBackend) {
+		fail("AUTH_BACKEND: must be one of %v, got %q", authBackends, c.AuthBackend)
+	}
+	if c.AuthBackend == "ldap" {
+		required("LDAP_URL", c.LDAPURL)
+		required("LDAP_BASE_DN", c.LDAPBaseDN)
+		if u, err := url.Parse(c.LDAPURL); c.LDAPURL != "" && (err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps")) {
+			fail("LDAP_URL: must be an ldap:// or ldaps:// URL, got %q", c.LDAPURL)
+		}
+		if strings.Count(c.LDAPUserFilter, "%s") != 1 {
+			fail("LDAP_USER_FILTER: must contain %%s once, got %q", c.LDAPUserFilter)
+		}
+		if c.LDAPBindDN != "" {
+			required("LDAP_BIND_PASSWORD", c.LDAPBindPassword)
+		}
+		atLeast("LDAP_POOL_SIZE", c.LDAPPoolSize, 1)
+		positive("LDAP_TIMEOUT", c.LDAPTimeout)
+	}
+	roleMappings("LDAP_ROLE_MAPPINGS", "LDAP_DEFAULT_ROLE", c.LDAPDefaultRole, c.LDAPRoleMappings)
+
+	if c.KafkaSASLUsername != "" {
+		required("KAFKA_SASL_PASSWORD", c.KafkaSASLPassword)
+	}
+	for _, name := range SecretSettings {
+		ref, ok := c.SecretRefs[name]
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(ref, "vault://") {
+			required("VAULT_ADDR (for "+name+")", c.VaultAddr)
+			required("VAULT_TOKEN (for "+name+")", c.VaultToken)
+			if path, key, _ := strings.Cut(strings.TrimPrefix(ref, "vault://"), "#"); path == "" || key == "" {
+				fail("%s: Vault reference must look like vault://<path>#<key>", name)
+			}
+		}
+		if strings.HasPrefix(ref, "gcpsm://") && !strings.Contains(ref, "/secrets/") {
+			fail("%s: Secret Manager reference must look like gcpsm://projects/<project>/secrets/<name>", name)
+		}
+	}
+	if len(c.SecretRefs) > 0 {
+		positive("SECRETS_REFRESH_INTERVAL", c.SecretsRefreshInterval)
+	}
+
+	return problems
+}
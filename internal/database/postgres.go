@@ -3,13 +3,27 @@ package database
 
 import (
 	"api-server/internal/config"
-	"database/sql"
+	"context"
 	"fmt"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewPostgresConnection(cfg *config.Config) (*sql.DB, error) {
+func NewPostgresConnection(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	return newPool(ctx, cfg, nil, nil)
+}
+
+// NewTracedConnection connects like NewPostgresConnection, timing every
+// statement with tracer
+func NewTracedConnection(ctx context.Context, cfg *config.Config, tracer *QueryTracer) (*pgxpool.Pool, error) {
+	return newPool(ctx, cfg, nil, tracer)
+}
+
+// newPool asks password, when set, for the password each time it opens a
+// connection, so a rotated secret is used by new connections. tracer may be
+// nil.
+func newPool(ctx context.Context, cfg *config.Config, password func() string, tracer *QueryTracer) (*pgxpool.Pool, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.DBHost,
 		cfg.DBPort,
@@ -18,14 +32,39 @@ func NewPostgresConnection(cfg *config.Config) (*sql.DB, error) {
 		cfg.DBName,
 	)
 
-	db, err := sql.Open("postgres", dsn)
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tune the connection pool
+	poolConfig.MaxConns = int32(cfg.DBMaxOpenConns)
+	poolConfig.MinConns = int32(cfg.DBMinConns)
+	poolConfig.MaxConnLifetime = cfg.DBConnMaxLifetime
+	poolConfig.MaxConnIdleTime = cfg.DBConnMaxIdleTime
+
+	if password != nil {
+		poolConfig.BeforeConnect = func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+			connConfig.Password = password()
+			return nil
+		}
+	}
+
+	// Prepare and cache statements per connection so hot queries skip parsing
+	poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	if tracer != nil {
+		poolConfig.ConnConfig.Tracer = tracer
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = db.Ping(); err != nil {
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, err
 	}
 
-	return db, nil
+	return pool, nil
 }
@@ -0,0 +1,207 @@
+// internal/database/rotate.go
+package database
+
+import (
+	"api-server/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	pgInvalidPassword      = "28P01"
+	pgInvalidAuthorization = "28000"
+)
+
+// rotateBackoff stops a rejected password from re-fetching the secret on
+// every request while the new one has not been published yet
+const rotateBackoff = 5 * time.Second
+
+// Pool is what the server needs from a connection pool, satisfied by both
+// *pgxpool.Pool and *RotatingPool
+type Pool interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+	Stat() *pgxpool.Stat
+	Close()
+}
+
+// PasswordSource supplies the database password from a secrets backend
+type PasswordSource interface {
+	// Value returns the latest known password
+	Value() string
+	// Refetch reads the password from the backend now
+	Refetch(ctx context.Context) (string, error)
+}
+
+// RotatingPool is a connection pool that survives database password
+// rotation. New connections use the source's latest password, and when
+// Postgres rejects it the password is re-fetched and the pool rebuilt, then
+// the call is retried once on the new pool.
+type RotatingPool struct {
+	cfg    *config.Config
+	source PasswordSource
+	tracer *QueryTracer
+
+	mu   sync.RWMutex
+	pool *pgxpool.Pool
+
+	rotateMu    sync.Mutex
+	lastAttempt time.Time
+}
+
+// NewRotatingPool connects with the source's current password; tracer, if
+// not nil, times every statement on this pool and the ones rebuilt from it
+func NewRotatingPool(ctx context.Context, cfg *config.Config, source PasswordSource, tracer *QueryTracer) (*RotatingPool, error) {
+	p := &RotatingPool{cfg: cfg, source: source, tracer: tracer}
+	pool, err := newPool(ctx, cfg, source.Value, tracer)
+	if isAuthError(err) {
+		pool, err = p.rebuild(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.pool = pool
+	return p, nil
+}
+
+func (p *RotatingPool) current() *pgxpool.Pool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pool
+}
+
+// rotate replaces failed with a pool built from a re-fetched password. If
+// another caller already replaced it, the newer pool is returned as is.
+func (p *RotatingPool) rotate(ctx context.Context, failed *pgxpool.Pool) (*pgxpool.Pool, error) {
+	p.rotateMu.Lock()
+	defer p.rotateMu.Unlock()
+
+	if pool := p.current(); pool != failed {
+		return pool, nil
+	}
+	if time.Since(p.lastAttempt) < rotateBackoff {
+		return nil, errors.New("database credentials were re-fetched recently")
+	}
+
+	pool, err := p.rebuild(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.pool = pool
+	p.mu.Unlock()
+
+	// Close waits for acquired connections, so in-flight queries on the old
+	// pool finish normally
+	go failed.Close()
+	log.Println("Rebuilt database connection pool after an authentication failure")
+	return pool, nil
+}
+
+func (p *RotatingPool) rebuild(ctx context.Context) (*pgxpool.Pool, error) {
+	p.lastAttempt = time.Now()
+	if _, err := p.source.Refetch(ctx); err != nil {
+		return nil, fmt.Errorf("failed to re-fetch database password: %w", err)
+	}
+	return newPool(ctx, p.cfg, p.source.Value, p.tracer)
+}
+
+// retry runs fn on the current pool, and once more on a rebuilt pool if the
+// first attempt failed authentication. Authentication fails before a query
+// is sent, so the retry never runs a statement twice.
+func (p *RotatingPool) retry(ctx context.Context, fn func(pool *pgxpool.Pool) error) error {
+	pool := p.current()
+	err := fn(pool)
+	if !isAuthError(err) {
+		return err
+	}
+	rotated, rotateErr := p.rotate(ctx, pool)
+	if rotateErr != nil {
+		log.Printf("Database authentication failed and credentials could not be rotated: %v", rotateErr)
+		return err
+	}
+	return fn(rotated)
+}
+
+func (p *RotatingPool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	var tag pgconn.CommandTag
+	err := p.retry(ctx, func(pool *pgxpool.Pool) error {
+		var err error
+		tag, err = pool.Exec(ctx, sql, args...)
+		return err
+	})
+	return tag, err
+}
+
+func (p *RotatingPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	var rows pgx.Rows
+	err := p.retry(ctx, func(pool *pgxpool.Pool) error {
+		var err error
+		rows, err = pool.Query(ctx, sql, args...)
+		return err
+	})
+	return rows, err
+}
+
+// QueryRow defers its error to Scan, so the retry happens there
+func (p *RotatingPool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &rotatingRow{p: p, ctx: ctx, sql: sql, args: args}
+}
+
+func (p *RotatingPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	var tx pgx.Tx
+	err := p.retry(ctx, func(pool *pgxpool.Pool) error {
+		var err error
+		tx, err = pool.Begin(ctx)
+		return err
+	})
+	return tx, err
+}
+
+func (p *RotatingPool) Ping(ctx context.Context) error {
+	return p.retry(ctx, func(pool *pgxpool.Pool) error {
+		return pool.Ping(ctx)
+	})
+}
+
+// Stat reports the current pool; counters restart when it is rebuilt
+func (p *RotatingPool) Stat() *pgxpool.Stat {
+	return p.current().Stat()
+}
+
+func (p *RotatingPool) Close() {
+	p.current().Close()
+}
+
+type rotatingRow struct {
+	p    *RotatingPool
+	ctx  context.Context
+	sql  string
+	args []any
+}
+
+func (r *rotatingRow) Scan(dest ...any) error {
+	return r.p.retry(r.ctx, func(pool *pgxpool.Pool) error {
+		return pool.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	})
+}
+
+// isAuthError reports whether err is Postgres rejecting the credentials
+func isAuthError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == pgInvalidPassword || pgErr.Code == pgInvalidAuthorization
+}
@@ -0,0 +1,156 @@
+// internal/database/tracer.go
+package database
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// modelPackage is where the queries are written, so the function of it that
+// ran a query names the statement
+const modelPackage = "api-server/internal/model."
+
+// QueryTracer times every statement run on the pool, exporting its duration
+// and the rows it returned or changed by statement, and logs statements
+// slower than the threshold. A statement is named after the model function
+// that ran it, e.g. model.GetTracesByCourseID.
+type QueryTracer struct {
+	threshold time.Duration
+
+	duration *prometheus.HistogramVec
+	rows     *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	statement string
+	sql       string
+	start     time.Time
+}
+
+// NewQueryTracer logs statements taking at least threshold; 0 logs none
+func NewQueryTracer(threshold time.Duration) *QueryTracer {
+	labels := []string{"statement"}
+	return &QueryTracer{
+		threshold: threshold,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Duration of database statements.",
+			Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		}, labels),
+		rows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_query_rows_total",
+			Help: "Rows database statements returned or changed.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_query_errors_total",
+			Help: "Database statements that failed.",
+		}, labels),
+	}
+}
+
+func (t *QueryTracer) Describe(ch chan<- *prometheus.Desc) {
+	t.duration.Describe(ch)
+	t.rows.Describe(ch)
+	t.errors.Describe(ch)
+}
+
+func (t *QueryTracer) Collect(ch chan<- prometheus.Metric) {
+	t.duration.Collect(ch)
+	t.rows.Collect(ch)
+	t.errors.Collect(ch)
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, &queryStart{
+		statement: statementName(),
+		sql:       data.SQL,
+		start:     time.Now(),
+	})
+}
+
+// TraceQueryEnd is called once a statement's rows are read, so its duration
+// includes reading them
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	q, ok := ctx.Value(queryStartKey{}).(*queryStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(q.start)
+	rows := data.CommandTag.RowsAffected()
+
+	t.duration.WithLabelValues(q.statement).Observe(elapsed.Seconds())
+	t.rows.WithLabelValues(q.statement).Add(float64(rows))
+	if data.Err != nil {
+		t.errors.WithLabelValues(q.statement).Inc()
+	}
+
+	attrs := []any{"statement", q.statement, "duration", elapsed, "rows", rows}
+	if data.Err != nil {
+		attrs = append(attrs, "error", data.Err)
+	}
+	if t.threshold > 0 && elapsed >= t.threshold {
+		slog.WarnContext(ctx, "Slow query", append(attrs, "sql", strings.Join(strings.Fields(q.sql), " "))...)
+		return
+	}
+	slog.DebugContext(ctx, "Query", attrs...)
+}
+
+// statementName names a statement after the outermost model function of the
+// call that is running it, so a helper such as the shared list query is
+// counted under the function calling it. Statements run outside the model
+// package are named after their caller.
+func statementName() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	var name, caller string
+	for {
+		frame, more := frames.Next()
+		fn := frame.Function
+		switch {
+		case strings.HasPrefix(fn, modelPackage):
+			name = fn
+		case name != "":
+			return shortName(name)
+		case caller == "" && !strings.HasPrefix(fn, "github.com/jackc/") && !strings.HasPrefix(fn, "api-server/internal/database."):
+			caller = fn
+		}
+		if !more {
+			break
+		}
+	}
+	if name == "" {
+		name = caller
+	}
+	if name == "" {
+		return "unknown"
+	}
+	return shortName(name)
+}
+
+// shortName drops the import path and any closure suffix from a function
+// name, e.g. api-server/internal/model.list[...].func1 becomes model.list
+func shortName(fn string) string {
+	fn = fn[strings.LastIndex(fn, "/")+1:]
+	if i := strings.Index(fn, "["); i >= 0 {
+		fn = fn[:i]
+	}
+	if pkg, rest, ok := strings.Cut(fn, "."); ok {
+		for _, part := range strings.Split(rest, ".") {
+			if strings.HasPrefix(part, "func") || part == "" {
+				break
+			}
+			pkg += "." + part
+		}
+		fn = pkg
+	}
+	return fn
+}
@@ -0,0 +1,28 @@
+// internal/embedding/embedding.go
+package embedding
+
+import (
+	"api-server/internal/config"
+	"context"
+	"time"
+)
+
+// requestTimeout bounds each call to the embedding API
+const requestTimeout = 15 * time.Second
+
+// Embedder turns text into a vector in the same space the processing
+// pipeline writes trace embeddings in
+type Embedder interface {
+	Name() string
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// New builds the Embedder selected by EMBEDDING_BACKEND. With "none" it
+// returns nil and similarity search is unavailable.
+func New(cfg *config.Config) Embedder {
+	switch cfg.EmbeddingBackend {
+	case "openai":
+		return NewOpenAI(cfg.EmbeddingURL, cfg.EmbeddingAPIKey, cfg.EmbeddingModel, cfg.EmbeddingDimensions)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// internal/embedding/openai.go
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// OpenAI embeds text through an OpenAI-compatible /embeddings endpoint
+type OpenAI struct {
+	url        string
+	apiKey     string
+	model      string
+	dimensions int
+	client     *http.Client
+}
+
+func NewOpenAI(url, apiKey, model string, dimensions int) *OpenAI {
+	return &OpenAI{
+		url:        url,
+		apiKey:     apiKey,
+		model:      model,
+		dimensions: dimensions,
+		client:     &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (o *OpenAI) Name() string {
+	return "openai"
+}
+
+type openAIRequest struct {
+	Input      string `json:"input"`
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions,omitempty"`
+}
+
+type openAIResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (o *OpenAI) Embed(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(openAIRequest{Input: text, Model: o.model, Dimensions: o.dimensions})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embedding API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embedding API returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+
+	var result openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding API response: %w", err)
+	}
+	if len(result.Data) != 1 {
+		return nil, fmt.Errorf("embedding API returned %d embeddings, want 1", len(result.Data))
+	}
+	if got := len(result.Data[0].Embedding); got != o.dimensions {
+		return nil, fmt.Errorf("embedding API returned %d dimensions, want %d", got, o.dimensions)
+	}
+	return result.Data[0].Embedding, nil
+}
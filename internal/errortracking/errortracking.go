@@ -0,0 +1,109 @@
+// internal/errortracking/errortracking.go
+package errortracking
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/logging"
+	"api-server/internal/tenant"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Init sends errors to Sentry, or any service that accepts its protocol,
+// when SENTRY_DSN is set and SENTRY_ENABLED is not false. Until then the
+// Capture functions do nothing.
+func Init(cfg *config.Config) error {
+	if cfg.SentryDSN == "" || !cfg.SentryEnabled {
+		return nil
+	}
+	release := cfg.SentryRelease
+	if release == "" {
+		release = buildRelease()
+	}
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         cfg.SentryDSN,
+		Environment: cfg.SentryEnvironment,
+		Release:     release,
+		BeforeSend:  redact,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize Sentry: %w", err)
+	}
+	log.Printf("Error tracking enabled, environment %q, release %q", cfg.SentryEnvironment, release)
+	return nil
+}
+
+// Flush waits up to timeout for queued events to be sent
+func Flush(timeout time.Duration) {
+	sentry.Flush(timeout)
+}
+
+// Capture reports an error from background work, tagged with the
+// component it came from and any other tags
+func Capture(err error, component string, tags map[string]string) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("component", component)
+		scope.SetTags(tags)
+		sentry.CaptureException(err)
+	})
+}
+
+// CaptureRequest reports an error that failed r with a server error. The
+// request's method, URL and headers are attached, without credentials.
+func CaptureRequest(r *http.Request, err error, tags map[string]string) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetRequest(r)
+		scope.SetTag("component", "http")
+		scope.SetTags(tags)
+		sentry.CaptureException(err)
+	})
+}
+
+// CapturePanic reports a panic recovered while serving r
+func CapturePanic(r *http.Request, p any, tags map[string]string) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetRequest(r)
+		scope.SetTag("component", "http")
+		scope.SetTags(tags)
+		sentry.CurrentHub().Recover(p)
+	})
+}
+
+// redact scrubs events the same way as log output, since error messages
+// and query strings can carry emails and credentials
+func redact(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+	event.Message = logging.Redact(event.Message)
+	for i := range event.Exception {
+		event.Exception[i].Value = logging.Redact(event.Exception[i].Value)
+	}
+	if event.Request != nil {
+		// Sentry already drops Authorization and cookies
+		delete(event.Request.Headers, http.CanonicalHeaderKey(tenant.APIKeyHeader))
+		event.Request.URL = logging.Redact(event.Request.URL)
+		event.Request.QueryString = logging.Redact(event.Request.QueryString)
+	}
+	return event
+}
+
+// buildRelease names the release after the VCS revision the binary was
+// built from, or its module version
+func buildRelease() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	if info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+// internal/extract/extract.go
+package extract
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/errortracking"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// maxErrorLen fits the trace_contents error column
+const maxErrorLen = 500
+
+// Extractor pulls the text out of uploaded PDFs in the background, so
+// keyword search can find traces by what they say. PDFs without a text
+// layer go to the OCR hook when one is configured.
+type Extractor struct {
+	repo      repository.Repository
+	storage   storage.Storage
+	ocr       OCR
+	interval  time.Duration
+	batchSize int
+	maxBytes  int64
+}
+
+// NewExtractor builds an Extractor from the EXTRACT_* settings, with an
+// HTTP OCR hook when EXTRACT_OCR_URL is set
+func NewExtractor(repo repository.Repository, store storage.Storage, cfg *config.Config) *Extractor {
+	var ocr OCR
+	if cfg.ExtractOCRURL != "" {
+		ocr = NewHTTPOCR(cfg.ExtractOCRURL)
+	}
+	return NewExtractorWithOCR(repo, store, ocr, cfg)
+}
+
+// NewExtractorWithOCR builds an Extractor that falls back to ocr, which may
+// be nil, for PDFs without text
+func NewExtractorWithOCR(repo repository.Repository, store storage.Storage, ocr OCR, cfg *config.Config) *Extractor {
+	return &Extractor{
+		repo:      repo,
+		storage:   store,
+		ocr:       ocr,
+		interval:  cfg.ExtractInterval,
+		batchSize: cfg.ExtractBatchSize,
+		maxBytes:  cfg.MaxUploadBodyBytes,
+	}
+}
+
+// Run extracts pending traces on every interval until ctx is cancelled
+func (e *Extractor) Run(ctx context.Context) {
+	log.Printf("Trace text extractor started, interval %s", e.interval)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		extracted, err := e.ExtractPending(ctx)
+		if err != nil {
+			log.Printf("Trace text extraction failed: %v", err)
+			errortracking.Capture(err, "extract", nil)
+		} else if extracted > 0 {
+			log.Printf("Extracted text from %d traces", extracted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExtractPending extracts the text of up to one batch of traces that have
+// none yet and returns how many were stored. Traces whose PDF can't be read
+// right now are left for the next pass.
+func (e *Extractor) ExtractPending(ctx context.Context) (int, error) {
+	traces, err := e.repo.GetTracesWithoutContent(ctx, e.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	extracted := 0
+	for _, trace := range traces {
+		if ctx.Err() != nil {
+			return extracted, ctx.Err()
+		}
+
+		content, err := e.extract(ctx, trace)
+		if err != nil {
+			log.Printf("Failed to extract text from trace %s, will retry: %v", trace.ID, err)
+			continue
+		}
+		if err := e.repo.SaveTraceContent(ctx, trace.ID, content); err != nil {
+			// The trace was deleted while its PDF was read
+			if errors.Is(err, model.ErrNotFound) {
+				continue
+			}
+			return extracted, err
+		}
+		if content.Error != nil {
+			log.Printf("No text extracted from trace %s: %s", trace.ID, *content.Error)
+		}
+		extracted++
+	}
+	return extracted, nil
+}
+
+// extract reads a trace's PDF and returns its text. Errors are transient,
+// such as storage or the OCR service being unreachable; a PDF that yields
+// no text is recorded as such instead.
+func (e *Extractor) extract(ctx context.Context, trace model.Trace) (model.TraceContent, error) {
+	// Only PDFs are parsed here; the pipeline handles other formats
+	if trace.ContentType != model.ContentTypePDF {
+		return failed(fmt.Sprintf("%s is not a PDF", trace.ContentType)), nil
+	}
+	object, err := e.storage.Download(ctx, trace.FileName)
+	if errors.Is(err, storage.ErrNotFound) {
+		return failed("stored object not found"), nil
+	}
+	if err != nil {
+		return model.TraceContent{}, err
+	}
+	defer object.Close()
+	data, err := io.ReadAll(io.LimitReader(object, e.maxBytes))
+	if err != nil {
+		return model.TraceContent{}, err
+	}
+
+	text, parseErr := Text(data)
+	if parseErr == nil && text != "" {
+		return model.TraceContent{Text: text, Method: model.ExtractionText}, nil
+	}
+	if e.ocr != nil {
+		text, err := e.ocr.Recognize(ctx, data)
+		if err != nil {
+			return model.TraceContent{}, err
+		}
+		if text != "" {
+			return model.TraceContent{Text: text, Method: model.ExtractionOCR}, nil
+		}
+		return failed("no text recognized"), nil
+	}
+	if parseErr != nil {
+		return failed(fmt.Sprintf("failed to parse PDF: %v", parseErr)), nil
+	}
+	return failed("PDF has no text layer"), nil
+}
+
+// failed records a PDF that yielded no text
+func failed(reason string) model.TraceContent {
+	if len(reason) > maxErrorLen {
+		reason = strings.ToValidUTF8(reason[:maxErrorLen], "")
+	}
+	return model.TraceContent{Method: model.ExtractionNone, Error: &reason}
+}
@@ -0,0 +1,53 @@
+// internal/extract/ocr.go
+package extract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ocrTimeout bounds one OCR call; scanned PDFs can take a while
+const ocrTimeout = 2 * time.Minute
+
+// OCR recognizes the text of a PDF with no text layer
+type OCR interface {
+	Recognize(ctx context.Context, pdf []byte) (string, error)
+}
+
+// HTTPOCR posts the PDF to an OCR service, which answers with plain text
+type HTTPOCR struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPOCR(url string) *HTTPOCR {
+	return &HTTPOCR{url: url, client: &http.Client{Timeout: ocrTimeout}}
+}
+
+func (o *HTTPOCR) Recognize(ctx context.Context, pdf []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(pdf))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/pdf")
+	req.Header.Set("Accept", "text/plain")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call OCR service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("OCR service returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	text, err := io.ReadAll(io.LimitReader(resp.Body, maxTextBytes))
+	if err != nil {
+		return "", err
+	}
+	return clean(string(text)), nil
+}
@@ -0,0 +1,47 @@
+// internal/extract/pdf.go
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// maxTextBytes caps the text kept per trace; a textbook-length PDF fits
+const maxTextBytes = 1 << 20
+
+// Text returns the text layer of a PDF. Scanned PDFs have none, so the
+// result is empty rather than an error.
+func Text(data []byte) (text string, err error) {
+	// The parser panics on some malformed files
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("malformed PDF: %v", p)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	raw, err := io.ReadAll(io.LimitReader(plain, maxTextBytes))
+	if err != nil {
+		return "", err
+	}
+	return clean(string(raw)), nil
+}
+
+// clean makes extracted text storable: valid UTF-8 without the NUL bytes
+// Postgres rejects, and with runs of whitespace collapsed
+func clean(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.ReplaceAll(text, "\x00", "")
+	return strings.Join(strings.Fields(text), " ")
+}
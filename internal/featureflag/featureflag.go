@@ -0,0 +1,188 @@
+// internal/featureflag/featureflag.go
+package featureflag
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Flags guarding features that are rolled out gradually
+const (
+	AsyncUploads  = "async_uploads"
+	KafkaConsumer = "kafka_consumer"
+	ExternalAuth  = "external_auth"
+)
+
+// Definition describes a known flag and its value when nothing configures it
+type Definition struct {
+	Name        string
+	Description string
+	Default     bool
+}
+
+var Definitions = []Definition{
+	{AsyncUploads, "Accept trace uploads and store them in the background", false},
+	{KafkaConsumer, "Consume events from Kafka in this process", false},
+	{ExternalAuth, "Allow sign-in through external identity providers", false},
+}
+
+// Where a flag's current value comes from
+const (
+	SourceDefault  = "default"
+	SourceConfig   = "config"
+	SourceOverride = "override"
+)
+
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// State is a flag's current value
+type State struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Source      string `json:"source"`
+}
+
+// Flags resolves flags from, in order of precedence, overrides stored in
+// the database, FEATURE_FLAGS and the flag's default. Overrides are cached and
+// reloaded every interval, so a toggle on one replica reaches the others
+// within that time.
+type Flags struct {
+	repo       repository.Repository
+	configured map[string]bool
+	interval   time.Duration
+
+	mu        sync.RWMutex
+	overrides map[string]bool
+}
+
+func New(repo repository.Repository, cfg *config.Config) *Flags {
+	configured := map[string]bool{}
+	for name, enabled := range cfg.FeatureFlags {
+		if definition(name) == nil {
+			log.Printf("Warning: ignoring unknown feature flag %q in FEATURE_FLAGS", name)
+			continue
+		}
+		configured[name] = enabled
+	}
+	return &Flags{
+		repo:       repo,
+		configured: configured,
+		interval:   cfg.FeatureFlagRefreshInterval,
+		overrides:  map[string]bool{},
+	}
+}
+
+func definition(name string) *Definition {
+	for i := range Definitions {
+		if Definitions[i].Name == name {
+			return &Definitions[i]
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether the named flag is on. Unknown flags are off.
+func (f *Flags) Enabled(name string) bool {
+	state, ok := f.state(name)
+	return ok && state.Enabled
+}
+
+func (f *Flags) state(name string) (State, bool) {
+	def := definition(name)
+	if def == nil {
+		return State{}, false
+	}
+	state := State{Name: def.Name, Description: def.Description, Enabled: def.Default, Source: SourceDefault}
+	if enabled, ok := f.configured[name]; ok {
+		state.Enabled, state.Source = enabled, SourceConfig
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if enabled, ok := f.overrides[name]; ok {
+		state.Enabled, state.Source = enabled, SourceOverride
+	}
+	return state, true
+}
+
+// List returns every known flag's state in definition order
+func (f *Flags) List() []State {
+	states := make([]State, 0, len(Definitions))
+	for _, def := range Definitions {
+		state, _ := f.state(def.Name)
+		states = append(states, state)
+	}
+	return states
+}
+
+// Set stores an override for the named flag and returns its new state
+func (f *Flags) Set(ctx context.Context, name string, enabled bool, userID uuid.UUID) (State, error) {
+	if definition(name) == nil {
+		return State{}, ErrUnknownFlag
+	}
+	if _, err := f.repo.SetFeatureFlagOverride(ctx, name, enabled, userID); err != nil {
+		return State{}, err
+	}
+	f.mu.Lock()
+	f.overrides[name] = enabled
+	f.mu.Unlock()
+	state, _ := f.state(name)
+	return state, nil
+}
+
+// Clear removes the named flag's override, falling back to config
+func (f *Flags) Clear(ctx context.Context, name string) (State, error) {
+	if definition(name) == nil {
+		return State{}, ErrUnknownFlag
+	}
+	err := f.repo.DeleteFeatureFlagOverride(ctx, name)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		return State{}, err
+	}
+	f.mu.Lock()
+	delete(f.overrides, name)
+	f.mu.Unlock()
+	state, _ := f.state(name)
+	return state, nil
+}
+
+// Refresh reloads the overrides from the database
+func (f *Flags) Refresh(ctx context.Context) error {
+	stored, err := f.repo.ListFeatureFlagOverrides(ctx)
+	if err != nil {
+		return err
+	}
+	overrides := make(map[string]bool, len(stored))
+	for _, o := range stored {
+		overrides[o.Name] = o.Enabled
+	}
+	f.mu.Lock()
+	f.overrides = overrides
+	f.mu.Unlock()
+	return nil
+}
+
+// Run refreshes the overrides every interval until ctx is cancelled
+func (f *Flags) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Refresh(ctx); err != nil {
+				log.Printf("Failed to refresh feature flags: %v", err)
+			}
+		}
+	}
+}
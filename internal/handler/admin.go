@@ -0,0 +1,282 @@
+// internal/handler/admin.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/capture"
+	"api-server/internal/chaos"
+	"api-server/internal/featureflag"
+	"api-server/internal/logging"
+	"api-server/internal/repository"
+	"api-server/internal/response"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+)
+
+const adminRealm = "Admin Authentication Required"
+
+// AdminHandler serves operational endpoints under /v1/admin
+type AdminHandler struct {
+	repo  repository.Repository
+	flags *featureflag.Flags
+	// faults is nil when fault injection is not enabled
+	faults *chaos.Injector
+	// captures is nil when payload capture is not enabled
+	captures *capture.Recorder
+}
+
+func NewAdminHandler(repo repository.Repository, flags *featureflag.Flags, faults *chaos.Injector, captures *capture.Recorder) *AdminHandler {
+	return &AdminHandler{repo: repo, flags: flags, faults: faults, captures: captures}
+}
+
+type logLevelRequest struct {
+	Level string `json:"level" validate:"required,oneof=debug info warn error"`
+}
+
+// SetLogLevel changes the server's log level until the next restart
+func (h *AdminHandler) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	var req logLevelRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Record the change at warn, before or after applying it, so it shows
+	// up when either the old or the new level allows it
+	previous := logging.Level()
+	logChange := func() {
+		slog.Warn("Log level changed", "from", previous, "to", req.Level, "by", user.Username)
+	}
+	loggedBefore := slog.Default().Enabled(r.Context(), slog.LevelWarn)
+	if loggedBefore {
+		logChange()
+	}
+	if err := logging.SetLevel(req.Level); err != nil {
+		writeError(w, r, internalError(err, "Failed to change log level"))
+		return
+	}
+	if !loggedBefore {
+		logChange()
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string]string{"level": logging.Level()})
+}
+
+type featureFlagRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+// ListFeatureFlags reports every known flag with its value and where it comes from
+func (h *AdminHandler) ListFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	response.WriteJSON(w, http.StatusOK, map[string]any{"flags": h.flags.List()})
+}
+
+// SetFeatureFlag overrides a flag for every replica until the override is cleared
+func (h *AdminHandler) SetFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	var req featureFlagRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	name := r.PathValue("name")
+	state, err := h.flags.Set(r.Context(), name, *req.Enabled, user.ID)
+	if err != nil {
+		writeError(w, r, featureFlagError(err, "Failed to set feature flag"))
+		return
+	}
+	log.Printf("Feature flag %s set to %t by %s", name, state.Enabled, user.Username)
+	response.WriteJSON(w, http.StatusOK, state)
+}
+
+// ClearFeatureFlag removes a flag's override so its configured value applies again
+func (h *AdminHandler) ClearFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	name := r.PathValue("name")
+	state, err := h.flags.Clear(r.Context(), name)
+	if err != nil {
+		writeError(w, r, featureFlagError(err, "Failed to clear feature flag"))
+		return
+	}
+	log.Printf("Feature flag %s override cleared by %s", name, user.Username)
+	response.WriteJSON(w, http.StatusOK, state)
+}
+
+func featureFlagError(err error, message string) error {
+	if errors.Is(err, featureflag.ErrUnknownFlag) {
+		return apierror.NotFound(apierror.CodeFeatureNotFound, "Feature flag not found")
+	}
+	return internalError(err, message)
+}
+
+type chaosFaultRequest struct {
+	LatencyMS int `json:"latency_ms" validate:"gte=0,lte=60000"`
+	// LatencyPercent defaults to every call when LatencyMS is set
+	LatencyPercent *int `json:"latency_percent" validate:"omitnil,gte=0,lte=100"`
+	ErrorPercent   int  `json:"error_percent" validate:"gte=0,lte=100"`
+}
+
+// ListChaosFaults reports the fault injected into each dependency on this replica
+func (h *AdminHandler) ListChaosFaults(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	response.WriteJSON(w, http.StatusOK, map[string]any{"faults": h.faults.List()})
+}
+
+// SetChaosFault replaces the fault injected into a dependency on this
+// replica until it is cleared or the server restarts
+func (h *AdminHandler) SetChaosFault(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	var req chaosFaultRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	fault := chaos.Fault{
+		Dependency:     r.PathValue("dependency"),
+		LatencyMS:      req.LatencyMS,
+		LatencyPercent: 100,
+		ErrorPercent:   req.ErrorPercent,
+	}
+	if req.LatencyPercent != nil {
+		fault.LatencyPercent = *req.LatencyPercent
+	}
+	fault, err = h.faults.Set(fault)
+	if err != nil {
+		writeError(w, r, chaosError(err))
+		return
+	}
+	slog.Warn("Fault injection changed", "dependency", fault.Dependency, "latency_ms", fault.LatencyMS,
+		"latency_percent", fault.LatencyPercent, "error_percent", fault.ErrorPercent, "by", user.Username)
+	response.WriteJSON(w, http.StatusOK, fault)
+}
+
+// ClearChaosFault stops injecting faults into a dependency
+func (h *AdminHandler) ClearChaosFault(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	fault, err := h.faults.Clear(r.PathValue("dependency"))
+	if err != nil {
+		writeError(w, r, chaosError(err))
+		return
+	}
+	slog.Warn("Fault injection cleared", "dependency", fault.Dependency, "by", user.Username)
+	response.WriteJSON(w, http.StatusOK, fault)
+}
+
+func chaosError(err error) error {
+	if errors.Is(err, chaos.ErrUnknownDependency) {
+		return apierror.NotFound(apierror.CodeDependencyNotFound, "Dependency not found")
+	}
+	return internalError(err, "Failed to change fault injection")
+}
+
+type watchCaptureRequest struct {
+	RequestID string `json:"request_id" validate:"required,max=128"`
+}
+
+// ListCaptures returns the payloads captured on this replica, newest first
+func (h *AdminHandler) ListCaptures(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	response.WriteJSON(w, http.StatusOK, map[string]any{"captures": h.captures.List()})
+}
+
+// GetCapture returns the latest capture of a request by its request ID
+func (h *AdminHandler) GetCapture(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	c, ok := h.captures.Get(r.PathValue("request_id"))
+	if !ok {
+		writeError(w, r, apierror.NotFound(apierror.CodeCaptureNotFound, "Capture not found"))
+		return
+	}
+	response.WriteJSON(w, http.StatusOK, c)
+}
+
+// WatchCapture captures the next request sent with a given X-Request-ID on
+// this replica, whether or not it is sampled
+func (h *AdminHandler) WatchCapture(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	var req watchCaptureRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.captures.Watch(req.RequestID)
+	slog.Warn("Payload capture requested", "request_id", req.RequestID, "by", user.Username)
+	response.WriteJSON(w, http.StatusAccepted, req)
+}
+
+// ClearCaptures forgets every capture and watched request ID on this replica
+func (h *AdminHandler) ClearCaptures(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	h.captures.Clear()
+	slog.Warn("Payload captures cleared", "by", user.Username)
+	writeDeleted(w, r, "Captures cleared successfully")
+}
@@ -0,0 +1,348 @@
+// internal/handler/auth.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/auth"
+	"api-server/internal/mailer"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/tenant"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuthHandler exchanges identity provider logins for the API's own bearer
+// tokens. For OIDC the client runs the provider's login flow itself and
+// posts the resulting token here; for SAML the API is the service provider
+// and the browser is sent through the IdP and back to the ACS. Local and
+// LDAP passwords can be exchanged too, which is how admins with MFA log in.
+//
+// Admins with MFA enabled get a short-lived challenge instead of a token
+// from every login, and redeem it with a TOTP code at POST /auth/mfa/verify.
+type AuthHandler struct {
+	repo      repository.Repository
+	mailer    *mailer.Mailer
+	authn     auth.PasswordAuthenticator
+	tokens    *auth.Tokens
+	providers map[string]auth.Provider
+	roles     *auth.RoleMapper
+	saml      *auth.SAML
+	samlRoles *auth.RoleMapper
+	mfaLimits model.MFALimits
+}
+
+func NewAuthHandler(repo repository.Repository, mail *mailer.Mailer, authn auth.PasswordAuthenticator, tokens *auth.Tokens, providers map[string]auth.Provider, roles *auth.RoleMapper, saml *auth.SAML, samlRoles *auth.RoleMapper, mfaLimits model.MFALimits) *AuthHandler {
+	return &AuthHandler{repo: repo, mailer: mail, authn: authn, tokens: tokens, providers: providers, roles: roles, saml: saml, samlRoles: samlRoles, mfaLimits: mfaLimits}
+}
+
+// LoginPassword exchanges a username and password, checked by the
+// AUTH_BACKEND, for a bearer token
+func (h *AuthHandler) LoginPassword(w http.ResponseWriter, r *http.Request) {
+	var req model.PasswordLoginRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user, err := authenticateBasic(r, h.authn, req.Username, req.Password)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	token, err := h.issue(r, user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, token)
+}
+
+// VerifyMFA redeems a login's MFA challenge and a TOTP or recovery code for
+// a bearer token
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req model.MFAVerifyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	invalid := apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired MFA token")
+	claims, err := h.tokens.VerifyMFAChallenge(req.MFAToken)
+	if err != nil || claims.TenantID != tenant.ID(r.Context()) {
+		writeError(w, r, invalid)
+		return
+	}
+	user, err := h.repo.GetUser(r.Context(), claims.UserID)
+	if errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, invalid)
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to retrieve user"))
+		return
+	}
+	// MFA may have been reset since the challenge was issued
+	mfa, err := h.repo.GetMFA(r.Context(), user.ID)
+	if errors.Is(err, model.ErrNotFound) || (err == nil && !mfa.Enabled) {
+		writeError(w, r, invalid)
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to retrieve MFA status"))
+		return
+	}
+	if err := checkMFACode(w, r, h.repo, mfa, req.Code, claims, h.mfaLimits); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	token, err := h.startSession(r, user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, token)
+}
+
+// LoginOIDC verifies a provider token and returns a bearer token for the
+// user linked to the account. A first login links the account to the user
+// with the same verified email, or else creates a user for it.
+func (h *AuthHandler) LoginOIDC(w http.ResponseWriter, r *http.Request) {
+	var req model.OIDCLoginRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	provider, ok := h.providers[req.Provider]
+	if !ok {
+		writeError(w, r, apierror.BadRequest(apierror.CodeUnknownProvider, "Unknown identity provider"))
+		return
+	}
+	identity, err := provider.Verify(r.Context(), req.Token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredential) {
+			log.Printf("Rejected %s login: %v", req.Provider, err)
+			writeError(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid identity provider token"))
+			return
+		}
+		log.Printf("Identity provider %s failed: %v", req.Provider, err)
+		writeError(w, r, apierror.New(http.StatusBadGateway, apierror.CodeIdentityProviderFailed, "Identity provider is unavailable"))
+		return
+	}
+	token, err := h.login(r, identity, h.roles.Role(identity))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, token)
+}
+
+// SAMLMetadata serves the SP metadata the IdP is configured with
+func (h *AuthHandler) SAMLMetadata(w http.ResponseWriter, r *http.Request) {
+	metadata, err := h.saml.Metadata()
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to build SAML metadata"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(metadata)
+}
+
+// SAMLLogin sends the browser to the IdP. The optional redirect_uri is
+// where the ACS sends it back to with the bearer token.
+func (h *AuthHandler) SAMLLogin(w http.ResponseWriter, r *http.Request) {
+	loginURL, cookie, err := h.saml.Login(tenant.ID(r.Context()), r.URL.Query().Get("redirect_uri"))
+	if err != nil {
+		if errors.Is(err, auth.ErrRedirectNotAllowed) {
+			writeError(w, r, apierror.BadRequest(apierror.CodeRedirectNotAllowed, "redirect_uri is not an allowed redirect URL"))
+			return
+		}
+		log.Printf("SAML login failed: %v", err)
+		writeError(w, r, apierror.New(http.StatusBadGateway, apierror.CodeIdentityProviderFailed, "Identity provider is unavailable"))
+		return
+	}
+	http.SetCookie(w, cookie)
+	http.Redirect(w, r, loginURL, http.StatusFound)
+}
+
+// SAMLACS is the assertion consumer service the IdP posts the browser back
+// to. The bearer token goes to the login's redirect_uri in the URL
+// fragment, which browsers don't send to servers, or else in the response.
+func (h *AuthHandler) SAMLACS(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, h.saml.ClearCookie())
+	identity, redirect, err := h.saml.Verify(r, tenant.ID(r.Context()))
+	if err != nil {
+		if tooLarge := payloadTooLarge(err); tooLarge != nil {
+			writeError(w, r, tooLarge)
+			return
+		}
+		if errors.Is(err, auth.ErrInvalidCredential) {
+			log.Printf("Rejected SAML login: %v", err)
+			writeError(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid SAML response"))
+			return
+		}
+		log.Printf("SAML login failed: %v", err)
+		writeError(w, r, apierror.New(http.StatusBadGateway, apierror.CodeIdentityProviderFailed, "Identity provider is unavailable"))
+		return
+	}
+
+	token, err := h.login(r, identity, h.samlRoles.Role(identity))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if redirect == "" {
+		writeJSON(w, r, http.StatusOK, token)
+		return
+	}
+	fragment := url.Values{
+		"access_token": {token.AccessToken},
+		"token_type":   {token.TokenType},
+		"expires_at":   {token.ExpiresAt.Format(time.RFC3339)},
+	}
+	if token.MFARequired {
+		fragment = url.Values{
+			"mfa_required": {"true"},
+			"mfa_token":    {token.MFAToken},
+			"expires_at":   {token.ExpiresAt.Format(time.RFC3339)},
+		}
+	}
+	http.Redirect(w, r, redirect+"#"+fragment.Encode(), http.StatusSeeOther)
+}
+
+// Impersonate issues the admin a short-lived token acting as another user,
+// so support can reproduce what the user reports without their password.
+// The token's session names the admin, the issue is audit-logged and every
+// response to the token carries X-Impersonated-By.
+func (h *AuthHandler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	admin, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	userID, err := pathUUID(r, "user_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if userID == admin.ID {
+		writeError(w, r, apierror.New(http.StatusForbidden, apierror.CodeInsufficientPermissions, "Admins cannot impersonate themselves"))
+		return
+	}
+
+	user, err := h.repo.GetUser(r.Context(), userID)
+	if errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, apierror.NotFound(apierror.CodeUserNotFound, "User not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to retrieve user"))
+		return
+	}
+	// Acting as another admin would get round their MFA
+	if user.Role == "admin" {
+		writeError(w, r, apierror.New(http.StatusForbidden, apierror.CodeInsufficientPermissions, "Admins cannot be impersonated"))
+		return
+	}
+	if user.DeactivatedAt != nil {
+		writeError(w, r, apierror.New(http.StatusForbidden, apierror.CodeAccountDeactivated, "The user's account has been deactivated"))
+		return
+	}
+
+	sessionID := uuid.New()
+	token, expiresAt, err := h.tokens.IssueImpersonation(sessionID, user.ID, tenant.ID(r.Context()), admin.ID)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to issue token"))
+		return
+	}
+	session := model.NewSession(sessionID, user.ID, r.UserAgent(), remoteIP(r), expiresAt)
+	session.ImpersonatedBy = &admin.ID
+	if _, err := h.repo.StartImpersonation(r.Context(), session); err != nil {
+		writeError(w, r, internalError(err, "Failed to start session"))
+		return
+	}
+
+	log.Printf("User %s impersonating %s until %s", admin.Username, user.Username, expiresAt.Format(time.RFC3339))
+	writeJSON(w, r, http.StatusOK, &model.AuthToken{AccessToken: token, TokenType: "Bearer", ExpiresAt: expiresAt, User: user, ImpersonatedBy: &admin.ID})
+}
+
+// login finds or provisions the identity's user and issues their token
+func (h *AuthHandler) login(r *http.Request, identity *auth.Identity, role string) (*model.AuthToken, error) {
+	// Every user has an email, so accounts that don't share one can't log in
+	if identity.Email == "" {
+		return nil, apierror.New(http.StatusForbidden, apierror.CodeEmailRequired, "The identity provider did not share an email address")
+	}
+
+	user, created, err := h.repo.LoginExternal(r.Context(), identity.ExternalIdentity, role)
+	if err != nil {
+		// An unverified email can't be linked, and may not be reused either
+		if model.IsUniqueViolation(err, "users_email_key") {
+			return nil, apierror.Conflict(apierror.CodeEmailTaken, "Email already exists")
+		}
+		return nil, internalError(err, "Failed to log in")
+	}
+	if created {
+		log.Printf("Provisioned user %s (%s) from %s login", user.Username, user.Role, identity.Provider)
+		h.mailer.Send(r.Context(), user, model.EmailAccountCreated, nil)
+	}
+	return h.issue(r, user)
+}
+
+// issue returns the user's bearer token, or an MFA challenge if they have
+// MFA enabled
+func (h *AuthHandler) issue(r *http.Request, user *model.User) (*model.AuthToken, error) {
+	if err := checkDeactivated(user); err != nil {
+		return nil, err
+	}
+	enabled, err := mfaEnabled(r.Context(), h.repo, user)
+	if err != nil {
+		return nil, internalError(err, "Failed to check MFA status")
+	}
+	if enabled {
+		challenge, expiresAt, err := h.tokens.IssueMFAChallenge(user.ID, tenant.ID(r.Context()))
+		if err != nil {
+			return nil, internalError(err, "Failed to issue MFA challenge")
+		}
+		return &model.AuthToken{ExpiresAt: expiresAt, MFARequired: true, MFAToken: challenge}, nil
+	}
+
+	return h.startSession(r, user)
+}
+
+// startSession issues the user a bearer token and records its session, with
+// the client's user agent and address, so the user can revoke it
+func (h *AuthHandler) startSession(r *http.Request, user *model.User) (*model.AuthToken, error) {
+	if err := checkDeactivated(user); err != nil {
+		return nil, err
+	}
+	sessionID := uuid.New()
+	token, expiresAt, err := h.tokens.Issue(sessionID, user.ID, tenant.ID(r.Context()))
+	if err != nil {
+		return nil, internalError(err, "Failed to issue token")
+	}
+	session := model.NewSession(sessionID, user.ID, r.UserAgent(), remoteIP(r), expiresAt)
+	if _, err := h.repo.CreateSession(r.Context(), session); err != nil {
+		return nil, internalError(err, "Failed to start session")
+	}
+	return &model.AuthToken{AccessToken: token, TokenType: "Bearer", ExpiresAt: expiresAt, User: user}, nil
+}
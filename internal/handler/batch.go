@@ -0,0 +1,125 @@
+// internal/handler/batch.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/response"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// maxBatchOperations caps how many sub-operations a single batch may contain
+const maxBatchOperations = 50
+
+// BatchOperation is one request inside a POST /v1/batch body
+type BatchOperation struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResult is the outcome of one sub-operation, in request order
+type BatchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchHandler replays each sub-operation against the API's own router so
+// every item goes through the same authentication and validation as a
+// standalone request.
+type BatchHandler struct {
+	router http.Handler
+}
+
+func NewBatchHandler(router http.Handler) *BatchHandler {
+	return &BatchHandler{router: router}
+}
+
+func (h *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var ops []BatchOperation
+	if err := decodeJSON(r, &ops); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if len(ops) == 0 || len(ops) > maxBatchOperations {
+		writeError(w, r, apierror.Validation(fmt.Sprintf("Batch must contain between 1 and %d operations", maxBatchOperations)))
+		return
+	}
+
+	// Validate every operation before running any of them
+	for i, op := range ops {
+		if err := op.validate(); err != nil {
+			writeError(w, r, apierror.Validation(fmt.Sprintf("Operation %d: %v", i, err)))
+			return
+		}
+	}
+
+	// Operations run sequentially; a failed item doesn't stop the ones after it
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = h.run(r, op)
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string][]BatchResult{"results": results})
+}
+
+func (op BatchOperation) validate() error {
+	switch strings.ToUpper(op.Method) {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return fmt.Errorf("unsupported method %q", op.Method)
+	}
+	if !strings.HasPrefix(op.Path, "/v1/") {
+		return fmt.Errorf("path must start with /v1/")
+	}
+	if strings.HasPrefix(op.Path, "/v1/batch") {
+		return fmt.Errorf("batches cannot be nested")
+	}
+	return nil
+}
+
+func (h *BatchHandler) run(parent *http.Request, op BatchOperation) BatchResult {
+	req, err := http.NewRequestWithContext(parent.Context(), strings.ToUpper(op.Method), op.Path, bytes.NewReader(op.Body))
+	if err != nil {
+		return errorResult(apierror.Validation("Invalid operation path"))
+	}
+
+	// Sub-requests act on behalf of the caller of the batch
+	if auth := parent.Header.Get("Authorization"); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	if language := parent.Header.Get("Accept-Language"); language != "" {
+		req.Header.Set("Accept-Language", language)
+	}
+	if len(op.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.RemoteAddr = parent.RemoteAddr
+	if forwarded := parent.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		req.Header["X-Forwarded-For"] = forwarded
+	}
+
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+
+	result := BatchResult{Status: rec.Code}
+	body := bytes.TrimSpace(rec.Body.Bytes())
+	if len(body) > 0 {
+		if json.Valid(body) {
+			result.Body = body
+		} else {
+			// Plain-text responses such as the router's 404 page are passed through as a JSON string
+			result.Body, _ = json.Marshal(string(body))
+		}
+	}
+	return result
+}
+
+func errorResult(err *apierror.Error) BatchResult {
+	body, _ := json.Marshal(map[string]*apierror.Error{"error": err})
+	return BatchResult{Status: err.Status, Body: body}
+}
@@ -0,0 +1,266 @@
+// internal/handler/canvas.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/canvas"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// canvasSyncHistory is how many syncs ListSyncs returns
+const canvasSyncHistory = 20
+
+// CanvasHandler serves the tenant's Canvas LMS integration under
+// /admin/canvas: the connection, which courses are linked to which Canvas
+// courses, the rosters pulled from Canvas and the syncs that pull them
+type CanvasHandler struct {
+	repo repository.Repository
+	// syncer is nil when CANVAS_ENABLED is off
+	syncer *canvas.Syncer
+}
+
+func NewCanvasHandler(repo repository.Repository, syncer *canvas.Syncer) *CanvasHandler {
+	return &CanvasHandler{repo: repo, syncer: syncer}
+}
+
+func (h *CanvasHandler) GetConnection(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	conn, err := h.repo.GetCanvasConnection(r.Context())
+	if err != nil {
+		writeError(w, r, canvasConnectionError(err, "Failed to retrieve Canvas connection"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, conn)
+}
+
+// SaveConnection connects the tenant to a Canvas instance, or replaces its
+// connection. The API token is never returned.
+func (h *CanvasHandler) SaveConnection(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	var req model.SaveCanvasConnectionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	conn, err := h.repo.SaveCanvasConnection(r.Context(), req)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to save Canvas connection"))
+		return
+	}
+	log.Printf("Canvas connection to %s saved by %s", conn.BaseURL, user.Username)
+	writeJSON(w, r, http.StatusOK, conn)
+}
+
+// DeleteConnection disconnects the tenant from Canvas. Course links and
+// rosters are kept for a later connection.
+func (h *CanvasHandler) DeleteConnection(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	if err := h.repo.DeleteCanvasConnection(r.Context()); err != nil {
+		writeError(w, r, canvasConnectionError(err, "Failed to delete Canvas connection"))
+		return
+	}
+	log.Printf("Canvas connection deleted by %s", user.Username)
+	writeDeleted(w, r, "Canvas connection deleted successfully")
+}
+
+// ListCourseLinks returns the tenant's courses that are linked to Canvas
+// courses, oldest link first
+func (h *CanvasHandler) ListCourseLinks(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	links, err := h.repo.ListCanvasCourseLinks(r.Context())
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to list Canvas course links"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"courses": links})
+}
+
+// LinkCourse links a course to a Canvas course. Moving the link to another
+// Canvas course drops the roster pulled from the old one.
+func (h *CanvasHandler) LinkCourse(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req model.LinkCanvasCourseRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	link, err := h.repo.LinkCanvasCourse(r.Context(), courseID, req.CanvasCourseID)
+	if model.IsUniqueViolation(err, "canvas_course_links_canvas_course_key") {
+		writeError(w, r, apierror.Conflict(apierror.CodeCanvasCourseLinked, "Canvas course is already linked to another course"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, courseError(err, "Failed to link course to Canvas"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, link)
+}
+
+// UnlinkCourse removes a course's Canvas link and the roster pulled for it
+func (h *CanvasHandler) UnlinkCourse(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.repo.UnlinkCanvasCourse(r.Context(), courseID); err != nil {
+		writeError(w, r, canvasLinkError(err, "Failed to unlink course from Canvas"))
+		return
+	}
+	writeDeleted(w, r, "Course unlinked from Canvas successfully")
+}
+
+// GetRoster returns the roster the last sync pulled for a linked course, by
+// role and name
+func (h *CanvasHandler) GetRoster(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	roster, err := h.repo.GetCanvasRoster(r.Context(), courseID)
+	if err != nil {
+		writeError(w, r, canvasLinkError(err, "Failed to retrieve Canvas roster"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"roster": roster})
+}
+
+// RequestSync queues a sync of the tenant now, rather than at its next
+// scheduled time
+func (h *CanvasHandler) RequestSync(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	if h.syncer == nil {
+		writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeCanvasUnavailable, "Canvas sync is not enabled"))
+		return
+	}
+	if _, err := h.repo.GetCanvasConnection(r.Context()); err != nil {
+		writeError(w, r, canvasConnectionError(err, "Failed to queue Canvas sync"))
+		return
+	}
+
+	sync, err := h.repo.CreateCanvasSync(r.Context(), model.CanvasSyncManual, &user.ID)
+	if model.IsUniqueViolation(err, "canvas_syncs_active_idx") {
+		writeError(w, r, apierror.Conflict(apierror.CodeCanvasSyncInProgress, "A Canvas sync is already pending or running"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to queue Canvas sync"))
+		return
+	}
+	log.Printf("Canvas sync %s requested by %s", sync.ID, user.Username)
+	h.syncer.Notify()
+
+	w.Header().Set("Location", fmt.Sprintf("/v%d/admin/canvas/sync/%s", requestVersion(r), sync.ID))
+	writeJSON(w, r, http.StatusAccepted, sync)
+}
+
+// ListSyncs returns the tenant's latest syncs, newest first
+func (h *CanvasHandler) ListSyncs(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	syncs, err := h.repo.ListCanvasSyncs(r.Context(), canvasSyncHistory)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to list Canvas syncs"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"syncs": syncs})
+}
+
+func (h *CanvasHandler) GetSync(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	syncID, err := pathUUID(r, "sync_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	sync, err := h.repo.GetCanvasSync(r.Context(), syncID)
+	if errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, apierror.NotFound(apierror.CodeCanvasSyncNotFound, "Canvas sync not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to retrieve Canvas sync"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, sync)
+}
+
+// canvasConnectionError maps model.ErrNotFound to CANVAS_NOT_CONNECTED and anything else to a 500
+func canvasConnectionError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeCanvasNotConnected, "Canvas is not connected")
+	}
+	return internalError(err, message)
+}
+
+// canvasLinkError maps model.ErrNotFound to CANVAS_COURSE_NOT_LINKED and anything else to a 500
+func canvasLinkError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeCanvasCourseNotLinked, "Course is not linked to Canvas")
+	}
+	return internalError(err, message)
+}
@@ -0,0 +1,138 @@
+// internal/handler/comment.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"errors"
+	"net/http"
+)
+
+// CommentHandler lets signed-in users discuss a trace, leaving comments on
+// the whole PDF or one of its pages. A trace's comments are read, written
+// and deleted only by callers its visibility lets read the trace.
+type CommentHandler struct {
+	repo repository.Repository
+}
+
+func NewCommentHandler(repo repository.Repository) *CommentHandler {
+	return &CommentHandler{repo: repo}
+}
+
+// CreateComment adds the caller's comment to a trace
+func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// Only a trace the caller may read can be commented on
+	if _, err := readableTrace(r, h.repo, "Failed to create comment"); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	var req model.CreateTraceCommentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	comment, err := h.repo.CreateTraceComment(r.Context(), courseID, traceID, user.ID, req)
+	if err != nil {
+		writeError(w, r, traceError(err, "Failed to create comment"))
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, comment)
+}
+
+// ListComments returns a page of a trace's comments, oldest first
+func (h *CommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticate(r, h.repo); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// The comments of a trace hidden from the caller are hidden with it
+	if _, err := readableTrace(r, h.repo, "Failed to retrieve comments"); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	comments, err := h.repo.ListTraceComments(r.Context(), courseID, traceID, opts)
+	if errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, traceError(err, "Failed to retrieve comments"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, listError(err, "Failed to retrieve comments"))
+		return
+	}
+	writePage(w, r, comments, opts.Fields)
+}
+
+// DeleteComment deletes a comment. Users can delete their own comments;
+// admins can delete anyone's.
+func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	_, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	commentID, err := pathUUID(r, "comment_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, err := readableTrace(r, h.repo, "Failed to delete comment"); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	comment, err := h.repo.GetTraceComment(r.Context(), traceID, commentID)
+	if err != nil {
+		writeError(w, r, commentError(err, "Failed to delete comment"))
+		return
+	}
+	if comment.UserID != user.ID && user.Role != "admin" {
+		writeError(w, r, apierror.New(http.StatusForbidden, apierror.CodeInsufficientPermissions, "Only the author or an admin can delete a comment"))
+		return
+	}
+	if err := h.repo.DeleteTraceComment(r.Context(), traceID, commentID); err != nil {
+		writeError(w, r, commentError(err, "Failed to delete comment"))
+		return
+	}
+	writeDeleted(w, r, "Comment deleted successfully")
+}
+
+// commentError maps model.ErrNotFound to COMMENT_NOT_FOUND and anything else to a 500
+func commentError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeCommentNotFound, "Comment not found")
+	}
+	return internalError(err, message)
+}
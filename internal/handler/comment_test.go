@@ -0,0 +1,92 @@
+// internal/handler/comment_test.go
+package handler_test
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/testutil"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// commentTrace uploads a trace, private as every upload starts out, to a new
+// course and returns the path of its comments
+func commentTrace(t *testing.T, env *testutil.Env, admin testutil.Credentials) string {
+	t.Helper()
+	var instructor model.Instructor
+	env.JSON(t, &admin, http.MethodPost, "/v1/instructor",
+		model.CreateInstructorRequest{Name: "Ada Lovelace", Email: "ada@example.edu"}, http.StatusCreated, &instructor)
+	var course model.Course
+	env.JSON(t, &admin, http.MethodPost, "/v1/course", model.CreateCourseRequest{
+		Name:         "Network Structures and Cloud Computing",
+		SemesterTerm: "Fall",
+		CreditHours:  4,
+		SubjectCode:  "CSYE",
+		CourseID:     6225,
+		SemesterYear: 2025,
+		InstructorID: instructor.ID,
+	}, http.StatusCreated, &course)
+
+	resp, body := env.UploadTrace(t, &admin, course.ID.String(), "lecture.pdf", tracePDF)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: status %d: %s", resp.StatusCode, body)
+	}
+	var uploaded uploadResponse
+	if err := json.Unmarshal(body, &uploaded); err != nil {
+		t.Fatalf("failed to decode upload response: %v: %s", err, body)
+	}
+	return fmt.Sprintf("/v1/course/%s/trace/%s/comments", course.ID, uploaded.TraceID)
+}
+
+// wantError fails the test unless the response is status with code
+func wantError(t *testing.T, resp *http.Response, body []byte, status int, code string) {
+	t.Helper()
+	var envelope struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	json.Unmarshal(body, &envelope)
+	if resp.StatusCode != status || envelope.Error.Code != code {
+		t.Errorf("%s %s: status %d and code %q, want %d and %s: %s",
+			resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, envelope.Error.Code, status, code, body)
+	}
+}
+
+// TestCommentsFollowTraceVisibility checks that a trace's comments can't be
+// read, written or deleted by a signed-in user the trace is hidden from
+func TestCommentsFollowTraceVisibility(t *testing.T) {
+	env := testutil.StartInMemory(t)
+	admin := env.CreateAdmin(t, "admin")
+	student := env.CreateUser(t, "student", "student")
+	path := commentTrace(t, env, admin)
+
+	var comment model.TraceComment
+	env.JSON(t, &admin, http.MethodPost, path, model.CreateTraceCommentRequest{Text: "See slide 3"}, http.StatusCreated, &comment)
+
+	// The trace is private, so to the student it doesn't exist
+	resp, body := env.Do(t, &student, http.MethodGet, path, "", nil)
+	wantError(t, resp, body, http.StatusNotFound, "TRACE_NOT_FOUND")
+	resp, body = env.Do(t, &student, http.MethodPost, path, "application/json", strings.NewReader(`{"text":"Hello"}`))
+	wantError(t, resp, body, http.StatusNotFound, "TRACE_NOT_FOUND")
+	resp, body = env.Do(t, &student, http.MethodDelete, path+"/"+comment.ID.String(), "", nil)
+	wantError(t, resp, body, http.StatusNotFound, "TRACE_NOT_FOUND")
+
+	// and without credentials there's nothing to see either
+	resp, body = env.Do(t, nil, http.MethodGet, path, "", nil)
+	wantError(t, resp, body, http.StatusUnauthorized, "AUTHENTICATION_REQUIRED")
+
+	// Once course members may read the trace, the student can join in
+	env.JSON(t, &admin, http.MethodPut, strings.TrimSuffix(path, "/comments")+"/visibility",
+		map[string]string{"visibility": model.VisibilityCourseMembers}, http.StatusOK, nil)
+	env.JSON(t, &student, http.MethodPost, path, model.CreateTraceCommentRequest{Text: "Which slide?"}, http.StatusCreated, nil)
+	var page struct {
+		Data []model.TraceComment `json:"data"`
+	}
+	env.JSON(t, &student, http.MethodGet, path, nil, http.StatusOK, &page)
+	if len(page.Data) != 2 {
+		t.Errorf("student sees %d comments, want 2", len(page.Data))
+	}
+}
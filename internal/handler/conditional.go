@@ -0,0 +1,61 @@
+// internal/handler/conditional.go
+package handler
+
+import (
+	"api-server/internal/response"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// writeCacheable writes body with an ETag, and a Last-Modified header when
+// lastModified is set, answering 304 Not Modified when the client's copy is
+// current. The ETag is a hash of the JSON body and is weak because content
+// negotiation may re-encode the same content.
+func writeCacheable(w http.ResponseWriter, r *http.Request, lastModified time.Time, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to encode response"))
+		return
+	}
+	sum := sha256.Sum256(data)
+	etag := fmt.Sprintf(`W/"%x"`, sum[:16])
+
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	response.WriteJSON(w, http.StatusOK, body)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when no
+// If-None-Match is sent, as RFC 9110 orders them
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// HTTP dates have whole seconds
+	return !lastModified.Truncate(time.Second).After(since)
+}
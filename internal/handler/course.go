@@ -2,546 +2,1234 @@
 package handler
 
 import (
-	"api-server/internal/config"
+	"api-server/internal/apierror"
+	"api-server/internal/lifecycle"
+	"api-server/internal/mailer"
+	"api-server/internal/middleware"
 	"api-server/internal/model"
+	"api-server/internal/notify"
+	"api-server/internal/outbox"
+	"api-server/internal/progress"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"api-server/internal/tenant"
 	"context"
-	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
-	"cloud.google.com/go/storage"
-	"google.golang.org/api/option"
-
-	"github.com/IBM/sarama"
 	"github.com/google/uuid"
 )
 
 type CourseHandler struct {
-	db         *sql.DB
-	gcsClient  *storage.Client
-	bucketName string
-	producer   sarama.SyncProducer
+	repo      repository.Repository
+	storage   storage.Storage
+	lifecycle *lifecycle.Manager
+	outbox    *outbox.Relay
+	notifier  *notify.Notifier
+	mailer    *mailer.Mailer
+	uploads   *progress.Tracker
+	// allowedTypes are the MIME types traces may be uploaded as
+	allowedTypes []string
+	// limits cap each course's total trace bytes and what each user uploads
+	// in a day
+	limits model.UploadLimits
 }
 
-func NewCourseHandler(db *sql.DB, cfg *config.Config, producer sarama.SyncProducer) *CourseHandler {
-	ctx := context.Background()
-	var client *storage.Client
-	var err error
-
-	log.Printf("GCSCredentialsFile: %q", cfg.GCSCredentialsFile)
-	if cfg.GCSCredentialsFile != "" {
-		client, err = storage.NewClient(ctx, option.WithCredentialsFile(cfg.GCSCredentialsFile))
-	} else {
-		log.Println("Using Application Default Credentials")
-		client, err = storage.NewClient(ctx)
-	}
-	if err != nil {
-		log.Fatalf("Failed to create GCS client: %v", err)
-	}
-
+func NewCourseHandler(repo repository.Repository, store storage.Storage, lifecycleManager *lifecycle.Manager, relay *outbox.Relay, notifier *notify.Notifier, mail *mailer.Mailer, uploads *progress.Tracker, allowedTypes []string, limits model.UploadLimits) *CourseHandler {
 	return &CourseHandler{
-		db:         db,
-		gcsClient:  client,
-		bucketName: cfg.GCSBucketName,
-		producer:   producer,
+		repo:         repo,
+		storage:      store,
+		lifecycle:    lifecycleManager,
+		outbox:       relay,
+		notifier:     notifier,
+		mailer:       mail,
+		uploads:      uploads,
+		allowedTypes: allowedTypes,
+		limits:       limits,
 	}
 }
 
-func (h *CourseHandler) authenticateRequest(w http.ResponseWriter, r *http.Request) (*model.User, error) {
-	username, password, hasAuth := r.BasicAuth()
-	if !hasAuth {
-		return nil, fmt.Errorf("authentication required")
-	}
-
-	// Authenticate the user
-	user, err := model.AuthenticateUser(h.db, username, password)
-	if err != nil {
-		return nil, err
-	}
-
-	// Check admin privileges
-	if user.Role != "admin" {
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Insufficient permissions"})
-		return nil, fmt.Errorf("insufficient permissions")
-	}
+// courseRealm is the Basic Auth realm challenged on course and trace endpoints
+const courseRealm = "Course Authentication Required"
 
-	return user, nil
-}
-
-func (h *CourseHandler) handleAuthError(w http.ResponseWriter, err error) {
-	w.Header().Set("WWW-Authenticate", `Basic realm="Course Authentication Required"`)
-	w.WriteHeader(http.StatusUnauthorized)
-	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+func (h *CourseHandler) handleStorageUnavailable(w http.ResponseWriter, r *http.Request, err error) {
+	writeStorageUnavailable(w, r, err)
 }
 
 func (h *CourseHandler) CreateCourse(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	// Authenticate user
-	user, err := h.authenticateRequest(w, r)
+	user, err := authenticateAdmin(r, h.repo)
 	if err != nil {
-		h.handleAuthError(w, err)
+		writeAuthError(w, r, err, courseRealm)
 		return
 	}
 
 	var req model.CreateCourseRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// Validate the request data
-	if err := req.Validate(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// Create the course in the database
-	course, err := model.CreateCourse(h.db, req, user.ID)
+	course, err := h.repo.CreateCourse(r.Context(), req, user.ID)
 	if err != nil {
-		if strings.Contains(err.Error(), "foreign key constraint") {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid instructor_id"})
+		if model.IsForeignKeyViolation(err) {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidReference, "Invalid instructor_id"))
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create course"})
+		writeError(w, r, quotaError(w, err, "Failed to create course"))
 		return
 	}
 
 	// Return the created course
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(course)
+	writeJSON(w, r, http.StatusCreated, course)
 }
 
 func (h *CourseHandler) GetCourseByID(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	// Extract the course ID from path parameters
-	courseIDStr := r.PathValue("course_id")
-	if courseIDStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Course ID is required"})
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Parse the related resources to embed in the course
+	expand, err := parseCourseExpand(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Parse the course ID into a UUID
-	courseID, err := uuid.Parse(courseIDStr)
+	// Traces are for admins and services allowed to read them, so a course
+	// with its trace summary is no longer the same for every caller
+	if expand.Traces {
+		if err := authenticateScoped(r, h.repo, model.ScopeTraceRead); err != nil {
+			writeAuthError(w, r, err, courseRealm)
+			return
+		}
+		w.Header().Set("Cache-Control", middleware.NoStore)
+	}
+
+	// Retrieve the course from the database, joined to what it embeds
+	var course *model.Course
+	if expand.Instructor || expand.Traces {
+		course, err = h.repo.GetCourseExpanded(r.Context(), courseID, expand)
+	} else {
+		course, err = h.repo.GetCourseByID(r.Context(), courseID)
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid course ID format"})
+		writeError(w, r, courseError(err, "Failed to retrieve course"))
 		return
 	}
 
-	// Retrieve the course from the database
-	course, err := model.GetCourseByID(h.db, courseID)
+	// The response changes when the embedded instructor does, and with the
+	// trace summary also when a trace is deleted, which no date records
+	lastModified := course.DateUpdated
+	if course.Instructor != nil && course.Instructor.DateUpdated.After(lastModified) {
+		lastModified = course.Instructor.DateUpdated
+	}
+	if course.Traces != nil {
+		lastModified = time.Time{}
+	}
+
+	// Return the course details as JSON, or 304 if the client's copy is current
+	writeCacheable(w, r, lastModified, envelope(r, course))
+}
+
+func (h *CourseHandler) ListCourses(w http.ResponseWriter, r *http.Request) {
+	// Parse pagination, sort and field selection parameters
+	opts, err := parseListOptions(r)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Course not found"})
+		writeError(w, r, err)
+		return
+	}
+	// Archived courses are listed only on request, and then on their own
+	archived := false
+	if raw := r.URL.Query().Get("archived"); raw != "" {
+		if archived, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidQuery, "archived must be true or false"))
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve course"})
+	}
+
+	// Retrieve the page of courses from the database
+	courses, err := h.repo.ListCourses(r.Context(), archived, opts)
+	if err != nil {
+		writeError(w, r, listError(err, "Failed to retrieve courses"))
 		return
 	}
 
-	// Return the course details as JSON
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(course)
+	// Lists get no Last-Modified: a removed course doesn't change the newest date
+	writeCacheable(w, r, time.Time{}, pageBody(r, courses, opts.Fields))
 }
 
 func (h *CourseHandler) DeleteCourseByID(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	// Authenticate user
-	_, err := h.authenticateRequest(w, r)
-	if err != nil {
-		h.handleAuthError(w, err)
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, courseRealm)
 		return
 	}
 
 	// Extract the course ID from path parameters
-	courseIDStr := r.PathValue("course_id")
-	if courseIDStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Course ID is required"})
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Parse the course ID as a UUID
-	courseID, err := uuid.Parse(courseIDStr)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid course ID format"})
+	// Delete the course from the database
+	if err := h.repo.DeleteCourseByID(r.Context(), courseID); err != nil {
+		writeError(w, r, courseError(err, "Failed to delete course"))
 		return
 	}
 
-	// Delete the course from the database
-	err = model.DeleteCourseByID(h.db, courseID)
+	// Return success response
+	writeDeleted(w, r, "Course deleted successfully")
+}
+
+// ArchiveCourse hides a course from default listings and stops trace
+// uploads to it. It stays readable, and archiving it again changes nothing.
+func (h *CourseHandler) ArchiveCourse(w http.ResponseWriter, r *http.Request) {
+	h.setCourseArchived(w, r, true)
+}
+
+// UnarchiveCourse returns an archived course to default listings and uploads
+func (h *CourseHandler) UnarchiveCourse(w http.ResponseWriter, r *http.Request) {
+	h.setCourseArchived(w, r, false)
+}
+
+func (h *CourseHandler) setCourseArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	user, err := authenticateAdmin(r, h.repo)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Course not found"})
-			return
-		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete course"})
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Return success response
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Course deleted successfully"})
+	course, err := h.repo.SetCourseArchived(r.Context(), courseID, archived, user.ID)
+	if err != nil {
+		writeError(w, r, courseError(err, "Failed to update course"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, course)
 }
 
 func (h *CourseHandler) PatchCourse(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	// Authenticate user
-	user, err := h.authenticateRequest(w, r)
+	user, err := authenticateAdmin(r, h.repo)
 	if err != nil {
-		h.handleAuthError(w, err)
+		writeAuthError(w, r, err, courseRealm)
 		return
 	}
 
 	// Extract the course ID from path parameters
-	courseIDStr := r.PathValue("course_id")
-	if courseIDStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Course ID is required"})
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	courseID, err := uuid.Parse(courseIDStr)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid course ID format"})
+	// A merge patch or JSON Patch applies to the course as it is now
+	if patchType := patchType(r); patchType != "" {
+		course, err := h.repo.GetCourseByID(r.Context(), courseID)
+		if err != nil {
+			writeError(w, r, courseError(err, "Failed to update course"))
+			return
+		}
+		var patched model.CreateCourseRequest
+		if err := applyPatch(r, patchType, course.Writable(), &patched); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		// A field the patch removed fails validation like a missing one
+		if err := validateRequest(&patched); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		// Only what the patch changed is written, so concurrent updates to
+		// other fields survive
+		h.updateCourse(w, r, courseID, patched.ChangesFrom(*course), user.ID)
 		return
 	}
 
 	// Parse request body
 	var req model.UpdateCourseRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// Validate request
-	if err := req.Validate(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.updateCourse(w, r, courseID, req, user.ID)
+}
+
+// ExportCourses streams every course, or every archived course, as one JSON
+// array, for exports too large to page through
+func (h *CourseHandler) ExportCourses(w http.ResponseWriter, r *http.Request) {
+	// Authenticate user
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, courseRealm)
 		return
 	}
 
-	// Update the course
-	updatedCourse, err := model.UpdateCourse(h.db, courseID, req, user.ID)
+	// Parse sort and field selection parameters; paging doesn't apply
+	opts, err := parseListOptions(r)
 	if err != nil {
-		if strings.Contains(err.Error(), "foreign key constraint") {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user_id or instructor_id"})
+		writeError(w, r, err)
+		return
+	}
+	archived := false
+	if raw := r.URL.Query().Get("archived"); raw != "" {
+		if archived, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidQuery, "archived must be true or false"))
 			return
 		}
-		if err.Error() == "course not found" {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Course not found"})
+	}
+
+	streamList(w, r, opts.Fields, "Failed to export courses", func(fn func(model.Course) error) error {
+		return h.repo.StreamCourses(r.Context(), archived, opts, fn)
+	})
+}
+
+// PutCourse replaces a course. Unlike PATCH, the body must carry every
+// field a new course needs.
+func (h *CourseHandler) PutCourse(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req model.CreateCourseRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.updateCourse(w, r, courseID, req.ReplaceRequest(), user.ID)
+}
+
+// updateCourse applies a PATCH or PUT and writes the updated course
+func (h *CourseHandler) updateCourse(w http.ResponseWriter, r *http.Request, courseID uuid.UUID, req model.UpdateCourseRequest, userID uuid.UUID) {
+	// Update the course and record the change in the audit log atomically
+	updatedCourse, err := h.repo.UpdateCourse(r.Context(), courseID, req, userID)
+	if err != nil {
+		if model.IsForeignKeyViolation(err) {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidReference, "Invalid user_id or instructor_id"))
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update course"})
+		writeError(w, r, courseError(err, "Failed to update course"))
 		return
 	}
 
 	// Return the updated course
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(updatedCourse)
+	writeJSON(w, r, http.StatusOK, updatedCourse)
+}
+
+// BulkUpdateCourses applies the same changes to every course a filter
+// matches, such as rolling a semester's courses over to the next one, in
+// one transaction. With ?dry_run=true it only reports what would change.
+func (h *CourseHandler) BulkUpdateCourses(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	dryRun := false
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidQuery, "dry_run must be true or false"))
+			return
+		}
+	}
+
+	var req model.BulkUpdateCoursesRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// An empty filter would rewrite every course of the tenant
+	if req.Filter.Empty() {
+		writeError(w, r, apierror.Validation("filter must name at least one field"))
+		return
+	}
+	if req.Changes == (model.UpdateCourseRequest{}) {
+		writeError(w, r, apierror.Validation("changes must name at least one field"))
+		return
+	}
+	// A dry run writes nothing for the database to check the instructor against
+	if req.Changes.InstructorID != nil {
+		if _, err := h.repo.GetInstructorByID(r.Context(), *req.Changes.InstructorID); err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				writeError(w, r, apierror.BadRequest(apierror.CodeInvalidReference, "Invalid instructor_id"))
+				return
+			}
+			writeError(w, r, internalError(err, "Failed to update courses"))
+			return
+		}
+	}
+
+	results, err := h.repo.BulkUpdateCourses(r.Context(), req.Filter, req.Changes, user.ID, dryRun)
+	if err != nil {
+		if model.IsForeignKeyViolation(err) {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidReference, "Invalid instructor_id"))
+			return
+		}
+		writeError(w, r, internalError(err, "Failed to update courses"))
+		return
+	}
+	updated := 0
+	for _, result := range results {
+		if len(result.Changes) > 0 {
+			updated++
+		}
+	}
+	if !dryRun {
+		log.Printf("Bulk update of %d courses by %s changed %d", len(results), user.Username, updated)
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"dry_run": dryRun,
+		"matched": len(results),
+		"updated": updated,
+		"courses": results,
+	})
 }
 
 func (h *CourseHandler) HandleTraceUpload(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	// Authenticate user
-	user, err := h.authenticateRequest(w, r)
+	user, err := authenticateAdmin(r, h.repo)
 	if err != nil {
-		h.handleAuthError(w, err)
+		writeAuthError(w, r, err, courseRealm)
 		return
 	}
 
 	// Extract course ID from path parameters
-	courseIDStr := r.PathValue("course_id")
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 
-	// Parse the course ID
-	courseID, err := uuid.Parse(courseIDStr)
+	// Report progress under the client's upload session, if it named one,
+	// from the first byte received
+	session, err := startUploadSession(r, h.uploads, user.ID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid course_id format"})
+		writeError(w, r, err)
 		return
 	}
+	defer session.Finish()
+	r.Body = session.Receive(r.Body)
 
-	// Parse multipart form (max 10MB)
+	// Fail fast while storage is down instead of recording a failed trace
+	if err := h.storage.Connect(r.Context()); err != nil {
+		h.handleStorageUnavailable(w, r, err)
+		return
+	}
+
+	// Parse multipart form, keeping up to 10MB in memory; the total size is
+	// capped by the upload body limit
 	err = r.ParseMultipartForm(10 << 20)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to parse multipart form"})
+		if tooLarge := payloadTooLarge(err); tooLarge != nil {
+			writeError(w, r, tooLarge)
+			return
+		}
+		writeError(w, r, apierror.BadRequest(apierror.CodeInvalidRequestBody, "Failed to parse multipart form"))
 		return
 	}
 
-	// Get the PDF file
-	file, _, err := r.FormFile("file")
+	// Get the file; a missing file is reported by validation below
+	uploadReq := model.UploadTraceRequest{VectorID: r.FormValue("vector_id"), Visibility: r.FormValue("visibility")}
+	file, header, err := r.FormFile("file")
+	if err == nil {
+		defer file.Close()
+		uploadReq.File = header.Filename
+	}
+	if err := validateRequest(&uploadReq); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	contentType, format, err := uploadFormat(file, header, h.allowedTypes)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "File is required"})
+		writeError(w, r, err)
 		return
 	}
-	defer file.Close()
 
 	var vectorID *string
-	if vid := r.FormValue("vector_id"); vid != "" {
-		vectorID = &vid
+	if uploadReq.VectorID != "" {
+		vectorID = &uploadReq.VectorID
 	}
 
 	// Fetch course details
-	course, err := model.GetCourseByID(h.db, courseID)
+	course, err := h.repo.GetCourseByID(r.Context(), courseID)
 	if err != nil {
-		log.Printf("Failed to fetch course: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch course details"})
+		writeError(w, r, courseError(err, "Failed to fetch course details"))
+		return
+	}
+	if course.ArchivedAt != nil {
+		writeError(w, r, apierror.Conflict(apierror.CodeCourseArchived, "Course is archived; unarchive it to upload traces"))
 		return
 	}
 
 	// Fetch instructor details
-	instructor, err := model.GetInstructorByID(h.db, course.InstructorID)
+	instructor, err := h.repo.GetInstructorByID(r.Context(), course.InstructorID)
 	if err != nil {
-		log.Printf("Failed to fetch instructor: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch instructor details"})
+		writeError(w, r, internalError(err, "Failed to fetch instructor details"))
 		return
 	}
 
-	// Generate custom filename
-	customName := fmt.Sprintf(
-		"%s_%s_%s_%d_%s_%d.pdf",
-		sanitizeFilename(course.Name),
-		sanitizeFilename(instructor.Name),
-		course.SubjectCode,
-		course.CourseID,
-		course.SemesterTerm,
-		course.SemesterYear,
-	)
+	// Generate custom filename, with the extension of the uploaded format.
+	// The trace's ID keeps it apart from the other uploads to the course.
+	traceID := uuid.New()
+	customName := traceFileName(course, instructor, "_"+traceID.String(), format.Extension)
 
-	// Generate a unique filename for GCS to avoid conflicts
-	bucketURL, err := h.uploadToGCS(file, customName)
-	status := "uploaded"
+	// Count the upload against the course's, the user's and the tenant's
+	// quotas before storing it; it is given back if it doesn't end up recorded
+	if err := h.repo.ChargeUpload(r.Context(), courseID, user.ID, header.Size, h.limits); err != nil {
+		writeError(w, r, quotaError(w, err, "Failed to record upload usage"))
+		return
+	}
+
+	// Store it under the tenant's bucket prefix if it has one. The trace records the prefixed
+	// name, which is what the lifecycle and data jobs move and delete.
+	objectName := tenant.ObjectName(r.Context(), customName)
+	bucketURL, err := h.storage.Upload(r.Context(), objectName, session.Store(file, header.Size))
+	newTrace := repository.NewTrace{
+		ID:           traceID,
+		UserID:       user.ID,
+		InstructorID: course.InstructorID,
+		CourseID:     courseID,
+		Status:       "uploaded",
+		VectorID:     vectorID,
+		FileName:     objectName,
+		BucketURL:    bucketURL,
+		ContentType:  contentType,
+		SizeBytes:    header.Size,
+		Visibility:   uploadReq.Visibility,
+	}
 	if err != nil {
 		log.Printf("GCS upload failed: %v", err)
-		status = "failed"
-		bucketURL = "" // Since bucket_url is NOT NULL, use empty string
-		err = model.InsertTrace(h.db, user.ID, course.InstructorID, status, courseID, vectorID, customName, bucketURL)
+		h.notifier.Notify(notify.EventUploadFailed, "Trace upload failed",
+			"Uploading %s for course %s (tenant %s) to GCS failed: %v", objectName, courseID, tenant.Slug(r.Context()), err)
+		h.refundUpload(r, courseID, user.ID, header.Size)
+		newTrace.SizeBytes = 0
+		newTrace.Status = "failed"
+		newTrace.BucketURL = "" // Since bucket_url is NOT NULL, use empty string
+		_, err = h.repo.InsertTrace(r.Context(), newTrace)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to insert trace record"})
+			writeError(w, r, internalError(err, "Failed to insert trace record"))
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to upload file to GCS"})
+		writeError(w, r, apierror.New(http.StatusInternalServerError, apierror.CodeUploadFailed, "Failed to upload file to GCS"))
 		return
 	}
 
-	// Insert trace record on successful upload
-	err = model.InsertTrace(h.db, user.ID, course.InstructorID, status, courseID, vectorID, customName, bucketURL)
+	// Build the pdf-upload event for the processing pipeline, naming the
+	// trace it reports the processing status of
+	messageBytes, err := model.PDFUploadEvent(newTrace.ID, *course, *instructor, bucketURL, contentType, tenant.Slug(r.Context()))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to insert trace record"})
+		h.refundUpload(r, courseID, user.ID, header.Size)
+		writeError(w, r, internalError(err, "Failed to insert trace record"))
 		return
 	}
 
-	// Produce JSON message to Kafka
-	traceMessage := map[string]string{
-		"instructor_name": strings.ToLower(instructor.Name),
-		"course_code":     strings.ToLower(fmt.Sprintf("%s %d", course.SubjectCode, course.CourseID)),
-		"semester_term":   strings.ToLower(course.SemesterTerm),
-		"semester_year":   strings.ToLower(fmt.Sprintf("%d", course.SemesterYear)),
-		"course_name":     strings.ToLower(course.Name),
-		"credit_hours":    strings.ToLower(fmt.Sprintf("%d", course.CreditHours)),
-		"bucket_path":     bucketURL,
+	// Insert the trace record and its outbox event atomically. The upload is
+	// accepted even while Kafka is down: the trace stays publish_pending
+	// until the relay gets the event through.
+	trace, err := h.repo.InsertTraceWithEvent(r.Context(), newTrace, model.TopicPDFUpload, messageBytes)
+	if err != nil {
+		h.refundUpload(r, courseID, user.ID, header.Size)
+		writeError(w, r, internalError(err, "Failed to insert trace record"))
+		return
 	}
-	messageBytes, err := json.Marshal(traceMessage)
+
+	// Publish right away rather than waiting for the next relay poll
+	h.outbox.Notify()
+	session.Complete(trace.ID)
+
+	writeJSON(w, r, http.StatusCreated, map[string]string{
+		"message":        "File uploaded successfully",
+		"bucket_url":     bucketURL,
+		"trace_id":       trace.ID.String(),
+		"publish_status": *trace.PublishStatus,
+	})
+}
+
+// CreateTraceLink records a link to a video hosted elsewhere as a trace of
+// the course. Nothing is stored, so nothing is charged against the upload
+// quotas; the link is processed like an upload and its trace's visibility
+// decides who may follow it.
+func (h *CourseHandler) CreateTraceLink(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
 	if err != nil {
-		log.Printf("Failed to marshal Kafka message: %v", err)
-	} else {
-		msg := &sarama.ProducerMessage{
-			Topic: "pdf-upload",
-			Value: sarama.ByteEncoder(messageBytes),
-		}
-		partition, offset, err := h.producer.SendMessage(msg)
-		if err != nil {
-			log.Printf("Failed to send Kafka message: %v", err)
-		} else {
-			log.Printf("Sent message to partition %d, offset %d", partition, offset)
-		}
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "File uploaded successfully", "bucket_url": bucketURL})
+	if !slices.Contains(h.allowedTypes, model.ContentTypeVideoLink) {
+		writeError(w, r, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedType,
+			fmt.Sprintf("Traces must be one of %s", strings.Join(h.allowedTypes, ", "))))
+		return
+	}
+
+	var req model.CreateTraceLinkRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	linkURL, err := videoLinkURL(req.URL)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	course, err := h.repo.GetCourseByID(r.Context(), courseID)
+	if err != nil {
+		writeError(w, r, courseError(err, "Failed to fetch course details"))
+		return
+	}
+	if course.ArchivedAt != nil {
+		writeError(w, r, apierror.Conflict(apierror.CodeCourseArchived, "Course is archived; unarchive it to upload traces"))
+		return
+	}
+	instructor, err := h.repo.GetInstructorByID(r.Context(), course.InstructorID)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to fetch instructor details"))
+		return
+	}
+
+	// The pipeline fetches the video from the link where it would fetch an
+	// upload from the bucket
+	traceID := uuid.New()
+	messageBytes, err := model.PDFUploadEvent(traceID, *course, *instructor, linkURL, model.ContentTypeVideoLink, tenant.Slug(r.Context()))
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to insert trace record"))
+		return
+	}
+	trace, err := h.repo.InsertTraceWithEvent(r.Context(), repository.NewTrace{
+		ID:           traceID,
+		UserID:       user.ID,
+		InstructorID: course.InstructorID,
+		CourseID:     courseID,
+		Status:       "uploaded",
+		VectorID:     req.VectorID,
+		ContentType:  model.ContentTypeVideoLink,
+		Visibility:   req.Visibility,
+		LinkURL:      &linkURL,
+	}, model.TopicPDFUpload, messageBytes)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to insert trace record"))
+		return
+	}
+
+	h.outbox.Notify()
+
+	writeJSON(w, r, http.StatusCreated, map[string]string{
+		"message":        "Link added successfully",
+		"link_url":       linkURL,
+		"trace_id":       trace.ID.String(),
+		"publish_status": *trace.PublishStatus,
+	})
 }
 
-func (h *CourseHandler) uploadToGCS(file io.Reader, filename string) (string, error) {
-	ctx := context.Background()
-	bucket := h.gcsClient.Bucket(h.bucketName)
-	object := bucket.Object(filename)
+// videoLinkURL checks that raw is an absolute https URL without credentials
+// in it, the only kind of link a trace may point readers at, and returns it
+// normalized
+func videoLinkURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return "", apierror.Validation("url must be an https URL without credentials")
+	}
+	u.Fragment = ""
+	return u.String(), nil
+}
 
-	w := object.NewWriter(ctx)
-	if _, err := io.Copy(w, file); err != nil {
-		return "", err
+// ReplaceTraceFile uploads a new revision of a trace's file. The earlier
+// file stays in storage and is listed among the trace's revisions; the
+// trace is processed again from the new one.
+func (h *CourseHandler) ReplaceTraceFile(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
 	}
-	if err := w.Close(); err != nil {
-		return "", err
+
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
 
-	attrs, err := object.Attrs(ctx)
+	session, err := startUploadSession(r, h.uploads, user.ID)
 	if err != nil {
-		return "", err
+		writeError(w, r, err)
+		return
 	}
-	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", h.bucketName, attrs.Name), nil
+	defer session.Finish()
+	r.Body = session.Receive(r.Body)
+
+	if err := h.storage.Connect(r.Context()); err != nil {
+		h.handleStorageUnavailable(w, r, err)
+		return
+	}
+
+	err = r.ParseMultipartForm(10 << 20)
+	if err != nil {
+		if tooLarge := payloadTooLarge(err); tooLarge != nil {
+			writeError(w, r, tooLarge)
+			return
+		}
+		writeError(w, r, apierror.BadRequest(apierror.CodeInvalidRequestBody, "Failed to parse multipart form"))
+		return
+	}
+
+	var replaceReq model.ReplaceTraceFileRequest
+	file, header, err := r.FormFile("file")
+	if err == nil {
+		defer file.Close()
+		replaceReq.File = header.Filename
+	}
+	if err := validateRequest(&replaceReq); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	contentType, format, err := uploadFormat(file, header, h.allowedTypes)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	existing, err := h.repo.GetTraceByID(r.Context(), courseID, traceID)
+	if err != nil {
+		writeError(w, r, traceError(err, "Failed to get trace"))
+		return
+	}
+	if existing.LinkURL != nil {
+		writeError(w, r, apierror.Conflict(apierror.CodeTraceIsLink, "The trace is a video link and has no file to replace"))
+		return
+	}
+	course, err := h.repo.GetCourseByID(r.Context(), courseID)
+	if err != nil {
+		writeError(w, r, courseError(err, "Failed to fetch course details"))
+		return
+	}
+	if course.ArchivedAt != nil {
+		writeError(w, r, apierror.Conflict(apierror.CodeCourseArchived, "Course is archived; unarchive it to upload traces"))
+		return
+	}
+	instructor, err := h.repo.GetInstructorByID(r.Context(), course.InstructorID)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to fetch instructor details"))
+		return
+	}
+
+	// Earlier revisions are counted against the quotas until the trace is
+	// deleted, so the new file is charged in full
+	if err := h.repo.ChargeUpload(r.Context(), courseID, user.ID, header.Size, h.limits); err != nil {
+		writeError(w, r, quotaError(w, err, "Failed to record upload usage"))
+		return
+	}
+
+	// Each revision gets its own object, named after the trace and when it
+	// was uploaded, so the earlier ones are kept
+	suffix := fmt.Sprintf("_%s_%s", traceID, time.Now().UTC().Format("20060102T150405Z"))
+	objectName := tenant.ObjectName(r.Context(), traceFileName(course, instructor, suffix, format.Extension))
+	bucketURL, err := h.storage.Upload(r.Context(), objectName, session.Store(file, header.Size))
+	if err != nil {
+		log.Printf("GCS upload failed: %v", err)
+		h.notifier.Notify(notify.EventUploadFailed, "Trace upload failed",
+			"Uploading %s for course %s (tenant %s) to GCS failed: %v", objectName, courseID, tenant.Slug(r.Context()), err)
+		h.refundUpload(r, courseID, user.ID, header.Size)
+		writeError(w, r, apierror.New(http.StatusInternalServerError, apierror.CodeUploadFailed, "Failed to upload file to GCS"))
+		return
+	}
+
+	messageBytes, err := model.PDFUploadEvent(traceID, *course, *instructor, bucketURL, contentType, tenant.Slug(r.Context()))
+	if err != nil {
+		h.refundUpload(r, courseID, user.ID, header.Size)
+		writeError(w, r, internalError(err, "Failed to replace trace file"))
+		return
+	}
+	newFile := model.TraceFile{FileName: objectName, BucketURL: bucketURL, ContentType: contentType, SizeBytes: header.Size}
+	trace, err := h.repo.ReplaceTraceFile(r.Context(), courseID, traceID, newFile, user.ID, model.TopicPDFUpload, messageBytes)
+	if err != nil {
+		h.refundUpload(r, courseID, user.ID, header.Size)
+		writeError(w, r, traceError(err, "Failed to replace trace file"))
+		return
+	}
+
+	h.outbox.Notify()
+	session.Complete(trace.ID)
+	writeJSON(w, r, http.StatusOK, trace)
+}
+
+// ListTraceRevisions returns every file a trace has had, newest first
+func (h *CourseHandler) ListTraceRevisions(w http.ResponseWriter, r *http.Request) {
+	if err := authenticateScoped(r, h.repo, model.ScopeTraceRead); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	revisions, err := h.repo.ListTraceRevisions(r.Context(), courseID, traceID)
+	if err != nil {
+		writeError(w, r, traceError(err, "Failed to retrieve trace revisions"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"revisions": revisions})
 }
 
 func (h *CourseHandler) GetTracesByCourseID(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	// Authenticate user
-	_, err := h.authenticateRequest(w, r)
+	// Only the traces whose visibility lets the caller read them are listed
+	audience, err := traceAudience(r, h.repo)
 	if err != nil {
-		h.handleAuthError(w, err)
+		writeAuthError(w, r, err, courseRealm)
 		return
 	}
 
 	// Extract course_id from path parameters
-	courseIDStr := r.PathValue("course_id")
-	// Parse the course ID
-	courseID, err := uuid.Parse(courseIDStr)
+	courseID, err := pathUUID(r, "course_id")
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid course_id format"})
+		writeError(w, r, err)
+		return
+	}
+
+	// Parse pagination, sort and field selection parameters
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Parse the related resources to embed in each trace
+	expand, err := parseTraceExpand(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// Get traces from the database
-	traces, err := model.GetTracesByCourseID(h.db, courseID)
+	traces, err := h.repo.GetTracesByCourseID(r.Context(), courseID, audience, opts)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve traces"})
+		writeError(w, r, listError(err, "Failed to retrieve traces"))
+		return
+	}
+
+	// Embed the course and instructor of the whole page with one query
+	if err := h.repo.ExpandTraces(r.Context(), traces.Data, expand); err != nil {
+		writeError(w, r, internalError(err, "Failed to retrieve traces"))
 		return
 	}
 
-	// Return the traces as JSON
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{"data": traces})
+	// Expanded resources are kept even when ?fields= leaves them out
+	fields := opts.Fields
+	if len(fields) > 0 {
+		fields = append(fields, expand.Names()...)
+	}
+
+	// Return the page of traces as JSON, or 304 if the client's copy is current
+	writeCacheable(w, r, time.Time{}, pageBody(r, traces, fields))
 }
 
-func (h *CourseHandler) GetTraceByID(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// ExportTraces streams all of a course's traces as one JSON array
+func (h *CourseHandler) ExportTraces(w http.ResponseWriter, r *http.Request) {
+	// Only the traces whose visibility lets the caller read them are exported
+	audience, err := traceAudience(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
 
-	// Authenticate user
-	user, err := h.authenticateRequest(w, r)
+	// Extract course_id from path parameters
+	courseID, err := pathUUID(r, "course_id")
 	if err != nil {
-		h.handleAuthError(w, err)
+		writeError(w, r, err)
 		return
 	}
 
-	// Check admin privileges
-	if user.Role != "admin" {
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Insufficient permissions"})
+	// Parse sort and field selection parameters; paging doesn't apply
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Extract course_id and trace_id from path parameters
-	courseIDStr := r.PathValue("course_id")
-	traceIDStr := r.PathValue("trace_id")
+	streamList(w, r, opts.Fields, "Failed to export traces", func(fn func(model.Trace) error) error {
+		return h.repo.StreamTracesByCourseID(r.Context(), courseID, audience, opts, fn)
+	})
+}
 
-	// Parse the course ID
-	courseID, err := uuid.Parse(courseIDStr)
+func (h *CourseHandler) GetTraceByID(w http.ResponseWriter, r *http.Request) {
+	// Parse the related resources to embed in the trace
+	expand, err := parseTraceExpand(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid course_id format"})
+		writeError(w, r, err)
 		return
 	}
 
-	// Parse the trace ID
-	traceID, err := uuid.Parse(traceIDStr)
+	// Get the trace, if its visibility lets the caller read it
+	trace, err := readableTrace(r, h.repo, "Failed to retrieve trace")
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid trace_id format"})
+		writeAuthError(w, r, err, courseRealm)
 		return
 	}
 
-	// Get trace from the database
-	trace, err := model.GetTraceByID(h.db, courseID, traceID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Trace not found"})
-			return
-		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve trace"})
+	traces := []model.Trace{*trace}
+	if err := h.repo.ExpandTraces(r.Context(), traces, expand); err != nil {
+		writeError(w, r, internalError(err, "Failed to retrieve trace"))
 		return
 	}
+	trace = &traces[0]
+
+	// The response changes when an embedded resource does
+	lastModified := trace.DateUpdated
+	if trace.Course != nil && trace.Course.DateUpdated.After(lastModified) {
+		lastModified = trace.Course.DateUpdated
+	}
+	if trace.Instructor != nil && trace.Instructor.DateUpdated.After(lastModified) {
+		lastModified = trace.Instructor.DateUpdated
+	}
 
-	// Return the trace as JSON
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(trace)
+	// Return the trace as JSON, or 304 if the client's copy is current
+	writeCacheable(w, r, lastModified, envelope(r, trace))
 }
 
 func (h *CourseHandler) DeleteTraceByID(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
 	// Authenticate user
-	_, err := h.authenticateRequest(w, r)
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	// Extract course_id and trace_id from path parameters
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Delete the trace from the database
+	if err := h.repo.DeleteTraceByID(r.Context(), courseID, traceID); err != nil {
+		writeError(w, r, traceError(err, "Failed to delete trace"))
+		return
+	}
+
+	// Return success response
+	writeDeleted(w, r, "Trace deleted successfully")
+}
+
+// UpdateTraceStatus records the processing result for a trace. It is how
+// the PDF-processing pipeline writes back, with a service account key
+// scoped to trace:status:update.
+func (h *CourseHandler) UpdateTraceStatus(w http.ResponseWriter, r *http.Request) {
+	// Authenticate an admin or a service account allowed to update statuses
+	if err := authenticateScoped(r, h.repo, model.ScopeTraceStatusUpdate); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	// Extract course_id and trace_id from path parameters
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req model.UpdateTraceStatusRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Update the trace in the database
+	trace, err := h.repo.UpdateTraceStatus(r.Context(), courseID, traceID, req)
+	if err != nil {
+		writeError(w, r, traceError(err, "Failed to update trace status"))
+		return
+	}
+	if trace.Status == "processed" {
+		h.emailTraceProcessed(r, courseID, trace)
+	}
+
+	// Return the updated trace
+	writeJSON(w, r, http.StatusOK, trace)
+}
+
+// UpdateTraceVisibility sets who may read a trace and download its file
+func (h *CourseHandler) UpdateTraceVisibility(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
 	if err != nil {
-		h.handleAuthError(w, err)
+		writeAuthError(w, r, err, courseRealm)
 		return
 	}
 
 	// Extract course_id and trace_id from path parameters
-	courseIDStr := r.PathValue("course_id")
-	traceIDStr := r.PathValue("trace_id")
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 
-	// Parse the course ID
-	courseID, err := uuid.Parse(courseIDStr)
+	var req model.UpdateTraceVisibilityRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	trace, err := h.repo.UpdateTraceVisibility(r.Context(), courseID, traceID, req.Visibility, user.ID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid course_id format"})
+		writeError(w, r, traceError(err, "Failed to update trace visibility"))
 		return
 	}
+	writeJSON(w, r, http.StatusOK, trace)
+}
 
-	// Parse the trace ID
-	traceID, err := uuid.Parse(traceIDStr)
+// emailTraceProcessed tells the trace's uploader it is ready. The email is
+// best effort, so lookup failures are only logged.
+func (h *CourseHandler) emailTraceProcessed(r *http.Request, courseID uuid.UUID, trace *model.Trace) {
+	user, err := h.repo.GetUser(r.Context(), trace.UserID)
+	if err != nil {
+		log.Printf("Failed to look up uploader of trace %s: %v", trace.ID, err)
+		return
+	}
+	course, err := h.repo.GetCourseByID(r.Context(), courseID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid trace_id format"})
+		log.Printf("Failed to look up course of trace %s: %v", trace.ID, err)
 		return
 	}
+	h.mailer.Send(r.Context(), user, model.EmailTraceProcessed, map[string]any{
+		"CourseName": course.Name,
+		"FileName":   traceName(trace),
+	})
+}
 
-	// Delete the trace from the database
-	err = model.DeleteTraceByID(h.db, courseID, traceID)
+// traceName is what a trace is called in messages to people: its file's
+// name, or the video it links to
+func traceName(trace *model.Trace) string {
+	if trace.LinkURL != nil {
+		return *trace.LinkURL
+	}
+	return path.Base(trace.FileName)
+}
+
+// RestoreTrace moves an archived trace back to standard storage
+func (h *CourseHandler) RestoreTrace(w http.ResponseWriter, r *http.Request) {
+	// Authenticate user
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	// Extract course_id and trace_id from path parameters
+	courseID, traceID, err := tracePath(r)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Trace not found"})
+		writeError(w, r, err)
+		return
+	}
+
+	// Restore the trace to standard storage
+	trace, err := h.lifecycle.Restore(r.Context(), courseID, traceID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUnavailable) {
+			h.handleStorageUnavailable(w, r, err)
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete trace"})
+		writeError(w, r, traceError(err, "Failed to restore trace"))
 		return
 	}
 
-	// Return success response
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Trace deleted successfully"})
+	// Return the restored trace
+	writeJSON(w, r, http.StatusOK, trace)
+}
+
+// tracePath parses the course_id and trace_id path parameters
+func tracePath(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	traceID, err := pathUUID(r, "trace_id")
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return courseID, traceID, nil
+}
+
+// parseExpand reads ?expand=, a comma-separated list of related resources
+// to embed, each of which must be one of allowed
+func parseExpand(r *http.Request, allowed ...string) (map[string]bool, error) {
+	expand := map[string]bool{}
+	expandStr := r.URL.Query().Get("expand")
+	if expandStr == "" {
+		return expand, nil
+	}
+	for _, name := range strings.Split(expandStr, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(allowed, name) {
+			return nil, apierror.BadRequest(apierror.CodeInvalidQuery, fmt.Sprintf("cannot expand %q", name))
+		}
+		expand[name] = true
+	}
+	return expand, nil
+}
+
+// parseTraceExpand reads the resources to embed in traces: course and instructor
+func parseTraceExpand(r *http.Request) (model.TraceExpand, error) {
+	expand, err := parseExpand(r, model.ExpandCourse, model.ExpandInstructor)
+	if err != nil {
+		return model.TraceExpand{}, err
+	}
+	return model.TraceExpand{Course: expand[model.ExpandCourse], Instructor: expand[model.ExpandInstructor]}, nil
+}
+
+// parseCourseExpand reads the resources to embed in a course: instructor and
+// traces, a summary of its traces
+func parseCourseExpand(r *http.Request) (model.CourseExpand, error) {
+	expand, err := parseExpand(r, model.ExpandInstructor, model.ExpandCourseTraces)
+	if err != nil {
+		return model.CourseExpand{}, err
+	}
+	return model.CourseExpand{Instructor: expand[model.ExpandInstructor], Traces: expand[model.ExpandCourseTraces]}, nil
+}
+
+// refundUpload gives back usage charged for an upload that wasn't recorded
+func (h *CourseHandler) refundUpload(r *http.Request, courseID, userID uuid.UUID, sizeBytes int64) {
+	if err := h.repo.RefundUpload(context.WithoutCancel(r.Context()), courseID, userID, sizeBytes); err != nil {
+		log.Printf("Failed to refund upload usage: %v", err)
+	}
+}
+
+// courseError maps model.ErrNotFound to COURSE_NOT_FOUND and anything else to a 500
+func courseError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeCourseNotFound, "Course not found")
+	}
+	return internalError(err, message)
+}
+
+// traceError maps model.ErrNotFound to TRACE_NOT_FOUND and anything else to a 500
+func traceError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeTraceNotFound, "Trace not found")
+	}
+	return internalError(err, message)
+}
+
+// uploadFormat returns the MIME type of an uploaded file, if it is one of
+// allowed, and how it is stored. The type is the one the multipart part
+// declares or, for a generic one, the one the file's extension implies, and
+// must agree with the file's first bytes so a renamed file isn't taken for
+// something it isn't. The file is left rewound.
+func uploadFormat(file multipart.File, header *multipart.FileHeader, allowed []string) (string, model.UploadFormat, error) {
+	contentType, _, _ := mime.ParseMediaType(header.Header.Get("Content-Type"))
+	if contentType == "" || contentType == "application/octet-stream" {
+		ext := strings.ToLower(path.Ext(header.Filename))
+		for t, format := range model.UploadFormats {
+			if format.Extension == ext {
+				contentType = t
+				break
+			}
+		}
+	}
+	format, known := model.UploadFormats[contentType]
+	if !known || !slices.Contains(allowed, contentType) {
+		return "", model.UploadFormat{}, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedType,
+			fmt.Sprintf("File type must be one of %s", strings.Join(allowed, ", ")))
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", model.UploadFormat{}, apierror.BadRequest(apierror.CodeInvalidRequestBody, "Failed to read uploaded file")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", model.UploadFormat{}, apierror.BadRequest(apierror.CodeInvalidRequestBody, "Failed to read uploaded file")
+	}
+	if sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n])); sniffed != format.Sniffed {
+		return "", model.UploadFormat{}, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedType,
+			fmt.Sprintf("File content is not %s", contentType))
+	}
+	return contentType, format, nil
+}
+
+// traceFileName is the object name of a trace of course, taught by
+// instructor, with suffix before the extension
+func traceFileName(course *model.Course, instructor *model.Instructor, suffix, extension string) string {
+	return fmt.Sprintf(
+		"%s_%s_%s_%d_%s_%d%s%s",
+		sanitizeFilename(course.Name),
+		sanitizeFilename(instructor.Name),
+		course.SubjectCode,
+		course.CourseID,
+		course.SemesterTerm,
+		course.SemesterYear,
+		suffix,
+		extension,
+	)
 }
 
 // sanitizeFilename removes spaces and special characters, replacing with underscores or nothing.
@@ -0,0 +1,197 @@
+// internal/handler/course_bench_test.go
+package handler_test
+
+import (
+	"api-server/internal/app"
+	"api-server/internal/config"
+	"api-server/internal/model"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// benchCourses is how many courses, and traces of the benchmarked course,
+// the listings page through
+const benchCourses = 200
+
+// benchEnv is the in-memory server with an admin, a bearer token for them
+// and a course to upload to
+type benchEnv struct {
+	srv      *app.Server
+	token    string
+	courseID uuid.UUID
+}
+
+// newBenchEnv builds the server the serve command would in in-memory mode,
+// without OpenAPI validation. The benchmarks on it measure handler overhead
+// only: routing, middleware, authentication, encoding and the in-memory
+// repository. They never reach Postgres; the queries behind these endpoints,
+// on pgxpool against a database/sql baseline, are benchmarked in
+// internal/model.
+func newBenchEnv(b *testing.B) *benchEnv {
+	b.Helper()
+	// Request logs would dominate the output
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	cfg := config.NewConfig()
+	cfg.Mode = config.ModeInMemory
+	cfg.DebugAddr = ""
+	cfg.LifecycleEnabled = false
+	cfg.AuthTokenSecret = "benchmark-secret-0123456789abcdef"
+	srv, err := app.New(context.Background(), cfg)
+	if err != nil {
+		b.Fatalf("failed to build server: %v", err)
+	}
+	b.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	admin, err := srv.Repo.CreateUser(ctx, model.CreateUserRequest{
+		FirstName: "Bench",
+		Username:  "bench",
+		Password:  "bench-password",
+		Role:      "admin",
+		Email:     "bench@example.edu",
+	})
+	if err != nil {
+		b.Fatalf("failed to create admin: %v", err)
+	}
+	instructor, err := srv.Repo.CreateInstructor(ctx, model.CreateInstructorRequest{Name: "Ada Lovelace", Email: "ada@example.edu"}, admin.ID)
+	if err != nil {
+		b.Fatalf("failed to create instructor: %v", err)
+	}
+	var course *model.Course
+	for i := range benchCourses {
+		course, err = srv.Repo.CreateCourse(ctx, model.CreateCourseRequest{
+			Name:         fmt.Sprintf("Course %d", i),
+			SemesterTerm: "Fall",
+			CreditHours:  4,
+			SubjectCode:  "CSYE",
+			CourseID:     6000 + i,
+			SemesterYear: 2025,
+			InstructorID: instructor.ID,
+		}, admin.ID)
+		if err != nil {
+			b.Fatalf("failed to create course: %v", err)
+		}
+	}
+
+	// A bearer token, so requests don't each pay for bcrypt
+	env := &benchEnv{srv: srv, courseID: course.ID}
+	rec := env.serve(http.MethodPost, "/v1/auth/token", "application/json",
+		strings.NewReader(`{"username":"bench","password":"bench-password"}`))
+	if rec.Code != http.StatusOK {
+		b.Fatalf("login: status %d: %s", rec.Code, rec.Body)
+	}
+	var token model.AuthToken
+	if err := json.Unmarshal(rec.Body.Bytes(), &token); err != nil {
+		b.Fatalf("failed to decode token: %v", err)
+	}
+	env.token = token.AccessToken
+	return env
+}
+
+func (e *benchEnv) serve(method, path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if e.token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.token)
+	}
+	rec := httptest.NewRecorder()
+	e.srv.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// uploadForm is a multipart trace upload of a small PDF
+func uploadForm(b *testing.B) ([]byte, string) {
+	b.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "lecture.pdf")
+	if err != nil {
+		b.Fatalf("failed to build upload form: %v", err)
+	}
+	part.Write([]byte("%PDF-1.4\n" + strings.Repeat("0", 16<<10) + "\n%%EOF\n"))
+	form.Close()
+	return body.Bytes(), form.FormDataContentType()
+}
+
+func BenchmarkListCourses(b *testing.B) {
+	env := newBenchEnv(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if rec := env.serve(http.MethodGet, "/v1/course?limit=20", "", nil); rec.Code != http.StatusOK {
+				b.Errorf("list courses: status %d: %s", rec.Code, rec.Body)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkGetCourse(b *testing.B) {
+	env := newBenchEnv(b)
+	path := "/v1/course/" + env.courseID.String()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if rec := env.serve(http.MethodGet, path, "", nil); rec.Code != http.StatusOK {
+				b.Errorf("get course: status %d: %s", rec.Code, rec.Body)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkHandleTraceUpload(b *testing.B) {
+	env := newBenchEnv(b)
+	body, contentType := uploadForm(b)
+	path := fmt.Sprintf("/v1/course/%s/trace", env.courseID)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if rec := env.serve(http.MethodPost, path, contentType, bytes.NewReader(body)); rec.Code != http.StatusCreated {
+				b.Errorf("upload: status %d: %s", rec.Code, rec.Body)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkGetTracesByCourseID(b *testing.B) {
+	env := newBenchEnv(b)
+	body, contentType := uploadForm(b)
+	path := fmt.Sprintf("/v1/course/%s/trace", env.courseID)
+	for range benchCourses {
+		if rec := env.serve(http.MethodPost, path, contentType, bytes.NewReader(body)); rec.Code != http.StatusCreated {
+			b.Fatalf("upload: status %d: %s", rec.Code, rec.Body)
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if rec := env.serve(http.MethodGet, path+"?limit=20", "", nil); rec.Code != http.StatusOK {
+				b.Errorf("list traces: status %d: %s", rec.Code, rec.Body)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,55 @@
+// internal/handler/debug.go
+package handler
+
+import (
+	"api-server/internal/auth"
+	"api-server/internal/config"
+	"api-server/internal/repository"
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+)
+
+const debugRealm = "Debug Authentication Required"
+
+// NewDebugRouter serves the runtime profiling endpoints: /debug/pprof/,
+// /debug/vars and a full goroutine dump on /debug/goroutines. It is meant for
+// a separate, non-public listener. With DEBUG_REQUIRE_ADMIN set every request
+// must come from an admin, authenticated as on the API: by the AUTH_BACKEND
+// or a bearer token, with admins who enabled MFA held to bearer tokens and
+// MFA_REQUIRED_FOR_ADMINS applied. Otherwise access is left to network policy.
+func NewDebugRouter(cfg *config.Config, repo repository.Repository) (http.Handler, error) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("GET /debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		// debug=2 prints every goroutine's full stack, as on an unrecovered panic
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := runtimepprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+			log.Printf("Failed to write goroutine dump: %v", err)
+		}
+	})
+
+	if !cfg.DebugRequireAdmin {
+		return mux, nil
+	}
+	authn, err := auth.NewPasswordAuthenticator(cfg, repo)
+	if err != nil {
+		return nil, err
+	}
+	authenticated := authenticateRequest(authn, repo, auth.NewTokens(cfg), cfg.MFARequiredForAdmins)
+	return authenticated(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := authenticateAdmin(r, repo); err != nil {
+			writeAuthError(w, r, err, debugRealm)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})), nil
+}
@@ -0,0 +1,110 @@
+// internal/handler/debug_test.go
+package handler_test
+
+import (
+	"api-server/internal/app"
+	"api-server/internal/auth"
+	"api-server/internal/config"
+	"api-server/internal/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// TestDebugRouterAuthentication checks that the profiling endpoints admit
+// admins the way the API does, so an admin who enabled MFA can't get in
+// with just their password
+func TestDebugRouterAuthentication(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Mode = config.ModeInMemory
+	cfg.DebugAddr = ""
+	cfg.LifecycleEnabled = false
+	cfg.AuthTokenSecret = "debug-test-secret-0123456789abcdef"
+	srv, err := app.New(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("failed to build server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	const password = "debug-password"
+	createUser := func(username, role string) *model.User {
+		user, err := srv.Repo.CreateUser(ctx, model.CreateUserRequest{
+			FirstName: "Debug",
+			Username:  username,
+			Password:  password,
+			Role:      role,
+			Email:     username + "@example.edu",
+		})
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", username, err)
+		}
+		return user
+	}
+	createUser("admin", "admin")
+	createUser("student", "student")
+	mfaAdmin := createUser("mfa-admin", "admin")
+	if _, err := srv.Repo.SaveMFAEnrollment(ctx, mfaAdmin.ID, "JBSWY3DPEHPK3PXP", nil); err != nil {
+		t.Fatalf("failed to enroll MFA: %v", err)
+	}
+	// The first code used confirms the enrollment
+	if err := srv.Repo.UseMFAStep(ctx, mfaAdmin.ID, 1); err != nil {
+		t.Fatalf("failed to enable MFA: %v", err)
+	}
+
+	// The bearer token the MFA admin would get by giving a TOTP code
+	sessionID := uuid.New()
+	token, expiresAt, err := auth.NewTokens(cfg).Issue(sessionID, mfaAdmin.ID, model.DefaultTenantID)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	if _, err := srv.Repo.CreateSession(ctx, model.NewSession(sessionID, mfaAdmin.ID, "", "", expiresAt)); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		username   string
+		bearer     string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "no credentials", wantStatus: http.StatusUnauthorized, wantCode: "AUTHENTICATION_REQUIRED"},
+		{name: "admin", username: "admin", wantStatus: http.StatusOK},
+		{name: "student", username: "student", wantStatus: http.StatusForbidden, wantCode: "INSUFFICIENT_PERMISSIONS"},
+		{name: "MFA admin with password", username: "mfa-admin", wantStatus: http.StatusUnauthorized, wantCode: "MFA_REQUIRED"},
+		{name: "MFA admin with bearer token", bearer: token, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+			if tt.username != "" {
+				req.SetBasicAuth(tt.username, password)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			rec := httptest.NewRecorder()
+			srv.DebugHandler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			var envelope struct {
+				Error struct {
+					Code string `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil || envelope.Error.Code != tt.wantCode {
+				t.Errorf("error code %q, want %s: %s", envelope.Error.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// internal/handler/download.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+
+	"github.com/google/uuid"
+)
+
+// DownloadHandler serves the stored files of traces, to callers their
+// visibility allows and to anyone holding a share link, and records each
+// download in the trace's access log
+type DownloadHandler struct {
+	repo    repository.Repository
+	storage storage.Storage
+}
+
+func NewDownloadHandler(repo repository.Repository, store storage.Storage) *DownloadHandler {
+	return &DownloadHandler{repo: repo, storage: store}
+}
+
+// DownloadTrace streams a trace's file, or redirects to its video link, if
+// the caller may read the trace
+func (h *DownloadHandler) DownloadTrace(w http.ResponseWriter, r *http.Request) {
+	trace, err := readableTrace(r, h.repo, "Failed to download trace")
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	h.serveFile(w, r, trace, traceAccess(r, trace.ID, model.AccessDownload))
+}
+
+// DownloadShared streams the file of the trace a share link opens. It needs
+// no credentials: the token in the path is the permission.
+func (h *DownloadHandler) DownloadShared(w http.ResponseWriter, r *http.Request) {
+	trace, shareID, err := h.repo.GetSharedTrace(r.Context(), r.PathValue("token"))
+	if errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, apierror.NotFound(apierror.CodeShareLinkNotFound, "Share link not found, expired or revoked"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to open share link"))
+		return
+	}
+	access := traceAccess(r, trace.ID, model.AccessSharedDownload)
+	access.ShareID = &shareID
+	h.serveFile(w, r, trace, access)
+}
+
+// ListAccess returns a page of a trace's access log, newest first
+func (h *DownloadHandler) ListAccess(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	entries, err := h.repo.ListTraceAccess(r.Context(), courseID, traceID, opts)
+	if errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, traceError(err, "Failed to retrieve access log"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, listError(err, "Failed to retrieve access log"))
+		return
+	}
+	writePage(w, r, entries, opts.Fields)
+}
+
+// serveFile streams a trace's file from storage as an attachment named
+// after the stored object, or redirects to the video a link trace points
+// at. The download is recorded once the file is opened, and refused if it
+// can't be, so none goes unlogged.
+func (h *DownloadHandler) serveFile(w http.ResponseWriter, r *http.Request, trace *model.Trace, access model.TraceAccess) {
+	// A video link is followed rather than streamed, once it is logged
+	if trace.LinkURL != nil {
+		if _, err := h.repo.RecordTraceAccess(r.Context(), access); err != nil {
+			writeError(w, r, internalError(err, "Failed to record download"))
+			return
+		}
+		http.Redirect(w, r, *trace.LinkURL, http.StatusFound)
+		return
+	}
+	if trace.Status == "failed" || trace.BucketURL == "" {
+		writeError(w, r, apierror.NotFound(apierror.CodeTraceNotFound, "The trace has no stored file"))
+		return
+	}
+
+	file, err := h.storage.Download(r.Context(), trace.FileName)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, r, apierror.NotFound(apierror.CodeTraceNotFound, "The trace has no stored file"))
+		return
+	}
+	if errors.Is(err, storage.ErrUnavailable) {
+		w.Header().Set("Retry-After", "30")
+		writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeStorageUnavailable, "Storage is temporarily unavailable"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to download trace"))
+		return
+	}
+	defer file.Close()
+
+	if _, err := h.repo.RecordTraceAccess(r.Context(), access); err != nil {
+		writeError(w, r, internalError(err, "Failed to record download"))
+		return
+	}
+
+	w.Header().Set("Content-Type", trace.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(trace.FileName)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("Failed to send file of trace %s: %v", trace.ID, err)
+	}
+}
+
+// traceAccess is an access of a trace by the request's caller, from its
+// client address and user agent
+func traceAccess(r *http.Request, traceID uuid.UUID, action string) model.TraceAccess {
+	access := model.NewTraceAccess(traceID, action, r.UserAgent(), remoteIP(r))
+	if res, ok := r.Context().Value(authKey{}).(*authResult); ok {
+		if res.user != nil {
+			access.UserID = &res.user.ID
+		}
+		if res.account != nil {
+			access.ServiceAccountID = &res.account.ID
+		}
+	}
+	return access
+}
@@ -0,0 +1,255 @@
+// internal/handler/embedding.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/embedding"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Bounds on similarity search and semantic search
+const (
+	defaultSimilarLimit = 5
+	maxSimilarLimit     = 20
+	defaultSearchLimit  = 10
+	maxSearchLimit      = 50
+	maxQueryLen         = 1000
+	snippetLen          = 200
+)
+
+// EmbeddingHandler takes trace embeddings from the processing pipeline and
+// searches traces by meaning, within a course or across all of them
+type EmbeddingHandler struct {
+	repo repository.Repository
+	// embedder is nil when EMBEDDING_BACKEND is none
+	embedder   embedding.Embedder
+	dimensions int
+}
+
+func NewEmbeddingHandler(repo repository.Repository, embedder embedding.Embedder, dimensions int) *EmbeddingHandler {
+	return &EmbeddingHandler{repo: repo, embedder: embedder, dimensions: dimensions}
+}
+
+// UpdateTrace stores a trace's vector ID and embedding. It is how the
+// processing pipeline writes vectors back, with a service account key
+// scoped to trace:embedding:update.
+func (h *EmbeddingHandler) UpdateTrace(w http.ResponseWriter, r *http.Request) {
+	if err := authenticateScoped(r, h.repo, model.ScopeTraceEmbeddingUpdate); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req model.UpdateTraceRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if req.Embedding != nil {
+		if len(req.Embedding) != h.dimensions {
+			writeError(w, r, apierror.Validation(fmt.Sprintf("embedding must have %d dimensions, got %d", h.dimensions, len(req.Embedding))))
+			return
+		}
+		// A zero vector has no direction, so its cosine distance is undefined
+		if !hasNonZero(req.Embedding) {
+			writeError(w, r, apierror.Validation("embedding must not be all zeros"))
+			return
+		}
+	}
+
+	trace, err := h.repo.UpdateTraceEmbedding(r.Context(), courseID, traceID, req)
+	if err != nil {
+		writeError(w, r, traceError(err, "Failed to update trace"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, trace)
+}
+
+// SimilarTraces embeds ?text= and returns the course's traces nearest to
+// it, closest first. ?limit= caps the results.
+func (h *EmbeddingHandler) SimilarTraces(w http.ResponseWriter, r *http.Request) {
+	if err := authenticateScoped(r, h.repo, model.ScopeTraceRead); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	text, err := searchText(query, "text")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	limit, err := searchLimit(query, defaultSimilarLimit, maxSimilarLimit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	vector, ok := h.embed(w, r, text)
+	if !ok {
+		return
+	}
+
+	traces, err := h.repo.SimilarTraces(r.Context(), courseID, vector, limit)
+	if err != nil {
+		writeError(w, r, courseError(err, "Failed to search traces"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"traces": traces})
+}
+
+// Search embeds ?q= and returns the tenant's processed traces nearest to
+// it, closest first, each with its course and a snippet of the text the
+// pipeline embedded. subject_code, semester_term, semester_year and
+// instructor_id narrow the courses searched.
+func (h *EmbeddingHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if err := authenticateScoped(r, h.repo, model.ScopeTraceRead); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	query := r.URL.Query()
+	text, err := searchText(query, "q")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	limit, err := searchLimit(query, defaultSearchLimit, maxSearchLimit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	filters, err := searchFilters(query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	vector, ok := h.embed(w, r, text)
+	if !ok {
+		return
+	}
+
+	results, err := h.repo.SearchTraces(r.Context(), vector, filters, limit)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to search traces"))
+		return
+	}
+	for i := range results {
+		results[i].Snippet = model.Snippet(results[i].Excerpt, text, snippetLen)
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"results": results})
+}
+
+// embed turns the search text into a vector, writing a 503 when no
+// embedding backend is configured or it fails
+func (h *EmbeddingHandler) embed(w http.ResponseWriter, r *http.Request, text string) ([]float32, bool) {
+	if h.embedder == nil {
+		writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeEmbeddingUnavailable, "Similarity search is not configured"))
+		return nil, false
+	}
+	vector, err := h.embedder.Embed(r.Context(), text)
+	if err != nil {
+		log.Printf("Failed to embed search text via %s: %v", h.embedder.Name(), err)
+		w.Header().Set("Retry-After", "30")
+		writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeEmbeddingUnavailable, "Similarity search is temporarily unavailable").WithCause(err))
+		return nil, false
+	}
+	return vector, true
+}
+
+// searchText reads the required search text from the named parameter
+func searchText(query url.Values, name string) (string, error) {
+	text := strings.TrimSpace(query.Get(name))
+	if text == "" || len(text) > maxQueryLen {
+		return "", apierror.BadRequest(apierror.CodeInvalidQuery, fmt.Sprintf("%s is required and must be at most %d bytes", name, maxQueryLen))
+	}
+	return text, nil
+}
+
+// searchLimit reads ?limit=, between 1 and maxLimit
+func searchLimit(query url.Values, defaultLimit, maxLimit int) (int, error) {
+	raw := query.Get("limit")
+	if raw == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxLimit {
+		return 0, apierror.BadRequest(apierror.CodeInvalidQuery, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+	}
+	return limit, nil
+}
+
+// searchFilters reads the course metadata filters of a search
+func searchFilters(query url.Values) (model.SearchFilters, error) {
+	var filters model.SearchFilters
+	if code := query.Get("subject_code"); code != "" {
+		filters.SubjectCode = &code
+	}
+	if term := query.Get("semester_term"); term != "" {
+		if !slices.Contains([]string{"Fall", "Spring", "Summer"}, term) {
+			return filters, apierror.BadRequest(apierror.CodeInvalidQuery, "semester_term must be Fall, Spring or Summer")
+		}
+		filters.SemesterTerm = &term
+	}
+	if raw := query.Get("semester_year"); raw != "" {
+		year, err := strconv.Atoi(raw)
+		if err != nil {
+			return filters, apierror.BadRequest(apierror.CodeInvalidQuery, "semester_year must be a year")
+		}
+		filters.SemesterYear = &year
+	}
+	instructorID, err := queryUUID(query, "instructor_id")
+	if err != nil {
+		return filters, err
+	}
+	filters.InstructorID = instructorID
+	return filters, nil
+}
+
+// queryUUID reads an optional UUID query parameter
+func queryUUID(query url.Values, name string) (*uuid.UUID, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, apierror.BadRequest(apierror.CodeInvalidQuery, fmt.Sprintf("%s must be a UUID", name))
+	}
+	return &id, nil
+}
+
+func hasNonZero(v []float32) bool {
+	for _, x := range v {
+		if x != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+// internal/handler/favorite.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"errors"
+	"net/http"
+)
+
+// FavoriteHandler keeps the caller's shortlist of saved courses
+type FavoriteHandler struct {
+	repo repository.Repository
+}
+
+func NewFavoriteHandler(repo repository.Repository) *FavoriteHandler {
+	return &FavoriteHandler{repo: repo}
+}
+
+// ListFavorites returns a page of the courses on the caller's shortlist
+func (h *FavoriteHandler) ListFavorites(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	courses, err := h.repo.ListFavorites(r.Context(), user.ID, opts)
+	if err != nil {
+		writeError(w, r, listError(err, "Failed to retrieve favorites"))
+		return
+	}
+	writePage(w, r, courses, opts.Fields)
+}
+
+// AddFavorite saves a course to the caller's shortlist. It answers 201 when
+// the course is newly added and 200 when it was already there.
+func (h *FavoriteHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	course, added, err := h.repo.AddFavorite(r.Context(), user.ID, courseID)
+	if err != nil {
+		writeError(w, r, courseError(err, "Failed to add favorite"))
+		return
+	}
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	writeJSON(w, r, status, course)
+}
+
+// RemoveFavorite takes a course off the caller's shortlist
+func (h *FavoriteHandler) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.repo.RemoveFavorite(r.Context(), user.ID, courseID)
+	if errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, apierror.NotFound(apierror.CodeFavoriteNotFound, "Course is not a favorite"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to remove favorite"))
+		return
+	}
+	writeDeleted(w, r, "Favorite removed successfully")
+}
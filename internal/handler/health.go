@@ -2,18 +2,25 @@
 package handler
 
 import (
-	"api-server/internal/model"
-	"database/sql"
+	"api-server/internal/apierror"
+	"api-server/internal/publisher"
+	"api-server/internal/repository"
+	"api-server/internal/response"
+	"api-server/internal/router"
+	"api-server/internal/storage"
+	"context"
 	"io"
+	"log"
 	"net/http"
+	"time"
 )
 
 type HealthHandler struct {
-	db *sql.DB
+	repo repository.Repository
 }
 
-func NewHealthHandler(db *sql.DB) *HealthHandler {
-	return &HealthHandler{db: db}
+func NewHealthHandler(repo repository.Repository) *HealthHandler {
+	return &HealthHandler{repo: repo}
 }
 
 func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +43,7 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check for any path parameters
-	if r.URL.Path != "/healthz" {
+	if r.URL.Path != router.Route(r) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -53,7 +60,7 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Insert health check record
-	err = model.InsertHealthCheck(h.db)
+	err = h.repo.InsertHealthCheck(r.Context())
 	if err != nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		return
@@ -61,3 +68,75 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// LiveHandler reports the process is up without touching any dependency,
+// so a liveness probe doesn't restart a pod that is only waiting on one
+type LiveHandler struct{}
+
+func (LiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// NewStartingRouter serves while the server waits on Postgres and Kafka at
+// startup: /livez answers 200 and everything else 503 SERVICE_STARTING
+func NewStartingRouter() http.Handler {
+	mux := http.NewServeMux()
+	for _, prefix := range []string{"/internal", ""} {
+		mux.Handle(prefix+"/livez", LiveHandler{})
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeServiceStarting, "The server is waiting on its dependencies"))
+	})
+	return mux
+}
+
+type ReadyHandler struct {
+	repo      repository.Repository
+	storage   storage.Storage
+	publisher publisher.Publisher
+}
+
+func NewReadyHandler(repo repository.Repository, store storage.Storage, pub publisher.Publisher) *ReadyHandler {
+	return &ReadyHandler{repo: repo, storage: store, publisher: pub}
+}
+
+// ServeHTTP reports whether each dependency is reachable. Storage being down
+// only degrades trace endpoints, and events wait in the outbox while Kafka
+// is down, so both are reported without failing readiness.
+func (h *ReadyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	status := http.StatusOK
+	checks := map[string]string{"database": "ok", "storage": "ok", "kafka": "ok"}
+
+	if err := h.repo.Ping(ctx); err != nil {
+		log.Printf("Readiness check: database unavailable: %v", err)
+		checks["database"] = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+	if err := h.storage.Ping(ctx); err != nil {
+		log.Printf("Readiness check: storage unavailable: %v", err)
+		checks["storage"] = "degraded"
+	}
+	if err := h.publisher.Ping(ctx); err != nil {
+		log.Printf("Readiness check: Kafka unavailable: %v", err)
+		checks["kafka"] = "degraded"
+	}
+
+	response.WriteJSON(w, status, checks)
+}
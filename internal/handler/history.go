@@ -0,0 +1,149 @@
+// internal/handler/history.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// CourseHistoryHandler serves the versions a course has been through, what
+// changed in each and who changed it, and the events of the course and its
+// traces, and puts a course back to an earlier version
+type CourseHistoryHandler struct {
+	repo repository.Repository
+}
+
+func NewCourseHistoryHandler(repo repository.Repository) *CourseHistoryHandler {
+	return &CourseHistoryHandler{repo: repo}
+}
+
+// ListVersions returns a page of a course's versions, newest first
+func (h *CourseHistoryHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, err := h.repo.GetCourseByID(r.Context(), courseID); err != nil {
+		writeError(w, r, courseError(err, "Failed to retrieve course history"))
+		return
+	}
+	versions, err := h.repo.ListCourseVersions(r.Context(), courseID, opts)
+	if err != nil {
+		writeError(w, r, listError(err, "Failed to retrieve course history"))
+		return
+	}
+	writePage(w, r, versions, opts.Fields)
+}
+
+// ListEvents returns a page of the events of a course and its traces,
+// oldest first
+func (h *CourseHistoryHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, err := h.repo.GetCourseByID(r.Context(), courseID); err != nil {
+		writeError(w, r, courseError(err, "Failed to retrieve course events"))
+		return
+	}
+	events, err := h.repo.ListCourseEvents(r.Context(), courseID, opts)
+	if err != nil {
+		writeError(w, r, listError(err, "Failed to retrieve course events"))
+		return
+	}
+	writePage(w, r, events, opts.Fields)
+}
+
+func (h *CourseHistoryHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	_, version, err := h.version(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, version)
+}
+
+// RevertToVersion updates a course back to the way a version left it. The
+// revert is an update like any other, so it becomes the newest version.
+func (h *CourseHistoryHandler) RevertToVersion(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, version, err := h.version(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	course, err := h.repo.UpdateCourse(r.Context(), courseID, version.RevertRequest(), user.ID)
+	if model.IsForeignKeyViolation(err) {
+		writeError(w, r, apierror.BadRequest(apierror.CodeInvalidReference, fmt.Sprintf("The instructor of version %d no longer exists", version.Version)))
+		return
+	}
+	if err != nil {
+		writeError(w, r, courseError(err, "Failed to revert course"))
+		return
+	}
+	log.Printf("Course %s reverted to version %d by %s", course.ID, version.Version, user.Username)
+	writeJSON(w, r, http.StatusOK, course)
+}
+
+// version loads the course version the path names
+func (h *CourseHistoryHandler) version(r *http.Request) (uuid.UUID, *model.CourseVersion, error) {
+	courseID, err := pathUUID(r, "course_id")
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	number, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || number < 1 {
+		return uuid.Nil, nil, apierror.BadRequest(apierror.CodeInvalidID, "Invalid version format")
+	}
+
+	if _, err := h.repo.GetCourseByID(r.Context(), courseID); err != nil {
+		return uuid.Nil, nil, courseError(err, "Failed to retrieve course version")
+	}
+	version, err := h.repo.GetCourseVersion(r.Context(), courseID, number)
+	if errors.Is(err, model.ErrNotFound) {
+		return uuid.Nil, nil, apierror.NotFound(apierror.CodeCourseVersionNotFound, "Course version not found")
+	}
+	if err != nil {
+		return uuid.Nil, nil, internalError(err, "Failed to retrieve course version")
+	}
+	return courseID, version, nil
+}
@@ -2,27 +2,29 @@
 package handler
 
 import (
+	"api-server/internal/apierror"
 	"api-server/internal/model"
-	"database/sql"
-	"encoding/json"
+	"api-server/internal/repository"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/google/uuid"
 )
 
 type InstructorHandler struct {
-	db *sql.DB
+	repo repository.Repository
 }
 
-func NewInstructorHandler(db *sql.DB) *InstructorHandler {
-	return &InstructorHandler{db: db}
+func NewInstructorHandler(repo repository.Repository) *InstructorHandler {
+	return &InstructorHandler{repo: repo}
 }
 
+// instructorRealm is the Basic Auth realm challenged on instructor endpoints
+const instructorRealm = "Instructor Authentication Required"
+
 func (h *InstructorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("Instructor handler hit:", r.Method, r.URL.Path)
-	w.Header().Set("Content-Type", "application/json")
 
 	// Allow requests without authentication
 	if r.Method == http.MethodGet {
@@ -30,29 +32,10 @@ func (h *InstructorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// For all requests, require authentication
-	// Get Basic Auth credentials
-	username, password, hasAuth := r.BasicAuth()
-	if !hasAuth {
-		w.Header().Set("WWW-Authenticate", `Basic realm="Instructor Authentication Required"`)
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
-		return
-	}
-
-	// Authenticate user
-	user, err := model.AuthenticateUser(h.db, username, password)
+	// For all other requests, require an authenticated admin
+	user, err := authenticateAdmin(r, h.repo)
 	if err != nil {
-		w.Header().Set("WWW-Authenticate", `Basic realm="Invalid Credentials"`)
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid username or password"})
-		return
-	}
-
-	// Check if user has instructor or admin role
-	if user.Role != "admin" {
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Insufficient permissions"})
+		writeAuthError(w, r, err, instructorRealm)
 		return
 	}
 
@@ -64,161 +47,153 @@ func (h *InstructorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPatch:
 		h.PatchInstructor(w, r)
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		writeError(w, r, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed"))
 	}
 }
 
 func (h *InstructorHandler) createInstructor(w http.ResponseWriter, r *http.Request, user *model.User) {
 	var req model.CreateInstructorRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := req.Validate(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// Use the authenticated user's ID as the user_id for the instructor
-	instructor, err := model.CreateInstructor(h.db, req, user.ID)
+	instructor, err := h.repo.CreateInstructor(r.Context(), req, user.ID)
 	if err != nil {
 		// Check for unique constraint violations
-		if err.Error() == "pq: duplicate key value violates unique constraint \"instructors_email_key\"" {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Email already exists"})
+		if model.IsUniqueViolation(err, "instructors_email_key") {
+			writeError(w, r, apierror.Conflict(apierror.CodeEmailTaken, "Email already exists"))
 			return
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create instructor"})
+		writeError(w, r, internalError(err, "Failed to create instructor"))
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(instructor)
+	writeJSON(w, r, http.StatusCreated, instructor)
 }
 
 func (h *InstructorHandler) GetInstructorByID(w http.ResponseWriter, r *http.Request) {
 	// Get the instructor ID from query parameter
 	instructorID := r.URL.Query().Get("id")
 
-	// If no ID is provided, return an error
+	// If no ID is provided, list instructors instead
 	if instructorID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Instructor ID is required"})
+		h.ListInstructors(w, r)
 		return
 	}
 
 	// Process the provided ID
-	id, err := uuid.Parse(instructorID)
+	id, err := queryInstructorID(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid instructor ID format"})
+		writeError(w, r, err)
 		return
 	}
 
-	instructor, err := model.GetInstructorByID(h.db, id)
+	instructor, err := h.repo.GetInstructorByID(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Instructor not found"})
-			return
-		}
-
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve instructor"})
+		writeError(w, r, instructorError(err, "Failed to retrieve instructor"))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(instructor)
+	writeJSON(w, r, http.StatusOK, instructor)
 }
 
-func (h *InstructorHandler) DeleteInstructorByID(w http.ResponseWriter, r *http.Request) {
-	// Get the instructor ID from query parameter
-	instructorID := r.URL.Query().Get("id")
-
-	// If no ID is provided, return an error
-	if instructorID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Instructor ID is required"})
+func (h *InstructorHandler) ListInstructors(w http.ResponseWriter, r *http.Request) {
+	// Parse pagination, sort and field selection parameters
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Parse the ID
-	id, err := uuid.Parse(instructorID)
+	instructors, err := h.repo.ListInstructors(r.Context(), opts)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid instructor ID format"})
+		writeError(w, r, listError(err, "Failed to retrieve instructors"))
 		return
 	}
 
-	// Delete the instructor
-	err = model.DeleteInstructorByID(h.db, id)
+	writePage(w, r, instructors, opts.Fields)
+}
+
+func (h *InstructorHandler) DeleteInstructorByID(w http.ResponseWriter, r *http.Request) {
+	// Get the instructor ID from query parameter
+	id, err := queryInstructorID(r)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Instructor not found"})
-			return
-		}
+		writeError(w, r, err)
+		return
+	}
 
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete instructor"})
+	// Delete the instructor
+	if err := h.repo.DeleteInstructorByID(r.Context(), id); err != nil {
+		writeError(w, r, instructorError(err, "Failed to delete instructor"))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Instructor deleted successfully"})
+	writeDeleted(w, r, "Instructor deleted successfully")
 }
 
 func (h *InstructorHandler) PatchInstructor(w http.ResponseWriter, r *http.Request) {
 	// Get the instructor ID from query parameter
-	instructorID := r.URL.Query().Get("id")
-
-	// If no ID is provided, return an error
-	if instructorID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Instructor ID is required"})
-		return
-	}
-
-	// Parse the ID
-	id, err := uuid.Parse(instructorID)
+	id, err := queryInstructorID(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid instructor ID format"})
+		writeError(w, r, err)
 		return
 	}
 
 	// Parse the update request
 	var updateReq model.UpdateInstructorRequest
-	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+	if err := decodeJSON(r, &updateReq); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := validateRequest(&updateReq); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// Update the instructor
-	updatedInstructor, err := model.UpdateInstructor(h.db, id, updateReq)
+	updatedInstructor, err := h.repo.UpdateInstructor(r.Context(), id, updateReq)
 	if err != nil {
 		// Check for unique constraint violations
-		if strings.Contains(err.Error(), "unique constraint") && strings.Contains(err.Error(), "email") {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Email already exists"})
+		if model.IsUniqueViolation(err, "instructors_email_key") {
+			writeError(w, r, apierror.Conflict(apierror.CodeEmailTaken, "Email already exists"))
 			return
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update instructor"})
+		writeError(w, r, instructorError(err, "Failed to update instructor"))
 		return
 	}
 
 	// Return the updated instructor
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(updatedInstructor)
+	writeJSON(w, r, http.StatusOK, updatedInstructor)
+}
+
+// queryInstructorID parses the required ?id= query parameter
+func queryInstructorID(r *http.Request) (uuid.UUID, error) {
+	instructorID := r.URL.Query().Get("id")
+	if instructorID == "" {
+		return uuid.Nil, apierror.Validation("Instructor ID is required")
+	}
+	id, err := uuid.Parse(instructorID)
+	if err != nil {
+		return uuid.Nil, apierror.BadRequest(apierror.CodeInvalidID, "Invalid instructor ID format")
+	}
+	return id, nil
+}
+
+// instructorError maps model.ErrNotFound to INSTRUCTOR_NOT_FOUND and anything else to a 500
+func instructorError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeInstructorNotFound, "Instructor not found")
+	}
+	return internalError(err, message)
 }
@@ -0,0 +1,140 @@
+// internal/handler/listing.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/response"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseListOptions reads the ?limit=, ?cursor=, ?sort= and ?fields= query
+// parameters. Sort and field names are checked against the resource's
+// allowlist by the model layer.
+func parseListOptions(r *http.Request) (model.ListOptions, error) {
+	var opts model.ListOptions
+	query := r.URL.Query()
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > model.MaxPageLimit {
+			return opts, apierror.BadRequest(apierror.CodeInvalidQuery, fmt.Sprintf("limit must be between 1 and %d", model.MaxPageLimit))
+		}
+		opts.Page.Limit = limit
+	}
+
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
+		cursor, err := model.DecodeCursor(cursorStr)
+		if err != nil {
+			return opts, apierror.BadRequest(apierror.CodeInvalidQuery, "invalid cursor")
+		}
+		opts.Page.After = cursor
+	}
+
+	// ?sort=-created_at,name sorts by created_at descending, then name ascending
+	if sortStr := query.Get("sort"); sortStr != "" {
+		for _, key := range strings.Split(sortStr, ",") {
+			key = strings.TrimSpace(key)
+			field := model.SortField{Field: strings.TrimPrefix(key, "-"), Desc: strings.HasPrefix(key, "-")}
+			if field.Field == "" {
+				return opts, apierror.BadRequest(apierror.CodeInvalidQuery, "invalid sort parameter")
+			}
+			opts.Sort = append(opts.Sort, field)
+		}
+	}
+
+	if fieldsStr := query.Get("fields"); fieldsStr != "" {
+		for _, field := range strings.Split(fieldsStr, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				return opts, apierror.BadRequest(apierror.CodeInvalidQuery, "invalid fields parameter")
+			}
+			opts.Fields = append(opts.Fields, field)
+		}
+	}
+
+	return opts, nil
+}
+
+// listError maps a failed list query to INVALID_QUERY when the client's sort,
+// fields or cursor parameters were at fault, and to a 500 otherwise
+func listError(err error, message string) error {
+	if errors.Is(err, model.ErrInvalidListOptions) || errors.Is(err, model.ErrInvalidCursor) {
+		return apierror.BadRequest(apierror.CodeInvalidQuery, err.Error())
+	}
+	return internalError(err, message)
+}
+
+// projectPage trims every item in the page down to the requested fields. With
+// no ?fields= the page is returned as is.
+func projectPage[T any](page *model.Page[T], fields []string) any {
+	if len(fields) == 0 {
+		return page
+	}
+	return model.Page[map[string]json.RawMessage]{
+		Data:       projectItems(page.Data, fields),
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}
+}
+
+// projectItems keeps only the named fields of each item's JSON form
+func projectItems[T any](items []T, fields []string) []map[string]json.RawMessage {
+	data := make([]map[string]json.RawMessage, len(items))
+	for i, item := range items {
+		data[i] = make(map[string]json.RawMessage, len(fields))
+		raw, err := json.Marshal(item)
+		if err != nil {
+			continue
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &all); err != nil {
+			continue
+		}
+		for _, f := range fields {
+			if v, ok := all[f]; ok {
+				data[i][f] = v
+			}
+		}
+	}
+	return data
+}
+
+// streamList sends every item each yields as one JSON array, trimmed to
+// fields and wrapped in {"data": ...} for v2, writing items as they are read
+// so memory stays flat however many there are. Sort and field errors found
+// before the first item get the usual INVALID_QUERY; later errors can only
+// cut the array short.
+func streamList[T any](w http.ResponseWriter, r *http.Request, fields []string, message string, each func(fn func(T) error) error) {
+	key := ""
+	if requestVersion(r) == apiV2 {
+		key = "data"
+	}
+	stream := response.NewArrayStream(w, key)
+	err := each(func(item T) error {
+		var v any = item
+		if len(fields) > 0 {
+			v = projectItems([]T{item}, fields)[0]
+		}
+		return stream.Write(r.Context(), v)
+	})
+	if err == nil {
+		err = stream.Close()
+	}
+	if err == nil {
+		return
+	}
+	if !stream.Started() {
+		writeError(w, r, listError(err, message))
+		return
+	}
+	if r.Context().Err() == nil {
+		log.Printf("%s: %v", message, err)
+	}
+}
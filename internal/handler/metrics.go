@@ -0,0 +1,70 @@
+// internal/handler/metrics.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/config"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsRealm = "Metrics Authentication Required"
+
+// NewMetricsHandler serves the metrics in reg. With METRICS_USERNAME and
+// METRICS_PASSWORD, or METRICS_TOKEN, set, every scrape must carry those
+// Basic Auth credentials or that bearer token; otherwise access is left to
+// the network.
+func NewMetricsHandler(cfg *config.Config, reg *prometheus.Registry) http.Handler {
+	// Exemplars are only exposed to scrapers asking for OpenMetrics, and
+	// native histograms to those asking for protobuf
+	metrics := promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true})
+	username, password, token := cfg.MetricsUsername, cfg.MetricsPassword, cfg.MetricsToken
+	if password == "" && token == "" {
+		return metrics
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := authenticateScrape(r, username, password, token); err != nil {
+			if password != "" {
+				w.Header().Add("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", metricsRealm))
+			}
+			if token != "" {
+				w.Header().Add("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", metricsRealm))
+			}
+			writeError(w, r, err)
+			return
+		}
+		metrics.ServeHTTP(w, r)
+	})
+}
+
+// authenticateScrape checks a scrape's Basic Auth credentials or bearer
+// token against whichever of them are configured
+func authenticateScrape(r *http.Request, username, password, token string) error {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return apierror.New(http.StatusUnauthorized, apierror.CodeAuthenticationRequired, "Authentication required")
+	}
+	if bearer, ok := strings.CutPrefix(header, "Bearer "); ok {
+		if token != "" && secretEqual(bearer, token) {
+			return nil
+		}
+	} else if user, pass, ok := r.BasicAuth(); ok && password != "" {
+		// Both are compared, so a wrong username takes as long as a wrong password
+		userOK, passOK := secretEqual(user, username), secretEqual(pass, password)
+		if userOK && passOK {
+			return nil
+		}
+	}
+	return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid credentials")
+}
+
+// secretEqual compares a presented secret with the configured one in
+// constant time
+func secretEqual(presented, configured string) bool {
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(configured)) == 1
+}
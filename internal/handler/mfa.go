@@ -0,0 +1,292 @@
+// internal/handler/mfa.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/auth"
+	"api-server/internal/mailer"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MFAHandler manages the caller's TOTP enrollment. Enrollment is a two-step
+// affair: POST /user/mfa returns the secret and recovery codes, and MFA is
+// only enforced once a code from the authenticator app is confirmed at
+// POST /user/mfa/enable. Only admins may enroll.
+type MFAHandler struct {
+	repo   repository.Repository
+	mailer *mailer.Mailer
+	issuer string
+	limits model.MFALimits
+}
+
+func NewMFAHandler(repo repository.Repository, mail *mailer.Mailer, issuer string, limits model.MFALimits) *MFAHandler {
+	return &MFAHandler{repo: repo, mailer: mail, issuer: issuer, limits: limits}
+}
+
+// GetMFA returns the caller's enrollment status
+func (h *MFAHandler) GetMFA(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	mfa, err := h.repo.GetMFA(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, mfaError(err, "Failed to retrieve MFA status"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, mfa)
+}
+
+// Enroll starts an enrollment, replacing any pending one. The secret and
+// recovery codes are only ever shown in this response. Admins held back by
+// MFA_REQUIRED_FOR_ADMINS can still get here, so this checks the role itself.
+func (h *MFAHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	if user.Role != "admin" {
+		writeError(w, r, apierror.New(http.StatusForbidden, apierror.CodeInsufficientPermissions, "Only admins can enroll in MFA"))
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to generate MFA secret"))
+		return
+	}
+	codes, hashes, err := auth.NewRecoveryCodes()
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to generate recovery codes"))
+		return
+	}
+	if _, err := h.repo.SaveMFAEnrollment(r.Context(), user.ID, secret, hashes); err != nil {
+		if errors.Is(err, model.ErrMFAEnabled) {
+			writeError(w, r, apierror.Conflict(apierror.CodeMFAAlreadyEnabled, "MFA is already enabled"))
+			return
+		}
+		writeError(w, r, internalError(err, "Failed to save MFA enrollment"))
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, model.MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.ProvisioningURI(h.issuer, user.Username, secret),
+		RecoveryCodes:   codes,
+	})
+}
+
+// Enable confirms a pending enrollment with a code from the authenticator
+// app. Recovery codes are not accepted here, as they don't prove the app
+// was set up.
+func (h *MFAHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	var req model.MFACodeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	mfa, err := h.repo.GetMFA(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, mfaError(err, "Failed to retrieve MFA status"))
+		return
+	}
+	if mfa.Enabled {
+		writeError(w, r, apierror.Conflict(apierror.CodeMFAAlreadyEnabled, "MFA is already enabled"))
+		return
+	}
+	step, ok := auth.ValidateTOTP(mfa.Secret, req.Code, time.Now())
+	if !ok {
+		writeError(w, r, invalidMFACode())
+		return
+	}
+	if err := h.repo.UseMFAStep(r.Context(), user.ID, step); err != nil {
+		writeError(w, r, mfaCodeError(err))
+		return
+	}
+
+	mfa, err = h.repo.GetMFA(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, mfaError(err, "Failed to retrieve MFA status"))
+		return
+	}
+	log.Printf("User %s enabled MFA", user.Username)
+	h.mailer.Send(r.Context(), user, model.EmailMFAEnabled, nil)
+	writeJSON(w, r, http.StatusOK, mfa)
+}
+
+// Disable removes the caller's enrollment. Turning off enabled MFA takes a
+// current TOTP code or a recovery code; a pending one is just discarded.
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	var req model.MFACodeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	mfa, err := h.repo.GetMFA(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, mfaError(err, "Failed to retrieve MFA status"))
+		return
+	}
+	if mfa.Enabled {
+		if err := validateRequest(&req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := checkMFACode(w, r, h.repo, mfa, req.Code, nil, h.limits); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	if err := h.repo.DeleteMFA(r.Context(), user.ID); err != nil {
+		writeError(w, r, mfaError(err, "Failed to disable MFA"))
+		return
+	}
+	log.Printf("User %s disabled MFA", user.Username)
+	writeDeleted(w, r, "MFA disabled successfully")
+}
+
+// Reset removes another user's enrollment, for admins who have lost their
+// authenticator app and their recovery codes. Admins turn off their own MFA
+// with a code instead.
+func (h *MFAHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	admin, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	userID, err := pathUUID(r, "user_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if userID == admin.ID {
+		writeError(w, r, apierror.New(http.StatusForbidden, apierror.CodeInsufficientPermissions, "Admins cannot reset their own MFA"))
+		return
+	}
+
+	if err := h.repo.DeleteMFA(r.Context(), userID); err != nil {
+		writeError(w, r, mfaError(err, "Failed to reset MFA"))
+		return
+	}
+	log.Printf("MFA of user %s reset by %s", userID, admin.Username)
+	writeDeleted(w, r, "MFA reset successfully")
+}
+
+// mfaEnabled reports whether the user has confirmed an enrollment. Only
+// admins can enroll, so other users are not looked up.
+func mfaEnabled(ctx context.Context, repo repository.Repository, user *model.User) (bool, error) {
+	if user.Role != "admin" {
+		return false, nil
+	}
+	mfa, err := repo.GetMFA(ctx, user.ID)
+	if errors.Is(err, model.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return mfa.Enabled, nil
+}
+
+// checkMFACode accepts a TOTP code, at most once, or else an unused
+// recovery code, which is then spent. Codes are counted against the user's
+// limits, and against the login challenge they were sent with, if any,
+// before they are checked.
+func checkMFACode(w http.ResponseWriter, r *http.Request, repo repository.Repository, mfa *model.MFA, code string, challenge *auth.Claims, limits model.MFALimits) error {
+	ctx := r.Context()
+	var challengeID uuid.UUID
+	var challengeExpires time.Time
+	if challenge != nil {
+		challengeID, challengeExpires = challenge.SessionID, challenge.ExpiresAt
+	}
+	if err := repo.CountMFAAttempt(ctx, mfa.UserID, challengeID, challengeExpires, limits); err != nil {
+		return mfaAttemptError(w, err)
+	}
+
+	if err := useMFACode(ctx, repo, mfa, code); err != nil {
+		if errors.Is(err, model.ErrMFACodeUsed) {
+			log.Printf("User %s sent a wrong MFA code", mfa.UserID)
+		}
+		return mfaCodeError(err)
+	}
+	if err := repo.ClearMFAAttempts(ctx, mfa.UserID, challengeID); err != nil {
+		return internalError(err, "Failed to check MFA code")
+	}
+	return nil
+}
+
+func useMFACode(ctx context.Context, repo repository.Repository, mfa *model.MFA, code string) error {
+	if step, ok := auth.ValidateTOTP(mfa.Secret, code, time.Now()); ok {
+		return repo.UseMFAStep(ctx, mfa.UserID, step)
+	}
+	if err := repo.UseRecoveryCode(ctx, mfa.UserID, auth.HashRecoveryCode(code)); err != nil {
+		return err
+	}
+	log.Printf("User %s used a recovery code", mfa.UserID)
+	return nil
+}
+
+// mfaAttemptError maps a *model.MFALockedError to MFA_LOCKED, with a
+// Retry-After header, and model.ErrMFAChallengeSpent to INVALID_TOKEN, so
+// the client logs in again
+func mfaAttemptError(w http.ResponseWriter, err error) error {
+	var locked *model.MFALockedError
+	if errors.As(err, &locked) {
+		retryAfter := max(int(math.Ceil(time.Until(locked.Until).Seconds())), 1)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		return apierror.New(http.StatusTooManyRequests, apierror.CodeMFALocked, "Too many wrong MFA codes; try again later").
+			WithDetails(map[string]any{"locked_until": locked.Until})
+	}
+	if errors.Is(err, model.ErrMFAChallengeSpent) {
+		return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Too many wrong codes for this MFA token; log in again")
+	}
+	return internalError(err, "Failed to check MFA code")
+}
+
+func invalidMFACode() error {
+	return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidMFACode, "Invalid or already used MFA code")
+}
+
+// mfaCodeError maps model.ErrMFACodeUsed to INVALID_MFA_CODE and anything else to a 500
+func mfaCodeError(err error) error {
+	if errors.Is(err, model.ErrMFACodeUsed) {
+		return invalidMFACode()
+	}
+	return internalError(err, "Failed to check MFA code")
+}
+
+// mfaError maps model.ErrNotFound to MFA_NOT_ENROLLED and anything else to a 500
+func mfaError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeMFANotEnrolled, "MFA is not enrolled")
+	}
+	return internalError(err, message)
+}
@@ -0,0 +1,145 @@
+// internal/handler/notification.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// NotificationHandler serves the caller's in-app notification feed and lets
+// users choose which notifications and emails they get
+type NotificationHandler struct {
+	repo repository.Repository
+}
+
+func NewNotificationHandler(repo repository.Repository) *NotificationHandler {
+	return &NotificationHandler{repo: repo}
+}
+
+// GetPreferences returns the caller's email preferences
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+
+	prefs, err := h.repo.GetNotificationPreferences(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to retrieve notification preferences"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, prefs)
+}
+
+// UpdatePreferences turns the caller's emails and notifications on or off,
+// leaving the types the request doesn't name as they were
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+
+	var req model.UpdateNotificationPreferencesRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	prefs, err := h.repo.UpdateNotificationPreferences(r.Context(), user.ID, req)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to update notification preferences"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, prefs)
+}
+
+// ListNotifications returns a page of the caller's feed, newest first.
+// ?unread=true leaves out the notifications already read.
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	unreadOnly := false
+	if unread := r.URL.Query().Get("unread"); unread != "" {
+		if unreadOnly, err = strconv.ParseBool(unread); err != nil {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidQuery, "unread must be true or false"))
+			return
+		}
+	}
+
+	notifications, err := h.repo.ListNotifications(r.Context(), user.ID, unreadOnly, opts)
+	if err != nil {
+		writeError(w, r, listError(err, "Failed to retrieve notifications"))
+		return
+	}
+	writePage(w, r, notifications, opts.Fields)
+}
+
+// UpdateNotification marks one of the caller's notifications read or unread
+func (h *NotificationHandler) UpdateNotification(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	notificationID, err := pathUUID(r, "notification_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req model.UpdateNotificationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	notification, err := h.repo.SetNotificationRead(r.Context(), user.ID, notificationID, *req.Read)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			writeError(w, r, apierror.NotFound(apierror.CodeNotificationNotFound, "Notification not found"))
+			return
+		}
+		writeError(w, r, internalError(err, "Failed to update notification"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, notification)
+}
+
+// MarkAllRead marks every unread notification of the caller read
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+
+	marked, err := h.repo.MarkAllNotificationsRead(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to mark notifications read"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]int{"marked_read": marked})
+}
@@ -0,0 +1,64 @@
+// internal/handler/patch.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/jsonpatch"
+	"api-server/internal/response"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// patchType returns the patch format of a PATCH body: JSON Merge Patch,
+// JSON Patch, or "" for a plain partial body
+func patchType(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == jsonpatch.MergePatchType || mediaType == jsonpatch.JSONPatchType {
+		return mediaType
+	}
+	return ""
+}
+
+// applyPatch applies the body of r, a patch of patchType, to current and
+// decodes the result into patched. Fields current doesn't have are
+// rejected, and a test operation that fails answers 409.
+func applyPatch(r *http.Request, patchType string, current, patched any) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return bodyError(err)
+	}
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return internalError(err, "Failed to apply patch")
+	}
+
+	var result []byte
+	if patchType == jsonpatch.MergePatchType {
+		result, err = jsonpatch.Merge(doc, body)
+	} else {
+		result, err = jsonpatch.Apply(doc, body)
+	}
+	var opErr *jsonpatch.OpError
+	if errors.As(err, &opErr) {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return apierror.Conflict(apierror.CodePatchTestFailed, "A test operation of the patch failed").WithDetails(opErr)
+		}
+		return apierror.BadRequest(apierror.CodeInvalidPatch, "The patch can't be applied").WithDetails(opErr)
+	}
+	if err != nil {
+		return apierror.BadRequest(apierror.CodeInvalidPatch, "Invalid patch: "+err.Error())
+	}
+
+	if err := response.DecodeJSON(bytes.NewReader(result), patched); err != nil {
+		message := "The patched document is invalid"
+		if problem := decodeProblem(err); problem != "" {
+			message += ": " + problem
+		}
+		return apierror.BadRequest(apierror.CodeInvalidPatch, message)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// internal/handler/privacy.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/privacy"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// PrivacyHandler serves personal data exports and erasures. Both run as
+// background jobs: the request queues one and returns it, and the caller
+// polls it, downloading the archive once an export completes. Users may
+// request their own; admins may request anyone's in their tenant.
+type PrivacyHandler struct {
+	repo    repository.Repository
+	storage storage.Storage
+	runner  *privacy.Runner
+}
+
+func NewPrivacyHandler(repo repository.Repository, store storage.Storage, runner *privacy.Runner) *PrivacyHandler {
+	return &PrivacyHandler{repo: repo, storage: store, runner: runner}
+}
+
+// RequestExport queues an export of everything held about the user
+func (h *PrivacyHandler) RequestExport(w http.ResponseWriter, r *http.Request) {
+	h.requestJob(w, r, model.DataJobExport)
+}
+
+// RequestErasure queues the erasure of the user's personal data. The
+// account is kept, anonymized, so the courses the user created keep their
+// owner; the user can no longer log in once it runs.
+func (h *PrivacyHandler) RequestErasure(w http.ResponseWriter, r *http.Request) {
+	h.requestJob(w, r, model.DataJobErase)
+}
+
+func (h *PrivacyHandler) requestJob(w http.ResponseWriter, r *http.Request, kind string) {
+	requester, userID, err := h.authorize(r)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	if kind == model.DataJobErase {
+		if err := refuseImpersonation(r); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	job, err := h.repo.CreateDataJob(r.Context(), kind, userID, requester.ID)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			writeError(w, r, apierror.NotFound(apierror.CodeUserNotFound, "User not found"))
+			return
+		}
+		writeError(w, r, internalError(err, "Failed to queue data job"))
+		return
+	}
+	log.Printf("Data job %s (%s of user %s) requested by %s", job.ID, kind, userID, requester.Username)
+	h.runner.Notify()
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s", dataJobsPath(r, userID), job.ID))
+	writeJSON(w, r, http.StatusAccepted, job)
+}
+
+// ListDataJobs returns the user's export and erasure jobs, newest first
+func (h *PrivacyHandler) ListDataJobs(w http.ResponseWriter, r *http.Request) {
+	_, userID, err := h.authorize(r)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+
+	jobs, err := h.repo.ListDataJobs(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to list data jobs"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"jobs": jobs})
+}
+
+func (h *PrivacyHandler) GetDataJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadJob(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, r, http.StatusOK, job)
+}
+
+// DownloadExport streams a completed export's zip archive
+func (h *PrivacyHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Kind != model.DataJobExport || job.Status != model.DataJobCompleted || job.ResultObject == nil {
+		writeError(w, r, apierror.Conflict(apierror.CodeExportNotReady, "No export archive is available for this job"))
+		return
+	}
+
+	archive, err := h.storage.Download(r.Context(), *job.ResultObject)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, r, apierror.Conflict(apierror.CodeExportNotReady, "No export archive is available for this job"))
+			return
+		}
+		if errors.Is(err, storage.ErrUnavailable) {
+			w.Header().Set("Retry-After", "30")
+			writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeStorageUnavailable, "Storage is temporarily unavailable"))
+			return
+		}
+		writeError(w, r, internalError(err, "Failed to download export"))
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "export-"+job.ID.String()+".zip"))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, archive); err != nil {
+		log.Printf("Failed to send export %s: %v", job.ID, err)
+	}
+}
+
+// loadJob authorizes the request and loads the job named in its path,
+// writing the error and returning false when either fails
+func (h *PrivacyHandler) loadJob(w http.ResponseWriter, r *http.Request) (*model.DataJob, bool) {
+	_, userID, err := h.authorize(r)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return nil, false
+	}
+	jobID, err := pathUUID(r, "job_id")
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+
+	job, err := h.repo.GetDataJob(r.Context(), userID, jobID)
+	if err != nil {
+		writeError(w, r, dataJobError(err, "Failed to retrieve data job"))
+		return nil, false
+	}
+	return job, true
+}
+
+// authorize authenticates the request and parses the user_id path
+// parameter, which must be the caller's own ID unless the caller is an admin
+func (h *PrivacyHandler) authorize(r *http.Request) (*model.User, uuid.UUID, error) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	userID, err := pathUUID(r, "user_id")
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if userID != user.ID && user.Role != "admin" {
+		return nil, uuid.Nil, apierror.New(http.StatusForbidden, apierror.CodeInsufficientPermissions, "Insufficient permissions")
+	}
+	return user, userID, nil
+}
+
+// dataJobsPath is the request's version prefix followed by the user's jobs collection
+func dataJobsPath(r *http.Request, userID uuid.UUID) string {
+	return fmt.Sprintf("/v%d/user/%s/data-jobs", requestVersion(r), userID)
+}
+
+// dataJobError maps model.ErrNotFound to DATA_JOB_NOT_FOUND and anything else to a 500
+func dataJobError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeDataJobNotFound, "Data job not found")
+	}
+	return internalError(err, message)
+}
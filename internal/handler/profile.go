@@ -0,0 +1,258 @@
+// internal/handler/profile.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/avatar"
+	"api-server/internal/mailer"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"api-server/internal/tenant"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// avatarCachePolicy lets browsers keep a profile picture for five minutes.
+// Its URL stays the same when it is replaced, so it isn't kept longer.
+const avatarCachePolicy = "private, max-age=300"
+
+// ProfileHandler serves the caller's own profile and the profile pictures
+// users upload, which are stored as square PNGs
+type ProfileHandler struct {
+	repo     repository.Repository
+	storage  storage.Storage
+	mailer   *mailer.Mailer
+	maxBytes int64
+	size     int
+}
+
+func NewProfileHandler(repo repository.Repository, store storage.Storage, mail *mailer.Mailer, maxBytes int64, size int) *ProfileHandler {
+	return &ProfileHandler{repo: repo, storage: store, mailer: mail, maxBytes: maxBytes, size: size}
+}
+
+// GetProfile returns the caller's account and the URL of their profile
+// picture
+func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateSelf(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	h.writeProfile(w, r, user)
+}
+
+// UpdateProfile changes the caller's account as PUT /user does; fields left
+// out are kept
+func (h *ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	if user := updateSelf(w, r, h.repo, h.mailer); user != nil {
+		h.writeProfile(w, r, user)
+	}
+}
+
+// UploadAvatar sets the caller's profile picture from a PNG, JPEG or GIF
+// image, cropped to a centered square and scaled down to AVATAR_SIZE
+// pixels. A new picture replaces the old one.
+func (h *ProfileHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	if err := refuseImpersonation(r); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Fail fast while storage is down
+	if err := h.storage.Connect(r.Context()); err != nil {
+		writeStorageUnavailable(w, r, err)
+		return
+	}
+
+	err = r.ParseMultipartForm(10 << 20)
+	if err != nil {
+		if tooLarge := payloadTooLarge(err); tooLarge != nil {
+			writeError(w, r, tooLarge)
+			return
+		}
+		writeError(w, r, apierror.BadRequest(apierror.CodeInvalidRequestBody, "Failed to parse multipart form"))
+		return
+	}
+	var req model.UploadAvatarRequest
+	file, header, err := r.FormFile("file")
+	if err == nil {
+		defer file.Close()
+		req.File = header.Filename
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if header.Size > h.maxBytes {
+		writeError(w, r, apierror.PayloadTooLarge(h.maxBytes))
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, r, apierror.BadRequest(apierror.CodeInvalidRequestBody, "Failed to read uploaded file"))
+		return
+	}
+
+	picture, err := avatar.Process(data, h.size)
+	if errors.Is(err, avatar.ErrUnsupported) {
+		writeError(w, r, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedType, "The file must be a PNG, JPEG or GIF image"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to process profile picture"))
+		return
+	}
+
+	// Under the tenant's bucket prefix, like its traces
+	objectName := tenant.ObjectName(r.Context(), avatar.ObjectName(user.ID))
+	if _, err := h.storage.Upload(r.Context(), objectName, bytes.NewReader(picture.PNG)); err != nil {
+		if errors.Is(err, storage.ErrUnavailable) {
+			writeStorageUnavailable(w, r, err)
+			return
+		}
+		writeError(w, r, internalError(err, "Failed to store profile picture"))
+		return
+	}
+	saved := model.UserAvatar{ObjectName: objectName, Size: picture.Size, SizeBytes: int64(len(picture.PNG))}
+	if _, err := h.repo.SaveUserAvatar(r.Context(), user.ID, saved); err != nil {
+		writeError(w, r, internalError(err, "Failed to save profile picture"))
+		return
+	}
+
+	log.Printf("User %s uploaded a profile picture (%d bytes)", user.Username, saved.SizeBytes)
+	h.writeProfile(w, r, user)
+}
+
+// DeleteAvatar removes the caller's profile picture
+func (h *ProfileHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	if err := refuseImpersonation(r); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// The PNG goes first, so a failure leaves the picture in place to retry
+	picture, err := h.repo.GetUserAvatar(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, avatarError(err, "Failed to get profile picture"))
+		return
+	}
+	if err := h.storage.Delete(r.Context(), picture.ObjectName); err != nil {
+		if errors.Is(err, storage.ErrUnavailable) {
+			writeStorageUnavailable(w, r, err)
+			return
+		}
+		writeError(w, r, internalError(err, "Failed to delete profile picture"))
+		return
+	}
+	if _, err := h.repo.DeleteUserAvatar(r.Context(), user.ID); err != nil {
+		writeError(w, r, avatarError(err, "Failed to delete profile picture"))
+		return
+	}
+	writeDeleted(w, r, "Profile picture deleted successfully")
+}
+
+// GetAvatar serves a user's profile picture to any signed-in user of the
+// tenant, answering 304 when the client's copy is current
+func (h *ProfileHandler) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticate(r, h.repo); err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	userID, err := pathUUID(r, "user_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	picture, err := h.repo.GetUserAvatar(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, avatarError(err, "Failed to get profile picture"))
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%d"`, userID, picture.DateUpdated.UnixMicro())
+	setCacheHeaders := func() {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", picture.DateUpdated.UTC().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", avatarCachePolicy)
+	}
+	if notModified(r, etag, picture.DateUpdated) {
+		setCacheHeaders()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	image, err := h.storage.Download(r.Context(), picture.ObjectName)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, r, apierror.NotFound(apierror.CodeAvatarNotFound, "The user has no profile picture"))
+		return
+	}
+	if errors.Is(err, storage.ErrUnavailable) {
+		writeStorageUnavailable(w, r, err)
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to download profile picture"))
+		return
+	}
+	defer image.Close()
+
+	setCacheHeaders()
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, image); err != nil {
+		log.Printf("Failed to send profile picture of user %s: %v", userID, err)
+	}
+}
+
+// writeProfile answers with the user and the URL of their profile picture
+func (h *ProfileHandler) writeProfile(w http.ResponseWriter, r *http.Request, user *model.User) {
+	profile := model.Profile{User: user}
+	_, err := h.repo.GetUserAvatar(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, internalError(err, "Failed to get profile picture"))
+		return
+	}
+	if err == nil {
+		url := avatarURL(r, user.ID)
+		profile.AvatarURL = &url
+	}
+	writeJSON(w, r, http.StatusOK, profile)
+}
+
+// avatarURL is where the user's profile picture is served, under the
+// request's version prefix
+func avatarURL(r *http.Request, userID uuid.UUID) string {
+	return fmt.Sprintf("/v%d/user/%s/avatar", requestVersion(r), userID)
+}
+
+// avatarError maps model.ErrNotFound to AVATAR_NOT_FOUND and anything else to a 500
+func avatarError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeAvatarNotFound, "The user has no profile picture")
+	}
+	return internalError(err, message)
+}
+
+// writeStorageUnavailable answers 503 while storage can't be reached
+func writeStorageUnavailable(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("Storage unavailable: %v", err)
+	w.Header().Set("Retry-After", "30")
+	writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeStorageUnavailable, "Storage is temporarily unavailable"))
+}
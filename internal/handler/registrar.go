@@ -0,0 +1,76 @@
+// internal/handler/registrar.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/registrar"
+	"api-server/internal/repository"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// feedFormats maps the content types a registrar feed may be sent as onto
+// its format
+var feedFormats = map[string]string{
+	"text/csv":   registrar.FormatCSV,
+	"text/plain": registrar.FormatFixed,
+}
+
+// RegistrarHandler imports the registrar's semester feed of courses and
+// who teaches them
+type RegistrarHandler struct {
+	repo repository.Repository
+}
+
+func NewRegistrarHandler(repo repository.Repository) *RegistrarHandler {
+	return &RegistrarHandler{repo: repo}
+}
+
+// Import upserts the instructors and courses of a feed and reports what it
+// created, updated and left unchanged. With ?dry_run=true it only reports.
+func (h *RegistrarHandler) Import(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	dryRun := false
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidQuery, "dry_run must be true or false"))
+			return
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	format, ok := feedFormats[mediaType]
+	if !ok {
+		writeError(w, r, apierror.BadRequest(apierror.CodeInvalidRequestBody, "Registrar feed must be text/csv, or text/plain when fixed-width"))
+		return
+	}
+
+	records, err := registrar.Parse(r.Body, format)
+	if err != nil {
+		var feedErrs registrar.Errors
+		if errors.As(err, &feedErrs) {
+			writeError(w, r, apierror.Validation("Registrar feed validation failed").WithDetails(feedErrs))
+			return
+		}
+		writeError(w, r, bodyError(err))
+		return
+	}
+
+	report, err := registrar.Import(r.Context(), h.repo, records, user.ID, dryRun)
+	if err != nil {
+		writeError(w, r, quotaError(w, err, "Failed to import registrar feed"))
+		return
+	}
+	if !dryRun {
+		log.Printf("Registrar feed of %d sections imported by %s: instructors %+v, courses %+v",
+			len(records), user.Username, report.Instructors, report.Courses)
+	}
+	writeJSON(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,560 @@
+// internal/handler/request.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/auth"
+	"api-server/internal/errortracking"
+	"api-server/internal/middleware"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/response"
+	"api-server/internal/router"
+	"api-server/internal/schema"
+	"api-server/internal/tenant"
+	"api-server/internal/validation"
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type authKey struct{}
+
+// authResult is the outcome of checking a request's credentials
+type authResult struct {
+	user *model.User
+	// account is set instead of user for a service account's key
+	account *model.ServiceAccount
+	// sessionID names the session of a bearer token
+	sessionID uuid.UUID
+	// impersonatorID is the admin acting as user with an impersonation
+	// token, or uuid.Nil
+	impersonatorID uuid.UUID
+	err            error
+	// mfaMissing marks an admin without MFA while MFA_REQUIRED_FOR_ADMINS
+	// is set, who is kept out of admin endpoints until they enroll
+	mfaMissing bool
+}
+
+// authenticateRequest checks Basic Auth credentials, against authn, or a
+// bearer token up front so later middleware, such as the rate limiter,
+// knows who is calling. Handlers still decide whether credentials are
+// required; authenticate reuses the result instead of checking the
+// password again.
+//
+// Admins with MFA enabled must use a bearer token, which they only get by
+// giving a TOTP code; their Basic Auth requests are refused. Responses to
+// impersonation tokens name the admin in an X-Impersonated-By header.
+func authenticateRequest(authn auth.PasswordAuthenticator, repo repository.Repository, tokens *auth.Tokens, requireAdminMFA bool) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Batch sub-requests inherit the batch's result
+			if _, done := r.Context().Value(authKey{}).(*authResult); !done {
+				var res *authResult
+				if token, ok := bearerToken(r); ok && strings.HasPrefix(token, model.ServiceAccountKeyPrefix) {
+					account, err := authenticateServiceAccount(r, repo, token)
+					res = &authResult{account: account, err: err}
+				} else if ok {
+					user, claims, err := authenticateBearer(r, repo, tokens, token)
+					res = &authResult{user: user, err: err}
+					if claims != nil {
+						res.sessionID, res.impersonatorID = claims.SessionID, claims.ImpersonatorID
+					}
+				} else if username, password, hasAuth := r.BasicAuth(); hasAuth {
+					user, err := authenticateBasic(r, authn, username, password)
+					res = &authResult{user: user, err: err}
+				}
+				if res != nil {
+					if res.user != nil {
+						if res.err = checkDeactivated(res.user); res.err != nil {
+							res.user = nil
+						}
+					}
+					if res.user != nil {
+						res.err = checkAdminMFA(r, repo, res, requireAdminMFA)
+					}
+					if res.user != nil && res.impersonatorID != uuid.Nil {
+						w.Header().Set("X-Impersonated-By", res.impersonatorID.String())
+					}
+					switch {
+					case res.user != nil:
+						middleware.SetAccessLogUser(r.Context(), res.user.ID.String())
+					case res.account != nil:
+						middleware.SetAccessLogUser(r.Context(), res.account.ID.String())
+					}
+					r = r.WithContext(context.WithValue(r.Context(), authKey{}, res))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkDeactivated refuses a user an admin has deactivated
+func checkDeactivated(user *model.User) error {
+	if user.DeactivatedAt != nil {
+		return apierror.New(http.StatusForbidden, apierror.CodeAccountDeactivated, "This account has been deactivated")
+	}
+	return nil
+}
+
+// checkAdminMFA refuses Basic Auth for an admin with MFA enabled and marks
+// admins without it when MFA is required
+func checkAdminMFA(r *http.Request, repo repository.Repository, res *authResult, requireAdminMFA bool) error {
+	enabled, err := mfaEnabled(r.Context(), repo, res.user)
+	if err != nil {
+		return internalError(err, "Failed to check MFA status")
+	}
+	if _, isBearer := bearerToken(r); enabled && !isBearer {
+		res.user = nil
+		return apierror.New(http.StatusUnauthorized, apierror.CodeMFARequired, "MFA is enabled for this account; log in at POST /auth/token and use the bearer token")
+	}
+	res.mfaMissing = requireAdminMFA && res.user.Role == "admin" && !enabled
+	return nil
+}
+
+// authenticateBasic verifies Basic Auth credentials with the configured backend
+func authenticateBasic(r *http.Request, authn auth.PasswordAuthenticator, username, password string) (*model.User, error) {
+	user, err := authn.Authenticate(r.Context(), username, password)
+	if errors.Is(err, model.ErrInvalidCredentials) {
+		return nil, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid username or password")
+	}
+	if errors.Is(err, auth.ErrDirectoryUnavailable) {
+		log.Printf("Directory unavailable: %v", err)
+		return nil, apierror.New(http.StatusBadGateway, apierror.CodeIdentityProviderFailed, "Identity provider is unavailable")
+	}
+	if err != nil {
+		return nil, internalError(err, "Failed to authenticate")
+	}
+	return user, nil
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// authenticateBearer resolves a token issued by a login endpoint to its
+// user and claims. Tokens are only good in the tenant they were issued in,
+// and only until their session is revoked.
+func authenticateBearer(r *http.Request, repo repository.Repository, tokens *auth.Tokens, token string) (*model.User, *auth.Claims, error) {
+	invalid := apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired token")
+	claims, err := tokens.Verify(token)
+	if err != nil || claims.TenantID != tenant.ID(r.Context()) {
+		return nil, nil, invalid
+	}
+	err = repo.TouchSession(r.Context(), claims.UserID, claims.SessionID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, nil, invalid
+	}
+	if err != nil {
+		return nil, nil, internalError(err, "Failed to check session")
+	}
+	user, err := repo.GetUser(r.Context(), claims.UserID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, nil, invalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, claims, nil
+}
+
+// authenticateServiceAccount resolves a service account key to its account
+// in the request's tenant
+func authenticateServiceAccount(r *http.Request, repo repository.Repository, key string) (*model.ServiceAccount, error) {
+	account, err := repo.AuthenticateServiceAccount(r.Context(), key)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid service account key")
+	}
+	if err != nil {
+		return nil, internalError(err, "Failed to authenticate service account")
+	}
+	return account, nil
+}
+
+// authenticatedUser returns the user authenticateRequest verified, if any
+func authenticatedUser(r *http.Request) *model.User {
+	if res, ok := r.Context().Value(authKey{}).(*authResult); ok {
+		return res.user
+	}
+	return nil
+}
+
+// currentSession returns the session of the bearer token the request was
+// made with, or uuid.Nil for other credentials
+func currentSession(r *http.Request) uuid.UUID {
+	if res, ok := r.Context().Value(authKey{}).(*authResult); ok {
+		return res.sessionID
+	}
+	return uuid.Nil
+}
+
+// impersonator returns the admin acting as the caller with an impersonation
+// token, or uuid.Nil
+func impersonator(r *http.Request) uuid.UUID {
+	if res, ok := r.Context().Value(authKey{}).(*authResult); ok {
+		return res.impersonatorID
+	}
+	return uuid.Nil
+}
+
+// refuseImpersonation keeps an admin acting as a user from changing the
+// user's account
+func refuseImpersonation(r *http.Request) error {
+	if impersonator(r) != uuid.Nil {
+		return apierror.New(http.StatusForbidden, apierror.CodeInsufficientPermissions, "Impersonation tokens cannot change the user's account")
+	}
+	return nil
+}
+
+// authenticate returns the user authenticateRequest verified. Requests
+// that bypassed it have their Basic Auth credentials checked locally.
+// Service accounts are refused; only authenticateScoped admits them, and
+// so are users who must reset their password.
+func authenticate(r *http.Request, repo repository.Repository) (*model.User, error) {
+	user, err := authenticateSelf(r, repo)
+	if err != nil {
+		return nil, err
+	}
+	if user.PasswordResetRequired {
+		return nil, apierror.New(http.StatusForbidden, apierror.CodePasswordResetRequired, "Change your password with PUT /user first")
+	}
+	return user, nil
+}
+
+// authenticateSelf is authenticate admitting users who must reset their
+// password, for the endpoints they reset it with
+func authenticateSelf(r *http.Request, repo repository.Repository) (*model.User, error) {
+	if res, ok := r.Context().Value(authKey{}).(*authResult); ok {
+		if res.account != nil {
+			return nil, apierror.New(http.StatusForbidden, apierror.CodeInsufficientScope, "Service accounts cannot use this endpoint")
+		}
+		return res.user, res.err
+	}
+	username, password, hasAuth := r.BasicAuth()
+	if !hasAuth {
+		return nil, apierror.New(http.StatusUnauthorized, apierror.CodeAuthenticationRequired, "Authentication required")
+	}
+
+	user, err := repo.AuthenticateUser(r.Context(), username, password)
+	if errors.Is(err, model.ErrInvalidCredentials) {
+		return nil, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid username or password")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDeactivated(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// authenticateAdmin is authenticate plus a check for the admin role and,
+// with MFA_REQUIRED_FOR_ADMINS set, for MFA
+func authenticateAdmin(r *http.Request, repo repository.Repository) (*model.User, error) {
+	user, err := authenticate(r, repo)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != "admin" {
+		return nil, apierror.New(http.StatusForbidden, apierror.CodeInsufficientPermissions, "Insufficient permissions")
+	}
+	if res, ok := r.Context().Value(authKey{}).(*authResult); ok && res.mfaMissing {
+		return nil, apierror.New(http.StatusForbidden, apierror.CodeMFAEnrollmentRequired, "Admins must enable MFA at POST /user/mfa first")
+	}
+	return user, nil
+}
+
+// authenticatePlatformAdmin is authenticateAdmin limited to admins of the
+// default tenant, who manage the other tenants
+func authenticatePlatformAdmin(r *http.Request, repo repository.Repository) (*model.User, error) {
+	user, err := authenticateAdmin(r, repo)
+	if err != nil {
+		return nil, err
+	}
+	if tenant.ID(r.Context()) != model.DefaultTenantID {
+		return nil, apierror.New(http.StatusForbidden, apierror.CodeInsufficientPermissions, "Insufficient permissions")
+	}
+	return user, nil
+}
+
+// authenticateScoped admits a service account granted scope, or else an
+// admin. It guards the endpoints pipelines call.
+func authenticateScoped(r *http.Request, repo repository.Repository, scope string) error {
+	if res, ok := r.Context().Value(authKey{}).(*authResult); ok && res.account != nil {
+		if !res.account.HasScope(scope) {
+			return apierror.New(http.StatusForbidden, apierror.CodeInsufficientScope, fmt.Sprintf("Service account lacks the %s scope", scope))
+		}
+		return nil
+	}
+	_, err := authenticateAdmin(r, repo)
+	return err
+}
+
+// traceAudience authenticates a trace reader and returns the visibilities
+// of the traces they may read: nil, meaning every trace, for admins and
+// service accounts with the trace:read scope; course-members and public
+// traces for other signed-in users; and public ones for anonymous callers.
+func traceAudience(r *http.Request, repo repository.Repository) ([]string, error) {
+	if res, ok := r.Context().Value(authKey{}).(*authResult); ok && res.account != nil {
+		return nil, authenticateScoped(r, repo, model.ScopeTraceRead)
+	}
+	if anonymous(r) {
+		return []string{model.VisibilityPublic}, nil
+	}
+	user, err := authenticate(r, repo)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == "admin" {
+		_, err := authenticateAdmin(r, repo)
+		return nil, err
+	}
+	return []string{model.VisibilityCourseMembers, model.VisibilityPublic}, nil
+}
+
+// readableTrace returns the trace in the request's path if the caller may
+// read it, or the API error to answer with, message describing a failed
+// lookup. A trace hidden from a signed-in caller is reported not found, so
+// its existence isn't given away; anonymous callers are asked to sign in.
+func readableTrace(r *http.Request, repo repository.Repository, message string) (*model.Trace, error) {
+	audience, err := traceAudience(r, repo)
+	if err != nil {
+		return nil, err
+	}
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		return nil, err
+	}
+	trace, err := repo.GetTraceByID(r.Context(), courseID, traceID)
+	if err != nil {
+		return nil, traceError(err, message)
+	}
+	if audience != nil && !slices.Contains(audience, trace.Visibility) {
+		if anonymous(r) {
+			return nil, apierror.New(http.StatusUnauthorized, apierror.CodeAuthenticationRequired, "Authentication required")
+		}
+		return nil, traceError(model.ErrNotFound, message)
+	}
+	return trace, nil
+}
+
+// anonymous reports whether the request carries no credentials at all
+func anonymous(r *http.Request) bool {
+	if _, ok := r.Context().Value(authKey{}).(*authResult); ok {
+		return false
+	}
+	_, _, hasAuth := r.BasicAuth()
+	return !hasAuth
+}
+
+// rateLimitKey identifies the client for rate limiting: the authenticated
+// user or service account, or the client address for anonymous callers
+func rateLimitKey(r *http.Request) string {
+	if user := authenticatedUser(r); user != nil {
+		return "user:" + user.ID.String()
+	}
+	if res, ok := r.Context().Value(authKey{}).(*authResult); ok && res.account != nil {
+		return "service-account:" + res.account.ID.String()
+	}
+	return "ip:" + remoteIP(r)
+}
+
+// remoteIP is the client's address, as middleware.RealIP resolved it
+func remoteIP(r *http.Request) string {
+	if addr := middleware.ClientIP(r); addr.IsValid() {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+// writeAuthError writes an authentication failure, challenging for Basic Auth on 401s
+func writeAuthError(w http.ResponseWriter, r *http.Request, err error, realm string) {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+	}
+	writeError(w, r, err)
+}
+
+// pathUUID parses the named path parameter as a UUID
+func pathUUID(r *http.Request, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(r.PathValue(name))
+	if err != nil {
+		return uuid.Nil, apierror.BadRequest(apierror.CodeInvalidID, fmt.Sprintf("Invalid %s format", name))
+	}
+	return id, nil
+}
+
+// decodeJSON decodes the request body into v. XML and MessagePack bodies
+// are accepted too, with the same field names. A JSON body is first checked
+// against the route's schema in api/openapi.yaml, and one that breaks it is
+// refused with VALIDATION_FAILED and one detail per violated constraint.
+// Decoding itself is strict, see response.DecodeJSON, and its failures say
+// what is wrong, such as "unknown field 'semster_term'".
+func decodeJSON(r *http.Request, v any) error {
+	if format, _ := response.BodyFormat(r.Header.Get("Content-Type")); format == response.FormatJSON {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return bodyError(err)
+		}
+		if len(bytes.TrimSpace(body)) == 0 {
+			return bodyError(io.EOF)
+		}
+		if err := schema.Check(r, body); err != nil {
+			var fieldErrs validation.Errors
+			if errors.As(err, &fieldErrs) {
+				return apierror.Validation("Request body does not match its schema").WithDetails(fieldErrs)
+			}
+			return bodyError(err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if err := response.Decode(r, v); err != nil {
+		return bodyError(err)
+	}
+	return nil
+}
+
+// bodyError maps an unreadable or undecodable request body to a 413, or to
+// a 400 INVALID_REQUEST_BODY saying what is wrong with it
+func bodyError(err error) error {
+	if tooLarge := payloadTooLarge(err); tooLarge != nil {
+		return tooLarge
+	}
+	message := "Invalid request body"
+	if problem := decodeProblem(err); problem != "" {
+		message += ": " + problem
+	}
+	return apierror.BadRequest(apierror.CodeInvalidRequestBody, message)
+}
+
+// decodeProblem describes a JSON decoding error in terms of the document,
+// such as "unknown field 'semster_term'", or returns "" when it can't
+func decodeProblem(err error) string {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return "body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "unexpected end of JSON input"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("%s at offset %d", syntaxErr, syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return "body must be " + jsonType(typeErr.Type)
+		}
+		return fmt.Sprintf("field '%s' must be %s", typeErr.Field, jsonType(typeErr.Type))
+	case errors.Is(err, response.ErrTrailingData), errors.Is(err, response.ErrTooDeep):
+		return err.Error()
+	}
+	// encoding/json has no error type for an unknown field
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return fmt.Sprintf("unknown field '%s'", strings.Trim(field, `"`))
+	}
+	return ""
+}
+
+// jsonType names the kind of JSON value that decodes into t
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		return "a string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// payloadTooLarge returns a 413 when err came from reading past the body limit
+// set by middleware.MaxBodySize, and nil otherwise
+func payloadTooLarge(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return apierror.PayloadTooLarge(maxErr.Limit)
+	}
+	return nil
+}
+
+// validateRequest checks v against its validation tags. Failures are reported
+// as VALIDATION_FAILED with one {field, rule, message} entry per broken rule.
+func validateRequest(v any) error {
+	err := validation.Struct(v)
+	if err == nil {
+		return nil
+	}
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		return apierror.Validation("Request validation failed").WithDetails(fieldErrs)
+	}
+	return internalError(err, "Failed to validate request")
+}
+
+// internalError logs err and returns a 500 with a client-safe message. err
+// is kept as the cause for error tracking.
+func internalError(err error, message string) error {
+	log.Printf("%s: %v", message, err)
+	return apierror.Internal(message).WithCause(err)
+}
+
+// reportServerError sends a server error, with the cause behind it, to
+// error tracking along with who made the request and the route it matched
+func reportServerError(r *http.Request, err error) {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Status < http.StatusInternalServerError {
+			return
+		}
+		if apiErr.Cause != nil {
+			err = fmt.Errorf("%s: %w", apiErr.Message, apiErr.Cause)
+		}
+	}
+	tags := map[string]string{
+		"request_id":  middleware.RequestIDFromContext(r.Context()),
+		"route":       router.Route(r),
+		"api_version": fmt.Sprintf("v%d", requestVersion(r)),
+		"tenant":      tenant.Slug(r.Context()),
+	}
+	if res, ok := r.Context().Value(authKey{}).(*authResult); ok {
+		switch {
+		case res.user != nil:
+			tags["user_id"] = res.user.ID.String()
+		case res.account != nil:
+			tags["service_account_id"] = res.account.ID.String()
+		}
+	}
+	errortracking.CaptureRequest(r, err, tags)
+}
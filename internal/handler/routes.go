@@ -0,0 +1,514 @@
+// internal/handler/routes.go
+package handler
+
+import (
+	"api-server/api"
+	"api-server/internal/auth"
+	"api-server/internal/canvas"
+	"api-server/internal/capture"
+	"api-server/internal/chaos"
+	"api-server/internal/config"
+	"api-server/internal/embedding"
+	"api-server/internal/featureflag"
+	"api-server/internal/lifecycle"
+	"api-server/internal/llm"
+	"api-server/internal/logging"
+	"api-server/internal/mailer"
+	"api-server/internal/middleware"
+	"api-server/internal/model"
+	"api-server/internal/notify"
+	"api-server/internal/outbox"
+	"api-server/internal/privacy"
+	"api-server/internal/progress"
+	"api-server/internal/publisher"
+	"api-server/internal/repository"
+	"api-server/internal/response"
+	"api-server/internal/router"
+	"api-server/internal/searchindex"
+	"api-server/internal/storage"
+	"api-server/internal/tenant"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Services are the dependencies the API handlers are built on
+type Services struct {
+	Repo      repository.Repository
+	Storage   storage.Storage
+	Publisher publisher.Publisher
+	Lifecycle *lifecycle.Manager
+	Outbox    *outbox.Relay
+	Flags     *featureflag.Flags
+	DataJobs  *privacy.Runner
+	Notifier  *notify.Notifier
+	Mailer    *mailer.Mailer
+	// Embedder is nil when similarity search is not configured
+	Embedder embedding.Embedder
+	// Summarizer is nil when trace summaries are not configured
+	Summarizer llm.Summarizer
+	// SearchIndex is nil when the search index is not configured
+	SearchIndex *searchindex.Client
+	// Canvas is nil when Canvas sync is not enabled
+	Canvas *canvas.Syncer
+	// Chaos is nil when fault injection is not enabled
+	Chaos *chaos.Injector
+	// Capture is nil when payload capture is not enabled
+	Capture *capture.Recorder
+}
+
+// NewRouter registers every API route. Request counts and latencies are
+// recorded in reg, which is also served on /metrics. With cfg.OpenAPIValidation set, all
+// traffic is checked against the OpenAPI spec.
+//
+// Every route runs request ID, trace context, client IP resolution, access
+// logging, recovery, debug logging and metrics middleware, in that order. The /v1 and /v2 groups add a no-store cache policy, fault
+// injection when enabled, the admin IP allowlist, tenant resolution, authentication and rate limiting; the ops group (probes and metrics, under
+// /internal and at their original root paths) adds nothing. Per-route
+// middleware caps concurrency, sets deadlines and body limits and, for the
+// course catalog, sets a public cache policy.
+func NewRouter(cfg *config.Config, svc Services, reg *prometheus.Registry) (http.Handler, error) {
+	// Define and register the custom counter metric
+	requestCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests per endpoint",
+		},
+		[]string{"path", "method"},
+	)
+	if err := reg.Register(requestCounter); err != nil {
+		return nil, fmt.Errorf("failed to register requestCounter: %w", err)
+	}
+	// Latency keeps its classic buckets when native histograms are on, so
+	// dashboards built on them go on working
+	latencyOpts := prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by route, method and status.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}
+	if cfg.MetricsNativeHistograms {
+		latencyOpts.NativeHistogramBucketFactor = 1.1
+		latencyOpts.NativeHistogramMaxBucketNumber = 160
+		latencyOpts.NativeHistogramMinResetDuration = time.Hour
+	}
+	requestLatency := prometheus.NewHistogramVec(latencyOpts, []string{"path", "method", "status"})
+	if err := reg.Register(requestLatency); err != nil {
+		return nil, fmt.Errorf("failed to register requestLatency: %w", err)
+	}
+
+	// Zero RATE_LIMIT_RPS leaves the API unlimited
+	var limiter *middleware.RateLimiter
+	if cfg.RateLimitRPS > 0 {
+		limiter = middleware.NewRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst)
+	}
+
+	// Behind a load balancer the client address comes from its headers,
+	// which are only believed from TRUSTED_PROXIES
+	realIP, err := middleware.RealIP(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	accessLogOut, err := logging.OpenAccessLog(cfg.AccessLogFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open access log: %w", err)
+	}
+	accessLogger := middleware.NewAccessLogger(accessLogOut, cfg.AccessLogFormat, cfg.AccessLogSamplePercent, cfg.AccessLogExclude)
+
+	// The access log sits outside recovery so it records panics as the
+	// 500s they become
+	root := router.New(
+		middleware.RequestID,
+		middleware.TraceContext,
+		realIP,
+		middleware.AccessLog(accessLogger),
+		middleware.Recover,
+		middleware.Logging,
+		middleware.CountRequests(requestCounter),
+		middleware.ObserveLatency(requestLatency),
+	)
+	// Bearer tokens are only issued and accepted with AUTH_TOKEN_SECRET set
+	tokens := auth.NewTokens(cfg)
+	samlSP, err := auth.NewSAML(cfg, tokens)
+	if err != nil {
+		return nil, err
+	}
+	// Basic Auth passwords are checked by the AUTH_BACKEND
+	authn, err := auth.NewPasswordAuthenticator(cfg, svc.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Admin endpoints, and /metrics if asked, only answer allowed addresses
+	ipFilter, err := middleware.NewIPFilter(cfg.AdminIPAllowlist, cfg.AdminIPDenylist)
+	if err != nil {
+		return nil, err
+	}
+
+	// With multi-tenancy off every request is in the default tenant
+	var tenants *tenant.Resolver
+	if cfg.MultiTenancy {
+		tenants = tenant.NewResolver(svc.Repo, cfg.TenantBaseDomain)
+	}
+
+	// The version is set first so errors from the rest of the chain are
+	// shaped for it
+	versionGroup := func(prefix string, version router.Middleware) *router.Router {
+		return root.Group(prefix,
+			version,
+			middleware.CacheControl(middleware.NoStore),
+			middleware.Capture(svc.Capture, isCaptureRoute),
+			middleware.Chaos(svc.Chaos, isChaosRoute),
+			middleware.RestrictIPs(ipFilter, isAdminRoute),
+			resolveTenant(tenants),
+			authenticateRequest(authn, svc.Repo, tokens, cfg.MFARequiredForAdmins),
+			middleware.RateLimit(limiter, rateLimitKey),
+		)
+	}
+	v1 := versionGroup("/v1", apiVersion(apiV1))
+	v2 := versionGroup("/v2", apiVersion(apiV2))
+
+	// In-flight requests are capped in two pools, so a burst of uploads
+	// can't starve everything else. Probes and metrics are never shed.
+	apiPool, err := concurrencyPool(reg, "api", cfg.ConcurrencyLimit, cfg)
+	if err != nil {
+		return nil, err
+	}
+	uploadPool, err := concurrencyPool(reg, "upload", cfg.ConcurrencyLimitUpload, cfg)
+	if err != nil {
+		return nil, err
+	}
+	limitAPI := middleware.LimitConcurrency(apiPool)
+	limitUpload := middleware.LimitConcurrency(uploadPool)
+
+	// Route groups get their own deadlines and body limits: reads are short,
+	// uploads get longer and may carry multipart bodies up to the upload limit
+	probe := func(h http.Handler) http.Handler {
+		return middleware.Timeout(cfg.RequestTimeoutRead, middleware.MaxBodySize(cfg.MaxJSONBodyBytes, h))
+	}
+	read := func(h http.Handler) http.Handler {
+		return limitAPI(probe(h))
+	}
+	write := func(h http.Handler) http.Handler {
+		return limitAPI(middleware.Timeout(cfg.RequestTimeoutWrite, middleware.MaxBodySize(cfg.MaxJSONBodyBytes, h)))
+	}
+	upload := func(h http.Handler) http.Handler {
+		return limitUpload(middleware.Timeout(cfg.RequestTimeoutUpload, middleware.MaxBodySize(cfg.MaxUploadBodyBytes, h)))
+	}
+	// Exports stream their body as it is read instead of buffering it, so
+	// they get the upload pool and deadline without the buffering timeout
+	stream := func(h http.Handler) http.Handler {
+		return limitUpload(middleware.Deadline(cfg.RequestTimeoutUpload, middleware.MaxBodySize(cfg.MaxJSONBodyBytes, h)))
+	}
+	// Progress streams last as long as the upload they follow, without
+	// taking a second slot from the upload pool
+	follow := func(h http.Handler) http.Handler {
+		return limitAPI(middleware.Deadline(cfg.RequestTimeoutUpload, middleware.MaxBodySize(cfg.MaxJSONBodyBytes, h)))
+	}
+	readWrite := func(h http.Handler) http.Handler {
+		return limitAPI(middleware.TimeoutByMethod(cfg.RequestTimeoutRead, cfg.RequestTimeoutWrite, middleware.MaxBodySize(cfg.MaxJSONBodyBytes, h)))
+	}
+
+	// Liveness, readiness and metrics for the platform, under /internal and
+	// at the root paths existing probes and scrape configs use
+	healthHandler := NewHealthHandler(svc.Repo)
+	readyHandler := NewReadyHandler(svc.Repo, svc.Storage, svc.Publisher)
+	// /metrics is left out when it has a listener of its own
+	metricsHandler := NewMetricsHandler(cfg, reg)
+	metricsIPs := middleware.RestrictIPs(ipFilter, func(*http.Request) bool { return cfg.AdminIPProtectMetrics })
+	for _, ops := range []*router.Router{root.Group("/internal"), root} {
+		ops.Handle("/livez", LiveHandler{}, probe)
+		ops.Handle("/healthz", healthHandler, probe)
+		ops.Handle("/readyz", readyHandler, probe)
+		if cfg.MetricsAddr == "" {
+			ops.Handle("/metrics", metricsHandler, metricsIPs)
+		}
+	}
+
+	// The course catalog is public and the same for every caller of a
+	// tenant, so a CDN may cache it per tenant; everything else under /v1
+	// and /v2 answers no-store
+	catalog := middleware.CacheControl(middleware.PublicCache(cfg.CatalogCacheMaxAge, cfg.CatalogCacheStaleWhileRevalidate))
+
+	// Resource routes are served by both versions from the same handlers.
+	// v1 responses announce their deprecation and point at /v2.
+	userHandler := NewUserHandler(svc.Repo, svc.Mailer)
+	profileHandler := NewProfileHandler(svc.Repo, svc.Storage, svc.Mailer, cfg.AvatarMaxBytes, cfg.AvatarSize)
+	instructorHandler := NewInstructorHandler(svc.Repo)
+	uploads := progress.NewTracker(cfg.UploadProgressRetention)
+	uploadLimits := model.UploadLimits{
+		CourseBytes:       cfg.CourseMaxStorageBytes,
+		UserUploadsPerDay: cfg.UserMaxUploadsPerDay,
+		UserBytesPerDay:   cfg.UserMaxUploadBytesPerDay,
+	}
+	uploadHandler := NewUploadHandler(svc.Repo, uploads, uploadLimits)
+	courseHandler := NewCourseHandler(svc.Repo, svc.Storage, svc.Lifecycle, svc.Outbox, svc.Notifier, svc.Mailer, uploads, cfg.UploadAllowedTypes, uploadLimits)
+	serviceAccountHandler := NewServiceAccountHandler(svc.Repo)
+	privacyHandler := NewPrivacyHandler(svc.Repo, svc.Storage, svc.DataJobs)
+	mfaLimits := model.MFALimits{
+		ChallengeAttempts: cfg.MFAChallengeAttempts,
+		FailedAttempts:    cfg.MFAMaxFailedAttempts,
+		Lockout:           cfg.MFALockout,
+	}
+	mfaHandler := NewMFAHandler(svc.Repo, svc.Mailer, cfg.MFAIssuer, mfaLimits)
+	sessionHandler := NewSessionHandler(svc.Repo)
+	notificationHandler := NewNotificationHandler(svc.Repo)
+	commentHandler := NewCommentHandler(svc.Repo)
+	courseHistoryHandler := NewCourseHistoryHandler(svc.Repo)
+	favoriteHandler := NewFavoriteHandler(svc.Repo)
+	canvasHandler := NewCanvasHandler(svc.Repo, svc.Canvas)
+	registrarHandler := NewRegistrarHandler(svc.Repo)
+	embeddingHandler := NewEmbeddingHandler(svc.Repo, svc.Embedder, cfg.EmbeddingDimensions)
+	searchHandler := NewSearchHandler(svc.Repo, svc.SearchIndex)
+	summaryHandler := NewSummaryHandler(svc.Repo, svc.Summarizer, cfg.LLMMaxInputChars)
+	thumbnailHandler := NewThumbnailHandler(svc.Repo, svc.Storage)
+	downloadHandler := NewDownloadHandler(svc.Repo, svc.Storage)
+	shareHandler := NewShareHandler(svc.Repo, cfg.ShareLinkDefaultTTL, cfg.ShareLinkMaxTTL)
+	authHandler := NewAuthHandler(svc.Repo, svc.Mailer, authn, tokens, auth.NewProviders(cfg), auth.NewRoleMapper(cfg.OIDCDefaultRole, cfg.OIDCRoleMappings),
+		samlSP, auth.NewRoleMapper(cfg.SAMLDefaultRole, cfg.SAMLRoleMappings), mfaLimits)
+	resources := func(g *router.Router) {
+		// Login with an identity provider token, which calls out to the provider
+		g.HandleFunc("POST /auth/oidc", authHandler.LoginOIDC, write)
+
+		// Password login and MFA, which need bearer tokens: admins with MFA
+		// can't use Basic Auth, so enrolling without them would lock them out
+		if tokens != nil {
+			g.HandleFunc("POST /auth/token", authHandler.LoginPassword, write)
+			g.HandleFunc("POST /auth/mfa/verify", authHandler.VerifyMFA, write)
+			g.HandleFunc("GET /user/mfa", mfaHandler.GetMFA, read)
+			g.HandleFunc("POST /user/mfa", mfaHandler.Enroll, write)
+			g.HandleFunc("POST /user/mfa/enable", mfaHandler.Enable, write)
+			g.HandleFunc("POST /user/mfa/disable", mfaHandler.Disable, write)
+			g.HandleFunc("DELETE /admin/user/{user_id}/mfa", mfaHandler.Reset, write)
+			g.HandleFunc("POST /admin/impersonate/{user_id}", authHandler.Impersonate, write)
+
+			// Sessions, one per bearer token, which users can revoke
+			g.HandleFunc("GET /user/self/sessions", sessionHandler.ListSessions, read)
+			g.HandleFunc("DELETE /user/self/sessions", sessionHandler.DeleteSessions, write)
+			g.HandleFunc("DELETE /user/self/sessions/{session_id}", sessionHandler.DeleteSession, write)
+		}
+
+		// User endpoint
+		g.Handle("/user", userHandler, readWrite)
+		g.HandleFunc("GET /admin/user", userHandler.ListUsers, read)
+		g.HandleFunc("PATCH /admin/user/{user_id}", userHandler.AdminUpdateUser, write)
+
+		// The caller's own profile and profile picture, and the pictures of
+		// the tenant's users
+		g.HandleFunc("GET /user/self", profileHandler.GetProfile, read)
+		g.HandleFunc("PATCH /user/self", profileHandler.UpdateProfile, write)
+		g.HandleFunc("POST /user/self/avatar", profileHandler.UploadAvatar, upload)
+		g.HandleFunc("DELETE /user/self/avatar", profileHandler.DeleteAvatar, write)
+		g.HandleFunc("GET /user/{user_id}/avatar", profileHandler.GetAvatar, read)
+
+		// The caller's notification feed, and which notifications and
+		// account and trace emails they get
+		g.HandleFunc("GET /user/self/notifications", notificationHandler.ListNotifications, read)
+		g.HandleFunc("POST /user/self/notifications/read", notificationHandler.MarkAllRead, write)
+		g.HandleFunc("PATCH /user/self/notifications/{notification_id}", notificationHandler.UpdateNotification, write)
+		g.HandleFunc("GET /user/self/notifications/preferences", notificationHandler.GetPreferences, read)
+		g.HandleFunc("PATCH /user/self/notifications/preferences", notificationHandler.UpdatePreferences, write)
+
+		// The caller's shortlist of saved courses
+		g.HandleFunc("GET /user/self/favorites", favoriteHandler.ListFavorites, read)
+		g.HandleFunc("POST /user/self/favorites/{course_id}", favoriteHandler.AddFavorite, write)
+		g.HandleFunc("DELETE /user/self/favorites/{course_id}", favoriteHandler.RemoveFavorite, write)
+
+		// Service accounts for pipelines, limited to their scopes
+		g.HandleFunc("GET /admin/service-account", serviceAccountHandler.ListServiceAccounts, read)
+		g.HandleFunc("POST /admin/service-account", serviceAccountHandler.CreateServiceAccount, write)
+		g.HandleFunc("GET /admin/service-account/{account_id}", serviceAccountHandler.GetServiceAccount, read)
+		g.HandleFunc("PATCH /admin/service-account/{account_id}", serviceAccountHandler.PatchServiceAccount, write)
+		g.HandleFunc("DELETE /admin/service-account/{account_id}", serviceAccountHandler.DeleteServiceAccount, write)
+		g.HandleFunc("POST /admin/service-account/{account_id}/key", serviceAccountHandler.RotateServiceAccountKey, write)
+
+		// Canvas LMS sync: the connection, course links, pulled rosters and syncs
+		g.HandleFunc("GET /admin/canvas", canvasHandler.GetConnection, read)
+		g.HandleFunc("PUT /admin/canvas", canvasHandler.SaveConnection, write)
+		g.HandleFunc("DELETE /admin/canvas", canvasHandler.DeleteConnection, write)
+		g.HandleFunc("GET /admin/canvas/courses", canvasHandler.ListCourseLinks, read)
+		g.HandleFunc("PUT /admin/canvas/courses/{course_id}", canvasHandler.LinkCourse, write)
+		g.HandleFunc("DELETE /admin/canvas/courses/{course_id}", canvasHandler.UnlinkCourse, write)
+		g.HandleFunc("GET /admin/canvas/courses/{course_id}/roster", canvasHandler.GetRoster, read)
+		g.HandleFunc("POST /admin/canvas/sync", canvasHandler.RequestSync, write)
+		g.HandleFunc("GET /admin/canvas/sync", canvasHandler.ListSyncs, read)
+		g.HandleFunc("GET /admin/canvas/sync/{sync_id}", canvasHandler.GetSync, read)
+
+		// Registrar feed imports, which can carry a whole semester and get
+		// the upload deadline and body limit
+		g.HandleFunc("POST /admin/import/registrar", registrarHandler.Import, upload)
+
+		// Personal data export and erasure jobs. Archives are streamed from
+		// storage as they download.
+		g.HandleFunc("POST /user/{user_id}/export", privacyHandler.RequestExport, write)
+		g.HandleFunc("POST /user/{user_id}/erase", privacyHandler.RequestErasure, write)
+		g.HandleFunc("GET /user/{user_id}/data-jobs", privacyHandler.ListDataJobs, read)
+		g.HandleFunc("GET /user/{user_id}/data-jobs/{job_id}", privacyHandler.GetDataJob, read)
+		g.HandleFunc("GET /user/{user_id}/data-jobs/{job_id}/archive", privacyHandler.DownloadExport, stream)
+
+		// Instructor endpoint
+		g.Handle("/instructor", instructorHandler, readWrite)
+
+		// Course and trace endpoints
+		g.HandleFunc("POST /course", courseHandler.CreateCourse, write)
+		g.HandleFunc("GET /course", courseHandler.ListCourses, catalog, read)
+		g.HandleFunc("GET /admin/course/export", courseHandler.ExportCourses, stream)
+		g.HandleFunc("GET /course/{course_id}", courseHandler.GetCourseByID, catalog, read)
+		g.HandleFunc("PUT /course/{course_id}", courseHandler.PutCourse, write)
+		g.HandleFunc("PATCH /course/{course_id}", courseHandler.PatchCourse, write)
+		g.HandleFunc("DELETE /course/{course_id}", courseHandler.DeleteCourseByID, write)
+		g.HandleFunc("POST /course/{course_id}/archive", courseHandler.ArchiveCourse, write)
+		g.HandleFunc("POST /course/{course_id}/unarchive", courseHandler.UnarchiveCourse, write)
+		g.HandleFunc("GET /course/{course_id}/history", courseHistoryHandler.ListVersions, read)
+		g.HandleFunc("GET /course/{course_id}/history/{version}", courseHistoryHandler.GetVersion, read)
+		g.HandleFunc("POST /course/{course_id}/history/{version}/revert", courseHistoryHandler.RevertToVersion, write)
+		g.HandleFunc("GET /course/{course_id}/events", courseHistoryHandler.ListEvents, read)
+		g.HandleFunc("POST /admin/course/bulk-update", courseHandler.BulkUpdateCourses, write)
+		g.HandleFunc("GET /course/{course_id}/trace", courseHandler.GetTracesByCourseID, read)
+		g.HandleFunc("GET /course/{course_id}/trace/export", courseHandler.ExportTraces, stream)
+		g.HandleFunc("POST /course/{course_id}/trace", courseHandler.HandleTraceUpload, upload)
+		g.HandleFunc("POST /course/{course_id}/trace/link", courseHandler.CreateTraceLink, write)
+		g.HandleFunc("GET /uploads/usage", uploadHandler.GetUsage, read)
+		g.HandleFunc("GET /uploads/{session_id}/progress", uploadHandler.GetProgress, read)
+		g.HandleFunc("GET /uploads/{session_id}/progress/stream", uploadHandler.StreamProgress, follow)
+		g.HandleFunc("GET /course/{course_id}/trace/similar", embeddingHandler.SimilarTraces, read)
+		g.HandleFunc("GET /course/{course_id}/trace/{trace_id}", courseHandler.GetTraceByID, read)
+		g.HandleFunc("PATCH /course/{course_id}/trace/{trace_id}", embeddingHandler.UpdateTrace, write)
+		g.HandleFunc("DELETE /course/{course_id}/trace/{trace_id}", courseHandler.DeleteTraceByID, write)
+		g.HandleFunc("PUT /course/{course_id}/trace/{trace_id}/status", courseHandler.UpdateTraceStatus, write)
+		g.HandleFunc("PUT /course/{course_id}/trace/{trace_id}/file", courseHandler.ReplaceTraceFile, upload)
+		g.HandleFunc("GET /course/{course_id}/trace/{trace_id}/revisions", courseHandler.ListTraceRevisions, read)
+		// Restoring copies the object between storage classes, so it gets the upload deadline
+		g.HandleFunc("POST /course/{course_id}/trace/{trace_id}/restore", courseHandler.RestoreTrace, upload)
+		// Summarizing waits on the LLM, so it gets the upload deadline too
+		g.HandleFunc("POST /course/{course_id}/trace/{trace_id}/summarize", summaryHandler.SummarizeTrace, upload)
+		g.HandleFunc("GET /course/{course_id}/trace/{trace_id}/thumbnail", thumbnailHandler.GetThumbnail, read)
+		// Who may read a trace, and links to its file for people outside
+		// the system, which need no credentials
+		g.HandleFunc("PUT /course/{course_id}/trace/{trace_id}/visibility", courseHandler.UpdateTraceVisibility, write)
+		g.HandleFunc("GET /course/{course_id}/trace/{trace_id}/download", downloadHandler.DownloadTrace, stream)
+		g.HandleFunc("POST /course/{course_id}/trace/{trace_id}/share", shareHandler.CreateShare, write)
+		g.HandleFunc("GET /course/{course_id}/trace/{trace_id}/shares", shareHandler.ListShares, read)
+		g.HandleFunc("DELETE /course/{course_id}/trace/{trace_id}/shares/{share_id}", shareHandler.RevokeShare, write)
+		g.HandleFunc("GET /course/{course_id}/trace/{trace_id}/access-log", downloadHandler.ListAccess, read)
+		g.HandleFunc("GET /shared/{token}", downloadHandler.DownloadShared, stream)
+		// Any signed-in user can discuss a trace
+		g.HandleFunc("GET /course/{course_id}/trace/{trace_id}/comments", commentHandler.ListComments, read)
+		g.HandleFunc("POST /course/{course_id}/trace/{trace_id}/comments", commentHandler.CreateComment, write)
+		g.HandleFunc("DELETE /course/{course_id}/trace/{trace_id}/comments/{comment_id}", commentHandler.DeleteComment, write)
+
+		// Semantic search over processed traces in every course, keyword
+		// search over the text extracted from their PDFs, and faceted search
+		// of the courses and traces mirrored into the search index
+		g.HandleFunc("GET /search", embeddingHandler.Search, read)
+		g.HandleFunc("GET /search/keyword", searchHandler.KeywordSearch, read)
+		g.HandleFunc("GET /search/index", searchHandler.IndexSearch, read)
+	}
+	resources(v1.Group("", deprecated(cfg.APIV1DeprecationDate, cfg.APIV1SunsetDate)))
+	resources(v2)
+
+	// SAML endpoint URLs are registered with the IdP, so they only exist
+	// under /v2, and only with SAML configured
+	if samlSP != nil {
+		v2.HandleFunc("GET /auth/saml/metadata", authHandler.SAMLMetadata, read)
+		v2.HandleFunc("GET /auth/saml/login", authHandler.SAMLLogin, write)
+		v2.HandleFunc("POST /auth/saml/acs", authHandler.SAMLACS, write)
+	}
+
+	// Runtime log level, feature flags and tenants are operator endpoints with no v2
+	// counterpart, so they stay on v1 without deprecation headers
+	adminHandler := NewAdminHandler(svc.Repo, svc.Flags, svc.Chaos, svc.Capture)
+	v1.HandleFunc("PUT /admin/loglevel", adminHandler.SetLogLevel, write)
+	v1.HandleFunc("GET /admin/features", adminHandler.ListFeatureFlags, read)
+	v1.HandleFunc("PUT /admin/features/{name}", adminHandler.SetFeatureFlag, write)
+	v1.HandleFunc("DELETE /admin/features/{name}", adminHandler.ClearFeatureFlag, write)
+
+	// Fault injection only exists in development with CHAOS_ENABLED
+	if svc.Chaos != nil {
+		v1.HandleFunc("GET /admin/chaos", adminHandler.ListChaosFaults, read)
+		v1.HandleFunc("PUT /admin/chaos/{dependency}", adminHandler.SetChaosFault, write)
+		v1.HandleFunc("DELETE /admin/chaos/{dependency}", adminHandler.ClearChaosFault, write)
+	}
+
+	// Payload capture only exists with CAPTURE_ENABLED
+	if svc.Capture != nil {
+		v1.HandleFunc("GET /admin/captures", adminHandler.ListCaptures, read)
+		v1.HandleFunc("DELETE /admin/captures", adminHandler.ClearCaptures, write)
+		v1.HandleFunc("POST /admin/captures/watch", adminHandler.WatchCapture, write)
+		v1.HandleFunc("GET /admin/captures/{request_id}", adminHandler.GetCapture, read)
+	}
+
+	// Tenant administration, for admins of the default tenant
+	tenantHandler := NewTenantHandler(svc.Repo)
+	v1.HandleFunc("GET /admin/tenant", tenantHandler.ListTenants, read)
+	v1.HandleFunc("POST /admin/tenant", tenantHandler.CreateTenant, write)
+	v1.HandleFunc("GET /admin/tenant/{slug}", tenantHandler.GetTenant, read)
+	v1.HandleFunc("PATCH /admin/tenant/{slug}", tenantHandler.PatchTenant, write)
+	v1.HandleFunc("DELETE /admin/tenant/{slug}", tenantHandler.DeleteTenant, write)
+	v1.HandleFunc("GET /admin/tenant/{slug}/usage", tenantHandler.GetTenantUsage, read)
+
+	// Batch endpoint replays sub-operations against the routes above, each
+	// under its own route deadline, so the batch as a whole gets the longest one
+	v1.Handle("POST /batch", NewBatchHandler(root), func(h http.Handler) http.Handler {
+		return middleware.Timeout(cfg.RequestTimeoutUpload, middleware.MaxBodySize(cfg.MaxJSONBodyBytes, h))
+	})
+
+	// Content negotiation sits outside validation, which only knows JSON
+	if !cfg.OpenAPIValidation {
+		return response.Negotiate(root), nil
+	}
+	validator, err := middleware.NewOpenAPIValidator(api.Spec)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Validating requests and responses against the OpenAPI spec")
+	return response.Negotiate(validator.Wrap(root)), nil
+}
+
+// isChaosRoute reports whether r matched a fault injection endpoint, which
+// is never itself delayed or failed, so faults can always be cleared
+func isChaosRoute(r *http.Request) bool {
+	return strings.Contains(router.Route(r), "/admin/chaos")
+}
+
+// isCaptureRoute reports whether r matched a payload capture endpoint, which
+// is never itself captured, so reading captures doesn't evict them
+func isCaptureRoute(r *http.Request) bool {
+	return strings.Contains(router.Route(r), "/admin/captures")
+}
+
+// isAdminRoute reports whether r matched an /admin endpoint, which the admin
+// IP allowlist guards
+func isAdminRoute(r *http.Request) bool {
+	return strings.Contains(router.Route(r), "/admin/")
+}
+
+// concurrencyPool builds the limiter of one pool and exports its in-flight,
+// queued and shed counts labelled with the pool's name. It returns nil, and
+// exports nothing, when limit is zero.
+func concurrencyPool(reg *prometheus.Registry, name string, limit int, cfg *config.Config) (*middleware.ConcurrencyLimiter, error) {
+	if limit == 0 {
+		return nil, nil
+	}
+	l := middleware.NewConcurrencyLimiter(limit, cfg.ConcurrencyQueue, cfg.ConcurrencyQueueTimeout)
+	labels := prometheus.Labels{"pool": name}
+	for _, c := range []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight", Help: "Requests holding a concurrency slot, per pool.", ConstLabels: labels,
+		}, func() float64 { return float64(l.InFlight()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "http_requests_queued", Help: "Requests waiting for a concurrency slot, per pool.", ConstLabels: labels,
+		}, func() float64 { return float64(l.Queued()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "http_requests_shed_total", Help: "Requests refused with a 503 for want of a concurrency slot, per pool.", ConstLabels: labels,
+		}, func() float64 { return float64(l.Shed()) }),
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, fmt.Errorf("failed to register %s concurrency metrics: %w", name, err)
+		}
+	}
+	return l, nil
+}
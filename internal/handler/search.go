@@ -0,0 +1,157 @@
+// internal/handler/search.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/searchindex"
+	"api-server/internal/tenant"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Bounds on keyword search and search index queries
+const (
+	defaultKeywordLimit = 10
+	maxKeywordLimit     = 50
+	maxIndexOffset      = 1000
+)
+
+// SearchHandler searches the text extracted from trace PDFs, and the
+// search index when one is configured
+type SearchHandler struct {
+	repo repository.Repository
+	// index is nil when SEARCH_INDEX_BACKEND is none
+	index *searchindex.Client
+}
+
+func NewSearchHandler(repo repository.Repository, index *searchindex.Client) *SearchHandler {
+	return &SearchHandler{repo: repo, index: index}
+}
+
+// KeywordSearch returns the tenant's traces whose text matches ?q=, most
+// relevant first. q takes web search syntax: quoted phrases, OR and -word.
+// ?course_id= limits the search to one course.
+func (h *SearchHandler) KeywordSearch(w http.ResponseWriter, r *http.Request) {
+	if err := authenticateScoped(r, h.repo, model.ScopeTraceRead); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	query := r.URL.Query()
+	text, err := searchText(query, "q")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	limit, err := searchLimit(query, defaultKeywordLimit, maxKeywordLimit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	courseID, err := queryUUID(query, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	results, err := h.repo.SearchTraceContent(r.Context(), text, courseID, limit)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to search trace content"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"results": results})
+}
+
+// IndexSearch queries the search index for the tenant's courses, or its
+// traces with ?index=traces. ?q= is matched against names and, for traces,
+// the PDF's text; without it every document matches. Results carry
+// highlighted fragments and facet counts over all matches, and can be
+// filtered by course metadata and, for traces, course_id and status.
+func (h *SearchHandler) IndexSearch(w http.ResponseWriter, r *http.Request) {
+	if err := authenticateScoped(r, h.repo, model.ScopeTraceRead); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	query := r.URL.Query()
+	search := searchindex.Query{
+		Index:    searchindex.CoursesIndex,
+		TenantID: tenant.ID(r.Context()),
+		Text:     strings.TrimSpace(query.Get("q")),
+		Filters:  map[string]string{},
+	}
+	if len(search.Text) > maxQueryLen {
+		writeError(w, r, apierror.BadRequest(apierror.CodeInvalidQuery, fmt.Sprintf("q must be at most %d bytes", maxQueryLen)))
+		return
+	}
+	if index := query.Get("index"); index != "" {
+		if index != searchindex.CoursesIndex && index != searchindex.TracesIndex {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidQuery, "index must be courses or traces"))
+			return
+		}
+		search.Index = index
+	}
+	var err error
+	if search.Limit, err = searchLimit(query, defaultSearchLimit, maxSearchLimit); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if raw := query.Get("offset"); raw != "" {
+		if search.Offset, err = strconv.Atoi(raw); err != nil || search.Offset < 0 || search.Offset > maxIndexOffset {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidQuery, fmt.Sprintf("offset must be between 0 and %d", maxIndexOffset)))
+			return
+		}
+	}
+
+	filters, err := searchFilters(query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if filters.SubjectCode != nil {
+		search.Filters["subject_code"] = *filters.SubjectCode
+	}
+	if filters.SemesterTerm != nil {
+		search.Filters["semester_term"] = *filters.SemesterTerm
+	}
+	if filters.SemesterYear != nil {
+		search.Filters["semester_year"] = strconv.Itoa(*filters.SemesterYear)
+	}
+	if filters.InstructorID != nil {
+		search.Filters["instructor_id"] = filters.InstructorID.String()
+	}
+	courseID, err := queryUUID(query, "course_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if courseID != nil {
+		search.Filters["course_id"] = courseID.String()
+	}
+	if status := query.Get("status"); status != "" {
+		if !slices.Contains([]string{"uploaded", "processed", "failed"}, status) {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidQuery, "status must be uploaded, processed or failed"))
+			return
+		}
+		search.Filters["status"] = status
+	}
+
+	if h.index == nil {
+		writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeSearchIndexUnavailable, "The search index is not configured"))
+		return
+	}
+	result, err := h.index.Search(r.Context(), search)
+	if err != nil {
+		log.Printf("Failed to query search index: %v", err)
+		w.Header().Set("Retry-After", "30")
+		writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeSearchIndexUnavailable, "The search index is temporarily unavailable").WithCause(err))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,183 @@
+// internal/handler/serviceaccount.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// ServiceAccountHandler serves the management of the tenant's service
+// accounts under /admin/service-account. Accounts authenticate with
+// "Authorization: Bearer <key>" and can only call endpoints their scopes
+// allow; keys are returned when created or rotated and never again.
+type ServiceAccountHandler struct {
+	repo repository.Repository
+}
+
+func NewServiceAccountHandler(repo repository.Repository) *ServiceAccountHandler {
+	return &ServiceAccountHandler{repo: repo}
+}
+
+func (h *ServiceAccountHandler) ListServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	accounts, err := h.repo.ListServiceAccounts(r.Context())
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to list service accounts"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"service_accounts": accounts})
+}
+
+// CreateServiceAccount creates an account with a new key, which is only
+// ever returned here
+func (h *ServiceAccountHandler) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	var req model.CreateServiceAccountRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	key, err := model.NewServiceAccountKey()
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to generate service account key"))
+		return
+	}
+	account, err := h.repo.CreateServiceAccount(r.Context(), req, model.HashAPIKey(key), user.ID)
+	if err != nil {
+		writeError(w, r, serviceAccountError(err, "Failed to create service account"))
+		return
+	}
+
+	log.Printf("Service account %s (%s) created by %s with scopes %v", account.Name, account.ID, user.Username, account.Scopes)
+	writeJSON(w, r, http.StatusCreated, map[string]any{"service_account": account, "key": key})
+}
+
+func (h *ServiceAccountHandler) GetServiceAccount(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	accountID, err := pathUUID(r, "account_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	account, err := h.repo.GetServiceAccount(r.Context(), accountID)
+	if err != nil {
+		writeError(w, r, serviceAccountError(err, "Failed to retrieve service account"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, account)
+}
+
+// PatchServiceAccount renames an account or changes its description or
+// scopes. New scopes apply to the account's next request.
+func (h *ServiceAccountHandler) PatchServiceAccount(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	accountID, err := pathUUID(r, "account_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req model.UpdateServiceAccountRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	account, err := h.repo.UpdateServiceAccount(r.Context(), accountID, req)
+	if err != nil {
+		writeError(w, r, serviceAccountError(err, "Failed to update service account"))
+		return
+	}
+	log.Printf("Service account %s (%s) updated by %s with scopes %v", account.Name, account.ID, user.Username, account.Scopes)
+	writeJSON(w, r, http.StatusOK, account)
+}
+
+// RotateServiceAccountKey replaces an account's key. The old key stops
+// working at once, so the pipeline must be given the new one.
+func (h *ServiceAccountHandler) RotateServiceAccountKey(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	accountID, err := pathUUID(r, "account_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	key, err := model.NewServiceAccountKey()
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to generate service account key"))
+		return
+	}
+	account, err := h.repo.RotateServiceAccountKey(r.Context(), accountID, model.HashAPIKey(key))
+	if err != nil {
+		writeError(w, r, serviceAccountError(err, "Failed to rotate service account key"))
+		return
+	}
+	log.Printf("Service account %s (%s) key rotated by %s", account.Name, account.ID, user.Username)
+	writeJSON(w, r, http.StatusOK, map[string]any{"service_account": account, "key": key})
+}
+
+func (h *ServiceAccountHandler) DeleteServiceAccount(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	accountID, err := pathUUID(r, "account_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.repo.DeleteServiceAccount(r.Context(), accountID); err != nil {
+		writeError(w, r, serviceAccountError(err, "Failed to delete service account"))
+		return
+	}
+	log.Printf("Service account %s deleted by %s", accountID, user.Username)
+	writeDeleted(w, r, "Service account deleted successfully")
+}
+
+// serviceAccountError maps model.ErrNotFound to SERVICE_ACCOUNT_NOT_FOUND, a
+// duplicate name to SERVICE_ACCOUNT_NAME_TAKEN and anything else to a 500
+func serviceAccountError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeServiceAccountNotFound, "Service account not found")
+	}
+	if model.IsUniqueViolation(err, "service_accounts_name_key") {
+		return apierror.Conflict(apierror.CodeServiceAccountNameTaken, "Service account name already exists")
+	}
+	return internalError(err, message)
+}
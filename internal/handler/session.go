@@ -0,0 +1,86 @@
+// internal/handler/session.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// SessionHandler lets users see where they are logged in, one session per
+// bearer token, and log out of any of them. Revoked tokens are refused at
+// once, before they expire.
+type SessionHandler struct {
+	repo repository.Repository
+}
+
+func NewSessionHandler(repo repository.Repository) *SessionHandler {
+	return &SessionHandler{repo: repo}
+}
+
+// ListSessions returns the caller's active sessions, marking the one the
+// request was made with
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+
+	sessions, err := h.repo.ListSessions(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to list sessions"))
+		return
+	}
+	current := currentSession(r)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"sessions": sessions})
+}
+
+// DeleteSession revokes one of the caller's sessions, which may be the
+// current one
+func (h *SessionHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+	sessionID, err := pathUUID(r, "session_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.repo.DeleteSession(r.Context(), user.ID, sessionID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			writeError(w, r, apierror.NotFound(apierror.CodeSessionNotFound, "Session not found"))
+			return
+		}
+		writeError(w, r, internalError(err, "Failed to revoke session"))
+		return
+	}
+	log.Printf("User %s revoked session %s", user.Username, sessionID)
+	writeDeleted(w, r, "Session revoked successfully")
+}
+
+// DeleteSessions revokes every one of the caller's sessions, the current
+// one included
+func (h *SessionHandler) DeleteSessions(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, userRealm)
+		return
+	}
+
+	if err := h.repo.DeleteSessions(r.Context(), user.ID); err != nil {
+		writeError(w, r, internalError(err, "Failed to revoke sessions"))
+		return
+	}
+	log.Printf("User %s revoked all sessions", user.Username)
+	writeDeleted(w, r, "Sessions revoked successfully")
+}
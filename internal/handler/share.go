@@ -0,0 +1,138 @@
+// internal/handler/share.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ShareHandler lets admins hand out links to a trace's file for people
+// outside the system, which work without credentials until they expire or
+// are revoked
+type ShareHandler struct {
+	repo       repository.Repository
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+func NewShareHandler(repo repository.Repository, defaultTTL, maxTTL time.Duration) *ShareHandler {
+	return &ShareHandler{repo: repo, defaultTTL: defaultTTL, maxTTL: maxTTL}
+}
+
+// CreateShare creates a share link of a trace and records it in the trace's
+// access log. The token is only returned here; the server keeps just its
+// hash.
+func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// The body is optional; without one the link gets the default lifetime
+	var req model.CreateTraceShareRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	now := time.Now()
+	expiresAt := now.Add(h.defaultTTL)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(now) {
+		writeError(w, r, apierror.Validation("expires_at must be in the future"))
+		return
+	}
+	if expiresAt.After(now.Add(h.maxTTL)) {
+		writeError(w, r, apierror.Validation(fmt.Sprintf("expires_at must be within %s", h.maxTTL)))
+		return
+	}
+
+	token, err := model.NewShareToken()
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to generate share link"))
+		return
+	}
+	share, err := h.repo.CreateTraceShare(r.Context(), courseID, traceID, model.HashAPIKey(token), user.ID, expiresAt.UTC().Truncate(time.Second))
+	if err != nil {
+		writeError(w, r, traceError(err, "Failed to create share link"))
+		return
+	}
+	access := traceAccess(r, traceID, model.AccessShareCreated)
+	access.ShareID = &share.ID
+	if _, err := h.repo.RecordTraceAccess(r.Context(), access); err != nil {
+		writeError(w, r, internalError(err, "Failed to record share link"))
+		return
+	}
+
+	log.Printf("User %s shared trace %s until %s", user.Username, traceID, share.ExpiresAt.Format(time.RFC3339))
+	writeJSON(w, r, http.StatusCreated, map[string]any{
+		"share": share,
+		"token": token,
+		"url":   fmt.Sprintf("/v%d/shared/%s", requestVersion(r), token),
+	})
+}
+
+// ListShares returns every share link of a trace, newest first
+func (h *ShareHandler) ListShares(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	shares, err := h.repo.ListTraceShares(r.Context(), courseID, traceID)
+	if err != nil {
+		writeError(w, r, traceError(err, "Failed to retrieve share links"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"shares": shares})
+}
+
+// RevokeShare stops a share link from working. The link stays listed.
+func (h *ShareHandler) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	shareID, err := pathUUID(r, "share_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, err := h.repo.RevokeTraceShare(r.Context(), courseID, traceID, shareID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			writeError(w, r, apierror.NotFound(apierror.CodeShareLinkNotFound, "Share link not found"))
+			return
+		}
+		writeError(w, r, internalError(err, "Failed to revoke share link"))
+		return
+	}
+	log.Printf("User %s revoked share link %s of trace %s", user.Username, shareID, traceID)
+	writeDeleted(w, r, "Share link revoked successfully")
+}
@@ -0,0 +1,94 @@
+// internal/handler/summary.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/llm"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// SummaryHandler digests the text extracted from trace PDFs with an LLM
+type SummaryHandler struct {
+	repo repository.Repository
+	// summarizer is nil when LLM_BACKEND is none
+	summarizer    llm.Summarizer
+	maxInputChars int
+}
+
+func NewSummaryHandler(repo repository.Repository, summarizer llm.Summarizer, maxInputChars int) *SummaryHandler {
+	return &SummaryHandler{repo: repo, summarizer: summarizer, maxInputChars: maxInputChars}
+}
+
+// SummarizeTrace returns a digest of a trace's extracted text. The first
+// request asks the LLM and caches its answer on the trace; later ones get
+// the cached summary until the text is extracted again or ?refresh=true.
+func (h *SummaryHandler) SummarizeTrace(w http.ResponseWriter, r *http.Request) {
+	if err := authenticateScoped(r, h.repo, model.ScopeTraceRead); err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	courseID, traceID, err := tracePath(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	refresh := false
+	if raw := r.URL.Query().Get("refresh"); raw != "" {
+		if refresh, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, r, apierror.BadRequest(apierror.CodeInvalidQuery, "refresh must be true or false"))
+			return
+		}
+	}
+
+	if _, err := h.repo.GetTraceByID(r.Context(), courseID, traceID); err != nil {
+		writeError(w, r, traceError(err, "Failed to get trace"))
+		return
+	}
+	content, err := h.repo.GetTraceContent(r.Context(), courseID, traceID)
+	if errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, apierror.Conflict(apierror.CodeContentNotReady, "The trace's text has not been extracted yet"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to get trace content"))
+		return
+	}
+	if content.Method == model.ExtractionNone {
+		writeError(w, r, apierror.New(http.StatusUnprocessableEntity, apierror.CodeNoTraceText, "The trace's PDF has no text to summarize"))
+		return
+	}
+	if content.Summary != nil && !refresh {
+		content.Summary.Cached = true
+		writeJSON(w, r, http.StatusOK, content.Summary)
+		return
+	}
+
+	if h.summarizer == nil {
+		writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeSummaryUnavailable, "Trace summaries are not configured"))
+		return
+	}
+	text := content.Text
+	if runes := []rune(text); len(runes) > h.maxInputChars {
+		text = string(runes[:h.maxInputChars])
+	}
+	digest, err := h.summarizer.Summarize(r.Context(), text)
+	if err != nil {
+		log.Printf("Failed to summarize trace %s with %s: %v", traceID, h.summarizer.Model(), err)
+		w.Header().Set("Retry-After", "30")
+		writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeSummaryUnavailable, "Trace summaries are temporarily unavailable").WithCause(err))
+		return
+	}
+
+	summary, err := h.repo.SaveTraceSummary(r.Context(), traceID, digest, h.summarizer.Model())
+	if err != nil {
+		writeError(w, r, traceError(err, "Failed to save trace summary"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, summary)
+}
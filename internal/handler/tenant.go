@@ -0,0 +1,244 @@
+// internal/handler/tenant.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/router"
+	"api-server/internal/tenant"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// resolveTenant puts the request's tenant in its context, where the
+// repository scopes every query by it. It must run before authentication,
+// since usernames are unique per tenant. A nil resolver, with multi-tenancy
+// off, puts every request in the default tenant.
+func resolveTenant(resolver *tenant.Resolver) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Batch sub-requests stay in the batch's tenant
+			if tenant.FromContext(r.Context()) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if resolver == nil {
+				next.ServeHTTP(w, r.WithContext(tenant.NewContext(r.Context(), tenant.Default)))
+				return
+			}
+
+			t, err := resolver.Resolve(r)
+			switch {
+			case errors.Is(err, tenant.ErrInvalidAPIKey):
+				writeError(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "Invalid API key"))
+				return
+			case errors.Is(err, tenant.ErrUnknown):
+				writeError(w, r, apierror.NotFound(apierror.CodeTenantNotFound, "Tenant not found"))
+				return
+			case err != nil:
+				writeError(w, r, internalError(err, "Failed to resolve tenant"))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(tenant.NewContext(r.Context(), t)))
+		})
+	}
+}
+
+// TenantHandler serves tenant administration under /v1/admin/tenant. Tenants
+// are managed by the admins of the default tenant, who run the deployment.
+type TenantHandler struct {
+	repo repository.Repository
+}
+
+func NewTenantHandler(repo repository.Repository) *TenantHandler {
+	return &TenantHandler{repo: repo}
+}
+
+// ListTenants returns every tenant with its quotas
+func (h *TenantHandler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticatePlatformAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	tenants, err := h.repo.ListTenants(r.Context())
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to list tenants"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"tenants": tenants})
+}
+
+// CreateTenant creates a tenant with a new API key, which is only ever
+// returned here
+func (h *TenantHandler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticatePlatformAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	var req model.CreateTenantRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	apiKey, err := model.NewAPIKey()
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to generate API key"))
+		return
+	}
+	apiKeyHash := model.HashAPIKey(apiKey)
+	t, err := h.repo.CreateTenant(r.Context(), req, &apiKeyHash)
+	if err != nil {
+		if model.IsUniqueViolation(err, "tenants_slug_key") {
+			writeError(w, r, apierror.Conflict(apierror.CodeSlugTaken, "Tenant slug already exists"))
+			return
+		}
+		writeError(w, r, internalError(err, "Failed to create tenant"))
+		return
+	}
+
+	log.Printf("Tenant %s created by %s", t.Slug, user.Username)
+	writeJSON(w, r, http.StatusCreated, map[string]any{"tenant": t, "api_key": apiKey})
+}
+
+func (h *TenantHandler) GetTenant(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticatePlatformAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	t, err := h.repo.GetTenantBySlug(r.Context(), r.PathValue("slug"))
+	if err != nil {
+		writeError(w, r, tenantError(err, "Failed to retrieve tenant"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, t)
+}
+
+// PatchTenant updates a tenant's name, bucket prefix or quotas. A new bucket
+// prefix only applies to later uploads, and like any change may take the
+// tenant resolution cache's TTL to reach every request.
+func (h *TenantHandler) PatchTenant(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticatePlatformAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	var req model.UpdateTenantRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	t, err := h.repo.UpdateTenant(r.Context(), r.PathValue("slug"), req)
+	if err != nil {
+		writeError(w, r, tenantError(err, "Failed to update tenant"))
+		return
+	}
+	log.Printf("Tenant %s updated by %s", t.Slug, user.Username)
+	writeJSON(w, r, http.StatusOK, t)
+}
+
+// DeleteTenant removes a tenant that no longer owns any data. The default
+// tenant cannot be deleted.
+func (h *TenantHandler) DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticatePlatformAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	slug := r.PathValue("slug")
+	if slug == model.DefaultTenantSlug {
+		writeError(w, r, apierror.Conflict(apierror.CodeTenantInUse, "The default tenant cannot be deleted"))
+		return
+	}
+	if err := h.repo.DeleteTenant(r.Context(), slug); err != nil {
+		if model.IsForeignKeyViolation(err) {
+			writeError(w, r, apierror.Conflict(apierror.CodeTenantInUse, "Tenant still has users, instructors, courses or traces"))
+			return
+		}
+		writeError(w, r, tenantError(err, "Failed to delete tenant"))
+		return
+	}
+	log.Printf("Tenant %s deleted by %s", slug, user.Username)
+	writeDeleted(w, r, "Tenant deleted successfully")
+}
+
+// GetTenantUsage reports a tenant's usage next to its quotas
+func (h *TenantHandler) GetTenantUsage(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticatePlatformAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+
+	t, err := h.repo.GetTenantBySlug(r.Context(), r.PathValue("slug"))
+	if err != nil {
+		writeError(w, r, tenantError(err, "Failed to retrieve tenant"))
+		return
+	}
+	usage, err := h.repo.GetTenantUsage(r.Context(), t.ID)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to retrieve tenant usage"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"tenant": t.Slug, "usage": usage, "quotas": t.Quotas})
+}
+
+// tenantError maps model.ErrNotFound to TENANT_NOT_FOUND and anything else to a 500
+func tenantError(err error, message string) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return apierror.NotFound(apierror.CodeTenantNotFound, "Tenant not found")
+	}
+	return internalError(err, message)
+}
+
+// quotaError maps a *model.QuotaError to an error naming the quota and what
+// is left of it: 413 COURSE_STORAGE_EXCEEDED for the course storage quota,
+// 429 UPLOAD_QUOTA_EXCEEDED with a Retry-After of the next UTC midnight for
+// the daily upload quota, and 402 QUOTA_EXCEEDED for the other tenant quotas.
+// A missing course is COURSE_NOT_FOUND and anything else a 500.
+func quotaError(w http.ResponseWriter, err error, message string) error {
+	var quotaErr *model.QuotaError
+	if !errors.As(err, &quotaErr) {
+		return courseError(err, message)
+	}
+	details := map[string]any{
+		"quota":     quotaErr.Quota,
+		"limit":     quotaErr.Limit,
+		"used":      quotaErr.Used,
+		"remaining": quotaErr.Remaining(),
+	}
+	switch quotaErr.Quota {
+	case model.QuotaCourseStorageBytes:
+		return apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeCourseStorageExceeded, "Course storage quota exceeded").WithDetails(details)
+	case model.QuotaUploadsPerDay, model.QuotaUserUploadsPerDay, model.QuotaUserUploadBytesPerDay:
+		now := time.Now()
+		reset := model.UsageDay(now).Add(24 * time.Hour)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds()))))
+		details["resets_at"] = reset
+		message := "Daily upload quota exceeded"
+		if quotaErr.Quota != model.QuotaUploadsPerDay {
+			message = "Your daily upload quota is used up"
+		}
+		return apierror.New(http.StatusTooManyRequests, apierror.CodeUploadQuotaExceeded, message).WithDetails(details)
+	}
+	return apierror.New(http.StatusPaymentRequired, apierror.CodeQuotaExceeded, "Tenant quota exceeded").WithDetails(details)
+}
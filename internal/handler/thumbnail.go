@@ -0,0 +1,93 @@
+// internal/handler/thumbnail.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"api-server/internal/thumbnail"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// thumbnailCachePolicy lets browsers keep a thumbnail for an hour; it only
+// changes if the trace is rendered again, which changes its ETag
+const thumbnailCachePolicy = "private, max-age=3600"
+
+// ThumbnailHandler serves the previews rendered from trace PDFs
+type ThumbnailHandler struct {
+	repo    repository.Repository
+	storage storage.Storage
+}
+
+func NewThumbnailHandler(repo repository.Repository, store storage.Storage) *ThumbnailHandler {
+	return &ThumbnailHandler{repo: repo, storage: store}
+}
+
+// GetThumbnail returns the PNG rendered from the first page of a trace's
+// PDF, answering 304 when the client's copy is current
+func (h *ThumbnailHandler) GetThumbnail(w http.ResponseWriter, r *http.Request) {
+	trace, err := readableTrace(r, h.repo, "Failed to get trace")
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	if trace.LinkURL != nil {
+		writeError(w, r, apierror.New(http.StatusUnprocessableEntity, apierror.CodeNoThumbnail, "The trace is a video link and has no file to render"))
+		return
+	}
+	// readableTrace has checked the path
+	courseID, traceID, _ := tracePath(r)
+	thumb, err := h.repo.GetTraceThumbnail(r.Context(), courseID, traceID)
+	if errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, apierror.Conflict(apierror.CodeThumbnailNotReady, "The trace's thumbnail has not been generated yet"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to get trace thumbnail"))
+		return
+	}
+	if thumb.Error != nil {
+		writeError(w, r, apierror.New(http.StatusUnprocessableEntity, apierror.CodeNoThumbnail, "The trace's file could not be rendered"))
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%d"`, traceID, thumb.GeneratedAt.UnixMicro())
+	setCacheHeaders := func() {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", thumb.GeneratedAt.UTC().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", thumbnailCachePolicy)
+	}
+	if notModified(r, etag, thumb.GeneratedAt) {
+		setCacheHeaders()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	image, err := h.storage.Download(r.Context(), thumbnail.ObjectName(trace.FileName, trace.ID))
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, r, apierror.Conflict(apierror.CodeThumbnailNotReady, "The trace's thumbnail has not been generated yet"))
+		return
+	}
+	if errors.Is(err, storage.ErrUnavailable) {
+		w.Header().Set("Retry-After", "30")
+		writeError(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeStorageUnavailable, "Storage is temporarily unavailable"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to download thumbnail"))
+		return
+	}
+	defer image.Close()
+
+	setCacheHeaders()
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, image); err != nil {
+		log.Printf("Failed to send thumbnail of trace %s: %v", traceID, err)
+	}
+}
@@ -0,0 +1,174 @@
+// internal/handler/trace_upload_integration_test.go
+package handler_test
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/testutil"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tracePDF is the smallest file the upload accepts as a PDF
+var tracePDF = []byte("%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\ntrailer << /Root 1 0 R >>\n%%EOF\n")
+
+// uploadResponse is the body of a successful trace upload
+type uploadResponse struct {
+	BucketURL     string `json:"bucket_url"`
+	TraceID       string `json:"trace_id"`
+	PublishStatus string `json:"publish_status"`
+}
+
+// TestTraceUpload follows an upload from the request through to storage,
+// the traces table and the pdf-upload topic
+func TestTraceUpload(t *testing.T) {
+	env := testutil.Start(t)
+	admin := env.CreateAdmin(t, "uploader")
+	ctx := context.Background()
+
+	var instructor model.Instructor
+	env.JSON(t, &admin, http.MethodPost, "/v1/instructor",
+		model.CreateInstructorRequest{Name: "Ada Lovelace", Email: "ada@example.edu"}, http.StatusCreated, &instructor)
+	var course model.Course
+	env.JSON(t, &admin, http.MethodPost, "/v1/course", model.CreateCourseRequest{
+		Name:         "Network Structures and Cloud Computing",
+		SemesterTerm: "Fall",
+		CreditHours:  4,
+		SubjectCode:  "CSYE",
+		CourseID:     6225,
+		SemesterYear: 2025,
+		InstructorID: instructor.ID,
+	}, http.StatusCreated, &course)
+
+	resp, body := env.UploadTrace(t, &admin, course.ID.String(), "lecture.pdf", tracePDF)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: status %d, want %d: %s", resp.StatusCode, http.StatusCreated, body)
+	}
+	var uploaded uploadResponse
+	if err := json.Unmarshal(body, &uploaded); err != nil {
+		t.Fatalf("failed to decode upload response: %v: %s", err, body)
+	}
+
+	// The trace row points at the stored file
+	var fileName, bucketURL, status, contentType string
+	var sizeBytes int64
+	var userID, courseID string
+	err := env.DB.QueryRow(ctx, `
+		SELECT file_name, bucket_url, status, content_type, size_bytes, user_id::text, course_id::text
+		FROM api.traces WHERE id = $1
+	`, uploaded.TraceID).Scan(&fileName, &bucketURL, &status, &contentType, &sizeBytes, &userID, &courseID)
+	if err != nil {
+		t.Fatalf("failed to read trace %s: %v", uploaded.TraceID, err)
+	}
+	if bucketURL != uploaded.BucketURL {
+		t.Errorf("bucket_url = %q, response said %q", bucketURL, uploaded.BucketURL)
+	}
+	if status != "uploaded" || contentType != model.ContentTypePDF || sizeBytes != int64(len(tracePDF)) {
+		t.Errorf("trace has status %q, content type %q and %d bytes, want uploaded, %s and %d",
+			status, contentType, sizeBytes, model.ContentTypePDF, len(tracePDF))
+	}
+	if courseID != course.ID.String() {
+		t.Errorf("trace belongs to course %s, want %s", courseID, course.ID)
+	}
+	if !strings.Contains(fileName, uploaded.TraceID) {
+		t.Errorf("object name %q does not name trace %s", fileName, uploaded.TraceID)
+	}
+
+	// The object holds exactly what was uploaded
+	object, err := env.Storage.Download(ctx, fileName)
+	if err != nil {
+		t.Fatalf("failed to download %s: %v", fileName, err)
+	}
+	stored, err := io.ReadAll(object)
+	object.Close()
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", fileName, err)
+	}
+	if !bytes.Equal(stored, tracePDF) {
+		t.Errorf("stored object has %d bytes that differ from the %d uploaded", len(stored), len(tracePDF))
+	}
+
+	// The outbox relay publishes the pdf-upload event for the trace
+	messages := env.ConsumeMessages(t, model.TopicPDFUpload, 1, 30*time.Second)
+	var event map[string]string
+	if err := json.Unmarshal(messages[0], &event); err != nil {
+		t.Fatalf("failed to decode pdf-upload event: %v: %s", err, messages[0])
+	}
+	want := map[string]string{
+		"trace_id":        uploaded.TraceID,
+		"course_id":       course.ID.String(),
+		"instructor_name": "ada lovelace",
+		"course_code":     "csye 6225",
+		"semester_term":   "fall",
+		"semester_year":   "2025",
+		"bucket_path":     uploaded.BucketURL,
+		"content_type":    model.ContentTypePDF,
+		"tenant":          model.DefaultTenantSlug,
+	}
+	for key, value := range want {
+		if event[key] != value {
+			t.Errorf("event %s = %q, want %q", key, event[key], value)
+		}
+	}
+
+	// and marks the trace published once Kafka has acknowledged it
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var publishStatus string
+		err := env.DB.QueryRow(ctx, "SELECT publish_status FROM api.traces WHERE id = $1", uploaded.TraceID).Scan(&publishStatus)
+		if err != nil {
+			t.Fatalf("failed to read publish status: %v", err)
+		}
+		if publishStatus == model.PublishStatusPublished {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("publish_status is still %q", publishStatus)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// TestTraceUploadRejected checks that an upload that isn't a PDF stores
+// nothing and publishes nothing
+func TestTraceUploadRejected(t *testing.T) {
+	env := testutil.Start(t)
+	admin := env.CreateAdmin(t, "uploader")
+	ctx := context.Background()
+
+	var instructor model.Instructor
+	env.JSON(t, &admin, http.MethodPost, "/v1/instructor",
+		model.CreateInstructorRequest{Name: "Grace Hopper", Email: "grace@example.edu"}, http.StatusCreated, &instructor)
+	var course model.Course
+	env.JSON(t, &admin, http.MethodPost, "/v1/course", model.CreateCourseRequest{
+		Name:         "Compilers",
+		SemesterTerm: "Spring",
+		CreditHours:  4,
+		SubjectCode:  "CS",
+		CourseID:     5500,
+		SemesterYear: 2025,
+		InstructorID: instructor.ID,
+	}, http.StatusCreated, &course)
+
+	resp, body := env.UploadTrace(t, &admin, course.ID.String(), "notes.pdf", []byte("plain text, not a PDF"))
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("upload: status %d, want %d: %s", resp.StatusCode, http.StatusUnsupportedMediaType, body)
+	}
+
+	var traces, events int
+	err := env.DB.QueryRow(ctx, `
+		SELECT (SELECT count(*) FROM api.traces WHERE course_id = $1),
+		       (SELECT count(*) FROM api.outbox)
+	`, course.ID).Scan(&traces, &events)
+	if err != nil {
+		t.Fatalf("failed to count traces and outbox events: %v", err)
+	}
+	if traces != 0 || events != 0 {
+		t.Errorf("rejected upload left %d traces and %d outbox events", traces, events)
+	}
+}
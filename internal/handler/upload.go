@@ -0,0 +1,163 @@
+// internal/handler/upload.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/model"
+	"api-server/internal/progress"
+	"api-server/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// uploadSessionHeader carries the ID, chosen by the client, that an upload's
+// progress can be followed under while it is still being sent
+const uploadSessionHeader = "X-Upload-Session"
+
+// A progress stream checks for changes this often, and sends a comment
+// after this long without one so proxies keep the connection open
+const (
+	progressPollInterval = 250 * time.Millisecond
+	progressKeepAlive    = 15 * time.Second
+)
+
+// UploadHandler reports how far the caller's uploads have got, and how
+// much of their daily upload quota is left
+type UploadHandler struct {
+	repo    repository.Repository
+	uploads *progress.Tracker
+	limits  model.UploadLimits
+}
+
+func NewUploadHandler(repo repository.Repository, uploads *progress.Tracker, limits model.UploadLimits) *UploadHandler {
+	return &UploadHandler{repo: repo, uploads: uploads, limits: limits}
+}
+
+// GetUsage returns what the caller has uploaded today against their daily
+// quota, so clients can show what is left before uploading
+func (h *UploadHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	usage, err := h.repo.GetUserUploadUsage(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to retrieve upload usage"))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, h.limits.Quota(*usage))
+}
+
+// GetProgress returns the progress of one of the caller's uploads
+func (h *UploadHandler) GetProgress(w http.ResponseWriter, r *http.Request) {
+	_, p, err := h.progress(r)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, p)
+}
+
+// StreamProgress sends the progress of one of the caller's uploads as
+// server-sent events, one whenever it changes, until the upload completes
+// or fails
+func (h *UploadHandler) StreamProgress(w http.ResponseWriter, r *http.Request) {
+	userID, p, err := h.progress(r)
+	if err != nil {
+		writeAuthError(w, r, err, courseRealm)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	send := func(event string) bool {
+		if _, err := fmt.Fprint(w, event); err != nil {
+			return false
+		}
+		rc.Flush()
+		return true
+	}
+
+	ticker := time.NewTicker(progressPollInterval)
+	defer ticker.Stop()
+	lastSent := time.Now()
+	for {
+		if !send(progressEvent(p)) || p.Done() {
+			return
+		}
+		lastSent = time.Now()
+
+		for changed := false; !changed; {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+			}
+			next, ok := h.uploads.Get(p.SessionID, userID)
+			if !ok {
+				// Forgotten while idle; the last event stands
+				return
+			}
+			if changed = next != p; changed {
+				p = next
+			} else if time.Since(lastSent) >= progressKeepAlive {
+				if !send(": keep-alive\n\n") {
+					return
+				}
+				lastSent = time.Now()
+			}
+		}
+	}
+}
+
+// progress authenticates the caller and returns the progress of their
+// upload named in the path
+func (h *UploadHandler) progress(r *http.Request) (uuid.UUID, progress.Progress, error) {
+	user, err := authenticate(r, h.repo)
+	if err != nil {
+		return uuid.Nil, progress.Progress{}, err
+	}
+	sessionID, err := pathUUID(r, "session_id")
+	if err != nil {
+		return uuid.Nil, progress.Progress{}, err
+	}
+	p, ok := h.uploads.Get(sessionID, user.ID)
+	if !ok {
+		return uuid.Nil, progress.Progress{}, apierror.NotFound(apierror.CodeUploadSessionNotFound, "Upload session not found")
+	}
+	return user.ID, p, nil
+}
+
+// progressEvent is p as a server-sent event
+func progressEvent(p progress.Progress) string {
+	data, _ := json.Marshal(p)
+	return fmt.Sprintf("event: progress\ndata: %s\n\n", data)
+}
+
+// startUploadSession tracks the progress of an upload by userID under the
+// request's X-Upload-Session, if it has one. The session is nil otherwise,
+// which tracks nothing.
+func startUploadSession(r *http.Request, uploads *progress.Tracker, userID uuid.UUID) (*progress.Session, error) {
+	value := r.Header.Get(uploadSessionHeader)
+	if value == "" {
+		return nil, nil
+	}
+	sessionID, err := uuid.Parse(value)
+	if err != nil {
+		return nil, apierror.BadRequest(apierror.CodeInvalidID, "Invalid X-Upload-Session format")
+	}
+	session, err := uploads.Start(sessionID, userID, r.ContentLength)
+	if errors.Is(err, progress.ErrSessionInUse) {
+		return nil, apierror.Conflict(apierror.CodeUploadSessionInUse, "Upload session is already in use")
+	}
+	return session, err
+}
@@ -2,25 +2,30 @@
 package handler
 
 import (
+	"api-server/internal/apierror"
+	"api-server/internal/mailer"
 	"api-server/internal/model"
-	"database/sql"
-	"encoding/json"
+	"api-server/internal/repository"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"strings"
 )
 
 type UserHandler struct {
-	db *sql.DB
+	repo   repository.Repository
+	mailer *mailer.Mailer
 }
 
-func NewUserHandler(db *sql.DB) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(repo repository.Repository, mail *mailer.Mailer) *UserHandler {
+	return &UserHandler{repo: repo, mailer: mail}
 }
 
+// userRealm is the Basic Auth realm challenged on user endpoints
+const userRealm = "User Authentication Required"
+
 func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("User handler hit:", r.Method, r.URL.Path)
-	w.Header().Set("Content-Type", "application/json")
 
 	switch r.Method {
 	case http.MethodGet:
@@ -30,120 +35,169 @@ func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		h.UpdateUser(w, r)
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		writeError(w, r, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed"))
 	}
 }
 
 func (h *UserHandler) createUser(w http.ResponseWriter, r *http.Request) {
 	var req model.CreateUserRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := req.Validate(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	user, err := model.CreateUser(h.db, req)
+	user, err := h.repo.CreateUser(r.Context(), req)
 	if err != nil {
 		// Check for unique constraint violations
-		if err.Error() == "pq: duplicate key value violates unique constraint \"users_username_key\"" {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Username already exists"})
+		if model.IsUniqueViolation(err, "users_username_key") {
+			writeError(w, r, apierror.Conflict(apierror.CodeUsernameTaken, "Username already exists"))
 			return
 		}
-		if err.Error() == "pq: duplicate key value violates unique constraint \"users_email_key\"" {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Email already exists"})
+		if model.IsUniqueViolation(err, "users_email_key") {
+			writeError(w, r, apierror.Conflict(apierror.CodeEmailTaken, "Email already exists"))
 			return
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create user"})
+		writeError(w, r, internalError(err, "Failed to create user"))
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(user)
+	h.mailer.Send(r.Context(), user, model.EmailAccountCreated, nil)
+	writeJSON(w, r, http.StatusCreated, user)
 }
 
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get Basic Auth credentials
-	username, password, hasAuth := r.BasicAuth()
-	if !hasAuth {
-		w.Header().Set("WWW-Authenticate", `Basic realm="User Authentication Required"`)
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
-		return
-	}
-
-	// Authenticate user
-	user, err := model.AuthenticateUser(h.db, username, password)
+	// Authenticate user; one who must reset their password may still see
+	// their account
+	user, err := authenticateSelf(r, h.repo)
 	if err != nil {
-		w.Header().Set("WWW-Authenticate", `Basic realm="Invalid Credentials"`)
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid username or password"})
+		writeAuthError(w, r, err, userRealm)
 		return
 	}
 
 	// Return the authenticated user
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(user)
+	writeJSON(w, r, http.StatusOK, user)
 }
 
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get Basic Auth credentials
-	username, password, hasAuth := r.BasicAuth()
-	if !hasAuth {
-		w.Header().Set("WWW-Authenticate", `Basic realm="User Authentication Required"`)
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
-		return
+	if user := updateSelf(w, r, h.repo, h.mailer); user != nil {
+		writeJSON(w, r, http.StatusOK, user)
 	}
+}
 
-	// Authenticate user
-	authenticatedUser, err := model.AuthenticateUser(h.db, username, password)
+// updateSelf applies the caller's change to their own account and returns
+// the updated user, or writes the error and returns nil. A user who must
+// reset their password does so here.
+func updateSelf(w http.ResponseWriter, r *http.Request, repo repository.Repository, mail *mailer.Mailer) *model.User {
+	authenticatedUser, err := authenticateSelf(r, repo)
 	if err != nil {
-		w.Header().Set("WWW-Authenticate", `Basic realm="Invalid Credentials"`)
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid username or password"})
-		return
+		writeAuthError(w, r, err, userRealm)
+		return nil
+	}
+	if err := refuseImpersonation(r); err != nil {
+		writeError(w, r, err)
+		return nil
 	}
 
 	// Parse the update request
 	var updateReq model.UpdateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
-		return
+	if err := decodeJSON(r, &updateReq); err != nil {
+		writeError(w, r, err)
+		return nil
+	}
+
+	if err := validateRequest(&updateReq); err != nil {
+		writeError(w, r, err)
+		return nil
 	}
 
 	// Update the user
-	updatedUser, err := model.UpdateUser(h.db, authenticatedUser.ID, updateReq)
+	updatedUser, err := repo.UpdateUser(r.Context(), authenticatedUser.ID, updateReq)
 	if err != nil {
 		// Check for unique constraint violations
-		if strings.Contains(err.Error(), "unique constraint") && strings.Contains(err.Error(), "username") {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Username already exists"})
-			return
+		if model.IsUniqueViolation(err, "users_username_key") {
+			writeError(w, r, apierror.Conflict(apierror.CodeUsernameTaken, "Username already exists"))
+			return nil
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update user"})
+		writeError(w, r, internalError(err, "Failed to update user"))
+		return nil
+	}
+	if updateReq.Password != "" {
+		mail.Send(r.Context(), updatedUser, model.EmailPasswordChanged, map[string]any{"Changed": updatedUser.AccountUpdated})
+	}
+	return updatedUser
+}
+
+// ListUsers returns a page of all users (admin only)
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	// Authenticate user and check admin privileges
+	if _, err := authenticateAdmin(r, h.repo); err != nil {
+		writeAuthError(w, r, err, userRealm)
 		return
 	}
 
-	// Return the updated user
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(updatedUser)
+	// Parse pagination, sort and field selection parameters
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	users, err := h.repo.ListUsers(r.Context(), opts)
+	if err != nil {
+		writeError(w, r, listError(err, "Failed to retrieve users"))
+		return
+	}
+
+	writePage(w, r, users, opts.Fields)
+}
+
+// AdminUpdateUser changes another user's role, activates or deactivates
+// their account, requires them to reset their password or logs them out
+// everywhere (admin only). Each change is audit-logged.
+func (h *UserHandler) AdminUpdateUser(w http.ResponseWriter, r *http.Request) {
+	admin, err := authenticateAdmin(r, h.repo)
+	if err != nil {
+		writeAuthError(w, r, err, adminRealm)
+		return
+	}
+	userID, err := pathUUID(r, "user_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// An admin could otherwise lock themselves out
+	if userID == admin.ID {
+		writeError(w, r, apierror.New(http.StatusForbidden, apierror.CodeInsufficientPermissions, "Admins cannot change their own account here"))
+		return
+	}
+
+	var req model.AdminUpdateUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user, err := h.repo.AdminUpdateUser(r.Context(), userID, req, admin.ID)
+	if errors.Is(err, model.ErrNotFound) {
+		writeError(w, r, apierror.NotFound(apierror.CodeUserNotFound, "User not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError(err, "Failed to update user"))
+		return
+	}
+	log.Printf("User %s updated by %s", user.Username, admin.Username)
+	writeJSON(w, r, http.StatusOK, user)
 }
@@ -0,0 +1,158 @@
+// internal/handler/version.go
+package handler
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/i18n"
+	"api-server/internal/model"
+	"api-server/internal/response"
+	"api-server/internal/router"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API versions. v1 and v2 run the same handlers; the version only decides
+// how responses are shaped, so business logic is never duplicated per
+// version. v2 wraps every resource in {"data": ...}, moves paging into a
+// "pagination" object with a ready-made next link, answers deletes with
+// 204, and reports errors as RFC 7807 problem+json.
+const (
+	apiV1 = 1
+	apiV2 = 2
+)
+
+type versionKey struct{}
+
+// apiVersion tags every request in a route group with its API version
+func apiVersion(version int) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, version)))
+		})
+	}
+}
+
+// requestVersion is the API version of the route r matched, v1 by default
+func requestVersion(r *http.Request) int {
+	if version, ok := r.Context().Value(versionKey{}).(int); ok {
+		return version
+	}
+	return apiV1
+}
+
+// deprecated marks v1 responses with Deprecation, Sunset and a Link to the
+// v2 equivalent. A zero deprecatedAt is sent as "Deprecation: true"; a zero
+// sunset omits the Sunset header.
+func deprecated(deprecatedAt, sunset time.Time) router.Middleware {
+	deprecation := "true"
+	if !deprecatedAt.IsZero() {
+		deprecation = fmt.Sprintf("@%d", deprecatedAt.Unix())
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecation)
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			successor := "/v2" + strings.TrimPrefix(r.URL.Path, "/v1")
+			w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// v2Envelope wraps a single resource
+type v2Envelope struct {
+	Data any `json:"data"`
+}
+
+// v2Page is a list response
+type v2Page struct {
+	Data       any          `json:"data"`
+	Pagination v2Pagination `json:"pagination"`
+}
+
+type v2Pagination struct {
+	NextCursor *string `json:"next_cursor"`
+	HasMore    bool    `json:"has_more"`
+	// Next is the request URL for the following page
+	Next *string `json:"next"`
+}
+
+// envelope wraps a single resource for v2 and leaves it as is for v1
+func envelope(r *http.Request, v any) any {
+	if requestVersion(r) == apiV2 {
+		return v2Envelope{Data: v}
+	}
+	return v
+}
+
+// writeJSON writes v with the given status, enveloped for v2
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	response.WriteJSON(w, status, envelope(r, v))
+}
+
+// writeError writes err as the v1 error envelope or a v2 problem document.
+// Server errors are reported to error tracking.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	reportServerError(r, err)
+	err = localize(w, r, err)
+	if requestVersion(r) == apiV2 {
+		response.WriteProblem(w, err)
+		return
+	}
+	response.WriteError(w, err)
+}
+
+// localize puts an API error's messages in the language the request's
+// Accept-Language header prefers. The error code stays as it is.
+func localize(w http.ResponseWriter, r *http.Request, err error) error {
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	locale := i18n.FromRequest(r)
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", locale)
+	return apiErr.Localize(locale)
+}
+
+// writeDeleted confirms a delete: a message on v1, 204 No Content on v2
+func writeDeleted(w http.ResponseWriter, r *http.Request, message string) {
+	if requestVersion(r) == apiV2 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	response.WriteMessage(w, http.StatusOK, message)
+}
+
+// writePage writes a page of results trimmed to the requested fields
+func writePage[T any](w http.ResponseWriter, r *http.Request, page *model.Page[T], fields []string) {
+	response.WriteJSON(w, http.StatusOK, pageBody(r, page, fields))
+}
+
+// pageBody shapes a page of results for the request's API version
+func pageBody[T any](r *http.Request, page *model.Page[T], fields []string) any {
+	if requestVersion(r) != apiV2 {
+		return projectPage(page, fields)
+	}
+
+	var data any = page.Data
+	if len(fields) > 0 {
+		data = projectItems(page.Data, fields)
+	}
+	pagination := v2Pagination{NextCursor: page.NextCursor, HasMore: page.HasMore}
+	if page.NextCursor != nil {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", *page.NextCursor)
+		next.RawQuery = query.Encode()
+		link := next.RequestURI()
+		pagination.Next = &link
+	}
+	return v2Page{Data: data, Pagination: pagination}
+}
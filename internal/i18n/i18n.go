@@ -0,0 +1,116 @@
+// internal/i18n/i18n.go
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locales messages are available in. English is what the code itself
+// writes; the catalogs of the others translate it.
+const (
+	English = "en"
+	Spanish = "es"
+	Chinese = "zh"
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// catalog is one locale's messages
+type catalog struct {
+	// Errors holds the message of each API error code
+	Errors map[string]string `json:"errors"`
+	// Validation holds a template for each validation message, in which
+	// {field} and {param} are filled in
+	Validation map[string]string `json:"validation"`
+}
+
+var catalogs = mustLoad()
+
+func mustLoad() map[string]catalog {
+	files, err := localeFS.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("failed to read message catalogs: %v", err))
+	}
+	catalogs := make(map[string]catalog, len(files))
+	for _, f := range files {
+		data, err := localeFS.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("failed to read message catalog %s: %v", f.Name(), err))
+		}
+		var c catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			panic(fmt.Sprintf("failed to parse message catalog %s: %v", f.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(f.Name(), ".json")] = c
+	}
+	return catalogs
+}
+
+// Negotiate picks the locale an Accept-Language header prefers, by quality
+// and then order, matching on the primary language so es-MX gets Spanish
+// and zh-CN gets Chinese. Anything else gets English.
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		locale string
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if qs, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := catalogs[primary]; ok {
+			candidates = append(candidates, candidate{primary, q})
+		} else if primary == "*" {
+			candidates = append(candidates, candidate{English, q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	if len(candidates) == 0 {
+		return English
+	}
+	return candidates[0].locale
+}
+
+// FromRequest returns the locale r's Accept-Language header prefers
+func FromRequest(r *http.Request) string {
+	return Negotiate(r.Header.Get("Accept-Language"))
+}
+
+// Error returns the message of an API error code in locale, or fallback,
+// the English message the code wrote, when the catalog has none
+func Error(locale, code, fallback string) string {
+	if message, ok := catalogs[locale].Errors[code]; ok {
+		return message
+	}
+	return fallback
+}
+
+// Validation fills in the validation message named key for a field and
+// rule parameter, in locale or else in English. It returns "" for a key no
+// catalog has.
+func Validation(locale, key, field, param string) string {
+	template, ok := catalogs[locale].Validation[key]
+	if !ok {
+		if template, ok = catalogs[English].Validation[key]; !ok {
+			return ""
+		}
+	}
+	return strings.NewReplacer("{field}", field, "{param}", param).Replace(template)
+}
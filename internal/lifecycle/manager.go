@@ -0,0 +1,140 @@
+// internal/lifecycle/manager.go
+package lifecycle
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/errortracking"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// batchSize caps how many traces are archived per sweep
+const batchSize = 100
+
+// Manager moves traces from past semesters to coldline storage and restores
+// them on demand. It also archives courses from past semesters when
+// courseArchiveAfter is set.
+type Manager struct {
+	repo               repository.Repository
+	storage            storage.Storage
+	interval           time.Duration
+	archiveAfter       int
+	courseArchiveAfter int
+}
+
+func NewManager(repo repository.Repository, store storage.Storage, cfg *config.Config) *Manager {
+	return &Manager{
+		repo:               repo,
+		storage:            store,
+		interval:           cfg.LifecycleInterval,
+		archiveAfter:       cfg.LifecycleArchiveAfter,
+		courseArchiveAfter: cfg.LifecycleCourseArchiveAfter,
+	}
+}
+
+// Run sweeps for archivable traces on every interval until ctx is cancelled
+func (m *Manager) Run(ctx context.Context) {
+	log.Printf("Trace lifecycle manager started, interval %s", m.interval)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		archived, err := m.ArchiveEligible(ctx)
+		if err != nil {
+			log.Printf("Trace lifecycle sweep failed: %v", err)
+			errortracking.Capture(err, "lifecycle", nil)
+		} else if archived > 0 {
+			log.Printf("Trace lifecycle sweep archived %d traces", archived)
+		}
+		if m.courseArchiveAfter > 0 {
+			if courses, err := m.ArchiveCourses(ctx); err != nil {
+				log.Printf("Course lifecycle sweep failed: %v", err)
+				errortracking.Capture(err, "lifecycle", nil)
+			} else if courses > 0 {
+				log.Printf("Course lifecycle sweep archived %d courses", courses)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ArchiveEligible archives traces belonging to courses at least archiveAfter
+// semesters older than the current one and returns how many were moved. The
+// traces are archived concurrently, as many at once as the storage pool has
+// workers.
+func (m *Manager) ArchiveEligible(ctx context.Context) (int, error) {
+	cutoff := model.CurrentSemesterIndex(time.Now()) - m.archiveAfter + 1
+
+	traces, err := m.repo.GetArchivableTraces(ctx, cutoff, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		archived atomic.Int64
+		wg       sync.WaitGroup
+	)
+	for _, trace := range traces {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bucketURL, err := m.storage.Archive(ctx, trace.FileName)
+			if err != nil {
+				log.Printf("Failed to archive trace %s: %v", trace.ID, err)
+				return
+			}
+			if err := m.repo.UpdateTraceStorage(ctx, trace.ID, model.StorageTierColdline, bucketURL); err != nil {
+				log.Printf("Failed to record archived location for trace %s: %v", trace.ID, err)
+				return
+			}
+			archived.Add(1)
+		}()
+	}
+	wg.Wait()
+
+	return int(archived.Load()), ctx.Err()
+}
+
+// ArchiveCourses archives the courses of every tenant that are at least
+// courseArchiveAfter semesters older than the current one and returns how
+// many were archived. Courses an admin has unarchived stay that way.
+func (m *Manager) ArchiveCourses(ctx context.Context) (int, error) {
+	cutoff := model.CurrentSemesterIndex(time.Now()) - m.courseArchiveAfter + 1
+	return m.repo.ArchiveOldCourses(ctx, cutoff)
+}
+
+// Restore moves an archived trace back to standard storage and returns the updated record
+func (m *Manager) Restore(ctx context.Context, courseID, traceID uuid.UUID) (*model.Trace, error) {
+	trace, err := m.repo.GetTraceByID(ctx, courseID, traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing to do when the trace is already in standard storage
+	if trace.StorageTier == model.StorageTierStandard {
+		return trace, nil
+	}
+
+	bucketURL, err := m.storage.Restore(ctx, trace.FileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.repo.UpdateTraceStorage(ctx, trace.ID, model.StorageTierStandard, bucketURL); err != nil {
+		return nil, err
+	}
+
+	return m.repo.GetTraceByID(ctx, courseID, traceID)
+}
@@ -0,0 +1,51 @@
+// internal/lifecycle/partitions.go
+package lifecycle
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/errortracking"
+	"api-server/internal/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// Partitioner keeps api.traces partitioned ahead of time, so an upload never
+// finds no partition for its semester. It runs whether or not the storage
+// lifecycle is enabled.
+type Partitioner struct {
+	repo        repository.Repository
+	interval    time.Duration
+	monthsAhead int
+}
+
+func NewPartitioner(repo repository.Repository, cfg *config.Config) *Partitioner {
+	return &Partitioner{
+		repo:        repo,
+		interval:    cfg.TracePartitionInterval,
+		monthsAhead: cfg.TracePartitionMonthsAhead,
+	}
+}
+
+// Run creates the missing partitions now and on every interval until ctx is
+// cancelled
+func (p *Partitioner) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		created, err := p.repo.CreateTracePartitions(ctx, p.monthsAhead)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to create trace partitions: %v", err)
+			errortracking.Capture(err, "partitions", nil)
+		} else if created > 0 {
+			log.Printf("Created %d trace partitions", created)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// internal/lifecycle/reconcile.go
+package lifecycle
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/storage"
+	"context"
+	"log"
+)
+
+// ReconcileReport lists where the traces table and storage disagree
+type ReconcileReport struct {
+	Checked  int
+	Missing  []model.Trace
+	Orphaned []storage.Object
+	Fixed    int
+}
+
+// Reconcile compares stored traces with the objects in storage. Traces whose
+// object no longer exists are reported as missing and, when fix is set,
+// marked failed; objects no trace points at are reported as orphaned but
+// never deleted.
+func (m *Manager) Reconcile(ctx context.Context, fix bool) (*ReconcileReport, error) {
+	traces, err := m.repo.ListStoredTraces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := m.storage.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byURL := make(map[string]storage.Object, len(objects))
+	for _, object := range objects {
+		byURL[object.URL] = object
+	}
+
+	report := &ReconcileReport{Checked: len(traces)}
+	referenced := make(map[string]bool, len(traces))
+	for _, trace := range traces {
+		referenced[trace.BucketURL] = true
+		if _, ok := byURL[trace.BucketURL]; ok {
+			continue
+		}
+
+		report.Missing = append(report.Missing, trace)
+		if !fix {
+			continue
+		}
+		if err := m.repo.MarkTraceFailed(ctx, trace.ID); err != nil {
+			log.Printf("Failed to mark trace %s as failed: %v", trace.ID, err)
+			continue
+		}
+		report.Fixed++
+	}
+
+	for _, object := range objects {
+		if !referenced[object.URL] {
+			report.Orphaned = append(report.Orphaned, object)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,27 @@
+// internal/llm/llm.go
+package llm
+
+import (
+	"api-server/internal/config"
+	"context"
+)
+
+// summaryPrompt instructs the model how to digest a syllabus for students
+const summaryPrompt = `You summarize course syllabi for students. Given the text of a syllabus, write a concise digest covering the course goals, topics, grading breakdown, major deadlines and policies students must know. Use short paragraphs or bullet points, and do not invent details that are not in the text.`
+
+// Summarizer digests a trace's extracted text
+type Summarizer interface {
+	// Model names the model that wrote the summary, for caching
+	Model() string
+	Summarize(ctx context.Context, text string) (string, error)
+}
+
+// New builds the Summarizer selected by LLM_BACKEND. With "none" it returns
+// nil and summaries are unavailable.
+func New(cfg *config.Config) Summarizer {
+	switch cfg.LLMBackend {
+	case "openai":
+		return NewOpenAI(cfg.LLMURL, cfg.LLMAPIKey, cfg.LLMModel, cfg.LLMMaxTokens, cfg.LLMTimeout)
+	}
+	return nil
+}
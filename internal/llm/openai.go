@@ -0,0 +1,99 @@
+// internal/llm/openai.go
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAI summarizes text through an OpenAI-compatible /chat/completions
+// endpoint
+type OpenAI struct {
+	url       string
+	apiKey    string
+	model     string
+	maxTokens int
+	client    *http.Client
+}
+
+func NewOpenAI(url, apiKey, model string, maxTokens int, timeout time.Duration) *OpenAI {
+	return &OpenAI{
+		url:       url,
+		apiKey:    apiKey,
+		model:     model,
+		maxTokens: maxTokens,
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (o *OpenAI) Model() string {
+	return o.model
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model     string        `json:"model"`
+	Messages  []chatMessage `json:"messages"`
+	MaxTokens int           `json:"max_tokens,omitempty"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+func (o *OpenAI) Summarize(ctx context.Context, text string) (string, error) {
+	body, err := json.Marshal(chatRequest{
+		Model: o.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: text},
+		},
+		MaxTokens: o.maxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call LLM API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("LLM API returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+
+	var result chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode LLM API response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("LLM API returned no choices")
+	}
+	summary := strings.TrimSpace(result.Choices[0].Message.Content)
+	if summary == "" {
+		return "", fmt.Errorf("LLM API returned an empty summary")
+	}
+	return summary, nil
+}
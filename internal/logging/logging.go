@@ -0,0 +1,58 @@
+// internal/logging/logging.go
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// level is shared by the default logger so it can be changed at runtime
+var level = new(slog.LevelVar)
+
+// Setup installs a text logger on stderr as the slog default at the named
+// level. Calls through the standard log package are routed to it at info.
+// Everything it writes is redacted first; see NewRedactingHandler.
+func Setup(name string) error {
+	if err := SetLevel(name); err != nil {
+		return err
+	}
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(NewRedactingHandler(handler)))
+	return nil
+}
+
+// SetLevel changes the level of the default logger: debug, info, warn or error
+func SetLevel(name string) error {
+	var l slog.Level
+	switch strings.ToLower(name) {
+	case "debug":
+		l = slog.LevelDebug
+	case "info":
+		l = slog.LevelInfo
+	case "warn":
+		l = slog.LevelWarn
+	case "error":
+		l = slog.LevelError
+	default:
+		return fmt.Errorf("unknown log level %q", name)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Level returns the current level's name in lower case
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// OpenAccessLog returns where access logs go: stdout, apart from the
+// application log on stderr, or else the file at path, appended to
+func OpenAccessLog(path string) (io.Writer, error) {
+	if path == "" {
+		return os.Stdout, nil
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+}
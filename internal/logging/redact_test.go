@@ -0,0 +1,42 @@
+// internal/logging/redact_test.go
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactShareLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"path", "/v1/shared/Zm9vYmFyYmF6", "/v1/shared/[REDACTED]"},
+		{"path with query", "/v2/shared/Zm9vYmFyYmF6?download=1", "/v2/shared/[REDACTED]?download=1"},
+		{"URL", "referer https://api.example.edu/v1/shared/Zm9v-YmFy_YmF6 sent", "referer https://api.example.edu/v1/shared/[REDACTED] sent"},
+		{"request line", `"GET /v1/shared/Zm9vYmFyYmF6 HTTP/1.1" 200`, `"GET /v1/shared/[REDACTED] HTTP/1.1" 200`},
+		{"query token", "/v1/course?token=abc&limit=5", "/v1/course?token=[REDACTED]&limit=5"},
+		{"other paths", "/v1/course/5d0c/trace/9f1e/shares", "/v1/course/5d0c/trace/9f1e/shares"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRedactingHandlerSharePath checks the request log's path attribute,
+// which the Logging middleware writes for every request
+func TestRedactingHandlerSharePath(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(NewRedactingHandler(slog.NewTextHandler(&out, nil)))
+	logger.Info("Handled request", "method", "GET", "path", "/v1/shared/Zm9vYmFyYmF6")
+	if strings.Contains(out.String(), "Zm9vYmFyYmF6") {
+		t.Errorf("share token was logged: %s", out.String())
+	}
+}
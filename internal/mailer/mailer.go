@@ -0,0 +1,124 @@
+// internal/mailer/mailer.go
+package mailer
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"context"
+	"embed"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// sendTimeout bounds each email, including the preference lookup
+const sendTimeout = 30 * time.Second
+
+//go:embed templates/*.txt
+var templateFS embed.FS
+
+// Message is a plain text email
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+}
+
+// Driver delivers messages from a fixed sender address
+type Driver interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Mailer sends users templated emails about their account and traces, one
+// template per model.EmailEvents entry, unless they have turned that email
+// off. With MAIL_DRIVER=none nothing is sent.
+type Mailer struct {
+	driver    Driver
+	repo      repository.Repository
+	templates map[string]*template.Template
+}
+
+// New builds a Mailer around the MAIL_DRIVER selected by cfg
+func New(cfg *config.Config, repo repository.Repository) *Mailer {
+	var driver Driver
+	switch cfg.MailDriver {
+	case "smtp":
+		driver = NewSMTP(cfg.MailSMTPAddr, cfg.MailSMTPUsername, cfg.MailSMTPPassword, cfg.MailFrom)
+	case "sendgrid":
+		driver = NewSendGrid(cfg.MailSendGridAPIKey, cfg.MailFrom)
+	}
+	return NewWithDriver(driver, repo)
+}
+
+// NewWithDriver builds a Mailer that sends through driver; a nil driver
+// sends nothing
+func NewWithDriver(driver Driver, repo repository.Repository) *Mailer {
+	templates := map[string]*template.Template{}
+	for _, event := range model.EmailEvents {
+		templates[event] = template.Must(template.ParseFS(templateFS, "templates/"+event+".txt"))
+	}
+	return &Mailer{driver: driver, repo: repo, templates: templates}
+}
+
+// Send emails user about event in the background, unless they have turned
+// that email off. The template sees the user as .User and data's entries
+// by name. ctx must carry the user's tenant; its cancellation is ignored,
+// so the email outlives the request that triggered it.
+func (m *Mailer) Send(ctx context.Context, user *model.User, event string, data map[string]any) {
+	if m == nil || m.driver == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+		if err := m.send(ctx, user, event, data); err != nil {
+			log.Printf("Failed to send %s email to user %s via %s: %v", event, user.ID, m.driver.Name(), err)
+		}
+	}()
+}
+
+func (m *Mailer) send(ctx context.Context, user *model.User, event string, data map[string]any) error {
+	prefs, err := m.repo.GetNotificationPreferences(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read notification preferences: %w", err)
+	}
+	if !prefs.EmailEnabled(event) {
+		return nil
+	}
+	msg, err := m.render(user, event, data)
+	if err != nil {
+		return err
+	}
+	return m.driver.Send(ctx, msg)
+}
+
+// render fills in the event's template. Its "subject" block is the subject
+// line and the rest is the body.
+func (m *Mailer) render(user *model.User, event string, data map[string]any) (Message, error) {
+	tmpl, ok := m.templates[event]
+	if !ok {
+		return Message{}, fmt.Errorf("no email template for %q", event)
+	}
+	values := map[string]any{"User": user}
+	for key, value := range data {
+		values[key] = value
+	}
+
+	var subject, body strings.Builder
+	if err := tmpl.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s subject: %w", event, err)
+	}
+	if err := tmpl.Execute(&body, values); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s email: %w", event, err)
+	}
+	return Message{
+		To:      []string{user.Email},
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(body.String()) + "\n",
+	}, nil
+}
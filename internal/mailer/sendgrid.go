@@ -0,0 +1,82 @@
+// internal/mailer/sendgrid.go
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// sendGridURL is SendGrid's v3 mail send endpoint
+const sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGrid sends mail through the SendGrid web API
+type SendGrid struct {
+	apiKey string
+	from   string
+	client *http.Client
+}
+
+func NewSendGrid(apiKey, from string) *SendGrid {
+	return &SendGrid{apiKey: apiKey, from: from, client: &http.Client{Timeout: sendTimeout}}
+}
+
+func (s *SendGrid) Name() string {
+	return "sendgrid"
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+func (s *SendGrid) Send(ctx context.Context, msg Message) error {
+	to := make([]sendGridAddress, len(msg.To))
+	for i, addr := range msg.To {
+		to[i] = sendGridAddress{Email: addr}
+	}
+	body, err := json.Marshal(sendGridRequest{
+		Personalizations: []sendGridPersonalization{{To: to}},
+		From:             sendGridAddress{Email: s.from},
+		Subject:          msg.Subject,
+		Content:          []sendGridContent{{Type: "text/plain", Value: msg.Text}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call SendGrid: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("SendGrid returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
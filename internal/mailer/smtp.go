@@ -0,0 +1,65 @@
+// internal/mailer/smtp.go
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP sends mail through a relay, authenticating with PLAIN when a
+// username is set. The relay must offer STARTTLS for authentication.
+type SMTP struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTP(addr, username, password, from string) *SMTP {
+	s := &SMTP{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *SMTP) Name() string {
+	return "smtp"
+}
+
+// Send delivers msg to every recipient. smtp.SendMail takes no context, so
+// it runs until it finishes even after ctx is done.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	var data strings.Builder
+	fmt.Fprintf(&data, "From: %s\r\n", s.from)
+	fmt.Fprintf(&data, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&data, "Subject: %s\r\n", headerValue(msg.Subject))
+	fmt.Fprintf(&data, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	data.WriteString("MIME-Version: 1.0\r\n")
+	data.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	data.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	data.WriteString("\r\n")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.addr, s.auth, s.from, msg.To, []byte(data.String()))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send email via %s: %w", s.addr, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// headerValue keeps a header on one line
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
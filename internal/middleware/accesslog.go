@@ -0,0 +1,146 @@
+// internal/middleware/accesslog.go
+package middleware
+
+import (
+	"api-server/internal/logging"
+	"api-server/internal/router"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Access log formats
+const (
+	AccessLogNone     = "none"
+	AccessLogCombined = "combined"
+	AccessLogJSON     = "json"
+)
+
+// AccessLogger writes one line per request, apart from the application
+// log, in the Apache combined format or as JSON. Requests to excluded paths
+// are skipped, and only samplePercent of the rest are written, except
+// server errors, which always are.
+type AccessLogger struct {
+	mu            sync.Mutex
+	out           io.Writer
+	format        string
+	samplePercent int
+	exclude       []string
+}
+
+// NewAccessLogger returns nil, which logs nothing, for the none format
+func NewAccessLogger(out io.Writer, format string, samplePercent int, exclude []string) *AccessLogger {
+	if format == AccessLogNone || format == "" {
+		return nil
+	}
+	return &AccessLogger{out: out, format: format, samplePercent: samplePercent, exclude: exclude}
+}
+
+// accessLogUser is filled in by authentication further down the chain,
+// which only sees a copy of the request
+type accessLogUser struct{ id string }
+
+type accessLogUserKey struct{}
+
+// SetAccessLogUser records who made the request for its access log line
+func SetAccessLogUser(ctx context.Context, userID string) {
+	if u, ok := ctx.Value(accessLogUserKey{}).(*accessLogUser); ok {
+		u.id = userID
+	}
+}
+
+// accessLogEntry is a request's JSON access log line
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	ClientIP   string    `json:"client_ip"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+	UserID     string    `json:"user_id,omitempty"`
+	RequestID  string    `json:"request_id"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// AccessLog writes each request to l once it has been handled. A nil l
+// disables access logging.
+func AccessLog(l *AccessLogger) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(l.exclude, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			user := &accessLogUser{}
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), accessLogUserKey{}, user)))
+
+			if sw.Status() < http.StatusInternalServerError && rand.IntN(100) >= l.samplePercent {
+				return
+			}
+			l.write(accessLogEntry{
+				Time:       start,
+				ClientIP:   ClientIP(r).String(),
+				Method:     r.Method,
+				URI:        logging.Redact(r.URL.RequestURI()),
+				Proto:      r.Proto,
+				Status:     sw.Status(),
+				Bytes:      sw.Bytes(),
+				DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+				UserID:     user.id,
+				RequestID:  RequestIDFromContext(r.Context()),
+				Referer:    logging.Redact(r.Referer()),
+				UserAgent:  r.UserAgent(),
+			})
+		})
+	}
+}
+
+func (l *AccessLogger) write(e accessLogEntry) {
+	var line []byte
+	if l.format == AccessLogJSON {
+		line, _ = json.Marshal(e)
+		line = append(line, '\n')
+	} else {
+		line = []byte(combinedLine(e))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(line)
+}
+
+// combinedLine is e in the Apache combined format, with the user ID as the
+// authenticated user, followed by the duration in milliseconds and the
+// request ID
+func combinedLine(e accessLogEntry) string {
+	bytes := "-"
+	if e.Bytes > 0 {
+		bytes = strconv.FormatInt(e.Bytes, 10)
+	}
+	return fmt.Sprintf("%s - %s [%s] %s %d %s %s %s %.3f %s\n",
+		e.ClientIP, orDash(e.UserID), e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(e.Method+" "+e.URI+" "+e.Proto), e.Status, bytes,
+		strconv.Quote(orDash(e.Referer)), strconv.Quote(orDash(e.UserAgent)),
+		e.DurationMS, orDash(e.RequestID))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
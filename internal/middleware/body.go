@@ -0,0 +1,27 @@
+// internal/middleware/body.go
+package middleware
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/i18n"
+	"api-server/internal/response"
+	"net/http"
+)
+
+// MaxBodySize rejects requests whose body is larger than limit bytes with a
+// 413. Bodies without a Content-Length are cut off by http.MaxBytesReader,
+// which handlers surface as a 413 when decoding.
+func MaxBodySize(limit int64, next http.Handler) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			response.WriteError(w, apierror.PayloadTooLarge(limit).Localize(i18n.FromRequest(r)))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,80 @@
+// internal/middleware/cache.go
+package middleware
+
+import (
+	"api-server/internal/router"
+	"api-server/internal/tenant"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// NoStore is the policy for responses no cache may keep
+const NoStore = "no-store"
+
+// tenantVary names the request headers that pick the tenant, besides the
+// Host header caches already key on
+var tenantVary = tenant.APIKeyHeader + ", " + tenant.Header
+
+// PublicCache builds a policy that lets shared caches such as a CDN keep a
+// response for maxAge, then serve it stale for up to staleWhileRevalidate
+// while refetching it. A zero maxAge makes caches revalidate every time.
+func PublicCache(maxAge, staleWhileRevalidate time.Duration) string {
+	if maxAge <= 0 {
+		return "public, no-cache"
+	}
+	policy := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	if staleWhileRevalidate > 0 {
+		policy += fmt.Sprintf(", stale-while-revalidate=%d", int(staleWhileRevalidate.Seconds()))
+	}
+	return policy
+}
+
+// CacheControl sends policy on successful and 304 responses, and no-store on
+// everything else so errors are never cached. A Cache-Control header set
+// further in, by a route's own policy or the handler, is left alone, so a
+// group can default to no-store while single routes opt in to caching.
+// Responses it lets caches keep vary on the tenant headers, since the
+// tenant can come from them rather than the URL.
+func CacheControl(policy string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&cacheWriter{ResponseWriter: w, policy: policy}, r)
+		})
+	}
+}
+
+type cacheWriter struct {
+	http.ResponseWriter
+	policy      string
+	wroteHeader bool
+}
+
+func (cw *cacheWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if cw.Header().Get("Cache-Control") == "" {
+			if status < 300 || status == http.StatusNotModified {
+				cw.Header().Set("Cache-Control", cw.policy)
+				if cw.policy != NoStore {
+					cw.Header().Add("Vary", tenantVary)
+				}
+			} else {
+				cw.Header().Set("Cache-Control", NoStore)
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cacheWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *cacheWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,146 @@
+// internal/middleware/capture.go
+package middleware
+
+import (
+	"api-server/internal/capture"
+	"api-server/internal/logging"
+	"api-server/internal/router"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// capturedSecretHeaders have their values replaced whole in captures
+var capturedSecretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// Capture records the requests recorder picks, with their responses, for
+// admins troubleshooting a client integration. Bodies are kept up to the
+// recorder's size cap, and credentials, emails and sensitive JSON fields
+// are redacted before anything is stored. Requests exempt reports true for,
+// such as those reading the captures, are left alone. A nil recorder
+// disables it.
+func Capture(recorder *capture.Recorder, exempt func(*http.Request) bool) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		if recorder == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := RequestIDFromContext(r.Context())
+			if exempt(r) || !recorder.ShouldCapture(requestID) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			requestBody := &payloadBuffer{max: recorder.MaxBodyBytes()}
+			requestHeaders := capturedHeaders(r.Header)
+			if r.Body != nil {
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.TeeReader(r.Body, requestBody), r.Body}
+			}
+			cw := &payloadWriter{ResponseWriter: w, body: payloadBuffer{max: recorder.MaxBodyBytes()}}
+			next.ServeHTTP(cw, r)
+
+			status := cw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			recorder.Add(capture.Capture{
+				RequestID:       requestID,
+				Time:            start,
+				Method:          r.Method,
+				URI:             logging.Redact(r.URL.RequestURI()),
+				Status:          status,
+				DurationMS:      float64(time.Since(start).Microseconds()) / 1000,
+				RequestHeaders:  requestHeaders,
+				RequestBody:     requestBody.captured(r.Header.Get("Content-Type")),
+				ResponseHeaders: capturedHeaders(w.Header()),
+				ResponseBody:    cw.body.captured(w.Header().Get("Content-Type")),
+			})
+		})
+	}
+}
+
+// payloadBuffer keeps the first max bytes written to it and counts the rest
+type payloadBuffer struct {
+	buf  bytes.Buffer
+	max  int
+	size int64
+}
+
+func (b *payloadBuffer) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+	if room := b.max - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// captured is the body as a capture keeps it: redacted content for textual
+// types, just the size for the rest
+func (b *payloadBuffer) captured(contentType string) capture.Body {
+	body := capture.Body{ContentType: contentType, Size: b.size, Truncated: b.size > int64(b.buf.Len())}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		body.Content = logging.RedactJSON(b.buf.Bytes())
+	case strings.HasPrefix(mediaType, "text/"), mediaType == "application/xml", strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/x-www-form-urlencoded":
+		body.Content = logging.Redact(b.buf.String())
+	default:
+		body.Truncated = false
+	}
+	return body
+}
+
+// payloadWriter passes the response on while keeping its status and the
+// start of its body
+type payloadWriter struct {
+	http.ResponseWriter
+	status int
+	body   payloadBuffer
+}
+
+func (cw *payloadWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *payloadWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	n, err := cw.ResponseWriter.Write(p)
+	cw.body.Write(p[:n])
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *payloadWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// capturedHeaders is a copy of h with credentials replaced and the rest
+// redacted like log output
+func capturedHeaders(h http.Header) http.Header {
+	headers := h.Clone()
+	for name, values := range headers {
+		secret := slices.Contains(capturedSecretHeaders, http.CanonicalHeaderKey(name))
+		for i, v := range values {
+			if secret {
+				values[i] = "[REDACTED]"
+			} else {
+				values[i] = logging.Redact(v)
+			}
+		}
+	}
+	return headers
+}
@@ -0,0 +1,41 @@
+// internal/middleware/chaos.go
+package middleware
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/chaos"
+	"api-server/internal/i18n"
+	"api-server/internal/response"
+	"api-server/internal/router"
+	"errors"
+	"net/http"
+)
+
+// Chaos delays and fails requests as the http fault in faults says, so
+// clients' retries can be tested against the real API. Failed requests get a
+// 503 FAULT_INJECTED with Retry-After. Requests exempt reports true for, such
+// as those changing the faults, are left alone. A nil injector disables it.
+func Chaos(faults *chaos.Injector, exempt func(*http.Request) bool) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		if faults == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			err := faults.Inject(r.Context(), chaos.DependencyHTTP)
+			if errors.Is(err, chaos.ErrInjected) {
+				w.Header().Set("Retry-After", "1")
+				response.WriteError(w, apierror.New(http.StatusServiceUnavailable, apierror.CodeFaultInjected, "Fault injected for testing").Localize(i18n.FromRequest(r)))
+				return
+			}
+			if err != nil {
+				// The client went away during the injected latency
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
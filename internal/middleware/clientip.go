@@ -0,0 +1,122 @@
+// internal/middleware/clientip.go
+package middleware
+
+import (
+	"api-server/internal/router"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+type localPeerKey struct{}
+
+// WithLocalPeer marks a connection's context as coming from the same host,
+// as every connection to a Unix socket does. Its peer has no address, so it
+// is taken to be loopback and trusted like TRUSTED_PROXIES: it is the sidecar
+// proxy the socket is there for.
+func WithLocalPeer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, localPeerKey{}, true)
+}
+
+// RealIP resolves each request's client address. Behind a load balancer
+// the connection comes from the proxy, so when the peer is one of
+// trustedProxies (CIDRs or bare addresses) the client is read from
+// X-Forwarded-For: the rightmost address that is not itself a trusted proxy,
+// since addresses further left were written by the client and can't be
+// believed. Without X-Forwarded-For, a trusted peer's X-Real-IP is used.
+// Headers from any other peer are ignored.
+func RealIP(trustedProxies []string) (router.Middleware, error) {
+	proxies, err := parsePrefixes(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr := resolveClientIP(r, proxies)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, addr)))
+		})
+	}, nil
+}
+
+// ClientIP returns the address RealIP resolved, or else the peer's. It is
+// invalid when the address can't be parsed.
+func ClientIP(r *http.Request) netip.Addr {
+	if addr, ok := r.Context().Value(clientIPKey{}).(netip.Addr); ok {
+		return addr
+	}
+	return peerIP(r)
+}
+
+func resolveClientIP(r *http.Request, proxies []netip.Prefix) netip.Addr {
+	addr := peerIP(r)
+	if local, _ := r.Context().Value(localPeerKey{}).(bool); local {
+		addr = netip.IPv6Loopback()
+	} else if !addr.IsValid() || !contains(proxies, addr) {
+		return addr
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return realIP.Unmap()
+		}
+		return addr
+	}
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}
+		}
+		addr = hop.Unmap()
+		if !contains(proxies, addr) {
+			return addr
+		}
+	}
+	// Every hop is a proxy, so the leftmost is the closest to a client
+	return addr
+}
+
+// peerIP is the address of the connection, without its port
+func peerIP(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// parsePrefixes parses CIDRs, taking a bare address as a prefix of its own
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a CIDR or IP address", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// internal/middleware/concurrency.go
+package middleware
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/i18n"
+	"api-server/internal/response"
+	"api-server/internal/router"
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyLimiter caps the requests of one pool in flight at once. A
+// request over the cap waits for a slot behind at most queue others, for at
+// most wait; the rest are shed.
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+	queue int64
+	wait  time.Duration
+
+	queued atomic.Int64
+	shed   atomic.Int64
+}
+
+// NewConcurrencyLimiter allows limit requests in flight, queue more waiting
+// for up to wait each
+func NewConcurrencyLimiter(limit, queue int, wait time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		slots: make(chan struct{}, limit),
+		queue: int64(queue),
+		wait:  wait,
+	}
+}
+
+// InFlight is how many requests hold a slot
+func (l *ConcurrencyLimiter) InFlight() int {
+	return len(l.slots)
+}
+
+// Queued is how many requests are waiting for a slot
+func (l *ConcurrencyLimiter) Queued() int64 {
+	return l.queued.Load()
+}
+
+// Shed is how many requests have been turned away
+func (l *ConcurrencyLimiter) Shed() int64 {
+	return l.shed.Load()
+}
+
+// acquire takes a slot, waiting in the queue if it has room. It reports
+// false when the request should be shed.
+func (l *ConcurrencyLimiter) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.queued.Add(1) > l.queue {
+		l.queued.Add(-1)
+		return false
+	}
+	defer l.queued.Add(-1)
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *ConcurrencyLimiter) release() {
+	<-l.slots
+}
+
+// LimitConcurrency sheds requests l has no slot for with a 503 and
+// Retry-After, so a burst is refused quickly instead of slowing every
+// request down. A nil limiter disables limiting.
+func LimitConcurrency(l *ConcurrencyLimiter) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		retryAfter := strconv.Itoa(max(1, int(math.Ceil(l.wait.Seconds()))))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !l.acquire(r.Context()) {
+				l.shed.Add(1)
+				w.Header().Set("Retry-After", retryAfter)
+				response.WriteError(w, apierror.New(http.StatusServiceUnavailable, apierror.CodeServerOverloaded, "Too many requests in progress").Localize(i18n.FromRequest(r)))
+				return
+			}
+			defer l.release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// internal/middleware/logging.go
+package middleware
+
+import (
+	"api-server/internal/router"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Logging logs each request at debug level once it has been handled
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		slog.Debug("Handled request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"client_ip", ClientIP(r).String(),
+			"route", router.Route(r),
+			"status", sw.Status(),
+			"duration", time.Since(start),
+			"request_id", RequestIDFromContext(r.Context()),
+		)
+	})
+}
+
+// statusWriter records the status code and body size written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(p)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+// Bytes is the size of the body written
+func (sw *statusWriter) Bytes() int64 {
+	return sw.bytes
+}
+
+// Status is the status sent, 200 if the handler wrote nothing
+func (sw *statusWriter) Status() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
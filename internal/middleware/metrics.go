@@ -0,0 +1,44 @@
+// internal/middleware/metrics.go
+package middleware
+
+import (
+	"api-server/internal/router"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CountRequests increments counter, labelled by route pattern and method,
+// for every request
+func CountRequests(counter *prometheus.CounterVec) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			counter.WithLabelValues(router.Route(r), r.Method).Inc()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ObserveLatency records how long each request took in histogram, labelled
+// by route pattern, method and status. A request that is part of a sampled
+// trace is recorded with its trace ID as an exemplar, which links the
+// bucket it lands in to the trace.
+func ObserveLatency(histogram *prometheus.HistogramVec) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+
+			observer := histogram.WithLabelValues(router.Route(r), r.Method, strconv.Itoa(sw.Status()))
+			seconds := time.Since(start).Seconds()
+			if traceID := TraceIDFromContext(r.Context()); traceID != "" {
+				observer.(prometheus.ExemplarObserver).ObserveWithExemplar(seconds, prometheus.Labels{"trace_id": traceID})
+				return
+			}
+			observer.Observe(seconds)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// internal/middleware/openapi.go
+package middleware
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/response"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+)
+
+// OpenAPIValidator checks every request and response against an OpenAPI
+// document. It is meant for development and test environments: it buffers
+// whole bodies, and any drift between the handlers and the spec replaces the
+// response with a 500 CONTRACT_VIOLATION so it can't go unnoticed.
+type OpenAPIValidator struct {
+	router  routers.Router
+	options *openapi3filter.Options
+}
+
+// NewOpenAPIValidator parses and validates spec. Authentication is checked
+// by the handlers, so security requirements in the spec are not enforced.
+func NewOpenAPIValidator(spec []byte) (*OpenAPIValidator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI spec: %w", err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %w", err)
+	}
+	// Keep violation messages to one line instead of dumping the schema
+	openapi3.SchemaErrorDetailsDisabled = true
+	// Uploaded trace files, data export archives and thumbnails are opaque to
+	// validation, but multipart parts and downloads need a decoder for their
+	// content type
+	openapi3filter.RegisterBodyDecoder("application/pdf", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/octet-stream", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/zip", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/vnd.openxmlformats-officedocument.wordprocessingml.document", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/vnd.openxmlformats-officedocument.presentationml.presentation", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("image/png", openapi3filter.FileBodyDecoder)
+	// Server-sent event streams are checked as text
+	openapi3filter.RegisterBodyDecoder("text/event-stream", openapi3filter.RegisteredBodyDecoder("text/plain"))
+	// JSON Patch has a decoder already; JSON Merge Patch is JSON too
+	openapi3filter.RegisterBodyDecoder("application/merge-patch+json", openapi3filter.JSONBodyDecoder)
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OpenAPI router: %w", err)
+	}
+
+	return &OpenAPIValidator{
+		router: router,
+		options: &openapi3filter.Options{
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			IncludeResponseStatus: true,
+		},
+	}, nil
+}
+
+// Wrap validates the traffic through next. A request the spec rejects is
+// only a violation if the handler accepted it; handler 4xx responses are
+// passed through so clients still see the handler's error.
+func (v *OpenAPIValidator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			// Let the handler report the unreadable or oversized body
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Body.Close()
+
+		route, pathParams, routeErr := v.router.FindRoute(r)
+		for _, value := range pathParams {
+			// The legacy router lets a path parameter match an empty
+			// segment, so /v1/course would otherwise match /v1/course/{course_id}
+			if value == "" {
+				route, routeErr = nil, &routers.RouteError{Reason: routers.ErrPathNotFound.Error()}
+			}
+		}
+
+		cw := &captureWriter{header: make(http.Header)}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(cw, r)
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+
+		// The spec router ignores trailing slashes, so a request ServeMux
+		// itself rejected can still match a documented route
+		if routeErr == nil && muxFallback(cw.status) && strings.HasPrefix(cw.header.Get("Content-Type"), "text/plain") {
+			cw.flush(w)
+			return
+		}
+		if routeErr != nil {
+			if !muxFallback(cw.status) {
+				v.violation(w, r, cw.status, fmt.Errorf("route is not documented: %w", routeErr))
+				return
+			}
+			cw.flush(w)
+			return
+		}
+
+		// Validation reads the body itself, so give it a fresh copy
+		validationReq := r.Clone(context.WithoutCancel(r.Context()))
+		validationReq.Body = io.NopCloser(bytes.NewReader(body))
+		input := &openapi3filter.RequestValidationInput{
+			Request:    validationReq,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    v.options,
+		}
+		// The spec only describes JSON bodies; XML and MessagePack ones are
+		// checked by the handler's own validation
+		if format, ok := response.BodyFormat(r.Header.Get("Content-Type")); ok && format != response.FormatJSON {
+			options := *v.options
+			options.ExcludeRequestBody = true
+			input.Options = &options
+		}
+		if err := openapi3filter.ValidateRequest(validationReq.Context(), input); err != nil && cw.status < 400 {
+			v.violation(w, r, cw.status, fmt.Errorf("handler accepted a request the spec rejects: %w", err))
+			return
+		}
+
+		err = openapi3filter.ValidateResponse(validationReq.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 cw.status,
+			Header:                 cw.header,
+			Body:                   io.NopCloser(bytes.NewReader(cw.body.Bytes())),
+			Options:                v.options,
+		})
+		if err != nil {
+			v.violation(w, r, cw.status, fmt.Errorf("response does not match the spec: %w", err))
+			return
+		}
+		cw.flush(w)
+	})
+}
+
+// muxFallback reports whether status is one ServeMux itself answers
+// unmatched requests with: 404, 405, or a redirect to the cleaned path
+func muxFallback(status int) bool {
+	switch status {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusMovedPermanently, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// violation logs the drift and replaces the handler's response with a 500
+func (v *OpenAPIValidator) violation(w http.ResponseWriter, r *http.Request, status int, err error) {
+	log.Printf("OPENAPI CONTRACT VIOLATION: %s %s (handler status %d): %v", r.Method, r.URL.Path, status, err)
+	response.WriteError(w, apierror.New(http.StatusInternalServerError, apierror.CodeContractViolation,
+		"The handler does not conform to the OpenAPI contract").WithDetails(map[string]any{
+		"handler_status": status,
+		"reason":         err.Error(),
+	}))
+}
+
+// captureWriter buffers a response so it can be validated before it is sent
+type captureWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (cw *captureWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	return cw.body.Write(p)
+}
+
+func (cw *captureWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *captureWriter) flush(w http.ResponseWriter) {
+	dst := w.Header()
+	for k, v := range cw.header {
+		dst[k] = v
+	}
+	w.WriteHeader(cw.status)
+	w.Write(cw.body.Bytes())
+}
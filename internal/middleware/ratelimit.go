@@ -0,0 +1,88 @@
+// internal/middleware/ratelimit.go
+package middleware
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/i18n"
+	"api-server/internal/response"
+	"api-server/internal/router"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTTL is how long an unused client bucket is kept
+const limiterIdleTTL = 10 * time.Minute
+
+// RateLimiter keeps a token bucket per client key
+type RateLimiter struct {
+	rps   float64
+	burst int
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter allows each client rps requests per second on average,
+// with bursts of up to burst requests
+func NewRateLimiter(rps, burst int) *RateLimiter {
+	return &RateLimiter{
+		rps:       float64(rps),
+		burst:     max(burst, 1),
+		clients:   map[string]*clientLimiter{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from key's bucket
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > limiterIdleTTL {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > limiterIdleTTL {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(l.rps), l.burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	return c.limiter.AllowN(now, 1)
+}
+
+// RateLimit rejects requests over the limit with a 429. key identifies the
+// client; a nil limiter disables limiting.
+func RateLimit(l *RateLimiter, key func(*http.Request) string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		retryAfter := strconv.Itoa(int(math.Ceil(1 / l.rps)))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !l.Allow(key(r)) {
+				w.Header().Set("Retry-After", retryAfter)
+				response.WriteError(w, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many requests").Localize(i18n.FromRequest(r)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
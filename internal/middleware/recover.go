@@ -0,0 +1,35 @@
+// internal/middleware/recover.go
+package middleware
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/errortracking"
+	"api-server/internal/i18n"
+	"api-server/internal/response"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover turns a panic in next into a 500 and logs it with the stack, so
+// one bad request can't take the connection down with it. The panic is also
+// reported to error tracking.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				// Deliberate aborts are left to net/http
+				panic(p)
+			}
+			slog.Error("Handler panicked", "method", r.Method, "path", r.URL.Path,
+				"request_id", RequestIDFromContext(r.Context()), "panic", p, "stack", string(debug.Stack()))
+			errortracking.CapturePanic(r, p, map[string]string{"request_id": RequestIDFromContext(r.Context())})
+			response.WriteError(w, apierror.Internal("Internal server error").Localize(i18n.FromRequest(r)))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,128 @@
+// internal/middleware/timeout.go
+package middleware
+
+import (
+	"api-server/internal/apierror"
+	"api-server/internal/i18n"
+	"api-server/internal/response"
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeout bounds how long next may run. Like http.TimeoutHandler, the response
+// is buffered so a handler that overruns can't write a partial body; when d
+// elapses the request context is cancelled, which stops in-flight DB and GCS
+// calls, and the client receives a 503 problem+json instead.
+func Timeout(d time.Duration, next http.Handler) http.Handler {
+	if d <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			dst := w.Header()
+			for k, v := range tw.header {
+				dst[k] = v
+			}
+			if tw.status == 0 {
+				tw.status = http.StatusOK
+			}
+			w.WriteHeader(tw.status)
+			w.Write(tw.body.Bytes())
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			if ctx.Err() == context.DeadlineExceeded {
+				response.WriteProblem(w, apierror.New(http.StatusServiceUnavailable, apierror.CodeRequestTimeout, "The request took too long to process").Localize(i18n.FromRequest(r)))
+			}
+		}
+	})
+}
+
+// TimeoutByMethod applies the read timeout to GET and HEAD requests and the
+// write timeout to everything else, for handlers that serve both.
+func TimeoutByMethod(read, write time.Duration, next http.Handler) http.Handler {
+	readHandler := Timeout(read, next)
+	writeHandler := Timeout(write, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			readHandler.ServeHTTP(w, r)
+			return
+		}
+		writeHandler.ServeHTTP(w, r)
+	})
+}
+
+// Deadline cancels the request context once d has passed but, unlike
+// Timeout, doesn't buffer the response, for handlers that stream a body too
+// large to hold. A stream cut off by the deadline simply ends early.
+func Deadline(d time.Duration, next http.Handler) http.Handler {
+	if d <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// timeoutWriter buffers a response until the handler finishes in time
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = status
+}
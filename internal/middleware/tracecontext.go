@@ -0,0 +1,61 @@
+// internal/middleware/tracecontext.go
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// TraceParentHeader carries the W3C trace context of the trace a request
+// is part of, as OpenTelemetry clients and proxies send it
+const TraceParentHeader = "traceparent"
+
+type traceIDKey struct{}
+
+// TraceContext picks up the trace ID from a request's traceparent header,
+// so metrics can point at the trace. Only sampled traces are kept: one the
+// tracing backend never stored would lead nowhere.
+func TraceContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := parseTraceParent(r.Header.Get(TraceParentHeader)); ok {
+			r = r.WithContext(context.WithValue(r.Context(), traceIDKey{}, id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TraceIDFromContext returns the sampled trace ID TraceContext found, or ""
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// parseTraceParent returns the trace ID of a traceparent header,
+// version-traceid-parentid-flags, if it is well formed and sampled. Later
+// versions may append fields, which are ignored.
+func parseTraceParent(header string) (string, bool) {
+	fields := strings.Split(header, "-")
+	if len(fields) < 4 || len(fields[0]) != 2 || fields[0] == "ff" || (fields[0] == "00" && len(fields) != 4) {
+		return "", false
+	}
+	version, traceID, parentID, flags := fields[0], fields[1], fields[2], fields[3]
+	if !isLowerHex(version) || len(traceID) != 32 || !isLowerHex(traceID) || strings.Trim(traceID, "0") == "" ||
+		len(parentID) != 16 || !isLowerHex(parentID) || len(flags) != 2 || !isLowerHex(flags) {
+		return "", false
+	}
+	// The low bit of the flags is "sampled"
+	if !strings.ContainsRune("13579bdf", rune(flags[1])) {
+		return "", false
+	}
+	return traceID, true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+// internal/migrate/migrate.go
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockKey serializes concurrent migrators, e.g. several pods starting at once
+const lockKey = 7125_0001
+
+// Migration is one numbered SQL file such as 006_add_trace_storage_tier.sql
+type Migration struct {
+	Version string
+	Name    string
+	SQL     string
+}
+
+// Load reads the *.sql files in fsys, ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	migrations := make([]Migration, 0, len(files))
+	seen := map[string]string{}
+	for _, file := range files {
+		version, _, ok := strings.Cut(path.Base(file), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.sql", file)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %s", other, file, version)
+		}
+		seen[version] = file
+
+		sql, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: file, SQL: string(sql)})
+	}
+	return migrations, nil
+}
+
+func ensureTable(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, `
+		CREATE SCHEMA IF NOT EXISTS api;
+		CREATE TABLE IF NOT EXISTS api.schema_migrations (
+			version VARCHAR(20) PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// Pending returns the migrations that haven't been applied yet
+func Pending(ctx context.Context, db *pgxpool.Pool, migrations []Migration) ([]Migration, error) {
+	if err := ensureTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `SELECT version FROM api.schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction
+// together with its schema_migrations row, and returns the ones it applied.
+func Up(ctx context.Context, db *pgxpool.Pool, migrations []Migration) ([]Migration, error) {
+	pending, err := Pending(ctx, db, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		ran, err := apply(ctx, db, m, true)
+		if err != nil {
+			return applied, fmt.Errorf("migration %s failed: %w", m.Name, err)
+		}
+		if ran {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// Baseline records every migration up to and including version as applied
+// without running it, for databases that were migrated before this tool.
+func Baseline(ctx context.Context, db *pgxpool.Pool, migrations []Migration, version string) ([]Migration, error) {
+	pending, err := Pending(ctx, db, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	var recorded []Migration
+	for _, m := range pending {
+		if m.Version > version {
+			break
+		}
+		ran, err := apply(ctx, db, m, false)
+		if err != nil {
+			return recorded, err
+		}
+		if ran {
+			recorded = append(recorded, m)
+		}
+	}
+	return recorded, nil
+}
+
+// apply runs m (when execute is set) and records it. It reports false when
+// another migrator applied m first.
+func apply(ctx context.Context, db *pgxpool.Pool, m Migration, execute bool) (bool, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM api.schema_migrations WHERE version = $1)`, m.Version).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	if execute {
+		if _, err := tx.Exec(ctx, m.SQL); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO api.schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
@@ -0,0 +1,137 @@
+// internal/migrate/plans_test.go
+package migrate_test
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/testutil"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// TestHotQueryPlans explains the queries migration 031 indexes, as the model
+// package builds them: each model function runs once, on empty tables,
+// through a recorder, and the statement it ran is explained with its own
+// arguments. Sequential scans are turned off, so that even on empty tables
+// the plan shows whether the index can serve the query.
+func TestHotQueryPlans(t *testing.T) {
+	db := testutil.StartDatabase(t)
+	ctx := context.Background()
+
+	tenantID, courseID := model.DefaultTenantID, uuid.New()
+	tests := []struct {
+		name  string
+		query func(db model.DBTX) error
+		index string
+	}{
+		{
+			// Admins and service accounts see every trace
+			name: "GetTracesByCourseID",
+			query: func(db model.DBTX) error {
+				_, err := model.GetTracesByCourseID(ctx, db, tenantID, courseID, nil, model.ListOptions{})
+				return err
+			},
+			index: "traces_tenant_course_created_idx",
+		},
+		{
+			// Other signed-in users only the ones their visibility allows
+			name: "GetTracesByCourseID/visible",
+			query: func(db model.DBTX) error {
+				visible := []string{model.VisibilityCourseMembers, model.VisibilityPublic}
+				_, err := model.GetTracesByCourseID(ctx, db, tenantID, courseID, visible, model.ListOptions{})
+				return err
+			},
+			index: "traces_tenant_course_created_idx",
+		},
+		{
+			name: "ListCourses",
+			query: func(db model.DBTX) error {
+				_, err := model.ListCourses(ctx, db, tenantID, false, model.ListOptions{})
+				return err
+			},
+			index: "courses_tenant_active_created_idx",
+		},
+		{
+			name: "GetCourseBySection",
+			query: func(db model.DBTX) error {
+				_, err := model.GetCourseBySection(ctx, db, tenantID, "CSYE", 7125, "Spring", 2025)
+				return err
+			},
+			index: "courses_tenant_section_idx",
+		},
+		{
+			name: "GetUserByEmail",
+			query: func(db model.DBTX) error {
+				_, err := model.GetUserByEmail(ctx, db, tenantID, "user@example.com")
+				return err
+			},
+			index: "users_tenant_email_lower_idx",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := db.Begin(ctx)
+			if err != nil {
+				t.Fatalf("failed to begin: %v", err)
+			}
+			defer tx.Rollback(ctx)
+			if _, err := tx.Exec(ctx, "SET LOCAL enable_seqscan = off"); err != nil {
+				t.Fatalf("failed to turn off sequential scans: %v", err)
+			}
+
+			// The tables are empty, so lookups find nothing
+			statements := testutil.RecordStatements(t, tx, func(db model.DBTX) error {
+				if err := tt.query(db); !errors.Is(err, model.ErrNotFound) {
+					return err
+				}
+				return nil
+			})
+			statement := statements[len(statements)-1]
+			plan := explain(ctx, t, tx, statement.SQL, statement.Args)
+			for _, index := range partitionIndexes(ctx, t, tx, tt.index) {
+				if strings.Contains(plan, " "+index+" ") {
+					return
+				}
+			}
+			t.Errorf("%s is not planned with %s:\n%s\n%s", tt.name, tt.index, statement.SQL, plan)
+		})
+	}
+}
+
+// explain returns the plan of sql as EXPLAIN prints it
+func explain(ctx context.Context, t *testing.T, tx pgx.Tx, sql string, args []any) string {
+	t.Helper()
+	rows, err := tx.Query(ctx, "EXPLAIN "+sql, args...)
+	if err != nil {
+		t.Fatalf("failed to explain: %v", err)
+	}
+	lines, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatalf("failed to read plan: %v", err)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// partitionIndexes returns index and, when it is on a partitioned table such
+// as api.traces, the index of each partition it was created on, which is
+// what plans name
+func partitionIndexes(ctx context.Context, t *testing.T, tx pgx.Tx, index string) []string {
+	t.Helper()
+	rows, err := tx.Query(ctx, `
+		SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass('api.' || $1)
+	`, index)
+	if err != nil {
+		t.Fatalf("failed to list partition indexes of %s: %v", index, err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatalf("failed to list partition indexes of %s: %v", index, err)
+	}
+	return append(names, index)
+}
@@ -0,0 +1,73 @@
+// internal/model/audit.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEntry records who changed what on an entity
+type AuditEntry struct {
+	ID          uuid.UUID      `json:"id"`
+	UserID      uuid.UUID      `json:"user_id"`
+	Action      string         `json:"action"`
+	EntityType  string         `json:"entity_type"`
+	EntityID    uuid.UUID      `json:"entity_id"`
+	Changes     map[string]any `json:"changes"`
+	DateCreated time.Time      `json:"date_created"`
+}
+
+// FieldChange is the old and new value of a single changed field
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+func InsertAuditEntry(ctx context.Context, db DBTX, entry AuditEntry) error {
+	query := `
+		INSERT INTO api.audit_log (user_id, action, entity_type, entity_id, changes)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	changes := entry.Changes
+	if changes == nil {
+		changes = map[string]any{}
+	}
+	_, err := db.Exec(ctx, query, entry.UserID, entry.Action, entry.EntityType, entry.EntityID, changes)
+	return err
+}
+
+// CourseArchivedAuditEntry records userID archiving or unarchiving a course
+func CourseArchivedAuditEntry(before, after *Course, userID uuid.UUID) AuditEntry {
+	action := "course.archived"
+	if after.ArchivedAt == nil {
+		action = "course.unarchived"
+	}
+	return AuditEntry{
+		UserID:     userID,
+		Action:     action,
+		EntityType: "course",
+		EntityID:   after.ID,
+		Changes:    map[string]any{"archived_at": FieldChange{Old: before.ArchivedAt, New: after.ArchivedAt}},
+	}
+}
+
+// DiffCourses lists the fields that differ between two versions of a course
+func DiffCourses(before, after *Course) map[string]any {
+	changes := map[string]any{}
+	add := func(field string, old, new any) {
+		if old != new {
+			changes[field] = FieldChange{Old: old, New: new}
+		}
+	}
+	add("name", before.Name, after.Name)
+	add("semester_term", before.SemesterTerm, after.SemesterTerm)
+	add("credit_hours", before.CreditHours, after.CreditHours)
+	add("subject_code", before.SubjectCode, after.SubjectCode)
+	add("course_id", before.CourseID, after.CourseID)
+	add("semester_year", before.SemesterYear, after.SemesterYear)
+	add("user_id", before.UserID, after.UserID)
+	add("instructor_id", before.InstructorID, after.InstructorID)
+	return changes
+}
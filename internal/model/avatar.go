@@ -0,0 +1,76 @@
+// internal/model/avatar.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserAvatar is a user's profile picture. The square PNG is in storage;
+// this records where and its size.
+type UserAvatar struct {
+	ObjectName  string    `json:"-"`
+	Size        int       `json:"size"`
+	SizeBytes   int64     `json:"size_bytes"`
+	DateUpdated time.Time `json:"date_updated"`
+}
+
+// Profile is the caller's own account, with the URL their profile picture
+// is served at, or nil if they have none
+type Profile struct {
+	*User
+	AvatarURL *string `json:"avatar_url"`
+}
+
+// UploadAvatarRequest holds the multipart fields of a profile picture upload
+type UploadAvatarRequest struct {
+	File string `json:"file" validate:"required,max=255"`
+}
+
+// SaveUserAvatar records the user's profile picture, replacing any earlier
+// one. It returns ErrNotFound if the user is unknown.
+func SaveUserAvatar(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, avatar UserAvatar) (*UserAvatar, error) {
+	query := `
+		INSERT INTO api.user_avatars (user_id, tenant_id, object_name, size, size_bytes)
+		SELECT id, tenant_id, $3, $4, $5 FROM api.users
+		WHERE tenant_id = $1 AND id = $2
+		ON CONFLICT (user_id) DO UPDATE
+		SET object_name = EXCLUDED.object_name, size = EXCLUDED.size, size_bytes = EXCLUDED.size_bytes,
+		    date_updated = CURRENT_TIMESTAMP
+		RETURNING object_name, size, size_bytes, date_updated
+	`
+	return scanUserAvatar(db.QueryRow(ctx, query, tenantID, userID, avatar.ObjectName, avatar.Size, avatar.SizeBytes))
+}
+
+// GetUserAvatar returns the profile picture of one of the tenant's users,
+// or ErrNotFound if they have none
+func GetUserAvatar(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) (*UserAvatar, error) {
+	query := `
+		SELECT object_name, size, size_bytes, date_updated
+		FROM api.user_avatars
+		WHERE tenant_id = $1 AND user_id = $2
+	`
+	return scanUserAvatar(db.QueryRow(ctx, query, tenantID, userID))
+}
+
+// DeleteUserAvatar forgets the user's profile picture and returns it, so
+// the caller can delete the stored PNG. It returns ErrNotFound if they have
+// none.
+func DeleteUserAvatar(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) (*UserAvatar, error) {
+	query := `
+		DELETE FROM api.user_avatars
+		WHERE tenant_id = $1 AND user_id = $2
+		RETURNING object_name, size, size_bytes, date_updated
+	`
+	return scanUserAvatar(db.QueryRow(ctx, query, tenantID, userID))
+}
+
+func scanUserAvatar(row interface{ Scan(...any) error }) (*UserAvatar, error) {
+	var a UserAvatar
+	if err := row.Scan(&a.ObjectName, &a.Size, &a.SizeBytes, &a.DateUpdated); err != nil {
+		return nil, notFound(err)
+	}
+	return &a, nil
+}
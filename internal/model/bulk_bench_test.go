@@ -0,0 +1,130 @@
+// internal/model/bulk_bench_test.go
+package model_test
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/testutil"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// benchBatch is how many rows each iteration inserts, about one registrar
+// sync page or a mid-sized CSV import
+const benchBatch = 500
+
+// newBenchDatabase starts a migrated Postgres with a user to own the rows
+func newBenchDatabase(b *testing.B) (*pgxpool.Pool, uuid.UUID) {
+	b.Helper()
+	db := testutil.StartDatabase(b)
+	user, err := model.CreateUser(context.Background(), db, model.DefaultTenantID, model.CreateUserRequest{
+		FirstName: "Bench",
+		Username:  "bench",
+		Password:  "bench-password",
+		Role:      "admin",
+		Email:     "bench@example.edu",
+	})
+	if err != nil {
+		b.Fatalf("failed to create user: %v", err)
+	}
+	return db, user.ID
+}
+
+// inTx runs insert in a transaction that is rolled back, so every iteration
+// inserts the same rows into the same empty tables
+func inTx(b *testing.B, db *pgxpool.Pool, insert func(tx pgx.Tx) error) {
+	b.Helper()
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		b.Fatalf("failed to begin: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := insert(tx); err != nil {
+		b.Fatalf("failed to insert: %v", err)
+	}
+}
+
+func BenchmarkCreateInstructors(b *testing.B) {
+	db, userID := newBenchDatabase(b)
+	ctx := context.Background()
+	reqs := make([]model.CreateInstructorRequest, benchBatch)
+	for i := range reqs {
+		reqs[i] = model.CreateInstructorRequest{
+			Name:  fmt.Sprintf("Instructor %d", i),
+			Email: fmt.Sprintf("instructor%d@example.edu", i),
+		}
+	}
+
+	b.Run("bulk", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			inTx(b, db, func(tx pgx.Tx) error {
+				_, err := model.CreateInstructors(ctx, tx, model.DefaultTenantID, reqs, userID)
+				return err
+			})
+		}
+	})
+	b.Run("row", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			inTx(b, db, func(tx pgx.Tx) error {
+				for _, req := range reqs {
+					if _, err := model.CreateInstructor(ctx, tx, model.DefaultTenantID, req, userID); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
+	})
+}
+
+func BenchmarkCreateCourses(b *testing.B) {
+	db, userID := newBenchDatabase(b)
+	ctx := context.Background()
+	instructor, err := model.CreateInstructor(ctx, db, model.DefaultTenantID,
+		model.CreateInstructorRequest{Name: "Ada Lovelace", Email: "ada@example.edu"}, userID)
+	if err != nil {
+		b.Fatalf("failed to create instructor: %v", err)
+	}
+	reqs := make([]model.CreateCourseRequest, benchBatch)
+	for i := range reqs {
+		reqs[i] = model.CreateCourseRequest{
+			Name:         fmt.Sprintf("Course %d", i),
+			SemesterTerm: "Fall",
+			CreditHours:  4,
+			SubjectCode:  "CSYE",
+			CourseID:     6000 + i,
+			SemesterYear: 2025,
+			InstructorID: instructor.ID,
+		}
+	}
+
+	b.Run("bulk", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			inTx(b, db, func(tx pgx.Tx) error {
+				_, err := model.CreateCourses(ctx, tx, model.DefaultTenantID, reqs, userID)
+				return err
+			})
+		}
+	})
+	b.Run("row", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			inTx(b, db, func(tx pgx.Tx) error {
+				for _, req := range reqs {
+					if _, err := model.CreateCourse(ctx, tx, model.DefaultTenantID, req, userID); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
+	})
+}
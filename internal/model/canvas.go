@@ -0,0 +1,426 @@
+// internal/model/canvas.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CanvasConnection is the Canvas instance a tenant syncs with
+type CanvasConnection struct {
+	BaseURL  string `json:"base_url"`
+	APIToken string `json:"-"`
+	// LinkBaseURL is the front-end that the links pushed to Canvas point at
+	LinkBaseURL string    `json:"link_base_url"`
+	Enabled     bool      `json:"enabled"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+}
+
+// SaveCanvasConnectionRequest replaces the tenant's connection. Enabled
+// defaults to true.
+type SaveCanvasConnectionRequest struct {
+	BaseURL     string `json:"base_url" validate:"required,http_url,max=255"`
+	APIToken    string `json:"api_token" validate:"required,max=255"`
+	LinkBaseURL string `json:"link_base_url" validate:"required,http_url,max=255"`
+	Enabled     *bool  `json:"enabled,omitempty"`
+}
+
+// CanvasCourseLink ties a course to the Canvas course it is synced with
+type CanvasCourseLink struct {
+	CourseID       uuid.UUID `json:"course_id"`
+	CanvasCourseID int64     `json:"canvas_course_id"`
+	DateCreated    time.Time `json:"date_created"`
+	DateUpdated    time.Time `json:"date_updated"`
+}
+
+type LinkCanvasCourseRequest struct {
+	CanvasCourseID int64 `json:"canvas_course_id" validate:"required,gte=1"`
+}
+
+// Canvas roster roles, from the user's Canvas enrollment type
+const (
+	CanvasRoleTeacher  = "teacher"
+	CanvasRoleTA       = "ta"
+	CanvasRoleStudent  = "student"
+	CanvasRoleDesigner = "designer"
+	CanvasRoleObserver = "observer"
+)
+
+// CanvasRosterEntry is one member of a linked course's Canvas roster.
+// InstructorID is the tenant's instructor with the same email, if any.
+type CanvasRosterEntry struct {
+	CanvasUserID int64      `json:"canvas_user_id"`
+	Name         string     `json:"name"`
+	Email        *string    `json:"email"`
+	Role         string     `json:"role"`
+	InstructorID *uuid.UUID `json:"instructor_id"`
+}
+
+// Canvas sync triggers
+const (
+	CanvasSyncSchedule = "schedule"
+	CanvasSyncManual   = "manual"
+)
+
+// CanvasSync is one run of the Canvas sync for a tenant. Its statuses are
+// those of data jobs.
+type CanvasSync struct {
+	ID            uuid.UUID        `json:"id"`
+	TenantID      uuid.UUID        `json:"-"`
+	Trigger       string           `json:"trigger"`
+	RequestedBy   *uuid.UUID       `json:"requested_by"`
+	Status        string           `json:"status"`
+	Error         *string          `json:"error"`
+	CoursesSynced int              `json:"courses_synced"`
+	RosterEntries int              `json:"roster_entries"`
+	Conflicts     []CanvasConflict `json:"conflicts"`
+	DateCreated   time.Time        `json:"date_created"`
+	DateUpdated   time.Time        `json:"date_updated"`
+	DateCompleted *time.Time       `json:"date_completed"`
+}
+
+// Done reports whether the sync has finished, successfully or not
+func (s *CanvasSync) Done() bool {
+	return s.Status == DataJobCompleted || s.Status == DataJobFailed
+}
+
+// Canvas conflict kinds
+const (
+	// The linked Canvas course doesn't exist
+	CanvasConflictCourseNotFound = "canvas_course_not_found"
+	// The API token may not read the roster or write pages
+	CanvasConflictAccessDenied = "canvas_access_denied"
+	// Canvas failed some other way
+	CanvasConflictError = "canvas_error"
+	// A Canvas teacher's email matches none of the tenant's instructors
+	CanvasConflictUnknownInstructor = "unknown_instructor"
+	// The course's instructor is not a teacher of the Canvas course
+	CanvasConflictInstructorMismatch = "instructor_mismatch"
+)
+
+// CanvasConflict is something a sync found that an admin has to resolve.
+// The rest of the sync goes ahead.
+type CanvasConflict struct {
+	CourseID       uuid.UUID `json:"course_id"`
+	CanvasCourseID int64     `json:"canvas_course_id"`
+	Kind           string    `json:"kind"`
+	Detail         string    `json:"detail"`
+}
+
+const canvasConnectionColumns = "base_url, api_token, link_base_url, enabled, date_created, date_updated"
+
+func GetCanvasConnection(ctx context.Context, db DBTX, tenantID uuid.UUID) (*CanvasConnection, error) {
+	query := "SELECT " + canvasConnectionColumns + " FROM api.canvas_connections WHERE tenant_id = $1"
+	return scanCanvasConnection(db.QueryRow(ctx, query, tenantID))
+}
+
+func SaveCanvasConnection(ctx context.Context, db DBTX, tenantID uuid.UUID, req SaveCanvasConnectionRequest) (*CanvasConnection, error) {
+	enabled := req.Enabled == nil || *req.Enabled
+	query := `
+		INSERT INTO api.canvas_connections (tenant_id, base_url, api_token, link_base_url, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tenant_id) DO UPDATE
+		SET base_url = EXCLUDED.base_url,
+			api_token = EXCLUDED.api_token,
+			link_base_url = EXCLUDED.link_base_url,
+			enabled = EXCLUDED.enabled,
+			date_updated = CURRENT_TIMESTAMP
+		RETURNING ` + canvasConnectionColumns
+	return scanCanvasConnection(db.QueryRow(ctx, query, tenantID, req.BaseURL, req.APIToken, req.LinkBaseURL, enabled))
+}
+
+func DeleteCanvasConnection(ctx context.Context, db DBTX, tenantID uuid.UUID) error {
+	result, err := db.Exec(ctx, "DELETE FROM api.canvas_connections WHERE tenant_id = $1", tenantID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanCanvasConnection(row interface{ Scan(dest ...any) error }) (*CanvasConnection, error) {
+	var c CanvasConnection
+	err := row.Scan(&c.BaseURL, &c.APIToken, &c.LinkBaseURL, &c.Enabled, &c.DateCreated, &c.DateUpdated)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &c, nil
+}
+
+// LinkCanvasCourse links one of the tenant's courses to a Canvas course, or
+// moves its link to another, which drops the roster pulled for the old one.
+// It returns ErrNotFound for an unknown course.
+func LinkCanvasCourse(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, canvasCourseID int64) (*CanvasCourseLink, error) {
+	var link CanvasCourseLink
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		_, err := tx.Exec(ctx, `
+			DELETE FROM api.canvas_roster_entries
+			WHERE course_id IN (
+				SELECT course_id FROM api.canvas_course_links
+				WHERE course_id = $1 AND tenant_id = $2 AND canvas_course_id <> $3
+			)
+		`, courseID, tenantID, canvasCourseID)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRow(ctx, `
+			INSERT INTO api.canvas_course_links (course_id, tenant_id, canvas_course_id)
+			SELECT id, tenant_id, $3 FROM api.courses WHERE id = $1 AND tenant_id = $2
+			ON CONFLICT (course_id) DO UPDATE
+			SET canvas_course_id = EXCLUDED.canvas_course_id, date_updated = CURRENT_TIMESTAMP
+			RETURNING course_id, canvas_course_id, date_created, date_updated
+		`, courseID, tenantID, canvasCourseID).Scan(&link.CourseID, &link.CanvasCourseID, &link.DateCreated, &link.DateUpdated)
+		return notFound(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// UnlinkCanvasCourse removes a course's link and its roster. It returns
+// ErrNotFound if the course isn't linked.
+func UnlinkCanvasCourse(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID) error {
+	result, err := db.Exec(ctx, "DELETE FROM api.canvas_course_links WHERE course_id = $1 AND tenant_id = $2", courseID, tenantID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListCanvasCourseLinks returns the tenant's linked courses, oldest link first
+func ListCanvasCourseLinks(ctx context.Context, db DBTX, tenantID uuid.UUID) ([]CanvasCourseLink, error) {
+	rows, err := db.Query(ctx, `
+		SELECT course_id, canvas_course_id, date_created, date_updated
+		FROM api.canvas_course_links
+		WHERE tenant_id = $1
+		ORDER BY date_created, course_id
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []CanvasCourseLink{}
+	for rows.Next() {
+		var link CanvasCourseLink
+		if err := rows.Scan(&link.CourseID, &link.CanvasCourseID, &link.DateCreated, &link.DateUpdated); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// ReplaceCanvasRoster stores the roster pulled for a linked course in place
+// of the last one, matching entries to the tenant's instructors by email,
+// and returns it as GetCanvasRoster does. It returns ErrNotFound if the
+// course isn't linked.
+func ReplaceCanvasRoster(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, entries []CanvasRosterEntry) ([]CanvasRosterEntry, error) {
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		// Lock the link so an unlink can't interleave
+		var linked uuid.UUID
+		err := tx.QueryRow(ctx, "SELECT course_id FROM api.canvas_course_links WHERE course_id = $1 AND tenant_id = $2 FOR UPDATE", courseID, tenantID).Scan(&linked)
+		if err != nil {
+			return notFound(err)
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM api.canvas_roster_entries WHERE course_id = $1", courseID); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		// One statement for the whole roster, however large the course
+		userIDs := make([]int64, len(entries))
+		names := make([]string, len(entries))
+		emails := make([]*string, len(entries))
+		roles := make([]string, len(entries))
+		for i, e := range entries {
+			userIDs[i], names[i], emails[i], roles[i] = e.CanvasUserID, e.Name, e.Email, e.Role
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO api.canvas_roster_entries (course_id, tenant_id, canvas_user_id, name, email, role, instructor_id)
+			SELECT $1, $2, e.canvas_user_id, e.name, e.email, e.role,
+				(SELECT id FROM api.instructors WHERE tenant_id = $2 AND LOWER(email) = LOWER(e.email) LIMIT 1)
+			FROM unnest($3::bigint[], $4::text[], $5::text[], $6::text[]) AS e(canvas_user_id, name, email, role)
+			ON CONFLICT (course_id, canvas_user_id, role) DO NOTHING
+		`, courseID, tenantID, userIDs, names, emails, roles)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetCanvasRoster(ctx, db, tenantID, courseID)
+}
+
+// GetCanvasRoster returns a linked course's roster by role and name. It
+// returns ErrNotFound if the course isn't linked.
+func GetCanvasRoster(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID) ([]CanvasRosterEntry, error) {
+	var linked bool
+	err := db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM api.canvas_course_links WHERE course_id = $1 AND tenant_id = $2)", courseID, tenantID).Scan(&linked)
+	if err != nil {
+		return nil, err
+	}
+	if !linked {
+		return nil, ErrNotFound
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT canvas_user_id, name, email, role, instructor_id
+		FROM api.canvas_roster_entries
+		WHERE course_id = $1
+		ORDER BY role, name, canvas_user_id
+	`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roster := []CanvasRosterEntry{}
+	for rows.Next() {
+		var e CanvasRosterEntry
+		if err := rows.Scan(&e.CanvasUserID, &e.Name, &e.Email, &e.Role, &e.InstructorID); err != nil {
+			return nil, err
+		}
+		roster = append(roster, e)
+	}
+	return roster, rows.Err()
+}
+
+const canvasSyncColumns = "id, tenant_id, trigger_type, requested_by, status, error, courses_synced, roster_entries, conflicts, date_created, date_updated, date_completed"
+
+// CreateCanvasSync queues a sync for the tenant. It fails on the
+// canvas_syncs_active_idx unique constraint while another is pending or
+// running.
+func CreateCanvasSync(ctx context.Context, db DBTX, tenantID uuid.UUID, trigger string, requestedBy *uuid.UUID) (*CanvasSync, error) {
+	query := `
+		INSERT INTO api.canvas_syncs (tenant_id, trigger_type, requested_by)
+		VALUES ($1, $2, $3)
+		RETURNING ` + canvasSyncColumns
+	return scanCanvasSync(db.QueryRow(ctx, query, tenantID, trigger, requestedBy))
+}
+
+// ListCanvasSyncs returns the tenant's latest limit syncs, newest first
+func ListCanvasSyncs(ctx context.Context, db DBTX, tenantID uuid.UUID, limit int) ([]CanvasSync, error) {
+	query := "SELECT " + canvasSyncColumns + " FROM api.canvas_syncs WHERE tenant_id = $1 ORDER BY date_created DESC LIMIT $2"
+	rows, err := db.Query(ctx, query, tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	syncs := []CanvasSync{}
+	for rows.Next() {
+		sync, err := scanCanvasSync(rows)
+		if err != nil {
+			return nil, err
+		}
+		syncs = append(syncs, *sync)
+	}
+	return syncs, rows.Err()
+}
+
+func GetCanvasSync(ctx context.Context, db DBTX, tenantID, syncID uuid.UUID) (*CanvasSync, error) {
+	query := "SELECT " + canvasSyncColumns + " FROM api.canvas_syncs WHERE id = $1 AND tenant_id = $2"
+	return scanCanvasSync(db.QueryRow(ctx, query, syncID, tenantID))
+}
+
+// ScheduleCanvasSyncs queues a scheduled sync for every enabled connection
+// that has had none for interval and has none pending or running, across
+// tenants, and returns how many it queued
+func ScheduleCanvasSyncs(ctx context.Context, db DBTX, interval time.Duration) (int, error) {
+	result, err := db.Exec(ctx, `
+		INSERT INTO api.canvas_syncs (tenant_id, trigger_type)
+		SELECT c.tenant_id, 'schedule'
+		FROM api.canvas_connections c
+		WHERE c.enabled AND NOT EXISTS (
+			SELECT 1 FROM api.canvas_syncs s
+			WHERE s.tenant_id = c.tenant_id
+			AND (s.status IN ('pending', 'running') OR s.date_created > CURRENT_TIMESTAMP - $1 * INTERVAL '1 millisecond')
+		)
+		ON CONFLICT DO NOTHING
+	`, interval.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// ClaimCanvasSync marks the oldest pending sync, or running sync whose lease
+// has run out, as running for lease, like ClaimDataJob. ErrNotFound means
+// there is none.
+func ClaimCanvasSync(ctx context.Context, db DBTX, lease time.Duration) (*CanvasSync, error) {
+	query := `
+		UPDATE api.canvas_syncs
+		SET status = 'running',
+			lease_expires = CURRENT_TIMESTAMP + $1 * INTERVAL '1 millisecond',
+			date_updated = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM api.canvas_syncs
+			WHERE status = 'pending' OR (status = 'running' AND lease_expires < CURRENT_TIMESTAMP)
+			ORDER BY date_created
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + canvasSyncColumns
+	return scanCanvasSync(db.QueryRow(ctx, query, lease.Milliseconds()))
+}
+
+// FinishCanvasSync records a claimed sync's status, error, counts and
+// conflicts
+func FinishCanvasSync(ctx context.Context, db DBTX, sync CanvasSync) error {
+	conflicts := sync.Conflicts
+	if conflicts == nil {
+		conflicts = []CanvasConflict{}
+	}
+	result, err := db.Exec(ctx, `
+		UPDATE api.canvas_syncs
+		SET status = $2,
+			error = $3,
+			courses_synced = $4,
+			roster_entries = $5,
+			conflicts = $6,
+			lease_expires = NULL,
+			date_updated = CURRENT_TIMESTAMP,
+			date_completed = CASE WHEN $7 THEN CURRENT_TIMESTAMP END
+		WHERE id = $1
+	`, sync.ID, sync.Status, sync.Error, sync.CoursesSynced, sync.RosterEntries, conflicts, sync.Done())
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanCanvasSync(row interface{ Scan(dest ...any) error }) (*CanvasSync, error) {
+	var s CanvasSync
+	err := row.Scan(
+		&s.ID,
+		&s.TenantID,
+		&s.Trigger,
+		&s.RequestedBy,
+		&s.Status,
+		&s.Error,
+		&s.CoursesSynced,
+		&s.RosterEntries,
+		&s.Conflicts,
+		&s.DateCreated,
+		&s.DateUpdated,
+		&s.DateCompleted,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &s, nil
+}
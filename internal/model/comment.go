@@ -0,0 +1,124 @@
+// internal/model/comment.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TraceComment is a note left on a trace, optionally about one page of its PDF
+type TraceComment struct {
+	ID          uuid.UUID `json:"id"`
+	TraceID     uuid.UUID `json:"trace_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Page        *int      `json:"page"`
+	Text        string    `json:"text"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// CreateTraceCommentRequest is a new comment; Page is left out for comments
+// about the whole trace
+type CreateTraceCommentRequest struct {
+	Page *int   `json:"page,omitempty" validate:"omitnil,gte=1,lte=100000"`
+	Text string `json:"text" validate:"required,max=2000"`
+}
+
+// traceCommentListSpec is the ?sort= and ?fields= allowlist for comments
+var traceCommentListSpec = &listSpec[TraceComment]{
+	table: "api.trace_comments",
+	columns: map[string]listColumn[TraceComment]{
+		"id":           {"id", kindUUID, true, func(c *TraceComment) any { return &c.ID }},
+		"trace_id":     {"trace_id", kindUUID, false, func(c *TraceComment) any { return &c.TraceID }},
+		"user_id":      {"user_id", kindUUID, false, func(c *TraceComment) any { return &c.UserID }},
+		"page":         {"page", kindInt, false, func(c *TraceComment) any { return &c.Page }},
+		"text":         {"text", kindString, false, func(c *TraceComment) any { return &c.Text }},
+		"date_created": {"date_created", kindTime, true, func(c *TraceComment) any { return &c.DateCreated }},
+	},
+	aliases:     map[string]string{"created_at": "date_created"},
+	defaultSort: []SortField{{Field: "date_created"}},
+}
+
+const traceCommentColumns = "id, trace_id, user_id, page, text, date_created"
+
+// CreateTraceComment adds a comment to one of the tenant's traces. It
+// returns ErrNotFound if the trace isn't in the course.
+func CreateTraceComment(ctx context.Context, db DBTX, tenantID, courseID, traceID, userID uuid.UUID, req CreateTraceCommentRequest) (*TraceComment, error) {
+	var c TraceComment
+	err := db.QueryRow(ctx, `
+		INSERT INTO api.trace_comments (tenant_id, trace_id, user_id, page, text)
+		SELECT tenant_id, id, $4, $5, $6 FROM api.traces
+		WHERE tenant_id = $1 AND course_id = $2 AND id = $3
+		RETURNING `+traceCommentColumns, tenantID, courseID, traceID, userID, req.Page, req.Text).Scan(
+		&c.ID, &c.TraceID, &c.UserID, &c.Page, &c.Text, &c.DateCreated)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &c, nil
+}
+
+// GetTraceComment returns one comment on one of the tenant's traces
+func GetTraceComment(ctx context.Context, db DBTX, tenantID, traceID, commentID uuid.UUID) (*TraceComment, error) {
+	var c TraceComment
+	err := db.QueryRow(ctx, `
+		SELECT `+traceCommentColumns+` FROM api.trace_comments
+		WHERE tenant_id = $1 AND trace_id = $2 AND id = $3`, tenantID, traceID, commentID).Scan(
+		&c.ID, &c.TraceID, &c.UserID, &c.Page, &c.Text, &c.DateCreated)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &c, nil
+}
+
+// ListTraceComments returns one page of a trace's comments, oldest first
+// unless opts.Sort says otherwise. The caller checks the trace exists.
+func ListTraceComments(ctx context.Context, db DBTX, tenantID, traceID uuid.UUID, opts ListOptions) (*Page[TraceComment], error) {
+	return list(ctx, db, traceCommentListSpec, "tenant_id = $1 AND trace_id = $2", []any{tenantID, traceID}, opts)
+}
+
+// PaginateTraceComments pages through comments held in memory like
+// ListTraceComments does
+func PaginateTraceComments(comments []TraceComment, opts ListOptions) (*Page[TraceComment], error) {
+	return paginate(traceCommentListSpec, comments, opts)
+}
+
+// DeleteTraceComment deletes one comment on one of the tenant's traces
+func DeleteTraceComment(ctx context.Context, db DBTX, tenantID, traceID, commentID uuid.UUID) error {
+	result, err := db.Exec(ctx, "DELETE FROM api.trace_comments WHERE tenant_id = $1 AND trace_id = $2 AND id = $3", tenantID, traceID, commentID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetUserTraceComments returns every comment the user left, oldest first
+func GetUserTraceComments(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) ([]TraceComment, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+traceCommentColumns+` FROM api.trace_comments
+		WHERE tenant_id = $1 AND user_id = $2
+		ORDER BY date_created`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []TraceComment{}
+	for rows.Next() {
+		var c TraceComment
+		if err := rows.Scan(&c.ID, &c.TraceID, &c.UserID, &c.Page, &c.Text, &c.DateCreated); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// DeleteUserTraceComments deletes every comment the user left
+func DeleteUserTraceComments(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, "DELETE FROM api.trace_comments WHERE tenant_id = $1 AND user_id = $2", tenantID, userID)
+	return err
+}
@@ -0,0 +1,223 @@
+// internal/model/content.go
+package model
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How a trace's text was extracted
+const (
+	ExtractionText = "text"
+	ExtractionOCR  = "ocr"
+	// ExtractionNone records a PDF that yielded no text
+	ExtractionNone = "none"
+)
+
+// TraceContent is the text extracted from a trace's PDF
+type TraceContent struct {
+	Text   string
+	Method string
+	// Error says why no text was extracted, when Method is ExtractionNone
+	Error *string
+	// Summary is the cached LLM summary of Text, if one was asked for
+	Summary *TraceSummary
+}
+
+// TraceSummary is an LLM's digest of a trace's text
+type TraceSummary struct {
+	TraceID      uuid.UUID `json:"trace_id"`
+	Summary      string    `json:"summary"`
+	Model        string    `json:"model"`
+	SummarizedAt time.Time `json:"summarized_at"`
+	// Cached is true when the summary was stored by an earlier request
+	Cached bool `json:"cached"`
+}
+
+// KeywordResult is one trace whose content matches a keyword search,
+// ranked by relevance. The snippet wraps matched words in **.
+type KeywordResult struct {
+	Course  SearchCourse `json:"course"`
+	Trace   SearchTrace  `json:"trace"`
+	Rank    float64      `json:"rank"`
+	Snippet string       `json:"snippet"`
+}
+
+// GetTracesWithoutContent returns up to limit traces, across tenants, whose
+// text hasn't been extracted yet, oldest first
+func GetTracesWithoutContent(ctx context.Context, db DBTX, limit int) ([]Trace, error) {
+	query := `
+		SELECT t.id, t.user_id, t.instructor_id, t.status, t.vector_id, t.file_name, t.bucket_url, t.storage_tier, t.content_type, t.size_bytes, t.visibility, t.link_url, t.publish_status, t.archived_at, t.date_created, t.date_updated
+		FROM api.traces t
+		LEFT JOIN api.trace_contents tc ON tc.trace_id = t.id
+		WHERE tc.trace_id IS NULL
+		AND t.status <> 'failed'
+		AND t.bucket_url <> ''
+		ORDER BY t.date_created
+		LIMIT $1
+	`
+
+	rows, err := db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var traces []Trace
+	for rows.Next() {
+		var trace Trace
+		err := rows.Scan(
+			&trace.ID,
+			&trace.UserID,
+			&trace.InstructorID,
+			&trace.Status,
+			&trace.VectorID,
+			&trace.FileName,
+			&trace.BucketURL,
+			&trace.StorageTier,
+			&trace.ContentType,
+			&trace.SizeBytes,
+			&trace.Visibility,
+			&trace.LinkURL,
+			&trace.PublishStatus,
+			&trace.ArchivedAt,
+			&trace.DateCreated,
+			&trace.DateUpdated,
+		)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, trace)
+	}
+	return traces, rows.Err()
+}
+
+// SaveTraceContent stores the text extracted from a trace, in the trace's
+// tenant. It returns ErrNotFound if the trace was deleted meanwhile.
+func SaveTraceContent(ctx context.Context, db DBTX, traceID uuid.UUID, content TraceContent) error {
+	query := `
+		INSERT INTO api.trace_contents (trace_id, tenant_id, content, method, error)
+		SELECT id, tenant_id, $2, $3, $4 FROM api.traces WHERE id = $1
+		ON CONFLICT (trace_id) DO UPDATE
+		SET content = EXCLUDED.content, method = EXCLUDED.method, error = EXCLUDED.error, extracted_at = CURRENT_TIMESTAMP,
+		    summary = NULL, summary_model = NULL, summarized_at = NULL
+	`
+	result, err := db.Exec(ctx, query, traceID, content.Text, content.Method, content.Error)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetTraceContent returns the text extracted from one of the tenant's
+// traces, with its cached summary. It returns ErrNotFound if the trace is
+// unknown or its text hasn't been extracted yet.
+func GetTraceContent(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID) (*TraceContent, error) {
+	query := `
+		SELECT tc.content, tc.method, tc.error, tc.summary, tc.summary_model, tc.summarized_at
+		FROM api.trace_contents tc
+		JOIN api.traces t ON t.id = tc.trace_id
+		WHERE tc.tenant_id = $1 AND t.course_id = $2 AND tc.trace_id = $3
+	`
+
+	var (
+		content      TraceContent
+		summary      *string
+		summaryModel *string
+		summarizedAt *time.Time
+	)
+	err := db.QueryRow(ctx, query, tenantID, courseID, traceID).Scan(
+		&content.Text,
+		&content.Method,
+		&content.Error,
+		&summary,
+		&summaryModel,
+		&summarizedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if summary != nil && summaryModel != nil && summarizedAt != nil {
+		content.Summary = &TraceSummary{TraceID: traceID, Summary: *summary, Model: *summaryModel, SummarizedAt: *summarizedAt}
+	}
+	return &content, nil
+}
+
+// SaveTraceSummary caches an LLM summary of one of the tenant's traces. It
+// returns ErrNotFound if the trace's text is gone.
+func SaveTraceSummary(ctx context.Context, db DBTX, tenantID, traceID uuid.UUID, summary, model string) (*TraceSummary, error) {
+	query := `
+		UPDATE api.trace_contents
+		SET summary = $3, summary_model = $4, summarized_at = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND trace_id = $2
+		RETURNING summarized_at
+	`
+	saved := TraceSummary{TraceID: traceID, Summary: summary, Model: model}
+	if err := db.QueryRow(ctx, query, tenantID, traceID, summary, model).Scan(&saved.SummarizedAt); err != nil {
+		return nil, notFound(err)
+	}
+	return &saved, nil
+}
+
+// SearchTraceContent returns up to limit of the tenant's traces whose text
+// matches query, a web-search style expression such as
+// `"load balancer" -aws`, most relevant first. courseID, when set, limits
+// the search to one course.
+func SearchTraceContent(ctx context.Context, db DBTX, tenantID uuid.UUID, query string, courseID *uuid.UUID, limit int) ([]KeywordResult, error) {
+	where := "tc.tenant_id = $1 AND tc.search @@ q.query"
+	args := []any{tenantID, query, limit}
+	if courseID != nil {
+		args = append(args, *courseID)
+		where += fmt.Sprintf(" AND t.course_id = $%d", len(args))
+	}
+
+	sql := fmt.Sprintf(`
+		SELECT c.id, c.name, c.subject_code, c.course_id, c.semester_term, c.semester_year, c.instructor_id,
+		       t.id, t.file_name, t.vector_id,
+		       ts_rank(tc.search, q.query)::float8 AS rank,
+		       ts_headline('english', tc.content, q.query, 'StartSel=**, StopSel=**, MaxWords=35, MinWords=15')
+		FROM api.trace_contents tc
+		CROSS JOIN websearch_to_tsquery('english', $2) AS q(query)
+		JOIN api.traces t ON t.id = tc.trace_id
+		JOIN api.courses c ON c.id = t.course_id
+		WHERE %s
+		ORDER BY rank DESC, t.date_created DESC
+		LIMIT $3
+	`, where)
+
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []KeywordResult{}
+	for rows.Next() {
+		var r KeywordResult
+		err := rows.Scan(
+			&r.Course.ID,
+			&r.Course.Name,
+			&r.Course.SubjectCode,
+			&r.Course.CourseID,
+			&r.Course.SemesterTerm,
+			&r.Course.SemesterYear,
+			&r.Course.InstructorID,
+			&r.Trace.ID,
+			&r.Trace.FileName,
+			&r.Trace.VectorID,
+			&r.Rank,
+			&r.Snippet,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
@@ -2,8 +2,7 @@
 package model
 
 import (
-	"database/sql"
-	"errors"
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -24,27 +23,226 @@ type Course struct {
 	DateUpdated  time.Time `json:"date_updated"`
 	UserID       uuid.UUID `json:"user_id"`
 	InstructorID uuid.UUID `json:"instructor_id"`
+	// StorageBytes is the total size of the course's traces
+	StorageBytes int64 `json:"storage_bytes"`
+	// ArchivedAt is set while the course is archived
+	ArchivedAt *time.Time `json:"archived_at"`
+	// Instructor and Traces are embedded only when ?expand= asks for them
+	Instructor *Instructor   `json:"instructor,omitempty"`
+	Traces     *CourseTraces `json:"traces,omitempty"`
+}
+
+// CourseTraces sums up a course's traces for a course card
+type CourseTraces struct {
+	Count int `json:"count"`
+	// Latest is the most recently uploaded trace, or nil when there is none
+	Latest *LatestTrace `json:"latest"`
+}
+
+type LatestTrace struct {
+	ID          uuid.UUID `json:"id"`
+	FileName    string    `json:"file_name"`
+	Status      string    `json:"status"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// ExpandCourseTraces names the summary of its traces ?expand= can embed in a course
+const ExpandCourseTraces = "traces"
+
+// CourseExpand says which related resources to embed in a course
+type CourseExpand struct {
+	Instructor bool
+	Traces     bool
 }
 
 type CreateCourseRequest struct {
-	Name         string    `json:"name"`
-	SemesterTerm string    `json:"semester_term"`
-	CreditHours  int       `json:"credit_hours"`
-	SubjectCode  string    `json:"subject_code"`
-	CourseID     int       `json:"course_id"`
-	SemesterYear int       `json:"semester_year"`
-	InstructorID uuid.UUID `json:"instructor_id"`
+	Name         string    `json:"name" validate:"required,max=100"`
+	SemesterTerm string    `json:"semester_term" validate:"required,oneof=Fall Spring Summer"`
+	CreditHours  int       `json:"credit_hours" validate:"gt=0"`
+	SubjectCode  string    `json:"subject_code" validate:"required,max=10"`
+	CourseID     int       `json:"course_id" validate:"gte=1,lte=99999999"`
+	SemesterYear int       `json:"semester_year" validate:"gte=2000"`
+	InstructorID uuid.UUID `json:"instructor_id" validate:"required"`
+}
+
+// Writable returns the fields of course that a PUT sets, which is the
+// document JSON Merge Patch and JSON Patch bodies apply to
+func (c Course) Writable() CreateCourseRequest {
+	return CreateCourseRequest{
+		Name:         c.Name,
+		SemesterTerm: c.SemesterTerm,
+		CreditHours:  c.CreditHours,
+		SubjectCode:  c.SubjectCode,
+		CourseID:     c.CourseID,
+		SemesterYear: c.SemesterYear,
+		InstructorID: c.InstructorID,
+	}
+}
+
+// ChangesFrom is the update that sets the fields of req that differ from
+// course, leaving the rest to whatever they are when it applies
+func (req CreateCourseRequest) ChangesFrom(course Course) UpdateCourseRequest {
+	var changes UpdateCourseRequest
+	if req.Name != course.Name {
+		changes.Name = &req.Name
+	}
+	if req.SemesterTerm != course.SemesterTerm {
+		changes.SemesterTerm = &req.SemesterTerm
+	}
+	if req.CreditHours != course.CreditHours {
+		changes.CreditHours = &req.CreditHours
+	}
+	if req.SubjectCode != course.SubjectCode {
+		changes.SubjectCode = &req.SubjectCode
+	}
+	if req.CourseID != course.CourseID {
+		changes.CourseID = &req.CourseID
+	}
+	if req.SemesterYear != course.SemesterYear {
+		changes.SemesterYear = &req.SemesterYear
+	}
+	if req.InstructorID != course.InstructorID {
+		changes.InstructorID = &req.InstructorID
+	}
+	return changes
+}
+
+// ReplaceRequest is the update that gives a course every field of req, as
+// a PUT replaces the whole course
+func (req CreateCourseRequest) ReplaceRequest() UpdateCourseRequest {
+	return UpdateCourseRequest{
+		Name:         &req.Name,
+		SemesterTerm: &req.SemesterTerm,
+		CreditHours:  &req.CreditHours,
+		SubjectCode:  &req.SubjectCode,
+		CourseID:     &req.CourseID,
+		SemesterYear: &req.SemesterYear,
+		InstructorID: &req.InstructorID,
+	}
 }
 
 // UpdateCourseRequest defines the optional fields for updating a course via PATCH.
 type UpdateCourseRequest struct {
-	Name         *string    `json:"name,omitempty"`
-	SemesterTerm *string    `json:"semester_term,omitempty"`
-	CreditHours  *int       `json:"credit_hours,omitempty"`
-	SubjectCode  *string    `json:"subject_code,omitempty"`
-	CourseID     *int       `json:"course_id,omitempty"`
-	SemesterYear *int       `json:"semester_year,omitempty"`
-	InstructorID *uuid.UUID `json:"instructor_id,omitempty"`
+	Name         *string    `json:"name,omitempty" validate:"omitnil,required,max=100"`
+	SemesterTerm *string    `json:"semester_term,omitempty" validate:"omitnil,oneof=Fall Spring Summer"`
+	CreditHours  *int       `json:"credit_hours,omitempty" validate:"omitnil,gt=0"`
+	SubjectCode  *string    `json:"subject_code,omitempty" validate:"omitnil,required,max=10"`
+	CourseID     *int       `json:"course_id,omitempty" validate:"omitnil,gte=1,lte=99999999"`
+	SemesterYear *int       `json:"semester_year,omitempty" validate:"omitnil,gte=2000"`
+	InstructorID *uuid.UUID `json:"instructor_id,omitempty" validate:"omitnil,required"`
+}
+
+// Apply returns course with the request's fields set
+func (req UpdateCourseRequest) Apply(course Course) Course {
+	if req.Name != nil {
+		course.Name = *req.Name
+	}
+	if req.SemesterTerm != nil {
+		course.SemesterTerm = *req.SemesterTerm
+	}
+	if req.CreditHours != nil {
+		course.CreditHours = *req.CreditHours
+	}
+	if req.SubjectCode != nil {
+		course.SubjectCode = *req.SubjectCode
+	}
+	if req.CourseID != nil {
+		course.CourseID = *req.CourseID
+	}
+	if req.SemesterYear != nil {
+		course.SemesterYear = *req.SemesterYear
+	}
+	if req.InstructorID != nil {
+		course.InstructorID = *req.InstructorID
+	}
+	return course
+}
+
+// CourseFilter picks the courses a bulk update applies to. A field left
+// out matches every course.
+type CourseFilter struct {
+	SemesterTerm *string    `json:"semester_term,omitempty" validate:"omitnil,oneof=Fall Spring Summer"`
+	SemesterYear *int       `json:"semester_year,omitempty" validate:"omitnil,gte=2000"`
+	SubjectCode  *string    `json:"subject_code,omitempty" validate:"omitnil,required,max=10"`
+	InstructorID *uuid.UUID `json:"instructor_id,omitempty" validate:"omitnil,required"`
+}
+
+// Empty reports whether the filter matches every course
+func (f CourseFilter) Empty() bool {
+	return f.SemesterTerm == nil && f.SemesterYear == nil && f.SubjectCode == nil && f.InstructorID == nil
+}
+
+// BulkUpdateCoursesRequest applies the same changes to every course the
+// filter matches, such as moving a semester's courses to the next one
+type BulkUpdateCoursesRequest struct {
+	Filter  CourseFilter        `json:"filter"`
+	Changes UpdateCourseRequest `json:"changes"`
+}
+
+// CourseChange is what a bulk update did, or on a dry run would do, to one
+// course. Changes is empty for a course that already had the new values.
+type CourseChange struct {
+	Course  Course         `json:"course"`
+	Changes map[string]any `json:"changes"`
+}
+
+// UploadTraceRequest holds the multipart fields of a trace upload
+type UploadTraceRequest struct {
+	File       string `json:"file" validate:"required,max=255"`
+	VectorID   string `json:"vector_id" validate:"omitempty,max=100"`
+	Visibility string `json:"visibility" validate:"omitempty,oneof=private course-members public"`
+}
+
+// CreateTraceLinkRequest records a video link as a trace
+type CreateTraceLinkRequest struct {
+	URL        string  `json:"url" validate:"required,max=2048"`
+	VectorID   *string `json:"vector_id,omitempty" validate:"omitnil,max=100"`
+	Visibility string  `json:"visibility,omitempty" validate:"omitempty,oneof=private course-members public"`
+}
+
+// UpdateTraceVisibilityRequest changes who may read a trace
+type UpdateTraceVisibilityRequest struct {
+	Visibility string `json:"visibility" validate:"required,oneof=private course-members public"`
+}
+
+// ReplaceTraceFileRequest holds the multipart fields of a new revision of a
+// trace's file
+type ReplaceTraceFileRequest struct {
+	File string `json:"file" validate:"required,max=255"`
+}
+
+// UploadFormat is how a file type traces may be uploaded as is stored and
+// recognized
+type UploadFormat struct {
+	// Extension ends the stored object's name
+	Extension string
+	// Sniffed is what http.DetectContentType makes of the file's first
+	// bytes; Office documents are ZIP archives
+	Sniffed string
+}
+
+// ContentTypePDF is the type of the traces text extraction and thumbnails
+// handle, and of every trace uploaded before other formats were accepted
+const ContentTypePDF = "application/pdf"
+
+// ContentTypeVideoLink is the type of a video link trace, which points at a
+// video hosted elsewhere rather than storing a file. UPLOAD_ALLOWED_TYPES
+// must list it for links to be accepted.
+const ContentTypeVideoLink = "text/uri-list"
+
+// UploadFormats are the MIME types UPLOAD_ALLOWED_TYPES may list
+var UploadFormats = map[string]UploadFormat{
+	ContentTypePDF: {Extension: ".pdf", Sniffed: "application/pdf"},
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   {Extension: ".docx", Sniffed: "application/zip"},
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": {Extension: ".pptx", Sniffed: "application/zip"},
+	"application/zip": {Extension: ".zip", Sniffed: "application/zip"},
+}
+
+// UpdateTraceStatusRequest is the processing result a pipeline reports for
+// a trace. VectorID, when given, replaces the trace's vector ID.
+type UpdateTraceStatusRequest struct {
+	Status   string  `json:"status" validate:"required,oneof=uploaded processed failed"`
+	VectorID *string `json:"vector_id,omitempty" validate:"omitnil,required,max=100"`
 }
 
 type Trace struct {
@@ -55,60 +253,101 @@ type Trace struct {
 	VectorID     *string   `json:"vector_id"`
 	FileName     string    `json:"file_name"`
 	BucketURL    string    `json:"bucket_url"`
-	DateCreated  time.Time `json:"date_created"`
-	DateUpdated  time.Time `json:"date_updated"`
+	StorageTier  string    `json:"storage_tier"`
+	// ContentType is the MIME type of the uploaded file, one of
+	// UPLOAD_ALLOWED_TYPES when it was uploaded
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	// Visibility is who may read the trace and download its file
+	Visibility string `json:"visibility"`
+	// LinkURL is the video a link trace points at. Link traces have no
+	// stored file: FileName and BucketURL are empty and SizeBytes is 0.
+	LinkURL *string `json:"link_url"`
+	// PublishStatus is how far the trace's pdf-upload event has got to
+	// Kafka, nil for a trace that has none, such as a failed upload
+	PublishStatus *string    `json:"publish_status"`
+	ArchivedAt    *time.Time `json:"archived_at"`
+	DateCreated   time.Time  `json:"date_created"`
+	DateUpdated   time.Time  `json:"date_updated"`
+	// Course and Instructor are embedded only when ?expand= asks for them
+	Course     *Course     `json:"course,omitempty"`
+	Instructor *Instructor `json:"instructor,omitempty"`
 }
 
-func (r *CreateCourseRequest) Validate() error {
-	if r.Name == "" {
-		return errors.New("name is required")
-	}
-	if r.SemesterTerm != "Fall" && r.SemesterTerm != "Spring" && r.SemesterTerm != "Summer" {
-		return errors.New("semester_term must be 'Fall', 'Spring', or 'Summer'")
-	}
-	if r.CreditHours <= 0 {
-		return errors.New("credit_hours must be greater than 0")
-	}
-	if r.SubjectCode == "" {
-		return errors.New("subject_code is required")
-	}
-	if r.CourseID < 1 || r.CourseID > 99999999 {
-		return errors.New("course_id must be between 1 and 99999999")
-	}
-	if r.SemesterYear < 2000 {
-		return errors.New("semester_year must be greater than or equal to 2000")
-	}
-	if r.InstructorID == uuid.Nil {
-		return errors.New("instructor_id is required")
-	}
-	return nil
+// Resources ?expand= can embed in a trace
+const (
+	ExpandCourse     = "course"
+	ExpandInstructor = "instructor"
+)
+
+// TraceExpand says which related resources to embed in traces
+type TraceExpand struct {
+	Course     bool
+	Instructor bool
 }
 
-// Update Validate ensures the provided fields meet database constraints.
-func (r *UpdateCourseRequest) Validate() error {
-	if r.SemesterTerm != nil && *r.SemesterTerm != "Fall" && *r.SemesterTerm != "Spring" && *r.SemesterTerm != "Summer" {
-		return errors.New("semester_term must be 'Fall', 'Spring', or 'Summer'")
-	}
-	if r.CreditHours != nil && *r.CreditHours <= 0 {
-		return errors.New("credit_hours must be greater than 0")
+// Any reports whether anything is to be embedded
+func (e TraceExpand) Any() bool {
+	return e.Course || e.Instructor
+}
+
+// Names returns the JSON names of the embedded resources
+func (e TraceExpand) Names() []string {
+	var names []string
+	if e.Course {
+		names = append(names, ExpandCourse)
 	}
-	if r.CourseID != nil && (*r.CourseID < 1 || *r.CourseID > 99999999) {
-		return errors.New("course_id must be between 1 and 99999999")
+	if e.Instructor {
+		names = append(names, ExpandInstructor)
 	}
-	if r.SemesterYear != nil && *r.SemesterYear < 2000 {
-		return errors.New("semester_year must be greater than or equal to 2000")
+	return names
+}
+
+// Trace visibilities. Private traces are read by admins and service
+// accounts with the trace:read scope only; course-members traces also by
+// any signed-in user of the tenant, and public traces by anyone.
+const (
+	VisibilityPrivate       = "private"
+	VisibilityCourseMembers = "course-members"
+	VisibilityPublic        = "public"
+)
+
+// Trace storage tiers
+const (
+	StorageTierStandard = "standard"
+	StorageTierColdline = "coldline"
+)
+
+// Trace publish statuses, which follow the status of the trace's outbox
+// event: pending until the relay publishes it, failed once it gives up
+const (
+	PublishStatusPending   = "publish_pending"
+	PublishStatusPublished = "published"
+	PublishStatusFailed    = "publish_failed"
+)
+
+// PublishStatusOf returns the trace publish status matching the status of
+// its outbox event
+func PublishStatusOf(outboxStatus string) string {
+	switch outboxStatus {
+	case OutboxStatusPublished:
+		return PublishStatusPublished
+	case OutboxStatusFailed:
+		return PublishStatusFailed
+	default:
+		return PublishStatusPending
 	}
-	return nil
 }
 
-func CreateCourse(db *sql.DB, req CreateCourseRequest, userID uuid.UUID) (*Course, error) {
+func CreateCourse(ctx context.Context, db DBTX, tenantID uuid.UUID, req CreateCourseRequest, userID uuid.UUID) (*Course, error) {
 	var course Course
 	query := `
-		INSERT INTO api.courses (name, semester_term, credit_hours, subject_code, course_id, semester_year, user_id, instructor_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, name, semester_term, credit_hours, subject_code, course_id, semester_year, date_created, date_updated, user_id, instructor_id
+		INSERT INTO api.courses (name, semester_term, credit_hours, subject_code, course_id, semester_year, user_id, instructor_id, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, name, semester_term, credit_hours, subject_code, course_id, semester_year, date_created, date_updated, user_id, instructor_id, storage_bytes, archived_at
 	`
 	err := db.QueryRow(
+		ctx,
 		query,
 		req.Name,
 		req.SemesterTerm,
@@ -118,6 +357,7 @@ func CreateCourse(db *sql.DB, req CreateCourseRequest, userID uuid.UUID) (*Cours
 		req.SemesterYear,
 		userID,
 		req.InstructorID,
+		tenantID,
 	).Scan(
 		&course.ID,
 		&course.Name,
@@ -130,6 +370,8 @@ func CreateCourse(db *sql.DB, req CreateCourseRequest, userID uuid.UUID) (*Cours
 		&course.DateUpdated,
 		&course.UserID,
 		&course.InstructorID,
+		&course.StorageBytes,
+		&course.ArchivedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -137,15 +379,168 @@ func CreateCourse(db *sql.DB, req CreateCourseRequest, userID uuid.UUID) (*Cours
 	return &course, nil
 }
 
-func GetCourseByID(db *sql.DB, courseID uuid.UUID) (*Course, error) {
+// CreateCourses inserts courses in one statement and returns them in the
+// order of reqs, for imports writing many at once
+func CreateCourses(ctx context.Context, db DBTX, tenantID uuid.UUID, reqs []CreateCourseRequest, userID uuid.UUID) ([]Course, error) {
+	if len(reqs) == 0 {
+		return []Course{}, nil
+	}
+	// IDs are generated here, since RETURNING doesn't promise the input order
+	var (
+		ids           = make([]uuid.UUID, len(reqs))
+		names         = make([]string, len(reqs))
+		terms         = make([]string, len(reqs))
+		creditHours   = make([]int, len(reqs))
+		subjectCodes  = make([]string, len(reqs))
+		courseNumbers = make([]int, len(reqs))
+		years         = make([]int, len(reqs))
+		instructorIDs = make([]uuid.UUID, len(reqs))
+	)
+	for i, req := range reqs {
+		ids[i] = uuid.New()
+		names[i] = req.Name
+		terms[i] = req.SemesterTerm
+		creditHours[i] = req.CreditHours
+		subjectCodes[i] = req.SubjectCode
+		courseNumbers[i] = req.CourseID
+		years[i] = req.SemesterYear
+		instructorIDs[i] = req.InstructorID
+	}
+
+	rows, err := db.Query(ctx, `
+		INSERT INTO api.courses (id, name, semester_term, credit_hours, subject_code, course_id, semester_year, user_id, instructor_id, tenant_id)
+		SELECT c.id, c.name, c.semester_term, c.credit_hours, c.subject_code, c.course_id, c.semester_year, $1, c.instructor_id, $2
+		FROM unnest($3::uuid[], $4::text[], $5::text[], $6::integer[], $7::text[], $8::integer[], $9::integer[], $10::uuid[])
+			AS c(id, name, semester_term, credit_hours, subject_code, course_id, semester_year, instructor_id)
+		RETURNING id, name, semester_term, credit_hours, subject_code, course_id, semester_year, date_created, date_updated, user_id, instructor_id, storage_bytes, archived_at
+	`, userID, tenantID, ids, names, terms, creditHours, subjectCodes, courseNumbers, years, instructorIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	created := make(map[uuid.UUID]Course, len(reqs))
+	for rows.Next() {
+		var course Course
+		err := rows.Scan(
+			&course.ID,
+			&course.Name,
+			&course.SemesterTerm,
+			&course.CreditHours,
+			&course.SubjectCode,
+			&course.CourseID,
+			&course.SemesterYear,
+			&course.DateCreated,
+			&course.DateUpdated,
+			&course.UserID,
+			&course.InstructorID,
+			&course.StorageBytes,
+			&course.ArchivedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		created[course.ID] = course
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	courses := make([]Course, len(ids))
+	for i, id := range ids {
+		courses[i] = created[id]
+	}
+	return courses, nil
+}
+
+func GetCourseByID(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID) (*Course, error) {
+	return getCourse(ctx, db, tenantID, courseID, false)
+}
+
+// GetCourseExpanded reads a course together with the related resources
+// expand asks for, in one joined query
+func GetCourseExpanded(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, expand CourseExpand) (*Course, error) {
+	var (
+		course     Course
+		instructor Instructor
+		summary    CourseTraces
+		latestID   *uuid.UUID
+		latest     LatestTrace
+		latestName *string
+		latestStat *string
+		latestAt   *time.Time
+	)
+	columns := `c.id, c.name, c.semester_term, c.credit_hours, c.subject_code, c.course_id,
+		c.semester_year, c.date_created, c.date_updated, c.user_id, c.instructor_id, c.storage_bytes, c.archived_at`
+	joins := ""
+	dest := []any{
+		&course.ID,
+		&course.Name,
+		&course.SemesterTerm,
+		&course.CreditHours,
+		&course.SubjectCode,
+		&course.CourseID,
+		&course.SemesterYear,
+		&course.DateCreated,
+		&course.DateUpdated,
+		&course.UserID,
+		&course.InstructorID,
+		&course.StorageBytes,
+		&course.ArchivedAt,
+	}
+	if expand.Instructor {
+		columns += `, i.id, i.user_id, i.name, i.email, i.date_added, i.date_updated`
+		joins += ` JOIN api.instructors i ON i.id = c.instructor_id`
+		dest = append(dest, &instructor.ID, &instructor.UserID, &instructor.Name, &instructor.Email, &instructor.DateAdded, &instructor.DateUpdated)
+	}
+	if expand.Traces {
+		// The latest trace is read newest first off traces_tenant_course_created_idx
+		columns += `, (SELECT count(*) FROM api.traces t WHERE t.tenant_id = c.tenant_id AND t.course_id = c.id),
+			l.id, l.file_name, l.status, l.date_created`
+		joins += ` LEFT JOIN LATERAL (
+			SELECT id, file_name, status, date_created FROM api.traces t
+			WHERE t.tenant_id = c.tenant_id AND t.course_id = c.id
+			ORDER BY date_created DESC, id DESC
+			LIMIT 1
+		) l ON true`
+		dest = append(dest, &summary.Count, &latestID, &latestName, &latestStat, &latestAt)
+	}
+
+	query := `SELECT ` + columns + ` FROM api.courses c` + joins + ` WHERE c.id = $1 AND c.tenant_id = $2`
+	if err := db.QueryRow(ctx, query, courseID, tenantID).Scan(dest...); err != nil {
+		return nil, notFound(err)
+	}
+
+	if expand.Instructor {
+		course.Instructor = &instructor
+	}
+	if expand.Traces {
+		if latestID != nil {
+			latest.ID, latest.FileName, latest.Status, latest.DateCreated = *latestID, *latestName, *latestStat, *latestAt
+			summary.Latest = &latest
+		}
+		course.Traces = &summary
+	}
+	return &course, nil
+}
+
+// LockCourseByID reads a course with a row lock held until the surrounding transaction ends
+func LockCourseByID(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID) (*Course, error) {
+	return getCourse(ctx, db, tenantID, courseID, true)
+}
+
+func getCourse(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, forUpdate bool) (*Course, error) {
 	var course Course
 	query := `
         SELECT id, name, semester_term, credit_hours, subject_code, course_id, 
-		semester_year, date_created, date_updated, user_id, instructor_id
+		semester_year, date_created, date_updated, user_id, instructor_id, storage_bytes, archived_at
         FROM api.courses
-        WHERE id = $1
+        WHERE id = $1 AND tenant_id = $2
     `
-	err := db.QueryRow(query, courseID).Scan(
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	err := db.QueryRow(ctx, query, courseID, tenantID).Scan(
 		&course.ID,
 		&course.Name,
 		&course.SemesterTerm,
@@ -157,15 +552,156 @@ func GetCourseByID(db *sql.DB, courseID uuid.UUID) (*Course, error) {
 		&course.DateUpdated,
 		&course.UserID,
 		&course.InstructorID,
+		&course.StorageBytes,
+		&course.ArchivedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
+	}
+	return &course, nil
+}
+
+// GetCourseBySection finds a course by what the registrar knows it by: its
+// subject code and number in a semester. Nothing keeps those unique, so the
+// oldest such course wins.
+func GetCourseBySection(ctx context.Context, db DBTX, tenantID uuid.UUID, subjectCode string, courseID int, semesterTerm string, semesterYear int) (*Course, error) {
+	var course Course
+	query := `
+        SELECT id, name, semester_term, credit_hours, subject_code, course_id,
+		semester_year, date_created, date_updated, user_id, instructor_id, storage_bytes, archived_at
+        FROM api.courses
+        WHERE tenant_id = $1 AND subject_code = $2 AND course_id = $3 AND semester_term = $4 AND semester_year = $5
+        ORDER BY date_created, id
+        LIMIT 1
+    `
+	err := db.QueryRow(ctx, query, tenantID, subjectCode, courseID, semesterTerm, semesterYear).Scan(
+		&course.ID,
+		&course.Name,
+		&course.SemesterTerm,
+		&course.CreditHours,
+		&course.SubjectCode,
+		&course.CourseID,
+		&course.SemesterYear,
+		&course.DateCreated,
+		&course.DateUpdated,
+		&course.UserID,
+		&course.InstructorID,
+		&course.StorageBytes,
+		&course.ArchivedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
 	}
 	return &course, nil
 }
 
+// courseListSpec is the ?sort= and ?fields= allowlist for course listings
+var courseListSpec = &listSpec[Course]{
+	table: "api.courses",
+	columns: map[string]listColumn[Course]{
+		"id":            {"id", kindUUID, true, func(c *Course) any { return &c.ID }},
+		"name":          {"name", kindString, true, func(c *Course) any { return &c.Name }},
+		"semester_term": {"semester_term", kindString, true, func(c *Course) any { return &c.SemesterTerm }},
+		"credit_hours":  {"credit_hours", kindInt, true, func(c *Course) any { return &c.CreditHours }},
+		"subject_code":  {"subject_code", kindString, true, func(c *Course) any { return &c.SubjectCode }},
+		"course_id":     {"course_id", kindInt, true, func(c *Course) any { return &c.CourseID }},
+		"semester_year": {"semester_year", kindInt, true, func(c *Course) any { return &c.SemesterYear }},
+		"date_created":  {"date_created", kindTime, true, func(c *Course) any { return &c.DateCreated }},
+		"date_updated":  {"date_updated", kindTime, true, func(c *Course) any { return &c.DateUpdated }},
+		"user_id":       {"user_id", kindUUID, false, func(c *Course) any { return &c.UserID }},
+		"instructor_id": {"instructor_id", kindUUID, false, func(c *Course) any { return &c.InstructorID }},
+		"storage_bytes": {"storage_bytes", kindInt, false, func(c *Course) any { return &c.StorageBytes }},
+		"archived_at":   {"archived_at", kindTime, false, func(c *Course) any { return &c.ArchivedAt }},
+	},
+	aliases:     map[string]string{"created_at": "date_created", "updated_at": "date_updated"},
+	defaultSort: []SortField{{Field: "date_created", Desc: true}},
+}
+
+// ListCourses returns one page of the courses that are archived, or not,
+// newest first unless opts.Sort says otherwise
+func ListCourses(ctx context.Context, db DBTX, tenantID uuid.UUID, archived bool, opts ListOptions) (*Page[Course], error) {
+	where := "tenant_id = $1 AND archived_at IS NULL"
+	if archived {
+		where = "tenant_id = $1 AND archived_at IS NOT NULL"
+	}
+	return list(ctx, db, courseListSpec, where, []any{tenantID}, opts)
+}
+
+// EachCourse calls fn with every course that is archived, or not, in the
+// order ListCourses pages through them, reading them as fn is called
+func EachCourse(ctx context.Context, db DBTX, tenantID uuid.UUID, archived bool, opts ListOptions, fn func(Course) error) error {
+	where := "tenant_id = $1 AND archived_at IS NULL"
+	if archived {
+		where = "tenant_id = $1 AND archived_at IS NOT NULL"
+	}
+	return each(ctx, db, courseListSpec, where, []any{tenantID}, opts, fn)
+}
+
+// SetCourseArchived archives or unarchives a course. Archiving an archived
+// course keeps the time it was archived.
+func SetCourseArchived(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, archived bool) (*Course, error) {
+	var course Course
+	query := `
+        UPDATE api.courses
+        SET archived_at = CASE WHEN $3 THEN COALESCE(archived_at, CURRENT_TIMESTAMP) END,
+            date_updated = CASE WHEN (archived_at IS NOT NULL) = $3 THEN date_updated ELSE CURRENT_TIMESTAMP END
+        WHERE id = $1 AND tenant_id = $2
+        RETURNING id, name, semester_term, credit_hours, subject_code, course_id,
+		semester_year, date_created, date_updated, user_id, instructor_id, storage_bytes, archived_at
+    `
+	err := db.QueryRow(ctx, query, courseID, tenantID, archived).Scan(
+		&course.ID,
+		&course.Name,
+		&course.SemesterTerm,
+		&course.CreditHours,
+		&course.SubjectCode,
+		&course.CourseID,
+		&course.SemesterYear,
+		&course.DateCreated,
+		&course.DateUpdated,
+		&course.UserID,
+		&course.InstructorID,
+		&course.StorageBytes,
+		&course.ArchivedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &course, nil
+}
+
+// ArchiveCoursesBefore archives the courses of every tenant whose semester
+// index is strictly lower than beforeSemester and returns how many it
+// archived. A course an admin has unarchived is left alone. Each archived
+// course gets a course.archived event of no one's making.
+func ArchiveCoursesBefore(ctx context.Context, db DBTX, beforeSemester int) (int, error) {
+	query := `
+		WITH archived AS (
+			UPDATE api.courses
+			SET archived_at = CURRENT_TIMESTAMP, date_updated = CURRENT_TIMESTAMP
+			WHERE archived_at IS NULL
+			AND (semester_year * 3 + CASE semester_term WHEN 'Summer' THEN 1 WHEN 'Fall' THEN 2 ELSE 0 END) < $1
+			AND NOT EXISTS (
+				SELECT 1 FROM api.audit_log a
+				WHERE a.entity_type = 'course' AND a.entity_id = courses.id AND a.action = 'course.unarchived'
+			)
+			RETURNING id, tenant_id, archived_at
+		)
+		INSERT INTO api.events (tenant_id, course_id, aggregate_type, aggregate_id, sequence, type, data)
+		SELECT tenant_id, id, '` + AggregateCourse + `', id,
+			COALESCE((SELECT MAX(sequence) FROM api.events e WHERE e.aggregate_id = archived.id), 0) + 1,
+			'` + EventCourseArchived + `', jsonb_build_object('archived_at', archived_at)
+		FROM archived
+	`
+	result, err := db.Exec(ctx, query, beforeSemester)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
 // UpdateCourse updates a course, always setting user_id to the authenticated user's ID.
-func UpdateCourse(db *sql.DB, courseID uuid.UUID, req UpdateCourseRequest, userID uuid.UUID) (*Course, error) {
+func UpdateCourse(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, req UpdateCourseRequest, userID uuid.UUID) (*Course, error) {
 	var setClauses []string
 	var args []interface{}
 	argIndex := 1
@@ -217,12 +753,12 @@ func UpdateCourse(db *sql.DB, courseID uuid.UUID, req UpdateCourseRequest, userI
 
 	// Construct the SQL query
 	query := "UPDATE api.courses SET " + strings.Join(setClauses, ", ") +
-		fmt.Sprintf(" WHERE id = $%d RETURNING id, name, semester_term, credit_hours, subject_code, course_id, semester_year, date_created, date_updated, user_id, instructor_id", argIndex)
-	args = append(args, courseID)
+		fmt.Sprintf(" WHERE id = $%d AND tenant_id = $%d RETURNING id, name, semester_term, credit_hours, subject_code, course_id, semester_year, date_created, date_updated, user_id, instructor_id, storage_bytes, archived_at", argIndex, argIndex+1)
+	args = append(args, courseID, tenantID)
 
 	// Execute the query and scan the result
 	var course Course
-	err := db.QueryRow(query, args...).Scan(
+	err := db.QueryRow(ctx, query, args...).Scan(
 		&course.ID,
 		&course.Name,
 		&course.SemesterTerm,
@@ -234,56 +770,359 @@ func UpdateCourse(db *sql.DB, courseID uuid.UUID, req UpdateCourseRequest, userI
 		&course.DateUpdated,
 		&course.UserID,
 		&course.InstructorID,
+		&course.StorageBytes,
+		&course.ArchivedAt,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("course not found")
-		}
-		return nil, err
+		return nil, notFound(err)
 	}
 	return &course, nil
 }
 
-func DeleteCourseByID(db *sql.DB, courseID uuid.UUID) error {
-	query := "DELETE FROM api.courses WHERE id = $1"
-	result, err := db.Exec(query, courseID)
+// LockCourses reads the courses filter matches, by subject code, number and
+// semester, with row locks held until the surrounding transaction ends
+func LockCourses(ctx context.Context, db DBTX, tenantID uuid.UUID, filter CourseFilter) ([]Course, error) {
+	conditions := []string{"tenant_id = $1"}
+	args := []any{tenantID}
+	where := func(column string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if filter.SemesterTerm != nil {
+		where("semester_term", *filter.SemesterTerm)
+	}
+	if filter.SemesterYear != nil {
+		where("semester_year", *filter.SemesterYear)
+	}
+	if filter.SubjectCode != nil {
+		where("subject_code", *filter.SubjectCode)
+	}
+	if filter.InstructorID != nil {
+		where("instructor_id", *filter.InstructorID)
+	}
+
+	query := `
+        SELECT id, name, semester_term, credit_hours, subject_code, course_id,
+		semester_year, date_created, date_updated, user_id, instructor_id, storage_bytes, archived_at
+        FROM api.courses
+        WHERE ` + strings.Join(conditions, " AND ") + `
+        ORDER BY subject_code, course_id, semester_year, semester_term, id
+        FOR UPDATE
+    `
+	rows, err := db.Query(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
+	courses := []Course{}
+	for rows.Next() {
+		var course Course
+		err := rows.Scan(
+			&course.ID,
+			&course.Name,
+			&course.SemesterTerm,
+			&course.CreditHours,
+			&course.SubjectCode,
+			&course.CourseID,
+			&course.SemesterYear,
+			&course.DateCreated,
+			&course.DateUpdated,
+			&course.UserID,
+			&course.InstructorID,
+			&course.StorageBytes,
+			&course.ArchivedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		courses = append(courses, course)
+	}
+	return courses, rows.Err()
+}
+
+func DeleteCourseByID(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID) error {
+	query := "DELETE FROM api.courses WHERE id = $1 AND tenant_id = $2"
+	result, err := db.Exec(ctx, query, courseID, tenantID)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-func InsertTrace(db *sql.DB, userID, instructorID uuid.UUID, status string, courseID uuid.UUID, vectorID *string, fileName, bucketURL string) error {
+// InsertTrace records a trace. linkURL is set, and fileName and bucketURL
+// are empty, for a video link, which has no stored file.
+func InsertTrace(ctx context.Context, db DBTX, tenantID, traceID, userID, instructorID uuid.UUID, status string, courseID uuid.UUID, vectorID *string, fileName, bucketURL, contentType string, sizeBytes int64, visibility string, linkURL, publishStatus *string) (*Trace, error) {
 	query := `
-        INSERT INTO api.traces (user_id, instructor_id, status, course_id, vector_id, file_name, bucket_url)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        INSERT INTO api.traces (user_id, instructor_id, status, course_id, vector_id, file_name, bucket_url, tenant_id, content_type, size_bytes, visibility, id, publish_status, link_url)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+        RETURNING id, user_id, instructor_id, status, vector_id, file_name, bucket_url, storage_tier, content_type, size_bytes, visibility, link_url, publish_status, archived_at, date_created, date_updated
     `
-	_, err := db.Exec(query, userID, instructorID, status, courseID, vectorID, fileName, bucketURL)
+
+	var trace Trace
+	err := db.QueryRow(ctx, query, userID, instructorID, status, courseID, vectorID, fileName, bucketURL, tenantID, contentType, sizeBytes, visibility, traceID, publishStatus, linkURL).Scan(
+		&trace.ID,
+		&trace.UserID,
+		&trace.InstructorID,
+		&trace.Status,
+		&trace.VectorID,
+		&trace.FileName,
+		&trace.BucketURL,
+		&trace.StorageTier,
+		&trace.ContentType,
+		&trace.SizeBytes,
+		&trace.Visibility,
+		&trace.LinkURL,
+		&trace.PublishStatus,
+		&trace.ArchivedAt,
+		&trace.DateCreated,
+		&trace.DateUpdated,
+	)
 	if err != nil {
 		log.Printf("Database error: %v", err)
+		return nil, err
+	}
+	return &trace, nil
+}
+
+// traceListSpec is the ?sort= and ?fields= allowlist for trace listings
+var traceListSpec = &listSpec[Trace]{
+	table: "api.traces",
+	columns: map[string]listColumn[Trace]{
+		"id":             {"id", kindUUID, true, func(t *Trace) any { return &t.ID }},
+		"user_id":        {"user_id", kindUUID, false, func(t *Trace) any { return &t.UserID }},
+		"instructor_id":  {"instructor_id", kindUUID, false, func(t *Trace) any { return &t.InstructorID }},
+		"status":         {"status", kindString, true, func(t *Trace) any { return &t.Status }},
+		"vector_id":      {"vector_id", kindString, false, func(t *Trace) any { return &t.VectorID }},
+		"file_name":      {"file_name", kindString, true, func(t *Trace) any { return &t.FileName }},
+		"bucket_url":     {"bucket_url", kindString, false, func(t *Trace) any { return &t.BucketURL }},
+		"storage_tier":   {"storage_tier", kindString, true, func(t *Trace) any { return &t.StorageTier }},
+		"content_type":   {"content_type", kindString, true, func(t *Trace) any { return &t.ContentType }},
+		"size_bytes":     {"size_bytes", kindInt, false, func(t *Trace) any { return &t.SizeBytes }},
+		"visibility":     {"visibility", kindString, true, func(t *Trace) any { return &t.Visibility }},
+		"link_url":       {"link_url", kindString, false, func(t *Trace) any { return &t.LinkURL }},
+		"publish_status": {"publish_status", kindString, false, func(t *Trace) any { return &t.PublishStatus }},
+		"archived_at":    {"archived_at", kindTime, false, func(t *Trace) any { return &t.ArchivedAt }},
+		"date_created":   {"date_created", kindTime, true, func(t *Trace) any { return &t.DateCreated }},
+		"date_updated":   {"date_updated", kindTime, true, func(t *Trace) any { return &t.DateUpdated }},
+	},
+	aliases:     map[string]string{"created_at": "date_created", "updated_at": "date_updated"},
+	defaultSort: []SortField{{Field: "date_created", Desc: true}},
+}
+
+// GetTracesByCourseID returns one page of a course's traces with one of the
+// visible visibilities, or of all of them if visible is nil, newest first
+// unless opts.Sort says otherwise
+func GetTracesByCourseID(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, visible []string, opts ListOptions) (*Page[Trace], error) {
+	where, args := courseTraces(tenantID, courseID, visible)
+	return list(ctx, db, traceListSpec, where, args, opts)
+}
+
+// EachTraceByCourseID calls fn with every trace of a course, in the order
+// GetTracesByCourseID pages through them, reading them as fn is called
+func EachTraceByCourseID(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, visible []string, opts ListOptions, fn func(Trace) error) error {
+	where, args := courseTraces(tenantID, courseID, visible)
+	return each(ctx, db, traceListSpec, where, args, opts, fn)
+}
+
+// courseTraces is the condition selecting a course's traces with one of the
+// visible visibilities, or all of them if visible is nil
+func courseTraces(tenantID, courseID uuid.UUID, visible []string) (string, []any) {
+	if visible == nil {
+		return "tenant_id = $1 AND course_id = $2", []any{tenantID, courseID}
+	}
+	return "tenant_id = $1 AND course_id = $2 AND visibility = ANY($3)", []any{tenantID, courseID, visible}
+}
+
+// ExpandTraces embeds in each trace its course and instructor, as expand
+// asks, reading them for all the traces with one joined query
+func ExpandTraces(ctx context.Context, db DBTX, tenantID uuid.UUID, traces []Trace, expand TraceExpand) error {
+	if len(traces) == 0 || !expand.Any() {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(traces))
+	for i, t := range traces {
+		ids[i] = t.ID
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT t.id,
+			c.id, c.name, c.semester_term, c.credit_hours, c.subject_code, c.course_id, c.semester_year,
+			c.date_created, c.date_updated, c.user_id, c.instructor_id, c.storage_bytes, c.archived_at,
+			i.id, i.user_id, i.name, i.email, i.date_added, i.date_updated
+		FROM api.traces t
+		JOIN api.courses c ON c.id = t.course_id
+		JOIN api.instructors i ON i.id = t.instructor_id
+		WHERE t.tenant_id = $1 AND t.id = ANY($2)
+	`, tenantID, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type related struct {
+		course     Course
+		instructor Instructor
+	}
+	found := make(map[uuid.UUID]*related, len(traces))
+	for rows.Next() {
+		var (
+			traceID uuid.UUID
+			r       related
+		)
+		err := rows.Scan(
+			&traceID,
+			&r.course.ID,
+			&r.course.Name,
+			&r.course.SemesterTerm,
+			&r.course.CreditHours,
+			&r.course.SubjectCode,
+			&r.course.CourseID,
+			&r.course.SemesterYear,
+			&r.course.DateCreated,
+			&r.course.DateUpdated,
+			&r.course.UserID,
+			&r.course.InstructorID,
+			&r.course.StorageBytes,
+			&r.course.ArchivedAt,
+			&r.instructor.ID,
+			&r.instructor.UserID,
+			&r.instructor.Name,
+			&r.instructor.Email,
+			&r.instructor.DateAdded,
+			&r.instructor.DateUpdated,
+		)
+		if err != nil {
+			return err
+		}
+		found[traceID] = &r
+	}
+	if err := rows.Err(); err != nil {
+		return err
 	}
-	return err
+
+	for i := range traces {
+		r, ok := found[traces[i].ID]
+		if !ok {
+			continue
+		}
+		if expand.Course {
+			traces[i].Course = &r.course
+		}
+		if expand.Instructor {
+			traces[i].Instructor = &r.instructor
+		}
+	}
+	return nil
 }
 
-func GetTracesByCourseID(db *sql.DB, courseID uuid.UUID) ([]Trace, error) {
+func GetTraceByID(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID) (*Trace, error) {
 	query := `
-        SELECT id, user_id, instructor_id, course_id, status, vector_id, file_name, bucket_url, date_created, date_updated
-        FROM api.traces
-        WHERE course_id = $1
-        ORDER BY date_created DESC
-    `
+		SELECT id, user_id, instructor_id, status, vector_id, file_name, bucket_url, storage_tier, content_type, size_bytes, visibility, link_url, publish_status, archived_at, date_created, date_updated
+		FROM api.traces
+		WHERE course_id = $1 AND id = $2 AND tenant_id = $3
+	`
+
+	var trace Trace
 
-	rows, err := db.Query(query, courseID)
+	err := db.QueryRow(ctx, query, courseID, traceID, tenantID).Scan(
+		&trace.ID,
+		&trace.UserID,
+		&trace.InstructorID,
+		&trace.Status,
+		&trace.VectorID,
+		&trace.FileName,
+		&trace.BucketURL,
+		&trace.StorageTier,
+		&trace.ContentType,
+		&trace.SizeBytes,
+		&trace.Visibility,
+		&trace.LinkURL,
+		&trace.PublishStatus,
+		&trace.ArchivedAt,
+		&trace.DateCreated,
+		&trace.DateUpdated,
+	)
+
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &trace, nil
+}
+
+// DeleteTraceByID deletes a trace and returns its size, earlier revisions
+// included, for its tenant's storage usage
+func DeleteTraceByID(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID) (int64, error) {
+	query := `
+		DELETE FROM api.traces
+		WHERE course_id = $1 AND id = $2 AND tenant_id = $3
+		RETURNING size_bytes + ` + revisionBytes
+
+	var sizeBytes int64
+	if err := db.QueryRow(ctx, query, courseID, traceID, tenantID).Scan(&sizeBytes); err != nil {
+		return 0, notFound(err)
+	}
+	return sizeBytes, nil
+}
+
+// SemesterIndex orders semesters chronologically (Spring < Summer < Fall within a year)
+func SemesterIndex(year int, term string) int {
+	offset := 0
+	switch term {
+	case "Summer":
+		offset = 1
+	case "Fall":
+		offset = 2
+	}
+	return year*3 + offset
+}
+
+// CurrentSemesterIndex returns the SemesterIndex of the semester containing t
+func CurrentSemesterIndex(t time.Time) int {
+	switch {
+	case t.Month() <= time.May:
+		return SemesterIndex(t.Year(), "Spring")
+	case t.Month() <= time.July:
+		return SemesterIndex(t.Year(), "Summer")
+	default:
+		return SemesterIndex(t.Year(), "Fall")
+	}
+}
+
+// CreateTracePartitions creates the partitions of api.traces for the current
+// semester and every semester starting within monthsAhead months, and
+// returns how many it created. Partitions that exist are left alone.
+func CreateTracePartitions(ctx context.Context, db DBTX, monthsAhead int) (int, error) {
+	var created int
+	err := db.QueryRow(ctx,
+		"SELECT api.create_trace_partitions(LOCALTIMESTAMP, LOCALTIMESTAMP + make_interval(months => $1))",
+		monthsAhead,
+	).Scan(&created)
+	return created, err
+}
+
+// GetArchivableTraces returns uploaded traces still in standard storage whose
+// course semester index is strictly lower than beforeSemester.
+func GetArchivableTraces(ctx context.Context, db DBTX, beforeSemester int, limit int) ([]Trace, error) {
+	query := `
+		SELECT t.id, t.user_id, t.instructor_id, t.status, t.vector_id, t.file_name, t.bucket_url, t.storage_tier, t.content_type, t.size_bytes, t.visibility, t.link_url, t.publish_status, t.archived_at, t.date_created, t.date_updated
+		FROM api.traces t
+		JOIN api.courses c ON c.id = t.course_id
+		WHERE t.storage_tier = 'standard'
+		AND t.status <> 'failed'
+		AND t.bucket_url <> ''
+		AND (c.semester_year * 3 + CASE c.semester_term WHEN 'Summer' THEN 1 WHEN 'Fall' THEN 2 ELSE 0 END) < $1
+		ORDER BY t.date_created
+		LIMIT $2
+	`
+
+	rows, err := db.Query(ctx, query, beforeSemester, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -292,29 +1131,100 @@ func GetTracesByCourseID(db *sql.DB, courseID uuid.UUID) ([]Trace, error) {
 	var traces []Trace
 	for rows.Next() {
 		var trace Trace
-		var vectorID sql.NullString
-
 		err := rows.Scan(
 			&trace.ID,
 			&trace.UserID,
 			&trace.InstructorID,
-			&courseID,
 			&trace.Status,
-			&vectorID,
+			&trace.VectorID,
 			&trace.FileName,
 			&trace.BucketURL,
+			&trace.StorageTier,
+			&trace.ContentType,
+			&trace.SizeBytes,
+			&trace.Visibility,
+			&trace.LinkURL,
+			&trace.PublishStatus,
+			&trace.ArchivedAt,
 			&trace.DateCreated,
 			&trace.DateUpdated,
 		)
 		if err != nil {
 			return nil, err
 		}
+		traces = append(traces, trace)
+	}
 
-		if vectorID.Valid {
-			vectorIDStr := vectorID.String
-			trace.VectorID = &vectorIDStr
-		}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return traces, nil
+}
+
+// UpdateTraceStorage records a trace's new object location and storage tier
+func UpdateTraceStorage(ctx context.Context, db DBTX, traceID uuid.UUID, storageTier, bucketURL string) error {
+	query := `
+		UPDATE api.traces
+		SET storage_tier = $2,
+			bucket_url = $3,
+			archived_at = CASE WHEN $4 THEN CURRENT_TIMESTAMP ELSE NULL END,
+			date_updated = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	result, err := db.Exec(ctx, query, traceID, storageTier, bucketURL, storageTier != StorageTierStandard)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ListStoredTraces returns every trace whose upload succeeded, for checking
+// the database against the objects actually in storage
+func ListStoredTraces(ctx context.Context, db DBTX) ([]Trace, error) {
+	query := `
+		SELECT id, user_id, instructor_id, status, vector_id, file_name, bucket_url, storage_tier, content_type, size_bytes, visibility, link_url, publish_status, archived_at, date_created, date_updated
+		FROM api.traces
+		WHERE status <> 'failed'
+		ORDER BY date_created
+	`
 
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var traces []Trace
+	for rows.Next() {
+		var trace Trace
+		err := rows.Scan(
+			&trace.ID,
+			&trace.UserID,
+			&trace.InstructorID,
+			&trace.Status,
+			&trace.VectorID,
+			&trace.FileName,
+			&trace.BucketURL,
+			&trace.StorageTier,
+			&trace.ContentType,
+			&trace.SizeBytes,
+			&trace.Visibility,
+			&trace.LinkURL,
+			&trace.PublishStatus,
+			&trace.ArchivedAt,
+			&trace.DateCreated,
+			&trace.DateUpdated,
+		)
+		if err != nil {
+			return nil, err
+		}
 		traces = append(traces, trace)
 	}
 
@@ -325,61 +1235,60 @@ func GetTracesByCourseID(db *sql.DB, courseID uuid.UUID) ([]Trace, error) {
 	return traces, nil
 }
 
-func GetTraceByID(db *sql.DB, courseID, traceID uuid.UUID) (*Trace, error) {
+// UpdateTraceStatus sets a trace's processing status and, if given, its vector ID
+func UpdateTraceStatus(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID, req UpdateTraceStatusRequest) (*Trace, error) {
 	query := `
-		SELECT id, user_id, instructor_id, status, vector_id, file_name, bucket_url, date_created, date_updated
-		FROM api.traces
-		WHERE course_id = $1 AND id = $2
+		UPDATE api.traces SET status = $4, vector_id = COALESCE($5, vector_id), date_updated = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND course_id = $2 AND id = $3
+		RETURNING id, user_id, instructor_id, status, vector_id, file_name, bucket_url, storage_tier, content_type, size_bytes, visibility, link_url, publish_status, archived_at, date_created, date_updated
 	`
 
 	var trace Trace
-	var vectorID sql.NullString
-
-	err := db.QueryRow(query, courseID, traceID).Scan(
+	err := db.QueryRow(ctx, query, tenantID, courseID, traceID, req.Status, req.VectorID).Scan(
 		&trace.ID,
 		&trace.UserID,
 		&trace.InstructorID,
 		&trace.Status,
-		&vectorID,
+		&trace.VectorID,
 		&trace.FileName,
 		&trace.BucketURL,
+		&trace.StorageTier,
+		&trace.ContentType,
+		&trace.SizeBytes,
+		&trace.Visibility,
+		&trace.LinkURL,
+		&trace.PublishStatus,
+		&trace.ArchivedAt,
 		&trace.DateCreated,
 		&trace.DateUpdated,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, sql.ErrNoRows
-		}
-		return nil, err
-	}
-
-	if vectorID.Valid {
-		vectorIDStr := vectorID.String
-		trace.VectorID = &vectorIDStr
+		return nil, notFound(err)
 	}
-
 	return &trace, nil
 }
 
-func DeleteTraceByID(db *sql.DB, courseID, traceID uuid.UUID) error {
-	query := `
-		DELETE FROM api.traces
-		WHERE course_id = $1 AND id = $2
-	`
-
-	result, err := db.Exec(query, courseID, traceID)
+// LockTraceStatus returns a trace's status, locking the row until the
+// transaction ends
+func LockTraceStatus(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID) (string, error) {
+	var status string
+	err := db.QueryRow(ctx, "SELECT status FROM api.traces WHERE tenant_id = $1 AND course_id = $2 AND id = $3 FOR UPDATE",
+		tenantID, courseID, traceID).Scan(&status)
 	if err != nil {
-		return err
+		return "", notFound(err)
 	}
+	return status, nil
+}
 
-	rowsAffected, err := result.RowsAffected()
+// MarkTraceFailed sets a trace's status to failed
+func MarkTraceFailed(ctx context.Context, db DBTX, traceID uuid.UUID) error {
+	result, err := db.Exec(ctx, `UPDATE api.traces SET status = 'failed', date_updated = CURRENT_TIMESTAMP WHERE id = $1`, traceID)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
 	}
 
 	return nil
@@ -0,0 +1,228 @@
+// internal/model/course_bench_test.go
+package model_test
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/testutil"
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// benchRows is how many courses, and traces of the benchmarked course, the
+// listings page through
+const benchRows = 200
+
+// queryBench is a migrated Postgres with a course catalog in it, reached
+// through the pgxpool the server uses and through database/sql. The
+// database/sql side runs each statement the way lib/pq did before the move
+// to pgx, parsing and describing it on every call, where the pool prepares
+// it once per connection and caches it.
+type queryBench struct {
+	pool       *pgxpool.Pool
+	db         *sql.DB
+	userID     uuid.UUID
+	instructor *model.Instructor
+	course     model.Course
+}
+
+func newQueryBench(b *testing.B) *queryBench {
+	b.Helper()
+	pool, userID := newBenchDatabase(b)
+	ctx := context.Background()
+
+	connConfig := pool.Config().ConnConfig.Copy()
+	connConfig.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+	db := stdlib.OpenDB(*connConfig)
+	db.SetMaxOpenConns(int(pool.Config().MaxConns))
+	db.SetMaxIdleConns(int(pool.Config().MaxConns))
+	b.Cleanup(func() { db.Close() })
+
+	instructor, err := model.CreateInstructor(ctx, pool, model.DefaultTenantID,
+		model.CreateInstructorRequest{Name: "Ada Lovelace", Email: "ada@example.edu"}, userID)
+	if err != nil {
+		b.Fatalf("failed to create instructor: %v", err)
+	}
+	reqs := make([]model.CreateCourseRequest, benchRows)
+	for i := range reqs {
+		reqs[i] = model.CreateCourseRequest{
+			Name:         fmt.Sprintf("Course %d", i),
+			SemesterTerm: "Fall",
+			CreditHours:  4,
+			SubjectCode:  "CSYE",
+			CourseID:     6000 + i,
+			SemesterYear: 2025,
+			InstructorID: instructor.ID,
+		}
+	}
+	courses, err := model.CreateCourses(ctx, pool, model.DefaultTenantID, reqs, userID)
+	if err != nil {
+		b.Fatalf("failed to create courses: %v", err)
+	}
+	env := &queryBench{pool: pool, db: db, userID: userID, instructor: instructor, course: courses[len(courses)-1]}
+	for range benchRows {
+		if err := env.insertTrace(ctx, pool, model.VisibilityCourseMembers); err != nil {
+			b.Fatalf("failed to create trace: %v", err)
+		}
+	}
+	return env
+}
+
+// insertTrace records an upload to the benchmarked course as the upload
+// handler does
+func (e *queryBench) insertTrace(ctx context.Context, db model.DBTX, visibility string) error {
+	id := uuid.New()
+	fileName := fmt.Sprintf("ada_lovelace/csye_6000/fall_2025/lecture_%s.pdf", id)
+	publishStatus := model.PublishStatusPending
+	_, err := model.InsertTrace(ctx, db, model.DefaultTenantID, id, e.userID, e.instructor.ID, "uploaded", e.course.ID,
+		nil, fileName, "gs://traces/"+fileName, model.ContentTypePDF, 16<<10, visibility, nil, &publishStatus)
+	return err
+}
+
+// run measures query, one of the model's functions, on the pgxpool, and the
+// statements it runs replayed on database/sql. The database/sql side only
+// reads the raw column values, so it isn't charged for the model's scanning.
+func (e *queryBench) run(b *testing.B, query func(db model.DBTX) error) {
+	statements := testutil.RecordStatements(b, e.pool, query)
+	b.Run("pgxpool", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := query(e.pool); err != nil {
+					b.Errorf("pgxpool: %v", err)
+					return
+				}
+			}
+		})
+	})
+	b.Run("database-sql", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := replay(context.Background(), e.db, statements); err != nil {
+					b.Errorf("database/sql: %v", err)
+					return
+				}
+			}
+		})
+	})
+}
+
+// queryer is *sql.DB or *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// replay runs statements on db and reads every row they return
+func replay(ctx context.Context, db queryer, statements []testutil.Statement) error {
+	for _, s := range statements {
+		rows, err := db.QueryContext(ctx, s.SQL, s.Args...)
+		if err != nil {
+			return err
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		values := make([]any, len(columns))
+		for i := range values {
+			values[i] = new(sql.RawBytes)
+		}
+		for rows.Next() {
+			if err := rows.Scan(values...); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func BenchmarkListCourses(b *testing.B) {
+	env := newQueryBench(b)
+	env.run(b, func(db model.DBTX) error {
+		_, err := model.ListCourses(context.Background(), db, model.DefaultTenantID, false, model.ListOptions{})
+		return err
+	})
+}
+
+func BenchmarkGetCourse(b *testing.B) {
+	env := newQueryBench(b)
+	env.run(b, func(db model.DBTX) error {
+		_, err := model.GetCourseByID(context.Background(), db, model.DefaultTenantID, env.course.ID)
+		return err
+	})
+}
+
+func BenchmarkGetTracesByCourseID(b *testing.B) {
+	env := newQueryBench(b)
+	audience := []string{model.VisibilityCourseMembers, model.VisibilityPublic}
+	env.run(b, func(db model.DBTX) error {
+		_, err := model.GetTracesByCourseID(context.Background(), db, model.DefaultTenantID, env.course.ID, audience, model.ListOptions{})
+		return err
+	})
+}
+
+// BenchmarkInsertTrace measures the row an upload writes. Each insert is
+// rolled back, so the database/sql side can replay the recorded one, ID and
+// all.
+func BenchmarkInsertTrace(b *testing.B) {
+	env := newQueryBench(b)
+	ctx := context.Background()
+	tx, err := env.pool.Begin(ctx)
+	if err != nil {
+		b.Fatalf("failed to begin: %v", err)
+	}
+	statements := testutil.RecordStatements(b, tx, func(db model.DBTX) error {
+		return env.insertTrace(ctx, db, model.VisibilityPrivate)
+	})
+	tx.Rollback(ctx)
+
+	b.Run("pgxpool", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				tx, err := env.pool.Begin(ctx)
+				if err != nil {
+					b.Errorf("failed to begin: %v", err)
+					return
+				}
+				err = env.insertTrace(ctx, tx, model.VisibilityPrivate)
+				tx.Rollback(ctx)
+				if err != nil {
+					b.Errorf("pgxpool: %v", err)
+					return
+				}
+			}
+		})
+	})
+	b.Run("database-sql", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				tx, err := env.db.BeginTx(ctx, nil)
+				if err != nil {
+					b.Errorf("failed to begin: %v", err)
+					return
+				}
+				err = replay(ctx, tx, statements)
+				tx.Rollback()
+				if err != nil {
+					b.Errorf("database/sql: %v", err)
+					return
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,141 @@
+// internal/model/courseversion.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CourseVersion is a course as one change left it. Version 1 is the course
+// as created; each update that changes it adds the next version, made by
+// UserID, with the fields that differ from the version before.
+type CourseVersion struct {
+	ID           uuid.UUID      `json:"id"`
+	Version      int            `json:"version"`
+	Name         string         `json:"name"`
+	SemesterTerm string         `json:"semester_term"`
+	CreditHours  int            `json:"credit_hours"`
+	SubjectCode  string         `json:"subject_code"`
+	CourseID     int            `json:"course_id"`
+	SemesterYear int            `json:"semester_year"`
+	InstructorID uuid.UUID      `json:"instructor_id"`
+	UserID       uuid.UUID      `json:"user_id"`
+	Changes      map[string]any `json:"changes"`
+	DateCreated  time.Time      `json:"date_created"`
+}
+
+// NewCourseVersion is the version course is in after changes, from
+// DiffCourses; who last edited the course is not a change of its own
+func NewCourseVersion(course *Course, changes map[string]any) CourseVersion {
+	fields := map[string]any{}
+	for field, change := range changes {
+		if field != "user_id" {
+			fields[field] = change
+		}
+	}
+	return CourseVersion{
+		Name:         course.Name,
+		SemesterTerm: course.SemesterTerm,
+		CreditHours:  course.CreditHours,
+		SubjectCode:  course.SubjectCode,
+		CourseID:     course.CourseID,
+		SemesterYear: course.SemesterYear,
+		InstructorID: course.InstructorID,
+		UserID:       course.UserID,
+		Changes:      fields,
+		DateCreated:  course.DateUpdated,
+	}
+}
+
+// RevertRequest is the update that puts a course back the way version v
+// left it
+func (v CourseVersion) RevertRequest() UpdateCourseRequest {
+	return UpdateCourseRequest{
+		Name:         &v.Name,
+		SemesterTerm: &v.SemesterTerm,
+		CreditHours:  &v.CreditHours,
+		SubjectCode:  &v.SubjectCode,
+		CourseID:     &v.CourseID,
+		SemesterYear: &v.SemesterYear,
+		InstructorID: &v.InstructorID,
+	}
+}
+
+// courseVersionListSpec is the ?sort= and ?fields= allowlist for course
+// versions
+var courseVersionListSpec = &listSpec[CourseVersion]{
+	table: "api.course_versions",
+	columns: map[string]listColumn[CourseVersion]{
+		"id":            {"id", kindUUID, true, func(v *CourseVersion) any { return &v.ID }},
+		"version":       {"version", kindInt, true, func(v *CourseVersion) any { return &v.Version }},
+		"name":          {"name", kindString, false, func(v *CourseVersion) any { return &v.Name }},
+		"semester_term": {"semester_term", kindString, false, func(v *CourseVersion) any { return &v.SemesterTerm }},
+		"credit_hours":  {"credit_hours", kindInt, false, func(v *CourseVersion) any { return &v.CreditHours }},
+		"subject_code":  {"subject_code", kindString, false, func(v *CourseVersion) any { return &v.SubjectCode }},
+		"course_id":     {"course_number", kindInt, false, func(v *CourseVersion) any { return &v.CourseID }},
+		"semester_year": {"semester_year", kindInt, false, func(v *CourseVersion) any { return &v.SemesterYear }},
+		"instructor_id": {"instructor_id", kindUUID, false, func(v *CourseVersion) any { return &v.InstructorID }},
+		"user_id":       {"user_id", kindUUID, false, func(v *CourseVersion) any { return &v.UserID }},
+		"changes":       {"changes", kindString, false, func(v *CourseVersion) any { return &v.Changes }},
+		"date_created":  {"date_created", kindTime, true, func(v *CourseVersion) any { return &v.DateCreated }},
+	},
+	defaultSort: []SortField{{Field: "version", Desc: true}},
+}
+
+const courseVersionColumns = `id, version, name, semester_term, credit_hours, subject_code, course_number,
+	semester_year, instructor_id, user_id, changes, date_created`
+
+// InsertCourseVersion records v as the next version of one of the tenant's
+// courses. The caller holds the course's row lock, so versions are numbered
+// without gaps or clashes.
+func InsertCourseVersion(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, v CourseVersion) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO api.course_versions (tenant_id, course_id, version, user_id, name, semester_term,
+			credit_hours, subject_code, course_number, semester_year, instructor_id, changes, date_created)
+		SELECT $1, $2, COALESCE(MAX(version), 0) + 1, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+		FROM api.course_versions WHERE course_id = $2`,
+		tenantID, courseID, v.UserID, v.Name, v.SemesterTerm, v.CreditHours, v.SubjectCode, v.CourseID,
+		v.SemesterYear, v.InstructorID, v.Changes, v.DateCreated)
+	return err
+}
+
+// InsertFirstCourseVersions records version 1 of each of the courses just
+// created, as they are now, in one statement
+func InsertFirstCourseVersions(ctx context.Context, db DBTX, tenantID uuid.UUID, courseIDs []uuid.UUID) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO api.course_versions (tenant_id, course_id, version, user_id, name, semester_term,
+			credit_hours, subject_code, course_number, semester_year, instructor_id, date_created)
+		SELECT tenant_id, id, 1, user_id, name, semester_term, credit_hours, subject_code, course_id,
+			semester_year, instructor_id, date_updated
+		FROM api.courses WHERE tenant_id = $1 AND id = ANY($2)`,
+		tenantID, courseIDs)
+	return err
+}
+
+// ListCourseVersions returns one page of a course's versions, newest first
+// unless opts.Sort says otherwise. The caller checks the course exists.
+func ListCourseVersions(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, opts ListOptions) (*Page[CourseVersion], error) {
+	return list(ctx, db, courseVersionListSpec, "tenant_id = $1 AND course_id = $2", []any{tenantID, courseID}, opts)
+}
+
+// PaginateCourseVersions pages through versions held in memory like
+// ListCourseVersions does
+func PaginateCourseVersions(versions []CourseVersion, opts ListOptions) (*Page[CourseVersion], error) {
+	return paginate(courseVersionListSpec, versions, opts)
+}
+
+// GetCourseVersion returns one version of one of the tenant's courses
+func GetCourseVersion(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, version int) (*CourseVersion, error) {
+	var v CourseVersion
+	err := db.QueryRow(ctx, `
+		SELECT `+courseVersionColumns+` FROM api.course_versions
+		WHERE tenant_id = $1 AND course_id = $2 AND version = $3`, tenantID, courseID, version).Scan(
+		&v.ID, &v.Version, &v.Name, &v.SemesterTerm, &v.CreditHours, &v.SubjectCode, &v.CourseID,
+		&v.SemesterYear, &v.InstructorID, &v.UserID, &v.Changes, &v.DateCreated)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &v, nil
+}
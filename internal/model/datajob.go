@@ -0,0 +1,159 @@
+// internal/model/datajob.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Data job kinds
+const (
+	DataJobExport = "export"
+	DataJobErase  = "erase"
+)
+
+// Data job statuses
+const (
+	DataJobPending   = "pending"
+	DataJobRunning   = "running"
+	DataJobCompleted = "completed"
+	DataJobFailed    = "failed"
+)
+
+// DataJob is a requested export or erasure of a user's personal data, run in
+// the background by the data job runner
+type DataJob struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"-"`
+	Kind        string    `json:"kind"`
+	UserID      uuid.UUID `json:"user_id"`
+	RequestedBy uuid.UUID `json:"requested_by"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	Error       *string   `json:"error"`
+	// ResultObject is the storage object holding an export's archive. Erasing
+	// the user deletes it.
+	ResultObject  *string    `json:"-"`
+	DateCreated   time.Time  `json:"date_created"`
+	DateUpdated   time.Time  `json:"date_updated"`
+	DateCompleted *time.Time `json:"date_completed"`
+}
+
+// Done reports whether the job has finished, successfully or not
+func (j *DataJob) Done() bool {
+	return j.Status == DataJobCompleted || j.Status == DataJobFailed
+}
+
+const dataJobColumns = "id, tenant_id, kind, user_id, requested_by, status, attempts, error, result_object, date_created, date_updated, date_completed"
+
+func CreateDataJob(ctx context.Context, db DBTX, tenantID uuid.UUID, kind string, userID, requestedBy uuid.UUID) (*DataJob, error) {
+	query := `
+		INSERT INTO api.data_jobs (tenant_id, kind, user_id, requested_by)
+		SELECT $1, $2, id, $4 FROM api.users WHERE id = $3 AND tenant_id = $1
+		RETURNING ` + dataJobColumns
+	return scanDataJob(db.QueryRow(ctx, query, tenantID, kind, userID, requestedBy))
+}
+
+// ListDataJobs returns a user's jobs, newest first
+func ListDataJobs(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) ([]DataJob, error) {
+	query := "SELECT " + dataJobColumns + " FROM api.data_jobs WHERE tenant_id = $1 AND user_id = $2 ORDER BY date_created DESC"
+	rows, err := db.Query(ctx, query, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []DataJob{}
+	for rows.Next() {
+		job, err := scanDataJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+func GetDataJob(ctx context.Context, db DBTX, tenantID, userID, jobID uuid.UUID) (*DataJob, error) {
+	query := "SELECT " + dataJobColumns + " FROM api.data_jobs WHERE id = $1 AND tenant_id = $2 AND user_id = $3"
+	return scanDataJob(db.QueryRow(ctx, query, jobID, tenantID, userID))
+}
+
+// ClaimDataJob marks the oldest pending job, or running job whose lease has
+// run out, as running for lease and counts the attempt. SKIP LOCKED lets
+// several runners claim jobs at once. ErrNotFound means there is none.
+func ClaimDataJob(ctx context.Context, db DBTX, lease time.Duration) (*DataJob, error) {
+	query := `
+		UPDATE api.data_jobs
+		SET status = 'running',
+			attempts = attempts + 1,
+			lease_expires = CURRENT_TIMESTAMP + $1 * INTERVAL '1 millisecond',
+			date_updated = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM api.data_jobs
+			WHERE status = 'pending' OR (status = 'running' AND lease_expires < CURRENT_TIMESTAMP)
+			ORDER BY date_created
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + dataJobColumns
+	return scanDataJob(db.QueryRow(ctx, query, lease.Milliseconds()))
+}
+
+// FinishDataJob records a claimed job's status, error and result object.
+// Completed and failed jobs get their completion date.
+func FinishDataJob(ctx context.Context, db DBTX, job DataJob) error {
+	query := `
+		UPDATE api.data_jobs
+		SET status = $2,
+			error = $3,
+			result_object = $4,
+			lease_expires = NULL,
+			date_updated = CURRENT_TIMESTAMP,
+			date_completed = CASE WHEN $5 THEN CURRENT_TIMESTAMP END
+		WHERE id = $1
+	`
+
+	result, err := db.Exec(ctx, query, job.ID, job.Status, job.Error, job.ResultObject, job.Done())
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClearDataJobResults forgets the export archives of a user being erased,
+// whose objects the runner has already deleted
+func ClearDataJobResults(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, `
+		UPDATE api.data_jobs SET result_object = NULL, date_updated = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND user_id = $2 AND result_object IS NOT NULL
+	`, tenantID, userID)
+	return err
+}
+
+func scanDataJob(row interface{ Scan(dest ...any) error }) (*DataJob, error) {
+	var job DataJob
+	err := row.Scan(
+		&job.ID,
+		&job.TenantID,
+		&job.Kind,
+		&job.UserID,
+		&job.RequestedBy,
+		&job.Status,
+		&job.Attempts,
+		&job.Error,
+		&job.ResultObject,
+		&job.DateCreated,
+		&job.DateUpdated,
+		&job.DateCompleted,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &job, nil
+}
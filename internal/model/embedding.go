@@ -0,0 +1,130 @@
+// internal/model/embedding.go
+package model
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// UpdateTraceRequest is what the processing pipeline writes back for a
+// trace via PATCH: its vector ID, its embedding and the text it embedded,
+// in any combination
+type UpdateTraceRequest struct {
+	VectorID  *string   `json:"vector_id,omitempty" validate:"omitnil,required,max=100"`
+	Embedding []float32 `json:"embedding,omitempty" validate:"required_without_all=VectorID Excerpt,omitempty,min=1"`
+	Excerpt   *string   `json:"excerpt,omitempty" validate:"omitnil,required,max=2000"`
+}
+
+// SimilarTrace is a trace found by similarity search, with the cosine
+// distance of its embedding from the query's: 0 is identical, 2 opposite
+type SimilarTrace struct {
+	Trace
+	Distance float64 `json:"distance"`
+}
+
+// UpdateTraceEmbedding sets a trace's vector ID, embedding and excerpt,
+// keeping whichever the request leaves out
+func UpdateTraceEmbedding(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID, req UpdateTraceRequest) (*Trace, error) {
+	query := `
+		UPDATE api.traces
+		SET vector_id = COALESCE($4, vector_id), embedding = COALESCE($5::text::vector, embedding), excerpt = COALESCE($6, excerpt),
+		    date_updated = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND course_id = $2 AND id = $3
+		RETURNING id, user_id, instructor_id, status, vector_id, file_name, bucket_url, storage_tier, content_type, size_bytes, visibility, link_url, publish_status, archived_at, date_created, date_updated
+	`
+
+	var embedding *string
+	if req.Embedding != nil {
+		literal := vectorLiteral(req.Embedding)
+		embedding = &literal
+	}
+
+	var trace Trace
+	err := db.QueryRow(ctx, query, tenantID, courseID, traceID, req.VectorID, embedding, req.Excerpt).Scan(
+		&trace.ID,
+		&trace.UserID,
+		&trace.InstructorID,
+		&trace.Status,
+		&trace.VectorID,
+		&trace.FileName,
+		&trace.BucketURL,
+		&trace.StorageTier,
+		&trace.ContentType,
+		&trace.SizeBytes,
+		&trace.Visibility,
+		&trace.LinkURL,
+		&trace.PublishStatus,
+		&trace.ArchivedAt,
+		&trace.DateCreated,
+		&trace.DateUpdated,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &trace, nil
+}
+
+// SimilarTraces returns up to limit of a course's traces nearest to
+// embedding by cosine distance. Traces without an embedding, or with one of
+// a different dimension, are skipped.
+func SimilarTraces(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, embedding []float32, limit int) ([]SimilarTrace, error) {
+	query := `
+		SELECT id, user_id, instructor_id, status, vector_id, file_name, bucket_url, storage_tier, content_type, size_bytes, visibility, link_url, publish_status, archived_at, date_created, date_updated,
+		       embedding <=> $3::text::vector AS distance
+		FROM api.traces
+		WHERE tenant_id = $1 AND course_id = $2 AND embedding IS NOT NULL AND vector_dims(embedding) = $4
+		ORDER BY distance
+		LIMIT $5
+	`
+	rows, err := db.Query(ctx, query, tenantID, courseID, vectorLiteral(embedding), len(embedding), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	traces := []SimilarTrace{}
+	for rows.Next() {
+		var trace SimilarTrace
+		err := rows.Scan(
+			&trace.ID,
+			&trace.UserID,
+			&trace.InstructorID,
+			&trace.Status,
+			&trace.VectorID,
+			&trace.FileName,
+			&trace.BucketURL,
+			&trace.StorageTier,
+			&trace.ContentType,
+			&trace.SizeBytes,
+			&trace.Visibility,
+			&trace.LinkURL,
+			&trace.PublishStatus,
+			&trace.ArchivedAt,
+			&trace.DateCreated,
+			&trace.DateUpdated,
+			&trace.Distance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, trace)
+	}
+	return traces, rows.Err()
+}
+
+// vectorLiteral formats v as pgvector's text input, e.g. [0.1,-2,3e-05]
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -0,0 +1,207 @@
+// internal/model/event.go
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Aggregates the event ledger records events of
+const (
+	AggregateCourse = "course"
+	AggregateTrace  = "trace"
+)
+
+// Event types. Created, uploaded and updated events carry the aggregate as
+// it is afterwards; the others carry what changed.
+const (
+	EventCourseCreated          = "course.created"
+	EventCourseUpdated          = "course.updated"
+	EventCourseArchived         = "course.archived"
+	EventCourseUnarchived       = "course.unarchived"
+	EventCourseDeleted          = "course.deleted"
+	EventTraceUploaded          = "trace.uploaded"
+	EventTraceStatusChanged     = "trace.status_changed"
+	EventTraceFileReplaced      = "trace.file_replaced"
+	EventTraceVisibilityChanged = "trace.visibility_changed"
+	EventTraceDeleted           = "trace.deleted"
+)
+
+// Event is one entry of the append-only ledger of what happened to courses
+// and their traces. Position orders every event of the ledger; Sequence
+// numbers the events of one aggregate from 1. Every event belongs to a
+// course, its own or its trace's, so a course's events read as one stream.
+type Event struct {
+	ID            uuid.UUID `json:"id"`
+	Position      int       `json:"position"`
+	CourseID      uuid.UUID `json:"course_id"`
+	AggregateType string    `json:"aggregate_type"`
+	AggregateID   uuid.UUID `json:"aggregate_id"`
+	Sequence      int       `json:"sequence"`
+	Type          string    `json:"type"`
+	// UserID is who caused the event, nil for the server's own jobs
+	UserID      *uuid.UUID     `json:"user_id"`
+	Data        map[string]any `json:"data"`
+	DateCreated time.Time      `json:"date_created"`
+}
+
+// CourseEvent is an event of course courseID, caused by userID
+func CourseEvent(eventType string, courseID uuid.UUID, userID *uuid.UUID, data map[string]any) Event {
+	return Event{
+		CourseID:      courseID,
+		AggregateType: AggregateCourse,
+		AggregateID:   courseID,
+		Type:          eventType,
+		UserID:        userID,
+		Data:          data,
+	}
+}
+
+// TraceEvent is an event of a trace of courseID, caused by userID
+func TraceEvent(eventType string, courseID, traceID uuid.UUID, userID *uuid.UUID, data map[string]any) Event {
+	return Event{
+		CourseID:      courseID,
+		AggregateType: AggregateTrace,
+		AggregateID:   traceID,
+		Type:          eventType,
+		UserID:        userID,
+		Data:          data,
+	}
+}
+
+// CourseCreatedEvent records course as userID created it
+func CourseCreatedEvent(course *Course, userID uuid.UUID) Event {
+	return CourseEvent(EventCourseCreated, course.ID, &userID, snapshot(course))
+}
+
+// CourseUpdatedEvent records the changes to a course in a version of it
+func CourseUpdatedEvent(course *Course, version CourseVersion) Event {
+	return CourseEvent(EventCourseUpdated, course.ID, &version.UserID, version.Changes)
+}
+
+// CourseArchivedEvent records userID archiving or unarchiving a course, as
+// after has it
+func CourseArchivedEvent(after *Course, userID uuid.UUID) Event {
+	eventType := EventCourseArchived
+	if after.ArchivedAt == nil {
+		eventType = EventCourseUnarchived
+	}
+	return CourseEvent(eventType, after.ID, &userID, map[string]any{"archived_at": after.ArchivedAt})
+}
+
+// TraceUploadedEvent records trace, of courseID, as it was uploaded
+func TraceUploadedEvent(courseID uuid.UUID, trace *Trace) Event {
+	return TraceEvent(EventTraceUploaded, courseID, trace.ID, &trace.UserID, snapshot(trace))
+}
+
+// TraceStatusChangedEvent records a pipeline moving a trace from status
+// from to the status it has now
+func TraceStatusChangedEvent(courseID uuid.UUID, trace *Trace, from string) Event {
+	return TraceEvent(EventTraceStatusChanged, courseID, trace.ID, nil, map[string]any{
+		"status":    FieldChange{Old: from, New: trace.Status},
+		"vector_id": trace.VectorID,
+	})
+}
+
+// TraceFileReplacedEvent records userID replacing the file of a trace, as
+// it is now, keeping the earlier file as previous
+func TraceFileReplacedEvent(courseID uuid.UUID, trace *Trace, previous *TraceRevision, userID uuid.UUID) Event {
+	return TraceEvent(EventTraceFileReplaced, courseID, trace.ID, &userID, map[string]any{
+		"revision":     previous.Revision + 1,
+		"file_name":    FieldChange{Old: previous.FileName, New: trace.FileName},
+		"bucket_url":   FieldChange{Old: previous.BucketURL, New: trace.BucketURL},
+		"content_type": FieldChange{Old: previous.ContentType, New: trace.ContentType},
+		"size_bytes":   FieldChange{Old: previous.SizeBytes, New: trace.SizeBytes},
+	})
+}
+
+// TraceVisibilityChangedEvent records userID changing who may read a trace
+// from visibility from to the visibility it has now
+func TraceVisibilityChangedEvent(courseID uuid.UUID, trace *Trace, from string, userID uuid.UUID) Event {
+	return TraceEvent(EventTraceVisibilityChanged, courseID, trace.ID, &userID, map[string]any{
+		"visibility": FieldChange{Old: from, New: trace.Visibility},
+	})
+}
+
+// snapshot is v as the API shows it, as event data
+func snapshot(v any) map[string]any {
+	data := map[string]any{}
+	if b, err := json.Marshal(v); err == nil {
+		json.Unmarshal(b, &data)
+	}
+	return data
+}
+
+// eventListSpec is the ?sort= and ?fields= allowlist for events
+var eventListSpec = &listSpec[Event]{
+	table: "api.events",
+	columns: map[string]listColumn[Event]{
+		"id":             {"id", kindUUID, true, func(e *Event) any { return &e.ID }},
+		"position":       {"position", kindInt, true, func(e *Event) any { return &e.Position }},
+		"course_id":      {"course_id", kindUUID, false, func(e *Event) any { return &e.CourseID }},
+		"aggregate_type": {"aggregate_type", kindString, false, func(e *Event) any { return &e.AggregateType }},
+		"aggregate_id":   {"aggregate_id", kindUUID, false, func(e *Event) any { return &e.AggregateID }},
+		"sequence":       {"sequence", kindInt, false, func(e *Event) any { return &e.Sequence }},
+		"type":           {"type", kindString, false, func(e *Event) any { return &e.Type }},
+		"user_id":        {"user_id", kindUUID, false, func(e *Event) any { return &e.UserID }},
+		"data":           {"data", kindString, false, func(e *Event) any { return &e.Data }},
+		"date_created":   {"date_created", kindTime, true, func(e *Event) any { return &e.DateCreated }},
+	},
+	defaultSort: []SortField{{Field: "position"}},
+}
+
+// AppendEvents adds events to the ledger of one of the tenant's courses'
+// aggregates, in one statement, each numbered after the last event of its
+// aggregate. The caller holds the row lock of every aggregate that already
+// has events, and passes at most one event per aggregate.
+func AppendEvents(ctx context.Context, db DBTX, tenantID uuid.UUID, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	var (
+		courseIDs      = make([]uuid.UUID, len(events))
+		aggregateTypes = make([]string, len(events))
+		aggregateIDs   = make([]uuid.UUID, len(events))
+		types          = make([]string, len(events))
+		userIDs        = make([]*uuid.UUID, len(events))
+		data           = make([]string, len(events))
+	)
+	for i, e := range events {
+		courseIDs[i], aggregateTypes[i], aggregateIDs[i], types[i], userIDs[i] = e.CourseID, e.AggregateType, e.AggregateID, e.Type, e.UserID
+		if e.Data == nil {
+			e.Data = map[string]any{}
+		}
+		b, err := json.Marshal(e.Data)
+		if err != nil {
+			return err
+		}
+		data[i] = string(b)
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO api.events (tenant_id, course_id, aggregate_type, aggregate_id, sequence, type, user_id, data)
+		SELECT $1, e.course_id, e.aggregate_type, e.aggregate_id,
+			COALESCE((SELECT MAX(sequence) FROM api.events WHERE aggregate_id = e.aggregate_id), 0) + 1,
+			e.type, e.user_id, e.data::jsonb
+		FROM unnest($2::uuid[], $3::text[], $4::uuid[], $5::text[], $6::uuid[], $7::text[])
+			WITH ORDINALITY AS e(course_id, aggregate_type, aggregate_id, type, user_id, data, n)
+		ORDER BY e.n`,
+		tenantID, courseIDs, aggregateTypes, aggregateIDs, types, userIDs, data)
+	return err
+}
+
+// ListCourseEvents returns one page of the events of a course and its
+// traces, oldest first unless opts.Sort says otherwise. The caller checks
+// the course exists.
+func ListCourseEvents(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, opts ListOptions) (*Page[Event], error) {
+	return list(ctx, db, eventListSpec, "tenant_id = $1 AND course_id = $2", []any{tenantID, courseID}, opts)
+}
+
+// PaginateEvents pages through events held in memory like ListCourseEvents
+// does
+func PaginateEvents(events []Event, opts ListOptions) (*Page[Event], error) {
+	return paginate(eventListSpec, events, opts)
+}
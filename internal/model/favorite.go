@@ -0,0 +1,71 @@
+// internal/model/favorite.go
+package model
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// AddFavorite saves one of the tenant's courses to the user's shortlist and
+// returns it, with added false if it was already there. It returns
+// ErrNotFound for an unknown course.
+func AddFavorite(ctx context.Context, db DBTX, tenantID, userID, courseID uuid.UUID) (*Course, bool, error) {
+	course, err := GetCourseByID(ctx, db, tenantID, courseID)
+	if err != nil {
+		return nil, false, err
+	}
+	result, err := db.Exec(ctx, `
+		INSERT INTO api.favorites (user_id, course_id, tenant_id)
+		VALUES ($2, $3, $1)
+		ON CONFLICT (user_id, course_id) DO NOTHING`, tenantID, userID, courseID)
+	if err != nil {
+		return nil, false, err
+	}
+	return course, result.RowsAffected() == 1, nil
+}
+
+// RemoveFavorite takes a course off the user's shortlist. It returns
+// ErrNotFound if the course wasn't on it.
+func RemoveFavorite(ctx context.Context, db DBTX, tenantID, userID, courseID uuid.UUID) error {
+	result, err := db.Exec(ctx, "DELETE FROM api.favorites WHERE tenant_id = $1 AND user_id = $2 AND course_id = $3", tenantID, userID, courseID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListFavorites returns one page of the courses on the user's shortlist,
+// sorted like ListCourses
+func ListFavorites(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, opts ListOptions) (*Page[Course], error) {
+	where := "tenant_id = $1 AND id IN (SELECT course_id FROM api.favorites WHERE tenant_id = $1 AND user_id = $2)"
+	return list(ctx, db, courseListSpec, where, []any{tenantID, userID}, opts)
+}
+
+// GetFavoriteCourseIDs returns the IDs of the courses on the user's shortlist
+func GetFavoriteCourseIDs(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := db.Query(ctx, "SELECT course_id FROM api.favorites WHERE tenant_id = $1 AND user_id = $2 ORDER BY date_created", tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteFavorites empties the user's shortlist
+func DeleteFavorites(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, "DELETE FROM api.favorites WHERE tenant_id = $1 AND user_id = $2", tenantID, userID)
+	return err
+}
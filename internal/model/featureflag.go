@@ -0,0 +1,70 @@
+// internal/model/featureflag.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FeatureFlagOverride is a flag value set at runtime, taking precedence over config
+type FeatureFlagOverride struct {
+	Name        string     `json:"name"`
+	Enabled     bool       `json:"enabled"`
+	UpdatedBy   *uuid.UUID `json:"updated_by"`
+	DateUpdated time.Time  `json:"date_updated"`
+}
+
+func ListFeatureFlagOverrides(ctx context.Context, db DBTX) ([]FeatureFlagOverride, error) {
+	query := `
+		SELECT name, enabled, updated_by, date_updated
+		FROM api.feature_flags
+		ORDER BY name
+	`
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []FeatureFlagOverride
+	for rows.Next() {
+		var o FeatureFlagOverride
+		if err := rows.Scan(&o.Name, &o.Enabled, &o.UpdatedBy, &o.DateUpdated); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+// SetFeatureFlagOverride creates or replaces the override for name
+func SetFeatureFlagOverride(ctx context.Context, db DBTX, name string, enabled bool, userID uuid.UUID) (*FeatureFlagOverride, error) {
+	query := `
+		INSERT INTO api.feature_flags (name, enabled, updated_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+		SET enabled = EXCLUDED.enabled, updated_by = EXCLUDED.updated_by, date_updated = CURRENT_TIMESTAMP
+		RETURNING name, enabled, updated_by, date_updated
+	`
+
+	var o FeatureFlagOverride
+	err := db.QueryRow(ctx, query, name, enabled, userID).Scan(&o.Name, &o.Enabled, &o.UpdatedBy, &o.DateUpdated)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func DeleteFeatureFlagOverride(ctx context.Context, db DBTX, name string) error {
+	result, err := db.Exec(ctx, `DELETE FROM api.feature_flags WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -2,7 +2,7 @@
 package model
 
 import (
-	"database/sql"
+	"context"
 	"time"
 )
 
@@ -11,9 +11,9 @@ type HealthCheck struct {
 	DateTime time.Time `json:"datetime"`
 }
 
-func InsertHealthCheck(db *sql.DB) error {
+func InsertHealthCheck(ctx context.Context, db DBTX) error {
 	// PostgreSQL uses CURRENT_TIMESTAMP instead of UTC_TIMESTAMP()
 	query := "INSERT INTO api.health_check (datetime) VALUES (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')"
-	_, err := db.Exec(query)
+	_, err := db.Exec(ctx, query)
 	return err
 }
@@ -0,0 +1,200 @@
+// internal/model/identity.go
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExternalIdentity is an account at an identity provider, as vouched for by
+// the provider's token
+type ExternalIdentity struct {
+	Provider string
+	// Subject is the provider's stable ID for the account
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	// Username is the provider's login name, if it has one
+	Username string
+}
+
+// usernameUnsafe matches characters left out of provisioned usernames
+var usernameUnsafe = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// maxUsernameLength is the width of api.users.username
+const maxUsernameLength = 30
+
+// UsernameBase is the username a provisioned user is given before any
+// numeric suffix: the provider's login name or else the email's local part,
+// lower-cased and stripped to letters, digits, dots, dashes and underscores
+func (id ExternalIdentity) UsernameBase() string {
+	base := id.Username
+	if base == "" {
+		base, _, _ = strings.Cut(id.Email, "@")
+	}
+	base = usernameUnsafe.ReplaceAllString(strings.ToLower(base), "")
+	if base == "" {
+		base = "user"
+	}
+	return truncate(base, maxUsernameLength)
+}
+
+// UsernameCandidate is base with the nth numeric suffix, trimmed to fit;
+// the zeroth candidate is base itself
+func UsernameCandidate(base string, n int) string {
+	if n == 0 {
+		return base
+	}
+	suffix := fmt.Sprint(n + 1)
+	return truncate(base, maxUsernameLength-len(suffix)) + suffix
+}
+
+// ProvisionRequest is the new user created for an identity on its first
+// login. The password is random and never shown, so the user can only log in
+// through the provider until they set one.
+func (id ExternalIdentity) ProvisionRequest(username, role string) (CreateUserRequest, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return CreateUserRequest{}, err
+	}
+	firstName := id.FirstName
+	if firstName == "" {
+		firstName = username
+	}
+	return CreateUserRequest{
+		FirstName: truncate(firstName, 50),
+		LastName:  truncate(id.LastName, 50),
+		Username:  username,
+		Password:  hex.EncodeToString(secret),
+		Role:      role,
+		Email:     id.Email,
+	}, nil
+}
+
+// truncate cuts s to n characters, which is how VARCHAR widths count
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// GetUserByIdentity returns the user linked to the provider account and
+// records the login. ErrNotFound means the account is not linked yet.
+func GetUserByIdentity(ctx context.Context, db DBTX, tenantID uuid.UUID, provider, subject string) (*User, error) {
+	var userID uuid.UUID
+	err := db.QueryRow(ctx, `
+		UPDATE api.user_identities SET last_login = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND provider = $2 AND subject = $3
+		RETURNING user_id
+	`, tenantID, provider, subject).Scan(&userID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return GetUserByID(ctx, db, tenantID, userID)
+}
+
+func GetUserByEmail(ctx context.Context, db DBTX, tenantID uuid.UUID, email string) (*User, error) {
+	var user User
+	query := `
+        SELECT id, first_name, last_name, username, role, email, account_created, account_updated, deactivated_at, password_reset_required
+        FROM api.users
+        WHERE tenant_id = $1 AND lower(email) = lower($2)
+    `
+	err := db.QueryRow(ctx, query, tenantID, email).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Username,
+		&user.Role,
+		&user.Email,
+		&user.AccountCreated,
+		&user.AccountUpdated,
+		&user.DeactivatedAt,
+		&user.PasswordResetRequired,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+// LinkIdentity links the provider account to the user
+func LinkIdentity(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, identity ExternalIdentity) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO api.user_identities (tenant_id, user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+	`, tenantID, userID, identity.Provider, identity.Subject, identity.Email)
+	return err
+}
+
+// FreeUsername returns the first candidate for base that no user of the
+// tenant has taken
+func FreeUsername(ctx context.Context, db DBTX, tenantID uuid.UUID, base string) (string, error) {
+	// Suffixed candidates may cut the end off a long base
+	prefix := truncate(base, maxUsernameLength-4)
+	rows, err := db.Query(ctx, "SELECT username FROM api.users WHERE tenant_id = $1 AND username LIKE $2", tenantID, likePrefix(prefix)+"%")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	taken := map[string]bool{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return "", err
+		}
+		taken[username] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	for n := 0; ; n++ {
+		if candidate := UsernameCandidate(base, n); !taken[candidate] {
+			return candidate, nil
+		}
+	}
+}
+
+// likePrefix escapes LIKE wildcards in s
+func likePrefix(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// OIDCLoginRequest exchanges a provider's token for one of the API's: an ID
+// token for google and sso, an OAuth access token for github
+type OIDCLoginRequest struct {
+	Provider string `json:"provider" validate:"required,max=30"`
+	Token    string `json:"token" validate:"required"`
+}
+
+// AuthToken is a bearer token issued by a login endpoint. For an admin
+// with MFA it is instead a challenge: MFARequired is set and MFAToken must
+// be redeemed with a TOTP code at POST /auth/mfa/verify before ExpiresAt.
+type AuthToken struct {
+	AccessToken string    `json:"access_token,omitempty"`
+	TokenType   string    `json:"token_type,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	User        *User     `json:"user,omitempty"`
+	MFARequired bool      `json:"mfa_required,omitempty"`
+	MFAToken    string    `json:"mfa_token,omitempty"`
+	// ImpersonatedBy names the admin an impersonation token was issued to
+	ImpersonatedBy *uuid.UUID `json:"impersonated_by,omitempty"`
+}
+
+// DeleteUserIdentities unlinks every provider account from the user
+func DeleteUserIdentities(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, "DELETE FROM api.user_identities WHERE tenant_id = $1 AND user_id = $2", tenantID, userID)
+	return err
+}
@@ -2,10 +2,8 @@
 package model
 
 import (
-	"database/sql"
-	"errors"
+	"context"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
@@ -22,45 +20,30 @@ type Instructor struct {
 }
 
 type CreateInstructorRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name  string `json:"name" validate:"required,max=100"`
+	Email string `json:"email" validate:"required,max=100,email_format"`
 }
 
 type UpdateInstructorRequest struct {
-	Name  *string `json:"name,omitempty"`
-	Email *string `json:"email,omitempty"`
+	Name  *string `json:"name,omitempty" validate:"omitnil,required,max=100"`
+	Email *string `json:"email,omitempty" validate:"omitnil,required,max=100,email_format"`
 }
 
-func (r *CreateInstructorRequest) Validate() error {
-	if r.Name == "" {
-		return errors.New("name is required")
-	}
-	if r.Email == "" {
-		return errors.New("email is required")
-	}
-
-	// Email format validation using regex
-	emailRegex := regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`)
-	if !emailRegex.MatchString(r.Email) {
-		return errors.New("invalid email format")
-	}
-
-	return nil
-}
-
-func CreateInstructor(db *sql.DB, req CreateInstructorRequest, userID uuid.UUID) (*Instructor, error) {
+func CreateInstructor(ctx context.Context, db DBTX, tenantID uuid.UUID, req CreateInstructorRequest, userID uuid.UUID) (*Instructor, error) {
 	var instructor Instructor
 	query := `
-	INSERT INTO api.instructors (user_id, name, email)
-	VALUES ($1, $2, $3)
+	INSERT INTO api.instructors (user_id, name, email, tenant_id)
+	VALUES ($1, $2, $3, $4)
 	RETURNING id, user_id, name, email, date_added, date_updated
 	`
 
 	err := db.QueryRow(
+		ctx,
 		query,
 		userID,
 		req.Name,
 		req.Email,
+		tenantID,
 	).Scan(
 		&instructor.ID,
 		&instructor.UserID,
@@ -77,16 +60,70 @@ func CreateInstructor(db *sql.DB, req CreateInstructorRequest, userID uuid.UUID)
 	return &instructor, nil
 }
 
-func GetInstructorByID(db *sql.DB, instructorID uuid.UUID) (*Instructor, error) {
+// CreateInstructors inserts instructors in one statement and returns them in
+// the order of reqs, for imports writing many at once
+func CreateInstructors(ctx context.Context, db DBTX, tenantID uuid.UUID, reqs []CreateInstructorRequest, userID uuid.UUID) ([]Instructor, error) {
+	if len(reqs) == 0 {
+		return []Instructor{}, nil
+	}
+	// IDs are generated here, since RETURNING doesn't promise the input order
+	ids := make([]uuid.UUID, len(reqs))
+	names := make([]string, len(reqs))
+	emails := make([]string, len(reqs))
+	for i, req := range reqs {
+		ids[i] = uuid.New()
+		names[i] = req.Name
+		emails[i] = req.Email
+	}
+
+	rows, err := db.Query(ctx, `
+	INSERT INTO api.instructors (id, user_id, name, email, tenant_id)
+	SELECT i.id, $1, i.name, i.email, $2
+	FROM unnest($3::uuid[], $4::text[], $5::text[]) AS i(id, name, email)
+	RETURNING id, user_id, name, email, date_added, date_updated
+	`, userID, tenantID, ids, names, emails)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	created := make(map[uuid.UUID]Instructor, len(reqs))
+	for rows.Next() {
+		var instructor Instructor
+		err := rows.Scan(
+			&instructor.ID,
+			&instructor.UserID,
+			&instructor.Name,
+			&instructor.Email,
+			&instructor.DateAdded,
+			&instructor.DateUpdated,
+		)
+		if err != nil {
+			return nil, err
+		}
+		created[instructor.ID] = instructor
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	instructors := make([]Instructor, len(ids))
+	for i, id := range ids {
+		instructors[i] = created[id]
+	}
+	return instructors, nil
+}
+
+func GetInstructorByID(ctx context.Context, db DBTX, tenantID, instructorID uuid.UUID) (*Instructor, error) {
 	var instructor Instructor
 
 	query := `
 	SELECT id, user_id, name, email, date_added, date_updated
 	FROM api.instructors
-	WHERE id = $1
+	WHERE id = $1 AND tenant_id = $2
 	`
 
-	err := db.QueryRow(query, instructorID).Scan(
+	err := db.QueryRow(ctx, query, instructorID, tenantID).Scan(
 		&instructor.ID,
 		&instructor.UserID,
 		&instructor.Name,
@@ -96,47 +133,91 @@ func GetInstructorByID(db *sql.DB, instructorID uuid.UUID) (*Instructor, error)
 	)
 
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 
 	return &instructor, nil
 }
 
-func DeleteInstructorByID(db *sql.DB, instructorID uuid.UUID) error {
+// GetInstructorByEmail finds an instructor by email, ignoring case. Emails
+// differing only in case can both exist, in which case the oldest wins.
+func GetInstructorByEmail(ctx context.Context, db DBTX, tenantID uuid.UUID, email string) (*Instructor, error) {
+	var instructor Instructor
+
 	query := `
-	DELETE FROM api.instructors
-	WHERE id = $1
+	SELECT id, user_id, name, email, date_added, date_updated
+	FROM api.instructors
+	WHERE tenant_id = $1 AND LOWER(email) = LOWER($2)
+	ORDER BY date_added, id
+	LIMIT 1
 	`
 
-	result, err := db.Exec(query, instructorID)
+	err := db.QueryRow(ctx, query, tenantID, email).Scan(
+		&instructor.ID,
+		&instructor.UserID,
+		&instructor.Name,
+		&instructor.Email,
+		&instructor.DateAdded,
+		&instructor.DateUpdated,
+	)
+
 	if err != nil {
-		return err
+		return nil, notFound(err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return &instructor, nil
+}
+
+// instructorListSpec is the ?sort= and ?fields= allowlist for instructor listings
+var instructorListSpec = &listSpec[Instructor]{
+	table: "api.instructors",
+	columns: map[string]listColumn[Instructor]{
+		"id":           {"id", kindUUID, true, func(i *Instructor) any { return &i.ID }},
+		"user_id":      {"user_id", kindUUID, false, func(i *Instructor) any { return &i.UserID }},
+		"name":         {"name", kindString, true, func(i *Instructor) any { return &i.Name }},
+		"email":        {"email", kindString, true, func(i *Instructor) any { return &i.Email }},
+		"date_added":   {"date_added", kindTime, true, func(i *Instructor) any { return &i.DateAdded }},
+		"date_updated": {"date_updated", kindTime, true, func(i *Instructor) any { return &i.DateUpdated }},
+	},
+	aliases:     map[string]string{"created_at": "date_added", "updated_at": "date_updated"},
+	defaultSort: []SortField{{Field: "date_added", Desc: true}},
+}
+
+// ListInstructors returns one page of instructors, most recently added first unless opts.Sort says otherwise
+func ListInstructors(ctx context.Context, db DBTX, tenantID uuid.UUID, opts ListOptions) (*Page[Instructor], error) {
+	return list(ctx, db, instructorListSpec, "tenant_id = $1", []any{tenantID}, opts)
+}
+
+func DeleteInstructorByID(ctx context.Context, db DBTX, tenantID, instructorID uuid.UUID) error {
+	query := `
+	DELETE FROM api.instructors
+	WHERE id = $1 AND tenant_id = $2
+	`
+
+	result, err := db.Exec(ctx, query, instructorID, tenantID)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-func UpdateInstructor(db *sql.DB, instructorID uuid.UUID, req UpdateInstructorRequest) (*Instructor, error) {
+func UpdateInstructor(ctx context.Context, db DBTX, tenantID, instructorID uuid.UUID, req UpdateInstructorRequest) (*Instructor, error) {
 	// Start a transaction
-	tx, err := db.Begin()
+	tx, err := db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback()
+	defer tx.Rollback(ctx)
 
 	// Build the update query dynamically based on which fields are provided
 	query := "UPDATE api.instructors SET"
-	args := []interface{}{instructorID}
-	argIndex := 2 // Start at 2 because instructorID is $1
+	args := []interface{}{instructorID, tenantID}
+	argIndex := 3 // Start at 3 because instructorID is $1 and tenantID is $2
 
 	// Track if we need to add fields
 	var updates []string
@@ -158,16 +239,16 @@ func UpdateInstructor(db *sql.DB, instructorID uuid.UUID, req UpdateInstructorRe
 
 	// If no fields to update, return the current instructor
 	if len(updates) == 1 { // Only timestamp update
-		return GetInstructorByID(db, instructorID)
+		return GetInstructorByID(ctx, db, tenantID, instructorID)
 	}
 
 	// Complete the query
 	query += strings.Join(updates, ",")
-	query += " WHERE id = $1 RETURNING id, user_id, name, email, date_added, date_updated"
+	query += " WHERE id = $1 AND tenant_id = $2 RETURNING id, user_id, name, email, date_added, date_updated"
 
 	// Execute the update
 	var instructor Instructor
-	err = tx.QueryRow(query, args...).Scan(
+	err = tx.QueryRow(ctx, query, args...).Scan(
 		&instructor.ID,
 		&instructor.UserID,
 		&instructor.Name,
@@ -181,7 +262,7 @@ func UpdateInstructor(db *sql.DB, instructorID uuid.UUID, req UpdateInstructorRe
 	}
 
 	// Commit the transaction
-	if err = tx.Commit(); err != nil {
+	if err = tx.Commit(ctx); err != nil {
 		return nil, err
 	}
 
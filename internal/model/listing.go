@@ -0,0 +1,486 @@
+// internal/model/listing.go
+package model
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidListOptions is wrapped by errors for unknown sort or field names
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// SortField is one key of a ?sort= parameter; "-name" sorts name descending
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions controls paging, ordering, and column selection of list queries
+type ListOptions struct {
+	Page   PageRequest
+	Sort   []SortField
+	Fields []string
+}
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindInt
+	kindTime
+	kindUUID
+)
+
+// listColumn maps an API field onto its SQL column and the struct field it scans into
+type listColumn[T any] struct {
+	column   string
+	kind     columnKind
+	sortable bool
+	ptr      func(*T) any
+}
+
+// listSpec is the allowlist of fields a resource exposes to ?sort= and ?fields=
+type listSpec[T any] struct {
+	table       string
+	columns     map[string]listColumn[T]
+	aliases     map[string]string
+	defaultSort []SortField
+}
+
+func (s *listSpec[T]) lookup(field string) (string, listColumn[T], bool) {
+	if alias, ok := s.aliases[field]; ok {
+		field = alias
+	}
+	col, ok := s.columns[field]
+	return field, col, ok
+}
+
+// resolveSort validates the requested sort and appends id as a tiebreaker so
+// the ordering is total, which keyset pagination relies on.
+func (s *listSpec[T]) resolveSort(requested []SortField) ([]SortField, error) {
+	if len(requested) == 0 {
+		requested = s.defaultSort
+	}
+
+	var sort []SortField
+	seen := map[string]bool{}
+	for _, f := range requested {
+		name, col, ok := s.lookup(f.Field)
+		if !ok || !col.sortable {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidListOptions, f.Field)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		sort = append(sort, SortField{Field: name, Desc: f.Desc})
+	}
+	if !seen["id"] {
+		sort = append(sort, SortField{Field: "id", Desc: sort[len(sort)-1].Desc})
+	}
+	return sort, nil
+}
+
+// resolveFields validates the requested fields and adds the sort keys, which
+// are needed to build the next cursor.
+func (s *listSpec[T]) resolveFields(requested []string, sort []SortField) ([]string, error) {
+	var fields []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			fields = append(fields, name)
+		}
+	}
+
+	if len(requested) == 0 {
+		for name := range s.columns {
+			add(name)
+		}
+	}
+	for _, f := range requested {
+		// Aliases only apply to ?sort=; fields must match the JSON names
+		if _, ok := s.columns[f]; !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidListOptions, f)
+		}
+		add(f)
+	}
+	for _, f := range sort {
+		add(f.Field)
+	}
+	return fields, nil
+}
+
+func sortSignature(sort []SortField) string {
+	parts := make([]string, len(sort))
+	for i, f := range sort {
+		if f.Desc {
+			parts[i] = "-" + f.Field
+		} else {
+			parts[i] = f.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// list runs a keyset-paginated SELECT over spec.table. where is an optional
+// filter using placeholders $1..$len(whereArgs).
+func list[T any](ctx context.Context, db DBTX, spec *listSpec[T], where string, whereArgs []any, opts ListOptions) (*Page[T], error) {
+	sort, err := spec.resolveSort(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := spec.resolveFields(opts.Fields, sort)
+	if err != nil {
+		return nil, err
+	}
+	signature := sortSignature(sort)
+
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = spec.columns[f].column
+	}
+
+	var conditions []string
+	args := append([]any{}, whereArgs...)
+	if where != "" {
+		conditions = append(conditions, where)
+	}
+
+	if after := opts.Page.After; after != nil {
+		if after.Sort != signature || len(after.Values) != len(sort) {
+			return nil, ErrInvalidCursor
+		}
+
+		// (k1 op v1) OR (k1 = v1 AND k2 op v2) OR ...
+		var clauses []string
+		for i, f := range sort {
+			var parts []string
+			for j := 0; j <= i; j++ {
+				col := spec.columns[sort[j].Field]
+				value, err := parseCursorValue(col.kind, after.Values[j])
+				if err != nil {
+					return nil, ErrInvalidCursor
+				}
+				args = append(args, value)
+
+				op := "="
+				if j == i {
+					op = ">"
+					if f.Desc {
+						op = "<"
+					}
+				}
+				parts = append(parts, fmt.Sprintf("%s %s $%d", col.column, op, len(args)))
+			}
+			clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+		}
+		conditions = append(conditions, "("+strings.Join(clauses, " OR ")+")")
+	}
+
+	limit := opts.Page.limit()
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM " + spec.table
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", spec.orderBy(sort), limit+1)
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		var item T
+		targets := make([]any, len(fields))
+		for i, f := range fields {
+			targets[i] = spec.columns[f].ptr(&item)
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &Page[T]{Data: items}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if len(items) > limit {
+		page.Data = items[:limit]
+		page.HasMore = true
+
+		last := &page.Data[limit-1]
+		cursor := Cursor{Sort: signature}
+		for _, f := range sort {
+			cursor.Values = append(cursor.Values, formatCursorValue(spec.columns[f.Field].ptr(last)))
+		}
+		next := EncodeCursor(cursor)
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+// each runs the SELECT of list without a cursor or limit, calling fn with
+// every row as it is read, so a whole collection can be streamed without
+// holding it in memory. An error from fn stops the query and is returned.
+func each[T any](ctx context.Context, db DBTX, spec *listSpec[T], where string, whereArgs []any, opts ListOptions, fn func(T) error) error {
+	sort, err := spec.resolveSort(opts.Sort)
+	if err != nil {
+		return err
+	}
+	fields, err := spec.resolveFields(opts.Fields, sort)
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = spec.columns[f].column
+	}
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM " + spec.table
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY " + spec.orderBy(sort)
+
+	rows, err := db.Query(ctx, query, whereArgs...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item T
+		targets := make([]any, len(fields))
+		for i, f := range fields {
+			targets[i] = spec.columns[f].ptr(&item)
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// orderBy is the ORDER BY list of a resolved sort
+func (s *listSpec[T]) orderBy(sort []SortField) string {
+	orderBy := make([]string, len(sort))
+	for i, f := range sort {
+		orderBy[i] = s.columns[f.Field].column
+		if f.Desc {
+			orderBy[i] += " DESC"
+		}
+	}
+	return strings.Join(orderBy, ", ")
+}
+
+func formatCursorValue(ptr any) string {
+	switch v := ptr.(type) {
+	case *string:
+		return *v
+	case *int:
+		return strconv.Itoa(*v)
+	case *time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case *uuid.UUID:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func parseCursorValue(kind columnKind, s string) (any, error) {
+	switch kind {
+	case kindInt:
+		return strconv.Atoi(s)
+	case kindTime:
+		return time.Parse(time.RFC3339Nano, s)
+	case kindUUID:
+		return uuid.Parse(s)
+	default:
+		return s, nil
+	}
+}
+
+// paginate applies the sort, cursor and limit semantics of list to rows that
+// are already in memory, so backends without SQL page identically. Strings
+// compare bytewise rather than by database collation.
+func paginate[T any](spec *listSpec[T], items []T, opts ListOptions) (*Page[T], error) {
+	sort, err := spec.resolveSort(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spec.resolveFields(opts.Fields, sort); err != nil {
+		return nil, err
+	}
+	signature := sortSignature(sort)
+	compareRows := spec.compareRows(sort)
+
+	var after []any
+	if cursor := opts.Page.After; cursor != nil {
+		if cursor.Sort != signature || len(cursor.Values) != len(sort) {
+			return nil, ErrInvalidCursor
+		}
+		for i, f := range sort {
+			value, err := parseCursorValue(spec.columns[f.Field].kind, cursor.Values[i])
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			after = append(after, value)
+		}
+	}
+
+	var rows []T
+	for i := range items {
+		if after != nil && !pastCursor(spec, sort, &items[i], after) {
+			continue
+		}
+		rows = append(rows, items[i])
+	}
+	slices.SortFunc(rows, func(a, b T) int { return compareRows(&a, &b) })
+
+	limit := opts.Page.limit()
+	page := &Page[T]{Data: rows}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if len(rows) > limit {
+		page.Data = rows[:limit]
+		page.HasMore = true
+
+		last := &page.Data[limit-1]
+		cursor := Cursor{Sort: signature}
+		for _, f := range sort {
+			cursor.Values = append(cursor.Values, formatCursorValue(spec.columns[f.Field].ptr(last)))
+		}
+		next := EncodeCursor(cursor)
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+// sortAll applies the sort of each to rows that are already in memory,
+// checking opts as paginate does but with no cursor or limit
+func sortAll[T any](spec *listSpec[T], items []T, opts ListOptions) ([]T, error) {
+	sort, err := spec.resolveSort(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spec.resolveFields(opts.Fields, sort); err != nil {
+		return nil, err
+	}
+	compareRows := spec.compareRows(sort)
+	slices.SortFunc(items, func(a, b T) int { return compareRows(&a, &b) })
+	return items, nil
+}
+
+// compareRows orders two rows by a resolved sort
+func (s *listSpec[T]) compareRows(sort []SortField) func(a, b *T) int {
+	return func(a, b *T) int {
+		for _, f := range sort {
+			col := s.columns[f.Field]
+			c := compareValues(derefValue(col.ptr(a)), derefValue(col.ptr(b)))
+			if f.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
+
+// pastCursor reports whether item sorts strictly after the cursor values
+func pastCursor[T any](spec *listSpec[T], sort []SortField, item *T, after []any) bool {
+	for i, f := range sort {
+		c := compareValues(derefValue(spec.columns[f.Field].ptr(item)), after[i])
+		if f.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c > 0
+		}
+	}
+	return false
+}
+
+func derefValue(ptr any) any {
+	switch v := ptr.(type) {
+	case *string:
+		return *v
+	case *int:
+		return *v
+	case *time.Time:
+		return *v
+	case *uuid.UUID:
+		return *v
+	default:
+		return v
+	}
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int:
+		return cmp.Compare(a, b.(int))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case uuid.UUID:
+		other := b.(uuid.UUID)
+		return bytes.Compare(a[:], other[:])
+	default:
+		return 0
+	}
+}
+
+// PaginateCourses pages through courses held in memory like ListCourses does
+func PaginateCourses(courses []Course, opts ListOptions) (*Page[Course], error) {
+	return paginate(courseListSpec, courses, opts)
+}
+
+// PaginateTraces pages through traces held in memory like GetTracesByCourseID does
+func PaginateTraces(traces []Trace, opts ListOptions) (*Page[Trace], error) {
+	return paginate(traceListSpec, traces, opts)
+}
+
+// SortCourses sorts courses held in memory like EachCourse does
+func SortCourses(courses []Course, opts ListOptions) ([]Course, error) {
+	return sortAll(courseListSpec, courses, opts)
+}
+
+// SortTraces sorts traces held in memory like EachTraceByCourseID does
+func SortTraces(traces []Trace, opts ListOptions) ([]Trace, error) {
+	return sortAll(traceListSpec, traces, opts)
+}
+
+// PaginateUsers pages through users held in memory like ListUsers does
+func PaginateUsers(users []User, opts ListOptions) (*Page[User], error) {
+	return paginate(userListSpec, users, opts)
+}
+
+// PaginateInstructors pages through instructors held in memory like ListInstructors does
+func PaginateInstructors(instructors []Instructor, opts ListOptions) (*Page[Instructor], error) {
+	return paginate(instructorListSpec, instructors, opts)
+}
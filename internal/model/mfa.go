@@ -0,0 +1,231 @@
+// internal/model/mfa.go
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrMFAEnabled is returned when enrolling a user whose MFA is already on
+	ErrMFAEnabled = errors.New("MFA is already enabled")
+	// ErrMFACodeUsed is returned for a TOTP code, or a recovery code, that
+	// has already been used
+	ErrMFACodeUsed = errors.New("MFA code already used")
+	// ErrMFAChallengeSpent is returned for a login challenge that has taken
+	// as many codes as it allows
+	ErrMFAChallengeSpent = errors.New("MFA challenge has no attempts left")
+)
+
+// MFALockedError is returned while a user who failed too many MFA codes in
+// a row is locked out
+type MFALockedError struct {
+	Until time.Time
+}
+
+func (e *MFALockedError) Error() string {
+	return fmt.Sprintf("MFA locked until %s", e.Until.Format(time.RFC3339))
+}
+
+// MFALimits bound how many MFA codes are tried. A login challenge takes
+// ChallengeAttempts codes. FailedAttempts wrong codes in a row lock the user
+// out for Lockout, and each further wrong one locks them out again, until a
+// code is accepted.
+type MFALimits struct {
+	ChallengeAttempts int
+	FailedAttempts    int
+	Lockout           time.Duration
+}
+
+// MFA is a user's TOTP enrollment. It is pending until the user confirms
+// a code, and only enforced once enabled.
+type MFA struct {
+	UserID  uuid.UUID `json:"-"`
+	Secret  string    `json:"-"`
+	Enabled bool      `json:"enabled"`
+	// RecoveryCodes are the SHA-256 hashes of the unused recovery codes
+	RecoveryCodes          []string   `json:"-"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+	LastUsedStep           int64      `json:"-"`
+	FailedAttempts         int        `json:"-"`
+	LockedUntil            *time.Time `json:"-"`
+	DateCreated            time.Time  `json:"date_created"`
+	DateEnabled            *time.Time `json:"date_enabled"`
+}
+
+// MFAEnrollment is returned once, when enrollment starts: the secret for
+// the authenticator app and the recovery codes in the clear
+type MFAEnrollment struct {
+	Secret          string   `json:"secret"`
+	ProvisioningURI string   `json:"provisioning_uri"`
+	RecoveryCodes   []string `json:"recovery_codes"`
+}
+
+// MFACodeRequest carries a TOTP code or a recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// MFAVerifyRequest completes a login that returned an MFA challenge
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// PasswordLoginRequest exchanges a username and password for a bearer token
+type PasswordLoginRequest struct {
+	Username string `json:"username" validate:"required,max=30"`
+	Password string `json:"password" validate:"required"`
+}
+
+const mfaColumns = "user_id, secret, enabled, recovery_codes, last_used_step, failed_attempts, locked_until, date_created, date_enabled"
+
+func GetMFA(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) (*MFA, error) {
+	return scanMFA(db.QueryRow(ctx, "SELECT "+mfaColumns+" FROM api.user_mfa WHERE tenant_id = $1 AND user_id = $2", tenantID, userID))
+}
+
+// SaveMFAEnrollment starts or restarts a pending enrollment, replacing the
+// secret and recovery codes. ErrMFAEnabled means MFA is already on.
+func SaveMFAEnrollment(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, secret string, recoveryCodes []string) (*MFA, error) {
+	mfa, err := scanMFA(db.QueryRow(ctx, `
+		INSERT INTO api.user_mfa (user_id, tenant_id, secret, recovery_codes)
+		SELECT id, tenant_id, $3, $4 FROM api.users WHERE id = $2 AND tenant_id = $1
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, recovery_codes = EXCLUDED.recovery_codes,
+		    last_used_step = 0, date_created = CURRENT_TIMESTAMP
+		WHERE NOT api.user_mfa.enabled
+		RETURNING `+mfaColumns, tenantID, userID, secret, recoveryCodes))
+	if errors.Is(err, ErrNotFound) {
+		// Either the user is gone or the conflicting row is enabled
+		if _, getErr := GetMFA(ctx, db, tenantID, userID); getErr == nil {
+			return nil, ErrMFAEnabled
+		}
+	}
+	return mfa, err
+}
+
+// UseMFAStep records step as used, enabling a pending enrollment.
+// ErrMFACodeUsed means a code from this or a later step was already accepted.
+func UseMFAStep(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, step int64) error {
+	result, err := db.Exec(ctx, `
+		UPDATE api.user_mfa
+		SET last_used_step = $3, enabled = TRUE, date_enabled = COALESCE(date_enabled, CURRENT_TIMESTAMP)
+		WHERE tenant_id = $1 AND user_id = $2 AND last_used_step < $3
+	`, tenantID, userID, step)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrMFACodeUsed
+	}
+	return nil
+}
+
+// UseRecoveryCode removes the recovery code with the given hash from an
+// enabled enrollment. ErrMFACodeUsed means no unused code has that hash.
+func UseRecoveryCode(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, hash string) error {
+	result, err := db.Exec(ctx, `
+		UPDATE api.user_mfa SET recovery_codes = array_remove(recovery_codes, $3)
+		WHERE tenant_id = $1 AND user_id = $2 AND enabled AND $3 = ANY(recovery_codes)
+	`, tenantID, userID, hash)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrMFACodeUsed
+	}
+	return nil
+}
+
+// CountMFAAttempt counts a code as wrong before it is checked, so parallel
+// guesses can't get past the limits; ClearMFAAttempts takes it back once the
+// code is accepted. A code sent with a login challenge is counted against
+// challengeID too, which expires with the challenge. It returns a
+// *MFALockedError while the user is locked out and ErrMFAChallengeSpent once
+// the challenge has taken its codes, counting nothing, so call it in a
+// transaction.
+func CountMFAAttempt(ctx context.Context, db DBTX, tenantID, userID, challengeID uuid.UUID, challengeExpires time.Time, limits MFALimits) error {
+	if challengeID != uuid.Nil {
+		_, err := db.Exec(ctx, "DELETE FROM api.mfa_challenge_attempts WHERE tenant_id = $1 AND user_id = $2 AND expires_at <= CURRENT_TIMESTAMP", tenantID, userID)
+		if err != nil {
+			return err
+		}
+		var attempts int
+		err = db.QueryRow(ctx, `
+			INSERT INTO api.mfa_challenge_attempts (challenge_id, tenant_id, user_id, attempts, expires_at)
+			VALUES ($3, $1, $2, 1, $5)
+			ON CONFLICT (challenge_id) DO UPDATE
+			SET attempts = api.mfa_challenge_attempts.attempts + 1
+			WHERE api.mfa_challenge_attempts.attempts < $4
+			RETURNING attempts
+		`, tenantID, userID, challengeID, limits.ChallengeAttempts, challengeExpires.UTC()).Scan(&attempts)
+		if err = notFound(err); errors.Is(err, ErrNotFound) {
+			return ErrMFAChallengeSpent
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var counted bool
+	err := db.QueryRow(ctx, `
+		UPDATE api.user_mfa
+		SET failed_attempts = failed_attempts + 1,
+		    locked_until = CASE WHEN failed_attempts + 1 >= $3
+		        THEN CURRENT_TIMESTAMP + $4 * INTERVAL '1 millisecond' ELSE locked_until END
+		WHERE tenant_id = $1 AND user_id = $2 AND (locked_until IS NULL OR locked_until <= CURRENT_TIMESTAMP)
+		RETURNING TRUE
+	`, tenantID, userID, limits.FailedAttempts, limits.Lockout.Milliseconds()).Scan(&counted)
+	if err = notFound(err); !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	// Locked out, or not enrolled at all
+	mfa, err := GetMFA(ctx, db, tenantID, userID)
+	if err != nil {
+		return err
+	}
+	if mfa.LockedUntil == nil {
+		return ErrNotFound
+	}
+	return &MFALockedError{Until: *mfa.LockedUntil}
+}
+
+// ClearMFAAttempts forgets the user's wrong codes, and the codes a login
+// challenge took, once a code is accepted
+func ClearMFAAttempts(ctx context.Context, db DBTX, tenantID, userID, challengeID uuid.UUID) error {
+	if _, err := db.Exec(ctx, "DELETE FROM api.mfa_challenge_attempts WHERE tenant_id = $1 AND challenge_id = $2", tenantID, challengeID); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, `
+		UPDATE api.user_mfa SET failed_attempts = 0, locked_until = NULL
+		WHERE tenant_id = $1 AND user_id = $2
+	`, tenantID, userID)
+	return err
+}
+
+// DeleteMFA removes the user's enrollment, pending or enabled
+func DeleteMFA(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) error {
+	result, err := db.Exec(ctx, "DELETE FROM api.user_mfa WHERE tenant_id = $1 AND user_id = $2", tenantID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanMFA(row interface{ Scan(...any) error }) (*MFA, error) {
+	var mfa MFA
+	err := row.Scan(&mfa.UserID, &mfa.Secret, &mfa.Enabled, &mfa.RecoveryCodes, &mfa.LastUsedStep, &mfa.FailedAttempts, &mfa.LockedUntil,
+		&mfa.DateCreated, &mfa.DateEnabled)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	mfa.RecoveryCodesRemaining = len(mfa.RecoveryCodes)
+	return &mfa, nil
+}
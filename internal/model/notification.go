@@ -0,0 +1,297 @@
+// internal/model/notification.go
+package model
+
+import (
+	"context"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Emails sent to users about their account and traces. Each is sent unless
+// the user turns it off.
+const (
+	EmailAccountCreated  = "account_created"
+	EmailPasswordChanged = "password_changed"
+	EmailTraceProcessed  = "trace_processed"
+	EmailMFAEnabled      = "mfa_enabled"
+)
+
+// EmailEvents lists every email a user can turn on or off
+var EmailEvents = []string{EmailAccountCreated, EmailPasswordChanged, EmailTraceProcessed, EmailMFAEnabled}
+
+// In-app notification types. Each is added to the feed unless the user
+// turns it off.
+const (
+	NotificationTraceProcessed = "trace_processed"
+	NotificationCourseUpdated  = "course_updated"
+)
+
+// NotificationTypes lists every in-app notification a user can turn on or off
+var NotificationTypes = []string{NotificationTraceProcessed, NotificationCourseUpdated}
+
+// Preference channels, as stored in api.notification_preferences
+const (
+	channelEmail = "email"
+	channelInApp = "in_app"
+)
+
+// NotificationPreferences says which emails and in-app notifications a user
+// gets, by type
+type NotificationPreferences struct {
+	Email map[string]bool `json:"email"`
+	InApp map[string]bool `json:"in_app"`
+}
+
+// DefaultNotificationPreferences turns everything on
+func DefaultNotificationPreferences() *NotificationPreferences {
+	prefs := &NotificationPreferences{Email: map[string]bool{}, InApp: map[string]bool{}}
+	for _, event := range EmailEvents {
+		prefs.Email[event] = true
+	}
+	for _, kind := range NotificationTypes {
+		prefs.InApp[kind] = true
+	}
+	return prefs
+}
+
+// EmailEnabled reports whether the user wants the event's email
+func (p *NotificationPreferences) EmailEnabled(event string) bool {
+	enabled, ok := p.Email[event]
+	return !ok || enabled
+}
+
+// InAppEnabled reports whether the user wants notifications of the type
+func (p *NotificationPreferences) InAppEnabled(kind string) bool {
+	enabled, ok := p.InApp[kind]
+	return !ok || enabled
+}
+
+// channel returns the map holding the channel's settings
+func (p *NotificationPreferences) channel(name string) map[string]bool {
+	if name == channelInApp {
+		return p.InApp
+	}
+	return p.Email
+}
+
+// UpdateNotificationPreferencesRequest turns emails and in-app
+// notifications on or off; types left out keep their setting
+type UpdateNotificationPreferencesRequest struct {
+	Email map[string]bool `json:"email,omitempty" validate:"required_without=InApp,omitempty,dive,keys,oneof=account_created password_changed trace_processed mfa_enabled,endkeys"`
+	InApp map[string]bool `json:"in_app,omitempty" validate:"omitempty,dive,keys,oneof=trace_processed course_updated,endkeys"`
+}
+
+// GetNotificationPreferences returns the user's preferences, with every
+// type they haven't set turned on
+func GetNotificationPreferences(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) (*NotificationPreferences, error) {
+	rows, err := db.Query(ctx, `
+		SELECT channel, event, enabled FROM api.notification_preferences
+		WHERE tenant_id = $1 AND user_id = $2`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := DefaultNotificationPreferences()
+	for rows.Next() {
+		var channel, event string
+		var enabled bool
+		if err := rows.Scan(&channel, &event, &enabled); err != nil {
+			return nil, err
+		}
+		prefs.channel(channel)[event] = enabled
+	}
+	return prefs, rows.Err()
+}
+
+// UpdateNotificationPreferences stores the settings in req and returns the
+// user's preferences. ErrNotFound means the user doesn't exist.
+func UpdateNotificationPreferences(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, req UpdateNotificationPreferencesRequest) (*NotificationPreferences, error) {
+	var channels, events []string
+	var enabled []bool
+	add := func(channel string, settings map[string]bool) {
+		for event, on := range settings {
+			channels = append(channels, channel)
+			events = append(events, event)
+			enabled = append(enabled, on)
+		}
+	}
+	add(channelEmail, req.Email)
+	add(channelInApp, req.InApp)
+
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM api.users WHERE tenant_id = $1 AND id = $2)", tenantID, userID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO api.notification_preferences (user_id, tenant_id, channel, event, enabled)
+		SELECT $2, $1, p.channel, p.event, p.enabled
+		FROM unnest($3::text[], $4::text[], $5::boolean[]) AS p(channel, event, enabled)
+		ON CONFLICT (user_id, channel, event) DO UPDATE
+		SET enabled = EXCLUDED.enabled, date_updated = CURRENT_TIMESTAMP
+	`, tenantID, userID, channels, events, enabled)
+	if err != nil {
+		return nil, err
+	}
+	return GetNotificationPreferences(ctx, db, tenantID, userID)
+}
+
+// DeleteNotificationPreferences forgets the user's preferences
+func DeleteNotificationPreferences(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, "DELETE FROM api.notification_preferences WHERE tenant_id = $1 AND user_id = $2", tenantID, userID)
+	return err
+}
+
+// Notification is an entry in a user's in-app feed
+type Notification struct {
+	ID          uuid.UUID  `json:"id"`
+	Type        string     `json:"type"`
+	Message     string     `json:"message"`
+	CourseID    *uuid.UUID `json:"course_id"`
+	TraceID     *uuid.UUID `json:"trace_id"`
+	ReadAt      *time.Time `json:"read_at"`
+	DateCreated time.Time  `json:"date_created"`
+}
+
+// UpdateNotificationRequest marks a notification read or unread
+type UpdateNotificationRequest struct {
+	Read *bool `json:"read" validate:"required"`
+}
+
+// maxNotificationMessageLength is the width of api.notifications.message
+const maxNotificationMessageLength = 500
+
+// notificationListSpec is the ?sort= and ?fields= allowlist for the feed
+var notificationListSpec = &listSpec[Notification]{
+	table: "api.notifications",
+	columns: map[string]listColumn[Notification]{
+		"id":           {"id", kindUUID, true, func(n *Notification) any { return &n.ID }},
+		"type":         {"type", kindString, true, func(n *Notification) any { return &n.Type }},
+		"message":      {"message", kindString, false, func(n *Notification) any { return &n.Message }},
+		"course_id":    {"course_id", kindUUID, false, func(n *Notification) any { return &n.CourseID }},
+		"trace_id":     {"trace_id", kindUUID, false, func(n *Notification) any { return &n.TraceID }},
+		"read_at":      {"read_at", kindTime, false, func(n *Notification) any { return &n.ReadAt }},
+		"date_created": {"date_created", kindTime, true, func(n *Notification) any { return &n.DateCreated }},
+	},
+	aliases:     map[string]string{"created_at": "date_created"},
+	defaultSort: []SortField{{Field: "date_created", Desc: true}},
+}
+
+// ListNotifications returns one page of the user's feed, newest first unless
+// opts.Sort says otherwise, optionally only the unread entries
+func ListNotifications(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, unreadOnly bool, opts ListOptions) (*Page[Notification], error) {
+	where := "tenant_id = $1 AND user_id = $2"
+	if unreadOnly {
+		where += " AND read_at IS NULL"
+	}
+	return list(ctx, db, notificationListSpec, where, []any{tenantID, userID}, opts)
+}
+
+// PaginateNotifications pages through notifications held in memory like
+// ListNotifications does
+func PaginateNotifications(notifications []Notification, opts ListOptions) (*Page[Notification], error) {
+	return paginate(notificationListSpec, notifications, opts)
+}
+
+const notificationColumns = "id, type, message, course_id, trace_id, read_at, date_created"
+
+// InsertNotifications adds n to the feed of each user who hasn't turned its
+// type off. The ID and creation date are generated per user.
+func InsertNotifications(ctx context.Context, db DBTX, tenantID uuid.UUID, userIDs []uuid.UUID, n Notification) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO api.notifications (tenant_id, user_id, type, message, course_id, trace_id)
+		SELECT $1, u.id, $3, $4, $5, $6
+		FROM api.users u
+		WHERE u.tenant_id = $1 AND u.id = ANY($2)
+		  AND NOT EXISTS (
+		    SELECT 1 FROM api.notification_preferences p
+		    WHERE p.user_id = u.id AND p.channel = 'in_app' AND p.event = $3 AND NOT p.enabled
+		  )
+	`, tenantID, userIDs, n.Type, truncate(n.Message, maxNotificationMessageLength), n.CourseID, n.TraceID)
+	return err
+}
+
+// SetNotificationRead marks one of the user's notifications read or unread.
+// Marking a read notification read again keeps its original read_at.
+func SetNotificationRead(ctx context.Context, db DBTX, tenantID, userID, notificationID uuid.UUID, read bool) (*Notification, error) {
+	var n Notification
+	err := db.QueryRow(ctx, `
+		UPDATE api.notifications
+		SET read_at = CASE WHEN $4 THEN COALESCE(read_at, CURRENT_TIMESTAMP) END
+		WHERE tenant_id = $1 AND user_id = $2 AND id = $3
+		RETURNING `+notificationColumns, tenantID, userID, notificationID, read).Scan(
+		&n.ID, &n.Type, &n.Message, &n.CourseID, &n.TraceID, &n.ReadAt, &n.DateCreated)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &n, nil
+}
+
+// MarkAllNotificationsRead marks every unread notification of the user read
+// and returns how many there were
+func MarkAllNotificationsRead(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) (int, error) {
+	result, err := db.Exec(ctx, `
+		UPDATE api.notifications SET read_at = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND user_id = $2 AND read_at IS NULL`, tenantID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// DeleteNotifications empties the user's feed
+func DeleteNotifications(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, "DELETE FROM api.notifications WHERE tenant_id = $1 AND user_id = $2", tenantID, userID)
+	return err
+}
+
+// CourseUploaders returns the users who uploaded traces to the course, who
+// are told when it changes
+func CourseUploaders(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := db.Query(ctx, "SELECT DISTINCT user_id FROM api.traces WHERE tenant_id = $1 AND course_id = $2", tenantID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
+
+// TraceProcessedNotification tells a trace's uploader it has been processed
+func TraceProcessedNotification(course *Course, trace *Trace) Notification {
+	return Notification{
+		Type:     NotificationTraceProcessed,
+		Message:  "Your trace " + path.Base(trace.FileName) + " for " + course.Name + " has been processed",
+		CourseID: &course.ID,
+		TraceID:  &trace.ID,
+	}
+}
+
+// CourseUpdatedNotification tells uploaders which of a course's fields changed
+func CourseUpdatedNotification(course *Course, changes map[string]any) Notification {
+	fields := slices.Sorted(maps.Keys(changes))
+	return Notification{
+		Type:     NotificationCourseUpdated,
+		Message:  course.Name + " was updated: " + strings.Join(fields, ", ") + " changed",
+		CourseID: &course.ID,
+	}
+}
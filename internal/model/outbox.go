@@ -0,0 +1,323 @@
+// internal/model/outbox.go
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outbox event statuses
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusPublished = "published"
+	OutboxStatusFailed    = "failed"
+)
+
+// TopicPDFUpload is where uploaded traces are announced to the processing
+// pipeline. Despite the name it carries every accepted format; consumers
+// route on the event's content_type.
+const TopicPDFUpload = "pdf-upload"
+
+// OutboxEvent is a message written in the same transaction as the change it
+// describes and published to Kafka afterwards by the outbox relay.
+type OutboxEvent struct {
+	ID            uuid.UUID  `json:"id"`
+	Topic         string     `json:"topic"`
+	AggregateID   uuid.UUID  `json:"aggregate_id"`
+	Payload       []byte     `json:"payload"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     *string    `json:"last_error"`
+	DateCreated   time.Time  `json:"date_created"`
+	DatePublished *time.Time `json:"date_published"`
+}
+
+func InsertOutboxEvent(ctx context.Context, db DBTX, topic string, aggregateID uuid.UUID, payload []byte) (*OutboxEvent, error) {
+	var event OutboxEvent
+	query := `
+		INSERT INTO api.outbox (topic, aggregate_id, payload)
+		VALUES ($1, $2, $3)
+		RETURNING id, topic, aggregate_id, payload, status, attempts, last_error, date_created, date_published
+	`
+
+	err := db.QueryRow(ctx, query, topic, aggregateID, payload).Scan(
+		&event.ID,
+		&event.Topic,
+		&event.AggregateID,
+		&event.Payload,
+		&event.Status,
+		&event.Attempts,
+		&event.LastError,
+		&event.DateCreated,
+		&event.DatePublished,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// LockPendingOutboxEvents returns the oldest pending events, row-locked so
+// concurrent relays skip them. It must be called inside a transaction.
+func LockPendingOutboxEvents(ctx context.Context, db DBTX, limit int) ([]OutboxEvent, error) {
+	query := `
+		SELECT id, topic, aggregate_id, payload, status, attempts, last_error, date_created, date_published
+		FROM api.outbox
+		WHERE status = 'pending'
+		ORDER BY date_created
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.Topic,
+			&event.AggregateID,
+			&event.Payload,
+			&event.Status,
+			&event.Attempts,
+			&event.LastError,
+			&event.DateCreated,
+			&event.DatePublished,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func MarkOutboxEventPublished(ctx context.Context, db DBTX, eventID uuid.UUID) error {
+	query := `
+		UPDATE api.outbox
+		SET status = 'published', attempts = attempts + 1, last_error = NULL, date_published = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	_, err := db.Exec(ctx, query, eventID)
+	return err
+}
+
+// RecordOutboxFailure stores the publish error and gives up on the event once
+// maxAttempts is reached. It returns the event's new status.
+func RecordOutboxFailure(ctx context.Context, db DBTX, eventID uuid.UUID, publishErr string, maxAttempts int) (string, error) {
+	query := `
+		UPDATE api.outbox
+		SET attempts = attempts + 1,
+			last_error = $2,
+			status = CASE WHEN attempts + 1 >= $3 THEN 'failed' ELSE 'pending' END
+		WHERE id = $1
+		RETURNING status
+	`
+	var status string
+	err := db.QueryRow(ctx, query, eventID, publishErr, maxAttempts).Scan(&status)
+	return status, err
+}
+
+// SetTracePublishStatus records on a trace how far its event has got. The
+// aggregate of an event that isn't a trace's matches no row.
+func SetTracePublishStatus(ctx context.Context, db DBTX, traceID uuid.UUID, publishStatus string) error {
+	query := `
+		UPDATE api.traces
+		SET publish_status = $2
+		WHERE id = $1 AND publish_status IS DISTINCT FROM $2
+	`
+	_, err := db.Exec(ctx, query, traceID, publishStatus)
+	return err
+}
+
+// OutboxBacklog is how far the relay is behind: the events still waiting to
+// be published, the oldest of them, and the events it gave up on, plus the
+// traces accepted whose event is among them
+type OutboxBacklog struct {
+	Pending       int
+	Failed        int
+	OldestPending *time.Time
+	// TracesPending and TracesFailed count traces by publish status
+	TracesPending int
+	TracesFailed  int
+}
+
+func GetOutboxBacklog(ctx context.Context, db DBTX) (*OutboxBacklog, error) {
+	query := `
+		SELECT
+			count(*) FILTER (WHERE status = 'pending'),
+			count(*) FILTER (WHERE status = 'failed'),
+			min(date_created) FILTER (WHERE status = 'pending')
+		FROM api.outbox
+		WHERE status IN ('pending', 'failed')
+	`
+	var backlog OutboxBacklog
+	if err := db.QueryRow(ctx, query).Scan(&backlog.Pending, &backlog.Failed, &backlog.OldestPending); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT
+			count(*) FILTER (WHERE publish_status = 'publish_pending'),
+			count(*) FILTER (WHERE publish_status = 'publish_failed')
+		FROM api.traces
+		WHERE publish_status IN ('publish_pending', 'publish_failed')
+	`
+	if err := db.QueryRow(ctx, query).Scan(&backlog.TracesPending, &backlog.TracesFailed); err != nil {
+		return nil, err
+	}
+	return &backlog, nil
+}
+
+// PDFUploadEvent is the payload announcing a trace on TopicPDFUpload, naming
+// the trace the pipeline reports the processing status of, where its
+// object is stored and its MIME type, so the right parser picks it up
+func PDFUploadEvent(traceID uuid.UUID, course Course, instructor Instructor, bucketPath, contentType, tenantSlug string) ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"trace_id":        traceID.String(),
+		"course_id":       course.ID.String(),
+		"instructor_name": strings.ToLower(instructor.Name),
+		"course_code":     strings.ToLower(fmt.Sprintf("%s %d", course.SubjectCode, course.CourseID)),
+		"semester_term":   strings.ToLower(course.SemesterTerm),
+		"semester_year":   strings.ToLower(fmt.Sprintf("%d", course.SemesterYear)),
+		"course_name":     strings.ToLower(course.Name),
+		"credit_hours":    strings.ToLower(fmt.Sprintf("%d", course.CreditHours)),
+		"bucket_path":     bucketPath,
+		"content_type":    contentType,
+		"tenant":          tenantSlug,
+	})
+}
+
+// ReplayFilter selects the traces whose pdf-upload events are replayed;
+// zero fields select everything
+type ReplayFilter struct {
+	TenantID *uuid.UUID
+	CourseID *uuid.UUID
+	// Since and Until bound when the traces were uploaded, Until exclusive
+	Since  *time.Time
+	Until  *time.Time
+	Status string
+}
+
+// ReplayTrace is a trace, with its Course and Instructor set, and the slug
+// of its tenant: what its pdf-upload event is built from
+type ReplayTrace struct {
+	Trace
+	TenantSlug string
+}
+
+// ListReplayTraces returns up to limit traces matching filter that were
+// announced when uploaded, oldest first, starting after the trace uploaded
+// at afterCreated with ID afterID when afterCreated isn't zero. Traces of
+// every tenant are read unless filter names one.
+func ListReplayTraces(ctx context.Context, db DBTX, filter ReplayFilter, afterCreated time.Time, afterID uuid.UUID, limit int) ([]ReplayTrace, error) {
+	conditions := []string{"t.publish_status IS NOT NULL"}
+	args := []any{}
+	where := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.TenantID != nil {
+		where("t.tenant_id = $%d", *filter.TenantID)
+	}
+	if filter.CourseID != nil {
+		where("t.course_id = $%d", *filter.CourseID)
+	}
+	if filter.Since != nil {
+		where("t.date_created >= $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		where("t.date_created < $%d", *filter.Until)
+	}
+	if filter.Status != "" {
+		where("t.status = $%d", filter.Status)
+	}
+	if !afterCreated.IsZero() {
+		args = append(args, afterCreated, afterID)
+		conditions = append(conditions, fmt.Sprintf("(t.date_created, t.id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, limit)
+
+	query := `
+		SELECT t.id, t.user_id, t.instructor_id, t.status, t.vector_id, t.file_name, t.bucket_url, t.storage_tier, t.content_type, t.size_bytes, t.visibility,
+			t.link_url, t.publish_status, t.archived_at, t.date_created, t.date_updated,
+			c.id, c.name, c.subject_code, c.course_id, c.semester_term, c.semester_year, c.credit_hours,
+			i.id, i.name, tn.slug
+		FROM api.traces t
+		JOIN api.courses c ON c.id = t.course_id
+		JOIN api.instructors i ON i.id = t.instructor_id
+		JOIN api.tenants tn ON tn.id = t.tenant_id
+		WHERE ` + strings.Join(conditions, " AND ") + fmt.Sprintf(`
+		ORDER BY t.date_created, t.id
+		LIMIT $%d
+	`, len(args))
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var traces []ReplayTrace
+	for rows.Next() {
+		var (
+			trace      ReplayTrace
+			course     Course
+			instructor Instructor
+		)
+		err := rows.Scan(
+			&trace.ID,
+			&trace.UserID,
+			&trace.InstructorID,
+			&trace.Status,
+			&trace.VectorID,
+			&trace.FileName,
+			&trace.BucketURL,
+			&trace.StorageTier,
+			&trace.ContentType,
+			&trace.SizeBytes,
+			&trace.Visibility,
+			&trace.LinkURL,
+			&trace.PublishStatus,
+			&trace.ArchivedAt,
+			&trace.DateCreated,
+			&trace.DateUpdated,
+			&course.ID,
+			&course.Name,
+			&course.SubjectCode,
+			&course.CourseID,
+			&course.SemesterTerm,
+			&course.SemesterYear,
+			&course.CreditHours,
+			&instructor.ID,
+			&instructor.Name,
+			&trace.TenantSlug,
+		)
+		if err != nil {
+			return nil, err
+		}
+		trace.Course, trace.Instructor = &course, &instructor
+		traces = append(traces, trace)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return traces, nil
+}
@@ -0,0 +1,200 @@
+// internal/model/revision.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TraceRevision is one file a trace has had. Replacing the file keeps the
+// earlier object in storage, so every revision stays downloadable.
+type TraceRevision struct {
+	Revision     int       `json:"revision"`
+	Current      bool      `json:"current"`
+	FileName     string    `json:"file_name"`
+	BucketURL    string    `json:"bucket_url"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	DateUploaded time.Time `json:"date_uploaded"`
+	// DateReplaced is nil for the current revision
+	DateReplaced *time.Time `json:"date_replaced"`
+}
+
+// revisionBytes is the size of the earlier revisions of the api.traces row
+// being deleted. The trigger deleting them runs after RETURNING is computed.
+const revisionBytes = "COALESCE((SELECT sum(r.size_bytes) FROM api.trace_revisions r WHERE r.trace_id = api.traces.id), 0)"
+
+// TraceFile is a newly stored file for a trace
+type TraceFile struct {
+	FileName    string
+	BucketURL   string
+	ContentType string
+	SizeBytes   int64
+}
+
+// RecordTraceRevision keeps a trace's current file as its latest earlier
+// revision, before the file is replaced, and returns it. The trace should be
+// locked so concurrent replacements number their revisions in turn.
+func RecordTraceRevision(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID) (*TraceRevision, error) {
+	query := `
+		INSERT INTO api.trace_revisions (trace_id, revision, tenant_id, file_name, bucket_url, content_type, size_bytes, date_uploaded)
+		SELECT t.id,
+			(SELECT count(*) + 1 FROM api.trace_revisions r WHERE r.trace_id = t.id),
+			t.tenant_id, t.file_name, t.bucket_url, t.content_type, t.size_bytes,
+			COALESCE((SELECT max(r.date_replaced) FROM api.trace_revisions r WHERE r.trace_id = t.id), t.date_created)
+		FROM api.traces t
+		WHERE t.tenant_id = $1 AND t.course_id = $2 AND t.id = $3
+		RETURNING revision, file_name, bucket_url, content_type, size_bytes, date_uploaded, date_replaced
+	`
+
+	var revision TraceRevision
+	err := db.QueryRow(ctx, query, tenantID, courseID, traceID).Scan(
+		&revision.Revision,
+		&revision.FileName,
+		&revision.BucketURL,
+		&revision.ContentType,
+		&revision.SizeBytes,
+		&revision.DateUploaded,
+		&revision.DateReplaced,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &revision, nil
+}
+
+// ReplaceTraceFile points a trace at a new file, in standard storage and
+// waiting for the pipeline to process it and for its pdf-upload event to be
+// published. The text and thumbnail of the old file are dropped so the
+// background jobs redo them.
+func ReplaceTraceFile(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID, file TraceFile) (*Trace, error) {
+	query := `
+		UPDATE api.traces
+		SET file_name = $4,
+			bucket_url = $5,
+			content_type = $6,
+			size_bytes = $7,
+			status = 'uploaded',
+			storage_tier = 'standard',
+			archived_at = NULL,
+			publish_status = 'publish_pending',
+			date_updated = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND course_id = $2 AND id = $3
+		RETURNING id, user_id, instructor_id, status, vector_id, file_name, bucket_url, storage_tier, content_type, size_bytes, visibility, link_url, publish_status, archived_at, date_created, date_updated
+	`
+
+	var trace Trace
+	err := db.QueryRow(ctx, query, tenantID, courseID, traceID, file.FileName, file.BucketURL, file.ContentType, file.SizeBytes).Scan(
+		&trace.ID,
+		&trace.UserID,
+		&trace.InstructorID,
+		&trace.Status,
+		&trace.VectorID,
+		&trace.FileName,
+		&trace.BucketURL,
+		&trace.StorageTier,
+		&trace.ContentType,
+		&trace.SizeBytes,
+		&trace.Visibility,
+		&trace.LinkURL,
+		&trace.PublishStatus,
+		&trace.ArchivedAt,
+		&trace.DateCreated,
+		&trace.DateUpdated,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	if _, err := db.Exec(ctx, "DELETE FROM api.trace_contents WHERE trace_id = $1", traceID); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(ctx, "DELETE FROM api.trace_thumbnails WHERE trace_id = $1", traceID); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
+
+// ListTraceRevisions returns every revision of one of the tenant's traces,
+// newest first, the current one included. It returns ErrNotFound if the
+// trace is unknown.
+func ListTraceRevisions(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID) ([]TraceRevision, error) {
+	query := `
+		SELECT (SELECT count(*) + 1 FROM api.trace_revisions r WHERE r.trace_id = t.id),
+			t.file_name, t.bucket_url, t.content_type, t.size_bytes,
+			COALESCE((SELECT max(r.date_replaced) FROM api.trace_revisions r WHERE r.trace_id = t.id), t.date_created),
+			NULL::timestamp
+		FROM api.traces t
+		WHERE t.tenant_id = $1 AND t.course_id = $2 AND t.id = $3
+		UNION ALL
+		SELECT r.revision, r.file_name, r.bucket_url, r.content_type, r.size_bytes, r.date_uploaded, r.date_replaced
+		FROM api.trace_revisions r
+		JOIN api.traces t ON t.id = r.trace_id
+		WHERE t.tenant_id = $1 AND t.course_id = $2 AND t.id = $3
+		ORDER BY 1 DESC
+	`
+
+	rows, err := db.Query(ctx, query, tenantID, courseID, traceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []TraceRevision
+	for rows.Next() {
+		var revision TraceRevision
+		err := rows.Scan(
+			&revision.Revision,
+			&revision.FileName,
+			&revision.BucketURL,
+			&revision.ContentType,
+			&revision.SizeBytes,
+			&revision.DateUploaded,
+			&revision.DateReplaced,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revision.Current = revision.DateReplaced == nil
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, ErrNotFound
+	}
+	return revisions, nil
+}
+
+// TraceRevisionsOf returns the earlier revisions of the given traces, by
+// trace, oldest first
+func TraceRevisionsOf(ctx context.Context, db DBTX, traceIDs []uuid.UUID) (map[uuid.UUID][]TraceRevision, error) {
+	rows, err := db.Query(ctx, `
+		SELECT trace_id, revision, file_name, bucket_url, content_type, size_bytes, date_uploaded, date_replaced
+		FROM api.trace_revisions
+		WHERE trace_id = ANY($1)
+		ORDER BY trace_id, revision
+	`, traceIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := map[uuid.UUID][]TraceRevision{}
+	for rows.Next() {
+		var (
+			traceID  uuid.UUID
+			revision TraceRevision
+		)
+		err := rows.Scan(&traceID, &revision.Revision, &revision.FileName, &revision.BucketURL,
+			&revision.ContentType, &revision.SizeBytes, &revision.DateUploaded, &revision.DateReplaced)
+		if err != nil {
+			return nil, err
+		}
+		revisions[traceID] = append(revisions[traceID], revision)
+	}
+	return revisions, rows.Err()
+}
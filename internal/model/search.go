@@ -0,0 +1,158 @@
+// internal/model/search.go
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// SearchFilters narrow a search to courses matching every field set
+type SearchFilters struct {
+	SubjectCode  *string
+	SemesterTerm *string
+	SemesterYear *int
+	InstructorID *uuid.UUID
+}
+
+// Matches reports whether course passes the filters
+func (f SearchFilters) Matches(course *Course) bool {
+	return (f.SubjectCode == nil || strings.EqualFold(course.SubjectCode, *f.SubjectCode)) &&
+		(f.SemesterTerm == nil || course.SemesterTerm == *f.SemesterTerm) &&
+		(f.SemesterYear == nil || course.SemesterYear == *f.SemesterYear) &&
+		(f.InstructorID == nil || course.InstructorID == *f.InstructorID)
+}
+
+// SearchCourse is the course a search result belongs to
+type SearchCourse struct {
+	ID           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	SubjectCode  string    `json:"subject_code"`
+	CourseID     int       `json:"course_id"`
+	SemesterTerm string    `json:"semester_term"`
+	SemesterYear int       `json:"semester_year"`
+	InstructorID uuid.UUID `json:"instructor_id"`
+}
+
+// SearchTrace is the trace a search result points at
+type SearchTrace struct {
+	ID       uuid.UUID `json:"id"`
+	FileName string    `json:"file_name"`
+	VectorID *string   `json:"vector_id"`
+}
+
+// SearchResult is one processed trace matching a search, ranked by the
+// cosine distance of its embedding from the query's
+type SearchResult struct {
+	Course   SearchCourse `json:"course"`
+	Trace    SearchTrace  `json:"trace"`
+	Distance float64      `json:"distance"`
+	Snippet  string       `json:"snippet"`
+	// Excerpt is the text the trace's embedding was computed from, which
+	// Snippet quotes
+	Excerpt string `json:"-"`
+}
+
+// SearchTraces returns up to limit of the tenant's processed traces nearest
+// to embedding, among courses matching filters
+func SearchTraces(ctx context.Context, db DBTX, tenantID uuid.UUID, embedding []float32, filters SearchFilters, limit int) ([]SearchResult, error) {
+	where := []string{"t.tenant_id = $1", "t.status = 'processed'", "t.embedding IS NOT NULL", "vector_dims(t.embedding) = $3"}
+	args := []any{tenantID, vectorLiteral(embedding), len(embedding)}
+	filter := func(column string, value any) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if filters.SubjectCode != nil {
+		args = append(args, *filters.SubjectCode)
+		where = append(where, fmt.Sprintf("upper(c.subject_code) = upper($%d)", len(args)))
+	}
+	if filters.SemesterTerm != nil {
+		filter("c.semester_term", *filters.SemesterTerm)
+	}
+	if filters.SemesterYear != nil {
+		filter("c.semester_year", *filters.SemesterYear)
+	}
+	if filters.InstructorID != nil {
+		filter("c.instructor_id", *filters.InstructorID)
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT c.id, c.name, c.subject_code, c.course_id, c.semester_term, c.semester_year, c.instructor_id,
+		       t.id, t.file_name, t.vector_id, COALESCE(t.excerpt, ''), t.embedding <=> $2::text::vector AS distance
+		FROM api.traces t
+		JOIN api.courses c ON c.id = t.course_id
+		WHERE %s
+		ORDER BY distance
+		LIMIT $%d
+	`, strings.Join(where, " AND "), len(args))
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var r SearchResult
+		err := rows.Scan(
+			&r.Course.ID,
+			&r.Course.Name,
+			&r.Course.SubjectCode,
+			&r.Course.CourseID,
+			&r.Course.SemesterTerm,
+			&r.Course.SemesterYear,
+			&r.Course.InstructorID,
+			&r.Trace.ID,
+			&r.Trace.FileName,
+			&r.Trace.VectorID,
+			&r.Excerpt,
+			&r.Distance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// Snippet quotes up to size runes of excerpt around the first word of query
+// it contains, or from the start when it contains none. Cut ends are marked
+// with an ellipsis.
+func Snippet(excerpt, query string, size int) string {
+	excerpt = strings.Join(strings.Fields(excerpt), " ")
+	if utf8.RuneCountInString(excerpt) <= size {
+		return excerpt
+	}
+
+	runes := []rune(excerpt)
+	start := 0
+	lower := strings.ToLower(excerpt)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		// Short words such as "a" or "of" match almost anywhere
+		if utf8.RuneCountInString(word) < 3 {
+			continue
+		}
+		if i := strings.Index(lower, word); i >= 0 {
+			// Start a third of the snippet before the match, counting in runes
+			start = max(utf8.RuneCountInString(lower[:i])-size/3, 0)
+			break
+		}
+	}
+	end := min(start+size, len(runes))
+	start = max(end-size, 0)
+
+	snippet := strings.TrimSpace(string(runes[start:end]))
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
@@ -0,0 +1,169 @@
+// internal/model/serviceaccount.go
+package model
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Service account scopes. Each names the one thing it allows; an account
+// can do nothing its scopes don't list.
+const (
+	// ScopeTraceRead allows reading a course's traces
+	ScopeTraceRead = "trace:read"
+	// ScopeTraceStatusUpdate allows setting a trace's processing status
+	ScopeTraceStatusUpdate = "trace:status:update"
+	// ScopeTraceEmbeddingUpdate allows writing a trace's vector ID and embedding
+	ScopeTraceEmbeddingUpdate = "trace:embedding:update"
+)
+
+// ServiceAccountKeyPrefix starts every service account key, which tells
+// them apart from the JWTs sent as bearer tokens
+const ServiceAccountKeyPrefix = "sa_"
+
+// ServiceAccount is a non-human principal, such as the PDF-processing
+// consumer, that authenticates with a key and holds only its scopes
+type ServiceAccount struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Description *string    `json:"description"`
+	Scopes      []string   `json:"scopes"`
+	CreatedBy   *uuid.UUID `json:"created_by"`
+	LastUsed    *time.Time `json:"last_used"`
+	DateCreated time.Time  `json:"date_created"`
+	DateUpdated time.Time  `json:"date_updated"`
+}
+
+// HasScope reports whether the account was granted scope
+func (a *ServiceAccount) HasScope(scope string) bool {
+	return slices.Contains(a.Scopes, scope)
+}
+
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" validate:"required,max=50"`
+	Description *string  `json:"description,omitempty" validate:"omitnil,max=255"`
+	Scopes      []string `json:"scopes" validate:"required,min=1,dive,oneof=trace:read trace:status:update trace:embedding:update"`
+}
+
+// UpdateServiceAccountRequest defines the optional fields for updating a
+// service account via PATCH. Scopes, when given, replace all of them.
+type UpdateServiceAccountRequest struct {
+	Name        *string  `json:"name,omitempty" validate:"omitnil,required,max=50"`
+	Description *string  `json:"description,omitempty" validate:"omitnil,max=255"`
+	Scopes      []string `json:"scopes,omitempty" validate:"omitnil,min=1,dive,oneof=trace:read trace:status:update trace:embedding:update"`
+}
+
+// NewServiceAccountKey generates a random service account key. Only its
+// hash is stored.
+func NewServiceAccountKey() (string, error) {
+	key, err := NewAPIKey()
+	if err != nil {
+		return "", err
+	}
+	return ServiceAccountKeyPrefix + key, nil
+}
+
+const serviceAccountColumns = "id, name, description, scopes, created_by, last_used, date_created, date_updated"
+
+// CreateServiceAccount inserts a service account; keyHash is HashAPIKey of its key
+func CreateServiceAccount(ctx context.Context, db DBTX, tenantID uuid.UUID, req CreateServiceAccountRequest, keyHash string, createdBy uuid.UUID) (*ServiceAccount, error) {
+	query := `
+		INSERT INTO api.service_accounts (tenant_id, name, description, scopes, key_hash, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + serviceAccountColumns
+	return scanServiceAccount(db.QueryRow(ctx, query, tenantID, req.Name, req.Description, req.Scopes, keyHash, createdBy))
+}
+
+// ListServiceAccounts returns the tenant's service accounts by name; there
+// are few enough not to page
+func ListServiceAccounts(ctx context.Context, db DBTX, tenantID uuid.UUID) ([]ServiceAccount, error) {
+	rows, err := db.Query(ctx, "SELECT "+serviceAccountColumns+" FROM api.service_accounts WHERE tenant_id = $1 ORDER BY name", tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []ServiceAccount{}
+	for rows.Next() {
+		a, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *a)
+	}
+	return accounts, rows.Err()
+}
+
+func GetServiceAccount(ctx context.Context, db DBTX, tenantID, accountID uuid.UUID) (*ServiceAccount, error) {
+	query := "SELECT " + serviceAccountColumns + " FROM api.service_accounts WHERE tenant_id = $1 AND id = $2"
+	return scanServiceAccount(db.QueryRow(ctx, query, tenantID, accountID))
+}
+
+func UpdateServiceAccount(ctx context.Context, db DBTX, tenantID, accountID uuid.UUID, req UpdateServiceAccountRequest) (*ServiceAccount, error) {
+	var setClauses []string
+	var args []any
+	set := func(column string, value any) {
+		args = append(args, value)
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if req.Name != nil {
+		set("name", *req.Name)
+	}
+	if req.Description != nil {
+		set("description", *req.Description)
+	}
+	if req.Scopes != nil {
+		set("scopes", req.Scopes)
+	}
+	setClauses = append(setClauses, "date_updated = CURRENT_TIMESTAMP")
+
+	args = append(args, tenantID, accountID)
+	query := "UPDATE api.service_accounts SET " + strings.Join(setClauses, ", ") +
+		fmt.Sprintf(" WHERE tenant_id = $%d AND id = $%d RETURNING ", len(args)-1, len(args)) + serviceAccountColumns
+	return scanServiceAccount(db.QueryRow(ctx, query, args...))
+}
+
+// RotateServiceAccountKey replaces the account's key, so the old one stops
+// working at once
+func RotateServiceAccountKey(ctx context.Context, db DBTX, tenantID, accountID uuid.UUID, keyHash string) (*ServiceAccount, error) {
+	query := `
+		UPDATE api.service_accounts SET key_hash = $3, date_updated = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND id = $2
+		RETURNING ` + serviceAccountColumns
+	return scanServiceAccount(db.QueryRow(ctx, query, tenantID, accountID, keyHash))
+}
+
+func DeleteServiceAccount(ctx context.Context, db DBTX, tenantID, accountID uuid.UUID) error {
+	result, err := db.Exec(ctx, "DELETE FROM api.service_accounts WHERE tenant_id = $1 AND id = $2", tenantID, accountID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AuthenticateServiceAccount returns the tenant's account with the given
+// key and records its use. ErrNotFound means no account has that key.
+func AuthenticateServiceAccount(ctx context.Context, db DBTX, tenantID uuid.UUID, key string) (*ServiceAccount, error) {
+	query := `
+		UPDATE api.service_accounts SET last_used = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND key_hash = $2
+		RETURNING ` + serviceAccountColumns
+	return scanServiceAccount(db.QueryRow(ctx, query, tenantID, HashAPIKey(key)))
+}
+
+func scanServiceAccount(row interface{ Scan(...any) error }) (*ServiceAccount, error) {
+	var a ServiceAccount
+	err := row.Scan(&a.ID, &a.Name, &a.Description, &a.Scopes, &a.CreatedBy, &a.LastUsed, &a.DateCreated, &a.DateUpdated)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &a, nil
+}
@@ -0,0 +1,136 @@
+// internal/model/session.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a bearer token issued by a login, named by the token's jti.
+// A token is only accepted while its session exists, so deleting the
+// session revokes it.
+type Session struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"-"`
+	UserAgent   *string   `json:"user_agent"`
+	IPAddress   *string   `json:"ip_address"`
+	DateCreated time.Time `json:"date_created"`
+	LastSeen    time.Time `json:"last_seen"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	// ImpersonatedBy names the admin who started the session as the user
+	ImpersonatedBy *uuid.UUID `json:"impersonated_by"`
+	// Current marks the session of the token the request was made with
+	Current bool `json:"current"`
+}
+
+// maxUserAgentLength is the width of api.sessions.user_agent
+const maxUserAgentLength = 255
+
+// NewSession is the session of a token being issued. Empty details are
+// left null and long user agents are cut to fit.
+func NewSession(id, userID uuid.UUID, userAgent, ipAddress string, expiresAt time.Time) Session {
+	session := Session{ID: id, UserID: userID, ExpiresAt: expiresAt}
+	if userAgent != "" {
+		userAgent = truncate(userAgent, maxUserAgentLength)
+		session.UserAgent = &userAgent
+	}
+	if ipAddress != "" {
+		session.IPAddress = &ipAddress
+	}
+	return session
+}
+
+const sessionColumns = "id, user_id, user_agent, ip_address, date_created, last_seen, expires_at, impersonated_by"
+
+// CreateSession records a token being issued. The user's expired sessions
+// are cleared out at the same time.
+func CreateSession(ctx context.Context, db DBTX, tenantID uuid.UUID, session Session) (*Session, error) {
+	if _, err := db.Exec(ctx, "DELETE FROM api.sessions WHERE tenant_id = $1 AND user_id = $2 AND expires_at <= CURRENT_TIMESTAMP", tenantID, session.UserID); err != nil {
+		return nil, err
+	}
+	query := `
+		INSERT INTO api.sessions (id, tenant_id, user_id, user_agent, ip_address, expires_at, impersonated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + sessionColumns
+	return scanSession(db.QueryRow(ctx, query, session.ID, tenantID, session.UserID, session.UserAgent, session.IPAddress, session.ExpiresAt.UTC(), session.ImpersonatedBy))
+}
+
+// TouchSession records the session's use. ErrNotFound means it was
+// revoked, has expired or is not the user's.
+func TouchSession(ctx context.Context, db DBTX, tenantID, userID, sessionID uuid.UUID) error {
+	result, err := db.Exec(ctx, `
+		UPDATE api.sessions SET last_seen = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND user_id = $2 AND id = $3 AND expires_at > CURRENT_TIMESTAMP
+	`, tenantID, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListSessions returns the user's unexpired sessions, most recently used first
+func ListSessions(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) ([]Session, error) {
+	rows, err := db.Query(ctx, "SELECT "+sessionColumns+` FROM api.sessions
+		WHERE tenant_id = $1 AND user_id = $2 AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_seen DESC`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
+
+func DeleteSession(ctx context.Context, db DBTX, tenantID, userID, sessionID uuid.UUID) error {
+	result, err := db.Exec(ctx, "DELETE FROM api.sessions WHERE tenant_id = $1 AND user_id = $2 AND id = $3", tenantID, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UserImpersonatedAuditEntry records an admin starting a session as its
+// user. The session must be an impersonation.
+func UserImpersonatedAuditEntry(session *Session) AuditEntry {
+	return AuditEntry{
+		UserID:     *session.ImpersonatedBy,
+		Action:     "user.impersonated",
+		EntityType: "user",
+		EntityID:   session.UserID,
+		Changes: map[string]any{
+			"session_id": session.ID,
+			"expires_at": session.ExpiresAt,
+		},
+	}
+}
+
+// DeleteSessions revokes every token the user holds
+func DeleteSessions(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, "DELETE FROM api.sessions WHERE tenant_id = $1 AND user_id = $2", tenantID, userID)
+	return err
+}
+
+func scanSession(row interface{ Scan(...any) error }) (*Session, error) {
+	var s Session
+	err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.DateCreated, &s.LastSeen, &s.ExpiresAt, &s.ImpersonatedBy)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,172 @@
+// internal/model/share.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TraceShare is a link that lets anyone holding it download a trace's file,
+// whatever the trace's visibility, until it expires or is revoked. Only a
+// hash of the link's token is stored.
+type TraceShare struct {
+	ID        uuid.UUID  `json:"id"`
+	TraceID   uuid.UUID  `json:"trace_id"`
+	CreatedBy uuid.UUID  `json:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	// Active is whether the link still works: neither expired nor revoked
+	Active      bool      `json:"active"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// CreateTraceShareRequest is the optional body of a new share link.
+// ExpiresAt defaults to SHARE_LINK_DEFAULT_TTL from now.
+type CreateTraceShareRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// NewShareToken generates the random token of a share link. Only HashAPIKey
+// of it is stored.
+func NewShareToken() (string, error) {
+	return NewAPIKey()
+}
+
+const traceShareColumns = "id, trace_id, created_by, expires_at, revoked_at, date_created"
+
+// CreateTraceShare records a share link of one of the tenant's traces;
+// tokenHash is HashAPIKey of its token. It returns ErrNotFound if the trace
+// is unknown.
+func CreateTraceShare(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID, tokenHash string, createdBy uuid.UUID, expiresAt time.Time) (*TraceShare, error) {
+	query := `
+		INSERT INTO api.trace_shares (tenant_id, trace_id, token_hash, created_by, expires_at)
+		SELECT t.tenant_id, t.id, $4, $5, $6
+		FROM api.traces t
+		WHERE t.tenant_id = $1 AND t.course_id = $2 AND t.id = $3
+		RETURNING ` + traceShareColumns
+	return scanTraceShare(db.QueryRow(ctx, query, tenantID, courseID, traceID, tokenHash, createdBy, expiresAt))
+}
+
+// ListTraceShares returns every share link of one of the tenant's traces,
+// newest first, revoked and expired ones included
+func ListTraceShares(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID) ([]TraceShare, error) {
+	query := `
+		SELECT s.id, s.trace_id, s.created_by, s.expires_at, s.revoked_at, s.date_created
+		FROM api.trace_shares s
+		JOIN api.traces t ON t.id = s.trace_id
+		WHERE t.tenant_id = $1 AND t.course_id = $2 AND t.id = $3
+		ORDER BY s.date_created DESC
+	`
+
+	rows, err := db.Query(ctx, query, tenantID, courseID, traceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []TraceShare{}
+	for rows.Next() {
+		share, err := scanTraceShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *share)
+	}
+	return shares, rows.Err()
+}
+
+// RevokeTraceShare stops a share link of one of the tenant's traces from
+// working. Revoking a revoked link keeps its first revocation time.
+func RevokeTraceShare(ctx context.Context, db DBTX, tenantID, traceID, shareID uuid.UUID) (*TraceShare, error) {
+	query := `
+		UPDATE api.trace_shares
+		SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP)
+		WHERE tenant_id = $1 AND trace_id = $2 AND id = $3
+		RETURNING ` + traceShareColumns
+	return scanTraceShare(db.QueryRow(ctx, query, tenantID, traceID, shareID))
+}
+
+// GetSharedTrace returns the trace a share link's token opens, in whichever
+// tenant it is, and the link's ID. ErrNotFound means no link has the token,
+// or it has expired or been revoked.
+func GetSharedTrace(ctx context.Context, db DBTX, token string) (*Trace, uuid.UUID, error) {
+	query := `
+		SELECT s.id, t.id, t.user_id, t.instructor_id, t.status, t.vector_id, t.file_name, t.bucket_url, t.storage_tier, t.content_type, t.size_bytes, t.visibility, t.link_url, t.publish_status, t.archived_at, t.date_created, t.date_updated
+		FROM api.trace_shares s
+		JOIN api.traces t ON t.id = s.trace_id AND t.tenant_id = s.tenant_id
+		WHERE s.token_hash = $1 AND s.revoked_at IS NULL AND s.expires_at > CURRENT_TIMESTAMP
+	`
+
+	var (
+		shareID uuid.UUID
+		trace   Trace
+	)
+	err := db.QueryRow(ctx, query, HashAPIKey(token)).Scan(
+		&shareID,
+		&trace.ID,
+		&trace.UserID,
+		&trace.InstructorID,
+		&trace.Status,
+		&trace.VectorID,
+		&trace.FileName,
+		&trace.BucketURL,
+		&trace.StorageTier,
+		&trace.ContentType,
+		&trace.SizeBytes,
+		&trace.Visibility,
+		&trace.LinkURL,
+		&trace.PublishStatus,
+		&trace.ArchivedAt,
+		&trace.DateCreated,
+		&trace.DateUpdated,
+	)
+	if err != nil {
+		return nil, uuid.Nil, notFound(err)
+	}
+	return &trace, shareID, nil
+}
+
+// UpdateTraceVisibility sets who may read one of the tenant's traces
+func UpdateTraceVisibility(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID, visibility string) (*Trace, error) {
+	query := `
+		UPDATE api.traces SET visibility = $4, date_updated = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND course_id = $2 AND id = $3
+		RETURNING id, user_id, instructor_id, status, vector_id, file_name, bucket_url, storage_tier, content_type, size_bytes, visibility, link_url, publish_status, archived_at, date_created, date_updated
+	`
+
+	var trace Trace
+	err := db.QueryRow(ctx, query, tenantID, courseID, traceID, visibility).Scan(
+		&trace.ID,
+		&trace.UserID,
+		&trace.InstructorID,
+		&trace.Status,
+		&trace.VectorID,
+		&trace.FileName,
+		&trace.BucketURL,
+		&trace.StorageTier,
+		&trace.ContentType,
+		&trace.SizeBytes,
+		&trace.Visibility,
+		&trace.LinkURL,
+		&trace.PublishStatus,
+		&trace.ArchivedAt,
+		&trace.DateCreated,
+		&trace.DateUpdated,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &trace, nil
+}
+
+func scanTraceShare(row interface{ Scan(...any) error }) (*TraceShare, error) {
+	var s TraceShare
+	err := row.Scan(&s.ID, &s.TraceID, &s.CreatedBy, &s.ExpiresAt, &s.RevokedAt, &s.DateCreated)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	s.Active = s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+	return &s, nil
+}
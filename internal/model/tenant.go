@@ -0,0 +1,178 @@
+// internal/model/tenant.go
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTenantID owns every row created before multi-tenancy and serves
+// every request while it is disabled. Migration 010 creates it.
+var DefaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// DefaultTenantSlug is the slug of the default tenant
+const DefaultTenantSlug = "default"
+
+// Tenant is a department hosted on the deployment. Users, instructors,
+// courses and traces all belong to exactly one tenant.
+type Tenant struct {
+	ID   uuid.UUID `json:"id"`
+	Slug string    `json:"slug"`
+	Name string    `json:"name"`
+	// BucketPrefix is prepended to the object names of the tenant's traces
+	BucketPrefix *string      `json:"bucket_prefix"`
+	Quotas       TenantQuotas `json:"quotas"`
+	DateCreated  time.Time    `json:"date_created"`
+	DateUpdated  time.Time    `json:"date_updated"`
+}
+
+type CreateTenantRequest struct {
+	Slug         string       `json:"slug" validate:"required,max=63,slug"`
+	Name         string       `json:"name" validate:"required,max=100"`
+	BucketPrefix *string      `json:"bucket_prefix,omitempty" validate:"omitnil,required,max=100"`
+	Quotas       TenantQuotas `json:"quotas"`
+}
+
+// UpdateTenantRequest defines the optional fields for updating a tenant via
+// PATCH. Quotas, when given, replace all three quotas, so an omitted one
+// becomes unlimited.
+type UpdateTenantRequest struct {
+	Name         *string       `json:"name,omitempty" validate:"omitnil,required,max=100"`
+	BucketPrefix *string       `json:"bucket_prefix,omitempty" validate:"omitnil,required,max=100"`
+	Quotas       *TenantQuotas `json:"quotas,omitempty"`
+}
+
+// NewAPIKey generates a random tenant API key. Only its hash is stored.
+func NewAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashAPIKey is the api_key_hash stored for key. API keys are random, so an
+// unsalted hash is enough to keep them out of the database.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+const tenantColumns = "id, slug, name, bucket_prefix, max_courses, max_storage_bytes, max_uploads_per_day, date_created, date_updated"
+
+// CreateTenant inserts a tenant and its usage counters; apiKeyHash may be
+// nil for a tenant resolved only by header or subdomain
+func CreateTenant(ctx context.Context, db DBTX, req CreateTenantRequest, apiKeyHash *string) (*Tenant, error) {
+	query := `
+		WITH tenant AS (
+			INSERT INTO api.tenants (slug, name, bucket_prefix, api_key_hash, max_courses, max_storage_bytes, max_uploads_per_day)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING ` + tenantColumns + `
+		), usage AS (
+			INSERT INTO api.tenant_usage (tenant_id) SELECT id FROM tenant
+		)
+		SELECT ` + tenantColumns + ` FROM tenant`
+	q := req.Quotas
+	return scanTenant(db.QueryRow(ctx, query, req.Slug, req.Name, req.BucketPrefix, apiKeyHash, q.MaxCourses, q.MaxStorageBytes, q.MaxUploadsPerDay))
+}
+
+// ListTenants returns every tenant by slug; there are few enough not to page
+func ListTenants(ctx context.Context, db DBTX) ([]Tenant, error) {
+	rows, err := db.Query(ctx, "SELECT "+tenantColumns+" FROM api.tenants ORDER BY slug")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []Tenant
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, *t)
+	}
+	return tenants, rows.Err()
+}
+
+func GetTenantByID(ctx context.Context, db DBTX, tenantID uuid.UUID) (*Tenant, error) {
+	query := "SELECT " + tenantColumns + " FROM api.tenants WHERE id = $1"
+	return scanTenant(db.QueryRow(ctx, query, tenantID))
+}
+
+func GetTenantBySlug(ctx context.Context, db DBTX, slug string) (*Tenant, error) {
+	query := "SELECT " + tenantColumns + " FROM api.tenants WHERE slug = $1"
+	return scanTenant(db.QueryRow(ctx, query, slug))
+}
+
+// GetTenantByAPIKey looks a tenant up by the hash of key
+func GetTenantByAPIKey(ctx context.Context, db DBTX, key string) (*Tenant, error) {
+	query := "SELECT " + tenantColumns + " FROM api.tenants WHERE api_key_hash = $1"
+	return scanTenant(db.QueryRow(ctx, query, HashAPIKey(key)))
+}
+
+func UpdateTenant(ctx context.Context, db DBTX, slug string, req UpdateTenantRequest) (*Tenant, error) {
+	var setClauses []string
+	var args []any
+	set := func(column string, value any) {
+		args = append(args, value)
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if req.Name != nil {
+		set("name", *req.Name)
+	}
+	if req.BucketPrefix != nil {
+		set("bucket_prefix", *req.BucketPrefix)
+	}
+	if req.Quotas != nil {
+		set("max_courses", req.Quotas.MaxCourses)
+		set("max_storage_bytes", req.Quotas.MaxStorageBytes)
+		set("max_uploads_per_day", req.Quotas.MaxUploadsPerDay)
+	}
+	setClauses = append(setClauses, "date_updated = CURRENT_TIMESTAMP")
+
+	args = append(args, slug)
+	query := "UPDATE api.tenants SET " + strings.Join(setClauses, ", ") +
+		fmt.Sprintf(" WHERE slug = $%d RETURNING ", len(args)) + tenantColumns
+	return scanTenant(db.QueryRow(ctx, query, args...))
+}
+
+// DeleteTenant removes a tenant and its usage. It fails with a foreign key
+// violation while the tenant still has users, instructors, courses or traces.
+func DeleteTenant(ctx context.Context, db DBTX, slug string) error {
+	result, err := db.Exec(ctx, "DELETE FROM api.tenants WHERE slug = $1", slug)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanTenant(row interface{ Scan(dest ...any) error }) (*Tenant, error) {
+	var tenant Tenant
+	err := row.Scan(
+		&tenant.ID,
+		&tenant.Slug,
+		&tenant.Name,
+		&tenant.BucketPrefix,
+		&tenant.Quotas.MaxCourses,
+		&tenant.Quotas.MaxStorageBytes,
+		&tenant.Quotas.MaxUploadsPerDay,
+		&tenant.DateCreated,
+		&tenant.DateUpdated,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &tenant, nil
+}
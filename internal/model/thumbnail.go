@@ -0,0 +1,113 @@
+// internal/model/thumbnail.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TraceThumbnail is the preview rendered from the first page of a trace's
+// PDF. The PNG is in storage; this records its size.
+type TraceThumbnail struct {
+	Width     int
+	Height    int
+	SizeBytes int64
+	// Error says why the PDF couldn't be rendered; the rest is zero then
+	Error       *string
+	GeneratedAt time.Time
+}
+
+// GetTracesWithoutThumbnail returns up to limit traces, across tenants,
+// that haven't been rendered yet, oldest first
+func GetTracesWithoutThumbnail(ctx context.Context, db DBTX, limit int) ([]Trace, error) {
+	query := `
+		SELECT t.id, t.user_id, t.instructor_id, t.status, t.vector_id, t.file_name, t.bucket_url, t.storage_tier, t.content_type, t.size_bytes, t.visibility, t.link_url, t.publish_status, t.archived_at, t.date_created, t.date_updated
+		FROM api.traces t
+		LEFT JOIN api.trace_thumbnails tt ON tt.trace_id = t.id
+		WHERE tt.trace_id IS NULL
+		AND t.status <> 'failed'
+		AND t.bucket_url <> ''
+		ORDER BY t.date_created
+		LIMIT $1
+	`
+
+	rows, err := db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var traces []Trace
+	for rows.Next() {
+		var trace Trace
+		err := rows.Scan(
+			&trace.ID,
+			&trace.UserID,
+			&trace.InstructorID,
+			&trace.Status,
+			&trace.VectorID,
+			&trace.FileName,
+			&trace.BucketURL,
+			&trace.StorageTier,
+			&trace.ContentType,
+			&trace.SizeBytes,
+			&trace.Visibility,
+			&trace.LinkURL,
+			&trace.PublishStatus,
+			&trace.ArchivedAt,
+			&trace.DateCreated,
+			&trace.DateUpdated,
+		)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, trace)
+	}
+	return traces, rows.Err()
+}
+
+// SaveTraceThumbnail records the thumbnail rendered from a trace, in the
+// trace's tenant. It returns ErrNotFound if the trace was deleted meanwhile.
+func SaveTraceThumbnail(ctx context.Context, db DBTX, traceID uuid.UUID, thumbnail TraceThumbnail) error {
+	query := `
+		INSERT INTO api.trace_thumbnails (trace_id, tenant_id, width, height, size_bytes, error)
+		SELECT id, tenant_id, $2, $3, $4, $5 FROM api.traces WHERE id = $1
+		ON CONFLICT (trace_id) DO UPDATE
+		SET width = EXCLUDED.width, height = EXCLUDED.height, size_bytes = EXCLUDED.size_bytes, error = EXCLUDED.error,
+		    generated_at = CURRENT_TIMESTAMP
+	`
+	result, err := db.Exec(ctx, query, traceID, thumbnail.Width, thumbnail.Height, thumbnail.SizeBytes, thumbnail.Error)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetTraceThumbnail returns the thumbnail of one of the tenant's traces. It
+// returns ErrNotFound if the trace is unknown or hasn't been rendered yet.
+func GetTraceThumbnail(ctx context.Context, db DBTX, tenantID, courseID, traceID uuid.UUID) (*TraceThumbnail, error) {
+	query := `
+		SELECT tt.width, tt.height, tt.size_bytes, tt.error, tt.generated_at
+		FROM api.trace_thumbnails tt
+		JOIN api.traces t ON t.id = tt.trace_id
+		WHERE tt.tenant_id = $1 AND t.course_id = $2 AND tt.trace_id = $3
+	`
+
+	var thumbnail TraceThumbnail
+	err := db.QueryRow(ctx, query, tenantID, courseID, traceID).Scan(
+		&thumbnail.Width,
+		&thumbnail.Height,
+		&thumbnail.SizeBytes,
+		&thumbnail.Error,
+		&thumbnail.GeneratedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &thumbnail, nil
+}
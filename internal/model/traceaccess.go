@@ -0,0 +1,101 @@
+// internal/model/traceaccess.go
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Trace access log actions
+const (
+	AccessDownload       = "download"
+	AccessSharedDownload = "shared_download"
+	AccessShareCreated   = "share_created"
+)
+
+// TraceAccess is one entry of a trace's access log: a download of its file
+// or a share link issued for it. UserID and ServiceAccountID are both nil
+// for anonymous downloads.
+type TraceAccess struct {
+	ID               uuid.UUID  `json:"id"`
+	TraceID          uuid.UUID  `json:"trace_id"`
+	Action           string     `json:"action"`
+	UserID           *uuid.UUID `json:"user_id"`
+	ServiceAccountID *uuid.UUID `json:"service_account_id"`
+	ShareID          *uuid.UUID `json:"share_id"`
+	IPAddress        *string    `json:"ip_address"`
+	UserAgent        *string    `json:"user_agent"`
+	DateCreated      time.Time  `json:"date_created"`
+}
+
+// NewTraceAccess is an access of a trace being recorded. Empty details are
+// left null and long user agents are cut to fit, as for sessions.
+func NewTraceAccess(traceID uuid.UUID, action, userAgent, ipAddress string) TraceAccess {
+	access := TraceAccess{TraceID: traceID, Action: action}
+	if userAgent != "" {
+		userAgent = truncate(userAgent, maxUserAgentLength)
+		access.UserAgent = &userAgent
+	}
+	if ipAddress != "" {
+		access.IPAddress = &ipAddress
+	}
+	return access
+}
+
+// traceAccessListSpec is the ?sort= and ?fields= allowlist for access logs
+var traceAccessListSpec = &listSpec[TraceAccess]{
+	table: "api.trace_access_log",
+	columns: map[string]listColumn[TraceAccess]{
+		"id":                 {"id", kindUUID, true, func(a *TraceAccess) any { return &a.ID }},
+		"trace_id":           {"trace_id", kindUUID, false, func(a *TraceAccess) any { return &a.TraceID }},
+		"action":             {"action", kindString, true, func(a *TraceAccess) any { return &a.Action }},
+		"user_id":            {"user_id", kindUUID, false, func(a *TraceAccess) any { return &a.UserID }},
+		"service_account_id": {"service_account_id", kindUUID, false, func(a *TraceAccess) any { return &a.ServiceAccountID }},
+		"share_id":           {"share_id", kindUUID, false, func(a *TraceAccess) any { return &a.ShareID }},
+		"ip_address":         {"ip_address", kindString, false, func(a *TraceAccess) any { return &a.IPAddress }},
+		"user_agent":         {"user_agent", kindString, false, func(a *TraceAccess) any { return &a.UserAgent }},
+		"date_created":       {"date_created", kindTime, true, func(a *TraceAccess) any { return &a.DateCreated }},
+	},
+	aliases:     map[string]string{"created_at": "date_created"},
+	defaultSort: []SortField{{Field: "date_created", Desc: true}},
+}
+
+// RecordTraceAccess appends an entry to a trace's access log, in whichever
+// tenant the trace is, since share links are opened without one. It returns
+// ErrNotFound if the trace is unknown.
+func RecordTraceAccess(ctx context.Context, db DBTX, access TraceAccess) (*TraceAccess, error) {
+	err := db.QueryRow(ctx, `
+		INSERT INTO api.trace_access_log (tenant_id, trace_id, action, user_id, service_account_id, share_id, ip_address, user_agent)
+		SELECT tenant_id, id, $2, $3, $4, $5, $6, $7 FROM api.traces
+		WHERE id = $1
+		RETURNING id, date_created`,
+		access.TraceID, access.Action, access.UserID, access.ServiceAccountID, access.ShareID, access.IPAddress, access.UserAgent,
+	).Scan(&access.ID, &access.DateCreated)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &access, nil
+}
+
+// ListTraceAccess returns one page of a trace's access log, newest first
+// unless opts.Sort says otherwise. The caller checks the trace exists.
+func ListTraceAccess(ctx context.Context, db DBTX, tenantID, traceID uuid.UUID, opts ListOptions) (*Page[TraceAccess], error) {
+	return list(ctx, db, traceAccessListSpec, "tenant_id = $1 AND trace_id = $2", []any{tenantID, traceID}, opts)
+}
+
+// PaginateTraceAccess pages through access log entries held in memory like
+// ListTraceAccess does
+func PaginateTraceAccess(entries []TraceAccess, opts ListOptions) (*Page[TraceAccess], error) {
+	return paginate(traceAccessListSpec, entries, opts)
+}
+
+// ForgetUserTraceAccess clears the address and user agent of the user's
+// access log entries, keeping the record that they accessed the traces
+func ForgetUserTraceAccess(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, `
+		UPDATE api.trace_access_log SET ip_address = NULL, user_agent = NULL
+		WHERE tenant_id = $1 AND user_id = $2`, tenantID, userID)
+	return err
+}
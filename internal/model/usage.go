@@ -0,0 +1,297 @@
+// internal/model/usage.go
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Quotas a write can exceed, as named in QuotaError
+const (
+	QuotaCourses       = "max_courses"
+	QuotaStorageBytes  = "max_storage_bytes"
+	QuotaUploadsPerDay = "max_uploads_per_day"
+	// QuotaCourseStorageBytes is COURSE_MAX_STORAGE_BYTES, for every course
+	QuotaCourseStorageBytes = "max_course_storage_bytes"
+	// QuotaUserUploadsPerDay and QuotaUserUploadBytesPerDay are
+	// USER_MAX_UPLOADS_PER_DAY and USER_MAX_UPLOAD_BYTES_PER_DAY, for every user
+	QuotaUserUploadsPerDay     = "max_user_uploads_per_day"
+	QuotaUserUploadBytesPerDay = "max_user_upload_bytes_per_day"
+)
+
+// UploadLimits caps uploads beneath the tenant's quotas; zero fields are
+// unlimited
+type UploadLimits struct {
+	// CourseBytes caps each course's total trace bytes
+	CourseBytes int64
+	// UserUploadsPerDay and UserBytesPerDay cap what each user uploads in a
+	// day, midnight to midnight UTC
+	UserUploadsPerDay int64
+	UserBytesPerDay   int64
+}
+
+// UserUploadUsage is what a user has uploaded on Day, midnight UTC
+type UserUploadUsage struct {
+	Uploads int64
+	Bytes   int64
+	Day     time.Time
+}
+
+// UserUploadQuota is a user's daily upload quota and what is left of it.
+// The limits and what remains are nil when unlimited.
+type UserUploadQuota struct {
+	UploadsToday     int64     `json:"uploads_today"`
+	BytesToday       int64     `json:"bytes_today"`
+	MaxUploadsPerDay *int64    `json:"max_uploads_per_day"`
+	MaxBytesPerDay   *int64    `json:"max_bytes_per_day"`
+	RemainingUploads *int64    `json:"remaining_uploads"`
+	RemainingBytes   *int64    `json:"remaining_bytes"`
+	ResetsAt         time.Time `json:"resets_at"`
+}
+
+// Quota reports usage against the limits
+func (l UploadLimits) Quota(usage UserUploadUsage) UserUploadQuota {
+	quota := UserUploadQuota{UploadsToday: usage.Uploads, BytesToday: usage.Bytes, ResetsAt: usage.Day.Add(24 * time.Hour)}
+	if l.UserUploadsPerDay > 0 {
+		limit, remaining := l.UserUploadsPerDay, max(l.UserUploadsPerDay-usage.Uploads, 0)
+		quota.MaxUploadsPerDay, quota.RemainingUploads = &limit, &remaining
+	}
+	if l.UserBytesPerDay > 0 {
+		limit, remaining := l.UserBytesPerDay, max(l.UserBytesPerDay-usage.Bytes, 0)
+		quota.MaxBytesPerDay, quota.RemainingBytes = &limit, &remaining
+	}
+	return quota
+}
+
+// ApplyUser returns a user's usage after uploads and bytes on day,
+// restarting the counts when day is later than the one counted, or a
+// *QuotaError if positive ones go past a limit. The count is checked before
+// the bytes.
+func (l UploadLimits) ApplyUser(usage UserUploadUsage, uploads, bytes int64, day time.Time) (UserUploadUsage, error) {
+	if usage.Day.Before(day) {
+		usage = UserUploadUsage{Day: day}
+	}
+	if uploads > 0 && l.UserUploadsPerDay > 0 && usage.Uploads+uploads > l.UserUploadsPerDay {
+		return usage, &QuotaError{Quota: QuotaUserUploadsPerDay, Limit: l.UserUploadsPerDay, Used: usage.Uploads}
+	}
+	if bytes > 0 && l.UserBytesPerDay > 0 && usage.Bytes+bytes > l.UserBytesPerDay {
+		return usage, &QuotaError{Quota: QuotaUserUploadBytesPerDay, Limit: l.UserBytesPerDay, Used: usage.Bytes}
+	}
+	usage.Uploads = max(usage.Uploads+uploads, 0)
+	usage.Bytes = max(usage.Bytes+bytes, 0)
+	return usage, nil
+}
+
+// TenantQuotas caps what a tenant may use; nil fields are unlimited
+type TenantQuotas struct {
+	MaxCourses       *int64 `json:"max_courses" validate:"omitnil,gte=0"`
+	MaxStorageBytes  *int64 `json:"max_storage_bytes" validate:"omitnil,gte=0"`
+	MaxUploadsPerDay *int64 `json:"max_uploads_per_day" validate:"omitnil,gte=0"`
+}
+
+// TenantUsage is what a tenant uses against its quotas. UploadsToday counts
+// the uploads of UploadDay, midnight UTC.
+type TenantUsage struct {
+	Courses      int64     `json:"courses"`
+	StorageBytes int64     `json:"storage_bytes"`
+	UploadsToday int64     `json:"uploads_today"`
+	UploadDay    time.Time `json:"-"`
+	DateUpdated  time.Time `json:"date_updated"`
+}
+
+// UsageDelta changes a tenant's usage. Positive fields are checked against
+// its quotas; negative ones give usage back and never fail.
+type UsageDelta struct {
+	Courses      int64
+	StorageBytes int64
+	Uploads      int64
+}
+
+// QuotaError reports a write that would go past a quota, and how much of
+// the quota is already used
+type QuotaError struct {
+	Quota string
+	Limit int64
+	Used  int64
+}
+
+// Remaining is how much of the quota is left
+func (e *QuotaError) Remaining() int64 {
+	return max(e.Limit-e.Used, 0)
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota %s of %d exceeded", e.Quota, e.Limit)
+}
+
+// UsageDay is the day uploads are counted against, midnight UTC
+func UsageDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// Apply returns usage after delta on day, restarting the upload count when
+// day is later than the one counted, or a *QuotaError if a positive delta
+// goes past a quota. Storage is checked before uploads.
+func (q TenantQuotas) Apply(usage TenantUsage, delta UsageDelta, day time.Time) (TenantUsage, error) {
+	if usage.UploadDay.Before(day) {
+		usage.UploadsToday = 0
+		usage.UploadDay = day
+	}
+	check := func(quota string, limit *int64, current, change int64) error {
+		if change > 0 && limit != nil && current+change > *limit {
+			return &QuotaError{Quota: quota, Limit: *limit, Used: current}
+		}
+		return nil
+	}
+	if err := check(QuotaCourses, q.MaxCourses, usage.Courses, delta.Courses); err != nil {
+		return usage, err
+	}
+	if err := check(QuotaStorageBytes, q.MaxStorageBytes, usage.StorageBytes, delta.StorageBytes); err != nil {
+		return usage, err
+	}
+	if err := check(QuotaUploadsPerDay, q.MaxUploadsPerDay, usage.UploadsToday, delta.Uploads); err != nil {
+		return usage, err
+	}
+
+	// Counters never go below zero, which a refund after the day rolled over would do
+	add := func(current, change int64) int64 {
+		return max(current+change, 0)
+	}
+	usage.Courses = add(usage.Courses, delta.Courses)
+	usage.StorageBytes = add(usage.StorageBytes, delta.StorageBytes)
+	usage.UploadsToday = add(usage.UploadsToday, delta.Uploads)
+	return usage, nil
+}
+
+// GetTenantUsage returns a tenant's usage, with today's upload count
+func GetTenantUsage(ctx context.Context, db DBTX, tenantID uuid.UUID) (*TenantUsage, error) {
+	query := `
+		SELECT courses, storage_bytes, uploads_today, upload_day, date_updated
+		FROM api.tenant_usage
+		WHERE tenant_id = $1
+	`
+
+	var usage TenantUsage
+	err := db.QueryRow(ctx, query, tenantID).Scan(&usage.Courses, &usage.StorageBytes, &usage.UploadsToday, &usage.UploadDay, &usage.DateUpdated)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if today := UsageDay(time.Now()); usage.UploadDay.Before(today) {
+		usage.UploadsToday = 0
+		usage.UploadDay = today
+	}
+	return &usage, nil
+}
+
+// ChargeTenantUsage applies delta to a tenant's usage, returning a
+// *QuotaError and changing nothing if that exceeds a quota. It locks the
+// usage row, so callers run it in the transaction of the write it counts.
+func ChargeTenantUsage(ctx context.Context, db DBTX, tenantID uuid.UUID, delta UsageDelta) error {
+	query := `
+		SELECT u.courses, u.storage_bytes, u.uploads_today, u.upload_day, t.max_courses, t.max_storage_bytes, t.max_uploads_per_day
+		FROM api.tenant_usage u
+		JOIN api.tenants t ON t.id = u.tenant_id
+		WHERE u.tenant_id = $1
+		FOR UPDATE OF u
+	`
+
+	var usage TenantUsage
+	var quotas TenantQuotas
+	err := db.QueryRow(ctx, query, tenantID).Scan(
+		&usage.Courses,
+		&usage.StorageBytes,
+		&usage.UploadsToday,
+		&usage.UploadDay,
+		&quotas.MaxCourses,
+		&quotas.MaxStorageBytes,
+		&quotas.MaxUploadsPerDay,
+	)
+	if err != nil {
+		return notFound(err)
+	}
+
+	usage, err = quotas.Apply(usage, delta, UsageDay(time.Now()))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, `
+		UPDATE api.tenant_usage
+		SET courses = $2, storage_bytes = $3, uploads_today = $4, upload_day = $5, date_updated = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1
+	`, tenantID, usage.Courses, usage.StorageBytes, usage.UploadsToday, usage.UploadDay)
+	return err
+}
+
+// ChargeCourseStorage adds sizeBytes to a course's storage, returning a
+// *QuotaError and changing nothing if that takes it past limit. Zero limit
+// is unlimited, and a negative sizeBytes gives storage back. The course row
+// stays locked until the surrounding transaction ends.
+func ChargeCourseStorage(ctx context.Context, db DBTX, tenantID, courseID uuid.UUID, sizeBytes, limit int64) error {
+	var used int64
+	err := db.QueryRow(ctx, "SELECT storage_bytes FROM api.courses WHERE id = $1 AND tenant_id = $2 FOR UPDATE", courseID, tenantID).Scan(&used)
+	if err != nil {
+		return notFound(err)
+	}
+	if sizeBytes > 0 && limit > 0 && used+sizeBytes > limit {
+		return &QuotaError{Quota: QuotaCourseStorageBytes, Limit: limit, Used: used}
+	}
+	_, err = db.Exec(ctx, "UPDATE api.courses SET storage_bytes = GREATEST(storage_bytes + $3, 0) WHERE id = $1 AND tenant_id = $2", courseID, tenantID, sizeBytes)
+	return err
+}
+
+// GetUserUploadUsage returns what a user has uploaded today, which is
+// nothing if they haven't uploaded since before today
+func GetUserUploadUsage(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) (*UserUploadUsage, error) {
+	today := UsageDay(time.Now())
+	usage := UserUploadUsage{Day: today}
+	err := db.QueryRow(ctx, `
+		SELECT uploads, bytes, upload_day FROM api.user_upload_usage
+		WHERE tenant_id = $1 AND user_id = $2
+	`, tenantID, userID).Scan(&usage.Uploads, &usage.Bytes, &usage.Day)
+	if err := notFound(err); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if usage.Day.Before(today) {
+		usage = UserUploadUsage{Day: today}
+	}
+	return &usage, nil
+}
+
+// ChargeUserUploads adds uploads and bytes to what a user has uploaded
+// today, returning a *QuotaError and changing nothing if that goes past
+// limits. Negative ones give usage back. Like ChargeTenantUsage it locks
+// the user's row until the surrounding transaction ends.
+func ChargeUserUploads(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, uploads, bytes int64, limits UploadLimits) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO api.user_upload_usage (tenant_id, user_id) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO NOTHING
+	`, tenantID, userID)
+	if err != nil {
+		return err
+	}
+
+	var usage UserUploadUsage
+	err = db.QueryRow(ctx, `
+		SELECT uploads, bytes, upload_day FROM api.user_upload_usage
+		WHERE tenant_id = $1 AND user_id = $2
+		FOR UPDATE
+	`, tenantID, userID).Scan(&usage.Uploads, &usage.Bytes, &usage.Day)
+	if err != nil {
+		return notFound(err)
+	}
+
+	usage, err = limits.ApplyUser(usage, uploads, bytes, UsageDay(time.Now()))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, `
+		UPDATE api.user_upload_usage
+		SET uploads = $3, bytes = $4, upload_day = $5, date_updated = CURRENT_TIMESTAMP
+		WHERE tenant_id = $1 AND user_id = $2
+	`, tenantID, userID, usage.Uploads, usage.Bytes, usage.Day)
+	return err
+}
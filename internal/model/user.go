@@ -2,17 +2,21 @@
 package model
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrInvalidCredentials is returned by AuthenticateUser for an unknown
+// username or a wrong password, which are deliberately indistinguishable
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
 type User struct {
 	ID             uuid.UUID `json:"id"`
 	FirstName      string    `json:"first_name"`
@@ -23,51 +27,47 @@ type User struct {
 	Email          string    `json:"email"`
 	AccountCreated time.Time `json:"account_created"`
 	AccountUpdated time.Time `json:"account_updated"`
+	// DeactivatedAt is when an admin deactivated the account, which can't
+	// log in or use its tokens until reactivated
+	DeactivatedAt *time.Time `json:"deactivated_at"`
+	// PasswordResetRequired keeps the user to changing their password
+	PasswordResetRequired bool `json:"password_reset_required"`
 }
 
 type CreateUserRequest struct {
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Username  string `json:"username"`
-	Password  string `json:"password"`
-	Role      string `json:"role"`
-	Email     string `json:"email"`
+	FirstName string `json:"first_name" validate:"required,max=50"`
+	LastName  string `json:"last_name" validate:"max=50"`
+	Username  string `json:"username" validate:"required,max=30"`
+	Password  string `json:"password" validate:"required"`
+	Role      string `json:"role" validate:"required,oneof=student admin instructor"`
+	Email     string `json:"email" validate:"required,max=100,email_format"`
 }
 
-type UpdateUserRequest struct {
-	FirstName string `json:"first_name,omitempty"`
-	LastName  string `json:"last_name,omitempty"`
-	Username  string `json:"username,omitempty"`
-	Password  string `json:"password,omitempty"`
+// AdminUpdateUserRequest is an admin's change to a user's account. Fields
+// left out are kept. Deactivating the account or requiring a password reset
+// also revokes the user's sessions, as RevokeSessions does on its own.
+type AdminUpdateUserRequest struct {
+	Role                 *string `json:"role,omitempty" validate:"omitnil,oneof=student admin instructor"`
+	Active               *bool   `json:"active,omitempty"`
+	RequirePasswordReset *bool   `json:"require_password_reset,omitempty"`
+	RevokeSessions       bool    `json:"revoke_sessions,omitempty"`
 }
 
-func (r *CreateUserRequest) Validate() error {
-	if r.FirstName == "" {
-		return errors.New("first name is required")
-	}
-	if r.Username == "" {
-		return errors.New("username is required")
-	}
-	if r.Password == "" {
-		return errors.New("password is required")
-	}
-	if r.Role != "student" && r.Role != "admin" && r.Role != "instructor" {
-		return errors.New("role must be student, admin, or instructor")
-	}
-	if r.Email == "" {
-		return errors.New("email is required")
-	}
-
-	// Email format validation using regex
-	emailRegex := regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`)
-	if !emailRegex.MatchString(r.Email) {
-		return errors.New("invalid email format")
-	}
+// RevokesSessions reports whether the change logs the user out everywhere
+func (req AdminUpdateUserRequest) RevokesSessions() bool {
+	return req.RevokeSessions ||
+		(req.Active != nil && !*req.Active) ||
+		(req.RequirePasswordReset != nil && *req.RequirePasswordReset)
+}
 
-	return nil
+type UpdateUserRequest struct {
+	FirstName string `json:"first_name,omitempty" validate:"max=50"`
+	LastName  string `json:"last_name,omitempty" validate:"max=50"`
+	Username  string `json:"username,omitempty" validate:"max=30"`
+	Password  string `json:"password,omitempty"`
 }
 
-func CreateUser(db *sql.DB, req CreateUserRequest) (*User, error) {
+func CreateUser(ctx context.Context, db DBTX, tenantID uuid.UUID, req CreateUserRequest) (*User, error) {
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -76,12 +76,13 @@ func CreateUser(db *sql.DB, req CreateUserRequest) (*User, error) {
 
 	var user User
 	query := `
-        INSERT INTO api.users (first_name, last_name, username, password, role, email)
-        VALUES ($1, $2, $3, $4, $5, $6)
-        RETURNING id, first_name, last_name, username, role, email, account_created, account_updated
+        INSERT INTO api.users (first_name, last_name, username, password, role, email, tenant_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, first_name, last_name, username, role, email, account_created, account_updated, deactivated_at, password_reset_required
     `
 
 	err = db.QueryRow(
+		ctx,
 		query,
 		req.FirstName,
 		req.LastName,
@@ -89,6 +90,7 @@ func CreateUser(db *sql.DB, req CreateUserRequest) (*User, error) {
 		string(hashedPassword),
 		req.Role,
 		req.Email,
+		tenantID,
 	).Scan(
 		&user.ID,
 		&user.FirstName,
@@ -98,6 +100,8 @@ func CreateUser(db *sql.DB, req CreateUserRequest) (*User, error) {
 		&user.Email,
 		&user.AccountCreated,
 		&user.AccountUpdated,
+		&user.DeactivatedAt,
+		&user.PasswordResetRequired,
 	)
 
 	if err != nil {
@@ -107,17 +111,17 @@ func CreateUser(db *sql.DB, req CreateUserRequest) (*User, error) {
 	return &user, nil
 }
 
-func AuthenticateUser(db *sql.DB, username, password string) (*User, error) {
+func AuthenticateUser(ctx context.Context, db DBTX, tenantID uuid.UUID, username, password string) (*User, error) {
 	var user User
 	var hashedPassword string
 
 	query := `
-        SELECT id, first_name, last_name, username, password, role, email, account_created, account_updated 
+        SELECT id, first_name, last_name, username, password, role, email, account_created, account_updated, deactivated_at, password_reset_required 
         FROM api.users 
-        WHERE username = $1
+        WHERE tenant_id = $1 AND username = $2
     `
 
-	err := db.QueryRow(query, username).Scan(
+	err := db.QueryRow(ctx, query, tenantID, username).Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
@@ -127,11 +131,13 @@ func AuthenticateUser(db *sql.DB, username, password string) (*User, error) {
 		&user.Email,
 		&user.AccountCreated,
 		&user.AccountUpdated,
+		&user.DeactivatedAt,
+		&user.PasswordResetRequired,
 	)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidCredentials
 		}
 		return nil, err
 	}
@@ -139,24 +145,24 @@ func AuthenticateUser(db *sql.DB, username, password string) (*User, error) {
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	if err != nil {
-		return nil, errors.New("invalid password")
+		return nil, ErrInvalidCredentials
 	}
 
 	return &user, nil
 }
 
-func UpdateUser(db *sql.DB, userID uuid.UUID, req UpdateUserRequest) (*User, error) {
+func UpdateUser(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, req UpdateUserRequest) (*User, error) {
 	// Start a transaction
-	tx, err := db.Begin()
+	tx, err := db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback()
+	defer tx.Rollback(ctx)
 
 	// Build the update query dynamically based on which fields are provided
 	query := "UPDATE api.users SET"
-	args := []interface{}{userID}
-	argIndex := 2 // Start at 2 because userID is $1
+	args := []interface{}{userID, tenantID}
+	argIndex := 3 // Start at 3 because userID is $1 and tenantID is $2
 
 	// Track if we need to add fields
 	var updates []string
@@ -188,6 +194,8 @@ func UpdateUser(db *sql.DB, userID uuid.UUID, req UpdateUserRequest) (*User, err
 		updates = append(updates, fmt.Sprintf(" password = $%d", argIndex))
 		args = append(args, string(hashedPassword))
 		argIndex++
+		// A new password satisfies a required reset
+		updates = append(updates, " password_reset_required = false")
 	}
 
 	// Add account_updated timestamp
@@ -195,16 +203,16 @@ func UpdateUser(db *sql.DB, userID uuid.UUID, req UpdateUserRequest) (*User, err
 
 	// If no fields to update, return the current user
 	if len(updates) == 1 { // Only timestamp update
-		return GetUserByID(db, userID)
+		return GetUserByID(ctx, db, tenantID, userID)
 	}
 
 	// Complete the query
 	query += strings.Join(updates, ",")
-	query += " WHERE id = $1 RETURNING id, first_name, last_name, username, role, email, account_created, account_updated"
+	query += " WHERE id = $1 AND tenant_id = $2 RETURNING id, first_name, last_name, username, role, email, account_created, account_updated, deactivated_at, password_reset_required"
 
 	// Execute the update
 	var user User
-	err = tx.QueryRow(query, args...).Scan(
+	err = tx.QueryRow(ctx, query, args...).Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
@@ -213,6 +221,8 @@ func UpdateUser(db *sql.DB, userID uuid.UUID, req UpdateUserRequest) (*User, err
 		&user.Email,
 		&user.AccountCreated,
 		&user.AccountUpdated,
+		&user.DeactivatedAt,
+		&user.PasswordResetRequired,
 	)
 
 	if err != nil {
@@ -220,7 +230,7 @@ func UpdateUser(db *sql.DB, userID uuid.UUID, req UpdateUserRequest) (*User, err
 	}
 
 	// Commit the transaction
-	if err = tx.Commit(); err != nil {
+	if err = tx.Commit(ctx); err != nil {
 		return nil, err
 	}
 
@@ -228,16 +238,16 @@ func UpdateUser(db *sql.DB, userID uuid.UUID, req UpdateUserRequest) (*User, err
 }
 
 // helper function to get a user by ID
-func GetUserByID(db *sql.DB, userID uuid.UUID) (*User, error) {
+func GetUserByID(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) (*User, error) {
 	var user User
 
 	query := `
-        SELECT id, first_name, last_name, username, role, email, account_created, account_updated 
+        SELECT id, first_name, last_name, username, role, email, account_created, account_updated, deactivated_at, password_reset_required 
         FROM api.users 
-        WHERE id = $1
+        WHERE id = $1 AND tenant_id = $2
     `
 
-	err := db.QueryRow(query, userID).Scan(
+	err := db.QueryRow(ctx, query, userID, tenantID).Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
@@ -246,11 +256,124 @@ func GetUserByID(db *sql.DB, userID uuid.UUID) (*User, error) {
 		&user.Email,
 		&user.AccountCreated,
 		&user.AccountUpdated,
+		&user.DeactivatedAt,
+		&user.PasswordResetRequired,
 	)
 
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 
 	return &user, nil
 }
+
+// GetUserByUsername looks a user up by login name, for operator commands
+func GetUserByUsername(ctx context.Context, db DBTX, tenantID uuid.UUID, username string) (*User, error) {
+	var user User
+	query := `
+        SELECT id, first_name, last_name, username, role, email, account_created, account_updated, deactivated_at, password_reset_required
+        FROM api.users
+        WHERE tenant_id = $1 AND username = $2
+    `
+	err := db.QueryRow(ctx, query, tenantID, username).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Username,
+		&user.Role,
+		&user.Email,
+		&user.AccountCreated,
+		&user.AccountUpdated,
+		&user.DeactivatedAt,
+		&user.PasswordResetRequired,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+// userListSpec is the ?sort= and ?fields= allowlist for user listings. The
+// password hash is deliberately absent.
+var userListSpec = &listSpec[User]{
+	table: "api.users",
+	columns: map[string]listColumn[User]{
+		"id":                      {"id", kindUUID, true, func(u *User) any { return &u.ID }},
+		"first_name":              {"first_name", kindString, true, func(u *User) any { return &u.FirstName }},
+		"last_name":               {"last_name", kindString, false, func(u *User) any { return &u.LastName }},
+		"username":                {"username", kindString, true, func(u *User) any { return &u.Username }},
+		"role":                    {"role", kindString, true, func(u *User) any { return &u.Role }},
+		"email":                   {"email", kindString, true, func(u *User) any { return &u.Email }},
+		"account_created":         {"account_created", kindTime, true, func(u *User) any { return &u.AccountCreated }},
+		"account_updated":         {"account_updated", kindTime, true, func(u *User) any { return &u.AccountUpdated }},
+		"deactivated_at":          {"deactivated_at", kindTime, false, func(u *User) any { return &u.DeactivatedAt }},
+		"password_reset_required": {"password_reset_required", kindString, false, func(u *User) any { return &u.PasswordResetRequired }},
+	},
+	aliases:     map[string]string{"created_at": "account_created", "updated_at": "account_updated"},
+	defaultSort: []SortField{{Field: "account_created", Desc: true}},
+}
+
+// ListUsers returns one page of users, newest accounts first unless opts.Sort says otherwise
+func ListUsers(ctx context.Context, db DBTX, tenantID uuid.UUID, opts ListOptions) (*Page[User], error) {
+	return list(ctx, db, userListSpec, "tenant_id = $1", []any{tenantID}, opts)
+}
+
+// AdminUpdateUser applies an admin's change to one of the tenant's users
+func AdminUpdateUser(ctx context.Context, db DBTX, tenantID, userID uuid.UUID, req AdminUpdateUserRequest) (*User, error) {
+	query := `
+		UPDATE api.users
+		SET role = COALESCE($3, role),
+			deactivated_at = CASE
+				WHEN $4::boolean IS NULL THEN deactivated_at
+				WHEN $4 THEN NULL
+				ELSE COALESCE(deactivated_at, CURRENT_TIMESTAMP)
+			END,
+			password_reset_required = COALESCE($5, password_reset_required),
+			account_updated = CURRENT_TIMESTAMP
+		WHERE id = $1 AND tenant_id = $2
+		RETURNING id, first_name, last_name, username, role, email, account_created, account_updated, deactivated_at, password_reset_required
+	`
+
+	var user User
+	err := db.QueryRow(ctx, query, userID, tenantID, req.Role, req.Active, req.RequirePasswordReset).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Username,
+		&user.Role,
+		&user.Email,
+		&user.AccountCreated,
+		&user.AccountUpdated,
+		&user.DeactivatedAt,
+		&user.PasswordResetRequired,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+// UserUpdatedAuditEntry records adminID changing a user's account, and
+// whether the change revoked their sessions
+func UserUpdatedAuditEntry(before, after *User, sessionsRevoked bool, adminID uuid.UUID) AuditEntry {
+	changes := map[string]any{}
+	if before.Role != after.Role {
+		changes["role"] = FieldChange{Old: before.Role, New: after.Role}
+	}
+	if (before.DeactivatedAt == nil) != (after.DeactivatedAt == nil) {
+		changes["deactivated_at"] = FieldChange{Old: before.DeactivatedAt, New: after.DeactivatedAt}
+	}
+	if before.PasswordResetRequired != after.PasswordResetRequired {
+		changes["password_reset_required"] = FieldChange{Old: before.PasswordResetRequired, New: after.PasswordResetRequired}
+	}
+	if sessionsRevoked {
+		changes["sessions_revoked"] = true
+	}
+	return AuditEntry{
+		UserID:     adminID,
+		Action:     "user.updated",
+		EntityType: "user",
+		EntityID:   after.ID,
+		Changes:    changes,
+	}
+}
@@ -0,0 +1,181 @@
+// internal/model/userdata.go
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// UserData is the personal data held about a user: the account, the courses
+// they created, the traces they uploaded, the comments they left, the IDs
+// of the courses they saved as favorites and their profile picture, if any
+type UserData struct {
+	User      *User          `json:"user"`
+	Courses   []Course       `json:"courses"`
+	Traces    []UserTrace    `json:"traces"`
+	Comments  []TraceComment `json:"comments"`
+	Favorites []uuid.UUID    `json:"favorites"`
+	Avatar    *UserAvatar    `json:"avatar"`
+}
+
+// UserTrace is a trace with the course it belongs to and the files it had
+// before its current one
+type UserTrace struct {
+	Trace
+	CourseID  uuid.UUID       `json:"course_id"`
+	Revisions []TraceRevision `json:"revisions,omitempty"`
+}
+
+// Erased account values. The username and email are derived from the user's
+// ID so they stay unique, and the password is not a bcrypt hash, so no
+// password matches it.
+const (
+	ErasedFirstName = "Erased"
+	ErasedLastName  = "User"
+	ErasedPassword  = "!"
+)
+
+func ErasedUsername(userID uuid.UUID) string {
+	return ("erased-" + strings.ReplaceAll(userID.String(), "-", ""))[:30]
+}
+
+func ErasedEmail(userID uuid.UUID) string {
+	return "erased-" + userID.String() + "@erased.invalid"
+}
+
+// GetUserData collects a user's account, courses, traces, comments and
+// favorites
+func GetUserData(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) (*UserData, error) {
+	user, err := GetUserByID(ctx, db, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	data := &UserData{User: user, Courses: []Course{}, Traces: []UserTrace{}}
+
+	courses, err := db.Query(ctx, `
+		SELECT id, name, semester_term, credit_hours, subject_code, course_id,
+		semester_year, date_created, date_updated, user_id, instructor_id, storage_bytes, archived_at
+		FROM api.courses
+		WHERE tenant_id = $1 AND user_id = $2
+		ORDER BY date_created
+	`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer courses.Close()
+	for courses.Next() {
+		var c Course
+		err := courses.Scan(&c.ID, &c.Name, &c.SemesterTerm, &c.CreditHours, &c.SubjectCode, &c.CourseID,
+			&c.SemesterYear, &c.DateCreated, &c.DateUpdated, &c.UserID, &c.InstructorID, &c.StorageBytes, &c.ArchivedAt)
+		if err != nil {
+			return nil, err
+		}
+		data.Courses = append(data.Courses, c)
+	}
+	if err := courses.Err(); err != nil {
+		return nil, err
+	}
+
+	traces, err := db.Query(ctx, `
+		SELECT id, user_id, instructor_id, status, vector_id, file_name, bucket_url, storage_tier, content_type, size_bytes, visibility, link_url, publish_status, archived_at, date_created, date_updated, course_id
+		FROM api.traces
+		WHERE tenant_id = $1 AND user_id = $2
+		ORDER BY date_created
+	`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer traces.Close()
+	for traces.Next() {
+		var t UserTrace
+		err := traces.Scan(&t.ID, &t.UserID, &t.InstructorID, &t.Status, &t.VectorID, &t.FileName, &t.BucketURL,
+			&t.StorageTier, &t.ContentType, &t.SizeBytes, &t.Visibility, &t.LinkURL, &t.PublishStatus, &t.ArchivedAt, &t.DateCreated, &t.DateUpdated, &t.CourseID)
+		if err != nil {
+			return nil, err
+		}
+		data.Traces = append(data.Traces, t)
+	}
+	if err := traces.Err(); err != nil {
+		return nil, err
+	}
+	traceIDs := make([]uuid.UUID, len(data.Traces))
+	for i, t := range data.Traces {
+		traceIDs[i] = t.ID
+	}
+	revisions, err := TraceRevisionsOf(ctx, db, traceIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range data.Traces {
+		data.Traces[i].Revisions = revisions[data.Traces[i].ID]
+	}
+
+	if data.Comments, err = GetUserTraceComments(ctx, db, tenantID, userID); err != nil {
+		return nil, err
+	}
+	if data.Favorites, err = GetFavoriteCourseIDs(ctx, db, tenantID, userID); err != nil {
+		return nil, err
+	}
+	if data.Avatar, err = GetUserAvatar(ctx, db, tenantID, userID); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// AnonymizeUser replaces a user's name, username, email and password with
+// the erased values
+func AnonymizeUser(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) error {
+	result, err := db.Exec(ctx, `
+		UPDATE api.users
+		SET first_name = $3, last_name = $4, username = $5, email = $6, password = $7, account_updated = CURRENT_TIMESTAMP
+		WHERE id = $1 AND tenant_id = $2
+	`, userID, tenantID, ErasedFirstName, ErasedLastName, ErasedUsername(userID), ErasedEmail(userID), ErasedPassword)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUserTraces deletes the traces a user uploaded and returns the bytes
+// freed in each course, earlier revisions included, for the storage usage
+func DeleteUserTraces(ctx context.Context, db DBTX, tenantID, userID uuid.UUID) (map[uuid.UUID]int64, error) {
+	rows, err := db.Query(ctx, "DELETE FROM api.traces WHERE tenant_id = $1 AND user_id = $2 RETURNING course_id, size_bytes + "+revisionBytes, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	freed := map[uuid.UUID]int64{}
+	for rows.Next() {
+		var courseID uuid.UUID
+		var sizeBytes int64
+		if err := rows.Scan(&courseID, &sizeBytes); err != nil {
+			return nil, err
+		}
+		freed[courseID] += sizeBytes
+	}
+	return freed, rows.Err()
+}
+
+// TraceFileInUse reports whether a trace, or an earlier revision of one,
+// that userID did not upload still stores its file under objectName. Every
+// tenant is checked, since tenants without a bucket prefix share one set of
+// object names.
+func TraceFileInUse(ctx context.Context, db DBTX, userID uuid.UUID, objectName string) (bool, error) {
+	var inUse bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM api.traces WHERE file_name = $2 AND user_id <> $1
+		) OR EXISTS (
+			SELECT 1 FROM api.trace_revisions r JOIN api.traces t ON t.id = r.trace_id
+			WHERE r.file_name = $2 AND t.user_id <> $1
+		)
+	`, userID, objectName).Scan(&inUse)
+	return inUse, err
+}
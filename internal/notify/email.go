@@ -0,0 +1,25 @@
+// internal/notify/email.go
+package notify
+
+import (
+	"api-server/internal/mailer"
+	"context"
+)
+
+// Email sends alerts to a fixed list of recipients through a mail driver
+type Email struct {
+	driver mailer.Driver
+	to     []string
+}
+
+func NewEmail(driver mailer.Driver, to []string) *Email {
+	return &Email{driver: driver, to: to}
+}
+
+func (e *Email) Name() string {
+	return "email"
+}
+
+func (e *Email) Send(ctx context.Context, alert Alert) error {
+	return e.driver.Send(ctx, mailer.Message{To: e.to, Subject: alert.Subject, Text: alert.Text})
+}
@@ -0,0 +1,141 @@
+// internal/notify/notify.go
+package notify
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/logging"
+	"api-server/internal/mailer"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Events an alert can be raised for, each enabled unless NOTIFY_EVENTS turns
+// it off
+const (
+	EventUploadFailed  = "upload_failed"
+	EventOutboxBacklog = "outbox_backlog"
+	EventDeadLetter    = "dead_letter"
+)
+
+// sendTimeout bounds each delivery attempt, so a slow webhook or mail
+// server can't pile up goroutines
+const sendTimeout = 10 * time.Second
+
+// Alert is one notification
+type Alert struct {
+	Event   string
+	Subject string
+	Text    string
+}
+
+// Sender delivers alerts to one channel, such as a Slack webhook or a
+// mailbox
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, alert Alert) error
+}
+
+// Notifier sends operational alerts to every configured channel. Each event
+// type has its own rate limit; alerts over it are dropped and counted in the
+// next one that goes out.
+type Notifier struct {
+	senders  []Sender
+	disabled map[string]bool
+	every    time.Duration
+	burst    int
+
+	mu         sync.Mutex
+	limiters   map[string]*rate.Limiter
+	suppressed map[string]int
+}
+
+// New builds a Notifier from the NOTIFY_* settings. Without a Slack webhook
+// or email recipients, alerts are only logged.
+func New(cfg *config.Config) *Notifier {
+	var senders []Sender
+	if cfg.NotifySlackWebhookURL != "" {
+		senders = append(senders, NewSlack(cfg.NotifySlackWebhookURL))
+	}
+	if len(cfg.NotifyEmailTo) > 0 {
+		smtp := mailer.NewSMTP(cfg.NotifySMTPAddr, cfg.NotifySMTPUsername, cfg.NotifySMTPPassword, cfg.NotifyEmailFrom)
+		senders = append(senders, NewEmail(smtp, cfg.NotifyEmailTo))
+	}
+	return NewWithSenders(senders, cfg.NotifyEvents, cfg.NotifyMinInterval, cfg.NotifyBurst)
+}
+
+// NewWithSenders builds a Notifier around the given channels. events turns
+// event types on or off; each may send burst alerts at once and then one
+// per every.
+func NewWithSenders(senders []Sender, events map[string]bool, every time.Duration, burst int) *Notifier {
+	disabled := map[string]bool{}
+	for event, enabled := range events {
+		disabled[event] = !enabled
+	}
+	return &Notifier{
+		senders:    senders,
+		disabled:   disabled,
+		every:      every,
+		burst:      max(burst, 1),
+		limiters:   map[string]*rate.Limiter{},
+		suppressed: map[string]int{},
+	}
+}
+
+// Enabled reports whether alerts for event are sent anywhere
+func (n *Notifier) Enabled(event string) bool {
+	return n != nil && len(n.senders) > 0 && !n.disabled[event]
+}
+
+// Notify sends an alert for event in the background, unless the event type
+// is disabled or over its rate limit. The text is redacted like log output.
+func (n *Notifier) Notify(event, subject, format string, args ...any) {
+	if !n.Enabled(event) {
+		return
+	}
+	suppressed, ok := n.allow(event)
+	if !ok {
+		return
+	}
+	alert := Alert{
+		Event:   event,
+		Subject: subject,
+		Text:    logging.Redact(fmt.Sprintf(format, args...)),
+	}
+	if suppressed > 0 {
+		alert.Text += fmt.Sprintf("\n\n%d similar alerts were suppressed since the last one.", suppressed)
+	}
+	for _, sender := range n.senders {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := sender.Send(ctx, alert); err != nil {
+				log.Printf("Failed to send %s alert to %s: %v", event, sender.Name(), err)
+			}
+		}()
+	}
+}
+
+// allow takes a token from event's bucket, returning how many alerts were
+// dropped since the last one allowed
+func (n *Notifier) allow(event string) (int, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	limiter, ok := n.limiters[event]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(n.every), n.burst)
+		n.limiters[event] = limiter
+	}
+	if !limiter.Allow() {
+		n.suppressed[event]++
+		return 0, false
+	}
+	suppressed := n.suppressed[event]
+	delete(n.suppressed, event)
+	return suppressed, true
+}
@@ -0,0 +1,51 @@
+// internal/notify/slack.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Slack posts alerts to a Slack incoming webhook
+type Slack struct {
+	webhookURL string
+	client     *http.Client
+}
+
+func NewSlack(webhookURL string) *Slack {
+	return &Slack{webhookURL: webhookURL, client: &http.Client{Timeout: sendTimeout}}
+}
+
+func (s *Slack) Name() string {
+	return "slack"
+}
+
+func (s *Slack) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", alert.Subject, alert.Text),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The error names the webhook URL, which is itself the credential
+		return fmt.Errorf("failed to post to Slack webhook")
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
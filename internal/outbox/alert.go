@@ -0,0 +1,79 @@
+// internal/outbox/alert.go
+package outbox
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/notify"
+	"api-server/internal/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// BacklogMonitor alerts when pending events pile up past a threshold, and
+// when events are given up on and parked as failed, the outbox's dead
+// letters
+type BacklogMonitor struct {
+	repo      repository.Repository
+	notifier  *notify.Notifier
+	interval  time.Duration
+	threshold int
+
+	// lastFailed is the failed count at the previous check, or -1 before
+	// the first, so events that failed before startup aren't reported
+	lastFailed int
+}
+
+func NewBacklogMonitor(repo repository.Repository, notifier *notify.Notifier, cfg *config.Config) *BacklogMonitor {
+	return &BacklogMonitor{
+		repo:       repo,
+		notifier:   notifier,
+		interval:   cfg.NotifyCheckInterval,
+		threshold:  cfg.NotifyBacklogThreshold,
+		lastFailed: -1,
+	}
+}
+
+// Run checks the backlog every interval until ctx is cancelled. It returns
+// at once when neither alert is enabled.
+func (m *BacklogMonitor) Run(ctx context.Context) {
+	if !m.notifier.Enabled(notify.EventOutboxBacklog) && !m.notifier.Enabled(notify.EventDeadLetter) {
+		return
+	}
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *BacklogMonitor) check(ctx context.Context) {
+	backlog, err := m.repo.GetOutboxBacklog(ctx)
+	if err != nil {
+		log.Printf("Failed to check outbox backlog: %v", err)
+		return
+	}
+
+	if m.threshold > 0 && backlog.Pending >= m.threshold {
+		age := "unknown"
+		if backlog.OldestPending != nil {
+			age = time.Since(*backlog.OldestPending).Round(time.Second).String()
+		}
+		m.notifier.Notify(notify.EventOutboxBacklog, "Outbox backlog above threshold",
+			"%d outbox events are waiting to be published (threshold %d); the oldest has waited %s. Kafka may be unreachable.",
+			backlog.Pending, m.threshold, age)
+	}
+
+	if m.lastFailed >= 0 && backlog.Failed > m.lastFailed {
+		m.notifier.Notify(notify.EventDeadLetter, "Outbox events given up on",
+			"%d outbox events failed to publish after every attempt and were parked as failed (%d in total). They are not retried.",
+			backlog.Failed-m.lastFailed, backlog.Failed)
+	}
+	m.lastFailed = backlog.Failed
+}
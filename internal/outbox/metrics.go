@@ -0,0 +1,60 @@
+// internal/outbox/metrics.go
+package outbox
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// backlogQueryTimeout bounds the count taken on each scrape
+const backlogQueryTimeout = 5 * time.Second
+
+// BacklogCollector exports the outbox backlog, counted when scraped, so an
+// alert can fire when events pile up behind a Kafka outage
+type BacklogCollector struct {
+	repo repository.Repository
+
+	events        *prometheus.Desc
+	oldestPending *prometheus.Desc
+	traces        *prometheus.Desc
+}
+
+func NewBacklogCollector(repo repository.Repository) *BacklogCollector {
+	return &BacklogCollector{
+		repo:          repo,
+		events:        prometheus.NewDesc("outbox_events", "Outbox events waiting to be published (pending) or given up on (failed).", []string{"status"}, nil),
+		oldestPending: prometheus.NewDesc("outbox_oldest_pending_age_seconds", "Age of the oldest pending outbox event, or 0 when none are pending.", nil, nil),
+		traces:        prometheus.NewDesc("traces_unpublished", "Uploaded traces whose event is waiting to be published (publish_pending) or was given up on (publish_failed).", []string{"publish_status"}, nil),
+	}
+}
+
+func (c *BacklogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.events
+	ch <- c.oldestPending
+	ch <- c.traces
+}
+
+func (c *BacklogCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), backlogQueryTimeout)
+	defer cancel()
+
+	backlog, err := c.repo.GetOutboxBacklog(ctx)
+	if err != nil {
+		log.Printf("Failed to count outbox backlog: %v", err)
+		return
+	}
+	age := 0.0
+	if backlog.OldestPending != nil {
+		age = time.Since(*backlog.OldestPending).Seconds()
+	}
+	ch <- prometheus.MustNewConstMetric(c.events, prometheus.GaugeValue, float64(backlog.Pending), "pending")
+	ch <- prometheus.MustNewConstMetric(c.events, prometheus.GaugeValue, float64(backlog.Failed), "failed")
+	ch <- prometheus.MustNewConstMetric(c.oldestPending, prometheus.GaugeValue, age)
+	ch <- prometheus.MustNewConstMetric(c.traces, prometheus.GaugeValue, float64(backlog.TracesPending), model.PublishStatusPending)
+	ch <- prometheus.MustNewConstMetric(c.traces, prometheus.GaugeValue, float64(backlog.TracesFailed), model.PublishStatusFailed)
+}
@@ -0,0 +1,83 @@
+// internal/outbox/relay.go
+package outbox
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/errortracking"
+	"api-server/internal/model"
+	"api-server/internal/publisher"
+	"api-server/internal/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// Relay publishes pending outbox events written by request handlers. Events
+// are committed together with the rows they describe, so a crash or broker
+// outage between the write and the publish only delays delivery.
+type Relay struct {
+	repo        repository.Repository
+	publisher   publisher.Publisher
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	wake        chan struct{}
+}
+
+func NewRelay(repo repository.Repository, pub publisher.Publisher, cfg *config.Config) *Relay {
+	return &Relay{
+		repo:        repo,
+		publisher:   pub,
+		interval:    cfg.OutboxPollInterval,
+		batchSize:   cfg.OutboxBatchSize,
+		maxAttempts: cfg.OutboxMaxAttempts,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Notify asks the relay to dispatch pending events now instead of waiting for the next poll
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches pending events until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+
+		// Keep draining while full batches are published
+		for {
+			n, err := r.dispatch(ctx)
+			if err != nil {
+				log.Printf("Outbox dispatch failed: %v", err)
+				errortracking.Capture(err, "outbox", nil)
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// dispatch publishes one batch of pending events and returns how many were published
+func (r *Relay) dispatch(ctx context.Context) (int, error) {
+	return r.repo.DispatchOutbox(ctx, r.batchSize, r.maxAttempts, func(event model.OutboxEvent) error {
+		err := r.publisher.Publish(ctx, event.Topic, event.Payload)
+		if err != nil {
+			log.Printf("Failed to publish outbox event %s to %s: %v", event.ID, event.Topic, err)
+		}
+		return err
+	})
+}
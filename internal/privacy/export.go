@@ -0,0 +1,128 @@
+// internal/privacy/export.go
+package privacy
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/storage"
+	"api-server/internal/tenant"
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"time"
+)
+
+// export writes the user's data to a zip archive in storage and records it
+// as the job's result object
+func (r *Runner) export(ctx context.Context, job *model.DataJob) error {
+	data, err := r.repo.GetUserData(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Stream the archive into storage rather than building it in memory
+	name := tenant.ObjectName(ctx, path.Join("exports", job.ID.String()+".zip"))
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(r.writeArchive(ctx, pw, job, data))
+	}()
+	_, err = r.storage.Upload(ctx, name, pr)
+	pr.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("failed to store export: %w", err)
+	}
+
+	job.ResultObject = &name
+	return nil
+}
+
+// manifest describes an export archive
+type manifest struct {
+	JobID       string    `json:"job_id"`
+	UserID      string    `json:"user_id"`
+	Tenant      string    `json:"tenant"`
+	GeneratedAt time.Time `json:"generated_at"`
+	// Missing lists traces whose object could not be found in storage
+	Missing []string `json:"missing"`
+}
+
+// writeArchive writes manifest.json, user.json, courses.json and
+// traces.json, each stored trace file under traces/<trace id>/ and the
+// user's profile picture as avatar.png
+func (r *Runner) writeArchive(ctx context.Context, w io.Writer, job *model.DataJob, data *model.UserData) error {
+	zw := zip.NewWriter(w)
+	m := manifest{
+		JobID:       job.ID.String(),
+		UserID:      job.UserID.String(),
+		Tenant:      tenant.Slug(ctx),
+		GeneratedAt: time.Now().UTC(),
+		Missing:     []string{},
+	}
+
+	for _, trace := range data.Traces {
+		if trace.BucketURL == "" {
+			continue
+		}
+		err := r.copyObject(ctx, zw, path.Join("traces", trace.ID.String(), path.Base(trace.FileName)), trace.FileName)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Export %s: object of trace %s not found", job.ID, trace.ID)
+			m.Missing = append(m.Missing, trace.ID.String())
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to export trace %s: %w", trace.ID, err)
+		}
+	}
+
+	if data.Avatar != nil {
+		err := r.copyObject(ctx, zw, "avatar.png", data.Avatar.ObjectName)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Export %s: profile picture not found", job.ID)
+		} else if err != nil {
+			return fmt.Errorf("failed to export profile picture: %w", err)
+		}
+	}
+
+	files := []struct {
+		name  string
+		value any
+	}{
+		{"manifest.json", m},
+		{"user.json", data.User},
+		{"courses.json", data.Courses},
+		{"traces.json", data.Traces},
+		{"comments.json", data.Comments},
+		{"favorites.json", data.Favorites},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.value); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (r *Runner) copyObject(ctx context.Context, zw *zip.Writer, entry, object string) error {
+	rc, err := r.storage.Download(ctx, object)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	fw, err := zw.Create(entry)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, rc)
+	return err
+}
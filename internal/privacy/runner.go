@@ -0,0 +1,233 @@
+// internal/privacy/runner.go
+package privacy
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/errortracking"
+	"api-server/internal/model"
+	"api-server/internal/outbox"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"api-server/internal/tenant"
+	"api-server/internal/thumbnail"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Topic is where the runner announces finished jobs, through the outbox
+const Topic = "user-data-job"
+
+// Runner executes the personal data export and erasure jobs requested
+// through the API. A job is retried until it has been attempted maxAttempts
+// times; a runner that dies mid-job leaves it to be claimed again once its
+// lease runs out.
+type Runner struct {
+	repo        repository.Repository
+	storage     storage.Storage
+	relay       *outbox.Relay
+	interval    time.Duration
+	lease       time.Duration
+	maxAttempts int
+	wake        chan struct{}
+}
+
+func NewRunner(repo repository.Repository, store storage.Storage, relay *outbox.Relay, cfg *config.Config) *Runner {
+	return &Runner{
+		repo:        repo,
+		storage:     store,
+		relay:       relay,
+		interval:    cfg.DataJobPollInterval,
+		lease:       cfg.DataJobLease,
+		maxAttempts: cfg.DataJobMaxAttempts,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Notify asks the runner to look for jobs now instead of waiting for the next poll
+func (r *Runner) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run executes jobs until ctx is cancelled
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+
+		// Keep going while there are jobs to run
+		for {
+			ran, err := r.runNext(ctx)
+			if err != nil {
+				log.Printf("Data job run failed: %v", err)
+				errortracking.Capture(err, "data-jobs", nil)
+				break
+			}
+			if !ran {
+				break
+			}
+		}
+	}
+}
+
+// runNext claims and executes one job, reporting whether there was one
+func (r *Runner) runNext(ctx context.Context) (bool, error) {
+	job, err := r.repo.ClaimDataJob(ctx, r.lease)
+	if errors.Is(err, model.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	t, err := r.repo.GetTenantByID(ctx, job.TenantID)
+	if err != nil {
+		return true, fmt.Errorf("failed to load tenant of data job %s: %w", job.ID, err)
+	}
+	ctx = tenant.NewContext(ctx, t)
+
+	// A job claimed again after its lease ran out may already have used up its attempts
+	err = errors.New("gave up after the job's lease ran out")
+	if job.Attempts <= r.maxAttempts {
+		switch job.Kind {
+		case model.DataJobExport:
+			err = r.export(ctx, job)
+		case model.DataJobErase:
+			err = r.erase(ctx, job)
+		default:
+			err = fmt.Errorf("unknown data job kind %q", job.Kind)
+		}
+	}
+
+	if err != nil {
+		log.Printf("Data job %s (%s of user %s) attempt %d failed: %v", job.ID, job.Kind, job.UserID, job.Attempts, err)
+		errortracking.Capture(err, "data-jobs", map[string]string{
+			"job_id":   job.ID.String(),
+			"job_kind": job.Kind,
+			"attempt":  strconv.Itoa(job.Attempts),
+			"tenant":   t.Slug,
+		})
+		message := err.Error()
+		job.Error = &message
+		if job.Attempts < r.maxAttempts {
+			job.Status = model.DataJobPending
+			return true, r.repo.FinishDataJob(ctx, *job, "", nil)
+		}
+		job.Status = model.DataJobFailed
+	} else {
+		log.Printf("Data job %s (%s of user %s) completed", job.ID, job.Kind, job.UserID)
+		job.Error = nil
+		job.Status = model.DataJobCompleted
+	}
+
+	payload, err := json.Marshal(jobEvent{
+		JobID:       job.ID,
+		Kind:        job.Kind,
+		Status:      job.Status,
+		Tenant:      t.Slug,
+		UserID:      job.UserID,
+		RequestedBy: job.RequestedBy,
+		Error:       job.Error,
+	})
+	if err != nil {
+		return true, err
+	}
+	if err := r.repo.FinishDataJob(ctx, *job, Topic, payload); err != nil {
+		return true, err
+	}
+	r.relay.Notify()
+	return true, nil
+}
+
+// jobEvent is the completion notification published on Topic
+type jobEvent struct {
+	JobID       uuid.UUID `json:"job_id"`
+	Kind        string    `json:"kind"`
+	Status      string    `json:"status"`
+	Tenant      string    `json:"tenant"`
+	UserID      uuid.UUID `json:"user_id"`
+	RequestedBy uuid.UUID `json:"requested_by"`
+	Error       *string   `json:"error"`
+}
+
+// erase deletes the user's trace objects, profile picture and export
+// archives, then anonymizes the account and forgets the traces. Trace files
+// another user's trace still points at are kept. Deleting is idempotent, so
+// a retry after a partial failure picks up where the last attempt stopped.
+func (r *Runner) erase(ctx context.Context, job *model.DataJob) error {
+	data, err := r.repo.GetUserData(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+	for _, trace := range data.Traces {
+		if trace.BucketURL == "" {
+			continue
+		}
+		if err := r.deleteTraceFile(ctx, job.UserID, trace.FileName); err != nil {
+			return fmt.Errorf("failed to delete trace %s: %w", trace.ID, err)
+		}
+		if err := r.storage.Delete(ctx, thumbnail.ObjectName(trace.FileName, trace.ID)); err != nil {
+			return fmt.Errorf("failed to delete thumbnail of trace %s: %w", trace.ID, err)
+		}
+		for _, revision := range trace.Revisions {
+			if revision.BucketURL == "" {
+				continue
+			}
+			if err := r.deleteTraceFile(ctx, job.UserID, revision.FileName); err != nil {
+				return fmt.Errorf("failed to delete revision %d of trace %s: %w", revision.Revision, trace.ID, err)
+			}
+		}
+	}
+
+	if data.Avatar != nil {
+		if err := r.storage.Delete(ctx, data.Avatar.ObjectName); err != nil {
+			return fmt.Errorf("failed to delete profile picture: %w", err)
+		}
+	}
+
+	jobs, err := r.repo.ListDataJobs(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		if j.ResultObject == nil {
+			continue
+		}
+		if err := r.storage.Delete(ctx, *j.ResultObject); err != nil {
+			return fmt.Errorf("failed to delete export %s: %w", j.ID, err)
+		}
+	}
+
+	return r.repo.EraseUser(ctx, job.UserID)
+}
+
+// deleteTraceFile deletes one of the user's trace objects unless another
+// user's trace is stored under the same name, as uploads made before each
+// got a name of its own can be
+func (r *Runner) deleteTraceFile(ctx context.Context, userID uuid.UUID, objectName string) error {
+	inUse, err := r.repo.TraceFileInUse(ctx, userID, objectName)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		log.Printf("Keeping %s, which another user's trace still uses", objectName)
+		return nil
+	}
+	return r.storage.Delete(ctx, objectName)
+}
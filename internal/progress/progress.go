@@ -0,0 +1,222 @@
+// internal/progress/progress.go
+package progress
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrSessionInUse is returned by Start for a session ID another upload has
+// taken
+var ErrSessionInUse = errors.New("upload session in use")
+
+// States an upload session goes through
+const (
+	StateReceiving = "receiving"
+	StateStoring   = "storing"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+)
+
+// Progress is how far an upload has got. The request body is received in
+// full before the file in it is stored, so ReceivedBytes counts up to
+// TotalBytes first and then StoredBytes up to FileBytes.
+type Progress struct {
+	SessionID uuid.UUID `json:"session_id"`
+	State     string    `json:"state"`
+	// TotalBytes is the request's Content-Length, zero when it wasn't sent
+	TotalBytes    int64 `json:"total_bytes"`
+	ReceivedBytes int64 `json:"received_bytes"`
+	// FileBytes is zero until the file has been received
+	FileBytes   int64 `json:"file_bytes"`
+	StoredBytes int64 `json:"stored_bytes"`
+	// Percent counts receiving as the first half and storing as the second
+	Percent     int        `json:"percent"`
+	TraceID     *uuid.UUID `json:"trace_id,omitempty"`
+	DateUpdated time.Time  `json:"date_updated"`
+}
+
+// Done reports whether the upload has completed or failed
+func (p Progress) Done() bool {
+	return p.State == StateCompleted || p.State == StateFailed
+}
+
+// Tracker keeps the progress of uploads in this process only, so progress
+// is reported by the replica receiving the upload. Sessions are forgotten
+// once they haven't changed for the retention period.
+type Tracker struct {
+	retention time.Duration
+
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*Session
+}
+
+func NewTracker(retention time.Duration) *Tracker {
+	return &Tracker{retention: retention, sessions: map[uuid.UUID]*Session{}}
+}
+
+// Start tracks an upload of totalBytes by userID under id. An ID can be
+// reused once its last upload has finished, by the same user only.
+func (t *Tracker) Start(id, userID uuid.UUID, totalBytes int64) (*Session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune()
+	if s, ok := t.sessions[id]; ok && (s.userID != userID || !s.Progress().Done()) {
+		return nil, ErrSessionInUse
+	}
+	s := &Session{userID: userID, progress: Progress{
+		SessionID:   id,
+		State:       StateReceiving,
+		TotalBytes:  max(totalBytes, 0),
+		DateUpdated: time.Now().UTC(),
+	}}
+	t.sessions[id] = s
+	return s, nil
+}
+
+// Get returns the progress of userID's upload under id
+func (t *Tracker) Get(id, userID uuid.UUID) (Progress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune()
+	s, ok := t.sessions[id]
+	if !ok || s.userID != userID {
+		return Progress{}, false
+	}
+	return s.Progress(), true
+}
+
+// prune forgets sessions that have been idle past the retention period
+func (t *Tracker) prune() {
+	cutoff := time.Now().Add(-t.retention)
+	for id, s := range t.sessions {
+		if s.Progress().DateUpdated.Before(cutoff) {
+			delete(t.sessions, id)
+		}
+	}
+}
+
+// Session is one upload's progress. Its methods do nothing on a nil
+// Session, so uploads that aren't tracked need no checks.
+type Session struct {
+	userID uuid.UUID
+
+	mu       sync.Mutex
+	progress Progress
+}
+
+// Progress returns the session's progress so far
+func (s *Session) Progress() Progress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.progress
+}
+
+// Receive counts the bytes read from body as received
+func (s *Session) Receive(body io.ReadCloser) io.ReadCloser {
+	if s == nil {
+		return body
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&countingReader{r: body, count: func(n int64) {
+		s.update(func(p *Progress) { p.ReceivedBytes += n })
+	}}, body}
+}
+
+// Store moves the session on to storing a file of fileBytes and counts the
+// bytes read from file as stored. Rewinding file, as storage does to retry
+// an upload, starts the count again.
+func (s *Session) Store(file io.ReadSeeker, fileBytes int64) io.ReadSeeker {
+	if s == nil {
+		return file
+	}
+	s.update(func(p *Progress) {
+		p.State = StateStoring
+		p.FileBytes = fileBytes
+	})
+	return &countingReader{r: file, count: func(n int64) {
+		s.update(func(p *Progress) {
+			if n < 0 {
+				p.StoredBytes = 0
+				return
+			}
+			p.StoredBytes += n
+		})
+	}}
+}
+
+// Complete marks the upload done, recorded as traceID
+func (s *Session) Complete(traceID uuid.UUID) {
+	if s == nil {
+		return
+	}
+	s.update(func(p *Progress) {
+		p.State = StateCompleted
+		p.TraceID = &traceID
+	})
+}
+
+// Finish marks the upload failed unless it completed. Call it once the
+// upload request has been answered.
+func (s *Session) Finish() {
+	if s == nil {
+		return
+	}
+	s.update(func(p *Progress) {
+		if p.State != StateCompleted {
+			p.State = StateFailed
+		}
+	})
+}
+
+func (s *Session) update(fn func(*Progress)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.progress)
+	s.progress.Percent = percent(s.progress)
+	s.progress.DateUpdated = time.Now().UTC()
+}
+
+func percent(p Progress) int {
+	switch {
+	case p.State == StateCompleted:
+		return 100
+	case p.State == StateFailed:
+		return p.Percent
+	case p.State == StateStoring && p.FileBytes > 0:
+		return 50 + int(min(p.StoredBytes, p.FileBytes)*50/p.FileBytes)
+	case p.State == StateStoring:
+		return 50
+	case p.TotalBytes > 0:
+		return int(min(p.ReceivedBytes, p.TotalBytes) * 50 / p.TotalBytes)
+	}
+	return p.Percent
+}
+
+// countingReader reports each read's byte count, and a rewind as -1
+type countingReader struct {
+	r     io.Reader
+	count func(n int64)
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.count(int64(n))
+	}
+	return n, err
+}
+
+func (c *countingReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := c.r.(io.Seeker).Seek(offset, whence)
+	if err == nil && pos == 0 {
+		c.count(-1)
+	}
+	return pos, err
+}
@@ -0,0 +1,36 @@
+// internal/publisher/chaos.go
+package publisher
+
+import (
+	"api-server/internal/chaos"
+	"context"
+)
+
+// Chaos injects the publisher faults configured on an admin endpoint into
+// every publish, for testing retries and the circuit breaker in development
+type Chaos struct {
+	next   Publisher
+	faults *chaos.Injector
+}
+
+func NewChaos(next Publisher, faults *chaos.Injector) *Chaos {
+	return &Chaos{next: next, faults: faults}
+}
+
+func (p *Chaos) Publish(ctx context.Context, topic string, value []byte) error {
+	if err := p.faults.Inject(ctx, chaos.DependencyPublisher); err != nil {
+		return err
+	}
+	return p.next.Publish(ctx, topic, value)
+}
+
+func (p *Chaos) Ping(ctx context.Context) error {
+	if err := p.faults.Inject(ctx, chaos.DependencyPublisher); err != nil {
+		return err
+	}
+	return p.next.Ping(ctx)
+}
+
+func (p *Chaos) Close() error {
+	return p.next.Close()
+}
@@ -0,0 +1,81 @@
+// internal/publisher/kafka.go
+package publisher
+
+import (
+	"context"
+	"log"
+
+	"github.com/IBM/sarama"
+)
+
+type Kafka struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+}
+
+// KafkaAuth holds optional SASL/PLAIN credentials and whether to use TLS
+type KafkaAuth struct {
+	Username string
+	Password string
+	TLS      bool
+}
+
+func NewKafka(brokers []string, auth KafkaAuth) (*Kafka, error) {
+	kafkaConfig := sarama.NewConfig()
+	kafkaConfig.Producer.Return.Successes = true
+	if auth.Username != "" {
+		kafkaConfig.Net.SASL.Enable = true
+		kafkaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		kafkaConfig.Net.SASL.User = auth.Username
+		kafkaConfig.Net.SASL.Password = auth.Password
+	}
+	kafkaConfig.Net.TLS.Enable = auth.TLS
+	client, err := sarama.NewClient(brokers, kafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &Kafka{client: client, producer: producer}, nil
+}
+
+func (k *Kafka) Publish(ctx context.Context, topic string, value []byte) error {
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(value),
+	}
+	partition, offset, err := k.producer.SendMessage(msg)
+	if err != nil {
+		return err
+	}
+	log.Printf("Sent message to partition %d, offset %d", partition, offset)
+	return nil
+}
+
+// Ping refreshes the cluster metadata, which fails when no broker answers.
+// sarama can't be cancelled, so a ping that outlives ctx finishes in the
+// background.
+func (k *Kafka) Ping(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- k.client.RefreshMetadata()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (k *Kafka) Close() error {
+	// A producer made from a client leaves the client open
+	err := k.producer.Close()
+	if closeErr := k.client.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+// internal/publisher/memory.go
+package publisher
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Message is an event captured by the in-memory publisher
+type Message struct {
+	Topic string
+	Value []byte
+}
+
+// Noop discards every message, for local runs without a broker
+type Noop struct{}
+
+func NewNoop() *Noop {
+	return &Noop{}
+}
+
+func (n *Noop) Publish(ctx context.Context, topic string, value []byte) error {
+	log.Printf("Discarding message for topic %s (noop publisher)", topic)
+	return nil
+}
+
+func (n *Noop) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (n *Noop) Close() error {
+	return nil
+}
+
+// Memory keeps published messages in memory so they can be inspected
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Publish(ctx context.Context, topic string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, Message{Topic: topic, Value: append([]byte(nil), value...)})
+	return nil
+}
+
+// Messages returns a copy of every message published so far
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,112 @@
+// internal/publisher/metrics.go
+package publisher
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics counts Kafka producer operations per topic. A nil *Metrics
+// records nothing.
+type Metrics struct {
+	messages *prometheus.CounterVec
+	bytes    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	retries  *prometheus.CounterVec
+	failures *prometheus.CounterVec
+}
+
+// NewMetrics registers the producer metrics with reg
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	labels := []string{"topic"}
+	m := &Metrics{
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_producer_messages_total",
+			Help: "Messages published to Kafka.",
+		}, labels),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_producer_bytes_total",
+			Help: "Payload bytes published to Kafka.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kafka_producer_send_duration_seconds",
+			Help:    "Time taken by each attempt to send a message, successful or not.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_producer_retries_total",
+			Help: "Send attempts made after a message's first.",
+		}, labels),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_producer_failures_total",
+			Help: "Messages that could not be published after every retry.",
+		}, labels),
+	}
+	for _, c := range []prometheus.Collector{m.messages, m.bytes, m.duration, m.retries, m.failures} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// attempt records a send attempt; attempts after the first are retries
+func (m *Metrics) attempt(topic string, n int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	if n > 1 {
+		m.retries.WithLabelValues(topic).Inc()
+	}
+	m.duration.WithLabelValues(topic).Observe(elapsed.Seconds())
+}
+
+// published records the outcome of a publish once retries are over
+func (m *Metrics) published(topic string, size int, err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.failures.WithLabelValues(topic).Inc()
+		return
+	}
+	m.messages.WithLabelValues(topic).Inc()
+	m.bytes.WithLabelValues(topic).Add(float64(size))
+}
+
+// pingTimeout bounds the broker ping taken on each scrape
+const pingTimeout = 5 * time.Second
+
+// UpCollector exports kafka_up, 1 when a publisher's brokers answer a ping
+// taken at scrape time and 0 otherwise, so an alert can fire while events
+// pile up in the outbox
+type UpCollector struct {
+	publisher Publisher
+	up        *prometheus.Desc
+}
+
+func NewUpCollector(p Publisher) *UpCollector {
+	return &UpCollector{
+		publisher: p,
+		up:        prometheus.NewDesc("kafka_up", "Whether the Kafka brokers answered a metadata request (1) or not (0).", nil, nil),
+	}
+}
+
+func (c *UpCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+}
+
+func (c *UpCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	up := 1.0
+	if err := c.publisher.Ping(ctx); err != nil {
+		log.Printf("Kafka ping failed: %v", err)
+		up = 0
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, up)
+}
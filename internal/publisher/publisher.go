@@ -0,0 +1,41 @@
+// internal/publisher/publisher.go
+package publisher
+
+import (
+	"api-server/internal/config"
+	"context"
+	"fmt"
+)
+
+// Supported publisher backends
+const (
+	BackendKafka  = "kafka"
+	BackendNoop   = "noop"
+	BackendMemory = "memory"
+)
+
+// Publisher delivers event payloads to a topic
+type Publisher interface {
+	Publish(ctx context.Context, topic string, value []byte) error
+	// Ping checks the brokers can be reached
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// New builds the publisher selected by cfg.PublisherBackend
+func New(cfg *config.Config) (Publisher, error) {
+	switch cfg.PublisherBackend {
+	case BackendKafka:
+		return NewKafka([]string{cfg.KAFKA_BROKER}, KafkaAuth{
+			Username: cfg.KafkaSASLUsername,
+			Password: cfg.KafkaSASLPassword,
+			TLS:      cfg.KafkaTLS,
+		})
+	case BackendNoop:
+		return NewNoop(), nil
+	case BackendMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown publisher backend %q", cfg.PublisherBackend)
+	}
+}
@@ -0,0 +1,52 @@
+// internal/publisher/resilient.go
+package publisher
+
+import (
+	"api-server/internal/resilience"
+	"context"
+	"errors"
+	"time"
+)
+
+// Resilient retries publishes with backoff behind a circuit breaker
+type Resilient struct {
+	next    Publisher
+	policy  resilience.RetryPolicy
+	breaker *resilience.Breaker
+	metrics *Metrics
+}
+
+// NewResilient wraps next; metrics may be nil
+func NewResilient(next Publisher, policy resilience.RetryPolicy, breaker *resilience.Breaker, metrics *Metrics) *Resilient {
+	return &Resilient{next: next, policy: policy, breaker: breaker, metrics: metrics}
+}
+
+func (p *Resilient) Publish(ctx context.Context, topic string, value []byte) error {
+	attempts := 0
+	err := resilience.Retry(ctx, p.policy, func(ctx context.Context) error {
+		attempts++
+		start := time.Now()
+		err := p.breaker.Execute(func() error {
+			return p.next.Publish(ctx, topic, value)
+		})
+		if errors.Is(err, resilience.ErrBreakerOpen) {
+			return resilience.Permanent(err)
+		}
+		p.metrics.attempt(topic, attempts, time.Since(start))
+		return err
+	})
+	p.metrics.published(topic, len(value), err)
+	return err
+}
+
+// Ping fails without asking the brokers while the breaker is open
+func (p *Resilient) Ping(ctx context.Context) error {
+	if p.breaker.State() == resilience.StateOpen {
+		return resilience.ErrBreakerOpen
+	}
+	return p.next.Ping(ctx)
+}
+
+func (p *Resilient) Close() error {
+	return p.next.Close()
+}
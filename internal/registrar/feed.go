@@ -0,0 +1,288 @@
+// internal/registrar/feed.go
+package registrar
+
+import (
+	"api-server/internal/validation"
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Feed formats
+const (
+	FormatCSV   = "csv"
+	FormatFixed = "fixed"
+)
+
+// MaxRecords caps how many sections one feed may carry
+const MaxRecords = 10000
+
+// Record is one section of a semester feed: a course and who teaches it.
+// Field names are the feed's column names.
+type Record struct {
+	// Line is where the record starts in the feed, counting from 1
+	Line            int    `json:"-"`
+	SemesterTerm    string `json:"term" validate:"required,oneof=Fall Spring Summer"`
+	SemesterYear    int    `json:"year" validate:"gte=2000"`
+	SubjectCode     string `json:"subject" validate:"required,max=10"`
+	CourseNumber    int    `json:"number" validate:"gte=1,lte=99999999"`
+	Title           string `json:"title" validate:"required,max=100"`
+	CreditHours     int    `json:"credits" validate:"gt=0"`
+	InstructorName  string `json:"instructor_name" validate:"required,max=100"`
+	InstructorEmail string `json:"instructor_email" validate:"required,max=100,email_format"`
+}
+
+// section names the course a record describes, as the registrar does
+func (r Record) section() string {
+	return fmt.Sprintf("%s %d %s %d", r.SubjectCode, r.CourseNumber, r.SemesterTerm, r.SemesterYear)
+}
+
+// columns are the feed's columns, in fixed-width order
+var columns = []string{"year", "term", "subject", "number", "credits", "title", "instructor_name", "instructor_email"}
+
+// fixedWidths are the widths of the columns of a fixed-width feed, which
+// together take 330 characters a line
+var fixedWidths = []int{4, 6, 10, 8, 2, 100, 100, 100}
+
+// LineError is a problem with one line of a feed
+type LineError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// Errors is every problem found in a feed, in line order
+type Errors []LineError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, le := range e {
+		messages[i] = fmt.Sprintf("line %d: %s", le.Line, le.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Parse reads a feed in format. Every record is checked before any is
+// returned: a feed with problems fails as a whole with Errors, so an import
+// never applies half a feed because of a typo.
+func Parse(body io.Reader, format string) ([]Record, error) {
+	var (
+		records []Record
+		errs    Errors
+		err     error
+	)
+	switch format {
+	case FormatCSV:
+		records, errs, err = parseCSV(body)
+	case FormatFixed:
+		records, errs, err = parseFixed(body)
+	default:
+		return nil, fmt.Errorf("unknown feed format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) == 0 && len(records) == 0 {
+		errs = append(errs, LineError{Line: 1, Message: "feed has no records"})
+	}
+	if len(records) > MaxRecords {
+		errs = append(errs, LineError{Line: records[MaxRecords].Line, Message: fmt.Sprintf("feed has more than %d records", MaxRecords)})
+	}
+	errs = append(errs, check(records)...)
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return nil, errs
+	}
+	return records, nil
+}
+
+// parseCSV reads a feed with a header row naming the columns, in any order.
+// Other columns are ignored.
+func parseCSV(body io.Reader) ([]Record, Errors, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		errs, err := csvErrors(err)
+		return nil, errs, err
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		index[name] = i
+	}
+	var missing []string
+	for _, name := range columns {
+		if _, ok := index[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, Errors{{Line: 1, Message: "header is missing columns: " + strings.Join(missing, ", ")}}, nil
+	}
+
+	var (
+		records []Record
+		errs    Errors
+	)
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			csvErrs, err := csvErrors(err)
+			return nil, append(errs, csvErrs...), err
+		}
+		line, _ := reader.FieldPos(0)
+		fields := make(map[string]string, len(columns))
+		for _, name := range columns {
+			fields[name] = row[index[name]]
+		}
+		record, recordErrs := newRecord(line, fields)
+		records = append(records, record)
+		errs = append(errs, recordErrs...)
+	}
+	return records, errs, nil
+}
+
+// csvErrors reports a malformed CSV row as a problem with the feed, and
+// anything else, such as a body over the size limit, as an error
+func csvErrors(err error) (Errors, error) {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Errors{{Line: parseErr.StartLine, Message: parseErr.Err.Error()}}, nil
+	}
+	return nil, err
+}
+
+// parseFixed reads a feed with one record a line in the columns of
+// fixedWidths, measured in characters. Blank lines are skipped and a short
+// line leaves its missing columns empty.
+func parseFixed(body io.Reader) ([]Record, Errors, error) {
+	var (
+		records []Record
+		errs    Errors
+	)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), 64*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := []rune(strings.TrimRight(scanner.Text(), "\r"))
+		if strings.TrimSpace(string(text)) == "" {
+			continue
+		}
+		fields := make(map[string]string, len(columns))
+		start := 0
+		for i, name := range columns {
+			end := min(start+fixedWidths[i], len(text))
+			if start < end {
+				fields[name] = string(text[start:end])
+			}
+			start += fixedWidths[i]
+		}
+		record, recordErrs := newRecord(line, fields)
+		records = append(records, record)
+		errs = append(errs, recordErrs...)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return records, append(errs, LineError{Line: line + 1, Message: "line is too long"}), nil
+		}
+		return nil, nil, err
+	}
+	return records, errs, nil
+}
+
+// newRecord builds the record on line from its columns and checks it
+func newRecord(line int, fields map[string]string) (Record, Errors) {
+	var errs Errors
+	number := func(name string) int {
+		value := strings.TrimSpace(fields[name])
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, LineError{Line: line, Field: name, Rule: "number", Message: fmt.Sprintf("%s must be a whole number", name)})
+		}
+		return n
+	}
+
+	record := Record{
+		Line:            line,
+		SemesterTerm:    term(strings.TrimSpace(fields["term"])),
+		SemesterYear:    number("year"),
+		SubjectCode:     strings.ToUpper(strings.TrimSpace(fields["subject"])),
+		CourseNumber:    number("number"),
+		Title:           strings.TrimSpace(fields["title"]),
+		CreditHours:     number("credits"),
+		InstructorName:  strings.TrimSpace(fields["instructor_name"]),
+		InstructorEmail: strings.TrimSpace(fields["instructor_email"]),
+	}
+	if err := validation.Struct(&record); err != nil {
+		var fieldErrs validation.Errors
+		if !errors.As(err, &fieldErrs) {
+			return record, append(errs, LineError{Line: line, Message: err.Error()})
+		}
+		for _, fe := range fieldErrs {
+			// A column that isn't a number already said so
+			if hasField(errs, fe.Field) {
+				continue
+			}
+			errs = append(errs, LineError{Line: line, Field: fe.Field, Rule: fe.Rule, Message: fe.Message})
+		}
+	}
+	return record, errs
+}
+
+func hasField(errs Errors, field string) bool {
+	for _, e := range errs {
+		if e.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// term spells a semester term the way courses do; registrar feeds often
+// shout it
+func term(value string) string {
+	for _, t := range []string{"Fall", "Spring", "Summer"} {
+		if strings.EqualFold(value, t) {
+			return t
+		}
+	}
+	return value
+}
+
+// check finds what's wrong across records: a section listed twice, or an
+// instructor email given different names
+func check(records []Record) Errors {
+	var errs Errors
+	sections := make(map[string]int, len(records))
+	names := make(map[string]Record)
+	for _, r := range records {
+		if first, ok := sections[r.section()]; ok {
+			errs = append(errs, LineError{Line: r.Line, Message: fmt.Sprintf("%s is already listed on line %d", r.section(), first)})
+		} else {
+			sections[r.section()] = r.Line
+		}
+
+		email := strings.ToLower(r.InstructorEmail)
+		if first, ok := names[email]; !ok {
+			names[email] = r
+		} else if first.InstructorName != r.InstructorName {
+			errs = append(errs, LineError{Line: r.Line, Field: "instructor_name", Message: fmt.Sprintf("%s is named %q on line %d", r.InstructorEmail, first.InstructorName, first.Line)})
+		}
+	}
+	return errs
+}
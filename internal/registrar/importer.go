@@ -0,0 +1,291 @@
+// internal/registrar/importer.go
+package registrar
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// What an import does to a record
+const (
+	ActionCreated   = "created"
+	ActionUpdated   = "updated"
+	ActionUnchanged = "unchanged"
+)
+
+// Kinds of record an import writes
+const (
+	KindInstructor = "instructor"
+	KindCourse     = "course"
+)
+
+// Report is what an import did, or on a dry run would have done
+type Report struct {
+	DryRun      bool     `json:"dry_run"`
+	Instructors Summary  `json:"instructors"`
+	Courses     Summary  `json:"courses"`
+	Records     []Result `json:"records"`
+}
+
+// Summary counts what an import did to one kind of record
+type Summary struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+func (s *Summary) add(action string) {
+	switch action {
+	case ActionCreated:
+		s.Created++
+	case ActionUpdated:
+		s.Updated++
+	default:
+		s.Unchanged++
+	}
+}
+
+// Result is what an import did to one instructor or course. An instructor
+// is reported once, at the first line that names them.
+type Result struct {
+	Line int    `json:"line"`
+	Kind string `json:"kind"`
+	// Key is the instructor's email or the course's section
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	// ID is nil for a record a dry run would create
+	ID      *uuid.UUID                   `json:"id"`
+	Changes map[string]model.FieldChange `json:"changes,omitempty"`
+}
+
+// Import upserts the instructors and courses of a feed, matching
+// instructors by email, ignoring case, and courses by section. Fields the
+// feed doesn't carry are left alone, so importing a feed twice changes
+// nothing the second time. A dry run only reports.
+//
+// Every record is looked up first. The new instructors, then the new
+// courses, are then each inserted in one statement, and changed records
+// updated one at a time, so a failure leaves what was written before it;
+// importing the feed again picks up where it stopped.
+func Import(ctx context.Context, repo repository.Repository, records []Record, userID uuid.UUID, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, Records: []Result{}}
+	// instructors holds the plan for each instructor by lowercased email
+	instructors := make(map[string]*instructorPlan)
+	var (
+		results       []*Result
+		instructorOps []*instructorPlan
+		courseOps     []*coursePlan
+	)
+
+	for _, r := range records {
+		email := strings.ToLower(r.InstructorEmail)
+		instructor, seen := instructors[email]
+		if !seen {
+			var err error
+			if instructor, err = planInstructor(ctx, repo, r); err != nil {
+				return nil, fmt.Errorf("line %d: %w", r.Line, err)
+			}
+			instructors[email] = instructor
+			instructorOps = append(instructorOps, instructor)
+			results = append(results, instructor.result)
+		}
+
+		course, err := planCourse(ctx, repo, r, instructor)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.Line, err)
+		}
+		courseOps = append(courseOps, course)
+		results = append(results, course.result)
+	}
+
+	if !dryRun {
+		if err := applyInstructors(ctx, repo, instructorOps, userID); err != nil {
+			return nil, err
+		}
+		if err := applyCourses(ctx, repo, courseOps, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, result := range results {
+		if result.Kind == KindInstructor {
+			report.Instructors.add(result.Action)
+		} else {
+			report.Courses.add(result.Action)
+		}
+		report.Records = append(report.Records, *result)
+	}
+	return report, nil
+}
+
+// instructorPlan is what an import will do to an instructor: create it,
+// update it, or neither
+type instructorPlan struct {
+	result *Result
+	create *model.CreateInstructorRequest
+	update *model.UpdateInstructorRequest
+}
+
+// coursePlan is what an import will do to a course. Its instructor may not
+// have been created yet when it is planned.
+type coursePlan struct {
+	result     *Result
+	instructor *instructorPlan
+	create     *model.CreateCourseRequest
+	update     *model.UpdateCourseRequest
+}
+
+func planInstructor(ctx context.Context, repo repository.Repository, r Record) (*instructorPlan, error) {
+	plan := &instructorPlan{result: &Result{Line: r.Line, Kind: KindInstructor, Key: r.InstructorEmail}}
+
+	existing, err := repo.GetInstructorByEmail(ctx, r.InstructorEmail)
+	if errors.Is(err, model.ErrNotFound) {
+		plan.result.Action = ActionCreated
+		plan.create = &model.CreateInstructorRequest{Name: r.InstructorName, Email: r.InstructorEmail}
+		return plan, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The stored email keeps its case: another instructor may differ from it only in case
+	plan.result.ID = &existing.ID
+	plan.result.Key = existing.Email
+	if existing.Name == r.InstructorName {
+		plan.result.Action = ActionUnchanged
+		return plan, nil
+	}
+	plan.result.Action = ActionUpdated
+	plan.result.Changes = map[string]model.FieldChange{"name": {Old: existing.Name, New: r.InstructorName}}
+	plan.update = &model.UpdateInstructorRequest{Name: &r.InstructorName}
+	return plan, nil
+}
+
+func planCourse(ctx context.Context, repo repository.Repository, r Record, instructor *instructorPlan) (*coursePlan, error) {
+	plan := &coursePlan{result: &Result{Line: r.Line, Kind: KindCourse, Key: r.section()}, instructor: instructor}
+
+	existing, err := repo.GetCourseBySection(ctx, r.SubjectCode, r.CourseNumber, r.SemesterTerm, r.SemesterYear)
+	if errors.Is(err, model.ErrNotFound) {
+		plan.result.Action = ActionCreated
+		// The instructor ID is filled in once the instructor exists
+		plan.create = &model.CreateCourseRequest{
+			Name:         r.Title,
+			SemesterTerm: r.SemesterTerm,
+			CreditHours:  r.CreditHours,
+			SubjectCode:  r.SubjectCode,
+			CourseID:     r.CourseNumber,
+			SemesterYear: r.SemesterYear,
+		}
+		return plan, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plan.result.ID = &existing.ID
+	var req model.UpdateCourseRequest
+	changes := map[string]model.FieldChange{}
+	if existing.Name != r.Title {
+		req.Name = &r.Title
+		changes["name"] = model.FieldChange{Old: existing.Name, New: r.Title}
+	}
+	if existing.CreditHours != r.CreditHours {
+		req.CreditHours = &r.CreditHours
+		changes["credit_hours"] = model.FieldChange{Old: existing.CreditHours, New: r.CreditHours}
+	}
+	// An instructor still to be created has no ID yet, so it is a change;
+	// a dry run reports it without a new ID
+	if instructorID := instructor.result.ID; instructorID == nil || existing.InstructorID != *instructorID {
+		change := model.FieldChange{Old: existing.InstructorID}
+		if instructorID != nil {
+			change.New = *instructorID
+		}
+		changes["instructor_id"] = change
+	}
+	if len(changes) == 0 {
+		plan.result.Action = ActionUnchanged
+		return plan, nil
+	}
+	plan.result.Action = ActionUpdated
+	plan.result.Changes = changes
+	plan.update = &req
+	return plan, nil
+}
+
+// applyInstructors creates the planned instructors in one go and updates
+// the changed ones
+func applyInstructors(ctx context.Context, repo repository.Repository, plans []*instructorPlan, userID uuid.UUID) error {
+	var (
+		creates []*instructorPlan
+		reqs    []model.CreateInstructorRequest
+	)
+	for _, p := range plans {
+		if p.create != nil {
+			creates = append(creates, p)
+			reqs = append(reqs, *p.create)
+		}
+	}
+	created, err := repo.CreateInstructors(ctx, reqs, userID)
+	if err != nil {
+		return err
+	}
+	for i, p := range creates {
+		p.result.ID = &created[i].ID
+	}
+
+	for _, p := range plans {
+		if p.update == nil {
+			continue
+		}
+		if _, err := repo.UpdateInstructor(ctx, *p.result.ID, *p.update); err != nil {
+			return fmt.Errorf("line %d: %w", p.result.Line, err)
+		}
+	}
+	return nil
+}
+
+// applyCourses creates the planned courses in one go and updates the
+// changed ones, now that every instructor they name exists
+func applyCourses(ctx context.Context, repo repository.Repository, plans []*coursePlan, userID uuid.UUID) error {
+	var (
+		creates []*coursePlan
+		reqs    []model.CreateCourseRequest
+	)
+	for _, p := range plans {
+		if p.create != nil {
+			req := *p.create
+			req.InstructorID = *p.instructor.result.ID
+			creates = append(creates, p)
+			reqs = append(reqs, req)
+		}
+	}
+	created, err := repo.CreateCourses(ctx, reqs, userID)
+	if err != nil {
+		return err
+	}
+	for i, p := range creates {
+		p.result.ID = &created[i].ID
+	}
+
+	for _, p := range plans {
+		if p.update == nil {
+			continue
+		}
+		instructorID := *p.instructor.result.ID
+		if change, ok := p.result.Changes["instructor_id"]; ok {
+			p.update.InstructorID = &instructorID
+			change.New = instructorID
+			p.result.Changes["instructor_id"] = change
+		}
+		if _, err := repo.UpdateCourse(ctx, *p.result.ID, *p.update, userID); err != nil {
+			return fmt.Errorf("line %d: %w", p.result.Line, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,3296 @@
+// internal/repository/memory.go
+package repository
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/tenant"
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Memory implements Repository with maps guarded by a mutex, for
+// MODE=inmemory. It enforces the schema's unique and foreign key constraints
+// and reports violations as the same Postgres errors, so handlers map them to
+// the same responses. Nothing survives a restart.
+type Memory struct {
+	mu      sync.RWMutex
+	tenants map[uuid.UUID]*model.Tenant
+	apiKeys map[string]uuid.UUID // api_key_hash to tenant ID
+	usage   map[uuid.UUID]*model.TenantUsage
+	// userUploads is what each user has uploaded on their last day of uploads
+	userUploads map[uuid.UUID]model.UserUploadUsage
+	// owner is the tenant of each user, instructor, course and trace, which
+	// the model structs omit
+	owner      map[uuid.UUID]uuid.UUID
+	users      map[uuid.UUID]*model.User
+	identities map[memoryIdentityKey]uuid.UUID // linked identity to user ID
+	mfa        map[uuid.UUID]*model.MFA        // by user ID
+	// mfaChallenges count the codes each login challenge took, by its ID
+	mfaChallenges map[uuid.UUID]*memoryMFAChallenge
+	sessions      map[uuid.UUID]*model.Session
+	// prefs holds the settings each user has made, by user ID
+	prefs         map[uuid.UUID]*model.NotificationPreferences
+	notifications []*memoryNotification
+	// favorites holds when each user saved each course, by user ID
+	favorites map[uuid.UUID]map[uuid.UUID]time.Time
+	// avatars holds each user's profile picture, by user ID
+	avatars map[uuid.UUID]model.UserAvatar
+	// serviceAccounts carry their tenant, so they go when it does, as
+	// ON DELETE CASCADE has it
+	serviceAccounts map[uuid.UUID]*memoryServiceAccount
+	instructors     map[uuid.UUID]*model.Instructor
+	courses         map[uuid.UUID]*model.Course
+	// courseVersions holds each course's versions, oldest first, by course ID
+	courseVersions map[uuid.UUID][]model.CourseVersion
+	traces         map[uuid.UUID]*memoryTrace
+	// events is the event ledger, in order of position
+	events   []memoryEvent
+	outbox   []*model.OutboxEvent
+	dataJobs []*memoryDataJob
+	audit    []model.AuditEntry
+	flags    map[string]model.FeatureFlagOverride
+
+	// canvasConnections are by tenant ID, canvasLinks by course ID
+	canvasConnections map[uuid.UUID]*model.CanvasConnection
+	canvasLinks       map[uuid.UUID]*memoryCanvasLink
+	canvasSyncs       []*memoryCanvasSync
+}
+
+// memoryTrace is a trace plus the course it belongs to, which model.Trace
+// omits
+type memoryTrace struct {
+	model.Trace
+	courseID  uuid.UUID
+	embedding []float32
+	excerpt   string
+	content   *model.TraceContent
+	thumbnail *model.TraceThumbnail
+	comments  []model.TraceComment
+	// revisions are the files the trace had before, oldest first
+	revisions []model.TraceRevision
+	shares    []memoryShare
+	// access is the trace's access log, oldest first
+	access []model.TraceAccess
+}
+
+// memoryShare is a share link plus the hash of its token, which
+// model.TraceShare omits
+type memoryShare struct {
+	model.TraceShare
+	tokenHash string
+}
+
+// storedBytes is the size of the trace's file and its earlier revisions
+func (t *memoryTrace) storedBytes() int64 {
+	size := t.SizeBytes
+	for _, r := range t.revisions {
+		size += r.SizeBytes
+	}
+	return size
+}
+
+// memoryMFAChallenge is how many codes a login challenge took
+type memoryMFAChallenge struct {
+	userID    uuid.UUID
+	attempts  int
+	expiresAt time.Time
+}
+
+// memoryEvent is an event plus its tenant, which model.Event omits
+type memoryEvent struct {
+	model.Event
+	tenantID uuid.UUID
+}
+
+// memoryCanvasLink is a course's Canvas link plus its tenant and the last
+// roster pulled, which model.CanvasCourseLink omits
+type memoryCanvasLink struct {
+	model.CanvasCourseLink
+	tenantID uuid.UUID
+	roster   []model.CanvasRosterEntry
+}
+
+// memoryCanvasSync is a Canvas sync plus its lease, which model.CanvasSync
+// omits
+type memoryCanvasSync struct {
+	model.CanvasSync
+	leaseExpires time.Time
+}
+
+// memoryServiceAccount is a service account plus its tenant and key hash,
+// which model.ServiceAccount omits
+type memoryServiceAccount struct {
+	model.ServiceAccount
+	tenantID uuid.UUID
+	keyHash  string
+}
+
+// memoryIdentityKey identifies a provider account within a tenant
+type memoryIdentityKey struct {
+	tenantID          uuid.UUID
+	provider, subject string
+}
+
+// memoryNotification is a notification plus its recipient, which
+// model.Notification omits
+type memoryNotification struct {
+	model.Notification
+	userID uuid.UUID
+}
+
+// memoryDataJob is a data job plus its lease, which model.DataJob omits
+type memoryDataJob struct {
+	model.DataJob
+	leaseExpires time.Time
+}
+
+func NewMemory() *Memory {
+	defaultTenant := *tenant.Default
+	defaultTenant.DateCreated = now()
+	defaultTenant.DateUpdated = defaultTenant.DateCreated
+	return &Memory{
+		tenants:         map[uuid.UUID]*model.Tenant{defaultTenant.ID: &defaultTenant},
+		apiKeys:         map[string]uuid.UUID{},
+		usage:           map[uuid.UUID]*model.TenantUsage{defaultTenant.ID: {UploadDay: model.UsageDay(now()), DateUpdated: now()}},
+		userUploads:     map[uuid.UUID]model.UserUploadUsage{},
+		owner:           map[uuid.UUID]uuid.UUID{},
+		users:           map[uuid.UUID]*model.User{},
+		identities:      map[memoryIdentityKey]uuid.UUID{},
+		mfa:             map[uuid.UUID]*model.MFA{},
+		mfaChallenges:   map[uuid.UUID]*memoryMFAChallenge{},
+		sessions:        map[uuid.UUID]*model.Session{},
+		prefs:           map[uuid.UUID]*model.NotificationPreferences{},
+		favorites:       map[uuid.UUID]map[uuid.UUID]time.Time{},
+		avatars:         map[uuid.UUID]model.UserAvatar{},
+		serviceAccounts: map[uuid.UUID]*memoryServiceAccount{},
+		instructors:     map[uuid.UUID]*model.Instructor{},
+		courses:         map[uuid.UUID]*model.Course{},
+		courseVersions:  map[uuid.UUID][]model.CourseVersion{},
+		traces:          map[uuid.UUID]*memoryTrace{},
+		flags:           map[string]model.FeatureFlagOverride{},
+
+		canvasConnections: map[uuid.UUID]*model.CanvasConnection{},
+		canvasLinks:       map[uuid.UUID]*memoryCanvasLink{},
+	}
+}
+
+// now matches the microsecond precision of Postgres timestamps, so cursors
+// built from returned rows compare exactly
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func uniqueViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23505",
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
+
+func foreignKeyViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23503",
+		Message:        fmt.Sprintf("violates foreign key constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// InsertHealthCheck has nothing to record; reaching the store is the check
+func (m *Memory) InsertHealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// owns reports whether the row with the given ID belongs to tenantID
+func (m *Memory) owns(tenantID, id uuid.UUID) bool {
+	return m.owner[id] == tenantID
+}
+
+// Tenants
+
+func (m *Memory) CreateTenant(ctx context.Context, req model.CreateTenantRequest, apiKeyHash *string) (*model.Tenant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tenantBySlug(req.Slug) != nil {
+		return nil, uniqueViolation("tenants_slug_key")
+	}
+	if apiKeyHash != nil {
+		if _, ok := m.apiKeys[*apiKeyHash]; ok {
+			return nil, uniqueViolation("tenants_api_key_hash_key")
+		}
+	}
+
+	ts := now()
+	t := &model.Tenant{
+		ID:           uuid.New(),
+		Slug:         req.Slug,
+		Name:         req.Name,
+		BucketPrefix: req.BucketPrefix,
+		Quotas:       req.Quotas,
+		DateCreated:  ts,
+		DateUpdated:  ts,
+	}
+	m.tenants[t.ID] = t
+	if apiKeyHash != nil {
+		m.apiKeys[*apiKeyHash] = t.ID
+	}
+	m.usage[t.ID] = &model.TenantUsage{UploadDay: model.UsageDay(ts), DateUpdated: ts}
+	copied := *t
+	return &copied, nil
+}
+
+func (m *Memory) GetTenantByID(ctx context.Context, tenantID uuid.UUID) (*model.Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.tenants[tenantID]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (m *Memory) GetTenantBySlug(ctx context.Context, slug string) (*model.Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t := m.tenantBySlug(slug)
+	if t == nil {
+		return nil, model.ErrNotFound
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (m *Memory) GetTenantByAPIKey(ctx context.Context, key string) (*model.Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.apiKeys[model.HashAPIKey(key)]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	copied := *m.tenants[id]
+	return &copied, nil
+}
+
+func (m *Memory) ListTenants(ctx context.Context) ([]model.Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tenants := make([]model.Tenant, 0, len(m.tenants))
+	for _, t := range m.tenants {
+		tenants = append(tenants, *t)
+	}
+	slices.SortFunc(tenants, func(a, b model.Tenant) int { return strings.Compare(a.Slug, b.Slug) })
+	return tenants, nil
+}
+
+func (m *Memory) UpdateTenant(ctx context.Context, slug string, req model.UpdateTenantRequest) (*model.Tenant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.tenantBySlug(slug)
+	if t == nil {
+		return nil, model.ErrNotFound
+	}
+	if req.Name != nil {
+		t.Name = *req.Name
+	}
+	if req.BucketPrefix != nil {
+		t.BucketPrefix = req.BucketPrefix
+	}
+	if req.Quotas != nil {
+		t.Quotas = *req.Quotas
+	}
+	t.DateUpdated = now()
+	copied := *t
+	return &copied, nil
+}
+
+// DeleteTenant enforces the tenant_id foreign keys of the tables that
+// reference tenants
+func (m *Memory) DeleteTenant(ctx context.Context, slug string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.tenantBySlug(slug)
+	if t == nil {
+		return model.ErrNotFound
+	}
+	for id, owner := range m.owner {
+		if owner != t.ID {
+			continue
+		}
+		switch {
+		case m.users[id] != nil:
+			return foreignKeyViolation("users_tenant_id_fkey")
+		case m.instructors[id] != nil:
+			return foreignKeyViolation("instructors_tenant_id_fkey")
+		case m.courses[id] != nil:
+			return foreignKeyViolation("courses_tenant_id_fkey")
+		default:
+			return foreignKeyViolation("traces_tenant_id_fkey")
+		}
+	}
+	for hash, id := range m.apiKeys {
+		if id == t.ID {
+			delete(m.apiKeys, hash)
+		}
+	}
+	for id, a := range m.serviceAccounts {
+		if a.tenantID == t.ID {
+			delete(m.serviceAccounts, id)
+		}
+	}
+	delete(m.canvasConnections, t.ID)
+	m.events = slices.DeleteFunc(m.events, func(e memoryEvent) bool { return e.tenantID == t.ID })
+	m.canvasSyncs = slices.DeleteFunc(m.canvasSyncs, func(s *memoryCanvasSync) bool { return s.TenantID == t.ID })
+	delete(m.tenants, t.ID)
+	delete(m.usage, t.ID)
+	return nil
+}
+
+func (m *Memory) tenantBySlug(slug string) *model.Tenant {
+	for _, t := range m.tenants {
+		if t.Slug == slug {
+			return t
+		}
+	}
+	return nil
+}
+
+// Tenant usage
+
+func (m *Memory) GetTenantUsage(ctx context.Context, tenantID uuid.UUID) (*model.TenantUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	usage, ok := m.usage[tenantID]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	copied := *usage
+	if today := model.UsageDay(now()); copied.UploadDay.Before(today) {
+		copied.UploadsToday = 0
+		copied.UploadDay = today
+	}
+	return &copied, nil
+}
+
+func (m *Memory) GetUserUploadUsage(ctx context.Context, userID uuid.UUID) (*model.UserUploadUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	today := model.UsageDay(now())
+	usage := m.userUploads[userID]
+	if usage.Day.Before(today) {
+		usage = model.UserUploadUsage{Day: today}
+	}
+	return &usage, nil
+}
+
+func (m *Memory) ChargeUpload(ctx context.Context, courseID, userID uuid.UUID, sizeBytes int64, limits model.UploadLimits) error {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	course, ok := m.courses[courseID]
+	if !ok || !m.owns(tenantID, courseID) {
+		return model.ErrNotFound
+	}
+	if limits.CourseBytes > 0 && course.StorageBytes+sizeBytes > limits.CourseBytes {
+		return &model.QuotaError{Quota: model.QuotaCourseStorageBytes, Limit: limits.CourseBytes, Used: course.StorageBytes}
+	}
+	userUploads, err := limits.ApplyUser(m.userUploads[userID], 1, sizeBytes, model.UsageDay(now()))
+	if err != nil {
+		return err
+	}
+	if err := m.charge(tenantID, model.UsageDelta{StorageBytes: sizeBytes, Uploads: 1}); err != nil {
+		return err
+	}
+	m.userUploads[userID] = userUploads
+	course.StorageBytes += sizeBytes
+	return nil
+}
+
+func (m *Memory) RefundUpload(ctx context.Context, courseID, userID uuid.UUID, sizeBytes int64) error {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	course, ok := m.courses[courseID]
+	if !ok || !m.owns(tenantID, courseID) {
+		return model.ErrNotFound
+	}
+	course.StorageBytes = max(course.StorageBytes-sizeBytes, 0)
+	m.userUploads[userID], _ = model.UploadLimits{}.ApplyUser(m.userUploads[userID], -1, -sizeBytes, model.UsageDay(now()))
+	return m.charge(tenantID, model.UsageDelta{StorageBytes: -sizeBytes, Uploads: -1})
+}
+
+// charge is model.ChargeTenantUsage against the maps; callers hold the write lock
+func (m *Memory) charge(tenantID uuid.UUID, delta model.UsageDelta) error {
+	usage, ok := m.usage[tenantID]
+	if !ok {
+		return model.ErrNotFound
+	}
+	ts := now()
+	updated, err := m.tenants[tenantID].Quotas.Apply(*usage, delta, model.UsageDay(ts))
+	if err != nil {
+		return err
+	}
+	updated.DateUpdated = ts
+	*usage = updated
+	return nil
+}
+
+// Users
+
+func (m *Memory) CreateUser(ctx context.Context, req model.CreateUserRequest) (*model.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if !m.owns(tenantID, u.ID) {
+			continue
+		}
+		if u.Username == req.Username {
+			return nil, uniqueViolation("users_username_key")
+		}
+		if u.Email == req.Email {
+			return nil, uniqueViolation("users_email_key")
+		}
+	}
+
+	ts := now()
+	user := &model.User{
+		ID:             uuid.New(),
+		FirstName:      req.FirstName,
+		LastName:       req.LastName,
+		Username:       req.Username,
+		Password:       string(hashedPassword),
+		Role:           req.Role,
+		Email:          req.Email,
+		AccountCreated: ts,
+		AccountUpdated: ts,
+	}
+	m.users[user.ID] = user
+	m.owner[user.ID] = tenantID
+	return publicUser(user), nil
+}
+
+func (m *Memory) AuthenticateUser(ctx context.Context, username, password string) (*model.User, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	var user *model.User
+	for _, u := range m.users {
+		if m.owns(tenantID, u.ID) && u.Username == username {
+			user = publicUser(u)
+			user.Password = u.Password
+		}
+	}
+	m.mu.RUnlock()
+
+	if user == nil {
+		return nil, model.ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, model.ErrInvalidCredentials
+	}
+	user.Password = ""
+	return user, nil
+}
+
+func (m *Memory) GetUser(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[userID]
+	if !ok || !m.owns(tenantID, userID) {
+		return nil, model.ErrNotFound
+	}
+	return publicUser(user), nil
+}
+
+func (m *Memory) GetUserAvatar(ctx context.Context, userID uuid.UUID) (*model.UserAvatar, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	avatar, ok := m.avatars[userID]
+	if !ok || !m.owns(tenant.ID(ctx), userID) {
+		return nil, model.ErrNotFound
+	}
+	return &avatar, nil
+}
+
+func (m *Memory) SaveUserAvatar(ctx context.Context, userID uuid.UUID, avatar model.UserAvatar) (*model.UserAvatar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; !ok || !m.owns(tenant.ID(ctx), userID) {
+		return nil, model.ErrNotFound
+	}
+	avatar.DateUpdated = now()
+	m.avatars[userID] = avatar
+	return &avatar, nil
+}
+
+func (m *Memory) DeleteUserAvatar(ctx context.Context, userID uuid.UUID) (*model.UserAvatar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	avatar, ok := m.avatars[userID]
+	if !ok || !m.owns(tenant.ID(ctx), userID) {
+		return nil, model.ErrNotFound
+	}
+	delete(m.avatars, userID)
+	return &avatar, nil
+}
+
+func (m *Memory) UpdateUser(ctx context.Context, userID uuid.UUID, req model.UpdateUserRequest) (*model.User, error) {
+	var hashedPassword []byte
+	if req.Password != "" {
+		var err error
+		if hashedPassword, err = bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost); err != nil {
+			return nil, err
+		}
+	}
+
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok || !m.owns(tenantID, userID) {
+		return nil, model.ErrNotFound
+	}
+	if req.Username != "" {
+		for _, u := range m.users {
+			if u.ID != userID && m.owns(tenantID, u.ID) && u.Username == req.Username {
+				return nil, uniqueViolation("users_username_key")
+			}
+		}
+	}
+
+	changed := false
+	set := func(dst *string, value string) {
+		if value != "" {
+			*dst = value
+			changed = true
+		}
+	}
+	set(&user.FirstName, req.FirstName)
+	set(&user.LastName, req.LastName)
+	set(&user.Username, req.Username)
+	set(&user.Password, string(hashedPassword))
+	if hashedPassword != nil {
+		user.PasswordResetRequired = false
+	}
+	if changed {
+		user.AccountUpdated = now()
+	}
+	return publicUser(user), nil
+}
+
+func (m *Memory) ListUsers(ctx context.Context, opts model.ListOptions) (*model.Page[model.User], error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	users := make([]model.User, 0, len(m.users))
+	for _, u := range m.users {
+		if m.owns(tenantID, u.ID) {
+			users = append(users, *publicUser(u))
+		}
+	}
+	m.mu.RUnlock()
+	return model.PaginateUsers(users, opts)
+}
+
+func (m *Memory) LoginExternal(ctx context.Context, identity model.ExternalIdentity, role string) (*model.User, bool, error) {
+	tenantID := tenant.ID(ctx)
+	key := memoryIdentityKey{tenantID: tenantID, provider: identity.Provider, subject: identity.Subject}
+
+	m.mu.Lock()
+	if userID, ok := m.identities[key]; ok {
+		user := publicUser(m.users[userID])
+		m.mu.Unlock()
+		return user, false, nil
+	}
+	if identity.EmailVerified {
+		for _, u := range m.users {
+			if m.owns(tenantID, u.ID) && strings.EqualFold(u.Email, identity.Email) {
+				m.identities[key] = u.ID
+				user := publicUser(u)
+				m.mu.Unlock()
+				return user, false, nil
+			}
+		}
+	}
+	taken := map[string]bool{}
+	for _, u := range m.users {
+		if m.owns(tenantID, u.ID) {
+			taken[u.Username] = true
+		}
+	}
+	m.mu.Unlock()
+
+	base := identity.UsernameBase()
+	username := base
+	for n := 1; taken[username]; n++ {
+		username = model.UsernameCandidate(base, n)
+	}
+	req, err := identity.ProvisionRequest(username, role)
+	if err != nil {
+		return nil, false, err
+	}
+	// CreateUser hashes the password outside the lock, so a concurrent
+	// login may take the username first; it then fails as it would in Postgres
+	user, err := m.CreateUser(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+	m.mu.Lock()
+	m.identities[key] = user.ID
+	m.mu.Unlock()
+	return user, true, nil
+}
+
+func (m *Memory) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.owns(tenant.ID(ctx), userID) {
+		return model.DefaultNotificationPreferences(), nil
+	}
+	return m.notificationPreferences(userID), nil
+}
+
+func (m *Memory) UpdateNotificationPreferences(ctx context.Context, userID uuid.UUID, req model.UpdateNotificationPreferencesRequest) (*model.NotificationPreferences, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; !ok || !m.owns(tenant.ID(ctx), userID) {
+		return nil, model.ErrNotFound
+	}
+	set, ok := m.prefs[userID]
+	if !ok {
+		set = &model.NotificationPreferences{Email: map[string]bool{}, InApp: map[string]bool{}}
+		m.prefs[userID] = set
+	}
+	maps.Copy(set.Email, req.Email)
+	maps.Copy(set.InApp, req.InApp)
+	return m.notificationPreferences(userID), nil
+}
+
+// notificationPreferences fills in the user's settings over the defaults;
+// callers hold mu
+func (m *Memory) notificationPreferences(userID uuid.UUID) *model.NotificationPreferences {
+	prefs := model.DefaultNotificationPreferences()
+	if set, ok := m.prefs[userID]; ok {
+		maps.Copy(prefs.Email, set.Email)
+		maps.Copy(prefs.InApp, set.InApp)
+	}
+	return prefs
+}
+
+func (m *Memory) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, opts model.ListOptions) (*model.Page[model.Notification], error) {
+	m.mu.RLock()
+	var notifications []model.Notification
+	if m.owns(tenant.ID(ctx), userID) {
+		for _, n := range m.notifications {
+			if n.userID == userID && (!unreadOnly || n.ReadAt == nil) {
+				notifications = append(notifications, n.Notification)
+			}
+		}
+	}
+	m.mu.RUnlock()
+	return model.PaginateNotifications(notifications, opts)
+}
+
+func (m *Memory) SetNotificationRead(ctx context.Context, userID, notificationID uuid.UUID, read bool) (*model.Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.owns(tenant.ID(ctx), userID) {
+		return nil, model.ErrNotFound
+	}
+	for _, n := range m.notifications {
+		if n.ID != notificationID || n.userID != userID {
+			continue
+		}
+		switch {
+		case !read:
+			n.ReadAt = nil
+		case n.ReadAt == nil:
+			readAt := now()
+			n.ReadAt = &readAt
+		}
+		copied := n.Notification
+		return &copied, nil
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *Memory) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.owns(tenant.ID(ctx), userID) {
+		return 0, nil
+	}
+	marked := 0
+	readAt := now()
+	for _, n := range m.notifications {
+		if n.userID == userID && n.ReadAt == nil {
+			n.ReadAt = &readAt
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+// notify adds n to the feed of each user who hasn't turned its type off;
+// callers hold mu
+func (m *Memory) notify(userIDs []uuid.UUID, n model.Notification) {
+	for _, userID := range userIDs {
+		if _, ok := m.users[userID]; !ok || !m.notificationPreferences(userID).InAppEnabled(n.Type) {
+			continue
+		}
+		entry := &memoryNotification{Notification: n, userID: userID}
+		entry.ID = uuid.New()
+		entry.DateCreated = now()
+		m.notifications = append(m.notifications, entry)
+	}
+}
+
+func (m *Memory) CreateSession(ctx context.Context, session model.Session) (*model.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.createSession(ctx, session)
+}
+
+func (m *Memory) StartImpersonation(ctx context.Context, session model.Session) (*model.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[*session.ImpersonatedBy]; !ok {
+		return nil, foreignKeyViolation("sessions_impersonated_by_fkey")
+	}
+	created, err := m.createSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	entry := model.UserImpersonatedAuditEntry(created)
+	entry.ID = uuid.New()
+	entry.DateCreated = created.DateCreated
+	m.audit = append(m.audit, entry)
+	return created, nil
+}
+
+// createSession records a token being issued; callers hold mu
+func (m *Memory) createSession(ctx context.Context, session model.Session) (*model.Session, error) {
+	if _, ok := m.users[session.UserID]; !ok || !m.owns(tenant.ID(ctx), session.UserID) {
+		return nil, foreignKeyViolation("sessions_user_id_fkey")
+	}
+	if _, ok := m.sessions[session.ID]; ok {
+		return nil, uniqueViolation("sessions_pkey")
+	}
+	for id, s := range m.sessions {
+		if s.UserID == session.UserID && !s.ExpiresAt.After(now()) {
+			delete(m.sessions, id)
+		}
+	}
+	session.DateCreated = now()
+	session.LastSeen = session.DateCreated
+	session.ExpiresAt = session.ExpiresAt.UTC().Truncate(time.Microsecond)
+	session.Current = false
+	m.sessions[session.ID] = &session
+	c := session
+	return &c, nil
+}
+
+func (m *Memory) TouchSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	if !ok || s.UserID != userID || !m.owns(tenant.ID(ctx), userID) || !s.ExpiresAt.After(now()) {
+		return model.ErrNotFound
+	}
+	s.LastSeen = now()
+	return nil
+}
+
+func (m *Memory) ListSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sessions := []model.Session{}
+	if !m.owns(tenant.ID(ctx), userID) {
+		return sessions, nil
+	}
+	for _, s := range m.sessions {
+		if s.UserID == userID && s.ExpiresAt.After(now()) {
+			sessions = append(sessions, *s)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b model.Session) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return sessions, nil
+}
+
+func (m *Memory) DeleteSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	if !ok || s.UserID != userID || !m.owns(tenant.ID(ctx), userID) {
+		return model.ErrNotFound
+	}
+	delete(m.sessions, sessionID)
+	return nil
+}
+
+func (m *Memory) DeleteSessions(ctx context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.owns(tenant.ID(ctx), userID) {
+		m.deleteSessions(userID)
+	}
+	return nil
+}
+
+// deleteSessions revokes every token the user holds; callers hold mu
+func (m *Memory) deleteSessions(userID uuid.UUID) {
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+func (m *Memory) GetMFA(ctx context.Context, userID uuid.UUID) (*model.MFA, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mfa, ok := m.mfa[userID]
+	if !ok || !m.owns(tenant.ID(ctx), userID) {
+		return nil, model.ErrNotFound
+	}
+	return copyMFA(mfa), nil
+}
+
+func (m *Memory) SaveMFAEnrollment(ctx context.Context, userID uuid.UUID, secret string, recoveryCodes []string) (*model.MFA, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; !ok || !m.owns(tenant.ID(ctx), userID) {
+		return nil, model.ErrNotFound
+	}
+	if existing, ok := m.mfa[userID]; ok && existing.Enabled {
+		return nil, model.ErrMFAEnabled
+	}
+	mfa := &model.MFA{UserID: userID, Secret: secret, RecoveryCodes: slices.Clone(recoveryCodes), DateCreated: now()}
+	m.mfa[userID] = mfa
+	return copyMFA(mfa), nil
+}
+
+func (m *Memory) UseMFAStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mfa, ok := m.mfa[userID]
+	if !ok || !m.owns(tenant.ID(ctx), userID) || mfa.LastUsedStep >= step {
+		return model.ErrMFACodeUsed
+	}
+	mfa.LastUsedStep = step
+	if !mfa.Enabled {
+		enabled := now()
+		mfa.Enabled = true
+		mfa.DateEnabled = &enabled
+	}
+	return nil
+}
+
+func (m *Memory) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mfa, ok := m.mfa[userID]
+	if !ok || !m.owns(tenant.ID(ctx), userID) || !mfa.Enabled {
+		return model.ErrMFACodeUsed
+	}
+	i := slices.Index(mfa.RecoveryCodes, hash)
+	if i < 0 {
+		return model.ErrMFACodeUsed
+	}
+	mfa.RecoveryCodes = slices.Delete(mfa.RecoveryCodes, i, i+1)
+	return nil
+}
+
+func (m *Memory) CountMFAAttempt(ctx context.Context, userID, challengeID uuid.UUID, challengeExpires time.Time, limits model.MFALimits) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mfa, ok := m.mfa[userID]
+	if !ok || !m.owns(tenant.ID(ctx), userID) {
+		return model.ErrNotFound
+	}
+	current := now()
+	if mfa.LockedUntil != nil && mfa.LockedUntil.After(current) {
+		return &model.MFALockedError{Until: *mfa.LockedUntil}
+	}
+	if challengeID != uuid.Nil {
+		for id, c := range m.mfaChallenges {
+			if c.userID == userID && !c.expiresAt.After(current) {
+				delete(m.mfaChallenges, id)
+			}
+		}
+		challenge, ok := m.mfaChallenges[challengeID]
+		if !ok {
+			challenge = &memoryMFAChallenge{userID: userID, expiresAt: challengeExpires}
+			m.mfaChallenges[challengeID] = challenge
+		}
+		if challenge.attempts >= limits.ChallengeAttempts {
+			return model.ErrMFAChallengeSpent
+		}
+		challenge.attempts++
+	}
+	mfa.FailedAttempts++
+	if mfa.FailedAttempts >= limits.FailedAttempts {
+		lockedUntil := current.Add(limits.Lockout)
+		mfa.LockedUntil = &lockedUntil
+	}
+	return nil
+}
+
+func (m *Memory) ClearMFAAttempts(ctx context.Context, userID, challengeID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.mfaChallenges, challengeID)
+	if mfa, ok := m.mfa[userID]; ok && m.owns(tenant.ID(ctx), userID) {
+		mfa.FailedAttempts = 0
+		mfa.LockedUntil = nil
+	}
+	return nil
+}
+
+func (m *Memory) DeleteMFA(ctx context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.mfa[userID]; !ok || !m.owns(tenant.ID(ctx), userID) {
+		return model.ErrNotFound
+	}
+	delete(m.mfa, userID)
+	return nil
+}
+
+// copyMFA copies a stored enrollment, counting its recovery codes
+func copyMFA(mfa *model.MFA) *model.MFA {
+	c := *mfa
+	c.RecoveryCodes = slices.Clone(mfa.RecoveryCodes)
+	c.RecoveryCodesRemaining = len(c.RecoveryCodes)
+	return &c
+}
+
+// Service accounts
+
+func (m *Memory) CreateServiceAccount(ctx context.Context, req model.CreateServiceAccountRequest, keyHash string, createdBy uuid.UUID) (*model.ServiceAccount, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.serviceAccountNameTaken(tenantID, req.Name, uuid.Nil) {
+		return nil, uniqueViolation("service_accounts_name_key")
+	}
+	ts := now()
+	a := &memoryServiceAccount{
+		ServiceAccount: model.ServiceAccount{
+			ID:          uuid.New(),
+			Name:        req.Name,
+			Description: req.Description,
+			Scopes:      slices.Clone(req.Scopes),
+			CreatedBy:   &createdBy,
+			DateCreated: ts,
+			DateUpdated: ts,
+		},
+		tenantID: tenantID,
+		keyHash:  keyHash,
+	}
+	m.serviceAccounts[a.ID] = a
+	return copyServiceAccount(a), nil
+}
+
+func (m *Memory) ListServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	accounts := []model.ServiceAccount{}
+	for _, a := range m.serviceAccounts {
+		if a.tenantID == tenantID {
+			accounts = append(accounts, *copyServiceAccount(a))
+		}
+	}
+	slices.SortFunc(accounts, func(a, b model.ServiceAccount) int { return strings.Compare(a.Name, b.Name) })
+	return accounts, nil
+}
+
+func (m *Memory) GetServiceAccount(ctx context.Context, accountID uuid.UUID) (*model.ServiceAccount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.serviceAccounts[accountID]
+	if !ok || a.tenantID != tenant.ID(ctx) {
+		return nil, model.ErrNotFound
+	}
+	return copyServiceAccount(a), nil
+}
+
+func (m *Memory) UpdateServiceAccount(ctx context.Context, accountID uuid.UUID, req model.UpdateServiceAccountRequest) (*model.ServiceAccount, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.serviceAccounts[accountID]
+	if !ok || a.tenantID != tenantID {
+		return nil, model.ErrNotFound
+	}
+	if req.Name != nil && m.serviceAccountNameTaken(tenantID, *req.Name, accountID) {
+		return nil, uniqueViolation("service_accounts_name_key")
+	}
+	if req.Name != nil {
+		a.Name = *req.Name
+	}
+	if req.Description != nil {
+		a.Description = req.Description
+	}
+	if req.Scopes != nil {
+		a.Scopes = slices.Clone(req.Scopes)
+	}
+	a.DateUpdated = now()
+	return copyServiceAccount(a), nil
+}
+
+func (m *Memory) RotateServiceAccountKey(ctx context.Context, accountID uuid.UUID, keyHash string) (*model.ServiceAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.serviceAccounts[accountID]
+	if !ok || a.tenantID != tenant.ID(ctx) {
+		return nil, model.ErrNotFound
+	}
+	a.keyHash = keyHash
+	a.DateUpdated = now()
+	return copyServiceAccount(a), nil
+}
+
+func (m *Memory) DeleteServiceAccount(ctx context.Context, accountID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.serviceAccounts[accountID]
+	if !ok || a.tenantID != tenant.ID(ctx) {
+		return model.ErrNotFound
+	}
+	delete(m.serviceAccounts, accountID)
+	return nil
+}
+
+func (m *Memory) AuthenticateServiceAccount(ctx context.Context, key string) (*model.ServiceAccount, error) {
+	tenantID := tenant.ID(ctx)
+	keyHash := model.HashAPIKey(key)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.serviceAccounts {
+		if a.tenantID == tenantID && a.keyHash == keyHash {
+			used := now()
+			a.LastUsed = &used
+			return copyServiceAccount(a), nil
+		}
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *Memory) serviceAccountNameTaken(tenantID uuid.UUID, name string, except uuid.UUID) bool {
+	for id, a := range m.serviceAccounts {
+		if id != except && a.tenantID == tenantID && a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func copyServiceAccount(a *memoryServiceAccount) *model.ServiceAccount {
+	c := a.ServiceAccount
+	c.Scopes = slices.Clone(a.Scopes)
+	return &c
+}
+
+// publicUser copies a stored user without its password hash
+func publicUser(u *model.User) *model.User {
+	user := *u
+	user.Password = ""
+	return &user
+}
+
+func (m *Memory) AdminUpdateUser(ctx context.Context, userID uuid.UUID, req model.AdminUpdateUserRequest, adminID uuid.UUID) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok || !m.owns(tenant.ID(ctx), userID) {
+		return nil, model.ErrNotFound
+	}
+	previous := *user
+	ts := now()
+	if req.Role != nil {
+		user.Role = *req.Role
+	}
+	if req.Active != nil {
+		switch {
+		case *req.Active:
+			user.DeactivatedAt = nil
+		case user.DeactivatedAt == nil:
+			user.DeactivatedAt = &ts
+		}
+	}
+	if req.RequirePasswordReset != nil {
+		user.PasswordResetRequired = *req.RequirePasswordReset
+	}
+	user.AccountUpdated = ts
+	if req.RevokesSessions() {
+		m.deleteSessions(userID)
+	}
+
+	if entry := model.UserUpdatedAuditEntry(&previous, user, req.RevokesSessions(), adminID); len(entry.Changes) > 0 {
+		entry.ID = uuid.New()
+		entry.DateCreated = ts
+		m.audit = append(m.audit, entry)
+	}
+	return publicUser(user), nil
+}
+
+// Instructors
+
+func (m *Memory) CreateInstructor(ctx context.Context, req model.CreateInstructorRequest, userID uuid.UUID) (*model.Instructor, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return nil, foreignKeyViolation("instructors_user_id_fkey")
+	}
+	if m.instructorEmailTaken(tenantID, req.Email, uuid.Nil) {
+		return nil, uniqueViolation("instructors_email_key")
+	}
+
+	ts := now()
+	instructor := &model.Instructor{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Name:        req.Name,
+		Email:       req.Email,
+		DateAdded:   ts,
+		DateUpdated: ts,
+	}
+	m.instructors[instructor.ID] = instructor
+	m.owner[instructor.ID] = tenantID
+	copied := *instructor
+	return &copied, nil
+}
+
+func (m *Memory) CreateInstructors(ctx context.Context, reqs []model.CreateInstructorRequest, userID uuid.UUID) ([]model.Instructor, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(reqs) == 0 {
+		return []model.Instructor{}, nil
+	}
+	if _, ok := m.users[userID]; !ok {
+		return nil, foreignKeyViolation("instructors_user_id_fkey")
+	}
+	emails := make(map[string]bool, len(reqs))
+	for _, req := range reqs {
+		if emails[req.Email] || m.instructorEmailTaken(tenantID, req.Email, uuid.Nil) {
+			return nil, uniqueViolation("instructors_email_key")
+		}
+		emails[req.Email] = true
+	}
+
+	ts := now()
+	instructors := make([]model.Instructor, len(reqs))
+	for i, req := range reqs {
+		instructor := &model.Instructor{
+			ID:          uuid.New(),
+			UserID:      userID,
+			Name:        req.Name,
+			Email:       req.Email,
+			DateAdded:   ts,
+			DateUpdated: ts,
+		}
+		m.instructors[instructor.ID] = instructor
+		m.owner[instructor.ID] = tenantID
+		instructors[i] = *instructor
+	}
+	return instructors, nil
+}
+
+func (m *Memory) GetInstructorByID(ctx context.Context, instructorID uuid.UUID) (*model.Instructor, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	instructor, ok := m.instructors[instructorID]
+	if !ok || !m.owns(tenant.ID(ctx), instructorID) {
+		return nil, model.ErrNotFound
+	}
+	copied := *instructor
+	return &copied, nil
+}
+
+func (m *Memory) GetInstructorByEmail(ctx context.Context, email string) (*model.Instructor, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found *model.Instructor
+	for _, i := range m.instructors {
+		if !m.owns(tenantID, i.ID) || !strings.EqualFold(i.Email, email) {
+			continue
+		}
+		if found == nil || i.DateAdded.Before(found.DateAdded) {
+			found = i
+		}
+	}
+	if found == nil {
+		return nil, model.ErrNotFound
+	}
+	copied := *found
+	return &copied, nil
+}
+
+func (m *Memory) ListInstructors(ctx context.Context, opts model.ListOptions) (*model.Page[model.Instructor], error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	instructors := make([]model.Instructor, 0, len(m.instructors))
+	for _, i := range m.instructors {
+		if m.owns(tenantID, i.ID) {
+			instructors = append(instructors, *i)
+		}
+	}
+	m.mu.RUnlock()
+	return model.PaginateInstructors(instructors, opts)
+}
+
+func (m *Memory) UpdateInstructor(ctx context.Context, instructorID uuid.UUID, req model.UpdateInstructorRequest) (*model.Instructor, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	instructor, ok := m.instructors[instructorID]
+	if !ok || !m.owns(tenantID, instructorID) {
+		return nil, model.ErrNotFound
+	}
+	if req.Email != nil && m.instructorEmailTaken(tenantID, *req.Email, instructorID) {
+		return nil, uniqueViolation("instructors_email_key")
+	}
+
+	if req.Name != nil {
+		instructor.Name = *req.Name
+	}
+	if req.Email != nil {
+		instructor.Email = *req.Email
+	}
+	if req.Name != nil || req.Email != nil {
+		instructor.DateUpdated = now()
+	}
+	copied := *instructor
+	return &copied, nil
+}
+
+func (m *Memory) DeleteInstructorByID(ctx context.Context, instructorID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.instructors[instructorID]; !ok || !m.owns(tenant.ID(ctx), instructorID) {
+		return model.ErrNotFound
+	}
+	for _, c := range m.courses {
+		if c.InstructorID == instructorID {
+			return foreignKeyViolation("courses_instructor_id_fkey")
+		}
+	}
+	for _, t := range m.traces {
+		if t.InstructorID == instructorID {
+			return foreignKeyViolation("traces_instructor_id_fkey")
+		}
+	}
+	for _, link := range m.canvasLinks {
+		for i, e := range link.roster {
+			if e.InstructorID != nil && *e.InstructorID == instructorID {
+				link.roster[i].InstructorID = nil
+			}
+		}
+	}
+	delete(m.instructors, instructorID)
+	delete(m.owner, instructorID)
+	return nil
+}
+
+func (m *Memory) instructorEmailTaken(tenantID uuid.UUID, email string, except uuid.UUID) bool {
+	for _, i := range m.instructors {
+		if i.ID != except && m.owns(tenantID, i.ID) && i.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+// Courses
+
+func (m *Memory) CreateCourse(ctx context.Context, req model.CreateCourseRequest, userID uuid.UUID) (*model.Course, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return nil, foreignKeyViolation("courses_user_id_fkey")
+	}
+	if err := m.checkCourseInstructor(tenantID, req.InstructorID); err != nil {
+		return nil, err
+	}
+	if err := m.charge(tenantID, model.UsageDelta{Courses: 1}); err != nil {
+		return nil, err
+	}
+
+	ts := now()
+	course := &model.Course{
+		ID:           uuid.New(),
+		Name:         req.Name,
+		SemesterTerm: req.SemesterTerm,
+		CreditHours:  req.CreditHours,
+		SubjectCode:  req.SubjectCode,
+		CourseID:     req.CourseID,
+		SemesterYear: req.SemesterYear,
+		DateCreated:  ts,
+		DateUpdated:  ts,
+		UserID:       userID,
+		InstructorID: req.InstructorID,
+	}
+	m.courses[course.ID] = course
+	m.owner[course.ID] = tenantID
+	m.addCourseVersion(course.ID, model.NewCourseVersion(course, nil))
+	m.appendEvents(tenantID, model.CourseCreatedEvent(course, userID))
+	copied := *course
+	return &copied, nil
+}
+
+func (m *Memory) CreateCourses(ctx context.Context, reqs []model.CreateCourseRequest, userID uuid.UUID) ([]model.Course, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(reqs) == 0 {
+		return []model.Course{}, nil
+	}
+	if _, ok := m.users[userID]; !ok {
+		return nil, foreignKeyViolation("courses_user_id_fkey")
+	}
+	for _, req := range reqs {
+		if err := m.checkCourseInstructor(tenantID, req.InstructorID); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.charge(tenantID, model.UsageDelta{Courses: int64(len(reqs))}); err != nil {
+		return nil, err
+	}
+
+	ts := now()
+	courses := make([]model.Course, len(reqs))
+	for i, req := range reqs {
+		course := &model.Course{
+			ID:           uuid.New(),
+			Name:         req.Name,
+			SemesterTerm: req.SemesterTerm,
+			CreditHours:  req.CreditHours,
+			SubjectCode:  req.SubjectCode,
+			CourseID:     req.CourseID,
+			SemesterYear: req.SemesterYear,
+			DateCreated:  ts,
+			DateUpdated:  ts,
+			UserID:       userID,
+			InstructorID: req.InstructorID,
+		}
+		m.courses[course.ID] = course
+		m.owner[course.ID] = tenantID
+		m.addCourseVersion(course.ID, model.NewCourseVersion(course, nil))
+		m.appendEvents(tenantID, model.CourseCreatedEvent(course, userID))
+		courses[i] = *course
+	}
+	return courses, nil
+}
+
+// checkCourseInstructor enforces the course's instructor foreign keys,
+// including the one that keeps it within the course's tenant
+func (m *Memory) checkCourseInstructor(tenantID, instructorID uuid.UUID) error {
+	if _, ok := m.instructors[instructorID]; !ok {
+		return foreignKeyViolation("courses_instructor_id_fkey")
+	}
+	if !m.owns(tenantID, instructorID) {
+		return foreignKeyViolation("courses_tenant_instructor_fkey")
+	}
+	return nil
+}
+
+func (m *Memory) GetCourseByID(ctx context.Context, courseID uuid.UUID) (*model.Course, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	course, ok := m.courses[courseID]
+	if !ok || !m.owns(tenant.ID(ctx), courseID) {
+		return nil, model.ErrNotFound
+	}
+	copied := *course
+	return &copied, nil
+}
+
+func (m *Memory) GetCourseExpanded(ctx context.Context, courseID uuid.UUID, expand model.CourseExpand) (*model.Course, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	course, ok := m.courses[courseID]
+	if !ok || !m.owns(tenant.ID(ctx), courseID) {
+		return nil, model.ErrNotFound
+	}
+	copied := *course
+	if expand.Instructor {
+		instructor, ok := m.instructors[course.InstructorID]
+		if !ok {
+			return nil, model.ErrNotFound
+		}
+		copiedInstructor := *instructor
+		copied.Instructor = &copiedInstructor
+	}
+	if expand.Traces {
+		var summary model.CourseTraces
+		for _, t := range m.traces {
+			if t.courseID != courseID {
+				continue
+			}
+			summary.Count++
+			latest := summary.Latest
+			if latest == nil || t.DateCreated.After(latest.DateCreated) ||
+				(t.DateCreated.Equal(latest.DateCreated) && t.ID.String() > latest.ID.String()) {
+				summary.Latest = &model.LatestTrace{ID: t.ID, FileName: t.FileName, Status: t.Status, DateCreated: t.DateCreated}
+			}
+		}
+		copied.Traces = &summary
+	}
+	return &copied, nil
+}
+
+func (m *Memory) GetCourseBySection(ctx context.Context, subjectCode string, courseID int, semesterTerm string, semesterYear int) (*model.Course, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found *model.Course
+	for _, c := range m.courses {
+		if !m.owns(tenantID, c.ID) || c.SubjectCode != subjectCode || c.CourseID != courseID ||
+			c.SemesterTerm != semesterTerm || c.SemesterYear != semesterYear {
+			continue
+		}
+		if found == nil || c.DateCreated.Before(found.DateCreated) {
+			found = c
+		}
+	}
+	if found == nil {
+		return nil, model.ErrNotFound
+	}
+	copied := *found
+	return &copied, nil
+}
+
+func (m *Memory) ListCourses(ctx context.Context, archived bool, opts model.ListOptions) (*model.Page[model.Course], error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	courses := make([]model.Course, 0, len(m.courses))
+	for _, c := range m.courses {
+		if m.owns(tenantID, c.ID) && (c.ArchivedAt != nil) == archived {
+			courses = append(courses, *c)
+		}
+	}
+	m.mu.RUnlock()
+	return model.PaginateCourses(courses, opts)
+}
+
+func (m *Memory) StreamCourses(ctx context.Context, archived bool, opts model.ListOptions, fn func(model.Course) error) error {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	courses := make([]model.Course, 0, len(m.courses))
+	for _, c := range m.courses {
+		if m.owns(tenantID, c.ID) && (c.ArchivedAt != nil) == archived {
+			courses = append(courses, *c)
+		}
+	}
+	m.mu.RUnlock()
+
+	// The lock isn't held while fn runs, so a slow client can't block writers
+	courses, err := model.SortCourses(courses, opts)
+	if err != nil {
+		return err
+	}
+	for _, c := range courses {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) UpdateCourse(ctx context.Context, courseID uuid.UUID, req model.UpdateCourseRequest, userID uuid.UUID) (*model.Course, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	course, ok := m.courses[courseID]
+	if !ok || !m.owns(tenantID, courseID) {
+		return nil, model.ErrNotFound
+	}
+	if _, ok := m.users[userID]; !ok {
+		return nil, foreignKeyViolation("courses_user_id_fkey")
+	}
+	if req.InstructorID != nil {
+		if err := m.checkCourseInstructor(tenantID, *req.InstructorID); err != nil {
+			return nil, err
+		}
+	}
+
+	m.updateCourse(course, req, userID)
+	copied := *course
+	return &copied, nil
+}
+
+func (m *Memory) BulkUpdateCourses(ctx context.Context, filter model.CourseFilter, req model.UpdateCourseRequest, userID uuid.UUID, dryRun bool) ([]model.CourseChange, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return nil, foreignKeyViolation("courses_user_id_fkey")
+	}
+	if req.InstructorID != nil {
+		if err := m.checkCourseInstructor(tenantID, *req.InstructorID); err != nil {
+			return nil, err
+		}
+	}
+
+	var matched []*model.Course
+	for _, c := range m.courses {
+		if m.owns(tenantID, c.ID) && matchesCourseFilter(c, filter) {
+			matched = append(matched, c)
+		}
+	}
+	// The order of Postgres's LockCourses
+	slices.SortFunc(matched, func(a, b *model.Course) int {
+		return cmp.Or(
+			cmp.Compare(a.SubjectCode, b.SubjectCode),
+			cmp.Compare(a.CourseID, b.CourseID),
+			cmp.Compare(a.SemesterYear, b.SemesterYear),
+			cmp.Compare(a.SemesterTerm, b.SemesterTerm),
+			cmp.Compare(a.ID.String(), b.ID.String()),
+		)
+	})
+
+	results := make([]model.CourseChange, 0, len(matched))
+	for _, course := range matched {
+		updated := req.Apply(*course)
+		changes := model.DiffCourses(course, &updated)
+		if !dryRun && len(changes) > 0 {
+			m.updateCourse(course, req, userID)
+			updated = *course
+		}
+		results = append(results, model.CourseChange{Course: updated, Changes: changes})
+	}
+	return results, nil
+}
+
+func matchesCourseFilter(c *model.Course, filter model.CourseFilter) bool {
+	return (filter.SemesterTerm == nil || c.SemesterTerm == *filter.SemesterTerm) &&
+		(filter.SemesterYear == nil || c.SemesterYear == *filter.SemesterYear) &&
+		(filter.SubjectCode == nil || c.SubjectCode == *filter.SubjectCode) &&
+		(filter.InstructorID == nil || c.InstructorID == *filter.InstructorID)
+}
+
+// updateCourse applies req to course, records it in the audit log, course
+// history and event ledger and tells the course's other uploaders, as
+// Postgres.UpdateCourse does. The caller holds m.mu.
+func (m *Memory) updateCourse(course *model.Course, req model.UpdateCourseRequest, userID uuid.UUID) {
+	previous := *course
+	*course = req.Apply(*course)
+	course.UserID = userID
+	course.DateUpdated = now()
+
+	changes := model.DiffCourses(&previous, course)
+	m.audit = append(m.audit, model.AuditEntry{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Action:      "course.updated",
+		EntityType:  "course",
+		EntityID:    course.ID,
+		Changes:     changes,
+		DateCreated: course.DateUpdated,
+	})
+	if version := model.NewCourseVersion(course, changes); len(version.Changes) > 0 {
+		m.addCourseVersion(course.ID, version)
+		m.appendEvents(m.owner[course.ID], model.CourseUpdatedEvent(course, version))
+	}
+
+	delete(changes, "user_id")
+	if len(changes) > 0 {
+		var uploaders []uuid.UUID
+		for _, t := range m.traces {
+			if t.courseID == course.ID && t.UserID != userID && !slices.Contains(uploaders, t.UserID) {
+				uploaders = append(uploaders, t.UserID)
+			}
+		}
+		m.notify(uploaders, model.CourseUpdatedNotification(course, changes))
+	}
+}
+
+// addCourseVersion records v as the next version of a course. The caller
+// holds m.mu.
+func (m *Memory) addCourseVersion(courseID uuid.UUID, v model.CourseVersion) {
+	v.ID = uuid.New()
+	v.Version = len(m.courseVersions[courseID]) + 1
+	m.courseVersions[courseID] = append(m.courseVersions[courseID], v)
+}
+
+// appendEvents adds events to the ledger, numbering each after the last
+// event of its aggregate. The caller holds m.mu.
+func (m *Memory) appendEvents(tenantID uuid.UUID, events ...model.Event) {
+	for _, e := range events {
+		e.ID = uuid.New()
+		e.Position = len(m.events) + 1
+		e.Sequence = 1
+		for _, prior := range m.events {
+			if prior.AggregateID == e.AggregateID {
+				e.Sequence = prior.Sequence + 1
+			}
+		}
+		if e.Data == nil {
+			e.Data = map[string]any{}
+		}
+		e.DateCreated = now()
+		m.events = append(m.events, memoryEvent{Event: e, tenantID: tenantID})
+	}
+}
+
+func (m *Memory) SetCourseArchived(ctx context.Context, courseID uuid.UUID, archived bool, userID uuid.UUID) (*model.Course, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	course, ok := m.courses[courseID]
+	if !ok || !m.owns(tenantID, courseID) {
+		return nil, model.ErrNotFound
+	}
+	if (course.ArchivedAt != nil) != archived {
+		previous := *course
+		course.DateUpdated = now()
+		course.ArchivedAt = nil
+		if archived {
+			ts := course.DateUpdated
+			course.ArchivedAt = &ts
+		}
+		entry := model.CourseArchivedAuditEntry(&previous, course, userID)
+		entry.ID = uuid.New()
+		entry.DateCreated = course.DateUpdated
+		m.audit = append(m.audit, entry)
+		m.appendEvents(tenantID, model.CourseArchivedEvent(course, userID))
+	}
+	copied := *course
+	return &copied, nil
+}
+
+func (m *Memory) DeleteCourseByID(ctx context.Context, courseID uuid.UUID) error {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.courses[courseID]; !ok || !m.owns(tenantID, courseID) {
+		return model.ErrNotFound
+	}
+	for _, t := range m.traces {
+		if t.courseID == courseID {
+			return foreignKeyViolation("traces_course_id_fkey")
+		}
+	}
+	delete(m.courses, courseID)
+	delete(m.owner, courseID)
+	delete(m.courseVersions, courseID)
+	for _, saved := range m.favorites {
+		delete(saved, courseID)
+	}
+	delete(m.canvasLinks, courseID)
+	m.appendEvents(tenantID, model.CourseEvent(model.EventCourseDeleted, courseID, nil, nil))
+	return m.charge(tenantID, model.UsageDelta{Courses: -1})
+}
+
+func (m *Memory) ListCourseEvents(ctx context.Context, courseID uuid.UUID, opts model.ListOptions) (*model.Page[model.Event], error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	var events []model.Event
+	for _, e := range m.events {
+		if e.tenantID == tenantID && e.CourseID == courseID {
+			events = append(events, e.Event)
+		}
+	}
+	m.mu.RUnlock()
+	return model.PaginateEvents(events, opts)
+}
+
+func (m *Memory) ListCourseVersions(ctx context.Context, courseID uuid.UUID, opts model.ListOptions) (*model.Page[model.CourseVersion], error) {
+	m.mu.RLock()
+	var versions []model.CourseVersion
+	if m.owns(tenant.ID(ctx), courseID) {
+		versions = slices.Clone(m.courseVersions[courseID])
+	}
+	m.mu.RUnlock()
+	return model.PaginateCourseVersions(versions, opts)
+}
+
+func (m *Memory) GetCourseVersion(ctx context.Context, courseID uuid.UUID, version int) (*model.CourseVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	versions := m.courseVersions[courseID]
+	if !m.owns(tenant.ID(ctx), courseID) || version < 1 || version > len(versions) {
+		return nil, model.ErrNotFound
+	}
+	copied := versions[version-1]
+	return &copied, nil
+}
+
+// Traces
+
+func (m *Memory) InsertTrace(ctx context.Context, t NewTrace) (*model.Trace, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, err := m.insertTrace(tenantID, t)
+	if err != nil {
+		return nil, err
+	}
+	m.appendEvents(tenantID, model.TraceUploadedEvent(t.CourseID, trace))
+	return trace, nil
+}
+
+func (m *Memory) InsertTraceWithEvent(ctx context.Context, t NewTrace, topic string, payload []byte) (*model.Trace, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, err := m.insertTrace(tenantID, t)
+	if err != nil {
+		return nil, err
+	}
+	publishStatus := model.PublishStatusPending
+	m.traces[trace.ID].PublishStatus = &publishStatus
+	trace.PublishStatus = &publishStatus
+	m.appendEvents(tenantID, model.TraceUploadedEvent(t.CourseID, trace))
+	m.outbox = append(m.outbox, &model.OutboxEvent{
+		ID:          uuid.New(),
+		Topic:       topic,
+		AggregateID: trace.ID,
+		Payload:     append([]byte(nil), payload...),
+		Status:      model.OutboxStatusPending,
+		DateCreated: trace.DateCreated,
+	})
+	return trace, nil
+}
+
+func (m *Memory) insertTrace(tenantID uuid.UUID, t NewTrace) (*model.Trace, error) {
+	if _, ok := m.users[t.UserID]; !ok {
+		return nil, foreignKeyViolation("traces_user_id_fkey")
+	}
+	if _, ok := m.instructors[t.InstructorID]; !ok {
+		return nil, foreignKeyViolation("traces_instructor_id_fkey")
+	}
+	if _, ok := m.courses[t.CourseID]; !ok {
+		return nil, foreignKeyViolation("traces_course_id_fkey")
+	}
+
+	ts := now()
+	trace := &memoryTrace{
+		Trace: model.Trace{
+			ID:           t.traceID(),
+			UserID:       t.UserID,
+			InstructorID: t.InstructorID,
+			Status:       t.Status,
+			VectorID:     t.VectorID,
+			FileName:     t.FileName,
+			BucketURL:    t.BucketURL,
+			StorageTier:  model.StorageTierStandard,
+			ContentType:  t.ContentType,
+			SizeBytes:    t.SizeBytes,
+			Visibility:   t.visibility(),
+			LinkURL:      t.LinkURL,
+			DateCreated:  ts,
+			DateUpdated:  ts,
+		},
+		courseID: t.CourseID,
+	}
+	m.traces[trace.ID] = trace
+	m.owner[trace.ID] = tenantID
+	copied := trace.Trace
+	return &copied, nil
+}
+
+func (m *Memory) GetTracesByCourseID(ctx context.Context, courseID uuid.UUID, visible []string, opts model.ListOptions) (*model.Page[model.Trace], error) {
+	m.mu.RLock()
+	traces := m.courseTraces(tenant.ID(ctx), courseID, visible)
+	m.mu.RUnlock()
+	return model.PaginateTraces(traces, opts)
+}
+
+func (m *Memory) StreamTracesByCourseID(ctx context.Context, courseID uuid.UUID, visible []string, opts model.ListOptions, fn func(model.Trace) error) error {
+	m.mu.RLock()
+	traces := m.courseTraces(tenant.ID(ctx), courseID, visible)
+	m.mu.RUnlock()
+
+	traces, err := model.SortTraces(traces, opts)
+	if err != nil {
+		return err
+	}
+	for _, t := range traces {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// courseTraces returns the tenant's traces of a course with one of the
+// visible visibilities, or all of them if visible is nil
+func (m *Memory) courseTraces(tenantID, courseID uuid.UUID, visible []string) []model.Trace {
+	var traces []model.Trace
+	for _, t := range m.traces {
+		if t.courseID != courseID || !m.owns(tenantID, t.ID) {
+			continue
+		}
+		if visible == nil || slices.Contains(visible, t.Visibility) {
+			traces = append(traces, t.Trace)
+		}
+	}
+	return traces
+}
+
+func (m *Memory) GetTraceByID(ctx context.Context, courseID, traceID uuid.UUID) (*model.Trace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) {
+		return nil, model.ErrNotFound
+	}
+	copied := trace.Trace
+	return &copied, nil
+}
+
+func (m *Memory) ExpandTraces(ctx context.Context, traces []model.Trace, expand model.TraceExpand) error {
+	if !expand.Any() {
+		return nil
+	}
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := range traces {
+		trace, ok := m.traces[traces[i].ID]
+		if !ok || !m.owns(tenantID, trace.ID) {
+			continue
+		}
+		course, ok := m.courses[trace.courseID]
+		instructor, found := m.instructors[trace.InstructorID]
+		if !ok || !found {
+			continue
+		}
+		if expand.Course {
+			copied := *course
+			traces[i].Course = &copied
+		}
+		if expand.Instructor {
+			copied := *instructor
+			traces[i].Instructor = &copied
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteTraceByID(ctx context.Context, courseID, traceID uuid.UUID) error {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenantID, traceID) {
+		return model.ErrNotFound
+	}
+	delete(m.traces, traceID)
+	delete(m.owner, traceID)
+	m.appendEvents(tenantID, model.TraceEvent(model.EventTraceDeleted, courseID, traceID, nil, nil))
+	if course, ok := m.courses[courseID]; ok {
+		course.StorageBytes = max(course.StorageBytes-trace.storedBytes(), 0)
+	}
+	return m.charge(tenantID, model.UsageDelta{StorageBytes: -trace.storedBytes()})
+}
+
+func (m *Memory) ReplaceTraceFile(ctx context.Context, courseID, traceID uuid.UUID, file model.TraceFile, userID uuid.UUID, topic string, payload []byte) (*model.Trace, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenantID, traceID) {
+		return nil, model.ErrNotFound
+	}
+	ts := now()
+	previous := model.TraceRevision{
+		Revision:     len(trace.revisions) + 1,
+		FileName:     trace.FileName,
+		BucketURL:    trace.BucketURL,
+		ContentType:  trace.ContentType,
+		SizeBytes:    trace.SizeBytes,
+		DateUploaded: trace.uploadedAt(),
+		DateReplaced: &ts,
+	}
+	trace.revisions = append(trace.revisions, previous)
+
+	publishStatus := model.PublishStatusPending
+	trace.FileName = file.FileName
+	trace.BucketURL = file.BucketURL
+	trace.ContentType = file.ContentType
+	trace.SizeBytes = file.SizeBytes
+	trace.Status = "uploaded"
+	trace.StorageTier = model.StorageTierStandard
+	trace.ArchivedAt = nil
+	trace.PublishStatus = &publishStatus
+	trace.DateUpdated = ts
+	trace.content = nil
+	trace.thumbnail = nil
+
+	copied := trace.Trace
+	m.appendEvents(tenantID, model.TraceFileReplacedEvent(courseID, &copied, &previous, userID))
+	m.outbox = append(m.outbox, &model.OutboxEvent{
+		ID:          uuid.New(),
+		Topic:       topic,
+		AggregateID: traceID,
+		Payload:     append([]byte(nil), payload...),
+		Status:      model.OutboxStatusPending,
+		DateCreated: ts,
+	})
+	return &copied, nil
+}
+
+func (m *Memory) ListTraceRevisions(ctx context.Context, courseID, traceID uuid.UUID) ([]model.TraceRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) {
+		return nil, model.ErrNotFound
+	}
+	revisions := []model.TraceRevision{{
+		Revision:     len(trace.revisions) + 1,
+		Current:      true,
+		FileName:     trace.FileName,
+		BucketURL:    trace.BucketURL,
+		ContentType:  trace.ContentType,
+		SizeBytes:    trace.SizeBytes,
+		DateUploaded: trace.uploadedAt(),
+	}}
+	for i := len(trace.revisions) - 1; i >= 0; i-- {
+		revisions = append(revisions, trace.revisions[i])
+	}
+	return revisions, nil
+}
+
+func (m *Memory) UpdateTraceVisibility(ctx context.Context, courseID, traceID uuid.UUID, visibility string, userID uuid.UUID) (*model.Trace, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenantID, traceID) {
+		return nil, model.ErrNotFound
+	}
+	previous := trace.Visibility
+	trace.Visibility = visibility
+	trace.DateUpdated = now()
+	copied := trace.Trace
+	if previous != visibility {
+		m.appendEvents(tenantID, model.TraceVisibilityChangedEvent(courseID, &copied, previous, userID))
+	}
+	return &copied, nil
+}
+
+func (m *Memory) CreateTraceShare(ctx context.Context, courseID, traceID uuid.UUID, tokenHash string, userID uuid.UUID, expiresAt time.Time) (*model.TraceShare, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) {
+		return nil, model.ErrNotFound
+	}
+	share := memoryShare{
+		TraceShare: model.TraceShare{
+			ID:          uuid.New(),
+			TraceID:     traceID,
+			CreatedBy:   userID,
+			ExpiresAt:   expiresAt,
+			DateCreated: now(),
+		},
+		tokenHash: tokenHash,
+	}
+	trace.shares = append(trace.shares, share)
+	return share.view(), nil
+}
+
+func (m *Memory) ListTraceShares(ctx context.Context, courseID, traceID uuid.UUID) ([]model.TraceShare, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) {
+		return nil, model.ErrNotFound
+	}
+	shares := []model.TraceShare{}
+	for i := len(trace.shares) - 1; i >= 0; i-- {
+		shares = append(shares, *trace.shares[i].view())
+	}
+	return shares, nil
+}
+
+func (m *Memory) RevokeTraceShare(ctx context.Context, courseID, traceID, shareID uuid.UUID) (*model.TraceShare, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) {
+		return nil, model.ErrNotFound
+	}
+	for i := range trace.shares {
+		share := &trace.shares[i]
+		if share.ID != shareID {
+			continue
+		}
+		if share.RevokedAt == nil {
+			ts := now()
+			share.RevokedAt = &ts
+		}
+		return share.view(), nil
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *Memory) GetSharedTrace(ctx context.Context, token string) (*model.Trace, uuid.UUID, error) {
+	tokenHash := model.HashAPIKey(token)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, trace := range m.traces {
+		for _, share := range trace.shares {
+			if share.tokenHash == tokenHash && share.view().Active {
+				copied := trace.Trace
+				return &copied, share.ID, nil
+			}
+		}
+	}
+	return nil, uuid.Nil, model.ErrNotFound
+}
+
+func (m *Memory) RecordTraceAccess(ctx context.Context, access model.TraceAccess) (*model.TraceAccess, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[access.TraceID]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	access.ID = uuid.New()
+	access.DateCreated = now()
+	trace.access = append(trace.access, access)
+	return &access, nil
+}
+
+func (m *Memory) ListTraceAccess(ctx context.Context, courseID, traceID uuid.UUID, opts model.ListOptions) (*model.Page[model.TraceAccess], error) {
+	m.mu.RLock()
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) {
+		m.mu.RUnlock()
+		return nil, model.ErrNotFound
+	}
+	entries := slices.Clone(trace.access)
+	m.mu.RUnlock()
+	return model.PaginateTraceAccess(entries, opts)
+}
+
+// view is the share link as the API shows it
+func (s *memoryShare) view() *model.TraceShare {
+	view := s.TraceShare
+	view.Active = view.RevokedAt == nil && time.Now().Before(view.ExpiresAt)
+	return &view
+}
+
+// uploadedAt is when the trace's current file was uploaded: when the last
+// one was replaced, or else when the trace was
+func (t *memoryTrace) uploadedAt() time.Time {
+	if n := len(t.revisions); n > 0 {
+		return *t.revisions[n-1].DateReplaced
+	}
+	return t.DateCreated
+}
+
+func (m *Memory) UpdateTraceStatus(ctx context.Context, courseID, traceID uuid.UUID, req model.UpdateTraceStatusRequest) (*model.Trace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) {
+		return nil, model.ErrNotFound
+	}
+	previous := trace.Status
+	trace.Status = req.Status
+	if req.VectorID != nil {
+		vectorID := *req.VectorID
+		trace.VectorID = &vectorID
+	}
+	trace.DateUpdated = now()
+	if previous != trace.Status {
+		m.appendEvents(m.owner[traceID], model.TraceStatusChangedEvent(courseID, &trace.Trace, previous))
+	}
+	if previous != "processed" && trace.Status == "processed" {
+		m.notify([]uuid.UUID{trace.UserID}, model.TraceProcessedNotification(m.courses[courseID], &trace.Trace))
+	}
+	copied := trace.Trace
+	return &copied, nil
+}
+
+func (m *Memory) UpdateTraceEmbedding(ctx context.Context, courseID, traceID uuid.UUID, req model.UpdateTraceRequest) (*model.Trace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) {
+		return nil, model.ErrNotFound
+	}
+	if req.VectorID != nil {
+		vectorID := *req.VectorID
+		trace.VectorID = &vectorID
+	}
+	if req.Embedding != nil {
+		trace.embedding = slices.Clone(req.Embedding)
+	}
+	if req.Excerpt != nil {
+		trace.excerpt = *req.Excerpt
+	}
+	trace.DateUpdated = now()
+	copied := trace.Trace
+	return &copied, nil
+}
+
+func (m *Memory) SimilarTraces(ctx context.Context, courseID uuid.UUID, embedding []float32, limit int) ([]model.SimilarTrace, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.courses[courseID]; !ok || !m.owns(tenantID, courseID) {
+		return nil, model.ErrNotFound
+	}
+	traces := []model.SimilarTrace{}
+	for _, t := range m.traces {
+		if t.courseID == courseID && m.owns(tenantID, t.ID) && len(t.embedding) == len(embedding) {
+			traces = append(traces, model.SimilarTrace{Trace: t.Trace, Distance: cosineDistance(t.embedding, embedding)})
+		}
+	}
+	slices.SortFunc(traces, func(a, b model.SimilarTrace) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	return traces[:min(limit, len(traces))], nil
+}
+
+func (m *Memory) SearchTraces(ctx context.Context, embedding []float32, filters model.SearchFilters, limit int) ([]model.SearchResult, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := []model.SearchResult{}
+	for _, t := range m.traces {
+		course, ok := m.courses[t.courseID]
+		if !ok || !m.owns(tenantID, t.ID) || t.Status != "processed" || len(t.embedding) != len(embedding) || !filters.Matches(course) {
+			continue
+		}
+		results = append(results, model.SearchResult{
+			Course: model.SearchCourse{
+				ID:           course.ID,
+				Name:         course.Name,
+				SubjectCode:  course.SubjectCode,
+				CourseID:     course.CourseID,
+				SemesterTerm: course.SemesterTerm,
+				SemesterYear: course.SemesterYear,
+				InstructorID: course.InstructorID,
+			},
+			Trace:    model.SearchTrace{ID: t.ID, FileName: t.FileName, VectorID: t.VectorID},
+			Distance: cosineDistance(t.embedding, embedding),
+			Excerpt:  t.excerpt,
+		})
+	}
+	slices.SortFunc(results, func(a, b model.SearchResult) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	return results[:min(limit, len(results))], nil
+}
+
+func (m *Memory) GetTracesWithoutContent(ctx context.Context, limit int) ([]model.Trace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var traces []model.Trace
+	for _, t := range m.traces {
+		if t.content == nil && t.Status != "failed" && t.BucketURL != "" {
+			traces = append(traces, t.Trace)
+		}
+	}
+	slices.SortFunc(traces, func(a, b model.Trace) int {
+		return a.DateCreated.Compare(b.DateCreated)
+	})
+	return traces[:min(limit, len(traces))], nil
+}
+
+func (m *Memory) SaveTraceContent(ctx context.Context, traceID uuid.UUID, content model.TraceContent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok {
+		return model.ErrNotFound
+	}
+	trace.content = &content
+	return nil
+}
+
+func (m *Memory) GetTracesWithoutThumbnail(ctx context.Context, limit int) ([]model.Trace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var traces []model.Trace
+	for _, t := range m.traces {
+		if t.thumbnail == nil && t.Status != "failed" && t.BucketURL != "" {
+			traces = append(traces, t.Trace)
+		}
+	}
+	slices.SortFunc(traces, func(a, b model.Trace) int {
+		return a.DateCreated.Compare(b.DateCreated)
+	})
+	return traces[:min(limit, len(traces))], nil
+}
+
+func (m *Memory) SaveTraceThumbnail(ctx context.Context, traceID uuid.UUID, thumbnail model.TraceThumbnail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok {
+		return model.ErrNotFound
+	}
+	thumbnail.GeneratedAt = now()
+	trace.thumbnail = &thumbnail
+	return nil
+}
+
+func (m *Memory) GetTraceThumbnail(ctx context.Context, courseID, traceID uuid.UUID) (*model.TraceThumbnail, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) || trace.thumbnail == nil {
+		return nil, model.ErrNotFound
+	}
+	copied := *trace.thumbnail
+	return &copied, nil
+}
+
+func (m *Memory) GetTraceContent(ctx context.Context, courseID, traceID uuid.UUID) (*model.TraceContent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) || trace.content == nil {
+		return nil, model.ErrNotFound
+	}
+	copied := *trace.content
+	if copied.Summary != nil {
+		summary := *copied.Summary
+		copied.Summary = &summary
+	}
+	return &copied, nil
+}
+
+func (m *Memory) SaveTraceSummary(ctx context.Context, traceID uuid.UUID, summary, summaryModel string) (*model.TraceSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || !m.owns(tenant.ID(ctx), traceID) || trace.content == nil {
+		return nil, model.ErrNotFound
+	}
+	saved := model.TraceSummary{TraceID: traceID, Summary: summary, Model: summaryModel, SummarizedAt: now()}
+	trace.content.Summary = &saved
+	copied := saved
+	return &copied, nil
+}
+
+// SearchTraceContent matches traces containing every word of query, ignoring
+// case, and ranks them by how often the words occur. It is a rough stand-in
+// for Postgres full-text search: there is no stemming, and phrases and
+// -exclusions are treated as plain words.
+func (m *Memory) SearchTraceContent(ctx context.Context, query string, courseID *uuid.UUID, limit int) ([]model.KeywordResult, error) {
+	tenantID := tenant.ID(ctx)
+	var words []string
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if word = strings.Trim(word, `"-`); word != "" {
+			words = append(words, word)
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := []model.KeywordResult{}
+	for _, t := range m.traces {
+		course, ok := m.courses[t.courseID]
+		if !ok || t.content == nil || !m.owns(tenantID, t.ID) || (courseID != nil && t.courseID != *courseID) {
+			continue
+		}
+		text := strings.ToLower(t.content.Text)
+		hits := 0
+		for _, word := range words {
+			n := strings.Count(text, word)
+			if n == 0 {
+				hits = 0
+				break
+			}
+			hits += n
+		}
+		if hits == 0 {
+			continue
+		}
+		results = append(results, model.KeywordResult{
+			Course: model.SearchCourse{
+				ID:           course.ID,
+				Name:         course.Name,
+				SubjectCode:  course.SubjectCode,
+				CourseID:     course.CourseID,
+				SemesterTerm: course.SemesterTerm,
+				SemesterYear: course.SemesterYear,
+				InstructorID: course.InstructorID,
+			},
+			Trace:   model.SearchTrace{ID: t.ID, FileName: t.FileName, VectorID: t.VectorID},
+			Rank:    float64(hits) / float64(len(strings.Fields(text))),
+			Snippet: highlight(model.Snippet(t.content.Text, query, 200), words),
+		})
+	}
+	slices.SortFunc(results, func(a, b model.KeywordResult) int {
+		return cmp.Compare(b.Rank, a.Rank)
+	})
+	return results[:min(limit, len(results))], nil
+}
+
+// highlight wraps each word of text containing one of words in **, as
+// Postgres' ts_headline is asked to
+func highlight(text string, words []string) string {
+	fields := strings.Fields(text)
+	for i, field := range fields {
+		lower := strings.ToLower(field)
+		for _, word := range words {
+			if strings.Contains(lower, word) {
+				fields[i] = "**" + field + "**"
+				break
+			}
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// cosineDistance matches pgvector's <=> operator
+func cosineDistance(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	return 1 - dot/math.Sqrt(normA*normB)
+}
+
+func (m *Memory) ArchiveOldCourses(ctx context.Context, beforeSemester int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	unarchived := make(map[uuid.UUID]bool)
+	for _, entry := range m.audit {
+		if entry.Action == "course.unarchived" {
+			unarchived[entry.EntityID] = true
+		}
+	}
+	archived := 0
+	ts := now()
+	for _, c := range m.courses {
+		if c.ArchivedAt == nil && !unarchived[c.ID] && model.SemesterIndex(c.SemesterYear, c.SemesterTerm) < beforeSemester {
+			c.ArchivedAt = &ts
+			c.DateUpdated = ts
+			m.appendEvents(m.owner[c.ID], model.CourseEvent(model.EventCourseArchived, c.ID, nil, map[string]any{"archived_at": c.ArchivedAt}))
+			archived++
+		}
+	}
+	return archived, nil
+}
+
+// CreateTracePartitions has nothing to do: the memory store isn't partitioned
+func (m *Memory) CreateTracePartitions(ctx context.Context, monthsAhead int) (int, error) {
+	return 0, nil
+}
+
+func (m *Memory) GetArchivableTraces(ctx context.Context, beforeSemester int, limit int) ([]model.Trace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var traces []model.Trace
+	for _, t := range m.traces {
+		if t.StorageTier != model.StorageTierStandard || t.Status == "failed" || t.BucketURL == "" {
+			continue
+		}
+		course := m.courses[t.courseID]
+		if course == nil || model.SemesterIndex(course.SemesterYear, course.SemesterTerm) >= beforeSemester {
+			continue
+		}
+		traces = append(traces, t.Trace)
+	}
+	sortByDateCreated(traces)
+	if len(traces) > limit {
+		traces = traces[:limit]
+	}
+	return traces, nil
+}
+
+func (m *Memory) UpdateTraceStorage(ctx context.Context, traceID uuid.UUID, storageTier, bucketURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok {
+		return model.ErrNotFound
+	}
+	ts := now()
+	trace.StorageTier = storageTier
+	trace.BucketURL = bucketURL
+	trace.ArchivedAt = nil
+	if storageTier != model.StorageTierStandard {
+		trace.ArchivedAt = &ts
+	}
+	trace.DateUpdated = ts
+	return nil
+}
+
+func (m *Memory) ListStoredTraces(ctx context.Context) ([]model.Trace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var traces []model.Trace
+	for _, t := range m.traces {
+		if t.Status != "failed" {
+			traces = append(traces, t.Trace)
+		}
+	}
+	sortByDateCreated(traces)
+	return traces, nil
+}
+
+func (m *Memory) MarkTraceFailed(ctx context.Context, traceID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok {
+		return model.ErrNotFound
+	}
+	trace.Status = "failed"
+	trace.DateUpdated = now()
+	return nil
+}
+
+func sortByDateCreated(traces []model.Trace) {
+	slices.SortFunc(traces, func(a, b model.Trace) int { return a.DateCreated.Compare(b.DateCreated) })
+}
+
+// Personal data
+
+// Trace comments
+
+func (m *Memory) CreateTraceComment(ctx context.Context, courseID, traceID, userID uuid.UUID, req model.CreateTraceCommentRequest) (*model.TraceComment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) {
+		return nil, model.ErrNotFound
+	}
+	comment := model.TraceComment{ID: uuid.New(), TraceID: traceID, UserID: userID, Text: req.Text, DateCreated: now()}
+	if req.Page != nil {
+		page := *req.Page
+		comment.Page = &page
+	}
+	trace.comments = append(trace.comments, comment)
+	return &comment, nil
+}
+
+func (m *Memory) ListTraceComments(ctx context.Context, courseID, traceID uuid.UUID, opts model.ListOptions) (*model.Page[model.TraceComment], error) {
+	m.mu.RLock()
+	trace, ok := m.traces[traceID]
+	if !ok || trace.courseID != courseID || !m.owns(tenant.ID(ctx), traceID) {
+		m.mu.RUnlock()
+		return nil, model.ErrNotFound
+	}
+	comments := slices.Clone(trace.comments)
+	m.mu.RUnlock()
+	return model.PaginateTraceComments(comments, opts)
+}
+
+func (m *Memory) GetTraceComment(ctx context.Context, traceID, commentID uuid.UUID) (*model.TraceComment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || !m.owns(tenant.ID(ctx), traceID) {
+		return nil, model.ErrNotFound
+	}
+	for _, c := range trace.comments {
+		if c.ID == commentID {
+			return &c, nil
+		}
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *Memory) DeleteTraceComment(ctx context.Context, traceID, commentID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trace, ok := m.traces[traceID]
+	if !ok || !m.owns(tenant.ID(ctx), traceID) {
+		return model.ErrNotFound
+	}
+	before := len(trace.comments)
+	trace.comments = slices.DeleteFunc(trace.comments, func(c model.TraceComment) bool { return c.ID == commentID })
+	if len(trace.comments) == before {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// Favorites
+
+func (m *Memory) AddFavorite(ctx context.Context, userID, courseID uuid.UUID) (*model.Course, bool, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	course, ok := m.courses[courseID]
+	if !ok || !m.owns(tenantID, courseID) {
+		return nil, false, model.ErrNotFound
+	}
+	saved := m.favorites[userID]
+	if saved == nil {
+		saved = map[uuid.UUID]time.Time{}
+		m.favorites[userID] = saved
+	}
+	_, exists := saved[courseID]
+	if !exists {
+		saved[courseID] = now()
+	}
+	c := *course
+	return &c, !exists, nil
+}
+
+func (m *Memory) RemoveFavorite(ctx context.Context, userID, courseID uuid.UUID) error {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.favorites[userID][courseID]; !ok || !m.owns(tenantID, courseID) {
+		return model.ErrNotFound
+	}
+	delete(m.favorites[userID], courseID)
+	return nil
+}
+
+func (m *Memory) ListFavorites(ctx context.Context, userID uuid.UUID, opts model.ListOptions) (*model.Page[model.Course], error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var courses []model.Course
+	for courseID := range m.favorites[userID] {
+		if course, ok := m.courses[courseID]; ok && m.owns(tenantID, courseID) {
+			courses = append(courses, *course)
+		}
+	}
+	return model.PaginateCourses(courses, opts)
+}
+
+func (m *Memory) GetUserData(ctx context.Context, userID uuid.UUID) (*model.UserData, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[userID]
+	if !ok || !m.owns(tenantID, userID) {
+		return nil, model.ErrNotFound
+	}
+	data := &model.UserData{User: publicUser(user), Courses: []model.Course{}, Traces: []model.UserTrace{}, Comments: []model.TraceComment{}, Favorites: []uuid.UUID{}}
+	for _, c := range m.courses {
+		if c.UserID == userID && m.owns(tenantID, c.ID) {
+			data.Courses = append(data.Courses, *c)
+		}
+	}
+	for _, t := range m.traces {
+		if !m.owns(tenantID, t.ID) {
+			continue
+		}
+		if t.UserID == userID {
+			data.Traces = append(data.Traces, model.UserTrace{Trace: t.Trace, CourseID: t.courseID, Revisions: slices.Clone(t.revisions)})
+		}
+		for _, c := range t.comments {
+			if c.UserID == userID {
+				data.Comments = append(data.Comments, c)
+			}
+		}
+	}
+	slices.SortFunc(data.Courses, func(a, b model.Course) int { return a.DateCreated.Compare(b.DateCreated) })
+	slices.SortFunc(data.Traces, func(a, b model.UserTrace) int { return a.DateCreated.Compare(b.DateCreated) })
+	slices.SortFunc(data.Comments, func(a, b model.TraceComment) int { return a.DateCreated.Compare(b.DateCreated) })
+	saved := m.favorites[userID]
+	for courseID := range saved {
+		data.Favorites = append(data.Favorites, courseID)
+	}
+	slices.SortFunc(data.Favorites, func(a, b uuid.UUID) int { return saved[a].Compare(saved[b]) })
+	if avatar, ok := m.avatars[userID]; ok {
+		data.Avatar = &avatar
+	}
+	return data, nil
+}
+
+func (m *Memory) EraseUser(ctx context.Context, userID uuid.UUID) error {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok || !m.owns(tenantID, userID) {
+		return model.ErrNotFound
+	}
+	user.FirstName = model.ErasedFirstName
+	user.LastName = model.ErasedLastName
+	user.Username = model.ErasedUsername(userID)
+	user.Email = model.ErasedEmail(userID)
+	user.Password = model.ErasedPassword
+	user.AccountUpdated = now()
+	for key, id := range m.identities {
+		if id == userID {
+			delete(m.identities, key)
+		}
+	}
+	delete(m.mfa, userID)
+	delete(m.prefs, userID)
+	delete(m.favorites, userID)
+	delete(m.avatars, userID)
+	m.notifications = slices.DeleteFunc(m.notifications, func(n *memoryNotification) bool { return n.userID == userID })
+	m.deleteSessions(userID)
+
+	var freed int64
+	for id, t := range m.traces {
+		if !m.owns(tenantID, id) {
+			continue
+		}
+		t.comments = slices.DeleteFunc(t.comments, func(c model.TraceComment) bool { return c.UserID == userID })
+		t.shares = slices.DeleteFunc(t.shares, func(s memoryShare) bool { return s.CreatedBy == userID })
+		for i, a := range t.access {
+			if a.UserID != nil && *a.UserID == userID {
+				t.access[i].IPAddress, t.access[i].UserAgent = nil, nil
+			}
+		}
+		if t.UserID != userID {
+			continue
+		}
+		if course, ok := m.courses[t.courseID]; ok {
+			course.StorageBytes = max(course.StorageBytes-t.storedBytes(), 0)
+		}
+		freed += t.storedBytes()
+		delete(m.traces, id)
+		delete(m.owner, id)
+	}
+	for _, job := range m.dataJobs {
+		if job.UserID == userID && job.TenantID == tenantID {
+			job.ResultObject = nil
+		}
+	}
+	return m.charge(tenantID, model.UsageDelta{StorageBytes: -freed})
+}
+
+func (m *Memory) TraceFileInUse(ctx context.Context, userID uuid.UUID, objectName string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, t := range m.traces {
+		if t.UserID == userID {
+			continue
+		}
+		if t.FileName == objectName {
+			return true, nil
+		}
+		for _, r := range t.revisions {
+			if r.FileName == objectName {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Canvas sync
+
+func (m *Memory) GetCanvasConnection(ctx context.Context) (*model.CanvasConnection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	conn, ok := m.canvasConnections[tenant.ID(ctx)]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	copied := *conn
+	return &copied, nil
+}
+
+func (m *Memory) SaveCanvasConnection(ctx context.Context, req model.SaveCanvasConnectionRequest) (*model.CanvasConnection, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ts := now()
+	conn, ok := m.canvasConnections[tenantID]
+	if !ok {
+		conn = &model.CanvasConnection{DateCreated: ts}
+		m.canvasConnections[tenantID] = conn
+	}
+	conn.BaseURL = req.BaseURL
+	conn.APIToken = req.APIToken
+	conn.LinkBaseURL = req.LinkBaseURL
+	conn.Enabled = req.Enabled == nil || *req.Enabled
+	conn.DateUpdated = ts
+	copied := *conn
+	return &copied, nil
+}
+
+func (m *Memory) DeleteCanvasConnection(ctx context.Context) error {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.canvasConnections[tenantID]; !ok {
+		return model.ErrNotFound
+	}
+	delete(m.canvasConnections, tenantID)
+	return nil
+}
+
+func (m *Memory) LinkCanvasCourse(ctx context.Context, courseID uuid.UUID, canvasCourseID int64) (*model.CanvasCourseLink, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.courses[courseID]; !ok || !m.owns(tenantID, courseID) {
+		return nil, model.ErrNotFound
+	}
+	for _, other := range m.canvasLinks {
+		if other.CourseID != courseID && other.tenantID == tenantID && other.CanvasCourseID == canvasCourseID {
+			return nil, uniqueViolation("canvas_course_links_canvas_course_key")
+		}
+	}
+	ts := now()
+	link, ok := m.canvasLinks[courseID]
+	if !ok {
+		link = &memoryCanvasLink{CanvasCourseLink: model.CanvasCourseLink{CourseID: courseID, DateCreated: ts}, tenantID: tenantID}
+		m.canvasLinks[courseID] = link
+	}
+	if link.CanvasCourseID != canvasCourseID {
+		link.roster = nil
+	}
+	link.CanvasCourseID = canvasCourseID
+	link.DateUpdated = ts
+	copied := link.CanvasCourseLink
+	return &copied, nil
+}
+
+func (m *Memory) UnlinkCanvasCourse(ctx context.Context, courseID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if link, ok := m.canvasLinks[courseID]; !ok || link.tenantID != tenant.ID(ctx) {
+		return model.ErrNotFound
+	}
+	delete(m.canvasLinks, courseID)
+	return nil
+}
+
+func (m *Memory) ListCanvasCourseLinks(ctx context.Context) ([]model.CanvasCourseLink, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := []model.CanvasCourseLink{}
+	for _, link := range m.canvasLinks {
+		if link.tenantID == tenantID {
+			links = append(links, link.CanvasCourseLink)
+		}
+	}
+	slices.SortFunc(links, func(a, b model.CanvasCourseLink) int {
+		return cmp.Or(a.DateCreated.Compare(b.DateCreated), strings.Compare(a.CourseID.String(), b.CourseID.String()))
+	})
+	return links, nil
+}
+
+func (m *Memory) ReplaceCanvasRoster(ctx context.Context, courseID uuid.UUID, entries []model.CanvasRosterEntry) ([]model.CanvasRosterEntry, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.canvasLinks[courseID]
+	if !ok || link.tenantID != tenantID {
+		return nil, model.ErrNotFound
+	}
+	type key struct {
+		userID int64
+		role   string
+	}
+	seen := map[key]bool{}
+	roster := []model.CanvasRosterEntry{}
+	for _, e := range entries {
+		if seen[key{e.CanvasUserID, e.Role}] {
+			continue
+		}
+		seen[key{e.CanvasUserID, e.Role}] = true
+		e.InstructorID = nil
+		if e.Email != nil {
+			for _, i := range m.instructors {
+				if m.owns(tenantID, i.ID) && strings.EqualFold(i.Email, *e.Email) {
+					id := i.ID
+					e.InstructorID = &id
+					break
+				}
+			}
+		}
+		roster = append(roster, e)
+	}
+	slices.SortFunc(roster, compareRosterEntries)
+	link.roster = roster
+	return slices.Clone(roster), nil
+}
+
+func (m *Memory) GetCanvasRoster(ctx context.Context, courseID uuid.UUID) ([]model.CanvasRosterEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	link, ok := m.canvasLinks[courseID]
+	if !ok || link.tenantID != tenant.ID(ctx) {
+		return nil, model.ErrNotFound
+	}
+	roster := slices.Clone(link.roster)
+	if roster == nil {
+		roster = []model.CanvasRosterEntry{}
+	}
+	return roster, nil
+}
+
+// compareRosterEntries orders a roster by role, name and Canvas user ID, as
+// model.GetCanvasRoster does
+func compareRosterEntries(a, b model.CanvasRosterEntry) int {
+	return cmp.Or(strings.Compare(a.Role, b.Role), strings.Compare(a.Name, b.Name), cmp.Compare(a.CanvasUserID, b.CanvasUserID))
+}
+
+func (m *Memory) CreateCanvasSync(ctx context.Context, trigger string, requestedBy *uuid.UUID) (*model.CanvasSync, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sync, err := m.createCanvasSync(tenant.ID(ctx), trigger, requestedBy)
+	if err != nil {
+		return nil, err
+	}
+	return copyCanvasSync(sync), nil
+}
+
+func (m *Memory) createCanvasSync(tenantID uuid.UUID, trigger string, requestedBy *uuid.UUID) (*memoryCanvasSync, error) {
+	for _, s := range m.canvasSyncs {
+		if s.TenantID == tenantID && !s.Done() {
+			return nil, uniqueViolation("canvas_syncs_active_idx")
+		}
+	}
+	ts := now()
+	sync := &memoryCanvasSync{CanvasSync: model.CanvasSync{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		Trigger:     trigger,
+		RequestedBy: requestedBy,
+		Status:      model.DataJobPending,
+		Conflicts:   []model.CanvasConflict{},
+		DateCreated: ts,
+		DateUpdated: ts,
+	}}
+	m.canvasSyncs = append(m.canvasSyncs, sync)
+	return sync, nil
+}
+
+// ListCanvasSyncs returns the tenant's syncs newest first; syncs are
+// appended in creation order
+func (m *Memory) ListCanvasSyncs(ctx context.Context, limit int) ([]model.CanvasSync, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	syncs := []model.CanvasSync{}
+	for i := len(m.canvasSyncs) - 1; i >= 0 && len(syncs) < limit; i-- {
+		if s := m.canvasSyncs[i]; s.TenantID == tenantID {
+			syncs = append(syncs, *copyCanvasSync(s))
+		}
+	}
+	return syncs, nil
+}
+
+func (m *Memory) GetCanvasSync(ctx context.Context, syncID uuid.UUID) (*model.CanvasSync, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, s := range m.canvasSyncs {
+		if s.ID == syncID && s.TenantID == tenantID {
+			return copyCanvasSync(s), nil
+		}
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *Memory) ScheduleCanvasSyncs(ctx context.Context, interval time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since := now().Add(-interval)
+	queued := 0
+	for tenantID, conn := range m.canvasConnections {
+		if !conn.Enabled {
+			continue
+		}
+		due := true
+		for _, s := range m.canvasSyncs {
+			if s.TenantID == tenantID && (!s.Done() || s.DateCreated.After(since)) {
+				due = false
+				break
+			}
+		}
+		if !due {
+			continue
+		}
+		if _, err := m.createCanvasSync(tenantID, model.CanvasSyncSchedule, nil); err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+func (m *Memory) ClaimCanvasSync(ctx context.Context, lease time.Duration) (*model.CanvasSync, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ts := now()
+	for _, s := range m.canvasSyncs {
+		if s.Status == model.DataJobPending || (s.Status == model.DataJobRunning && s.leaseExpires.Before(ts)) {
+			s.Status = model.DataJobRunning
+			s.leaseExpires = ts.Add(lease)
+			s.DateUpdated = ts
+			return copyCanvasSync(s), nil
+		}
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *Memory) FinishCanvasSync(ctx context.Context, sync model.CanvasSync) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.canvasSyncs {
+		if stored.ID != sync.ID {
+			continue
+		}
+		ts := now()
+		stored.Status = sync.Status
+		stored.Error = sync.Error
+		stored.CoursesSynced = sync.CoursesSynced
+		stored.RosterEntries = sync.RosterEntries
+		stored.Conflicts = slices.Clone(sync.Conflicts)
+		if stored.Conflicts == nil {
+			stored.Conflicts = []model.CanvasConflict{}
+		}
+		stored.leaseExpires = time.Time{}
+		stored.DateUpdated = ts
+		stored.DateCompleted = nil
+		if sync.Done() {
+			stored.DateCompleted = &ts
+		}
+		return nil
+	}
+	return model.ErrNotFound
+}
+
+func copyCanvasSync(s *memoryCanvasSync) *model.CanvasSync {
+	copied := s.CanvasSync
+	copied.Conflicts = slices.Clone(s.Conflicts)
+	return &copied
+}
+
+// Data jobs
+
+func (m *Memory) CreateDataJob(ctx context.Context, kind string, userID, requestedBy uuid.UUID) (*model.DataJob, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok || !m.owns(tenantID, userID) {
+		return nil, model.ErrNotFound
+	}
+	ts := now()
+	job := &memoryDataJob{DataJob: model.DataJob{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		Kind:        kind,
+		UserID:      userID,
+		RequestedBy: requestedBy,
+		Status:      model.DataJobPending,
+		DateCreated: ts,
+		DateUpdated: ts,
+	}}
+	m.dataJobs = append(m.dataJobs, job)
+	copied := job.DataJob
+	return &copied, nil
+}
+
+// ListDataJobs returns the user's jobs newest first; jobs are appended in
+// creation order
+func (m *Memory) ListDataJobs(ctx context.Context, userID uuid.UUID) ([]model.DataJob, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jobs := []model.DataJob{}
+	for i := len(m.dataJobs) - 1; i >= 0; i-- {
+		if job := m.dataJobs[i]; job.UserID == userID && job.TenantID == tenantID {
+			jobs = append(jobs, job.DataJob)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *Memory) GetDataJob(ctx context.Context, userID, jobID uuid.UUID) (*model.DataJob, error) {
+	tenantID := tenant.ID(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, job := range m.dataJobs {
+		if job.ID == jobID && job.UserID == userID && job.TenantID == tenantID {
+			copied := job.DataJob
+			return &copied, nil
+		}
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *Memory) ClaimDataJob(ctx context.Context, lease time.Duration) (*model.DataJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ts := now()
+	for _, job := range m.dataJobs {
+		if job.Status == model.DataJobPending || (job.Status == model.DataJobRunning && job.leaseExpires.Before(ts)) {
+			job.Status = model.DataJobRunning
+			job.Attempts++
+			job.leaseExpires = ts.Add(lease)
+			job.DateUpdated = ts
+			copied := job.DataJob
+			return &copied, nil
+		}
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *Memory) FinishDataJob(ctx context.Context, job model.DataJob, topic string, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.dataJobs {
+		if stored.ID != job.ID {
+			continue
+		}
+		ts := now()
+		stored.Status = job.Status
+		stored.Error = job.Error
+		stored.ResultObject = job.ResultObject
+		stored.leaseExpires = time.Time{}
+		stored.DateUpdated = ts
+		stored.DateCompleted = nil
+		if job.Done() {
+			stored.DateCompleted = &ts
+		}
+		if topic != "" {
+			m.outbox = append(m.outbox, &model.OutboxEvent{
+				ID:          uuid.New(),
+				Topic:       topic,
+				AggregateID: job.ID,
+				Payload:     append([]byte(nil), payload...),
+				Status:      model.OutboxStatusPending,
+				DateCreated: ts,
+			})
+		}
+		return nil
+	}
+	return model.ErrNotFound
+}
+
+// Outbox
+
+// DispatchOutbox publishes without holding the lock, so a slow publisher
+// does not stall requests. Only one relay runs per process, which stands in
+// for the row locks the Postgres implementation takes.
+func (m *Memory) DispatchOutbox(ctx context.Context, limit, maxAttempts int, publish func(model.OutboxEvent) error) (int, error) {
+	m.mu.RLock()
+	var pending []*model.OutboxEvent
+	for _, event := range m.outbox {
+		if event.Status == model.OutboxStatusPending && len(pending) < limit {
+			pending = append(pending, event)
+		}
+	}
+	m.mu.RUnlock()
+
+	handled := 0
+	for _, event := range pending {
+		m.mu.RLock()
+		snapshot := *event
+		m.mu.RUnlock()
+
+		err := publish(snapshot)
+
+		m.mu.Lock()
+		event.Attempts++
+		if err != nil {
+			message := err.Error()
+			event.LastError = &message
+			if event.Attempts >= maxAttempts {
+				event.Status = model.OutboxStatusFailed
+			}
+		} else {
+			published := now()
+			event.Status = model.OutboxStatusPublished
+			event.LastError = nil
+			event.DatePublished = &published
+			handled++
+		}
+		if trace, ok := m.traces[event.AggregateID]; ok && event.Status != model.OutboxStatusPending {
+			publishStatus := model.PublishStatusOf(event.Status)
+			trace.PublishStatus = &publishStatus
+		}
+		m.mu.Unlock()
+	}
+	return handled, nil
+}
+
+func (m *Memory) GetOutboxBacklog(ctx context.Context) (*model.OutboxBacklog, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	backlog := &model.OutboxBacklog{}
+	for _, event := range m.outbox {
+		switch event.Status {
+		case model.OutboxStatusPending:
+			backlog.Pending++
+			if backlog.OldestPending == nil || event.DateCreated.Before(*backlog.OldestPending) {
+				created := event.DateCreated
+				backlog.OldestPending = &created
+			}
+		case model.OutboxStatusFailed:
+			backlog.Failed++
+		}
+	}
+	for _, t := range m.traces {
+		if t.PublishStatus == nil {
+			continue
+		}
+		switch *t.PublishStatus {
+		case model.PublishStatusPending:
+			backlog.TracesPending++
+		case model.PublishStatusFailed:
+			backlog.TracesFailed++
+		}
+	}
+	return backlog, nil
+}
+
+// Feature flags
+
+func (m *Memory) ListFeatureFlagOverrides(ctx context.Context) ([]model.FeatureFlagOverride, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	overrides := make([]model.FeatureFlagOverride, 0, len(m.flags))
+	for _, o := range m.flags {
+		overrides = append(overrides, o)
+	}
+	slices.SortFunc(overrides, func(a, b model.FeatureFlagOverride) int { return strings.Compare(a.Name, b.Name) })
+	return overrides, nil
+}
+
+func (m *Memory) SetFeatureFlagOverride(ctx context.Context, name string, enabled bool, userID uuid.UUID) (*model.FeatureFlagOverride, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; !ok {
+		return nil, foreignKeyViolation("feature_flags_updated_by_fkey")
+	}
+	o := model.FeatureFlagOverride{Name: name, Enabled: enabled, UpdatedBy: &userID, DateUpdated: now()}
+	m.flags[name] = o
+	return &o, nil
+}
+
+func (m *Memory) DeleteFeatureFlagOverride(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.flags[name]; !ok {
+		return model.ErrNotFound
+	}
+	delete(m.flags, name)
+	return nil
+}
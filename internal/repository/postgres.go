@@ -0,0 +1,1095 @@
+// internal/repository/postgres.go
+package repository
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/tenant"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DB is the connection pool behind Postgres, either a *pgxpool.Pool or a
+// *database.RotatingPool
+type DB interface {
+	model.DBTX
+	Ping(ctx context.Context) error
+}
+
+// Postgres implements Repository with the model package's queries
+type Postgres struct {
+	db DB
+}
+
+func NewPostgres(db DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.db.Ping(ctx)
+}
+
+func (p *Postgres) InsertHealthCheck(ctx context.Context) error {
+	return model.InsertHealthCheck(ctx, p.db)
+}
+
+func (p *Postgres) CreateTenant(ctx context.Context, req model.CreateTenantRequest, apiKeyHash *string) (*model.Tenant, error) {
+	return model.CreateTenant(ctx, p.db, req, apiKeyHash)
+}
+
+func (p *Postgres) GetTenantByID(ctx context.Context, tenantID uuid.UUID) (*model.Tenant, error) {
+	return model.GetTenantByID(ctx, p.db, tenantID)
+}
+
+func (p *Postgres) GetTenantBySlug(ctx context.Context, slug string) (*model.Tenant, error) {
+	return model.GetTenantBySlug(ctx, p.db, slug)
+}
+
+func (p *Postgres) GetTenantByAPIKey(ctx context.Context, key string) (*model.Tenant, error) {
+	return model.GetTenantByAPIKey(ctx, p.db, key)
+}
+
+func (p *Postgres) ListTenants(ctx context.Context) ([]model.Tenant, error) {
+	return model.ListTenants(ctx, p.db)
+}
+
+func (p *Postgres) UpdateTenant(ctx context.Context, slug string, req model.UpdateTenantRequest) (*model.Tenant, error) {
+	return model.UpdateTenant(ctx, p.db, slug, req)
+}
+
+func (p *Postgres) DeleteTenant(ctx context.Context, slug string) error {
+	return model.DeleteTenant(ctx, p.db, slug)
+}
+
+func (p *Postgres) GetTenantUsage(ctx context.Context, tenantID uuid.UUID) (*model.TenantUsage, error) {
+	return model.GetTenantUsage(ctx, p.db, tenantID)
+}
+
+// ChargeUpload charges the course and the tenant in one transaction, so a
+// quota failure of either leaves both unchanged
+func (p *Postgres) GetUserUploadUsage(ctx context.Context, userID uuid.UUID) (*model.UserUploadUsage, error) {
+	return model.GetUserUploadUsage(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) ChargeUpload(ctx context.Context, courseID, userID uuid.UUID, sizeBytes int64, limits model.UploadLimits) error {
+	tenantID := tenant.ID(ctx)
+	return model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		if err := model.ChargeCourseStorage(ctx, tx, tenantID, courseID, sizeBytes, limits.CourseBytes); err != nil {
+			return err
+		}
+		if err := model.ChargeUserUploads(ctx, tx, tenantID, userID, 1, sizeBytes, limits); err != nil {
+			return err
+		}
+		return model.ChargeTenantUsage(ctx, tx, tenantID, model.UsageDelta{StorageBytes: sizeBytes, Uploads: 1})
+	})
+}
+
+func (p *Postgres) RefundUpload(ctx context.Context, courseID, userID uuid.UUID, sizeBytes int64) error {
+	tenantID := tenant.ID(ctx)
+	return model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		if err := model.ChargeCourseStorage(ctx, tx, tenantID, courseID, -sizeBytes, 0); err != nil {
+			return err
+		}
+		if err := model.ChargeUserUploads(ctx, tx, tenantID, userID, -1, -sizeBytes, model.UploadLimits{}); err != nil {
+			return err
+		}
+		return model.ChargeTenantUsage(ctx, tx, tenantID, model.UsageDelta{StorageBytes: -sizeBytes, Uploads: -1})
+	})
+}
+
+func (p *Postgres) CreateUser(ctx context.Context, req model.CreateUserRequest) (*model.User, error) {
+	return model.CreateUser(ctx, p.db, tenant.ID(ctx), req)
+}
+
+func (p *Postgres) AuthenticateUser(ctx context.Context, username, password string) (*model.User, error) {
+	return model.AuthenticateUser(ctx, p.db, tenant.ID(ctx), username, password)
+}
+
+func (p *Postgres) GetUser(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	return model.GetUserByID(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error) {
+	return model.GetNotificationPreferences(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) UpdateNotificationPreferences(ctx context.Context, userID uuid.UUID, req model.UpdateNotificationPreferencesRequest) (*model.NotificationPreferences, error) {
+	var prefs *model.NotificationPreferences
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		var err error
+		prefs, err = model.UpdateNotificationPreferences(ctx, tx, tenant.ID(ctx), userID, req)
+		return err
+	})
+	return prefs, err
+}
+
+func (p *Postgres) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, opts model.ListOptions) (*model.Page[model.Notification], error) {
+	return model.ListNotifications(ctx, p.db, tenant.ID(ctx), userID, unreadOnly, opts)
+}
+
+func (p *Postgres) SetNotificationRead(ctx context.Context, userID, notificationID uuid.UUID, read bool) (*model.Notification, error) {
+	return model.SetNotificationRead(ctx, p.db, tenant.ID(ctx), userID, notificationID, read)
+}
+
+func (p *Postgres) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int, error) {
+	return model.MarkAllNotificationsRead(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) CreateSession(ctx context.Context, session model.Session) (*model.Session, error) {
+	return model.CreateSession(ctx, p.db, tenant.ID(ctx), session)
+}
+
+func (p *Postgres) StartImpersonation(ctx context.Context, session model.Session) (*model.Session, error) {
+	tenantID := tenant.ID(ctx)
+	var created *model.Session
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		var err error
+		if created, err = model.CreateSession(ctx, tx, tenantID, session); err != nil {
+			return err
+		}
+		return model.InsertAuditEntry(ctx, tx, model.UserImpersonatedAuditEntry(created))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (p *Postgres) TouchSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	return model.TouchSession(ctx, p.db, tenant.ID(ctx), userID, sessionID)
+}
+
+func (p *Postgres) ListSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	return model.ListSessions(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) DeleteSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	return model.DeleteSession(ctx, p.db, tenant.ID(ctx), userID, sessionID)
+}
+
+func (p *Postgres) DeleteSessions(ctx context.Context, userID uuid.UUID) error {
+	return model.DeleteSessions(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) GetMFA(ctx context.Context, userID uuid.UUID) (*model.MFA, error) {
+	return model.GetMFA(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) SaveMFAEnrollment(ctx context.Context, userID uuid.UUID, secret string, recoveryCodes []string) (*model.MFA, error) {
+	return model.SaveMFAEnrollment(ctx, p.db, tenant.ID(ctx), userID, secret, recoveryCodes)
+}
+
+func (p *Postgres) UseMFAStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	return model.UseMFAStep(ctx, p.db, tenant.ID(ctx), userID, step)
+}
+
+func (p *Postgres) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) error {
+	return model.UseRecoveryCode(ctx, p.db, tenant.ID(ctx), userID, hash)
+}
+
+func (p *Postgres) CountMFAAttempt(ctx context.Context, userID, challengeID uuid.UUID, challengeExpires time.Time, limits model.MFALimits) error {
+	return model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		return model.CountMFAAttempt(ctx, tx, tenant.ID(ctx), userID, challengeID, challengeExpires, limits)
+	})
+}
+
+func (p *Postgres) ClearMFAAttempts(ctx context.Context, userID, challengeID uuid.UUID) error {
+	return model.ClearMFAAttempts(ctx, p.db, tenant.ID(ctx), userID, challengeID)
+}
+
+func (p *Postgres) DeleteMFA(ctx context.Context, userID uuid.UUID) error {
+	return model.DeleteMFA(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) CreateServiceAccount(ctx context.Context, req model.CreateServiceAccountRequest, keyHash string, createdBy uuid.UUID) (*model.ServiceAccount, error) {
+	return model.CreateServiceAccount(ctx, p.db, tenant.ID(ctx), req, keyHash, createdBy)
+}
+
+func (p *Postgres) ListServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error) {
+	return model.ListServiceAccounts(ctx, p.db, tenant.ID(ctx))
+}
+
+func (p *Postgres) GetServiceAccount(ctx context.Context, accountID uuid.UUID) (*model.ServiceAccount, error) {
+	return model.GetServiceAccount(ctx, p.db, tenant.ID(ctx), accountID)
+}
+
+func (p *Postgres) UpdateServiceAccount(ctx context.Context, accountID uuid.UUID, req model.UpdateServiceAccountRequest) (*model.ServiceAccount, error) {
+	return model.UpdateServiceAccount(ctx, p.db, tenant.ID(ctx), accountID, req)
+}
+
+func (p *Postgres) RotateServiceAccountKey(ctx context.Context, accountID uuid.UUID, keyHash string) (*model.ServiceAccount, error) {
+	return model.RotateServiceAccountKey(ctx, p.db, tenant.ID(ctx), accountID, keyHash)
+}
+
+func (p *Postgres) DeleteServiceAccount(ctx context.Context, accountID uuid.UUID) error {
+	return model.DeleteServiceAccount(ctx, p.db, tenant.ID(ctx), accountID)
+}
+
+func (p *Postgres) AuthenticateServiceAccount(ctx context.Context, key string) (*model.ServiceAccount, error) {
+	return model.AuthenticateServiceAccount(ctx, p.db, tenant.ID(ctx), key)
+}
+
+func (p *Postgres) GetUserAvatar(ctx context.Context, userID uuid.UUID) (*model.UserAvatar, error) {
+	return model.GetUserAvatar(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) SaveUserAvatar(ctx context.Context, userID uuid.UUID, avatar model.UserAvatar) (*model.UserAvatar, error) {
+	return model.SaveUserAvatar(ctx, p.db, tenant.ID(ctx), userID, avatar)
+}
+
+func (p *Postgres) DeleteUserAvatar(ctx context.Context, userID uuid.UUID) (*model.UserAvatar, error) {
+	return model.DeleteUserAvatar(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) UpdateUser(ctx context.Context, userID uuid.UUID, req model.UpdateUserRequest) (*model.User, error) {
+	return model.UpdateUser(ctx, p.db, tenant.ID(ctx), userID, req)
+}
+
+func (p *Postgres) ListUsers(ctx context.Context, opts model.ListOptions) (*model.Page[model.User], error) {
+	return model.ListUsers(ctx, p.db, tenant.ID(ctx), opts)
+}
+
+func (p *Postgres) AdminUpdateUser(ctx context.Context, userID uuid.UUID, req model.AdminUpdateUserRequest, adminID uuid.UUID) (*model.User, error) {
+	tenantID := tenant.ID(ctx)
+	var user *model.User
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		previous, err := model.GetUserByID(ctx, tx, tenantID, userID)
+		if err != nil {
+			return err
+		}
+		if user, err = model.AdminUpdateUser(ctx, tx, tenantID, userID, req); err != nil {
+			return err
+		}
+		if req.RevokesSessions() {
+			if err := model.DeleteSessions(ctx, tx, tenantID, userID); err != nil {
+				return err
+			}
+		}
+		entry := model.UserUpdatedAuditEntry(previous, user, req.RevokesSessions(), adminID)
+		if len(entry.Changes) == 0 {
+			return nil
+		}
+		return model.InsertAuditEntry(ctx, tx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// LoginExternal runs in one transaction, so two first logins of the same
+// identity cannot both provision a user
+func (p *Postgres) LoginExternal(ctx context.Context, identity model.ExternalIdentity, role string) (*model.User, bool, error) {
+	tenantID := tenant.ID(ctx)
+	var user *model.User
+	created := false
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		var err error
+		user, err = model.GetUserByIdentity(ctx, tx, tenantID, identity.Provider, identity.Subject)
+		if !errors.Is(err, model.ErrNotFound) {
+			return err
+		}
+
+		if identity.EmailVerified {
+			user, err = model.GetUserByEmail(ctx, tx, tenantID, identity.Email)
+			if err == nil {
+				return model.LinkIdentity(ctx, tx, tenantID, user.ID, identity)
+			}
+			if !errors.Is(err, model.ErrNotFound) {
+				return err
+			}
+		}
+
+		username, err := model.FreeUsername(ctx, tx, tenantID, identity.UsernameBase())
+		if err != nil {
+			return err
+		}
+		req, err := identity.ProvisionRequest(username, role)
+		if err != nil {
+			return err
+		}
+		if user, err = model.CreateUser(ctx, tx, tenantID, req); err != nil {
+			return err
+		}
+		created = true
+		return model.LinkIdentity(ctx, tx, tenantID, user.ID, identity)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return user, created, nil
+}
+
+func (p *Postgres) CreateInstructor(ctx context.Context, req model.CreateInstructorRequest, userID uuid.UUID) (*model.Instructor, error) {
+	return model.CreateInstructor(ctx, p.db, tenant.ID(ctx), req, userID)
+}
+
+func (p *Postgres) CreateInstructors(ctx context.Context, reqs []model.CreateInstructorRequest, userID uuid.UUID) ([]model.Instructor, error) {
+	return model.CreateInstructors(ctx, p.db, tenant.ID(ctx), reqs, userID)
+}
+
+func (p *Postgres) GetInstructorByID(ctx context.Context, instructorID uuid.UUID) (*model.Instructor, error) {
+	return model.GetInstructorByID(ctx, p.db, tenant.ID(ctx), instructorID)
+}
+
+func (p *Postgres) GetInstructorByEmail(ctx context.Context, email string) (*model.Instructor, error) {
+	return model.GetInstructorByEmail(ctx, p.db, tenant.ID(ctx), email)
+}
+
+func (p *Postgres) ListInstructors(ctx context.Context, opts model.ListOptions) (*model.Page[model.Instructor], error) {
+	return model.ListInstructors(ctx, p.db, tenant.ID(ctx), opts)
+}
+
+func (p *Postgres) UpdateInstructor(ctx context.Context, instructorID uuid.UUID, req model.UpdateInstructorRequest) (*model.Instructor, error) {
+	return model.UpdateInstructor(ctx, p.db, tenant.ID(ctx), instructorID, req)
+}
+
+func (p *Postgres) DeleteInstructorByID(ctx context.Context, instructorID uuid.UUID) error {
+	return model.DeleteInstructorByID(ctx, p.db, tenant.ID(ctx), instructorID)
+}
+
+// CreateCourse counts the course against the tenant's quota in the same
+// transaction, so concurrent creates cannot overshoot it, and records its
+// first version and created event
+func (p *Postgres) CreateCourse(ctx context.Context, req model.CreateCourseRequest, userID uuid.UUID) (*model.Course, error) {
+	tenantID := tenant.ID(ctx)
+	var course *model.Course
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		if err := model.ChargeTenantUsage(ctx, tx, tenantID, model.UsageDelta{Courses: 1}); err != nil {
+			return err
+		}
+		var err error
+		if course, err = model.CreateCourse(ctx, tx, tenantID, req, userID); err != nil {
+			return err
+		}
+		if err := model.InsertCourseVersion(ctx, tx, tenantID, course.ID, model.NewCourseVersion(course, nil)); err != nil {
+			return err
+		}
+		return model.AppendEvents(ctx, tx, tenantID, []model.Event{model.CourseCreatedEvent(course, userID)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return course, nil
+}
+
+// CreateCourses charges the tenant's quota for all the courses at once and
+// inserts them, their first versions and their created events, with one
+// statement each
+func (p *Postgres) CreateCourses(ctx context.Context, reqs []model.CreateCourseRequest, userID uuid.UUID) ([]model.Course, error) {
+	if len(reqs) == 0 {
+		return []model.Course{}, nil
+	}
+	tenantID := tenant.ID(ctx)
+	var courses []model.Course
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		if err := model.ChargeTenantUsage(ctx, tx, tenantID, model.UsageDelta{Courses: int64(len(reqs))}); err != nil {
+			return err
+		}
+		var err error
+		if courses, err = model.CreateCourses(ctx, tx, tenantID, reqs, userID); err != nil {
+			return err
+		}
+		ids := make([]uuid.UUID, len(courses))
+		events := make([]model.Event, len(courses))
+		for i, c := range courses {
+			ids[i] = c.ID
+			events[i] = model.CourseCreatedEvent(&c, userID)
+		}
+		if err := model.InsertFirstCourseVersions(ctx, tx, tenantID, ids); err != nil {
+			return err
+		}
+		return model.AppendEvents(ctx, tx, tenantID, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return courses, nil
+}
+
+func (p *Postgres) GetCourseByID(ctx context.Context, courseID uuid.UUID) (*model.Course, error) {
+	return model.GetCourseByID(ctx, p.db, tenant.ID(ctx), courseID)
+}
+
+func (p *Postgres) GetCourseExpanded(ctx context.Context, courseID uuid.UUID, expand model.CourseExpand) (*model.Course, error) {
+	return model.GetCourseExpanded(ctx, p.db, tenant.ID(ctx), courseID, expand)
+}
+
+func (p *Postgres) GetCourseBySection(ctx context.Context, subjectCode string, courseID int, semesterTerm string, semesterYear int) (*model.Course, error) {
+	return model.GetCourseBySection(ctx, p.db, tenant.ID(ctx), subjectCode, courseID, semesterTerm, semesterYear)
+}
+
+func (p *Postgres) ListCourses(ctx context.Context, archived bool, opts model.ListOptions) (*model.Page[model.Course], error) {
+	return model.ListCourses(ctx, p.db, tenant.ID(ctx), archived, opts)
+}
+
+func (p *Postgres) StreamCourses(ctx context.Context, archived bool, opts model.ListOptions, fn func(model.Course) error) error {
+	return model.EachCourse(ctx, p.db, tenant.ID(ctx), archived, opts, fn)
+}
+
+// UpdateCourse locks the course, applies the update and records the change
+// in the audit log, course history and event ledger within one transaction
+func (p *Postgres) UpdateCourse(ctx context.Context, courseID uuid.UUID, req model.UpdateCourseRequest, userID uuid.UUID) (*model.Course, error) {
+	tenantID := tenant.ID(ctx)
+	var updated *model.Course
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		previous, err := model.LockCourseByID(ctx, tx, tenantID, courseID)
+		if err != nil {
+			return err
+		}
+		updated, err = model.UpdateCourse(ctx, tx, tenantID, courseID, req, userID)
+		if err != nil {
+			return err
+		}
+		return p.recordCourseUpdate(ctx, tx, previous, updated, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (p *Postgres) BulkUpdateCourses(ctx context.Context, filter model.CourseFilter, req model.UpdateCourseRequest, userID uuid.UUID, dryRun bool) ([]model.CourseChange, error) {
+	tenantID := tenant.ID(ctx)
+	var results []model.CourseChange
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		courses, err := model.LockCourses(ctx, tx, tenantID, filter)
+		if err != nil {
+			return err
+		}
+		results = make([]model.CourseChange, 0, len(courses))
+		for _, previous := range courses {
+			updated := req.Apply(previous)
+			changes := model.DiffCourses(&previous, &updated)
+			if dryRun || len(changes) == 0 {
+				results = append(results, model.CourseChange{Course: updated, Changes: changes})
+				continue
+			}
+
+			course, err := model.UpdateCourse(ctx, tx, tenantID, previous.ID, req, userID)
+			if err != nil {
+				return err
+			}
+			if err := p.recordCourseUpdate(ctx, tx, &previous, course, userID); err != nil {
+				return err
+			}
+			results = append(results, model.CourseChange{Course: *course, Changes: changes})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// recordCourseUpdate writes the audit entry, next version and updated event
+// for an update of a course, and notifies its uploaders
+func (p *Postgres) recordCourseUpdate(ctx context.Context, tx model.DBTX, previous, updated *model.Course, userID uuid.UUID) error {
+	changes := model.DiffCourses(previous, updated)
+	err := model.InsertAuditEntry(ctx, tx, model.AuditEntry{
+		UserID:     userID,
+		Action:     "course.updated",
+		EntityType: "course",
+		EntityID:   updated.ID,
+		Changes:    changes,
+	})
+	if err != nil {
+		return err
+	}
+	version := model.NewCourseVersion(updated, changes)
+	if len(version.Changes) > 0 {
+		if err := model.InsertCourseVersion(ctx, tx, tenant.ID(ctx), updated.ID, version); err != nil {
+			return err
+		}
+		if err := model.AppendEvents(ctx, tx, tenant.ID(ctx), []model.Event{model.CourseUpdatedEvent(updated, version)}); err != nil {
+			return err
+		}
+	}
+	return p.notifyCourseUpdated(ctx, tx, updated, changes, userID)
+}
+
+// notifyCourseUpdated tells the course's uploaders, other than the user
+// who made the change, which fields changed. Who is recorded as the
+// course's user doesn't concern them.
+func (p *Postgres) notifyCourseUpdated(ctx context.Context, tx model.DBTX, course *model.Course, changes map[string]any, userID uuid.UUID) error {
+	delete(changes, "user_id")
+	if len(changes) == 0 {
+		return nil
+	}
+	uploaders, err := model.CourseUploaders(ctx, tx, tenant.ID(ctx), course.ID)
+	if err != nil {
+		return err
+	}
+	uploaders = slices.DeleteFunc(uploaders, func(id uuid.UUID) bool { return id == userID })
+	return model.InsertNotifications(ctx, tx, tenant.ID(ctx), uploaders, model.CourseUpdatedNotification(course, changes))
+}
+
+// DeleteCourseByID records the course's deleted event, which outlives it
+func (p *Postgres) DeleteCourseByID(ctx context.Context, courseID uuid.UUID) error {
+	tenantID := tenant.ID(ctx)
+	return model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		if err := model.DeleteCourseByID(ctx, tx, tenantID, courseID); err != nil {
+			return err
+		}
+		if err := model.ChargeTenantUsage(ctx, tx, tenantID, model.UsageDelta{Courses: -1}); err != nil {
+			return err
+		}
+		deleted := model.CourseEvent(model.EventCourseDeleted, courseID, nil, nil)
+		return model.AppendEvents(ctx, tx, tenantID, []model.Event{deleted})
+	})
+}
+
+// SetCourseArchived locks the course so the audit log and event ledger only
+// record a change of state
+func (p *Postgres) SetCourseArchived(ctx context.Context, courseID uuid.UUID, archived bool, userID uuid.UUID) (*model.Course, error) {
+	tenantID := tenant.ID(ctx)
+	var course *model.Course
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		previous, err := model.LockCourseByID(ctx, tx, tenantID, courseID)
+		if err != nil {
+			return err
+		}
+		if course, err = model.SetCourseArchived(ctx, tx, tenantID, courseID, archived); err != nil {
+			return err
+		}
+		if (previous.ArchivedAt != nil) == archived {
+			return nil
+		}
+		if err := model.InsertAuditEntry(ctx, tx, model.CourseArchivedAuditEntry(previous, course, userID)); err != nil {
+			return err
+		}
+		return model.AppendEvents(ctx, tx, tenantID, []model.Event{model.CourseArchivedEvent(course, userID)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return course, nil
+}
+
+func (p *Postgres) ArchiveOldCourses(ctx context.Context, beforeSemester int) (int, error) {
+	return model.ArchiveCoursesBefore(ctx, p.db, beforeSemester)
+}
+
+func (p *Postgres) CreateTracePartitions(ctx context.Context, monthsAhead int) (int, error) {
+	return model.CreateTracePartitions(ctx, p.db, monthsAhead)
+}
+
+func (p *Postgres) ListCourseEvents(ctx context.Context, courseID uuid.UUID, opts model.ListOptions) (*model.Page[model.Event], error) {
+	return model.ListCourseEvents(ctx, p.db, tenant.ID(ctx), courseID, opts)
+}
+
+func (p *Postgres) ListCourseVersions(ctx context.Context, courseID uuid.UUID, opts model.ListOptions) (*model.Page[model.CourseVersion], error) {
+	return model.ListCourseVersions(ctx, p.db, tenant.ID(ctx), courseID, opts)
+}
+
+func (p *Postgres) GetCourseVersion(ctx context.Context, courseID uuid.UUID, version int) (*model.CourseVersion, error) {
+	return model.GetCourseVersion(ctx, p.db, tenant.ID(ctx), courseID, version)
+}
+
+// InsertTrace records the trace's uploaded event in the same transaction
+func (p *Postgres) InsertTrace(ctx context.Context, t NewTrace) (*model.Trace, error) {
+	tenantID := tenant.ID(ctx)
+	var trace *model.Trace
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		var err error
+		trace, err = model.InsertTrace(ctx, tx, tenantID, t.traceID(), t.UserID, t.InstructorID, t.Status, t.CourseID, t.VectorID, t.FileName, t.BucketURL, t.ContentType, t.SizeBytes, t.visibility(), t.LinkURL, nil)
+		if err != nil {
+			return err
+		}
+		return model.AppendEvents(ctx, tx, tenantID, []model.Event{model.TraceUploadedEvent(t.CourseID, trace)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+func (p *Postgres) InsertTraceWithEvent(ctx context.Context, t NewTrace, topic string, payload []byte) (*model.Trace, error) {
+	tenantID := tenant.ID(ctx)
+	var trace *model.Trace
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		var err error
+		publishStatus := model.PublishStatusPending
+		trace, err = model.InsertTrace(ctx, tx, tenantID, t.traceID(), t.UserID, t.InstructorID, t.Status, t.CourseID, t.VectorID, t.FileName, t.BucketURL, t.ContentType, t.SizeBytes, t.visibility(), t.LinkURL, &publishStatus)
+		if err != nil {
+			return err
+		}
+		if err := model.AppendEvents(ctx, tx, tenantID, []model.Event{model.TraceUploadedEvent(t.CourseID, trace)}); err != nil {
+			return err
+		}
+		_, err = model.InsertOutboxEvent(ctx, tx, topic, trace.ID, payload)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+func (p *Postgres) GetTracesByCourseID(ctx context.Context, courseID uuid.UUID, visible []string, opts model.ListOptions) (*model.Page[model.Trace], error) {
+	return model.GetTracesByCourseID(ctx, p.db, tenant.ID(ctx), courseID, visible, opts)
+}
+
+func (p *Postgres) StreamTracesByCourseID(ctx context.Context, courseID uuid.UUID, visible []string, opts model.ListOptions, fn func(model.Trace) error) error {
+	return model.EachTraceByCourseID(ctx, p.db, tenant.ID(ctx), courseID, visible, opts, fn)
+}
+
+func (p *Postgres) GetTraceByID(ctx context.Context, courseID, traceID uuid.UUID) (*model.Trace, error) {
+	return model.GetTraceByID(ctx, p.db, tenant.ID(ctx), courseID, traceID)
+}
+
+func (p *Postgres) ExpandTraces(ctx context.Context, traces []model.Trace, expand model.TraceExpand) error {
+	return model.ExpandTraces(ctx, p.db, tenant.ID(ctx), traces, expand)
+}
+
+func (p *Postgres) DeleteTraceByID(ctx context.Context, courseID, traceID uuid.UUID) error {
+	tenantID := tenant.ID(ctx)
+	return model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		sizeBytes, err := model.DeleteTraceByID(ctx, tx, tenantID, courseID, traceID)
+		if err != nil {
+			return err
+		}
+		if err := model.ChargeCourseStorage(ctx, tx, tenantID, courseID, -sizeBytes, 0); err != nil {
+			return err
+		}
+		if err := model.ChargeTenantUsage(ctx, tx, tenantID, model.UsageDelta{StorageBytes: -sizeBytes}); err != nil {
+			return err
+		}
+		deleted := model.TraceEvent(model.EventTraceDeleted, courseID, traceID, nil, nil)
+		return model.AppendEvents(ctx, tx, tenantID, []model.Event{deleted})
+	})
+}
+
+// ReplaceTraceFile locks the trace so concurrent replacements number their
+// revisions in turn
+func (p *Postgres) ReplaceTraceFile(ctx context.Context, courseID, traceID uuid.UUID, file model.TraceFile, userID uuid.UUID, topic string, payload []byte) (*model.Trace, error) {
+	tenantID := tenant.ID(ctx)
+	var trace *model.Trace
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		if _, err := model.LockTraceStatus(ctx, tx, tenantID, courseID, traceID); err != nil {
+			return err
+		}
+		previous, err := model.RecordTraceRevision(ctx, tx, tenantID, courseID, traceID)
+		if err != nil {
+			return err
+		}
+		trace, err = model.ReplaceTraceFile(ctx, tx, tenantID, courseID, traceID, file)
+		if err != nil {
+			return err
+		}
+		replaced := model.TraceFileReplacedEvent(courseID, trace, previous, userID)
+		if err := model.AppendEvents(ctx, tx, tenantID, []model.Event{replaced}); err != nil {
+			return err
+		}
+		_, err = model.InsertOutboxEvent(ctx, tx, topic, trace.ID, payload)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+func (p *Postgres) ListTraceRevisions(ctx context.Context, courseID, traceID uuid.UUID) ([]model.TraceRevision, error) {
+	return model.ListTraceRevisions(ctx, p.db, tenant.ID(ctx), courseID, traceID)
+}
+
+// UpdateTraceVisibility records a change of visibility in the event ledger
+// in the same transaction
+func (p *Postgres) UpdateTraceVisibility(ctx context.Context, courseID, traceID uuid.UUID, visibility string, userID uuid.UUID) (*model.Trace, error) {
+	tenantID := tenant.ID(ctx)
+	var trace *model.Trace
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		if _, err := model.LockTraceStatus(ctx, tx, tenantID, courseID, traceID); err != nil {
+			return err
+		}
+		previous, err := model.GetTraceByID(ctx, tx, tenantID, courseID, traceID)
+		if err != nil {
+			return err
+		}
+		trace, err = model.UpdateTraceVisibility(ctx, tx, tenantID, courseID, traceID, visibility)
+		if err != nil {
+			return err
+		}
+		if previous.Visibility == trace.Visibility {
+			return nil
+		}
+		changed := model.TraceVisibilityChangedEvent(courseID, trace, previous.Visibility, userID)
+		return model.AppendEvents(ctx, tx, tenantID, []model.Event{changed})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+func (p *Postgres) CreateTraceShare(ctx context.Context, courseID, traceID uuid.UUID, tokenHash string, userID uuid.UUID, expiresAt time.Time) (*model.TraceShare, error) {
+	return model.CreateTraceShare(ctx, p.db, tenant.ID(ctx), courseID, traceID, tokenHash, userID, expiresAt)
+}
+
+// ListTraceShares returns ErrNotFound for an unknown trace, rather than no links
+func (p *Postgres) ListTraceShares(ctx context.Context, courseID, traceID uuid.UUID) ([]model.TraceShare, error) {
+	tenantID := tenant.ID(ctx)
+	if _, err := model.GetTraceByID(ctx, p.db, tenantID, courseID, traceID); err != nil {
+		return nil, err
+	}
+	return model.ListTraceShares(ctx, p.db, tenantID, courseID, traceID)
+}
+
+func (p *Postgres) RevokeTraceShare(ctx context.Context, courseID, traceID, shareID uuid.UUID) (*model.TraceShare, error) {
+	tenantID := tenant.ID(ctx)
+	if _, err := model.GetTraceByID(ctx, p.db, tenantID, courseID, traceID); err != nil {
+		return nil, err
+	}
+	return model.RevokeTraceShare(ctx, p.db, tenantID, traceID, shareID)
+}
+
+func (p *Postgres) GetSharedTrace(ctx context.Context, token string) (*model.Trace, uuid.UUID, error) {
+	return model.GetSharedTrace(ctx, p.db, token)
+}
+
+func (p *Postgres) RecordTraceAccess(ctx context.Context, access model.TraceAccess) (*model.TraceAccess, error) {
+	return model.RecordTraceAccess(ctx, p.db, access)
+}
+
+func (p *Postgres) ListTraceAccess(ctx context.Context, courseID, traceID uuid.UUID, opts model.ListOptions) (*model.Page[model.TraceAccess], error) {
+	if _, err := model.GetTraceByID(ctx, p.db, tenant.ID(ctx), courseID, traceID); err != nil {
+		return nil, err
+	}
+	return model.ListTraceAccess(ctx, p.db, tenant.ID(ctx), traceID, opts)
+}
+
+func (p *Postgres) GetArchivableTraces(ctx context.Context, beforeSemester int, limit int) ([]model.Trace, error) {
+	return model.GetArchivableTraces(ctx, p.db, beforeSemester, limit)
+}
+
+func (p *Postgres) UpdateTraceStorage(ctx context.Context, traceID uuid.UUID, storageTier, bucketURL string) error {
+	return model.UpdateTraceStorage(ctx, p.db, traceID, storageTier, bucketURL)
+}
+
+func (p *Postgres) ListStoredTraces(ctx context.Context) ([]model.Trace, error) {
+	return model.ListStoredTraces(ctx, p.db)
+}
+
+// UpdateTraceStatus records a change of status in the event ledger, and
+// notifies the uploader when the trace becomes processed, in the same
+// transaction
+func (p *Postgres) UpdateTraceStatus(ctx context.Context, courseID, traceID uuid.UUID, req model.UpdateTraceStatusRequest) (*model.Trace, error) {
+	tenantID := tenant.ID(ctx)
+	var trace *model.Trace
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		previous, err := model.LockTraceStatus(ctx, tx, tenantID, courseID, traceID)
+		if err != nil {
+			return err
+		}
+		trace, err = model.UpdateTraceStatus(ctx, tx, tenantID, courseID, traceID, req)
+		if err != nil {
+			return err
+		}
+		if previous != trace.Status {
+			changed := model.TraceStatusChangedEvent(courseID, trace, previous)
+			if err := model.AppendEvents(ctx, tx, tenantID, []model.Event{changed}); err != nil {
+				return err
+			}
+		}
+		if previous == "processed" || trace.Status != "processed" {
+			return nil
+		}
+		course, err := model.GetCourseByID(ctx, tx, tenantID, courseID)
+		if err != nil {
+			return err
+		}
+		return model.InsertNotifications(ctx, tx, tenantID, []uuid.UUID{trace.UserID}, model.TraceProcessedNotification(course, trace))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+func (p *Postgres) UpdateTraceEmbedding(ctx context.Context, courseID, traceID uuid.UUID, req model.UpdateTraceRequest) (*model.Trace, error) {
+	return model.UpdateTraceEmbedding(ctx, p.db, tenant.ID(ctx), courseID, traceID, req)
+}
+
+func (p *Postgres) SimilarTraces(ctx context.Context, courseID uuid.UUID, embedding []float32, limit int) ([]model.SimilarTrace, error) {
+	tenantID := tenant.ID(ctx)
+	if _, err := model.GetCourseByID(ctx, p.db, tenantID, courseID); err != nil {
+		return nil, err
+	}
+	return model.SimilarTraces(ctx, p.db, tenantID, courseID, embedding, limit)
+}
+
+func (p *Postgres) SearchTraces(ctx context.Context, embedding []float32, filters model.SearchFilters, limit int) ([]model.SearchResult, error) {
+	return model.SearchTraces(ctx, p.db, tenant.ID(ctx), embedding, filters, limit)
+}
+
+func (p *Postgres) GetTracesWithoutContent(ctx context.Context, limit int) ([]model.Trace, error) {
+	return model.GetTracesWithoutContent(ctx, p.db, limit)
+}
+
+func (p *Postgres) SaveTraceContent(ctx context.Context, traceID uuid.UUID, content model.TraceContent) error {
+	return model.SaveTraceContent(ctx, p.db, traceID, content)
+}
+
+func (p *Postgres) GetTraceContent(ctx context.Context, courseID, traceID uuid.UUID) (*model.TraceContent, error) {
+	return model.GetTraceContent(ctx, p.db, tenant.ID(ctx), courseID, traceID)
+}
+
+func (p *Postgres) SaveTraceSummary(ctx context.Context, traceID uuid.UUID, summary, summaryModel string) (*model.TraceSummary, error) {
+	return model.SaveTraceSummary(ctx, p.db, tenant.ID(ctx), traceID, summary, summaryModel)
+}
+
+func (p *Postgres) SearchTraceContent(ctx context.Context, query string, courseID *uuid.UUID, limit int) ([]model.KeywordResult, error) {
+	return model.SearchTraceContent(ctx, p.db, tenant.ID(ctx), query, courseID, limit)
+}
+
+func (p *Postgres) GetTracesWithoutThumbnail(ctx context.Context, limit int) ([]model.Trace, error) {
+	return model.GetTracesWithoutThumbnail(ctx, p.db, limit)
+}
+
+func (p *Postgres) SaveTraceThumbnail(ctx context.Context, traceID uuid.UUID, thumbnail model.TraceThumbnail) error {
+	return model.SaveTraceThumbnail(ctx, p.db, traceID, thumbnail)
+}
+
+func (p *Postgres) GetTraceThumbnail(ctx context.Context, courseID, traceID uuid.UUID) (*model.TraceThumbnail, error) {
+	return model.GetTraceThumbnail(ctx, p.db, tenant.ID(ctx), courseID, traceID)
+}
+
+func (p *Postgres) MarkTraceFailed(ctx context.Context, traceID uuid.UUID) error {
+	return model.MarkTraceFailed(ctx, p.db, traceID)
+}
+
+func (p *Postgres) CreateTraceComment(ctx context.Context, courseID, traceID, userID uuid.UUID, req model.CreateTraceCommentRequest) (*model.TraceComment, error) {
+	return model.CreateTraceComment(ctx, p.db, tenant.ID(ctx), courseID, traceID, userID, req)
+}
+
+func (p *Postgres) ListTraceComments(ctx context.Context, courseID, traceID uuid.UUID, opts model.ListOptions) (*model.Page[model.TraceComment], error) {
+	if _, err := model.GetTraceByID(ctx, p.db, tenant.ID(ctx), courseID, traceID); err != nil {
+		return nil, err
+	}
+	return model.ListTraceComments(ctx, p.db, tenant.ID(ctx), traceID, opts)
+}
+
+func (p *Postgres) GetTraceComment(ctx context.Context, traceID, commentID uuid.UUID) (*model.TraceComment, error) {
+	return model.GetTraceComment(ctx, p.db, tenant.ID(ctx), traceID, commentID)
+}
+
+func (p *Postgres) DeleteTraceComment(ctx context.Context, traceID, commentID uuid.UUID) error {
+	return model.DeleteTraceComment(ctx, p.db, tenant.ID(ctx), traceID, commentID)
+}
+
+func (p *Postgres) AddFavorite(ctx context.Context, userID, courseID uuid.UUID) (*model.Course, bool, error) {
+	return model.AddFavorite(ctx, p.db, tenant.ID(ctx), userID, courseID)
+}
+
+func (p *Postgres) RemoveFavorite(ctx context.Context, userID, courseID uuid.UUID) error {
+	return model.RemoveFavorite(ctx, p.db, tenant.ID(ctx), userID, courseID)
+}
+
+func (p *Postgres) ListFavorites(ctx context.Context, userID uuid.UUID, opts model.ListOptions) (*model.Page[model.Course], error) {
+	return model.ListFavorites(ctx, p.db, tenant.ID(ctx), userID, opts)
+}
+
+func (p *Postgres) GetUserData(ctx context.Context, userID uuid.UUID) (*model.UserData, error) {
+	return model.GetUserData(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+// EraseUser anonymizes the user, unlinks their provider accounts, MFA and
+// sessions, forgets their notifications, preferences, favorites, profile
+// picture and the addresses they accessed traces from, deletes
+// their traces and gives the freed storage back to the courses and the tenant in
+// one transaction
+func (p *Postgres) EraseUser(ctx context.Context, userID uuid.UUID) error {
+	tenantID := tenant.ID(ctx)
+	return model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		if err := model.AnonymizeUser(ctx, tx, tenantID, userID); err != nil {
+			return err
+		}
+		if err := model.DeleteUserIdentities(ctx, tx, tenantID, userID); err != nil {
+			return err
+		}
+		if err := model.DeleteMFA(ctx, tx, tenantID, userID); err != nil && !errors.Is(err, model.ErrNotFound) {
+			return err
+		}
+		if err := model.DeleteSessions(ctx, tx, tenantID, userID); err != nil {
+			return err
+		}
+		if err := model.DeleteNotificationPreferences(ctx, tx, tenantID, userID); err != nil {
+			return err
+		}
+		if err := model.DeleteNotifications(ctx, tx, tenantID, userID); err != nil {
+			return err
+		}
+		if err := model.DeleteUserTraceComments(ctx, tx, tenantID, userID); err != nil {
+			return err
+		}
+		if err := model.ForgetUserTraceAccess(ctx, tx, tenantID, userID); err != nil {
+			return err
+		}
+		if err := model.DeleteFavorites(ctx, tx, tenantID, userID); err != nil {
+			return err
+		}
+		if _, err := model.DeleteUserAvatar(ctx, tx, tenantID, userID); err != nil && !errors.Is(err, model.ErrNotFound) {
+			return err
+		}
+		freed, err := model.DeleteUserTraces(ctx, tx, tenantID, userID)
+		if err != nil {
+			return err
+		}
+		var total int64
+		for courseID, sizeBytes := range freed {
+			if err := model.ChargeCourseStorage(ctx, tx, tenantID, courseID, -sizeBytes, 0); err != nil {
+				return err
+			}
+			total += sizeBytes
+		}
+		if err := model.ChargeTenantUsage(ctx, tx, tenantID, model.UsageDelta{StorageBytes: -total}); err != nil {
+			return err
+		}
+		return model.ClearDataJobResults(ctx, tx, tenantID, userID)
+	})
+}
+
+func (p *Postgres) TraceFileInUse(ctx context.Context, userID uuid.UUID, objectName string) (bool, error) {
+	return model.TraceFileInUse(ctx, p.db, userID, objectName)
+}
+
+func (p *Postgres) GetCanvasConnection(ctx context.Context) (*model.CanvasConnection, error) {
+	return model.GetCanvasConnection(ctx, p.db, tenant.ID(ctx))
+}
+
+func (p *Postgres) SaveCanvasConnection(ctx context.Context, req model.SaveCanvasConnectionRequest) (*model.CanvasConnection, error) {
+	return model.SaveCanvasConnection(ctx, p.db, tenant.ID(ctx), req)
+}
+
+func (p *Postgres) DeleteCanvasConnection(ctx context.Context) error {
+	return model.DeleteCanvasConnection(ctx, p.db, tenant.ID(ctx))
+}
+
+func (p *Postgres) LinkCanvasCourse(ctx context.Context, courseID uuid.UUID, canvasCourseID int64) (*model.CanvasCourseLink, error) {
+	return model.LinkCanvasCourse(ctx, p.db, tenant.ID(ctx), courseID, canvasCourseID)
+}
+
+func (p *Postgres) UnlinkCanvasCourse(ctx context.Context, courseID uuid.UUID) error {
+	return model.UnlinkCanvasCourse(ctx, p.db, tenant.ID(ctx), courseID)
+}
+
+func (p *Postgres) ListCanvasCourseLinks(ctx context.Context) ([]model.CanvasCourseLink, error) {
+	return model.ListCanvasCourseLinks(ctx, p.db, tenant.ID(ctx))
+}
+
+func (p *Postgres) ReplaceCanvasRoster(ctx context.Context, courseID uuid.UUID, entries []model.CanvasRosterEntry) ([]model.CanvasRosterEntry, error) {
+	return model.ReplaceCanvasRoster(ctx, p.db, tenant.ID(ctx), courseID, entries)
+}
+
+func (p *Postgres) GetCanvasRoster(ctx context.Context, courseID uuid.UUID) ([]model.CanvasRosterEntry, error) {
+	return model.GetCanvasRoster(ctx, p.db, tenant.ID(ctx), courseID)
+}
+
+func (p *Postgres) CreateCanvasSync(ctx context.Context, trigger string, requestedBy *uuid.UUID) (*model.CanvasSync, error) {
+	return model.CreateCanvasSync(ctx, p.db, tenant.ID(ctx), trigger, requestedBy)
+}
+
+func (p *Postgres) ListCanvasSyncs(ctx context.Context, limit int) ([]model.CanvasSync, error) {
+	return model.ListCanvasSyncs(ctx, p.db, tenant.ID(ctx), limit)
+}
+
+func (p *Postgres) GetCanvasSync(ctx context.Context, syncID uuid.UUID) (*model.CanvasSync, error) {
+	return model.GetCanvasSync(ctx, p.db, tenant.ID(ctx), syncID)
+}
+
+func (p *Postgres) ScheduleCanvasSyncs(ctx context.Context, interval time.Duration) (int, error) {
+	return model.ScheduleCanvasSyncs(ctx, p.db, interval)
+}
+
+func (p *Postgres) ClaimCanvasSync(ctx context.Context, lease time.Duration) (*model.CanvasSync, error) {
+	return model.ClaimCanvasSync(ctx, p.db, lease)
+}
+
+func (p *Postgres) FinishCanvasSync(ctx context.Context, sync model.CanvasSync) error {
+	return model.FinishCanvasSync(ctx, p.db, sync)
+}
+
+func (p *Postgres) CreateDataJob(ctx context.Context, kind string, userID, requestedBy uuid.UUID) (*model.DataJob, error) {
+	return model.CreateDataJob(ctx, p.db, tenant.ID(ctx), kind, userID, requestedBy)
+}
+
+func (p *Postgres) ListDataJobs(ctx context.Context, userID uuid.UUID) ([]model.DataJob, error) {
+	return model.ListDataJobs(ctx, p.db, tenant.ID(ctx), userID)
+}
+
+func (p *Postgres) GetDataJob(ctx context.Context, userID, jobID uuid.UUID) (*model.DataJob, error) {
+	return model.GetDataJob(ctx, p.db, tenant.ID(ctx), userID, jobID)
+}
+
+func (p *Postgres) ClaimDataJob(ctx context.Context, lease time.Duration) (*model.DataJob, error) {
+	return model.ClaimDataJob(ctx, p.db, lease)
+}
+
+func (p *Postgres) FinishDataJob(ctx context.Context, job model.DataJob, topic string, payload []byte) error {
+	return model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		if err := model.FinishDataJob(ctx, tx, job); err != nil {
+			return err
+		}
+		if topic == "" {
+			return nil
+		}
+		_, err := model.InsertOutboxEvent(ctx, tx, topic, job.ID, payload)
+		return err
+	})
+}
+
+// DispatchOutbox locks a batch of pending events with SKIP LOCKED, so several
+// relays can run against the same database without double-publishing. A
+// trace's publish status follows its event in the same transaction.
+func (p *Postgres) DispatchOutbox(ctx context.Context, limit, maxAttempts int, publish func(model.OutboxEvent) error) (int, error) {
+	handled := 0
+	err := model.WithTx(ctx, p.db, func(tx model.DBTX) error {
+		events, err := model.LockPendingOutboxEvents(ctx, tx, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			status := model.OutboxStatusPublished
+			if err := publish(event); err != nil {
+				if status, err = model.RecordOutboxFailure(ctx, tx, event.ID, err.Error(), maxAttempts); err != nil {
+					return err
+				}
+			} else {
+				if err := model.MarkOutboxEventPublished(ctx, tx, event.ID); err != nil {
+					return err
+				}
+				handled++
+			}
+			if status == model.OutboxStatusPending {
+				continue
+			}
+			if err := model.SetTracePublishStatus(ctx, tx, event.AggregateID, model.PublishStatusOf(status)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return handled, err
+}
+
+func (p *Postgres) GetOutboxBacklog(ctx context.Context) (*model.OutboxBacklog, error) {
+	return model.GetOutboxBacklog(ctx, p.db)
+}
+
+func (p *Postgres) ListFeatureFlagOverrides(ctx context.Context) ([]model.FeatureFlagOverride, error) {
+	return model.ListFeatureFlagOverrides(ctx, p.db)
+}
+
+func (p *Postgres) SetFeatureFlagOverride(ctx context.Context, name string, enabled bool, userID uuid.UUID) (*model.FeatureFlagOverride, error) {
+	return model.SetFeatureFlagOverride(ctx, p.db, name, enabled, userID)
+}
+
+func (p *Postgres) DeleteFeatureFlagOverride(ctx context.Context, name string) error {
+	return model.DeleteFeatureFlagOverride(ctx, p.db, name)
+}
@@ -0,0 +1,347 @@
+// internal/repository/repository.go
+package repository
+
+import (
+	"api-server/internal/model"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Repository is the data access used by handlers and background workers.
+// Postgres backs it in production; Memory backs MODE=inmemory. Errors follow
+// the model package: model.ErrNotFound, model.ErrInvalidCredentials, and
+// constraint violations recognised by model.IsUniqueViolation and
+// model.IsForeignKeyViolation.
+//
+// User, session, service account, instructor, course and trace methods only see the
+// tenant that tenant.ID(ctx) names, as do data jobs. The trace methods used by background
+// jobs (GetArchivableTraces through MarkTraceFailed), ClaimDataJob,
+// FinishDataJob, ScheduleCanvasSyncs, ClaimCanvasSync, FinishCanvasSync,
+// ArchiveOldCourses and CreateTracePartitions work across tenants.
+//
+// Course and trace writes keep the tenant's usage current. CreateCourse,
+// CreateCourses and ChargeUpload fail with a *model.QuotaError when they would exceed a quota.
+type Repository interface {
+	Ping(ctx context.Context) error
+	InsertHealthCheck(ctx context.Context) error
+
+	// Tenants. GetTenantByAPIKey takes the key itself, not its hash.
+	CreateTenant(ctx context.Context, req model.CreateTenantRequest, apiKeyHash *string) (*model.Tenant, error)
+	GetTenantByID(ctx context.Context, tenantID uuid.UUID) (*model.Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (*model.Tenant, error)
+	GetTenantByAPIKey(ctx context.Context, key string) (*model.Tenant, error)
+	ListTenants(ctx context.Context) ([]model.Tenant, error)
+	UpdateTenant(ctx context.Context, slug string, req model.UpdateTenantRequest) (*model.Tenant, error)
+	DeleteTenant(ctx context.Context, slug string) error
+
+	// Usage. Uploads are charged before they are stored, against the quotas
+	// of ctx's tenant and the limits for the course and the uploading user,
+	// and refunded if they are not recorded.
+	GetTenantUsage(ctx context.Context, tenantID uuid.UUID) (*model.TenantUsage, error)
+	GetUserUploadUsage(ctx context.Context, userID uuid.UUID) (*model.UserUploadUsage, error)
+	ChargeUpload(ctx context.Context, courseID, userID uuid.UUID, sizeBytes int64, limits model.UploadLimits) error
+	RefundUpload(ctx context.Context, courseID, userID uuid.UUID, sizeBytes int64) error
+
+	// Users. LoginExternal returns the user linked to the identity, linking
+	// the user with the identity's email if it is verified, or else creating
+	// one with role; created reports the latter.
+	CreateUser(ctx context.Context, req model.CreateUserRequest) (*model.User, error)
+	AuthenticateUser(ctx context.Context, username, password string) (*model.User, error)
+	GetUser(ctx context.Context, userID uuid.UUID) (*model.User, error)
+	UpdateUser(ctx context.Context, userID uuid.UUID, req model.UpdateUserRequest) (*model.User, error)
+	ListUsers(ctx context.Context, opts model.ListOptions) (*model.Page[model.User], error)
+	// AdminUpdateUser applies adminID's change to a user, revokes their
+	// sessions if the change calls for it and writes a user.updated audit
+	// entry for what changed
+	AdminUpdateUser(ctx context.Context, userID uuid.UUID, req model.AdminUpdateUserRequest, adminID uuid.UUID) (*model.User, error)
+	LoginExternal(ctx context.Context, identity model.ExternalIdentity, role string) (user *model.User, created bool, err error)
+
+	// Profile pictures. DeleteUserAvatar returns the picture it forgot, so
+	// its PNG can be deleted from storage.
+	GetUserAvatar(ctx context.Context, userID uuid.UUID) (*model.UserAvatar, error)
+	SaveUserAvatar(ctx context.Context, userID uuid.UUID, avatar model.UserAvatar) (*model.UserAvatar, error)
+	DeleteUserAvatar(ctx context.Context, userID uuid.UUID) (*model.UserAvatar, error)
+
+	// Multi-factor authentication. UseMFAStep accepts a TOTP time step at
+	// most once and enables a pending enrollment; UseRecoveryCode consumes
+	// a recovery code by its hash. CountMFAAttempt and ClearMFAAttempts
+	// limit the codes tried as model.CountMFAAttempt describes, with a nil
+	// challengeID for codes sent without a login challenge.
+	GetMFA(ctx context.Context, userID uuid.UUID) (*model.MFA, error)
+	SaveMFAEnrollment(ctx context.Context, userID uuid.UUID, secret string, recoveryCodes []string) (*model.MFA, error)
+	UseMFAStep(ctx context.Context, userID uuid.UUID, step int64) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) error
+	CountMFAAttempt(ctx context.Context, userID, challengeID uuid.UUID, challengeExpires time.Time, limits model.MFALimits) error
+	ClearMFAAttempts(ctx context.Context, userID, challengeID uuid.UUID) error
+	DeleteMFA(ctx context.Context, userID uuid.UUID) error
+
+	// Notifications. The feed is filled by UpdateTraceStatus, for the
+	// uploader of a trace that becomes processed, and UpdateCourse, for
+	// the course's other uploaders, unless they turned the type off.
+	// Email and notification types a user hasn't set are on.
+	// UpdateNotificationPreferences returns model.ErrNotFound for an
+	// unknown user, SetNotificationRead for a notification not the user's.
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error)
+	UpdateNotificationPreferences(ctx context.Context, userID uuid.UUID, req model.UpdateNotificationPreferencesRequest) (*model.NotificationPreferences, error)
+	ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, opts model.ListOptions) (*model.Page[model.Notification], error)
+	SetNotificationRead(ctx context.Context, userID, notificationID uuid.UUID, read bool) (*model.Notification, error)
+	MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int, error)
+
+	// Sessions, one per bearer token. TouchSession returns
+	// model.ErrNotFound for a revoked or expired session. StartImpersonation
+	// creates a session an admin takes as its user and writes a
+	// user.impersonated audit entry for it.
+	CreateSession(ctx context.Context, session model.Session) (*model.Session, error)
+	StartImpersonation(ctx context.Context, session model.Session) (*model.Session, error)
+	TouchSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error)
+	DeleteSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	DeleteSessions(ctx context.Context, userID uuid.UUID) error
+
+	// Service accounts. AuthenticateServiceAccount takes the key itself,
+	// not its hash, and returns model.ErrNotFound for an unknown key.
+	CreateServiceAccount(ctx context.Context, req model.CreateServiceAccountRequest, keyHash string, createdBy uuid.UUID) (*model.ServiceAccount, error)
+	ListServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error)
+	GetServiceAccount(ctx context.Context, accountID uuid.UUID) (*model.ServiceAccount, error)
+	UpdateServiceAccount(ctx context.Context, accountID uuid.UUID, req model.UpdateServiceAccountRequest) (*model.ServiceAccount, error)
+	RotateServiceAccountKey(ctx context.Context, accountID uuid.UUID, keyHash string) (*model.ServiceAccount, error)
+	DeleteServiceAccount(ctx context.Context, accountID uuid.UUID) error
+	AuthenticateServiceAccount(ctx context.Context, key string) (*model.ServiceAccount, error)
+
+	// Instructors. GetInstructorByEmail ignores the email's case.
+	// CreateInstructors inserts many at once, all or none, returning them in
+	// the order asked.
+	CreateInstructor(ctx context.Context, req model.CreateInstructorRequest, userID uuid.UUID) (*model.Instructor, error)
+	CreateInstructors(ctx context.Context, reqs []model.CreateInstructorRequest, userID uuid.UUID) ([]model.Instructor, error)
+	GetInstructorByID(ctx context.Context, instructorID uuid.UUID) (*model.Instructor, error)
+	GetInstructorByEmail(ctx context.Context, email string) (*model.Instructor, error)
+	ListInstructors(ctx context.Context, opts model.ListOptions) (*model.Page[model.Instructor], error)
+	UpdateInstructor(ctx context.Context, instructorID uuid.UUID, req model.UpdateInstructorRequest) (*model.Instructor, error)
+	DeleteInstructorByID(ctx context.Context, instructorID uuid.UUID) error
+
+	// Courses. UpdateCourse also writes a course.updated audit entry in the
+	// same transaction. GetCourseBySection returns the oldest course with a
+	// subject code and number in a semester. BulkUpdateCourses updates every
+	// course the filter matches as UpdateCourse would, all in one
+	// transaction, skipping courses already set as asked; a dry run only
+	// reports the changes. ListCourses lists either the archived courses or
+	// the others. SetCourseArchived writes a course.archived or
+	// course.unarchived audit entry when it changes the course. CreateCourses
+	// creates many courses as CreateCourse would, all or none in one
+	// transaction, returning them in the order asked.
+	CreateCourse(ctx context.Context, req model.CreateCourseRequest, userID uuid.UUID) (*model.Course, error)
+	CreateCourses(ctx context.Context, reqs []model.CreateCourseRequest, userID uuid.UUID) ([]model.Course, error)
+	GetCourseByID(ctx context.Context, courseID uuid.UUID) (*model.Course, error)
+	// GetCourseExpanded reads a course with the related resources expand
+	// names embedded, in a single query
+	GetCourseExpanded(ctx context.Context, courseID uuid.UUID, expand model.CourseExpand) (*model.Course, error)
+	GetCourseBySection(ctx context.Context, subjectCode string, courseID int, semesterTerm string, semesterYear int) (*model.Course, error)
+	ListCourses(ctx context.Context, archived bool, opts model.ListOptions) (*model.Page[model.Course], error)
+	// StreamCourses calls fn with every course ListCourses would list, in
+	// order and ignoring opts.Page, without reading them all first; an
+	// error from fn stops it
+	StreamCourses(ctx context.Context, archived bool, opts model.ListOptions, fn func(model.Course) error) error
+	UpdateCourse(ctx context.Context, courseID uuid.UUID, req model.UpdateCourseRequest, userID uuid.UUID) (*model.Course, error)
+	BulkUpdateCourses(ctx context.Context, filter model.CourseFilter, req model.UpdateCourseRequest, userID uuid.UUID, dryRun bool) ([]model.CourseChange, error)
+	DeleteCourseByID(ctx context.Context, courseID uuid.UUID) error
+	SetCourseArchived(ctx context.Context, courseID uuid.UUID, archived bool, userID uuid.UUID) (*model.Course, error)
+
+	// Course history. CreateCourse records version 1 of a course, and
+	// UpdateCourse and BulkUpdateCourses the next version whenever they
+	// change more than who last edited it. ListCourseVersions leaves checking
+	// the course exists to the caller.
+	ListCourseVersions(ctx context.Context, courseID uuid.UUID, opts model.ListOptions) (*model.Page[model.CourseVersion], error)
+	GetCourseVersion(ctx context.Context, courseID uuid.UUID, version int) (*model.CourseVersion, error)
+
+	// Event ledger. Every change to a course or one of its traces appends
+	// an event in the same transaction as the change itself, and events are
+	// never changed or removed until their tenant is. ListCourseEvents leaves
+	// checking the course exists to the caller.
+	ListCourseEvents(ctx context.Context, courseID uuid.UUID, opts model.ListOptions) (*model.Page[model.Event], error)
+
+	// Traces. InsertTraceWithEvent writes the trace and an outbox event for
+	// it atomically, the trace publish_pending until DispatchOutbox publishes
+	// or gives up on the event. Inserting a trace does not charge its size, which the
+	// upload already did; deleting one gives it back.
+	InsertTrace(ctx context.Context, trace NewTrace) (*model.Trace, error)
+	InsertTraceWithEvent(ctx context.Context, trace NewTrace, topic string, payload []byte) (*model.Trace, error)
+	// GetTracesByCourseID lists the traces with one of the visible
+	// visibilities, or all of them if visible is nil
+	GetTracesByCourseID(ctx context.Context, courseID uuid.UUID, visible []string, opts model.ListOptions) (*model.Page[model.Trace], error)
+	// StreamTracesByCourseID is to GetTracesByCourseID what StreamCourses
+	// is to ListCourses
+	StreamTracesByCourseID(ctx context.Context, courseID uuid.UUID, visible []string, opts model.ListOptions, fn func(model.Trace) error) error
+	GetTraceByID(ctx context.Context, courseID, traceID uuid.UUID) (*model.Trace, error)
+	// ExpandTraces embeds the related resources expand names in traces
+	// listed or got above, without a lookup per trace
+	ExpandTraces(ctx context.Context, traces []model.Trace, expand model.TraceExpand) error
+	DeleteTraceByID(ctx context.Context, courseID, traceID uuid.UUID) error
+	// ReplaceTraceFile keeps a trace's file as its latest earlier revision and
+	// points the trace at file, writing an outbox event for the new file as
+	// InsertTraceWithEvent does. Like inserting, it does not charge the size.
+	// ListTraceRevisions returns every revision, newest first.
+	ReplaceTraceFile(ctx context.Context, courseID, traceID uuid.UUID, file model.TraceFile, userID uuid.UUID, topic string, payload []byte) (*model.Trace, error)
+	ListTraceRevisions(ctx context.Context, courseID, traceID uuid.UUID) ([]model.TraceRevision, error)
+	// UpdateTraceVisibility sets who may read a trace, recording userID's
+	// change in the event ledger
+	UpdateTraceVisibility(ctx context.Context, courseID, traceID uuid.UUID, visibility string, userID uuid.UUID) (*model.Trace, error)
+	// Share links of traces. CreateTraceShare takes HashAPIKey of the link's
+	// token; GetSharedTrace looks the token up in every tenant and returns
+	// the trace and the link's ID, or ErrNotFound for an expired or revoked
+	// link.
+	CreateTraceShare(ctx context.Context, courseID, traceID uuid.UUID, tokenHash string, userID uuid.UUID, expiresAt time.Time) (*model.TraceShare, error)
+	ListTraceShares(ctx context.Context, courseID, traceID uuid.UUID) ([]model.TraceShare, error)
+	RevokeTraceShare(ctx context.Context, courseID, traceID, shareID uuid.UUID) (*model.TraceShare, error)
+	GetSharedTrace(ctx context.Context, token string) (*model.Trace, uuid.UUID, error)
+	// Access logs of traces. RecordTraceAccess records into the tenant of
+	// the access's trace, whichever that is, since share links are opened
+	// without one. ListTraceAccess returns model.ErrNotFound for a trace
+	// not in the course.
+	RecordTraceAccess(ctx context.Context, access model.TraceAccess) (*model.TraceAccess, error)
+	ListTraceAccess(ctx context.Context, courseID, traceID uuid.UUID, opts model.ListOptions) (*model.Page[model.TraceAccess], error)
+	UpdateTraceStatus(ctx context.Context, courseID, traceID uuid.UUID, req model.UpdateTraceStatusRequest) (*model.Trace, error)
+	// UpdateTraceEmbedding stores what the processing pipeline computed for
+	// a trace. SimilarTraces returns model.ErrNotFound for an unknown course;
+	// SearchTraces searches the tenant's processed traces in every course.
+	UpdateTraceEmbedding(ctx context.Context, courseID, traceID uuid.UUID, req model.UpdateTraceRequest) (*model.Trace, error)
+	SimilarTraces(ctx context.Context, courseID uuid.UUID, embedding []float32, limit int) ([]model.SimilarTrace, error)
+	SearchTraces(ctx context.Context, embedding []float32, filters model.SearchFilters, limit int) ([]model.SearchResult, error)
+	// Trace content. GetTracesWithoutContent and SaveTraceContent serve the
+	// background extractor across tenants, and saving new text drops the
+	// cached summary; the rest work within the caller's tenant.
+	// GetTraceContent returns model.ErrNotFound until the text is extracted.
+	GetTracesWithoutContent(ctx context.Context, limit int) ([]model.Trace, error)
+	SaveTraceContent(ctx context.Context, traceID uuid.UUID, content model.TraceContent) error
+	GetTraceContent(ctx context.Context, courseID, traceID uuid.UUID) (*model.TraceContent, error)
+	SaveTraceSummary(ctx context.Context, traceID uuid.UUID, summary, summaryModel string) (*model.TraceSummary, error)
+	SearchTraceContent(ctx context.Context, query string, courseID *uuid.UUID, limit int) ([]model.KeywordResult, error)
+	// Trace thumbnails. GetTracesWithoutThumbnail and SaveTraceThumbnail
+	// serve the background generator across tenants; GetTraceThumbnail
+	// works within the caller's tenant and returns model.ErrNotFound until
+	// the trace is rendered.
+	GetTracesWithoutThumbnail(ctx context.Context, limit int) ([]model.Trace, error)
+	SaveTraceThumbnail(ctx context.Context, traceID uuid.UUID, thumbnail model.TraceThumbnail) error
+	GetTraceThumbnail(ctx context.Context, courseID, traceID uuid.UUID) (*model.TraceThumbnail, error)
+	// ArchiveOldCourses archives the courses of semesters before
+	// beforeSemester, a model.SemesterIndex, and returns how many it archived.
+	// Courses an admin has unarchived are skipped.
+	ArchiveOldCourses(ctx context.Context, beforeSemester int) (int, error)
+	// CreateTracePartitions makes sure traces of the semesters starting
+	// within monthsAhead months have a partition to go in, and returns how
+	// many partitions it created
+	CreateTracePartitions(ctx context.Context, monthsAhead int) (int, error)
+	GetArchivableTraces(ctx context.Context, beforeSemester int, limit int) ([]model.Trace, error)
+	UpdateTraceStorage(ctx context.Context, traceID uuid.UUID, storageTier, bucketURL string) error
+	ListStoredTraces(ctx context.Context) ([]model.Trace, error)
+	MarkTraceFailed(ctx context.Context, traceID uuid.UUID) error
+
+	// Trace comments, which go with their trace. CreateTraceComment and
+	// ListTraceComments return model.ErrNotFound for a trace not in the
+	// course, GetTraceComment and DeleteTraceComment for a comment not on
+	// the trace.
+	CreateTraceComment(ctx context.Context, courseID, traceID, userID uuid.UUID, req model.CreateTraceCommentRequest) (*model.TraceComment, error)
+	ListTraceComments(ctx context.Context, courseID, traceID uuid.UUID, opts model.ListOptions) (*model.Page[model.TraceComment], error)
+	GetTraceComment(ctx context.Context, traceID, commentID uuid.UUID) (*model.TraceComment, error)
+	DeleteTraceComment(ctx context.Context, traceID, commentID uuid.UUID) error
+
+	// Favorites, the courses a user saved to their shortlist. AddFavorite
+	// returns model.ErrNotFound for an unknown course and reports whether
+	// the course was newly added; RemoveFavorite returns model.ErrNotFound
+	// when the course isn't a favorite. Deleting a course drops it from
+	// every shortlist.
+	AddFavorite(ctx context.Context, userID, courseID uuid.UUID) (*model.Course, bool, error)
+	RemoveFavorite(ctx context.Context, userID, courseID uuid.UUID) error
+	ListFavorites(ctx context.Context, userID uuid.UUID, opts model.ListOptions) (*model.Page[model.Course], error)
+
+	// Personal data. EraseUser anonymizes the account, deletes the user's
+	// traces, comments, favorites, notifications and notification
+	// preferences, and
+	// forgets their export archives, whose objects the caller deletes from
+	// storage first. Courses the user created are kept. TraceFileInUse
+	// reports whether another user's trace or trace revision, in any tenant,
+	// still stores its file under objectName, which is then kept.
+	GetUserData(ctx context.Context, userID uuid.UUID) (*model.UserData, error)
+	EraseUser(ctx context.Context, userID uuid.UUID) error
+	TraceFileInUse(ctx context.Context, userID uuid.UUID, objectName string) (bool, error)
+
+	// Canvas sync. GetCanvasConnection and DeleteCanvasConnection return
+	// model.ErrNotFound when the tenant hasn't connected Canvas,
+	// LinkCanvasCourse for an unknown course, and UnlinkCanvasCourse,
+	// ReplaceCanvasRoster and GetCanvasRoster for a course that isn't
+	// linked. CreateCanvasSync fails on the canvas_syncs_active_idx unique
+	// constraint while a sync is pending or running.
+	GetCanvasConnection(ctx context.Context) (*model.CanvasConnection, error)
+	SaveCanvasConnection(ctx context.Context, req model.SaveCanvasConnectionRequest) (*model.CanvasConnection, error)
+	DeleteCanvasConnection(ctx context.Context) error
+	LinkCanvasCourse(ctx context.Context, courseID uuid.UUID, canvasCourseID int64) (*model.CanvasCourseLink, error)
+	UnlinkCanvasCourse(ctx context.Context, courseID uuid.UUID) error
+	ListCanvasCourseLinks(ctx context.Context) ([]model.CanvasCourseLink, error)
+	ReplaceCanvasRoster(ctx context.Context, courseID uuid.UUID, entries []model.CanvasRosterEntry) ([]model.CanvasRosterEntry, error)
+	GetCanvasRoster(ctx context.Context, courseID uuid.UUID) ([]model.CanvasRosterEntry, error)
+	CreateCanvasSync(ctx context.Context, trigger string, requestedBy *uuid.UUID) (*model.CanvasSync, error)
+	ListCanvasSyncs(ctx context.Context, limit int) ([]model.CanvasSync, error)
+	GetCanvasSync(ctx context.Context, syncID uuid.UUID) (*model.CanvasSync, error)
+	ScheduleCanvasSyncs(ctx context.Context, interval time.Duration) (int, error)
+	ClaimCanvasSync(ctx context.Context, lease time.Duration) (*model.CanvasSync, error)
+	FinishCanvasSync(ctx context.Context, sync model.CanvasSync) error
+
+	// Data jobs. CreateDataJob returns model.ErrNotFound for an unknown user.
+	// ClaimDataJob returns model.ErrNotFound when no job is runnable.
+	// FinishDataJob records the job's Status, Error and ResultObject, and
+	// writes an outbox event with it when topic is not empty.
+	CreateDataJob(ctx context.Context, kind string, userID, requestedBy uuid.UUID) (*model.DataJob, error)
+	ListDataJobs(ctx context.Context, userID uuid.UUID) ([]model.DataJob, error)
+	GetDataJob(ctx context.Context, userID, jobID uuid.UUID) (*model.DataJob, error)
+	ClaimDataJob(ctx context.Context, lease time.Duration) (*model.DataJob, error)
+	FinishDataJob(ctx context.Context, job model.DataJob, topic string, payload []byte) error
+
+	// DispatchOutbox hands up to limit pending events to publish, oldest
+	// first, and records each outcome; an event is given up on after
+	// maxAttempts failures. It returns how many events were published.
+	DispatchOutbox(ctx context.Context, limit, maxAttempts int, publish func(model.OutboxEvent) error) (int, error)
+	// GetOutboxBacklog counts the pending and failed events across tenants
+	GetOutboxBacklog(ctx context.Context) (*model.OutboxBacklog, error)
+
+	// Feature flag overrides. DeleteFeatureFlagOverride returns
+	// model.ErrNotFound when the flag has no override.
+	ListFeatureFlagOverrides(ctx context.Context) ([]model.FeatureFlagOverride, error)
+	SetFeatureFlagOverride(ctx context.Context, name string, enabled bool, userID uuid.UUID) (*model.FeatureFlagOverride, error)
+	DeleteFeatureFlagOverride(ctx context.Context, name string) error
+}
+
+// NewTrace holds the columns of a trace record being inserted. A zero ID is
+// generated; callers set it to name the trace in an event written with it.
+type NewTrace struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	InstructorID uuid.UUID
+	CourseID     uuid.UUID
+	Status       string
+	VectorID     *string
+	FileName     string
+	BucketURL    string
+	ContentType  string
+	SizeBytes    int64
+	// Visibility defaults to private
+	Visibility string
+	// LinkURL is set for a video link, which has no file
+	LinkURL *string
+}
+
+// visibility is the visibility the trace is inserted with
+func (t NewTrace) visibility() string {
+	if t.Visibility == "" {
+		return model.VisibilityPrivate
+	}
+	return t.Visibility
+}
+
+// traceID is the ID the trace is inserted with
+func (t NewTrace) traceID() uuid.UUID {
+	if t.ID == uuid.Nil {
+		return uuid.New()
+	}
+	return t.ID
+}
@@ -0,0 +1,141 @@
+// internal/resilience/breaker.go
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrBreakerOpen is returned without calling the operation while the breaker is open
+var ErrBreakerOpen = errors.New("circuit breaker open")
+
+// BreakerState is exported as a gauge value: 0 closed, 1 half-open, 2 open
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker trips after Threshold consecutive failures and rejects calls until
+// Cooldown has passed, then lets a single trial call through.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{name: name, threshold: threshold, cooldown: cooldown}
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State reports the current state, moving open breakers to half-open once the cooldown elapsed
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	return b.state
+}
+
+// Execute runs fn unless the breaker is open and records its outcome.
+// Errors wrapped with Permanent don't count as failures.
+func (b *Breaker) Execute(fn func() error) error {
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	err := fn()
+	var perm *permanentError
+	b.record(err == nil || errors.As(err, &perm))
+	return err
+}
+
+func (b *Breaker) acquire() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+
+	switch b.state {
+	case StateOpen:
+		return ErrBreakerOpen
+	case StateHalfOpen:
+		// Only one trial call at a time while half-open
+		if b.trial {
+			return ErrBreakerOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+func (b *Breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.state = StateClosed
+		b.failures = 0
+		b.trial = false
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+		b.trial = false
+	}
+}
+
+func (b *Breaker) refresh() {
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.cooldown {
+		b.state = StateHalfOpen
+		b.trial = false
+	}
+}
+
+// RegisterBreakerMetrics exposes each breaker's state as circuit_breaker_state{name}
+func RegisterBreakerMetrics(reg prometheus.Registerer, breakers ...*Breaker) error {
+	for _, b := range breakers {
+		b := b
+		gauge := prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        "circuit_breaker_state",
+				Help:        "Circuit breaker state (0 closed, 1 half-open, 2 open)",
+				ConstLabels: prometheus.Labels{"name": b.name},
+			},
+			func() float64 { return float64(b.State()) },
+		)
+		if err := reg.Register(gauge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+// internal/response/codec.go
+package response
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// xmlRoot names the document element of XML responses. Array items are
+// <item> elements, and null values carry nil="true".
+const (
+	xmlRoot = "response"
+	xmlItem = "item"
+)
+
+// MaxJSONDepth caps how deeply a JSON request body may nest objects and
+// arrays
+const MaxJSONDepth = 32
+
+// Errors of DecodeJSON besides those of encoding/json
+var (
+	ErrTrailingData = errors.New("more than one JSON value")
+	ErrTooDeep      = fmt.Errorf("nested more than %d levels deep", MaxJSONDepth)
+)
+
+// Decode reads the request body into v according to its Content-Type:
+// JSON (the default), XML or MessagePack. XML elements and MessagePack keys
+// use the same names as the JSON fields.
+func Decode(r *http.Request, v any) error {
+	format, _ := BodyFormat(r.Header.Get("Content-Type"))
+	switch format {
+	case FormatXML:
+		return decodeXML(r.Body, v)
+	case FormatMsgpack:
+		return decodeMsgpack(r.Body, v)
+	}
+	return DecodeJSON(r.Body, v)
+}
+
+// DecodeJSON strictly decodes a single JSON value from body into v. Fields
+// v doesn't have are refused rather than dropped, as are anything after the
+// value and nesting deeper than MaxJSONDepth.
+func DecodeJSON(body io.Reader, v any) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if err := checkDepth(data); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+// checkDepth returns ErrTooDeep if data nests deeper than MaxJSONDepth.
+// Syntax errors are left for the decoder to report.
+func checkDepth(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			if depth++; depth > MaxJSONDepth {
+				return ErrTooDeep
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+}
+
+// jsonToXML converts a JSON document to XML, keeping object key order
+func jsonToXML(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+	enc := xml.NewEncoder(&out)
+	if err := writeXMLValue(dec, enc, xmlRoot); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// writeXMLValue writes the next JSON value from dec as an element named name
+func writeXMLValue(dec *json.Decoder, enc *xml.Encoder, name string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !validXMLName(name) {
+		// Map keys such as feature flag names need not be valid XML names
+		start = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for dec.More() {
+			child := xmlItem
+			if t == '{' {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child = keyTok.(string)
+			}
+			if err := writeXMLValue(dec, enc, child); err != nil {
+				return err
+			}
+		}
+		// Consume the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		return enc.EncodeToken(start.End())
+	case nil:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "nil"}, Value: "true"})
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		return enc.EncodeToken(start.End())
+	default:
+		return enc.EncodeElement(fmt.Sprint(t), start)
+	}
+}
+
+func validXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, c := range name {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || !(c == '-' || c == '.' || (c >= '0' && c <= '9'))) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonToMsgpack converts a JSON document to MessagePack, keeping integers
+// as integers
+func jsonToMsgpack(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(fromJSONNumbers(v))
+}
+
+func fromJSONNumbers(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]any:
+		for k, item := range t {
+			t[k] = fromJSONNumbers(item)
+		}
+	case []any:
+		for i, item := range t {
+			t[i] = fromJSONNumbers(item)
+		}
+	}
+	return v
+}
+
+// decodeMsgpack decodes MessagePack through its JSON form, so v's JSON tags
+// and field types (UUIDs, timestamps) apply exactly as they do for JSON
+func decodeMsgpack(r io.Reader, v any) error {
+	var generic any
+	if err := msgpack.NewDecoder(r).Decode(&generic); err != nil {
+		return err
+	}
+	data, err := json.Marshal(stringKeys(generic))
+	if err != nil {
+		return err
+	}
+	return DecodeJSON(bytes.NewReader(data), v)
+}
+
+// stringKeys converts maps with interface keys, which json can't encode
+func stringKeys(v any) any {
+	switch t := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(t))
+		for k, item := range t {
+			m[fmt.Sprint(k)] = stringKeys(item)
+		}
+		return m
+	case map[string]any:
+		for k, item := range t {
+			t[k] = stringKeys(item)
+		}
+	case []any:
+		for i, item := range t {
+			t[i] = stringKeys(item)
+		}
+	}
+	return v
+}
+
+// xmlNode is a parsed element, kept generic until it is matched to a field
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+// decodeXML fills v from a document whose child elements are named after
+// v's JSON fields; the document element's own name is not checked
+func decodeXML(r io.Reader, v any) error {
+	var root xmlNode
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("decode target must be a non-nil pointer")
+	}
+	return root.assign(rv.Elem())
+}
+
+func (n xmlNode) isNil() bool {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == "nil" && attr.Value == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+func (n xmlNode) assign(v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if n.isNil() {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return n.assign(v.Elem())
+	}
+
+	text := strings.TrimSpace(n.Content)
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(text))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := jsonFields(v.Type())
+		for _, child := range n.Nodes {
+			name := child.XMLName.Local
+			if name == "entry" {
+				for _, attr := range child.Attrs {
+					if attr.Name.Local == "key" {
+						name = attr.Value
+					}
+				}
+			}
+			index, ok := fields[name]
+			if !ok {
+				// Unknown fields are ignored, as they are in JSON bodies
+				continue
+			}
+			if err := child.assign(v.FieldByIndex(index)); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return nil
+	case reflect.Slice:
+		slice := reflect.MakeSlice(v.Type(), len(n.Nodes), len(n.Nodes))
+		for i, child := range n.Nodes {
+			if err := child.assign(slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.String:
+		v.SetString(text)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(text))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot decode XML into %s", v.Type())
+}
+
+// jsonFields maps the JSON names of t's exported fields, including those of
+// embedded structs, to their field indexes
+func jsonFields(t reflect.Type) map[string][]int {
+	fields := map[string][]int{}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		fields[name] = f.Index
+	}
+	return fields
+}
@@ -0,0 +1,177 @@
+// internal/response/negotiate.go
+package response
+
+import (
+	"bytes"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format is a serialization the API can speak besides its native JSON
+type Format int
+
+const (
+	FormatJSON Format = iota
+	FormatXML
+	FormatMsgpack
+)
+
+// mediaFormats maps accepted media types to formats; the first type listed
+// for a format is the one responses are labelled with
+var mediaFormats = []struct {
+	mediaType string
+	format    Format
+}{
+	{"application/json", FormatJSON},
+	{"application/xml", FormatXML},
+	{"text/xml", FormatXML},
+	{"application/msgpack", FormatMsgpack},
+	{"application/x-msgpack", FormatMsgpack},
+	{"application/vnd.msgpack", FormatMsgpack},
+}
+
+// BodyFormat returns the format of a request body with the given
+// Content-Type, and false for types other than JSON, XML and MessagePack
+func BodyFormat(contentType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return FormatJSON, false
+	}
+	if mediaType == "application/problem+json" {
+		return FormatJSON, true
+	}
+	for _, mf := range mediaFormats {
+		if mf.mediaType == mediaType {
+			return mf.format, true
+		}
+	}
+	return FormatJSON, false
+}
+
+// acceptedFormat picks the response format from an Accept header, by
+// quality and then order. Anything unrecognised, including */*, gets JSON,
+// so clients that never asked for another format see no change.
+func acceptedFormat(accept string) Format {
+	type candidate struct {
+		format Format
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if mediaType == "*/*" || mediaType == "application/*" {
+			candidates = append(candidates, candidate{FormatJSON, q})
+			continue
+		}
+		for _, mf := range mediaFormats {
+			if mf.mediaType == mediaType {
+				candidates = append(candidates, candidate{mf.format, q})
+				break
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	if len(candidates) == 0 {
+		return FormatJSON
+	}
+	return candidates[0].format
+}
+
+// Negotiate serves JSON responses as XML or MessagePack when the Accept
+// header prefers them. Handlers keep writing JSON; the body is re-encoded
+// here, keeping the JSON field names. Responses that aren't JSON, such as
+// metrics or ServeMux's plain-text 404s, pass through untouched.
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		format := acceptedFormat(r.Header.Get("Accept"))
+		if format == FormatJSON {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferedWriter{header: make(http.Header)}
+		next.ServeHTTP(bw, r)
+		if bw.status == 0 {
+			bw.status = http.StatusOK
+		}
+
+		body := bw.body.Bytes()
+		contentType := bw.header.Get("Content-Type")
+		if bodyFormat, ok := BodyFormat(contentType); ok && bodyFormat == FormatJSON && len(body) > 0 {
+			problem := strings.HasPrefix(contentType, "application/problem+json")
+			converted, convertedType, err := transcode(body, format, problem)
+			if err != nil {
+				log.Printf("Failed to re-encode response, sending JSON: %v", err)
+			} else {
+				body = converted
+				bw.header.Set("Content-Type", convertedType)
+				bw.header.Del("Content-Length")
+			}
+		}
+
+		dst := w.Header()
+		for k, v := range bw.header {
+			dst[k] = v
+		}
+		w.WriteHeader(bw.status)
+		w.Write(body)
+	})
+}
+
+// transcode re-encodes a JSON body as format
+func transcode(body []byte, format Format, problem bool) ([]byte, string, error) {
+	switch format {
+	case FormatXML:
+		out, err := jsonToXML(body)
+		if problem {
+			// RFC 7807 defines the XML flavour of problem documents
+			return out, "application/problem+xml", err
+		}
+		return out, "application/xml", err
+	case FormatMsgpack:
+		out, err := jsonToMsgpack(body)
+		return out, "application/msgpack", err
+	}
+	return body, "application/json", nil
+}
+
+// bufferedWriter holds a response until it has been re-encoded
+type bufferedWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedWriter) Write(p []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.body.Write(p)
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
@@ -0,0 +1,63 @@
+// internal/response/response.go
+package response
+
+import (
+	"api-server/internal/apierror"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// WriteJSON writes v as the JSON response body with the given status
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// WriteMessage writes a {"message": ...} body with the given status
+func WriteMessage(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, map[string]string{"message": message})
+}
+
+// WriteError writes err as {"error": {"code": ..., "message": ...}}. Errors
+// that aren't an *apierror.Error are logged and reported as INTERNAL_ERROR so
+// internal details don't leak to clients.
+func WriteError(w http.ResponseWriter, err error) {
+	apiErr := asAPIError(err)
+	WriteJSON(w, apiErr.Status, map[string]*apierror.Error{"error": apiErr})
+}
+
+// WriteProblem writes err as an RFC 7807 application/problem+json document,
+// keeping the API error code and details as extension members. Like
+// WriteError, unexpected errors become INTERNAL_ERROR.
+func WriteProblem(w http.ResponseWriter, err error) {
+	apiErr := asAPIError(err)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(apiErr.Status)
+	problem := map[string]any{
+		"type":   "about:blank",
+		"title":  http.StatusText(apiErr.Status),
+		"status": apiErr.Status,
+		"detail": apiErr.Message,
+		"code":   apiErr.Code,
+	}
+	if apiErr.Details != nil {
+		problem["details"] = apiErr.Details
+	}
+	if encodeErr := json.NewEncoder(w).Encode(problem); encodeErr != nil {
+		log.Printf("Failed to encode response: %v", encodeErr)
+	}
+}
+
+func asAPIError(err error) *apierror.Error {
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		log.Printf("Unhandled error: %v", err)
+		apiErr = apierror.Internal("Internal server error")
+	}
+	return apiErr
+}
@@ -0,0 +1,113 @@
+// internal/response/stream.go
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// A stream flushes what it has written after this many items, or once this
+// long has passed since it last flushed, so clients see progress on slow
+// queries as well as fast ones
+const (
+	streamFlushItems    = 100
+	streamFlushInterval = time.Second
+)
+
+// ArrayStream writes a JSON array as the response body one item at a time,
+// so a collection of any size is sent without being held in memory. Nothing
+// is written before the first item or Close, so an error until then can
+// still be answered with an ordinary error response. A stream abandoned part
+// way ends without its closing bracket, which tells the client it is
+// incomplete.
+type ArrayStream struct {
+	w         http.ResponseWriter
+	rc        *http.ResponseController
+	key       string
+	started   bool
+	unflushed int
+	lastFlush time.Time
+}
+
+// NewArrayStream streams a bare array or, when key isn't empty, an object
+// holding the array under key, such as {"data": [...]}
+func NewArrayStream(w http.ResponseWriter, key string) *ArrayStream {
+	return &ArrayStream{w: w, rc: http.NewResponseController(w), key: key}
+}
+
+// Started reports whether the status and any of the body have been sent
+func (s *ArrayStream) Started() bool {
+	return s.started
+}
+
+// Write sends v as the next item. Once ctx is done it returns ctx's error
+// instead, so the caller stops reading rows for a client that has gone.
+func (s *ArrayStream) Write(ctx context.Context, v any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	if s.started {
+		buf = append(buf, ',')
+	} else {
+		buf = s.start()
+	}
+	if _, err := s.w.Write(append(buf, data...)); err != nil {
+		return err
+	}
+
+	s.unflushed++
+	if s.unflushed >= streamFlushItems || time.Since(s.lastFlush) >= streamFlushInterval {
+		return s.flush()
+	}
+	return nil
+}
+
+// Close ends the array and flushes the rest of the body
+func (s *ArrayStream) Close() error {
+	var buf []byte
+	if !s.started {
+		buf = s.start()
+	}
+	buf = append(buf, ']')
+	if s.key != "" {
+		buf = append(buf, '}')
+	}
+	if _, err := s.w.Write(append(buf, '\n')); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// start sends the status and returns the opening of the body
+func (s *ArrayStream) start() []byte {
+	s.started = true
+	s.lastFlush = time.Now()
+	s.w.Header().Set("Content-Type", "application/json")
+	s.w.WriteHeader(http.StatusOK)
+
+	if s.key == "" {
+		return []byte{'['}
+	}
+	key, _ := json.Marshal(s.key)
+	return append(append([]byte{'{'}, key...), ':', '[')
+}
+
+// flush sends what has been written so far. Writers that can't flush, such
+// as those buffering a response to re-encode it, send it all at the end.
+func (s *ArrayStream) flush() error {
+	s.unflushed = 0
+	s.lastFlush = time.Now()
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// internal/router/router.go
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps a handler with behaviour that runs around it
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in mws so that mws[0] sees the request first
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Router registers routes on a ServeMux. A group shares its parent's mux but
+// adds a path prefix and middleware of its own, which runs after the
+// parent's; middleware passed to Handle runs last, closest to the handler.
+type Router struct {
+	mux        *http.ServeMux
+	prefix     string
+	middleware []Middleware
+}
+
+func New(mws ...Middleware) *Router {
+	return &Router{mux: http.NewServeMux(), middleware: mws}
+}
+
+// Group returns a router for routes under prefix with mws added to the chain
+func (r *Router) Group(prefix string, mws ...Middleware) *Router {
+	return &Router{
+		mux:        r.mux,
+		prefix:     r.prefix + prefix,
+		middleware: append(append([]Middleware{}, r.middleware...), mws...),
+	}
+}
+
+// Handle registers h for pattern, a ServeMux pattern such as
+// "GET /course/{course_id}" relative to the group's prefix
+func (r *Router) Handle(pattern string, h http.Handler, mws ...Middleware) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	}
+	pattern = r.prefix + path
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+	chain := append(append([]Middleware{}, r.middleware...), mws...)
+	r.mux.Handle(pattern, Chain(h, chain...))
+}
+
+// HandleFunc registers fn for pattern, like Handle
+func (r *Router) HandleFunc(pattern string, fn http.HandlerFunc, mws ...Middleware) {
+	r.Handle(pattern, fn, mws...)
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+// Route returns the path part of the pattern that matched r, e.g.
+// "/v1/course/{course_id}", for use as a low-cardinality metric label
+func Route(r *http.Request) string {
+	if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+		return path
+	}
+	return r.Pattern
+}
@@ -0,0 +1,152 @@
+// internal/schema/schema.go
+package schema
+
+import (
+	"api-server/api"
+	"api-server/internal/router"
+	"api-server/internal/validation"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// bodies holds the JSON Schema api/openapi.yaml gives each route's JSON
+// request body, by method and path as ServeMux patterns spell them, e.g.
+// "POST /v1/course"
+var bodies = mustLoad(api.Spec)
+
+func mustLoad(spec []byte) map[string]*openapi3.Schema {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		panic(fmt.Sprintf("failed to parse OpenAPI spec: %v", err))
+	}
+	schemas := make(map[string]*openapi3.Schema)
+	for path, item := range doc.Paths.Map() {
+		for method, op := range item.Operations() {
+			if op.RequestBody == nil || op.RequestBody.Value == nil {
+				continue
+			}
+			media := op.RequestBody.Value.Content.Get("application/json")
+			if media == nil || media.Schema == nil {
+				continue
+			}
+			schemas[method+" "+path] = media.Schema.Value
+		}
+	}
+	return schemas
+}
+
+// Check validates a JSON request body against the schema of the route that
+// matched r, before it is decoded, so a misspelt or unknown field is refused
+// instead of silently dropped. It returns validation.Errors listing every
+// violated constraint, an error if body isn't JSON at all, and nil for a
+// valid body or a route without a schema.
+func Check(r *http.Request, body []byte) error {
+	schema, ok := bodies[r.Method+" "+router.Route(r)]
+	if !ok {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return err
+	}
+	err := schema.VisitJSON(value, openapi3.MultiErrors())
+	if err == nil {
+		return nil
+	}
+	errs := flatten(err, nil)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// flatten turns the nested errors of a schema check into one FieldError per
+// violated constraint
+func flatten(err error, errs validation.Errors) validation.Errors {
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		for _, e := range multi {
+			errs = flatten(e, errs)
+		}
+		return errs
+	}
+	var schemaErr *openapi3.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return append(errs, validation.FieldError{Rule: "schema", Message: err.Error()})
+	}
+
+	path := schemaErr.JSONPointer()
+	rule := schemaErr.SchemaField
+	// An unknown property is reported against the object holding it
+	if quoted, ok := strings.CutPrefix(schemaErr.Reason, "property "); ok && rule == "properties" {
+		if name, err := strconv.Unquote(strings.TrimSuffix(quoted, " is unsupported")); err == nil {
+			path, rule = append(path, name), "unknown"
+		}
+	}
+	field := strings.Join(path, ".")
+
+	if field == "" {
+		return append(errs, validation.FieldError{Rule: rule, Message: schemaErr.Reason})
+	}
+	if key, param, ok := messageKey(rule, schemaErr.Schema); ok {
+		return append(errs, validation.NewFieldError(field, rule, key, param))
+	}
+	return append(errs, validation.FieldError{Field: field, Rule: rule, Message: field + ": " + schemaErr.Reason})
+}
+
+// messageKey returns the i18n catalog key of the message for a violated
+// schema constraint and the parameter it is filled in with, so the message
+// can be localized
+func messageKey(rule string, s *openapi3.Schema) (key, param string, ok bool) {
+	number := func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*f, 'f', -1, 64)
+	}
+	count := func(n *uint64) string {
+		if n == nil {
+			return ""
+		}
+		return strconv.FormatUint(*n, 10)
+	}
+
+	switch rule {
+	case "required", "unknown":
+		return rule, "", true
+	case "type":
+		if s.Type != nil {
+			return "type", strings.Join(s.Type.Slice(), ", "), true
+		}
+	case "enum":
+		values := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			values[i] = fmt.Sprint(v)
+		}
+		return "oneof", strings.Join(values, ", "), true
+	case "minimum":
+		if s.ExclusiveMin {
+			return "gt", number(s.Min), true
+		}
+		return "gte", number(s.Min), true
+	case "maximum":
+		if s.ExclusiveMax {
+			return "lt", number(s.Max), true
+		}
+		return "lte", number(s.Max), true
+	case "minLength":
+		return "min_chars", count(&s.MinLength), true
+	case "maxLength":
+		return "max_chars", count(s.MaxLength), true
+	case "minItems":
+		return "min_items", count(&s.MinItems), true
+	case "maxItems":
+		return "max_items", count(s.MaxItems), true
+	}
+	return "", "", false
+}
@@ -0,0 +1,146 @@
+// internal/searchindex/client.go
+package searchindex
+
+import (
+	"api-server/internal/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// errNotFound is returned for a 404 from the search cluster
+var errNotFound = errors.New("search index: not found")
+
+// Client talks to an Elasticsearch or OpenSearch cluster over its REST API
+type Client struct {
+	baseURL  string
+	username string
+	password string
+	prefix   string
+	client   *http.Client
+}
+
+// New builds the Client selected by SEARCH_INDEX_BACKEND. With "none" it
+// returns nil and the search index is unavailable.
+func New(cfg *config.Config) *Client {
+	if cfg.SearchIndexBackend != "elasticsearch" {
+		return nil
+	}
+	return &Client{
+		baseURL:  strings.TrimRight(cfg.SearchIndexURL, "/"),
+		username: cfg.SearchIndexUsername,
+		password: cfg.SearchIndexPassword,
+		prefix:   cfg.SearchIndexPrefix,
+		client:   &http.Client{Timeout: cfg.SearchIndexTimeout},
+	}
+}
+
+// index returns the full name of one of the indices, CoursesIndex or
+// TracesIndex
+func (c *Client) index(name string) string {
+	return c.prefix + "-" + name
+}
+
+// EnsureIndices creates the courses and traces indices with their mappings
+// if they don't exist yet
+func (c *Client) EnsureIndices(ctx context.Context) error {
+	for name, mappings := range indexMappings {
+		err := c.do(ctx, http.MethodHead, "/"+c.index(name), nil, nil)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, errNotFound) {
+			return err
+		}
+		if err := c.do(ctx, http.MethodPut, "/"+c.index(name), map[string]any{"mappings": mappings}, nil); err != nil {
+			return fmt.Errorf("failed to create index %s: %w", c.index(name), err)
+		}
+	}
+	return nil
+}
+
+// put writes a whole document
+func (c *Client) put(ctx context.Context, index, id string, doc any) error {
+	return c.do(ctx, http.MethodPut, "/"+c.index(index)+"/_doc/"+url.PathEscape(id), doc, nil)
+}
+
+// update merges fields into a document. Documents that were never indexed
+// are left alone.
+func (c *Client) update(ctx context.Context, index, id string, fields map[string]any) error {
+	err := c.do(ctx, http.MethodPost, "/"+c.index(index)+"/_update/"+url.PathEscape(id), map[string]any{"doc": fields}, nil)
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	return err
+}
+
+// updateWhere sets fields on every document whose field equals value
+func (c *Client) updateWhere(ctx context.Context, index, field, value string, fields map[string]any) error {
+	var script strings.Builder
+	for name := range fields {
+		fmt.Fprintf(&script, "ctx._source.%s = params.%s;", name, name)
+	}
+	body := map[string]any{
+		"query":  map[string]any{"term": map[string]any{field: value}},
+		"script": map[string]any{"source": script.String(), "params": fields},
+	}
+	return c.do(ctx, http.MethodPost, "/"+c.index(index)+"/_update_by_query?conflicts=proceed", body, nil)
+}
+
+// delete removes a document; it's fine if it was never indexed
+func (c *Client) delete(ctx context.Context, index, id string) error {
+	err := c.do(ctx, http.MethodDelete, "/"+c.index(index)+"/_doc/"+url.PathEscape(id), nil, nil)
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	return err
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response
+// into out when it isn't nil
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call search index: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("search index returned %s for %s %s: %s", resp.Status, method, path, bytes.TrimSpace(detail))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode search index response: %w", err)
+	}
+	return nil
+}
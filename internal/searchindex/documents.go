@@ -0,0 +1,137 @@
+// internal/searchindex/documents.go
+package searchindex
+
+import (
+	"api-server/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Index names, after the configured prefix
+const (
+	CoursesIndex = "courses"
+	TracesIndex  = "traces"
+)
+
+// indexMappings are the field types of each index. Keyword fields are
+// filtered and faceted on; text fields are searched and highlighted.
+var indexMappings = map[string]map[string]any{
+	CoursesIndex: {
+		"dynamic": "strict",
+		"properties": map[string]any{
+			"id":            keyword,
+			"tenant_id":     keyword,
+			"name":          text,
+			"subject_code":  keyword,
+			"course_id":     integer,
+			"semester_term": keyword,
+			"semester_year": integer,
+			"credit_hours":  integer,
+			"instructor_id": keyword,
+			"date_created":  date,
+			"date_updated":  date,
+		},
+	},
+	TracesIndex: {
+		"dynamic": "strict",
+		"properties": map[string]any{
+			"id":            keyword,
+			"tenant_id":     keyword,
+			"course_id":     keyword,
+			"course_name":   text,
+			"subject_code":  keyword,
+			"semester_term": keyword,
+			"semester_year": integer,
+			"instructor_id": keyword,
+			"status":        keyword,
+			"file_name":     text,
+			"content":       text,
+			"date_created":  date,
+			"date_updated":  date,
+		},
+	},
+}
+
+var (
+	keyword = map[string]any{"type": "keyword"}
+	text    = map[string]any{"type": "text"}
+	integer = map[string]any{"type": "integer"}
+	date    = map[string]any{"type": "date"}
+)
+
+// courseDocument is a course as stored in the courses index
+type courseDocument struct {
+	ID           uuid.UUID `json:"id"`
+	TenantID     uuid.UUID `json:"tenant_id"`
+	Name         string    `json:"name"`
+	SubjectCode  string    `json:"subject_code"`
+	CourseID     int       `json:"course_id"`
+	SemesterTerm string    `json:"semester_term"`
+	SemesterYear int       `json:"semester_year"`
+	CreditHours  int       `json:"credit_hours"`
+	InstructorID uuid.UUID `json:"instructor_id"`
+	DateCreated  time.Time `json:"date_created"`
+	DateUpdated  time.Time `json:"date_updated"`
+}
+
+func newCourseDocument(tenantID uuid.UUID, course *model.Course) courseDocument {
+	return courseDocument{
+		ID:           course.ID,
+		TenantID:     tenantID,
+		Name:         course.Name,
+		SubjectCode:  course.SubjectCode,
+		CourseID:     course.CourseID,
+		SemesterTerm: course.SemesterTerm,
+		SemesterYear: course.SemesterYear,
+		CreditHours:  course.CreditHours,
+		InstructorID: course.InstructorID,
+		DateCreated:  course.DateCreated,
+		DateUpdated:  course.DateUpdated,
+	}
+}
+
+// traceDocument is a trace as stored in the traces index, with its course's
+// catalog fields copied in so traces can be filtered by them. Content is
+// added once the PDF's text is extracted.
+type traceDocument struct {
+	ID           uuid.UUID `json:"id"`
+	TenantID     uuid.UUID `json:"tenant_id"`
+	CourseID     uuid.UUID `json:"course_id"`
+	CourseName   string    `json:"course_name"`
+	SubjectCode  string    `json:"subject_code"`
+	SemesterTerm string    `json:"semester_term"`
+	SemesterYear int       `json:"semester_year"`
+	InstructorID uuid.UUID `json:"instructor_id"`
+	Status       string    `json:"status"`
+	FileName     string    `json:"file_name"`
+	DateCreated  time.Time `json:"date_created"`
+	DateUpdated  time.Time `json:"date_updated"`
+}
+
+func newTraceDocument(tenantID uuid.UUID, course *model.Course, trace *model.Trace) traceDocument {
+	return traceDocument{
+		ID:           trace.ID,
+		TenantID:     tenantID,
+		CourseID:     course.ID,
+		CourseName:   course.Name,
+		SubjectCode:  course.SubjectCode,
+		SemesterTerm: course.SemesterTerm,
+		SemesterYear: course.SemesterYear,
+		InstructorID: trace.InstructorID,
+		Status:       trace.Status,
+		FileName:     trace.FileName,
+		DateCreated:  trace.DateCreated,
+		DateUpdated:  trace.DateUpdated,
+	}
+}
+
+// courseFields are the course fields copied into its traces' documents
+func courseFields(course *model.Course) map[string]any {
+	return map[string]any{
+		"course_name":   course.Name,
+		"subject_code":  course.SubjectCode,
+		"semester_term": course.SemesterTerm,
+		"semester_year": course.SemesterYear,
+	}
+}
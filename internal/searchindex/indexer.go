@@ -0,0 +1,85 @@
+// internal/searchindex/indexer.go
+package searchindex
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/errortracking"
+	"api-server/internal/resilience"
+	"context"
+	"log"
+	"time"
+)
+
+// ensureRetryInterval is how long the indexer waits before trying again to
+// create missing indices
+const ensureRetryInterval = 30 * time.Second
+
+// operation is one write to mirror into the index
+type operation struct {
+	desc  string
+	apply func(ctx context.Context, c *Client) error
+}
+
+// Indexer applies index writes in the background, in the order they were
+// made, so a slow or unavailable cluster never holds up API requests.
+// Writes are retried with backoff; ones still failing, or made while the
+// queue is full, are logged and dropped.
+type Indexer struct {
+	client *Client
+	queue  chan operation
+	policy resilience.RetryPolicy
+}
+
+func NewIndexer(client *Client, cfg *config.Config) *Indexer {
+	return &Indexer{
+		client: client,
+		queue:  make(chan operation, cfg.SearchIndexQueueSize),
+		policy: resilience.RetryPolicy{
+			Attempts:  cfg.RetryMaxAttempts,
+			BaseDelay: cfg.RetryBaseDelay,
+			MaxDelay:  cfg.RetryMaxDelay,
+		},
+	}
+}
+
+// enqueue schedules op without blocking the caller
+func (ix *Indexer) enqueue(op operation) {
+	select {
+	case ix.queue <- op:
+	default:
+		log.Printf("Search index queue full, dropping %s", op.desc)
+	}
+}
+
+// Run creates the indices if needed, then applies queued writes until ctx
+// is cancelled
+func (ix *Indexer) Run(ctx context.Context) {
+	for {
+		err := ix.client.EnsureIndices(ctx)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to create search indices: %v", err)
+		errortracking.Capture(err, "searchindex", nil)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ensureRetryInterval):
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case op := <-ix.queue:
+			err := resilience.Retry(ctx, ix.policy, func(ctx context.Context) error {
+				return op.apply(ctx, ix.client)
+			})
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Failed to index %s: %v", op.desc, err)
+				errortracking.Capture(err, "searchindex", map[string]string{"operation": op.desc})
+			}
+		}
+	}
+}
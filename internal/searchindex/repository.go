@@ -0,0 +1,183 @@
+// internal/searchindex/repository.go
+package searchindex
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/tenant"
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Repository mirrors course and trace writes into the search index once
+// they succeed. Every other method goes straight to the wrapped repository.
+type Repository struct {
+	repository.Repository
+	indexer *Indexer
+}
+
+func NewRepository(next repository.Repository, indexer *Indexer) *Repository {
+	return &Repository{Repository: next, indexer: indexer}
+}
+
+func (r *Repository) CreateCourse(ctx context.Context, req model.CreateCourseRequest, userID uuid.UUID) (*model.Course, error) {
+	course, err := r.Repository.CreateCourse(ctx, req, userID)
+	if err == nil {
+		r.putCourse(tenant.ID(ctx), course)
+	}
+	return course, err
+}
+
+func (r *Repository) CreateCourses(ctx context.Context, reqs []model.CreateCourseRequest, userID uuid.UUID) ([]model.Course, error) {
+	courses, err := r.Repository.CreateCourses(ctx, reqs, userID)
+	for i := range courses {
+		r.putCourse(tenant.ID(ctx), &courses[i])
+	}
+	return courses, err
+}
+
+func (r *Repository) UpdateCourse(ctx context.Context, courseID uuid.UUID, req model.UpdateCourseRequest, userID uuid.UUID) (*model.Course, error) {
+	course, err := r.Repository.UpdateCourse(ctx, courseID, req, userID)
+	if err == nil {
+		r.putUpdatedCourse(tenant.ID(ctx), course)
+	}
+	return course, err
+}
+
+func (r *Repository) BulkUpdateCourses(ctx context.Context, filter model.CourseFilter, req model.UpdateCourseRequest, userID uuid.UUID, dryRun bool) ([]model.CourseChange, error) {
+	results, err := r.Repository.BulkUpdateCourses(ctx, filter, req, userID, dryRun)
+	if err == nil && !dryRun {
+		for _, result := range results {
+			if len(result.Changes) > 0 {
+				r.putUpdatedCourse(tenant.ID(ctx), &result.Course)
+			}
+		}
+	}
+	return results, err
+}
+
+// putUpdatedCourse indexes an updated course and copies its fields onto its
+// traces
+func (r *Repository) putUpdatedCourse(tenantID uuid.UUID, course *model.Course) {
+	r.putCourse(tenantID, course)
+	fields := courseFields(course)
+	r.indexer.enqueue(operation{
+		desc: "traces of course " + course.ID.String(),
+		apply: func(ctx context.Context, c *Client) error {
+			return c.updateWhere(ctx, TracesIndex, "course_id", course.ID.String(), fields)
+		},
+	})
+}
+
+func (r *Repository) DeleteCourseByID(ctx context.Context, courseID uuid.UUID) error {
+	err := r.Repository.DeleteCourseByID(ctx, courseID)
+	if err == nil {
+		r.indexer.enqueue(operation{
+			desc: "deletion of course " + courseID.String(),
+			apply: func(ctx context.Context, c *Client) error {
+				// Courses with traces can't be deleted, so there are none to remove
+				return c.delete(ctx, CoursesIndex, courseID.String())
+			},
+		})
+	}
+	return err
+}
+
+func (r *Repository) InsertTrace(ctx context.Context, t repository.NewTrace) (*model.Trace, error) {
+	trace, err := r.Repository.InsertTrace(ctx, t)
+	if err == nil {
+		r.putTrace(ctx, t.CourseID, trace)
+	}
+	return trace, err
+}
+
+func (r *Repository) InsertTraceWithEvent(ctx context.Context, t repository.NewTrace, topic string, payload []byte) (*model.Trace, error) {
+	trace, err := r.Repository.InsertTraceWithEvent(ctx, t, topic, payload)
+	if err == nil {
+		r.putTrace(ctx, t.CourseID, trace)
+	}
+	return trace, err
+}
+
+func (r *Repository) DeleteTraceByID(ctx context.Context, courseID, traceID uuid.UUID) error {
+	err := r.Repository.DeleteTraceByID(ctx, courseID, traceID)
+	if err == nil {
+		r.indexer.enqueue(operation{
+			desc: "deletion of trace " + traceID.String(),
+			apply: func(ctx context.Context, c *Client) error {
+				return c.delete(ctx, TracesIndex, traceID.String())
+			},
+		})
+	}
+	return err
+}
+
+func (r *Repository) UpdateTraceStatus(ctx context.Context, courseID, traceID uuid.UUID, req model.UpdateTraceStatusRequest) (*model.Trace, error) {
+	trace, err := r.Repository.UpdateTraceStatus(ctx, courseID, traceID, req)
+	if err == nil {
+		r.updateTrace(trace.ID, map[string]any{"status": trace.Status, "date_updated": trace.DateUpdated})
+	}
+	return trace, err
+}
+
+func (r *Repository) UpdateTraceEmbedding(ctx context.Context, courseID, traceID uuid.UUID, req model.UpdateTraceRequest) (*model.Trace, error) {
+	trace, err := r.Repository.UpdateTraceEmbedding(ctx, courseID, traceID, req)
+	if err == nil {
+		r.updateTrace(trace.ID, map[string]any{"status": trace.Status, "date_updated": trace.DateUpdated})
+	}
+	return trace, err
+}
+
+func (r *Repository) SaveTraceContent(ctx context.Context, traceID uuid.UUID, content model.TraceContent) error {
+	err := r.Repository.SaveTraceContent(ctx, traceID, content)
+	if err == nil {
+		r.updateTrace(traceID, map[string]any{"content": content.Text})
+	}
+	return err
+}
+
+func (r *Repository) MarkTraceFailed(ctx context.Context, traceID uuid.UUID) error {
+	err := r.Repository.MarkTraceFailed(ctx, traceID)
+	if err == nil {
+		r.updateTrace(traceID, map[string]any{"status": "failed", "date_updated": time.Now().UTC()})
+	}
+	return err
+}
+
+func (r *Repository) putCourse(tenantID uuid.UUID, course *model.Course) {
+	doc := newCourseDocument(tenantID, course)
+	r.indexer.enqueue(operation{
+		desc: "course " + course.ID.String(),
+		apply: func(ctx context.Context, c *Client) error {
+			return c.put(ctx, CoursesIndex, doc.ID.String(), doc)
+		},
+	})
+}
+
+// putTrace indexes a new trace along with its course's catalog fields
+func (r *Repository) putTrace(ctx context.Context, courseID uuid.UUID, trace *model.Trace) {
+	course, err := r.Repository.GetCourseByID(ctx, courseID)
+	if err != nil {
+		log.Printf("Failed to get course %s to index trace %s: %v", courseID, trace.ID, err)
+		return
+	}
+	doc := newTraceDocument(tenant.ID(ctx), course, trace)
+	r.indexer.enqueue(operation{
+		desc: "trace " + trace.ID.String(),
+		apply: func(ctx context.Context, c *Client) error {
+			return c.put(ctx, TracesIndex, doc.ID.String(), doc)
+		},
+	})
+}
+
+func (r *Repository) updateTrace(traceID uuid.UUID, fields map[string]any) {
+	r.indexer.enqueue(operation{
+		desc: "trace " + traceID.String(),
+		apply: func(ctx context.Context, c *Client) error {
+			return c.update(ctx, TracesIndex, traceID.String(), fields)
+		},
+	})
+}
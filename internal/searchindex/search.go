@@ -0,0 +1,157 @@
+// internal/searchindex/search.go
+package searchindex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// highlightTag wraps matched words in highlights, as keyword search does
+const highlightTag = "**"
+
+// facetSize caps the values returned per facet
+const facetSize = 20
+
+// Query is a search of one index within a tenant. Empty Text matches every
+// document; each non-empty filter must match exactly.
+type Query struct {
+	Index    string
+	TenantID uuid.UUID
+	Text     string
+	Filters  map[string]string
+	Limit    int
+	Offset   int
+}
+
+// Result is a page of matching documents with facet counts over all matches
+type Result struct {
+	Total  int                `json:"total"`
+	Hits   []Hit              `json:"hits"`
+	Facets map[string][]Facet `json:"facets"`
+}
+
+// Hit is one matching document. Highlight holds fragments of the matched
+// text fields, keyed by field.
+type Hit struct {
+	ID        string              `json:"id"`
+	Score     float64             `json:"score"`
+	Document  json.RawMessage     `json:"document"`
+	Highlight map[string][]string `json:"highlight"`
+}
+
+// Facet is how many matches have one value of a field
+type Facet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Fields of each index that can be searched, filtered and faceted on
+var (
+	searchFields = map[string][]string{
+		CoursesIndex: {"name^2", "subject_code"},
+		TracesIndex:  {"file_name^2", "course_name", "content"},
+	}
+	highlightFields = map[string][]string{
+		CoursesIndex: {"name"},
+		TracesIndex:  {"file_name", "course_name", "content"},
+	}
+	filterFields = map[string][]string{
+		CoursesIndex: {"subject_code", "semester_term", "semester_year", "instructor_id"},
+		TracesIndex:  {"subject_code", "semester_term", "semester_year", "instructor_id", "course_id", "status"},
+	}
+	facetFields = map[string][]string{
+		CoursesIndex: {"subject_code", "semester_term", "semester_year"},
+		TracesIndex:  {"subject_code", "semester_term", "semester_year", "status"},
+	}
+)
+
+type searchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			ID        string              `json:"_id"`
+			Score     *float64            `json:"_score"`
+			Source    json.RawMessage     `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]struct {
+		Buckets []struct {
+			Key      any `json:"key"`
+			DocCount int `json:"doc_count"`
+		} `json:"buckets"`
+	} `json:"aggregations"`
+}
+
+// Search runs q against its index. Results never cross tenants.
+func (c *Client) Search(ctx context.Context, q Query) (*Result, error) {
+	filters := []any{map[string]any{"term": map[string]any{"tenant_id": q.TenantID.String()}}}
+	for _, field := range filterFields[q.Index] {
+		if value := q.Filters[field]; value != "" {
+			filters = append(filters, map[string]any{"term": map[string]any{field: value}})
+		}
+	}
+	match := map[string]any{"match_all": map[string]any{}}
+	if q.Text != "" {
+		match = map[string]any{"multi_match": map[string]any{"query": q.Text, "fields": searchFields[q.Index]}}
+	}
+
+	highlights := map[string]any{}
+	for _, field := range highlightFields[q.Index] {
+		highlights[field] = map[string]any{}
+	}
+	aggs := map[string]any{}
+	for _, field := range facetFields[q.Index] {
+		aggs[field] = map[string]any{"terms": map[string]any{"field": field, "size": facetSize}}
+	}
+
+	body := map[string]any{
+		"from":             q.Offset,
+		"size":             q.Limit,
+		"track_total_hits": true,
+		"query":            map[string]any{"bool": map[string]any{"must": match, "filter": filters}},
+		"highlight": map[string]any{
+			"pre_tags":  []string{highlightTag},
+			"post_tags": []string{highlightTag},
+			"fields":    highlights,
+		},
+		"aggs": aggs,
+		// Content can be long, so hits carry the highlighted fragments instead
+		"_source": map[string]any{"excludes": []string{"content", "tenant_id"}},
+	}
+
+	var resp searchResponse
+	if err := c.do(ctx, http.MethodPost, "/"+c.index(q.Index)+"/_search", body, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Total:  resp.Hits.Total.Value,
+		Hits:   make([]Hit, 0, len(resp.Hits.Hits)),
+		Facets: make(map[string][]Facet, len(resp.Aggregations)),
+	}
+	for _, hit := range resp.Hits.Hits {
+		h := Hit{ID: hit.ID, Document: hit.Source, Highlight: hit.Highlight}
+		if hit.Score != nil {
+			h.Score = *hit.Score
+		}
+		if h.Highlight == nil {
+			h.Highlight = map[string][]string{}
+		}
+		result.Hits = append(result.Hits, h)
+	}
+	for _, field := range facetFields[q.Index] {
+		facets := []Facet{}
+		for _, bucket := range resp.Aggregations[field].Buckets {
+			facets = append(facets, Facet{Value: fmt.Sprint(bucket.Key), Count: bucket.DocCount})
+		}
+		result.Facets[field] = facets
+	}
+	return result, nil
+}
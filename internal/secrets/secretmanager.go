@@ -0,0 +1,58 @@
+// internal/secrets/secretmanager.go
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/option"
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// SecretManager reads secrets from GCP Secret Manager. A reference is
+// gcpsm://projects/<project>/secrets/<name>, optionally followed by
+// /versions/<version>; without a version the latest one is read.
+type SecretManager struct {
+	credentialsFile string
+
+	once    sync.Once
+	service *secretmanager.Service
+	err     error
+}
+
+// NewSecretManager uses the credentials file when set and Application
+// Default Credentials otherwise, as the GCS client does
+func NewSecretManager(credentialsFile string) *SecretManager {
+	return &SecretManager{credentialsFile: credentialsFile}
+}
+
+func (s *SecretManager) Fetch(ctx context.Context, ref string) (string, error) {
+	// The client is only created once a gcpsm:// reference is used
+	s.once.Do(func() {
+		var opts []option.ClientOption
+		if s.credentialsFile != "" {
+			opts = append(opts, option.WithCredentialsFile(s.credentialsFile))
+		}
+		s.service, s.err = secretmanager.NewService(context.Background(), opts...)
+	})
+	if s.err != nil {
+		return "", fmt.Errorf("failed to create Secret Manager client: %w", s.err)
+	}
+
+	name := strings.TrimPrefix(ref, "gcpsm://")
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	resp, err := s.service.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to access %s: %w", name, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,64 @@
+// internal/secrets/secrets.go
+package secrets
+
+import (
+	"api-server/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Fetcher reads the secret a reference points at
+type Fetcher interface {
+	Fetch(ctx context.Context, ref string) (string, error)
+}
+
+// Resolver fetches vault:// references from Vault and gcpsm:// references
+// from GCP Secret Manager
+type Resolver struct {
+	vault         Fetcher
+	secretManager Fetcher
+}
+
+func NewResolver(cfg *config.Config) *Resolver {
+	return &Resolver{
+		vault:         NewVault(cfg.VaultAddr, cfg.VaultToken),
+		secretManager: NewSecretManager(cfg.GCSCredentialsFile),
+	}
+}
+
+func (r *Resolver) Fetch(ctx context.Context, ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "vault://"):
+		return r.vault.Fetch(ctx, ref)
+	case strings.HasPrefix(ref, "gcpsm://"):
+		return r.secretManager.Fetch(ctx, ref)
+	default:
+		return "", fmt.Errorf("unsupported secret reference %q", ref)
+	}
+}
+
+// Resolve fetches every secret reference in cfg and stores the values in
+// cfg, reporting all failures together
+func Resolve(ctx context.Context, cfg *config.Config) error {
+	if len(cfg.SecretRefs) == 0 {
+		return nil
+	}
+	resolver := NewResolver(cfg)
+
+	var errs []error
+	for _, name := range config.SecretSettings {
+		ref, ok := cfg.SecretRefs[name]
+		if !ok {
+			continue
+		}
+		value, err := resolver.Fetch(ctx, ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		cfg.SetSecret(name, value)
+	}
+	return errors.Join(errs...)
+}
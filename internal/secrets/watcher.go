@@ -0,0 +1,132 @@
+// internal/secrets/watcher.go
+package secrets
+
+import (
+	"api-server/internal/config"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Watcher re-fetches the secrets referenced in the config every interval,
+// so long-running components can pick up rotated values without a restart
+type Watcher struct {
+	resolver Fetcher
+	refs     map[string]string
+	interval time.Duration
+
+	mu          sync.RWMutex
+	values      map[string]string
+	subscribers []func(name, value string)
+}
+
+// NewWatcher starts from the values already resolved into cfg by Resolve
+func NewWatcher(cfg *config.Config) *Watcher {
+	values := map[string]string{}
+	for name := range cfg.SecretRefs {
+		values[name] = cfg.Secret(name)
+	}
+	return &Watcher{
+		resolver: NewResolver(cfg),
+		refs:     cfg.SecretRefs,
+		interval: cfg.SecretsRefreshInterval,
+		values:   values,
+	}
+}
+
+// Watching reports whether the named setting came from a secret reference
+func (w *Watcher) Watching(name string) bool {
+	_, ok := w.refs[name]
+	return ok
+}
+
+// Value returns the latest value of the named secret setting
+func (w *Watcher) Value(name string) string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.values[name]
+}
+
+// OnChange registers fn to be called with each secret whose value changes
+func (w *Watcher) OnChange(fn func(name, value string)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Refresh fetches every secret now. A secret that fails to fetch keeps its
+// previous value.
+func (w *Watcher) Refresh(ctx context.Context) {
+	for name, ref := range w.refs {
+		if _, err := w.fetch(ctx, name, ref); err != nil {
+			log.Printf("Failed to refresh secret %s: %v", name, err)
+		}
+	}
+}
+
+// Secret returns a handle on one watched secret, for components that need to
+// re-fetch it on demand, e.g. after the database rejects a password
+func (w *Watcher) Secret(name string) *Secret {
+	return &Secret{watcher: w, name: name}
+}
+
+func (w *Watcher) fetch(ctx context.Context, name, ref string) (string, error) {
+	value, err := w.resolver.Fetch(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	changed := w.values[name] != value
+	w.values[name] = value
+	subscribers := append([]func(string, string){}, w.subscribers...)
+	w.mu.Unlock()
+
+	if changed {
+		log.Printf("Secret %s changed", name)
+		for _, fn := range subscribers {
+			fn(name, value)
+		}
+	}
+	return value, nil
+}
+
+// Secret is a single watched secret
+type Secret struct {
+	watcher *Watcher
+	name    string
+}
+
+// Value returns the latest fetched value
+func (s *Secret) Value() string {
+	return s.watcher.Value(s.name)
+}
+
+// Refetch fetches the secret now instead of waiting for the next refresh
+func (s *Secret) Refetch(ctx context.Context) (string, error) {
+	ref, ok := s.watcher.refs[s.name]
+	if !ok {
+		return "", fmt.Errorf("%s is not a secret reference", s.name)
+	}
+	return s.watcher.fetch(ctx, s.name, ref)
+}
+
+// Run refreshes the secrets every interval until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	if len(w.refs) == 0 {
+		return
+	}
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Refresh(ctx)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// internal/seed/seed.go
+package seed
+
+import (
+	"api-server/internal/model"
+	"api-server/internal/storage"
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// ErrAlreadySeeded is returned when the fixture users already exist
+var ErrAlreadySeeded = errors.New("seed data is already loaded")
+
+// Fixtures is the demo data set. Instructors, courses and traces refer to
+// each other by ref and to their creating user by username.
+type Fixtures struct {
+	Users       []model.CreateUserRequest `json:"users"`
+	Instructors []struct {
+		Ref       string `json:"ref"`
+		Name      string `json:"name"`
+		Email     string `json:"email"`
+		CreatedBy string `json:"created_by"`
+	} `json:"instructors"`
+	Courses []struct {
+		model.CreateCourseRequest
+		Ref        string `json:"ref"`
+		Instructor string `json:"instructor"`
+		CreatedBy  string `json:"created_by"`
+	} `json:"courses"`
+	Traces []struct {
+		Course     string `json:"course"`
+		UploadedBy string `json:"uploaded_by"`
+	} `json:"traces"`
+}
+
+// Result counts what Load created
+type Result struct {
+	Users       int
+	Instructors int
+	Courses     int
+	Traces      int
+}
+
+// LoadFixtures parses the embedded fixture set
+func LoadFixtures() (*Fixtures, error) {
+	raw, err := fixtures.ReadFile("fixtures/seed.json")
+	if err != nil {
+		return nil, err
+	}
+	var f Fixtures
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("invalid seed fixtures: %w", err)
+	}
+	return &f, nil
+}
+
+// Load inserts the fixtures in a single transaction, so a failed seed leaves
+// nothing behind. Example traces are only created when store is non-nil;
+// each gets a placeholder PDF uploaded so its bucket_url resolves. All of it
+// belongs to the default tenant.
+func Load(ctx context.Context, db *pgxpool.Pool, store storage.Storage, f *Fixtures) (*Result, error) {
+	var samplePDF []byte
+	if store != nil && len(f.Traces) > 0 {
+		var err error
+		if samplePDF, err = fixtures.ReadFile("fixtures/sample.pdf"); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{}
+	err := model.WithTx(ctx, db, func(tx model.DBTX) error {
+		users := map[string]uuid.UUID{}
+		for _, req := range f.Users {
+			user, err := model.CreateUser(ctx, tx, model.DefaultTenantID, req)
+			if model.IsUniqueViolation(err, "users_username_key") || model.IsUniqueViolation(err, "users_email_key") {
+				return ErrAlreadySeeded
+			}
+			if err != nil {
+				return fmt.Errorf("user %s: %w", req.Username, err)
+			}
+			users[user.Username] = user.ID
+			result.Users++
+		}
+
+		instructors := map[string]*model.Instructor{}
+		for _, fixture := range f.Instructors {
+			userID, err := lookupUser(users, fixture.CreatedBy)
+			if err != nil {
+				return err
+			}
+			req := model.CreateInstructorRequest{Name: fixture.Name, Email: fixture.Email}
+			instructor, err := model.CreateInstructor(ctx, tx, model.DefaultTenantID, req, userID)
+			if err != nil {
+				return fmt.Errorf("instructor %s: %w", fixture.Ref, err)
+			}
+			instructors[fixture.Ref] = instructor
+			result.Instructors++
+		}
+
+		courses := map[string]*model.Course{}
+		for _, fixture := range f.Courses {
+			userID, err := lookupUser(users, fixture.CreatedBy)
+			if err != nil {
+				return err
+			}
+			instructor, ok := instructors[fixture.Instructor]
+			if !ok {
+				return fmt.Errorf("course %s refers to unknown instructor %q", fixture.Ref, fixture.Instructor)
+			}
+			req := fixture.CreateCourseRequest
+			req.InstructorID = instructor.ID
+			course, err := model.CreateCourse(ctx, tx, model.DefaultTenantID, req, userID)
+			if err != nil {
+				return fmt.Errorf("course %s: %w", fixture.Ref, err)
+			}
+			if err := model.AppendEvents(ctx, tx, model.DefaultTenantID, []model.Event{model.CourseCreatedEvent(course, userID)}); err != nil {
+				return err
+			}
+			courses[fixture.Ref] = course
+			result.Courses++
+		}
+		// Seeded courses and traces count against the tenant's usage and quotas
+		if err := model.ChargeTenantUsage(ctx, tx, model.DefaultTenantID, model.UsageDelta{Courses: int64(result.Courses)}); err != nil {
+			return err
+		}
+
+		if store == nil {
+			return nil
+		}
+		for _, fixture := range f.Traces {
+			userID, err := lookupUser(users, fixture.UploadedBy)
+			if err != nil {
+				return err
+			}
+			course, ok := courses[fixture.Course]
+			if !ok {
+				return fmt.Errorf("trace refers to unknown course %q", fixture.Course)
+			}
+
+			fileName := fmt.Sprintf("seed_%s_%d_%s_%d.pdf", course.SubjectCode, course.CourseID, course.SemesterTerm, course.SemesterYear)
+			bucketURL, err := store.Upload(ctx, fileName, bytes.NewReader(samplePDF))
+			if err != nil {
+				return fmt.Errorf("failed to upload %s: %w", fileName, err)
+			}
+			size := int64(len(samplePDF))
+			if err := model.ChargeTenantUsage(ctx, tx, model.DefaultTenantID, model.UsageDelta{StorageBytes: size, Uploads: 1}); err != nil {
+				return err
+			}
+			trace, err := model.InsertTrace(ctx, tx, model.DefaultTenantID, uuid.New(), userID, course.InstructorID, "uploaded", course.ID, nil, fileName, bucketURL, model.ContentTypePDF, size, model.VisibilityPrivate, nil, nil)
+			if err != nil {
+				return fmt.Errorf("trace for %s: %w", fixture.Course, err)
+			}
+			if err := model.AppendEvents(ctx, tx, model.DefaultTenantID, []model.Event{model.TraceUploadedEvent(course.ID, trace)}); err != nil {
+				return err
+			}
+			result.Traces++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func lookupUser(users map[string]uuid.UUID, username string) (uuid.UUID, error) {
+	id, ok := users[username]
+	if !ok {
+		return uuid.Nil, fmt.Errorf("seed fixtures refer to unknown user %q", username)
+	}
+	return id, nil
+}
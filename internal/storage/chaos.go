@@ -0,0 +1,79 @@
+// internal/storage/chaos.go
+package storage
+
+import (
+	"api-server/internal/chaos"
+	"context"
+	"io"
+)
+
+// Chaos injects the storage faults configured on an admin endpoint into
+// every call, for testing retries and the circuit breaker in development
+type Chaos struct {
+	next   Storage
+	faults *chaos.Injector
+}
+
+func NewChaos(next Storage, faults *chaos.Injector) *Chaos {
+	return &Chaos{next: next, faults: faults}
+}
+
+func (s *Chaos) Connect(ctx context.Context) error {
+	if err := s.faults.Inject(ctx, chaos.DependencyStorage); err != nil {
+		return err
+	}
+	return s.next.Connect(ctx)
+}
+
+func (s *Chaos) Ping(ctx context.Context) error {
+	if err := s.faults.Inject(ctx, chaos.DependencyStorage); err != nil {
+		return err
+	}
+	return s.next.Ping(ctx)
+}
+
+func (s *Chaos) Upload(ctx context.Context, filename string, file io.Reader) (string, error) {
+	if err := s.faults.Inject(ctx, chaos.DependencyStorage); err != nil {
+		return "", err
+	}
+	return s.next.Upload(ctx, filename, file)
+}
+
+func (s *Chaos) Archive(ctx context.Context, filename string) (string, error) {
+	if err := s.faults.Inject(ctx, chaos.DependencyStorage); err != nil {
+		return "", err
+	}
+	return s.next.Archive(ctx, filename)
+}
+
+func (s *Chaos) Restore(ctx context.Context, filename string) (string, error) {
+	if err := s.faults.Inject(ctx, chaos.DependencyStorage); err != nil {
+		return "", err
+	}
+	return s.next.Restore(ctx, filename)
+}
+
+func (s *Chaos) List(ctx context.Context) ([]Object, error) {
+	if err := s.faults.Inject(ctx, chaos.DependencyStorage); err != nil {
+		return nil, err
+	}
+	return s.next.List(ctx)
+}
+
+func (s *Chaos) Download(ctx context.Context, filename string) (io.ReadCloser, error) {
+	if err := s.faults.Inject(ctx, chaos.DependencyStorage); err != nil {
+		return nil, err
+	}
+	return s.next.Download(ctx, filename)
+}
+
+func (s *Chaos) Delete(ctx context.Context, filename string) error {
+	if err := s.faults.Inject(ctx, chaos.DependencyStorage); err != nil {
+		return err
+	}
+	return s.next.Delete(ctx, filename)
+}
+
+func (s *Chaos) Close() error {
+	return s.next.Close()
+}
@@ -0,0 +1,327 @@
+// internal/storage/gcs.go
+package storage
+
+import (
+	"api-server/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// Storage classes used by the trace lifecycle
+const (
+	ClassStandard = "STANDARD"
+	ClassColdline = "COLDLINE"
+)
+
+// ErrUnavailable is returned when the storage client cannot be initialized
+var ErrUnavailable = errors.New("storage unavailable")
+
+// GCS wraps a lazily created Cloud Storage client. The client is built on first
+// use rather than at startup so a GCS outage only affects storage endpoints.
+type GCS struct {
+	cfg           *config.Config
+	bucketName    string
+	archiveBucket string
+	publicHost    string
+
+	mu          sync.Mutex
+	client      *gcs.Client
+	lastErr     error
+	lastAttempt time.Time
+}
+
+func NewGCS(cfg *config.Config) *GCS {
+	publicHost := "https://storage.googleapis.com"
+	if cfg.StorageEmulatorHost != "" {
+		publicHost = emulatorURL(cfg.StorageEmulatorHost)
+	}
+
+	return &GCS{
+		cfg:           cfg,
+		bucketName:    cfg.GCSBucketName,
+		archiveBucket: cfg.GCSArchiveBucketName,
+		publicHost:    publicHost,
+	}
+}
+
+// Connect initializes the client ahead of the first request
+func (s *GCS) Connect(ctx context.Context) error {
+	_, err := s.getClient(ctx)
+	return err
+}
+
+// Ping verifies the client is initialized and the primary bucket is reachable
+func (s *GCS) Ping(ctx context.Context) error {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := client.Bucket(s.bucketName).Attrs(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return nil
+}
+
+// getClient returns the shared client, creating it with retries if needed.
+// After a failed initialization further attempts are throttled by the retry
+// cooldown so a storage outage doesn't stall every request.
+func (s *GCS) getClient(ctx context.Context) (*gcs.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		return s.client, nil
+	}
+	if s.lastErr != nil && time.Since(s.lastAttempt) < s.cfg.GCSInitCooldown {
+		return nil, s.lastErr
+	}
+
+	var err error
+	backoff := s.cfg.GCSInitBackoff
+	for attempt := 1; attempt <= s.cfg.GCSInitAttempts; attempt++ {
+		var client *gcs.Client
+		client, err = s.newClient(ctx)
+		if err == nil {
+			s.client = client
+			s.lastErr = nil
+			return client, nil
+		}
+		log.Printf("GCS client initialization attempt %d/%d failed: %v", attempt, s.cfg.GCSInitAttempts, err)
+
+		if attempt == s.cfg.GCSInitAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			// The caller gave up, so don't hold this failure against later requests
+			return nil, fmt.Errorf("%w: %v", ErrUnavailable, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	s.lastAttempt = time.Now()
+	s.lastErr = fmt.Errorf("%w: %v", ErrUnavailable, err)
+	return nil, s.lastErr
+}
+
+func (s *GCS) newClient(ctx context.Context) (*gcs.Client, error) {
+	var client *gcs.Client
+	var err error
+
+	log.Printf("GCSCredentialsFile: %q", s.cfg.GCSCredentialsFile)
+	if s.cfg.StorageEmulatorHost != "" {
+		// The client library reads STORAGE_EMULATOR_HOST itself and skips authentication
+		log.Printf("Using storage emulator at %s", s.cfg.StorageEmulatorHost)
+		client, err = gcs.NewClient(ctx)
+	} else if s.cfg.GCSCredentialsFile != "" {
+		client, err = gcs.NewClient(ctx, option.WithCredentialsFile(s.cfg.GCSCredentialsFile))
+	} else {
+		log.Println("Using Application Default Credentials")
+		client, err = gcs.NewClient(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Emulators start empty, so create the buckets we need up front
+	if s.cfg.StorageEmulatorHost != "" {
+		if err := s.ensureBuckets(ctx, client); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+func (s *GCS) ensureBuckets(ctx context.Context, client *gcs.Client) error {
+	for _, name := range []string{s.bucketName, s.archiveBucket} {
+		if name == "" {
+			continue
+		}
+		bucket := client.Bucket(name)
+		if _, err := bucket.Attrs(ctx); err == nil {
+			continue
+		} else if !errors.Is(err, gcs.ErrBucketNotExist) {
+			return err
+		}
+		if err := bucket.Create(ctx, "local-dev", nil); err != nil {
+			return fmt.Errorf("failed to create emulator bucket %s: %w", name, err)
+		}
+		log.Printf("Created emulator bucket %s", name)
+	}
+	return nil
+}
+
+func (s *GCS) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	return s.client.Close()
+}
+
+// Upload writes the file to the primary bucket and returns its public URL
+func (s *GCS) Upload(ctx context.Context, filename string, file io.Reader) (string, error) {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	object := client.Bucket(s.bucketName).Object(filename)
+
+	w := object.NewWriter(ctx)
+	if _, err := io.Copy(w, file); err != nil {
+		w.Close()
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	attrs, err := object.Attrs(ctx)
+	if err != nil {
+		return "", err
+	}
+	return s.objectURL(s.bucketName, attrs.Name), nil
+}
+
+// Archive moves an object to coldline storage. When an archive bucket is
+// configured the object is copied there and removed from the primary bucket,
+// otherwise its storage class is rewritten in place.
+func (s *GCS) Archive(ctx context.Context, filename string) (string, error) {
+	dstBucket := s.bucketName
+	if s.archiveBucket != "" {
+		dstBucket = s.archiveBucket
+	}
+	return s.move(ctx, s.bucketName, dstBucket, filename, ClassColdline)
+}
+
+// Restore brings an archived object back to standard storage in the primary bucket
+func (s *GCS) Restore(ctx context.Context, filename string) (string, error) {
+	srcBucket := s.bucketName
+	if s.archiveBucket != "" {
+		srcBucket = s.archiveBucket
+	}
+	return s.move(ctx, srcBucket, s.bucketName, filename, ClassStandard)
+}
+
+// List returns every object in the primary bucket and, when configured, the archive bucket
+func (s *GCS) List(ctx context.Context) ([]Object, error) {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	for _, bucket := range s.buckets() {
+		it := client.Bucket(bucket).Objects(ctx, nil)
+		for {
+			attrs, err := it.Next()
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to list bucket %s: %w", bucket, err)
+			}
+			objects = append(objects, Object{
+				Name:         attrs.Name,
+				URL:          s.objectURL(bucket, attrs.Name),
+				StorageClass: attrs.StorageClass,
+			})
+		}
+	}
+	return objects, nil
+}
+
+// Download opens an object from the primary bucket, falling back to the
+// archive bucket for archived objects
+func (s *GCS) Download(ctx context.Context, filename string) (io.ReadCloser, error) {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range s.buckets() {
+		r, err := client.Bucket(bucket).Object(filename).NewReader(ctx)
+		if errors.Is(err, gcs.ErrObjectNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, filename)
+}
+
+// Delete removes an object from the primary and archive buckets
+func (s *GCS) Delete(ctx context.Context, filename string) error {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	for _, bucket := range s.buckets() {
+		err := client.Bucket(bucket).Object(filename).Delete(ctx)
+		if err != nil && !errors.Is(err, gcs.ErrObjectNotExist) {
+			return fmt.Errorf("failed to delete %s from bucket %s: %w", filename, bucket, err)
+		}
+	}
+	return nil
+}
+
+// buckets is the primary bucket followed by a separate archive bucket, if any
+func (s *GCS) buckets() []string {
+	buckets := []string{s.bucketName}
+	if s.archiveBucket != "" && s.archiveBucket != s.bucketName {
+		buckets = append(buckets, s.archiveBucket)
+	}
+	return buckets
+}
+
+func (s *GCS) move(ctx context.Context, srcBucket, dstBucket, filename, storageClass string) (string, error) {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	src := client.Bucket(srcBucket).Object(filename)
+	dst := client.Bucket(dstBucket).Object(filename)
+
+	copier := dst.CopierFrom(src)
+	copier.StorageClass = storageClass
+	attrs, err := copier.Run(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to rewrite %s to %s: %w", filename, storageClass, err)
+	}
+
+	// Remove the source object once the copy lives in a different bucket
+	if srcBucket != dstBucket {
+		if err := src.Delete(ctx); err != nil {
+			log.Printf("Failed to delete %s from bucket %s after move: %v", filename, srcBucket, err)
+		}
+	}
+
+	return s.objectURL(dstBucket, attrs.Name), nil
+}
+
+func (s *GCS) objectURL(bucket, name string) string {
+	return fmt.Sprintf("%s/%s/%s", s.publicHost, bucket, name)
+}
+
+// emulatorURL adds a scheme to STORAGE_EMULATOR_HOST values such as "localhost:4443"
+func emulatorURL(host string) string {
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		return strings.TrimSuffix(host, "/")
+	}
+	return "http://" + strings.TrimSuffix(host, "/")
+}
@@ -0,0 +1,91 @@
+// internal/storage/metrics.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/googleapi"
+)
+
+// Metrics counts storage operations by operation (upload, download, and so
+// on). A nil *Metrics records nothing.
+type Metrics struct {
+	operations *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	uploadSize prometheus.Histogram
+}
+
+// NewMetrics registers the storage metrics with reg
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gcs_operations_total",
+			Help: "Storage operations, successful or not.",
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gcs_operation_errors_total",
+			Help: "Storage operations that failed after every retry, by HTTP status or error kind.",
+		}, []string{"operation", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gcs_operation_duration_seconds",
+			Help:    "Time taken by storage operations, including retries.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"operation"}),
+		uploadSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "gcs_upload_size_bytes",
+			Help: "Size of uploaded objects.",
+			// 64KiB to 1GiB
+			Buckets: prometheus.ExponentialBuckets(64<<10, 4, 8),
+		}),
+	}
+	for _, c := range []prometheus.Collector{m.operations, m.errors, m.duration, m.uploadSize} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// observe records an operation that started at start and ended with err
+func (m *Metrics) observe(operation string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.operations.WithLabelValues(operation).Inc()
+	m.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(operation, errorCode(err)).Inc()
+	}
+}
+
+func (m *Metrics) uploaded(size int64) {
+	if m == nil {
+		return
+	}
+	m.uploadSize.Observe(float64(size))
+}
+
+// errorCode labels err with its GCS HTTP status where there is one
+func errorCode(err error) string {
+	var apiErr *googleapi.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return strconv.Itoa(apiErr.Code)
+	case errors.Is(err, ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, ErrNotFound), errors.Is(err, gcs.ErrObjectNotExist), errors.Is(err, gcs.ErrBucketNotExist):
+		return "not_found"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,211 @@
+// internal/storage/pool.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrPoolClosed is returned for operations submitted after Close
+var ErrPoolClosed = errors.New("storage pool closed")
+
+// Pool runs uploads, archives, restores, listings and deletes on a fixed
+// number of workers, so a batch of them, or a burst of uploads, can't open
+// unbounded connections to GCS. Each operation gets taskTimeout, counted
+// from when a worker picks it up, and Close cancels those in progress.
+// Download isn't pooled: the reader it returns outlives the task.
+type Pool struct {
+	next        Storage
+	taskTimeout time.Duration
+	metrics     *PoolMetrics
+
+	tasks    chan poolTask
+	shutdown context.Context
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
+}
+
+type poolTask struct {
+	ctx      context.Context
+	queuedAt time.Time
+	fn       func(ctx context.Context) error
+	done     chan error
+}
+
+// NewPool starts size workers in front of next; metrics may be nil
+func NewPool(next Storage, size int, taskTimeout time.Duration, metrics *PoolMetrics) *Pool {
+	shutdown, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		next:        next,
+		taskTimeout: taskTimeout,
+		metrics:     metrics,
+		tasks:       make(chan poolTask),
+		shutdown:    shutdown,
+		cancel:      cancel,
+	}
+	metrics.sized(size)
+	p.workers.Add(size)
+	for range size {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+	for {
+		select {
+		case <-p.shutdown.Done():
+			return
+		case t := <-p.tasks:
+			p.metrics.started(t.queuedAt)
+			ctx, cancel := context.WithTimeout(t.ctx, p.taskTimeout)
+			stop := context.AfterFunc(p.shutdown, cancel)
+			t.done <- t.fn(ctx)
+			stop()
+			cancel()
+			p.metrics.finished()
+		}
+	}
+}
+
+// run waits for a worker to run fn and returns its error. Once a worker has
+// picked fn up, run waits for it to finish even if ctx is cancelled, since
+// fn may still be reading the caller's upload body.
+func (p *Pool) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	t := poolTask{ctx: ctx, queuedAt: time.Now(), fn: fn, done: make(chan error, 1)}
+	select {
+	case p.tasks <- t:
+		return <-t.done
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.shutdown.Done():
+		return ErrPoolClosed
+	}
+}
+
+func (p *Pool) Connect(ctx context.Context) error {
+	return p.next.Connect(ctx)
+}
+
+func (p *Pool) Ping(ctx context.Context) error {
+	return p.next.Ping(ctx)
+}
+
+func (p *Pool) Upload(ctx context.Context, filename string, file io.Reader) (string, error) {
+	var url string
+	err := p.run(ctx, func(ctx context.Context) error {
+		var err error
+		url, err = p.next.Upload(ctx, filename, file)
+		return err
+	})
+	return url, err
+}
+
+func (p *Pool) Archive(ctx context.Context, filename string) (string, error) {
+	var url string
+	err := p.run(ctx, func(ctx context.Context) error {
+		var err error
+		url, err = p.next.Archive(ctx, filename)
+		return err
+	})
+	return url, err
+}
+
+func (p *Pool) Restore(ctx context.Context, filename string) (string, error) {
+	var url string
+	err := p.run(ctx, func(ctx context.Context) error {
+		var err error
+		url, err = p.next.Restore(ctx, filename)
+		return err
+	})
+	return url, err
+}
+
+func (p *Pool) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	err := p.run(ctx, func(ctx context.Context) error {
+		var err error
+		objects, err = p.next.List(ctx)
+		return err
+	})
+	return objects, err
+}
+
+func (p *Pool) Download(ctx context.Context, filename string) (io.ReadCloser, error) {
+	return p.next.Download(ctx, filename)
+}
+
+func (p *Pool) Delete(ctx context.Context, filename string) error {
+	return p.run(ctx, func(ctx context.Context) error {
+		return p.next.Delete(ctx, filename)
+	})
+}
+
+// Close cancels the operations in progress, waits for the workers to stop
+// and closes next
+func (p *Pool) Close() error {
+	p.cancel()
+	p.workers.Wait()
+	return p.next.Close()
+}
+
+// PoolMetrics records how long storage operations wait for a worker and how
+// many workers are busy. A nil *PoolMetrics records nothing.
+type PoolMetrics struct {
+	wait    prometheus.Histogram
+	busy    prometheus.Gauge
+	workers prometheus.Gauge
+}
+
+// NewPoolMetrics registers the storage pool metrics with reg
+func NewPoolMetrics(reg prometheus.Registerer) (*PoolMetrics, error) {
+	m := &PoolMetrics{
+		wait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "storage_pool_queue_wait_seconds",
+			Help:    "Time storage operations waited for a free worker.",
+			Buckets: []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}),
+		busy: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_pool_busy_workers",
+			Help: "Storage pool workers running an operation.",
+		}),
+		workers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_pool_workers",
+			Help: "Storage pool workers in all.",
+		}),
+	}
+	for _, c := range []prometheus.Collector{m.wait, m.busy, m.workers} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *PoolMetrics) sized(size int) {
+	if m == nil {
+		return
+	}
+	m.workers.Set(float64(size))
+}
+
+func (m *PoolMetrics) started(queuedAt time.Time) {
+	if m == nil {
+		return
+	}
+	m.wait.Observe(time.Since(queuedAt).Seconds())
+	m.busy.Inc()
+}
+
+func (m *PoolMetrics) finished() {
+	if m == nil {
+		return
+	}
+	m.busy.Dec()
+}
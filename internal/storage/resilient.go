@@ -0,0 +1,172 @@
+// internal/storage/resilient.go
+package storage
+
+import (
+	"api-server/internal/resilience"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// Resilient retries storage operations with backoff behind a circuit breaker.
+// While the breaker is open calls fail immediately with ErrUnavailable.
+type Resilient struct {
+	next    Storage
+	policy  resilience.RetryPolicy
+	breaker *resilience.Breaker
+	metrics *Metrics
+}
+
+// NewResilient wraps next; metrics may be nil
+func NewResilient(next Storage, policy resilience.RetryPolicy, breaker *resilience.Breaker, metrics *Metrics) *Resilient {
+	return &Resilient{next: next, policy: policy, breaker: breaker, metrics: metrics}
+}
+
+func (s *Resilient) Connect(ctx context.Context) error {
+	if s.breaker.State() == resilience.StateOpen {
+		return fmt.Errorf("%w: %v", ErrUnavailable, resilience.ErrBreakerOpen)
+	}
+	return s.next.Connect(ctx)
+}
+
+func (s *Resilient) Ping(ctx context.Context) error {
+	if s.breaker.State() == resilience.StateOpen {
+		return fmt.Errorf("%w: %v", ErrUnavailable, resilience.ErrBreakerOpen)
+	}
+	return s.next.Ping(ctx)
+}
+
+func (s *Resilient) Upload(ctx context.Context, filename string, file io.Reader) (string, error) {
+	// Uploads can only be retried when the body can be rewound
+	policy := s.policy
+	seeker, canSeek := file.(io.Seeker)
+	if !canSeek {
+		policy.Attempts = 1
+	}
+
+	var url string
+	attempt := 0
+	body := &countingReader{r: file}
+	err := s.do(ctx, "upload", policy, func(ctx context.Context) error {
+		if attempt > 0 && canSeek {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return resilience.Permanent(err)
+			}
+		}
+		attempt++
+		body.n = 0
+
+		var err error
+		url, err = s.next.Upload(ctx, filename, body)
+		return err
+	})
+	if err == nil {
+		s.metrics.uploaded(body.n)
+	}
+	return url, err
+}
+
+func (s *Resilient) Archive(ctx context.Context, filename string) (string, error) {
+	var url string
+	err := s.do(ctx, "archive", s.policy, func(ctx context.Context) error {
+		var err error
+		url, err = s.next.Archive(ctx, filename)
+		return err
+	})
+	return url, err
+}
+
+func (s *Resilient) Restore(ctx context.Context, filename string) (string, error) {
+	var url string
+	err := s.do(ctx, "restore", s.policy, func(ctx context.Context) error {
+		var err error
+		url, err = s.next.Restore(ctx, filename)
+		return err
+	})
+	return url, err
+}
+
+func (s *Resilient) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	err := s.do(ctx, "list", s.policy, func(ctx context.Context) error {
+		var err error
+		objects, err = s.next.List(ctx)
+		return err
+	})
+	return objects, err
+}
+
+func (s *Resilient) Download(ctx context.Context, filename string) (io.ReadCloser, error) {
+	var r io.ReadCloser
+	err := s.do(ctx, "download", s.policy, func(ctx context.Context) error {
+		var err error
+		r, err = s.next.Download(ctx, filename)
+		return err
+	})
+	return r, err
+}
+
+func (s *Resilient) Delete(ctx context.Context, filename string) error {
+	return s.do(ctx, "delete", s.policy, func(ctx context.Context) error {
+		return s.next.Delete(ctx, filename)
+	})
+}
+
+func (s *Resilient) Close() error {
+	return s.next.Close()
+}
+
+// do runs fn with retries and records it as operation
+func (s *Resilient) do(ctx context.Context, operation string, policy resilience.RetryPolicy, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := resilience.Retry(ctx, policy, func(ctx context.Context) error {
+		err := s.breaker.Execute(func() error {
+			err := fn(ctx)
+			if err != nil && !isRetryable(err) {
+				return resilience.Permanent(err)
+			}
+			return err
+		})
+		if errors.Is(err, resilience.ErrBreakerOpen) {
+			return resilience.Permanent(err)
+		}
+		return err
+	})
+	if errors.Is(err, resilience.ErrBreakerOpen) {
+		err = fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	s.metrics.observe(operation, start, err)
+	return err
+}
+
+// isRetryable reports whether err looks transient: client errors other than
+// timeouts and throttling are not retried.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrUnavailable) ||
+		errors.Is(err, gcs.ErrObjectNotExist) || errors.Is(err, gcs.ErrBucketNotExist) || errors.Is(err, ErrNotFound) {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code >= 500 || apiErr.Code == http.StatusTooManyRequests || apiErr.Code == http.StatusRequestTimeout
+	}
+	return true
+}
+
+// countingReader counts the bytes an upload reads
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
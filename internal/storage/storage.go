@@ -0,0 +1,33 @@
+// internal/storage/storage.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Download for an object that does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores trace objects and moves them between storage tiers
+type Storage interface {
+	Connect(ctx context.Context) error
+	Ping(ctx context.Context) error
+	Upload(ctx context.Context, filename string, file io.Reader) (string, error)
+	Archive(ctx context.Context, filename string) (string, error)
+	Restore(ctx context.Context, filename string) (string, error)
+	List(ctx context.Context) ([]Object, error)
+	// Download opens a stored object, wherever its tier keeps it
+	Download(ctx context.Context, filename string) (io.ReadCloser, error)
+	// Delete removes a stored object; a missing one is not an error
+	Delete(ctx context.Context, filename string) error
+	Close() error
+}
+
+// Object is a stored trace object as reported by List
+type Object struct {
+	Name         string
+	URL          string
+	StorageClass string
+}
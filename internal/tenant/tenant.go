@@ -0,0 +1,177 @@
+// internal/tenant/tenant.go
+package tenant
+
+import (
+	"api-server/internal/model"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Request headers that select a tenant
+const (
+	APIKeyHeader = "X-API-Key"
+	Header       = "X-Tenant"
+)
+
+// cacheTTL is how long a resolved tenant is reused, which is also how long a
+// change to a tenant can take to reach every request
+const cacheTTL = 30 * time.Second
+
+var (
+	// ErrUnknown is returned for a slug that names no tenant
+	ErrUnknown = errors.New("unknown tenant")
+	// ErrInvalidAPIKey is returned for an API key that matches no tenant
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+// Default is the default tenant as used while multi-tenancy is disabled
+var Default = &model.Tenant{ID: model.DefaultTenantID, Slug: model.DefaultTenantSlug, Name: "Default"}
+
+type key struct{}
+
+// NewContext returns ctx carrying the tenant a request resolved to
+func NewContext(ctx context.Context, t *model.Tenant) context.Context {
+	return context.WithValue(ctx, key{}, t)
+}
+
+// FromContext returns the request's tenant, or nil outside a request
+func FromContext(ctx context.Context) *model.Tenant {
+	t, _ := ctx.Value(key{}).(*model.Tenant)
+	return t
+}
+
+// ID is the tenant queries made with ctx are scoped to. Work outside a
+// request, such as admin commands and seeding, uses the default tenant.
+func ID(ctx context.Context) uuid.UUID {
+	if t := FromContext(ctx); t != nil {
+		return t.ID
+	}
+	return model.DefaultTenantID
+}
+
+// Slug is the slug of ctx's tenant, defaulting like ID
+func Slug(ctx context.Context) string {
+	if t := FromContext(ctx); t != nil {
+		return t.Slug
+	}
+	return model.DefaultTenantSlug
+}
+
+// ObjectName prefixes a storage object name with the bucket prefix of ctx's
+// tenant, if it has one
+func ObjectName(ctx context.Context, name string) string {
+	if t := FromContext(ctx); t != nil && t.BucketPrefix != nil {
+		return path.Join(*t.BucketPrefix, name)
+	}
+	return name
+}
+
+// Lookup finds tenants for a Resolver
+type Lookup interface {
+	GetTenantBySlug(ctx context.Context, slug string) (*model.Tenant, error)
+	GetTenantByAPIKey(ctx context.Context, key string) (*model.Tenant, error)
+}
+
+// Resolver picks a request's tenant from, in order, its API key, the
+// X-Tenant header, or the subdomain of baseDomain it was sent to. Requests
+// with none of these get the default tenant.
+type Resolver struct {
+	lookup     Lookup
+	baseDomain string
+
+	mu    sync.Mutex
+	cache map[string]cachedTenant
+}
+
+type cachedTenant struct {
+	tenant  *model.Tenant
+	expires time.Time
+}
+
+// NewResolver resolves tenants through lookup. An empty baseDomain turns
+// subdomain resolution off.
+func NewResolver(lookup Lookup, baseDomain string) *Resolver {
+	return &Resolver{
+		lookup:     lookup,
+		baseDomain: strings.ToLower(strings.TrimPrefix(baseDomain, ".")),
+		cache:      map[string]cachedTenant{},
+	}
+}
+
+// Resolve returns r's tenant, ErrInvalidAPIKey or ErrUnknown
+func (res *Resolver) Resolve(r *http.Request) (*model.Tenant, error) {
+	ctx := r.Context()
+	if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+		return res.cached("key:"+model.HashAPIKey(apiKey), func() (*model.Tenant, error) {
+			t, err := res.lookup.GetTenantByAPIKey(ctx, apiKey)
+			if errors.Is(err, model.ErrNotFound) {
+				return nil, ErrInvalidAPIKey
+			}
+			return t, err
+		})
+	}
+
+	slug := r.Header.Get(Header)
+	if slug == "" {
+		slug = res.subdomain(r.Host)
+	}
+	if slug == "" {
+		slug = model.DefaultTenantSlug
+	}
+	return res.cached("slug:"+slug, func() (*model.Tenant, error) {
+		t, err := res.lookup.GetTenantBySlug(ctx, slug)
+		if errors.Is(err, model.ErrNotFound) {
+			return nil, ErrUnknown
+		}
+		return t, err
+	})
+}
+
+// subdomain returns the single label host has in front of the base domain
+func (res *Resolver) subdomain(host string) string {
+	if res.baseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, ok := strings.CutSuffix(strings.ToLower(host), "."+res.baseDomain)
+	if !ok || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// cached returns the tenant stored under cacheKey, calling fetch when it is
+// missing or expired. Failures are not cached.
+func (res *Resolver) cached(cacheKey string, fetch func() (*model.Tenant, error)) (*model.Tenant, error) {
+	now := time.Now()
+	res.mu.Lock()
+	entry, ok := res.cache[cacheKey]
+	res.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.tenant, nil
+	}
+
+	t, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	res.mu.Lock()
+	for k, e := range res.cache {
+		if now.After(e.expires) {
+			delete(res.cache, k)
+		}
+	}
+	res.cache[cacheKey] = cachedTenant{tenant: t, expires: now.Add(cacheTTL)}
+	res.mu.Unlock()
+	return t, nil
+}
@@ -0,0 +1,143 @@
+// internal/testutil/containers.go
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/kafka"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Images are pinned so a harness run is reproducible
+const (
+	PostgresImage = "pgvector/pgvector:0.8.0-pg16"
+	KafkaImage    = "confluentinc/confluent-local:7.5.0"
+	FakeGCSImage  = "fsouza/fake-gcs-server:1.50"
+)
+
+// Same credentials as docker-compose.yml
+const (
+	dbName     = "api"
+	dbUser     = "admin"
+	dbPassword = "password"
+)
+
+// skipWithoutDocker skips the test when no container runtime is reachable, so
+// go test ./... still passes on machines without Docker
+func skipWithoutDocker(t testing.TB) {
+	t.Helper()
+	if err := dockerHealth(); err != nil {
+		t.Skipf("Docker is not available, skipping integration test: %v", err)
+	}
+}
+
+func dockerHealth() (err error) {
+	// testcontainers panics, rather than failing, when it finds no Docker host
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		return err
+	}
+	return provider.Health(context.Background())
+}
+
+// StartPostgres runs an empty Postgres and returns its host and mapped port
+func StartPostgres(ctx context.Context, t testing.TB) (host, port string) {
+	t.Helper()
+	container, err := postgres.Run(ctx, PostgresImage,
+		postgres.WithDatabase(dbName),
+		postgres.WithUsername(dbUser),
+		postgres.WithPassword(dbPassword),
+		// The server restarts once after running init scripts
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(time.Minute),
+		),
+	)
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("failed to start Postgres: %v", err)
+	}
+	return endpoint(ctx, t, container, "5432/tcp")
+}
+
+// StartKafka runs a single-node KRaft broker and returns its bootstrap addresses
+func StartKafka(ctx context.Context, t testing.TB) []string {
+	t.Helper()
+	container, err := kafka.Run(ctx, KafkaImage, kafka.WithClusterID("api-server-test"))
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("failed to start Kafka: %v", err)
+	}
+	brokers, err := container.Brokers(ctx)
+	if err != nil {
+		t.Fatalf("failed to read Kafka brokers: %v", err)
+	}
+	return brokers
+}
+
+// StartFakeGCS runs fake-gcs-server over plain HTTP and returns the
+// host:port to use as STORAGE_EMULATOR_HOST
+func StartFakeGCS(ctx context.Context, t testing.TB) string {
+	t.Helper()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        FakeGCSImage,
+			Cmd:          []string{"-scheme", "http", "-port", "4443"},
+			ExposedPorts: []string{"4443/tcp"},
+			WaitingFor:   wait.ForHTTP("/storage/v1/b").WithPort("4443/tcp"),
+		},
+		Started: true,
+	})
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("failed to start fake-gcs-server: %v", err)
+	}
+
+	host, port := endpoint(ctx, t, container, "4443/tcp")
+	address := host + ":" + port
+
+	// Resumable upload URLs must point at the mapped port, which is only
+	// known once the container is running
+	body := fmt.Sprintf(`{"externalUrl": "http://%s"}`, address)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://"+address+"/_internal/config", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("failed to configure fake-gcs-server: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to configure fake-gcs-server: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to configure fake-gcs-server: status %d", resp.StatusCode)
+	}
+	return address
+}
+
+func endpoint(ctx context.Context, t testing.TB, container testcontainers.Container, port string) (string, string) {
+	t.Helper()
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to read container host: %v", err)
+	}
+	mapped, err := container.MappedPort(ctx, nat.Port(port))
+	if err != nil {
+		t.Fatalf("failed to read mapped port %s: %v", port, err)
+	}
+	return host, mapped.Port()
+}
@@ -0,0 +1,306 @@
+// internal/testutil/env.go
+package testutil
+
+import (
+	"api-server/internal/app"
+	"api-server/internal/config"
+	"api-server/internal/database"
+	"api-server/internal/migrate"
+	"api-server/internal/model"
+	"api-server/internal/publisher"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"api-server/migrations"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Env is the API running in-process against real Postgres, Kafka and
+// fake-gcs-server containers, wired by app.New like the serve command.
+// Containers and the server are torn down when the test ends.
+type Env struct {
+	Config  *config.Config
+	DB      database.Pool
+	Repo    repository.Repository
+	Storage storage.Storage
+	Brokers []string
+	Server  *httptest.Server
+}
+
+// Credentials are Basic Auth credentials for requests against Env
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Start launches the containers, applies every migration and serves the API
+// on a local httptest server. The test is skipped when Docker is unavailable.
+// Start sets STORAGE_EMULATOR_HOST, so it cannot be used from parallel tests.
+func Start(t testing.TB) *Env {
+	t.Helper()
+	skipWithoutDocker(t)
+
+	ctx := context.Background()
+	dbHost, dbPort := StartPostgres(ctx, t)
+	brokers := StartKafka(ctx, t)
+	gcsHost := StartFakeGCS(ctx, t)
+
+	// The GCS client library reads the emulator address from the environment
+	t.Setenv("STORAGE_EMULATOR_HOST", gcsHost)
+
+	cfg := config.NewConfig()
+	cfg.DBHost, cfg.DBPort = dbHost, dbPort
+	cfg.DBUser, cfg.DBPassword, cfg.DBName = dbUser, dbPassword, dbName
+	cfg.StorageEmulatorHost = gcsHost
+	cfg.GCSBucketName = "traces"
+	cfg.GCSArchiveBucketName = "traces-archive"
+	cfg.KAFKA_BROKER = brokers[0]
+	cfg.PublisherBackend = publisher.BackendKafka
+	cfg.OutboxPollInterval = 100 * time.Millisecond
+	// Fail integration tests on any drift from api/openapi.yaml
+	cfg.OpenAPIValidation = true
+
+	applyMigrations(ctx, t, cfg)
+
+	srv, err := app.New(ctx, cfg)
+	if err != nil {
+		t.Fatalf("failed to build server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	if err := srv.Storage.Connect(ctx); err != nil {
+		t.Fatalf("failed to connect to fake-gcs-server: %v", err)
+	}
+	runCtx, stop := context.WithCancel(ctx)
+	t.Cleanup(stop)
+	srv.Start(runCtx)
+
+	server := httptest.NewServer(srv.Handler)
+	t.Cleanup(server.Close)
+
+	return &Env{
+		Config:  cfg,
+		DB:      srv.DB,
+		Repo:    srv.Repo,
+		Storage: srv.Storage,
+		Brokers: brokers,
+		Server:  server,
+	}
+}
+
+// StartInMemory serves app.NewTestServer, the API on the in-memory
+// repository, storage and publisher, for handler tests that don't need the
+// real backends. Env.DB and Env.Brokers are unset. It runs without Docker.
+func StartInMemory(t testing.TB) *Env {
+	t.Helper()
+	srv, err := app.NewTestServer()
+	if err != nil {
+		t.Fatalf("failed to build server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	server := httptest.NewServer(srv.Handler)
+	t.Cleanup(server.Close)
+
+	return &Env{
+		Config:  srv.Config,
+		Repo:    srv.Repo,
+		Storage: srv.Storage,
+		Server:  server,
+	}
+}
+
+// StartDatabase runs Postgres with every migration applied and returns a
+// pool connected to it, for tests of the schema that don't need the API.
+// The test is skipped when Docker is unavailable.
+func StartDatabase(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+	skipWithoutDocker(t)
+
+	ctx := context.Background()
+	cfg := config.NewConfig()
+	cfg.DBHost, cfg.DBPort = StartPostgres(ctx, t)
+	cfg.DBUser, cfg.DBPassword, cfg.DBName = dbUser, dbPassword, dbName
+	applyMigrations(ctx, t, cfg)
+
+	db, err := database.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		t.Fatalf("failed to connect to Postgres: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+// applyMigrations brings the database cfg points at up to date
+func applyMigrations(ctx context.Context, t testing.TB, cfg *config.Config) {
+	t.Helper()
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	db, err := database.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		t.Fatalf("failed to connect to Postgres: %v", err)
+	}
+	defer db.Close()
+	if _, err := migrate.Up(ctx, db, all); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+}
+
+// CreateAdmin inserts an admin user directly and returns its credentials
+func (e *Env) CreateAdmin(t testing.TB, username string) Credentials {
+	t.Helper()
+	return e.CreateUser(t, username, "admin")
+}
+
+// CreateUser inserts a user with role directly and returns its credentials
+func (e *Env) CreateUser(t testing.TB, username, role string) Credentials {
+	t.Helper()
+	creds := Credentials{Username: username, Password: "integration-password"}
+	_, err := e.Repo.CreateUser(context.Background(), model.CreateUserRequest{
+		FirstName: "Integration",
+		LastName:  "User",
+		Username:  creds.Username,
+		Password:  creds.Password,
+		Role:      role,
+		Email:     username + "@example.com",
+	})
+	if err != nil {
+		t.Fatalf("failed to create %s %s: %v", role, username, err)
+	}
+	return creds
+}
+
+// Do sends a request to the API and returns the response with its body read
+func (e *Env) Do(t testing.TB, creds *Credentials, method, path, contentType string, body io.Reader) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, e.Server.URL+path, body)
+	if err != nil {
+		t.Fatalf("failed to build %s %s: %v", method, path, err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if creds != nil {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	resp, err := e.Server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read %s %s response: %v", method, path, err)
+	}
+	return resp, data
+}
+
+// JSON sends body as JSON, fails the test unless the response has
+// wantStatus, and decodes the response into out when out is non-nil
+func (e *Env) JSON(t testing.TB, creds *Credentials, method, path string, body any, wantStatus int, out any) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to encode %s %s body: %v", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	resp, data := e.Do(t, creds, method, path, "application/json", reader)
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, path, resp.StatusCode, wantStatus, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("failed to decode %s %s response: %v: %s", method, path, err, data)
+		}
+	}
+}
+
+// UploadTrace posts a multipart trace upload for the course
+func (e *Env) UploadTrace(t testing.TB, creds *Credentials, courseID, filename string, content []byte) (*http.Response, []byte) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("failed to build upload form: %v", err)
+	}
+	part.Write(content)
+	form.Close()
+
+	return e.Do(t, creds, http.MethodPost, fmt.Sprintf("/v1/course/%s/trace", courseID), form.FormDataContentType(), &body)
+}
+
+// ConsumeMessages reads topic from the oldest offset until n messages have
+// arrived, failing the test if that takes longer than timeout
+func (e *Env) ConsumeMessages(t testing.TB, topic string, n int, timeout time.Duration) [][]byte {
+	t.Helper()
+	consumer, err := sarama.NewConsumer(e.Brokers, sarama.NewConfig())
+	if err != nil {
+		t.Fatalf("failed to create Kafka consumer: %v", err)
+	}
+	defer consumer.Close()
+
+	deadline := time.After(timeout)
+	var partitions []int32
+	for {
+		// The topic is auto-created by the first publish, so wait for it
+		partitions, _ = consumer.Partitions(topic)
+		if len(partitions) > 0 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("topic %s was not created within %s", topic, timeout)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	messages := make(chan []byte)
+	done := make(chan struct{})
+	for _, partition := range partitions {
+		pc, err := consumer.ConsumePartition(topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			t.Fatalf("failed to consume %s partition %d: %v", topic, partition, err)
+		}
+		defer pc.Close()
+		go func() {
+			for msg := range pc.Messages() {
+				select {
+				case messages <- msg.Value:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	// Deferred last so forwarders stop before the partition consumers close
+	defer close(done)
+
+	var received [][]byte
+	for len(received) < n {
+		select {
+		case msg := <-messages:
+			received = append(received, msg)
+		case <-deadline:
+			t.Fatalf("received %d of %d messages on %s within %s", len(received), n, topic, timeout)
+		}
+	}
+	return received
+}
@@ -0,0 +1,59 @@
+// internal/testutil/statements.go
+package testutil
+
+import (
+	"api-server/internal/model"
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Statement is a SQL statement and its arguments as a model function ran it
+type Statement struct {
+	SQL  string
+	Args []any
+}
+
+// RecordStatements calls fn with db wrapped so that every statement fn runs
+// is recorded before it is passed on, and returns them in order. Tests use
+// it to check or replay exactly the SQL the model package builds, rather
+// than a copy of it. Statements run inside a transaction fn begins are not
+// recorded. The test fails if fn does.
+func RecordStatements(t testing.TB, db model.DBTX, fn func(model.DBTX) error) []Statement {
+	t.Helper()
+	recorder := &statementRecorder{DBTX: db}
+	if err := fn(recorder); err != nil {
+		t.Fatalf("failed to run the statements to record: %v", err)
+	}
+	if len(recorder.statements) == 0 {
+		t.Fatalf("no statements were run")
+	}
+	return recorder.statements
+}
+
+// statementRecorder is a model.DBTX recording what runs through it
+type statementRecorder struct {
+	model.DBTX
+	statements []Statement
+}
+
+func (r *statementRecorder) record(sql string, args []any) {
+	r.statements = append(r.statements, Statement{SQL: sql, Args: args})
+}
+
+func (r *statementRecorder) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	r.record(sql, args)
+	return r.DBTX.Exec(ctx, sql, args...)
+}
+
+func (r *statementRecorder) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	r.record(sql, args)
+	return r.DBTX.Query(ctx, sql, args...)
+}
+
+func (r *statementRecorder) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	r.record(sql, args)
+	return r.DBTX.QueryRow(ctx, sql, args...)
+}
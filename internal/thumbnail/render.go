@@ -0,0 +1,60 @@
+// internal/thumbnail/render.go
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// renderTimeout bounds rendering one page; large scans can take a while
+const renderTimeout = time.Minute
+
+// ErrUnrenderable is returned by a Renderer for a PDF it can't render, such
+// as a corrupt or encrypted one, which retrying won't fix
+var ErrUnrenderable = errors.New("PDF can't be rendered")
+
+// Renderer draws the first page of a PDF as a PNG width pixels wide
+type Renderer interface {
+	Render(ctx context.Context, pdf []byte, width int) ([]byte, error)
+}
+
+// Pdftoppm renders with poppler's pdftoppm, which the Docker image includes
+type Pdftoppm struct {
+	path string
+}
+
+func NewPdftoppm(path string) *Pdftoppm {
+	return &Pdftoppm{path: path}
+}
+
+func (p *Pdftoppm) Render(ctx context.Context, pdf []byte, width int) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	// Page 1 only, scaled to width with the aspect ratio kept, from stdin
+	// to stdout
+	cmd := exec.CommandContext(ctx, p.path, "-png", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to-x", strconv.Itoa(width), "-scale-to-y", "-1", "-")
+	cmd.Stdin = bytes.NewReader(pdf)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("pdftoppm: %w", ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("%w: %s", ErrUnrenderable, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run pdftoppm: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,167 @@
+// internal/thumbnail/thumbnail.go
+package thumbnail
+
+import (
+	"api-server/internal/config"
+	"api-server/internal/errortracking"
+	"api-server/internal/model"
+	"api-server/internal/repository"
+	"api-server/internal/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxErrorLen fits the trace_thumbnails error column
+const maxErrorLen = 500
+
+// ObjectName is where the thumbnail of a trace stored as fileName goes:
+// under thumbnails/ next to its PDF, so it shares the PDF's tenant prefix
+func ObjectName(fileName string, traceID uuid.UUID) string {
+	return path.Join(path.Dir(fileName), "thumbnails", traceID.String()+".png")
+}
+
+// Generator renders the first page of uploaded PDFs as PNG thumbnails in
+// the background, so course pages can preview traces
+type Generator struct {
+	repo      repository.Repository
+	storage   storage.Storage
+	renderer  Renderer
+	interval  time.Duration
+	batchSize int
+	width     int
+	maxBytes  int64
+}
+
+// NewGenerator builds a Generator from the THUMBNAIL_* settings, rendering
+// with pdftoppm
+func NewGenerator(repo repository.Repository, store storage.Storage, cfg *config.Config) *Generator {
+	return NewGeneratorWithRenderer(repo, store, NewPdftoppm(cfg.ThumbnailPdftoppmPath), cfg)
+}
+
+// NewGeneratorWithRenderer builds a Generator that renders with renderer
+func NewGeneratorWithRenderer(repo repository.Repository, store storage.Storage, renderer Renderer, cfg *config.Config) *Generator {
+	return &Generator{
+		repo:      repo,
+		storage:   store,
+		renderer:  renderer,
+		interval:  cfg.ThumbnailInterval,
+		batchSize: cfg.ThumbnailBatchSize,
+		width:     cfg.ThumbnailWidth,
+		maxBytes:  cfg.MaxUploadBodyBytes,
+	}
+}
+
+// Run renders pending traces on every interval until ctx is cancelled
+func (g *Generator) Run(ctx context.Context) {
+	log.Printf("Trace thumbnail generator started, interval %s", g.interval)
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	for {
+		generated, err := g.GeneratePending(ctx)
+		if err != nil {
+			log.Printf("Trace thumbnail generation failed: %v", err)
+			errortracking.Capture(err, "thumbnail", nil)
+		} else if generated > 0 {
+			log.Printf("Generated thumbnails of %d traces", generated)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GeneratePending renders up to one batch of traces that have no thumbnail
+// yet and returns how many were recorded. Traces whose PDF can't be read or
+// rendered right now are left for the next pass.
+func (g *Generator) GeneratePending(ctx context.Context) (int, error) {
+	traces, err := g.repo.GetTracesWithoutThumbnail(ctx, g.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	generated := 0
+	for _, trace := range traces {
+		if ctx.Err() != nil {
+			return generated, ctx.Err()
+		}
+
+		thumbnail, err := g.generate(ctx, trace)
+		if err != nil {
+			log.Printf("Failed to generate thumbnail of trace %s, will retry: %v", trace.ID, err)
+			continue
+		}
+		if err := g.repo.SaveTraceThumbnail(ctx, trace.ID, thumbnail); err != nil {
+			// The trace was deleted while its PDF was rendered
+			if errors.Is(err, model.ErrNotFound) {
+				continue
+			}
+			return generated, err
+		}
+		if thumbnail.Error != nil {
+			log.Printf("No thumbnail generated for trace %s: %s", trace.ID, *thumbnail.Error)
+		}
+		generated++
+	}
+	return generated, nil
+}
+
+// generate renders a trace's PDF and stores the PNG. Errors are transient,
+// such as storage being unreachable; a PDF that can't be rendered is
+// recorded as such instead.
+func (g *Generator) generate(ctx context.Context, trace model.Trace) (model.TraceThumbnail, error) {
+	if trace.ContentType != model.ContentTypePDF {
+		return failed(fmt.Sprintf("%s is not a PDF", trace.ContentType)), nil
+	}
+	object, err := g.storage.Download(ctx, trace.FileName)
+	if errors.Is(err, storage.ErrNotFound) {
+		return failed("stored object not found"), nil
+	}
+	if err != nil {
+		return model.TraceThumbnail{}, err
+	}
+	defer object.Close()
+	data, err := io.ReadAll(io.LimitReader(object, g.maxBytes))
+	if err != nil {
+		return model.TraceThumbnail{}, err
+	}
+
+	rendered, err := g.renderer.Render(ctx, data, g.width)
+	if errors.Is(err, ErrUnrenderable) {
+		return failed(err.Error()), nil
+	}
+	if err != nil {
+		return model.TraceThumbnail{}, err
+	}
+	// Check what the renderer gave back really is a PNG before serving it
+	size, err := png.DecodeConfig(bytes.NewReader(rendered))
+	if err != nil {
+		return failed(fmt.Sprintf("renderer returned an invalid PNG: %v", err)), nil
+	}
+
+	if _, err := g.storage.Upload(ctx, ObjectName(trace.FileName, trace.ID), bytes.NewReader(rendered)); err != nil {
+		return model.TraceThumbnail{}, err
+	}
+	return model.TraceThumbnail{Width: size.Width, Height: size.Height, SizeBytes: int64(len(rendered))}, nil
+}
+
+// failed records a PDF that couldn't be rendered
+func failed(reason string) model.TraceThumbnail {
+	if len(reason) > maxErrorLen {
+		reason = strings.ToValidUTF8(reason[:maxErrorLen], "")
+	}
+	return model.TraceThumbnail{Error: &reason}
+}
@@ -0,0 +1,132 @@
+// internal/validation/validation.go
+package validation
+
+import (
+	"api-server/internal/i18n"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// emailPattern matches the CHECK constraint on the users and instructors tables
+var emailPattern = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`)
+
+// slugPattern matches the CHECK constraint on tenant slugs, which double as
+// subdomains
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// FieldError describes one failed rule on one input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	// key names Message in the i18n catalog and param fills it in, so it
+	// can be localized; an error without a key keeps its message
+	key   string
+	param string
+}
+
+// NewFieldError returns the error for field failing rule, with the
+// catalog's message named key filled in with param
+func NewFieldError(field, rule, key, param string) FieldError {
+	return FieldError{
+		Field:   field,
+		Rule:    rule,
+		Message: i18n.Validation(i18n.English, key, field, param),
+		key:     key,
+		param:   param,
+	}
+}
+
+// Errors is every rule that failed for a struct, in field order
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Localize returns a copy of e with its messages in locale
+func (e Errors) Localize(locale string) Errors {
+	localized := make(Errors, len(e))
+	for i, fe := range e {
+		if fe.key != "" {
+			fe.Message = i18n.Validation(locale, fe.key, fe.Field, fe.param)
+		}
+		localized[i] = fe
+	}
+	return localized
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON names, which is what clients send
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("email_format", func(fl validator.FieldLevel) bool {
+		return emailPattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return slugPattern.MatchString(fl.Field().String())
+	})
+
+	return v
+}
+
+// Struct checks v against its `validate` struct tags and returns Errors
+// listing every failed rule, or nil when v is valid.
+func Struct(v any) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	result := make(Errors, len(fieldErrs))
+	for i, fe := range fieldErrs {
+		key, param := messageKey(fe)
+		result[i] = NewFieldError(fe.Field(), fe.Tag(), key, param)
+	}
+	return result
+}
+
+// messageKey returns the catalog key of the message for a failed rule and
+// the parameter it is filled in with
+func messageKey(fe validator.FieldError) (key, param string) {
+	isString := fe.Kind() == reflect.String
+	switch tag := fe.Tag(); tag {
+	case "required", "email_format", "slug", "http_url", "gt", "gte", "lte":
+		return tag, fe.Param()
+	case "oneof":
+		return tag, strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min", "max":
+		if isString {
+			return tag + "_chars", fe.Param()
+		}
+		return tag, fe.Param()
+	default:
+		return "rule", tag
+	}
+}
@@ -0,0 +1,4 @@
+-- migrations/006_add_trace_storage_tier.sql
+ALTER TABLE api.traces
+    ADD COLUMN storage_tier VARCHAR(10) NOT NULL DEFAULT 'standard' CHECK (storage_tier IN ('standard', 'coldline')),
+    ADD COLUMN archived_at TIMESTAMP NULL;
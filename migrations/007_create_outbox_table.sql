@@ -0,0 +1,14 @@
+-- migrations/007_create_outbox_table.sql
+CREATE TABLE api.outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    topic VARCHAR(100) NOT NULL,
+    aggregate_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'published', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_published TIMESTAMP NULL
+);
+
+CREATE INDEX outbox_pending_idx ON api.outbox (date_created) WHERE status = 'pending';
@@ -0,0 +1,12 @@
+-- migrations/008_create_audit_log_table.sql
+CREATE TABLE api.audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES api.users(id),
+    action VARCHAR(50) NOT NULL, -- e.g., course.updated
+    entity_type VARCHAR(30) NOT NULL,
+    entity_id UUID NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_log_entity_idx ON api.audit_log (entity_type, entity_id, date_created);
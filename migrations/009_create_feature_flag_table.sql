@@ -0,0 +1,8 @@
+-- migrations/009_create_feature_flag_table.sql
+-- Overrides set through the admin API; flags without a row use their configured value
+CREATE TABLE api.feature_flags (
+    name VARCHAR(50) PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    updated_by UUID REFERENCES api.users(id) ON DELETE SET NULL,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
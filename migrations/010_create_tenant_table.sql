@@ -0,0 +1,39 @@
+-- migrations/010_create_tenant_table.sql
+-- Departments hosted on one deployment. Rows that predate tenancy belong to
+-- the default tenant, which also serves every request while tenancy is off.
+CREATE TABLE api.tenants (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    slug VARCHAR(63) NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9]([a-z0-9-]*[a-z0-9])?$'), -- usable as a subdomain
+    name VARCHAR(100) NOT NULL,
+    api_key_hash CHAR(64) UNIQUE, -- SHA-256 of the tenant's API key, hex encoded
+    bucket_prefix VARCHAR(100), -- object name prefix for the tenant's traces
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO api.tenants (id, slug, name) VALUES ('00000000-0000-0000-0000-000000000001', 'default', 'Default');
+
+ALTER TABLE api.users ADD COLUMN tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES api.tenants(id);
+ALTER TABLE api.instructors ADD COLUMN tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES api.tenants(id);
+ALTER TABLE api.courses ADD COLUMN tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES api.tenants(id);
+ALTER TABLE api.traces ADD COLUMN tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES api.tenants(id);
+
+-- Usernames and emails are unique within a tenant. The constraint names are
+-- kept, since handlers map violations of them to USERNAME_TAKEN and EMAIL_TAKEN.
+ALTER TABLE api.users
+    DROP CONSTRAINT users_username_key,
+    ADD CONSTRAINT users_username_key UNIQUE (tenant_id, username),
+    DROP CONSTRAINT users_email_key,
+    ADD CONSTRAINT users_email_key UNIQUE (tenant_id, email);
+ALTER TABLE api.instructors
+    DROP CONSTRAINT instructors_email_key,
+    ADD CONSTRAINT instructors_email_key UNIQUE (tenant_id, email),
+    ADD CONSTRAINT instructors_tenant_id_id_key UNIQUE (tenant_id, id);
+
+-- A course can only name an instructor of its own tenant
+ALTER TABLE api.courses
+    ADD CONSTRAINT courses_tenant_instructor_fkey FOREIGN KEY (tenant_id, instructor_id) REFERENCES api.instructors (tenant_id, id);
+
+CREATE INDEX instructors_tenant_idx ON api.instructors (tenant_id);
+CREATE INDEX courses_tenant_idx ON api.courses (tenant_id);
+CREATE INDEX traces_tenant_course_idx ON api.traces (tenant_id, course_id);
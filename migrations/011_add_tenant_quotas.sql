@@ -0,0 +1,25 @@
+-- migrations/011_add_tenant_quotas.sql
+-- Per-tenant quotas; NULL is unlimited
+ALTER TABLE api.tenants
+    ADD COLUMN max_courses BIGINT CHECK (max_courses >= 0),
+    ADD COLUMN max_storage_bytes BIGINT CHECK (max_storage_bytes >= 0),
+    ADD COLUMN max_uploads_per_day BIGINT CHECK (max_uploads_per_day >= 0);
+
+-- Size of the uploaded object, counted against the tenant's storage. Traces
+-- uploaded before this migration count as empty.
+ALTER TABLE api.traces ADD COLUMN size_bytes BIGINT NOT NULL DEFAULT 0;
+
+-- What each tenant is using, kept current by the writes that change it.
+-- uploads_today counts uploads on upload_day (UTC) and restarts on a new day.
+CREATE TABLE api.tenant_usage (
+    tenant_id UUID PRIMARY KEY REFERENCES api.tenants(id) ON DELETE CASCADE,
+    courses BIGINT NOT NULL DEFAULT 0,
+    storage_bytes BIGINT NOT NULL DEFAULT 0,
+    uploads_today BIGINT NOT NULL DEFAULT 0,
+    upload_day DATE NOT NULL DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')::date,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO api.tenant_usage (tenant_id, courses)
+SELECT t.id, (SELECT count(*) FROM api.courses c WHERE c.tenant_id = t.id)
+FROM api.tenants t;
@@ -0,0 +1,8 @@
+-- migrations/012_add_course_storage_bytes.sql
+-- Trace bytes held by each course, checked against COURSE_MAX_STORAGE_BYTES
+ALTER TABLE api.courses ADD COLUMN storage_bytes BIGINT NOT NULL DEFAULT 0 CHECK (storage_bytes >= 0);
+
+UPDATE api.courses c
+SET storage_bytes = t.total
+FROM (SELECT course_id, sum(size_bytes) AS total FROM api.traces GROUP BY course_id) t
+WHERE t.course_id = c.id;
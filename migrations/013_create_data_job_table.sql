@@ -0,0 +1,21 @@
+-- migrations/013_create_data_job_table.sql
+-- Personal data exports and erasures, run in the background by the data job
+-- runner. A running job whose lease runs out is picked up again.
+CREATE TABLE api.data_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('export', 'erase')),
+    user_id UUID NOT NULL REFERENCES api.users(id),
+    requested_by UUID NOT NULL REFERENCES api.users(id),
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NULL,
+    result_object TEXT NULL, -- storage object holding an export's archive
+    lease_expires TIMESTAMP NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_completed TIMESTAMP NULL
+);
+
+CREATE INDEX data_jobs_user_idx ON api.data_jobs (tenant_id, user_id, date_created);
+CREATE INDEX data_jobs_runnable_idx ON api.data_jobs (date_created) WHERE status IN ('pending', 'running');
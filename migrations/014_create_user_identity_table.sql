@@ -0,0 +1,23 @@
+-- migrations/014_create_user_identity_table.sql
+-- Accounts at external identity providers (Google, GitHub, university SSO)
+-- linked to users, so a returning login finds its user by the provider's
+-- stable subject rather than by email.
+CREATE TABLE api.user_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES api.users(id) ON DELETE CASCADE,
+    provider VARCHAR(30) NOT NULL,
+    subject VARCHAR(255) NOT NULL, -- the provider's ID for the account
+    email VARCHAR(100) NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_login TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_identities_subject_key UNIQUE (tenant_id, provider, subject)
+);
+
+CREATE INDEX user_identities_user_idx ON api.user_identities (user_id);
+
+-- Users provisioned on first login get a default role that may be student
+-- or instructor, which the original check never allowed
+ALTER TABLE api.users
+    DROP CONSTRAINT users_role_check,
+    ADD CONSTRAINT users_role_check CHECK (role IN ('student', 'admin', 'instructor'));
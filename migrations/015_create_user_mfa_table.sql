@@ -0,0 +1,14 @@
+-- migrations/015_create_user_mfa_table.sql
+-- TOTP multi-factor authentication. A row is created at enrollment and
+-- enabled once the user confirms a code; recovery codes are stored as
+-- SHA-256 hashes and removed as they are used.
+CREATE TABLE api.user_mfa (
+    user_id UUID PRIMARY KEY REFERENCES api.users(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL, -- base32 TOTP secret
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    recovery_codes TEXT[] NOT NULL DEFAULT '{}',
+    last_used_step BIGINT NOT NULL DEFAULT 0, -- the last accepted time step, so a code can't be replayed
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_enabled TIMESTAMP NULL
+);
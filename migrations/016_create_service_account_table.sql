@@ -0,0 +1,16 @@
+-- migrations/016_create_service_account_table.sql
+-- Non-human principals, such as the PDF-processing consumer, that call the
+-- API with a key limited to the scopes they are granted
+CREATE TABLE api.service_accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    description VARCHAR(255) NULL,
+    scopes TEXT[] NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the account's key, hex encoded
+    created_by UUID NULL REFERENCES api.users(id) ON DELETE SET NULL,
+    last_used TIMESTAMP NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT service_accounts_name_key UNIQUE (tenant_id, name)
+);
@@ -0,0 +1,15 @@
+-- migrations/017_create_session_table.sql
+-- One row per bearer token issued by a login, named by the token's jti.
+-- Deleting the row revokes the token before it expires.
+CREATE TABLE api.sessions (
+    id UUID PRIMARY KEY,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES api.users(id) ON DELETE CASCADE,
+    user_agent VARCHAR(255) NULL,
+    ip_address VARCHAR(45) NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX sessions_user_idx ON api.sessions (user_id);
@@ -0,0 +1,4 @@
+-- migrations/018_add_outbox_failed_index.sql
+-- Lets the outbox backlog metrics count failed events without reading the
+-- published ones
+CREATE INDEX outbox_failed_idx ON api.outbox (date_created) WHERE status = 'failed';
@@ -0,0 +1,11 @@
+-- migrations/019_create_notification_preference_table.sql
+-- Which emails a user wants, one row per email type they have set. Types
+-- without a row are sent.
+CREATE TABLE api.notification_preferences (
+    user_id UUID NOT NULL REFERENCES api.users(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    event VARCHAR(50) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, event)
+);
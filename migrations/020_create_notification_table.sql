@@ -0,0 +1,22 @@
+-- migrations/020_create_notification_table.sql
+-- In-app notifications about the user's traces and the courses they upload
+-- to, newest first, unread until read_at is set
+CREATE TABLE api.notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES api.users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    message VARCHAR(500) NOT NULL,
+    course_id UUID NULL REFERENCES api.courses(id) ON DELETE CASCADE,
+    trace_id UUID NULL REFERENCES api.traces(id) ON DELETE CASCADE,
+    read_at TIMESTAMP NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX notifications_user_idx ON api.notifications (user_id, date_created);
+CREATE INDEX notifications_unread_idx ON api.notifications (user_id) WHERE read_at IS NULL;
+
+-- Preferences now cover in-app notifications as well as email
+ALTER TABLE api.notification_preferences ADD COLUMN channel VARCHAR(20) NOT NULL DEFAULT 'email';
+ALTER TABLE api.notification_preferences DROP CONSTRAINT notification_preferences_pkey;
+ALTER TABLE api.notification_preferences ADD PRIMARY KEY (user_id, channel, event);
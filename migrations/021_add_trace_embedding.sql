@@ -0,0 +1,8 @@
+-- migrations/021_add_trace_embedding.sql
+-- Trace embeddings written by the processing pipeline, searched by cosine
+-- distance within a course. The column has no fixed dimension so
+-- EMBEDDING_DIMENSIONS can change without a migration; searches scan one
+-- course's traces, which needs no vector index.
+CREATE EXTENSION IF NOT EXISTS vector;
+
+ALTER TABLE api.traces ADD COLUMN embedding vector NULL;
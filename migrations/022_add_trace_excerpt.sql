@@ -0,0 +1,4 @@
+-- migrations/022_add_trace_excerpt.sql
+-- The text the processing pipeline embedded for each trace, which search
+-- results quote as snippets
+ALTER TABLE api.traces ADD COLUMN excerpt VARCHAR(2000) NULL;
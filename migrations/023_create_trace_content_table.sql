@@ -0,0 +1,15 @@
+-- migrations/023_create_trace_content_table.sql
+-- Text extracted from each trace's PDF in the background, indexed for
+-- keyword search. A row with an error records a PDF that yielded no text,
+-- so it isn't retried.
+CREATE TABLE api.trace_contents (
+    trace_id UUID PRIMARY KEY REFERENCES api.traces(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id),
+    content TEXT NOT NULL DEFAULT '',
+    method VARCHAR(10) NOT NULL CHECK (method IN ('text', 'ocr', 'none')),
+    error VARCHAR(500) NULL,
+    search tsvector GENERATED ALWAYS AS (to_tsvector('english', content)) STORED,
+    extracted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX trace_contents_search_idx ON api.trace_contents USING GIN (search);
@@ -0,0 +1,7 @@
+-- migrations/024_add_trace_summary.sql
+-- LLM summaries of each trace's extracted text, cached until the text is
+-- extracted again
+ALTER TABLE api.trace_contents
+    ADD COLUMN summary TEXT NULL,
+    ADD COLUMN summary_model VARCHAR(100) NULL,
+    ADD COLUMN summarized_at TIMESTAMP NULL;
@@ -0,0 +1,15 @@
+-- migrations/025_create_trace_comment_table.sql
+-- Comments teaching staff leave on a trace, optionally pinned to a page of
+-- its PDF, oldest first
+CREATE TABLE api.trace_comments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    trace_id UUID NOT NULL REFERENCES api.traces(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES api.users(id) ON DELETE CASCADE,
+    page INTEGER NULL CHECK (page >= 1),
+    text VARCHAR(2000) NOT NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX trace_comments_trace_idx ON api.trace_comments (trace_id, date_created);
+CREATE INDEX trace_comments_user_idx ON api.trace_comments (user_id);
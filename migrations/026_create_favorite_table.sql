@@ -0,0 +1,11 @@
+-- migrations/026_create_favorite_table.sql
+-- Courses each user saved to their shortlist
+CREATE TABLE api.favorites (
+    user_id UUID NOT NULL REFERENCES api.users(id) ON DELETE CASCADE,
+    course_id UUID NOT NULL REFERENCES api.courses(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, course_id)
+);
+
+CREATE INDEX favorites_course_idx ON api.favorites (course_id);
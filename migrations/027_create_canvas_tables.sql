@@ -0,0 +1,55 @@
+-- migrations/027_create_canvas_tables.sql
+-- Canvas LMS sync. A tenant connects one Canvas instance and links its
+-- courses to Canvas courses; each sync pulls the roster of every linked
+-- course and pushes a page of course and trace links back to Canvas. At most
+-- one sync per tenant is pending or running at a time.
+CREATE TABLE api.canvas_connections (
+    tenant_id UUID PRIMARY KEY REFERENCES api.tenants(id) ON DELETE CASCADE,
+    base_url VARCHAR(255) NOT NULL,
+    api_token VARCHAR(255) NOT NULL,
+    link_base_url VARCHAR(255) NOT NULL, -- the front-end that pushed links point at
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE api.canvas_course_links (
+    course_id UUID PRIMARY KEY REFERENCES api.courses(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    canvas_course_id BIGINT NOT NULL CHECK (canvas_course_id >= 1),
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT canvas_course_links_canvas_course_key UNIQUE (tenant_id, canvas_course_id)
+);
+
+-- The roster pulled by the last sync, one row per Canvas user and role.
+-- Unlinking the course drops it.
+CREATE TABLE api.canvas_roster_entries (
+    course_id UUID NOT NULL REFERENCES api.canvas_course_links(course_id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    canvas_user_id BIGINT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NULL,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('teacher', 'ta', 'student', 'designer', 'observer')),
+    instructor_id UUID NULL REFERENCES api.instructors(id) ON DELETE SET NULL, -- matched by email
+    PRIMARY KEY (course_id, canvas_user_id, role)
+);
+
+CREATE TABLE api.canvas_syncs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    trigger_type VARCHAR(10) NOT NULL CHECK (trigger_type IN ('schedule', 'manual')),
+    requested_by UUID NULL REFERENCES api.users(id),
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    error TEXT NULL,
+    courses_synced INTEGER NOT NULL DEFAULT 0,
+    roster_entries INTEGER NOT NULL DEFAULT 0,
+    conflicts JSONB NOT NULL DEFAULT '[]',
+    lease_expires TIMESTAMP NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_completed TIMESTAMP NULL
+);
+
+CREATE UNIQUE INDEX canvas_syncs_active_idx ON api.canvas_syncs (tenant_id) WHERE status IN ('pending', 'running');
+CREATE INDEX canvas_syncs_tenant_idx ON api.canvas_syncs (tenant_id, date_created);
@@ -0,0 +1,6 @@
+-- migrations/028_add_course_archived_at.sql
+-- Archived courses stay readable but are left out of course listings and
+-- take no new traces
+ALTER TABLE api.courses ADD COLUMN archived_at TIMESTAMP NULL;
+
+CREATE INDEX courses_tenant_active_idx ON api.courses (tenant_id) WHERE archived_at IS NULL;
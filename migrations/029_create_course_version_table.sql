@@ -0,0 +1,27 @@
+-- migrations/029_create_course_version_table.sql
+-- Every version of a course: version 1 as created, and one more for each
+-- update that changed it, with the fields that changed from the version
+-- before. Courses that predate it start with their current state.
+CREATE TABLE api.course_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    course_id UUID NOT NULL REFERENCES api.courses(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK (version >= 1),
+    user_id UUID REFERENCES api.users(id), -- who made this version
+    name VARCHAR(100) NOT NULL,
+    semester_term VARCHAR(10) NOT NULL,
+    credit_hours INTEGER NOT NULL,
+    subject_code VARCHAR(10) NOT NULL,
+    course_number INTEGER NOT NULL, -- the course's course_id
+    semester_year INTEGER NOT NULL,
+    instructor_id UUID, -- no foreign key: the instructor may since have been deleted
+    changes JSONB NOT NULL DEFAULT '{}',
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT course_versions_course_version_key UNIQUE (course_id, version)
+);
+
+INSERT INTO api.course_versions (tenant_id, course_id, version, user_id, name, semester_term, credit_hours,
+    subject_code, course_number, semester_year, instructor_id, date_created)
+SELECT tenant_id, id, 1, user_id, name, semester_term, credit_hours, subject_code, course_id, semester_year,
+    instructor_id, date_updated
+FROM api.courses;
@@ -0,0 +1,20 @@
+-- migrations/030_add_trace_publish_status.sql
+-- How far each trace's pdf-upload event has got to Kafka, following its
+-- outbox event, so an upload accepted while the broker is down shows as
+-- publish_pending rather than passing for delivered. NULL for a trace that
+-- has no event.
+ALTER TABLE api.traces
+    ADD COLUMN publish_status VARCHAR(20) NULL
+        CHECK (publish_status IN ('publish_pending', 'published', 'publish_failed'));
+
+UPDATE api.traces t
+SET publish_status = CASE o.status
+        WHEN 'published' THEN 'published'
+        WHEN 'failed' THEN 'publish_failed'
+        ELSE 'publish_pending'
+    END
+FROM api.outbox o
+WHERE o.aggregate_id = t.id;
+
+CREATE INDEX traces_unpublished_idx ON api.traces (publish_status)
+    WHERE publish_status IN ('publish_pending', 'publish_failed');
@@ -0,0 +1,23 @@
+-- migrations/031_add_access_pattern_indexes.sql
+-- Indexes matching how the hot queries read these tables, so that listing a
+-- course's traces or courses reads one page of an index in order instead of
+-- sorting every row, and email lookups, which ignore case, stop scanning
+-- the tenant's users. TestHotQueryPlans in internal/migrate confirms each
+-- query uses its index.
+
+-- Trace listings filter on tenant and course and page newest first, with
+-- id breaking ties. This supersedes traces_tenant_course_idx.
+CREATE INDEX traces_tenant_course_created_idx ON api.traces (tenant_id, course_id, date_created DESC, id DESC);
+DROP INDEX api.traces_tenant_course_idx;
+
+-- Course listings skip archived courses and page newest first
+CREATE INDEX courses_tenant_active_created_idx ON api.courses (tenant_id, date_created DESC, id DESC)
+    WHERE archived_at IS NULL;
+DROP INDEX api.courses_tenant_active_idx;
+
+-- Registrar imports find a course by its section
+CREATE INDEX courses_tenant_section_idx ON api.courses (tenant_id, semester_year, semester_term, subject_code, course_id);
+
+-- Login with an external identity finds users by email regardless of case,
+-- which the unique (tenant_id, email) constraint can't serve
+CREATE INDEX users_tenant_email_lower_idx ON api.users (tenant_id, lower(email));
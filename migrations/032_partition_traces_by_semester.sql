@@ -0,0 +1,102 @@
+-- migrations/032_partition_traces_by_semester.sql
+-- Partitions traces by the semester they were uploaded in, following
+-- model.CurrentSemesterIndex: Spring from January, Summer from June and Fall
+-- from August. Listings and the lifecycle sweep then read per-semester
+-- indexes that stay small, and old semesters can be detached whole.
+--
+-- The primary key has to include date_created, so traces can no longer be
+-- the target of a foreign key. Deleting a trace removes its content,
+-- comments and notifications through a trigger instead.
+ALTER TABLE api.trace_contents DROP CONSTRAINT trace_contents_trace_id_fkey;
+ALTER TABLE api.trace_comments DROP CONSTRAINT trace_comments_trace_id_fkey;
+ALTER TABLE api.notifications DROP CONSTRAINT notifications_trace_id_fkey;
+
+ALTER TABLE api.traces RENAME TO traces_unpartitioned;
+ALTER INDEX api.traces_pkey RENAME TO traces_unpartitioned_pkey;
+
+CREATE TABLE api.traces (LIKE api.traces_unpartitioned INCLUDING DEFAULTS INCLUDING CONSTRAINTS)
+    PARTITION BY RANGE (date_created);
+
+ALTER TABLE api.traces
+    ADD PRIMARY KEY (id, date_created),
+    ADD FOREIGN KEY (user_id) REFERENCES api.users(id),
+    ADD FOREIGN KEY (instructor_id) REFERENCES api.instructors(id),
+    ADD FOREIGN KEY (course_id) REFERENCES api.courses(id),
+    ADD FOREIGN KEY (tenant_id) REFERENCES api.tenants(id);
+
+-- create_trace_partitions creates the partition of every semester from the
+-- one containing from_time through the one containing upto, skipping those
+-- that exist, and returns how many it created. The server calls it
+-- periodically to stay TRACE_PARTITION_MONTHS_AHEAD ahead.
+CREATE FUNCTION api.create_trace_partitions(from_time TIMESTAMP, upto TIMESTAMP) RETURNS INTEGER AS $$
+DECLARE
+    semester_start DATE;
+    semester_end DATE;
+    term TEXT;
+    partition_name TEXT;
+    created INTEGER := 0;
+BEGIN
+    -- Replicas starting together would otherwise race to create the same partition
+    PERFORM pg_advisory_xact_lock(71250002);
+
+    semester_start := CASE
+        WHEN extract(month FROM from_time) <= 5 THEN make_date(extract(year FROM from_time)::INTEGER, 1, 1)
+        WHEN extract(month FROM from_time) <= 7 THEN make_date(extract(year FROM from_time)::INTEGER, 6, 1)
+        ELSE make_date(extract(year FROM from_time)::INTEGER, 8, 1)
+    END;
+
+    WHILE semester_start <= upto LOOP
+        CASE extract(month FROM semester_start)
+            WHEN 1 THEN
+                term := 'spring';
+                semester_end := semester_start + INTERVAL '5 months';
+            WHEN 6 THEN
+                term := 'summer';
+                semester_end := semester_start + INTERVAL '2 months';
+            ELSE
+                term := 'fall';
+                semester_end := semester_start + INTERVAL '5 months';
+        END CASE;
+
+        partition_name := format('traces_%s_%s', extract(year FROM semester_start), term);
+        IF to_regclass('api.' || partition_name) IS NULL THEN
+            EXECUTE format('CREATE TABLE api.%I PARTITION OF api.traces FOR VALUES FROM (%L) TO (%L)',
+                partition_name, semester_start, semester_end);
+            created := created + 1;
+        END IF;
+
+        semester_start := semester_end;
+    END LOOP;
+
+    RETURN created;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT api.create_trace_partitions(
+    COALESCE((SELECT min(date_created) FROM api.traces_unpartitioned), LOCALTIMESTAMP),
+    LOCALTIMESTAMP + INTERVAL '12 months'
+);
+
+INSERT INTO api.traces SELECT * FROM api.traces_unpartitioned;
+DROP TABLE api.traces_unpartitioned;
+
+-- Created after the copy, and on the parent so every partition gets them
+CREATE INDEX traces_tenant_course_created_idx ON api.traces (tenant_id, course_id, date_created DESC, id DESC);
+CREATE INDEX traces_unpublished_idx ON api.traces (publish_status)
+    WHERE publish_status IN ('publish_pending', 'publish_failed');
+-- The lifecycle sweep reads traces still in standard storage oldest first,
+-- so it stops within the oldest semesters
+CREATE INDEX traces_standard_created_idx ON api.traces (date_created)
+    WHERE storage_tier = 'standard';
+
+CREATE FUNCTION api.delete_trace_dependents() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM api.trace_contents WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_comments WHERE trace_id = OLD.id;
+    DELETE FROM api.notifications WHERE trace_id = OLD.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER traces_delete_dependents AFTER DELETE ON api.traces
+    FOR EACH ROW EXECUTE FUNCTION api.delete_trace_dependents();
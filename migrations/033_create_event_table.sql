@@ -0,0 +1,48 @@
+-- migrations/033_create_event_table.sql
+-- The append-only ledger of what happened to each course and its traces:
+-- every event, numbered per aggregate by sequence and across the ledger by
+-- position. Events outlive the course or trace they describe, so neither is
+-- a foreign key, and only deleting the tenant removes them. Existing courses
+-- and uploaded traces start with their created and uploaded events, as they
+-- are now.
+CREATE TABLE api.events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    position BIGINT GENERATED ALWAYS AS IDENTITY UNIQUE,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    course_id UUID NOT NULL, -- the course the aggregate is or belongs to
+    aggregate_type VARCHAR(20) NOT NULL CHECK (aggregate_type IN ('course', 'trace')),
+    aggregate_id UUID NOT NULL,
+    sequence INTEGER NOT NULL CHECK (sequence >= 1),
+    type VARCHAR(50) NOT NULL,
+    user_id UUID, -- who caused it, NULL for the server's own jobs
+    data JSONB NOT NULL DEFAULT '{}',
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT events_aggregate_sequence_key UNIQUE (aggregate_id, sequence)
+);
+
+CREATE INDEX idx_events_course ON api.events (tenant_id, course_id, position);
+
+CREATE FUNCTION api.reject_event_update() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'api.events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER events_append_only
+    BEFORE UPDATE ON api.events
+    FOR EACH ROW EXECUTE FUNCTION api.reject_event_update();
+
+INSERT INTO api.events (tenant_id, course_id, aggregate_type, aggregate_id, sequence, type, user_id, data, date_created)
+SELECT c.tenant_id, c.id, 'course', c.id, 1, 'course.created', c.user_id, to_jsonb(c) - 'tenant_id',
+    c.date_created
+FROM api.courses c
+ORDER BY c.date_created, c.id;
+
+INSERT INTO api.events (tenant_id, course_id, aggregate_type, aggregate_id, sequence, type, user_id, data, date_created)
+SELECT t.tenant_id, t.course_id, 'trace', t.id, 1, 'trace.uploaded', t.user_id,
+    jsonb_build_object('id', t.id, 'user_id', t.user_id, 'instructor_id', t.instructor_id,
+        'status', t.status, 'vector_id', t.vector_id, 'file_name', t.file_name, 'bucket_url', t.bucket_url,
+        'storage_tier', t.storage_tier, 'date_created', t.date_created),
+    t.date_created
+FROM api.traces t
+ORDER BY t.date_created, t.id;
@@ -0,0 +1,23 @@
+-- migrations/034_create_trace_thumbnail_table.sql
+-- First-page previews rendered from each trace's PDF in the background. The
+-- PNG itself is stored next to the PDF; a row with an error records a PDF
+-- that couldn't be rendered, so it isn't retried.
+CREATE TABLE api.trace_thumbnails (
+    trace_id UUID PRIMARY KEY,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id),
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    error VARCHAR(500) NULL,
+    generated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE FUNCTION api.delete_trace_dependents() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM api.trace_contents WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_thumbnails WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_comments WHERE trace_id = OLD.id;
+    DELETE FROM api.notifications WHERE trace_id = OLD.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,4 @@
+-- migrations/035_add_trace_content_type.sql
+-- Traces recorded before other formats were accepted are all PDFs
+ALTER TABLE api.traces
+    ADD COLUMN content_type VARCHAR(100) NOT NULL DEFAULT 'application/pdf';
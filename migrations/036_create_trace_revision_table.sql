@@ -0,0 +1,27 @@
+-- migrations/036_create_trace_revision_table.sql
+-- Earlier files of traces whose file was replaced. The superseded object
+-- stays in storage under its own name; the trace holds the current file,
+-- revision count + 1.
+CREATE TABLE api.trace_revisions (
+    trace_id UUID NOT NULL,
+    revision INTEGER NOT NULL,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id),
+    file_name VARCHAR(255) NOT NULL,
+    bucket_url TEXT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    date_uploaded TIMESTAMP NOT NULL,
+    date_replaced TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (trace_id, revision)
+);
+
+CREATE OR REPLACE FUNCTION api.delete_trace_dependents() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM api.trace_contents WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_thumbnails WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_revisions WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_comments WHERE trace_id = OLD.id;
+    DELETE FROM api.notifications WHERE trace_id = OLD.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
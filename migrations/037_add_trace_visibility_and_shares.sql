@@ -0,0 +1,33 @@
+-- migrations/037_add_trace_visibility_and_shares.sql
+-- Who may read a trace, and links that let anyone holding one download its
+-- file until the link expires or is revoked. Only a hash of each link's
+-- token is kept.
+ALTER TABLE api.traces
+    ADD COLUMN visibility VARCHAR(20) NOT NULL DEFAULT 'private'
+    CHECK (visibility IN ('private', 'course-members', 'public'));
+
+CREATE TABLE api.trace_shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    trace_id UUID NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_by UUID NOT NULL REFERENCES api.users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX trace_shares_trace_idx ON api.trace_shares (trace_id);
+CREATE INDEX trace_shares_user_idx ON api.trace_shares (created_by);
+
+CREATE OR REPLACE FUNCTION api.delete_trace_dependents() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM api.trace_contents WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_thumbnails WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_revisions WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_comments WHERE trace_id = OLD.id;
+    DELETE FROM api.notifications WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_shares WHERE trace_id = OLD.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
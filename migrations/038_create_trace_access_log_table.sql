@@ -0,0 +1,32 @@
+-- migrations/038_create_trace_access_log_table.sql
+-- Every download of a trace's file and every share link issued for it: who,
+-- when, and from which address and user agent. Anonymous downloads, of
+-- public traces or through a share link, have neither user nor account.
+CREATE TABLE api.trace_access_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    trace_id UUID NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('download', 'shared_download', 'share_created')),
+    user_id UUID NULL REFERENCES api.users(id) ON DELETE SET NULL,
+    service_account_id UUID NULL REFERENCES api.service_accounts(id) ON DELETE SET NULL,
+    share_id UUID NULL REFERENCES api.trace_shares(id) ON DELETE SET NULL,
+    ip_address VARCHAR(45),
+    user_agent VARCHAR(255),
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX trace_access_log_trace_idx ON api.trace_access_log (trace_id, date_created);
+CREATE INDEX trace_access_log_user_idx ON api.trace_access_log (user_id);
+
+CREATE OR REPLACE FUNCTION api.delete_trace_dependents() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM api.trace_contents WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_thumbnails WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_revisions WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_comments WHERE trace_id = OLD.id;
+    DELETE FROM api.notifications WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_access_log WHERE trace_id = OLD.id;
+    DELETE FROM api.trace_shares WHERE trace_id = OLD.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
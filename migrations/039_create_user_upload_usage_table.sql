@@ -0,0 +1,12 @@
+-- migrations/039_create_user_upload_usage_table.sql
+-- What each user has uploaded on upload_day (UTC), counted against
+-- USER_MAX_UPLOADS_PER_DAY and USER_MAX_UPLOAD_BYTES_PER_DAY. The counts
+-- restart on the user's first upload of a new day.
+CREATE TABLE api.user_upload_usage (
+    user_id UUID PRIMARY KEY REFERENCES api.users(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    uploads BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    upload_day DATE NOT NULL DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')::date,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,7 @@
+-- migrations/040_add_user_account_status.sql
+-- Account status admins manage: a deactivated user can't log in or use
+-- their tokens, and a user whose password reset is required can do nothing
+-- but change it.
+ALTER TABLE api.users
+    ADD COLUMN deactivated_at TIMESTAMP,
+    ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,5 @@
+-- migrations/041_add_session_impersonated_by.sql
+-- Sessions an admin started as another user to reproduce their issues name
+-- the admin. They end with the admin's account.
+ALTER TABLE api.sessions
+    ADD COLUMN impersonated_by UUID NULL REFERENCES api.users(id) ON DELETE CASCADE;
@@ -0,0 +1,13 @@
+-- migrations/042_create_user_avatars_table.sql
+-- Users' profile pictures. Each is a square PNG in storage, under the
+-- tenant's bucket prefix; this records where, and goes with the user.
+CREATE TABLE api.user_avatars (
+    user_id UUID PRIMARY KEY REFERENCES api.users(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    object_name TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    date_updated TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX user_avatars_tenant_idx ON api.user_avatars (tenant_id);
@@ -0,0 +1,17 @@
+-- migrations/043_add_mfa_attempt_limits.sql
+-- Wrong MFA codes are counted so they can't be guessed. A user who fails
+-- too many in a row is locked out until locked_until, and each MFA
+-- challenge a login returns takes a limited number of codes.
+ALTER TABLE api.user_mfa
+    ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN locked_until TIMESTAMP NULL;
+
+CREATE TABLE api.mfa_challenge_attempts (
+    challenge_id UUID PRIMARY KEY, -- the jti of the challenge token
+    tenant_id UUID NOT NULL REFERENCES api.tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES api.users(id) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX mfa_challenge_attempts_user_idx ON api.mfa_challenge_attempts (user_id);
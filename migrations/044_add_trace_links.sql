@@ -0,0 +1,4 @@
+-- migrations/044_add_trace_links.sql
+-- A video link has no stored file: its file_name and bucket_url are empty
+ALTER TABLE api.traces
+    ADD COLUMN link_url TEXT;
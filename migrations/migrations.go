@@ -0,0 +1,9 @@
+// migrations/migrations.go
+package migrations
+
+import "embed"
+
+// FS holds the numbered SQL migrations so the binary can apply them itself
+//
+//go:embed *.sql
+var FS embed.FS